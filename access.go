@@ -0,0 +1,146 @@
+package store
+
+import (
+	"cmp"
+	"container/heap"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AccessInfo - история обращений к ключу, см. Store.AccessInfo.
+type AccessInfo struct {
+	CreatedAt      time.Time `json:"createdAt"`               // когда ключ появился, перезапись значения его не сбрасывает
+	UpdatedAt      time.Time `json:"updatedAt"`               // последняя запись значения
+	LastAccessedAt time.Time `json:"lastAccessedAt,omitzero"` // последнее чтение, нулевое - ключ не читали
+	Views          uint64    `json:"views"`
+}
+
+// touch отмечает чтение элемента и возвращает новое число просмотров
+func (it *Item) touch(now time.Time) uint64 {
+	it.accessedAt.Store(now.UnixNano())
+	it.markRead()
+	return it.Views.Add(1)
+}
+
+// lastAccessed - время последнего чтения, нулевое если элемент не читали
+func (it *Item) lastAccessed() time.Time {
+	if ns := it.accessedAt.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// AccessInfo возвращает историю обращений к ключу: когда он создан, записан и последний раз прочитан.
+// Сам вызов чтением не считается. false - ключа нет или он истёк.
+func (s *Store) AccessInfo(key string) (AccessInfo, bool) {
+	item, ok := s.loadItem(s.skey(key))
+
+	if !ok || item.expiredAt(s.now()) {
+		return AccessInfo{}, false
+	}
+	return AccessInfo{
+		CreatedAt:      item.CreatedAt,
+		UpdatedAt:      item.UpdatedAt,
+		LastAccessedAt: item.lastAccessed(),
+		Views:          item.Views.Load(),
+	}, true
+}
+
+// Touch отмечает ключ использованным, не читая значение: обновляет время последнего чтения,
+// частоту для WithAdmission и место в стеке последних ключей с WithTouchOnGet. С views
+// Touch считается просмотром и увеличивает Views, кроме ключей SetWithMaxViews: их лимит
+// тратят только настоящие чтения. Счетчики попаданий не меняются. false - ключа нет или он истёк.
+func (s *Store) Touch(key string, views bool) bool {
+	return s.touchKey(s.skey(key), views, s.now())
+}
+
+// TouchMany - Touch для нескольких ключей, возвращает число найденных.
+func (s *Store) TouchMany(views bool, keys ...string) int {
+	now := s.now()
+	n := 0
+	for _, key := range keys {
+		if s.touchKey(s.skey(key), views, now) {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Store) touchKey(key string, views bool, now time.Time) bool {
+	item, ok := s.loadItem(key)
+	if !ok || item.expiredAt(now) {
+		return false
+	}
+	if views && item.maxViews == 0 {
+		s.countView(key, item, now)
+	} else {
+		item.accessedAt.Store(now.UnixNano())
+	}
+	s.recordAccess(key)
+	if s.cfg.touchOnGet {
+		s.push(key)
+	}
+	return true
+}
+
+// KeyViews - ключ с числом просмотров, см. TopViewed.
+type KeyViews struct {
+	Key   string `json:"key"`
+	Views uint64 `json:"views"`
+}
+
+// TopViewed возвращает n ключей с наибольшим Views, от самых просматриваемых.
+// Ключи с нулём просмотров и истекшие не попадают. Проход по всем ключам держит
+// min-кучу из n элементов, так что стоит O(N log n) и не копирует всё хранилище.
+func (s *Store) TopViewed(n int) []KeyViews {
+	if n <= 0 {
+		return nil
+	}
+	now := s.now()
+	top := make(viewsHeap, 0, n)
+
+	s.mu.RLock()
+	for raw, item := range s.data {
+		views := item.Views.Load()
+		if views == 0 || item.expiredAt(now) {
+			continue
+		}
+		if len(top) == n && views <= top[0].Views {
+			continue
+		}
+		key, ok := s.userKey(raw)
+		if !ok {
+			continue
+		}
+		if len(top) < n {
+			heap.Push(&top, KeyViews{Key: key, Views: views})
+		} else {
+			top[0] = KeyViews{Key: key, Views: views}
+			heap.Fix(&top, 0)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(top, func(a, b KeyViews) int {
+		if a.Views != b.Views {
+			return cmp.Compare(b.Views, a.Views)
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return top
+}
+
+// viewsHeap - min-куча по Views для TopViewed
+type viewsHeap []KeyViews
+
+func (h viewsHeap) Len() int           { return len(h) }
+func (h viewsHeap) Less(i, j int) bool { return h[i].Views < h[j].Views }
+func (h viewsHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *viewsHeap) Push(x any)        { *h = append(*h, x.(KeyViews)) }
+func (h *viewsHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
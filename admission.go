@@ -0,0 +1,147 @@
+package store
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// Admission - что делать с новым ключом, которого TinyLFU не считает достаточно частым,
+// см. WithAdmission.
+type Admission int
+
+const (
+	// AdmissionOff - фильтр выключен, любой новый ключ вытесняет старые.
+	AdmissionOff Admission = iota
+	// AdmissionReject - редкий новый ключ не записывается, Set при этом не возвращает ошибку.
+	AdmissionReject
+	// AdmissionDemote - редкий новый ключ записывается с PriorityLow и уйдёт первым.
+	AdmissionDemote
+)
+
+const (
+	sketchDepth = 4  // строк count-min sketch
+	sketchMax   = 15 // потолок счетчика, как у 4-битных счетчиков TinyLFU
+
+	minSketchWidth = 1 << 10
+	maxSketchWidth = 1 << 20
+)
+
+// WithAdmission включает фильтр допуска TinyLFU для лимита WithMaxMemory. Хранилище
+// приблизительно считает частоту обращений ко всем ключам, в том числе отсутствующим
+// (промахи Get и записи). Когда новому ключу не хватает места, его частота сравнивается
+// с частотой ключа, которого пришлось бы вытеснить: если новый ключ не чаще, он отклоняется
+// или понижается до PriorityLow, и однократный обход множества ключей не вымывает горячие.
+// Само вытеснение тоже выбирает из случайной выборки самый редкий ключ, а не самый старый.
+//
+// Фильтр действует на Set, SetWithPriority, SetWithProvenance и записи загрузчика.
+// Перезапись существующих ключей и записи с PriorityHigh проходят всегда. Частоты
+// периодически делятся пополам, так что фильтр подстраивается под смену нагрузки.
+func WithAdmission(mode Admission) Option {
+	return func(c *config) {
+		c.admission = mode
+	}
+}
+
+// frequencySketch - count-min sketch частот со старением, как в TinyLFU: после
+// resetAt увеличений все счетчики делятся пополам
+type frequencySketch struct {
+	seed maphash.Seed
+
+	mu        sync.Mutex
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// newFrequencySketch подбирает ширину под число элементов, которое примерно влезает в maxMemory
+func newFrequencySketch(maxMemory int64) *frequencySketch {
+	width := minSketchWidth
+	for int64(width) < maxMemory/itemOverhead && width < maxSketchWidth {
+		width <<= 1
+	}
+	f := &frequencySketch{
+		seed:    maphash.MakeSeed(),
+		mask:    uint64(width - 1),
+		resetAt: 10 * width,
+	}
+	for i := range f.rows {
+		f.rows[i] = make([]uint8, width)
+	}
+	return f
+}
+
+// index - позиция ключа в строке row, двойное хеширование одного maphash
+func (f *frequencySketch) index(h uint64, row int) uint64 {
+	return (h + uint64(row)*(h>>32|1)) & f.mask
+}
+
+// increment учитывает обращение к ключу
+func (f *frequencySketch) increment(key string) {
+	h := maphash.String(f.seed, key)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	added := false
+	for i := range f.rows {
+		if c := &f.rows[i][f.index(h, i)]; *c < sketchMax {
+			*c++
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	if f.additions++; f.additions >= f.resetAt {
+		for i := range f.rows {
+			for j := range f.rows[i] {
+				f.rows[i][j] >>= 1
+			}
+		}
+		f.additions /= 2
+	}
+}
+
+// estimate - оценка частоты ключа, минимум по строкам
+func (f *frequencySketch) estimate(key string) uint8 {
+	h := maphash.String(f.seed, key)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	est := uint8(sketchMax)
+	for i := range f.rows {
+		est = min(est, f.rows[i][f.index(h, i)])
+	}
+	return est
+}
+
+// recordAccess учитывает обращение к ключу хранения для WithAdmission
+func (s *Store) recordAccess(key string) {
+	if s.freq != nil {
+		s.freq.increment(key)
+	}
+}
+
+// admitLocked решает, записывать ли новый ключ key, которому не хватает места. В режиме
+// AdmissionDemote может понизить приоритет ещё не опубликованного it. Вызывается под s.mu.Lock
+func (s *Store) admitLocked(key string, it *Item, now time.Time) bool {
+	if s.freq == nil || it.priority == PriorityHigh {
+		return true
+	}
+	if _, ok := s.data[key]; ok || s.memUsed+itemSize(key, it) <= s.cfg.maxMemory {
+		return true
+	}
+	victim, ok := s.victimLocked(now, key)
+	if !ok || s.data[victim].expiredAt(now) || s.freq.estimate(key) > s.freq.estimate(victim) {
+		return true
+	}
+	if s.cfg.admission == AdmissionDemote {
+		it.priority = PriorityLow
+		s.stats.admissionDemoted.Add(1)
+		return true
+	}
+	s.stats.admissionRejected.Add(1)
+	s.cfg.logger.Debug("store: key rejected by admission filter", "key", key, "victim", victim)
+	return false
+}
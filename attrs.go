@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"maps"
+)
+
+type attrsKey struct{}
+
+// WithAttrs возвращает контекст с атрибутами запроса (ID запроса, арендатор), по которым
+// внешние системы связывают работу кеша с запросами. Атрибуты добавляются к уже переданным
+// в ctx, одноимённые заменяются. Их получают записи методов с контекстом (SetContext,
+// DeleteContext, TrySet, записи LoadingStore):
+//
+//   - AuditRecord.Attrs - атрибуты запроса, сделавшего изменение;
+//   - Mutation.Attrs приёмника WithSink - тоже;
+//   - Event.Attrs подписки Watch - атрибуты запроса, записавшего значение, в том числе для
+//     его удаления, истечения и вытеснения: сами они не действие запроса;
+//   - контекст колбэка WithExpireCallbackContext - так же, через AttrsFrom.
+//
+// Загрузчик LoadingStore получает контекст Get, так что атрибуты доступны и ему, в том
+// числе при фоновом обновлении, которое запустил этот Get.
+func WithAttrs(ctx context.Context, attrs map[string]string) context.Context {
+	merged := maps.Clone(AttrsFrom(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(attrs))
+	}
+	maps.Copy(merged, attrs)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// AttrsFrom возвращает атрибуты из контекста, nil - если их не передали. Мапа общая с
+// контекстом, изменять её нельзя.
+func AttrsFrom(ctx context.Context) map[string]string {
+	attrs, _ := ctx.Value(attrsKey{}).(map[string]string)
+	return attrs
+}
+
+// ctxOpts - writeOpts записи с контекстом: автор и атрибуты запроса
+func ctxOpts(ctx context.Context) writeOpts {
+	return writeOpts{actor: ActorFrom(ctx), attrs: AttrsFrom(ctx)}
+}
+
+// attrsContext - контекст колбэка с атрибутами записи значения
+func attrsContext(attrs map[string]string) context.Context {
+	if attrs == nil {
+		return context.Background()
+	}
+	return context.WithValue(context.Background(), attrsKey{}, attrs)
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Операции журнала аудита, см. AuditRecord.Op.
+const (
+	AuditSet    = "set"
+	AuditDelete = "delete"
+	AuditReset  = "reset"
+)
+
+// AuditRecord - запись журнала аудита: кто, что и когда изменил.
+type AuditRecord struct {
+	At    time.Time `json:"at"`
+	Actor string    `json:"actor,omitempty"` // из WithActor, пустой - автор не передан
+	Op    string    `json:"op"`              // AuditSet, AuditDelete или AuditReset
+	Key   string    `json:"key,omitempty"`   // для AuditReset - префикс ResetMatching, пустой для всего хранилища
+
+	Attrs map[string]string `json:"attrs,omitempty"` // атрибуты запроса, см. WithAttrs
+}
+
+// WithAuditLog хранит последние capacity записей аудита для Set, Delete и Reset (и их
+// вариантов с контекстом), записей Txn и Pipeline, см. AuditTail и ExportAudit. Автора записи передают через
+// контекст: SetContext(WithActor(ctx, "alice"), ...).
+func WithAuditLog(capacity int) Option {
+	return func(c *config) {
+		c.auditCapacity = capacity
+	}
+}
+
+// WithAuditHook вызывает fn на каждую запись аудита синхронно, после того как изменение
+// применено и блокировки хранилища отпущены, например для отправки во внешний журнал.
+// Работает и без WithAuditLog.
+func WithAuditHook(fn func(AuditRecord)) Option {
+	return func(c *config) {
+		c.auditHook = fn
+	}
+}
+
+type actorKey struct{}
+
+// WithActor возвращает контекст с автором изменений для журнала аудита.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom возвращает автора из контекста, пустую строку - если его не передали.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// auditRing - ограниченное кольцо записей аудита, при переполнении вытесняется самая старая
+type auditRing struct {
+	mu   sync.Mutex
+	buf  []AuditRecord
+	head int // индекс самой старой записи
+	n    int
+}
+
+func (r *auditRing) push(rec AuditRecord) {
+	r.mu.Lock()
+	r.buf[(r.head+r.n)%len(r.buf)] = rec
+	if r.n < len(r.buf) {
+		r.n++
+	} else {
+		r.head = (r.head + 1) % len(r.buf)
+	}
+	r.mu.Unlock()
+}
+
+// tail возвращает до n последних записей, от старых к новым, n < 0 - все
+func (r *auditRing) tail(n int) []AuditRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n < 0 || n > r.n {
+		n = r.n
+	}
+	res := make([]AuditRecord, n)
+	for i := range n {
+		res[i] = r.buf[(r.head+r.n-n+i)%len(r.buf)]
+	}
+	return res
+}
+
+// audit записывает изменение в журнал аудита и передаёт его в WithAuditHook
+func (s *Store) audit(op, key string, w writeOpts) {
+	if s.auditLog == nil && s.cfg.auditHook == nil {
+		return
+	}
+	rec := AuditRecord{At: s.now(), Actor: w.actor, Op: op, Key: key, Attrs: w.attrs}
+	if s.auditLog != nil {
+		s.auditLog.push(rec)
+	}
+	if s.cfg.auditHook != nil {
+		s.cfg.auditHook(rec)
+	}
+}
+
+// AuditTail возвращает до n последних записей аудита, от старых к новым.
+// Без WithAuditLog возвращает nil.
+func (s *Store) AuditTail(n int) []AuditRecord {
+	if s.auditLog == nil || n <= 0 {
+		return nil
+	}
+	return s.auditLog.tail(n)
+}
+
+// ExportAudit пишет весь журнал аудита в w в формате JSON Lines, от старых записей к новым.
+func (s *Store) ExportAudit(w io.Writer) error {
+	if s.auditLog == nil {
+		return nil
+	}
+	enc := json.NewEncoder(w)
+	for _, rec := range s.auditLog.tail(-1) {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
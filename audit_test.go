@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+// wantAudit сравнивает операции и ключи последних записей аудита с want ("op key")
+func wantAudit(t *testing.T, s *Store, want ...string) []AuditRecord {
+	t.Helper()
+	recs := s.AuditTail(len(want) + 1)
+	got := make([]string, len(recs))
+	for i, rec := range recs {
+		got[i] = rec.Op + " " + rec.Key
+	}
+	if len(got) != len(want) {
+		t.Fatalf("audit = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("audit = %q, want %q", got, want)
+		}
+	}
+	return recs
+}
+
+func TestAuditRecordsActor(t *testing.T) {
+	var hooked []AuditRecord
+	s, err := New(WithAuditLog(16), WithAuditHook(func(rec AuditRecord) { hooked = append(hooked, rec) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithActor(context.Background(), "alice")
+	if err := s.SetContext(ctx, "k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteContext(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	s.Set("anon", "v", 0)
+
+	recs := wantAudit(t, s, "set k", "delete k", "set anon")
+	if recs[0].Actor != "alice" || recs[1].Actor != "alice" || recs[2].Actor != "" {
+		t.Errorf("actors = %q, %q, %q", recs[0].Actor, recs[1].Actor, recs[2].Actor)
+	}
+	if len(hooked) != len(recs) {
+		t.Errorf("hook got %d records, log has %d", len(hooked), len(recs))
+	}
+}
+
+func TestAuditTxnAndPipeline(t *testing.T) {
+	s, err := New(WithAuditLog(16), WithKeyVersion("v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("old", "x", 0)
+	err = s.Txn(func(tx *Txn) error {
+		tx.Set("a", "1", 0)
+		tx.Delete("old")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAudit(t, s, "set old", "set a", "delete old")
+
+	if _, err := s.Pipeline().Set("b", "2", 0).Delete("a").Commit(); err != nil {
+		t.Fatal(err)
+	}
+	wantAudit(t, s, "set old", "set a", "delete old", "set b", "delete a")
+
+	// неудачная транзакция ничего не пишет в журнал
+	s.Txn(func(tx *Txn) error {
+		tx.Get("b")
+		tx.Set("c", "3", 0)
+		s.Set("b", "changed", 0)
+		return nil
+	})
+	wantAudit(t, s, "set old", "set a", "delete old", "set b", "delete a", "set b")
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// maxBitOffset - как в Redis, битовая строка не больше 512MB
+const maxBitOffset = 1<<32 - 1
+
+// ErrBitOffset - смещение бита за пределами 512MB.
+var ErrBitOffset = errors.New("store: bit offset is out of range")
+
+// SetBit устанавливает бит offset строкового значения key и возвращает его прежнее значение.
+// Биты нумеруются как в Redis: бит 0 - старший бит первого байта. Строка дополняется
+// нулевыми байтами до нужной длины, отсутствующий ключ создаётся с TTL по умолчанию
+// (WithDefaultTTL). Если ключ хранит не строку, возвращается ErrWrongType.
+//
+// Значения не меняются на месте, поэтому SetBit копирует строку: битовые карты
+// подходят для флагов и ежедневных активных пользователей, а не для гигабайтных массивов.
+func (s *Store) SetBit(key string, offset uint64, value bool) (bool, error) {
+	if offset > maxBitOffset {
+		return false, ErrBitOffset
+	}
+
+	var old bool
+	err := s.update(key, kindString, func(next *Item) bool {
+		cur := next.plain() // битовые операции работают с несжатой строкой
+		i, mask := offset/8, byte(0x80>>(offset%8))
+		if i < uint64(len(cur)) {
+			old = cur[i]&mask != 0
+		}
+		if old == value {
+			return false
+		}
+
+		b := make([]byte, max(uint64(len(cur)), i+1))
+		copy(b, cur)
+		if value {
+			b[i] |= mask
+		} else {
+			b[i] &^= mask
+		}
+		next.Value, next.rawSize = string(b), 0
+		return true
+	})
+	return old, err
+}
+
+// GetBit возвращает бит offset строкового значения key. Бит за концом строки,
+// отсутствующий ключ и ключ другого типа дают false.
+func (s *Store) GetBit(key string, offset uint64) bool {
+	item, ok := s.view(key, kindString)
+	if !ok {
+		return false
+	}
+	v := item.plain()
+	if offset/8 >= uint64(len(v)) {
+		return false
+	}
+	return v[offset/8]&(0x80>>(offset%8)) != 0
+}
+
+// BitCount возвращает число единичных бит строкового значения key,
+// 0 - если ключа нет или он хранит не строку.
+func (s *Store) BitCount(key string) int {
+	item, ok := s.view(key, kindString)
+	if !ok {
+		return 0
+	}
+	v := item.plain()
+	n := 0
+	for i := 0; i < len(v); i++ {
+		n += bits.OnesCount8(v[i])
+	}
+	return n
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// WithBloomFilter включает фильтр Блума по ключам хранения: Get и чтения коллекций для
+// ключа, которого в фильтре точно нет, возвращают промах без блокировок и без обращения
+// к мапе. Полезно, когда большая часть чтений - промахи по огромному пространству ключей.
+// expectedKeys и fpRate (доля ложных срабатываний, например 0.01) задают размер фильтра.
+//
+// Удаления фильтр не забывает, поэтому со временем он заполняется. Проход очистки
+// перестраивает фильтр по текущим ключам, когда оценка ложных срабатываний вдвое
+// превышает fpRate. Состояние фильтра - в Stats.Bloom.
+func WithBloomFilter(expectedKeys int, fpRate float64) Option {
+	return func(c *config) {
+		c.bloomKeys = expectedKeys
+		c.bloomFPRate = fpRate
+	}
+}
+
+// BloomStats - состояние фильтра WithBloomFilter.
+type BloomStats struct {
+	Bits           uint64  `json:"bits"`
+	Hashes         int     `json:"hashes"`
+	Skipped        uint64  `json:"skipped"`        // промахов отдано фильтром без обращения к мапе
+	FalsePositives uint64  `json:"falsePositives"` // фильтр пропустил чтение, а ключа не оказалось
+	FillRatio      float64 `json:"fillRatio"`      // доля установленных битов
+	EstimatedFP    float64 `json:"estimatedFP"`    // оценка доли ложных срабатываний по заполнению
+	Rebuilds       uint64  `json:"rebuilds"`
+}
+
+// bloomFilter - битовый массив с атомарными словами: читатели не берут блокировок,
+// добавляют ключи под s.mu.Lock. Перестройка создаёт новый фильтр и подменяет указатель
+type bloomFilter struct {
+	words  []atomic.Uint64
+	mask   uint64
+	hashes int
+}
+
+func newBloomFilter(expectedKeys int, fpRate float64) *bloomFilter {
+	n := float64(max(expectedKeys, 1))
+	m := uint64(math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	size := uint64(64)
+	for size < m {
+		size <<= 1
+	}
+	k := int(math.Round(float64(size) / n * math.Ln2))
+	return &bloomFilter{
+		words:  make([]atomic.Uint64, size/64),
+		mask:   size - 1,
+		hashes: min(max(k, 1), 16),
+	}
+}
+
+// positions вызывает fn для каждого бита ключа, двойное хеширование одного maphash
+func (b *bloomFilter) positions(h uint64, fn func(bit uint64) bool) {
+	step := h>>32 | 1
+	for i := range b.hashes {
+		if !fn((h + uint64(i)*step) & b.mask) {
+			return
+		}
+	}
+}
+
+func (b *bloomFilter) add(h uint64) {
+	b.positions(h, func(bit uint64) bool {
+		b.words[bit/64].Or(1 << (bit % 64))
+		return true
+	})
+}
+
+func (b *bloomFilter) mayContain(h uint64) bool {
+	found := true
+	b.positions(h, func(bit uint64) bool {
+		found = b.words[bit/64].Load()&(1<<(bit%64)) != 0
+		return found
+	})
+	return found
+}
+
+func (b *bloomFilter) fillRatio() float64 {
+	set := 0
+	for i := range b.words {
+		set += bits.OnesCount64(b.words[i].Load())
+	}
+	return float64(set) / float64(b.mask+1)
+}
+
+// bloomSeed - общий для всех фильтров процесса, перестройка хеши не меняет
+var bloomSeed = maphash.MakeSeed()
+
+// bloomAddLocked добавляет ключ хранения в фильтр, вызывается под s.mu.Lock до публикации элемента
+func (s *Store) bloomAddLocked(key string) {
+	if b := s.bloom.Load(); b != nil {
+		b.add(maphash.String(bloomSeed, key))
+	}
+}
+
+// bloomAbsent - ключа хранения точно нет, чтение можно завершить промахом
+func (s *Store) bloomAbsent(key string) bool {
+	b := s.bloom.Load()
+	if b == nil || b.mayContain(maphash.String(bloomSeed, key)) {
+		return false
+	}
+	s.stats.bloomSkipped.Add(1)
+	return true
+}
+
+// bloomMiss учитывает ложное срабатывание: фильтр пропустил чтение отсутствующего ключа
+func (s *Store) bloomMiss() {
+	if s.bloom.Load() != nil {
+		s.stats.bloomFalsePositives.Add(1)
+	}
+}
+
+// resetBloomLocked перестраивает фильтр по текущим ключам, вызывается под s.mu.Lock
+func (s *Store) resetBloomLocked() {
+	if s.bloom.Load() == nil {
+		return
+	}
+	b := newBloomFilter(max(s.cfg.bloomKeys, len(s.data)), s.cfg.bloomFPRate)
+	for key := range s.data {
+		b.add(maphash.String(bloomSeed, key))
+	}
+	s.bloom.Store(b)
+	s.stats.bloomRebuilds.Add(1)
+}
+
+// refreshBloom перестраивает переполненный удалёнными ключами фильтр, см. WithBloomFilter
+func (s *Store) refreshBloom() {
+	b := s.bloom.Load()
+	if b == nil || math.Pow(b.fillRatio(), float64(b.hashes)) <= 2*s.cfg.bloomFPRate {
+		return
+	}
+	s.mu.Lock()
+	s.resetBloomLocked()
+	keys := len(s.data)
+	s.mu.Unlock()
+	s.cfg.logger.Debug("store: bloom filter rebuilt", "keys", keys)
+}
+
+func (s *Store) bloomStats() *BloomStats {
+	b := s.bloom.Load()
+	if b == nil {
+		return nil
+	}
+	fill := b.fillRatio()
+	return &BloomStats{
+		Bits:           b.mask + 1,
+		Hashes:         b.hashes,
+		Skipped:        s.stats.bloomSkipped.Load(),
+		FalsePositives: s.stats.bloomFalsePositives.Load(),
+		FillRatio:      fill,
+		EstimatedFP:    math.Pow(fill, float64(b.hashes)),
+		Rebuilds:       s.stats.bloomRebuilds.Load(),
+	}
+}
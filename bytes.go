@@ -0,0 +1,40 @@
+package store
+
+import (
+	"time"
+	"unsafe"
+)
+
+// WithZeroCopyBytes убирает копирование в SetBytes и GetBytes для горячих путей.
+// SetBytes забирает срез себе: после вызова его нельзя менять. GetBytes отдаёт срез поверх
+// хранимой строки: его нельзя менять никогда, иначе изменятся значение в хранилище и все
+// строки, уже полученные через Get. Без опции оба метода копируют и срезы принадлежат вызывающему.
+// Get и FullList отдают строки: они неизменяемы, поэтому всегда делят память с хранилищем
+// без копирования, и опция на них не влияет. Список без значений - FullListFields.
+func WithZeroCopyBytes() Option {
+	return func(c *config) {
+		c.zeroCopyBytes = true
+	}
+}
+
+// SetBytes сохраняет бинарное значение как Set. Без WithZeroCopyBytes b копируется
+// и его можно менять после вызова.
+func (s *Store) SetBytes(key string, b []byte, ttl time.Duration) error {
+	if s.cfg.zeroCopyBytes {
+		return s.Set(key, unsafe.String(unsafe.SliceData(b), len(b)), ttl)
+	}
+	return s.Set(key, string(b), ttl)
+}
+
+// GetBytes возвращает значение как срез байт, false - ключа нет или он истёк.
+// Без WithZeroCopyBytes срез - копия значения, которой владеет вызывающий.
+func (s *Store) GetBytes(key string) ([]byte, bool) {
+	v, ok := s.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if s.cfg.zeroCopyBytes {
+		return unsafe.Slice(unsafe.StringData(v), len(v)), true
+	}
+	return []byte(v), true
+}
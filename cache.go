@@ -0,0 +1,47 @@
+package store
+
+import "time"
+
+// Cache - операции с ключами и строковыми значениями, которые реализует *Store.
+// Код, который зависит от Cache, а не от *Store, можно тестировать с моком, а хранилище
+// оборачивать декораторами логирования, метрик, трассировки или внедрения ошибок.
+// Декоратору удобно встроить Cache и переопределить только нужные методы:
+//
+//	type logged struct{ store.Cache }
+//
+//	func (l logged) Set(key, value string, ttl time.Duration) error {
+//		err := l.Cache.Set(key, value, ttl)
+//		slog.Debug("cache set", "key", key, "err", err)
+//		return err
+//	}
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key, value string, ttl time.Duration) error
+	Delete(key string)
+
+	SetNX(key, value string, ttl time.Duration) bool
+	SetXX(key, value string, ttl time.Duration) bool
+	CompareAndDelete(key, value string) bool
+	IncrBy(key string, delta int64) (int64, error)
+
+	TTL(key string) (time.Duration, bool)
+	Expire(key string, ttl time.Duration) bool
+
+	Keys(pattern string) []string
+	Size() int
+	Stats() Stats
+}
+
+var _ Cache = (*Store)(nil)
+
+// Decorator оборачивает Cache, см. Decorate.
+type Decorator func(Cache) Cache
+
+// Decorate оборачивает c декораторами по порядку: первый из decorators оказывается
+// ближе всего к c, последний - снаружи и первым видит вызов.
+func Decorate(c Cache, decorators ...Decorator) Cache {
+	for _, d := range decorators {
+		c = d(c)
+	}
+	return c
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// CDCWriter - Sink, который пишет каждое изменение строкой JSON (JSON Lines) в io.Writer:
+// так второй процесс может зеркалировать кеш (ApplyMutation) или строить по нему индексы.
+// Подключается через WithSink, очередь которого и даёт обратное давление: пока writer
+// не успевает, изменения копятся в очереди, а на заполненной очереди запись в хранилище
+// ждёт или изменение отбрасывается (SinkOptions.DropWhenFull).
+//
+// В поток попадают те же изменения, что и в любой приёмник WithSink, включая массовые
+// (Reset - строкой EventFlush) и коллекции с их типом в Kind. Истечение TTL и вытеснение
+// зеркало применяет само по ExpiresAt.
+type CDCWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewCDCWriter возвращает CDCWriter поверх w. Если w - *bufio.Writer, он сбрасывается
+// после каждой пачки.
+func NewCDCWriter(w io.Writer) *CDCWriter {
+	return &CDCWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+// Write пишет пачку изменений по строке на изменение.
+func (c *CDCWriter) Write(ctx context.Context, batch []Mutation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range batch {
+		if err := c.enc.Encode(m); err != nil {
+			return fmt.Errorf("store: cdc write: %w", err)
+		}
+	}
+	if bw, ok := c.w.(*bufio.Writer); ok {
+		if err := bw.Flush(); err != nil {
+			return fmt.Errorf("store: cdc flush: %w", err)
+		}
+	}
+	return nil
+}
+
+// CDCChannel - Sink, который отправляет изменения в канал. Если канал не вычитывают,
+// Write ждёт до отмены своего контекста, а изменения копятся в очереди WithSink.
+type CDCChannel chan<- Mutation
+
+// Write отправляет изменения пачки в канал по одному.
+func (ch CDCChannel) Write(ctx context.Context, batch []Mutation) error {
+	for _, m := range batch {
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// ReadCDC читает поток CDCWriter из r и вызывает fn на каждое изменение, пока поток
+// не кончится (тогда возвращает nil) или fn не вернёт ошибку.
+func ReadCDC(r io.Reader, fn func(Mutation) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var m Mutation
+		if err := dec.Decode(&m); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("store: cdc read: %w", err)
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+}
+
+// ApplyMutation применяет изменение из CDC-потока к хранилищу-зеркалу: EventSet записывает
+// значение со сроком ExpiresAt (уже истекшее удаляет ключ), коллекцию - с её типом по Kind,
+// EventDelete удаляет ключ, EventFlush очищает хранилище как ResetAll.
+// Повторное применение того же изменения ничего не меняет. ApplyMutation работает и в режиме
+// только для чтения (SetReadOnly): зеркало закрывают для своих клиентов, но не для основного узла.
+func (s *Store) ApplyMutation(m Mutation) error {
+	w := writeOpts{replicated: true}
+	switch m.Type {
+	case EventSet:
+		ttl := NoExpiration
+		if !m.ExpiresAt.IsZero() {
+			if ttl = m.ExpiresAt.Sub(s.now()); ttl <= 0 {
+				s.remove(m.Key, w)
+				return nil
+			}
+		}
+		if m.Kind != "" {
+			payload, err := mutationPayload(m)
+			if err != nil {
+				return err
+			}
+			w.payload = payload
+		}
+		return s.set(m.Key, m.Value, ttl, w)
+	case EventDelete:
+		s.remove(m.Key, w)
+		return nil
+	case EventFlush:
+		_, err := s.resetAll(w, nil)
+		return err
+	default:
+		return fmt.Errorf("store: apply mutation: unexpected type %v", m.Type)
+	}
+}
+
+// mutationPayload восстанавливает коллекцию из Mutation.Value по Mutation.Kind, см. Item.text
+func mutationPayload(m Mutation) (*Item, error) {
+	kind, ok := parseKind(m.Kind)
+	if !ok {
+		return nil, fmt.Errorf("store: apply mutation: unknown value kind %q", m.Kind)
+	}
+	it := &Item{kind: kind}
+	var err error
+	switch kind {
+	case kindString:
+		return nil, nil
+	case kindHash:
+		err = json.Unmarshal([]byte(m.Value), &it.hash)
+	case kindList:
+		err = json.Unmarshal([]byte(m.Value), &it.list)
+	case kindSet:
+		var members []string
+		err = json.Unmarshal([]byte(m.Value), &members)
+		it.set = make(map[string]struct{}, len(members))
+		for _, v := range members {
+			it.set[v] = struct{}{}
+		}
+	case kindZSet:
+		var members []ZMember
+		err = json.Unmarshal([]byte(m.Value), &members)
+		for _, v := range members {
+			it.zset = it.zset.with(v.Member, v.Score)
+		}
+	case kindCounter:
+		var n int64
+		n, err = strconv.ParseInt(m.Value, 10, 64)
+		it.counter = new(atomic.Int64)
+		it.counter.Store(n)
+	case kindHLL:
+		it.hll, err = base64.StdEncoding.DecodeString(m.Value)
+		if err == nil && len(it.hll) != hllRegisters {
+			err = errors.New("wrong register count")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store: apply mutation: %s value of %q: %w", m.Kind, m.Key, err)
+	}
+	return it, nil
+}
@@ -0,0 +1,290 @@
+// Package chaos - декоратор store.Cache, который внедряет задержки, ошибки и потерянные записи,
+// что-бы проверить, как сервис переживает неисправный кеш.
+//
+//	c := chaos.New(s, chaos.WithLatency(time.Millisecond, 50*time.Millisecond), chaos.WithErrorRate(0.05))
+//	svc := NewService(c) // сервис зависит от store.Cache
+//
+// Неисправности включаются вероятностями; с WithSeed последовательность повторяется от запуска
+// к запуску. Enable и Disable переключают их на ходу, например по флагу в админке стенда.
+package chaos
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// ErrInjected - ошибка, которую возвращают методы с error при внедрённом сбое,
+// если WithErrorRate не задал свою.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Option настраивает Cache.
+type Option func(*Cache)
+
+// WithLatency добавляет к каждому вызову случайную задержку от min до max.
+func WithLatency(min, max time.Duration) Option {
+	return func(c *Cache) {
+		c.minDelay, c.maxDelay = min, max
+	}
+}
+
+// WithErrorRate делает вызов неудачным с вероятностью p (0..1): методы с error возвращают
+// err (nil - ErrInjected), Get - промах, SetNX и другие методы с bool - false, Keys - nil.
+// Вызов до хранилища не доходит.
+func WithErrorRate(p float64, err ...error) Option {
+	return func(c *Cache) {
+		c.errorRate = p
+		if len(err) > 0 && err[0] != nil {
+			c.err = err[0]
+		}
+	}
+}
+
+// WithDropRate теряет запись с вероятностью p: Set, SetNX, SetXX, Expire, IncrBy, Delete
+// и CompareAndDelete сообщают об успехе, но хранилище не меняют, как кеш, потерявший
+// запись при падении.
+// IncrBy возвращает delta, как будто ключа не было.
+func WithDropRate(p float64) Option {
+	return func(c *Cache) { c.dropRate = p }
+}
+
+// WithMethods ограничивает неисправности методами methods ("Get", "Set", ...),
+// остальные вызываются без изменений. По умолчанию затронуты все методы store.Cache.
+func WithMethods(methods ...string) Option {
+	return func(c *Cache) { c.methods = slices.Clone(methods) }
+}
+
+// WithSeed задаёт затравку генератора: одинаковая затравка и одинаковая
+// последовательность вызовов дают одинаковые сбои.
+func WithSeed(seed uint64) Option {
+	return func(c *Cache) { c.rnd = rand.New(rand.NewPCG(seed, seed)) }
+}
+
+// Stats - сколько неисправностей внедрено.
+type Stats struct {
+	Delayed uint64 `json:"delayed"`
+	Failed  uint64 `json:"failed"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// Cache - store.Cache с внедрёнными неисправностями.
+type Cache struct {
+	next store.Cache
+
+	minDelay, maxDelay time.Duration
+	errorRate          float64
+	dropRate           float64
+	err                error
+	methods            []string
+
+	enabled atomic.Bool
+
+	mu  sync.Mutex // rand.Rand не потокобезопасен
+	rnd *rand.Rand
+
+	delayed atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+var _ store.Cache = (*Cache)(nil)
+
+// New оборачивает next. Неисправности включены сразу.
+func New(next store.Cache, opts ...Option) *Cache {
+	c := &Cache{next: next, err: ErrInjected}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.rnd == nil {
+		c.rnd = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	c.enabled.Store(true)
+	return c
+}
+
+// Decorator - New для store.Decorate.
+func Decorator(opts ...Option) store.Decorator {
+	return func(next store.Cache) store.Cache {
+		return New(next, opts...)
+	}
+}
+
+// Enable включает неисправности.
+func (c *Cache) Enable() { c.enabled.Store(true) }
+
+// Disable выключает неисправности: вызовы идут в обёрнутый Cache без задержек.
+func (c *Cache) Disable() { c.enabled.Store(false) }
+
+// Unwrap возвращает обёрнутый Cache.
+func (c *Cache) Unwrap() store.Cache { return c.next }
+
+// FaultStats возвращает счетчики внедрённых неисправностей. Stats - метод store.Cache,
+// он сам может отказать.
+func (c *Cache) FaultStats() Stats {
+	return Stats{
+		Delayed: c.delayed.Load(),
+		Failed:  c.failed.Load(),
+		Dropped: c.dropped.Load(),
+	}
+}
+
+// fault - исход вызова
+type fault int
+
+const (
+	pass fault = iota
+	fail
+	drop
+)
+
+// inject выдерживает задержку и решает, сломать ли вызов method. write - вызов можно потерять
+func (c *Cache) inject(method string, write bool) fault {
+	if !c.enabled.Load() || (c.methods != nil && !slices.Contains(c.methods, method)) {
+		return pass
+	}
+
+	c.mu.Lock()
+	var delay time.Duration
+	if c.maxDelay > 0 {
+		delay = c.minDelay
+		if span := c.maxDelay - c.minDelay; span > 0 {
+			delay += time.Duration(c.rnd.Int64N(int64(span)))
+		}
+	}
+	failNow := c.errorRate > 0 && c.rnd.Float64() < c.errorRate
+	dropNow := write && !failNow && c.dropRate > 0 && c.rnd.Float64() < c.dropRate
+	c.mu.Unlock()
+
+	if delay > 0 {
+		c.delayed.Add(1)
+		time.Sleep(delay)
+	}
+	switch {
+	case failNow:
+		c.failed.Add(1)
+		return fail
+	case dropNow:
+		c.dropped.Add(1)
+		return drop
+	}
+	return pass
+}
+
+// Get - store.Cache.Get с внедрёнными неисправностями.
+func (c *Cache) Get(key string) (string, bool) {
+	if c.inject("Get", false) != pass {
+		return "", false
+	}
+	return c.next.Get(key)
+}
+
+// Set - store.Cache.Set с внедрёнными неисправностями.
+func (c *Cache) Set(key, value string, ttl time.Duration) error {
+	switch c.inject("Set", true) {
+	case fail:
+		return c.err
+	case drop:
+		return nil
+	}
+	return c.next.Set(key, value, ttl)
+}
+
+// Delete - store.Cache.Delete с внедрёнными неисправностями.
+func (c *Cache) Delete(key string) {
+	if c.inject("Delete", true) != pass {
+		return
+	}
+	c.next.Delete(key)
+}
+
+// SetNX - store.Cache.SetNX с внедрёнными неисправностями.
+func (c *Cache) SetNX(key, value string, ttl time.Duration) bool {
+	switch c.inject("SetNX", true) {
+	case fail:
+		return false
+	case drop:
+		return true
+	}
+	return c.next.SetNX(key, value, ttl)
+}
+
+// SetXX - store.Cache.SetXX с внедрёнными неисправностями.
+func (c *Cache) SetXX(key, value string, ttl time.Duration) bool {
+	switch c.inject("SetXX", true) {
+	case fail:
+		return false
+	case drop:
+		return true
+	}
+	return c.next.SetXX(key, value, ttl)
+}
+
+// CompareAndDelete - store.Cache.CompareAndDelete с внедрёнными неисправностями.
+func (c *Cache) CompareAndDelete(key, value string) bool {
+	switch c.inject("CompareAndDelete", true) {
+	case fail:
+		return false
+	case drop:
+		return true
+	}
+	return c.next.CompareAndDelete(key, value)
+}
+
+// IncrBy - store.Cache.IncrBy с внедрёнными неисправностями.
+func (c *Cache) IncrBy(key string, delta int64) (int64, error) {
+	switch c.inject("IncrBy", true) {
+	case fail:
+		return 0, c.err
+	case drop:
+		return delta, nil
+	}
+	return c.next.IncrBy(key, delta)
+}
+
+// TTL - store.Cache.TTL с внедрёнными неисправностями.
+func (c *Cache) TTL(key string) (time.Duration, bool) {
+	if c.inject("TTL", false) != pass {
+		return 0, false
+	}
+	return c.next.TTL(key)
+}
+
+// Expire - store.Cache.Expire с внедрёнными неисправностями.
+func (c *Cache) Expire(key string, ttl time.Duration) bool {
+	switch c.inject("Expire", true) {
+	case fail:
+		return false
+	case drop:
+		return true
+	}
+	return c.next.Expire(key, ttl)
+}
+
+// Keys - store.Cache.Keys с внедрёнными неисправностями.
+func (c *Cache) Keys(pattern string) []string {
+	if c.inject("Keys", false) != pass {
+		return nil
+	}
+	return c.next.Keys(pattern)
+}
+
+// Size - store.Cache.Size с внедрёнными неисправностями.
+func (c *Cache) Size() int {
+	if c.inject("Size", false) != pass {
+		return 0
+	}
+	return c.next.Size()
+}
+
+// Stats - store.Cache.Stats с внедрёнными неисправностями.
+func (c *Cache) Stats() store.Stats {
+	if c.inject("Stats", false) != pass {
+		return store.Stats{}
+	}
+	return c.next.Stats()
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+)
+
+// Checksum возвращает контрольную сумму неистекших ключей и их значений. Сумма не зависит
+// от порядка ключей, сжатия, интернирования и процесса, так что совпадает для основного
+// узла и реплики с одинаковыми данными, а VerifySnapshot считает её так же по файлу снапшота.
+// TTL, просмотры и метаданные в сумму не входят: на реплике они законно отличаются.
+//
+// Проход по всем ключам идёт под RLock. Что-бы сравнить основной узел с репликой,
+// Checksum вызывают, когда записи остановлены, например в режиме только для чтения.
+func (s *Store) Checksum() uint64 {
+	now := s.now()
+	var sum uint64
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for raw, item := range s.data {
+		if !item.expiredAt(now) {
+			sum += entryChecksum(raw, item.text())
+		}
+	}
+	return sum
+}
+
+// entryChecksum - FNV-1a ключа и значения с длинами, что-бы ("ab", "c") не совпало с ("a", "bc").
+// Суммы записей складываются, так порядок обхода мапы не важен
+func entryChecksum(key, value string) uint64 {
+	h := fnv.New64a()
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(key)))
+	h.Write(n[:])
+	io.WriteString(h, key)
+	binary.LittleEndian.PutUint64(n[:], uint64(len(value)))
+	h.Write(n[:])
+	io.WriteString(h, value)
+	return h.Sum64()
+}
+
+// SnapshotInfo - результат VerifySnapshot.
+type SnapshotInfo struct {
+	Keys     int    `json:"keys"`             // неистекших ключей, которые загрузил бы LoadSnapshot
+	Expired  int    `json:"expired"`          // истекших к моменту проверки
+	Skipped  int    `json:"skipped"`          // ключей, которые LoadSnapshot пропустил бы: неизвестный тип, исчерпанные просмотры
+	Checksum uint64 `json:"checksum"`         // Checksum хранилища, загруженного из снапшота
+	Version  int    `json:"version"`          // версия формата файла, старые LoadSnapshot переводит в текущую
+	Schema   string `json:"schema,omitempty"` // WithKeyVersion сохранившего хранилища, см. MigrateKeys
+}
+
+// VerifySnapshot проверяет файл снапшота, не загружая его: разбирает элементы по одному,
+// так что память не зависит от размера файла. Ошибка - файл обрезан или испорчен.
+// Checksum в результате совпадает с Checksum хранилища, из которого снапшот сохранён,
+// если с тех пор данные не менялись и ключи не истекли.
+//
+// С WithSnapshotCodec формат определяет кодек, и файл читается целиком.
+func (s *Store) VerifySnapshot(path string) (SnapshotInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("store: verify snapshot: %w", err)
+	}
+	defer f.Close()
+
+	var info SnapshotInfo
+	if err := s.verifySnapshot(f, &info); err != nil {
+		return info, fmt.Errorf("store: verify snapshot: %w", err)
+	}
+	return info, nil
+}
+
+func (s *Store) verifySnapshot(r io.Reader, info *SnapshotInfo) error {
+	now := s.now()
+	h, err := s.scanSnapshot(r, func(key string, si snapshotItem) error {
+		if !si.ExpiresAt.IsZero() && now.After(si.ExpiresAt) {
+			info.Expired++
+			return nil
+		}
+		item, ok := s.itemFromSnapshot(key, si)
+		if !ok {
+			info.Skipped++
+			return nil
+		}
+		info.Keys++
+		info.Checksum += entryChecksum(key, item.text())
+		return nil
+	})
+	info.Version, info.Schema = h.Version, h.Schema
+	return err
+}
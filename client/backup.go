@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// BackupResult - ответ сервера на Backup.
+type BackupResult struct {
+	Target string        `json:"target"`
+	Name   string        `json:"name"`  // имя объекта в цели
+	Bytes  int64         `json:"bytes"` // размер снапшота
+	Took   time.Duration `json:"took"`
+}
+
+// Backup просит сервер снять снапшот и отправить его в цель target, настроенную на сервере
+// (httpserver.WithBackupTargets). name - имя объекта, пусто - по времени на сервере.
+// Снапшот идёт от сервера к цели напрямую, через клиента не проходит.
+func (c *HTTP) Backup(ctx context.Context, target, name string) (BackupResult, error) {
+	q := url.Values{"target": {target}}
+	if name != "" {
+		q.Set("name", name)
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/backup?"+q.Encode(), nil)
+	if err != nil {
+		return BackupResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return BackupResult{}, statusError(resp)
+	}
+	var res BackupResult
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	return res, err
+}
+
+// OpenBackup загружает то, что в него пишут, снапшотом в этот сервер (PUT /snapshot)
+// поверх его данных, так что HTTP - цель резервного копирования httpserver.BackupTarget
+// для резервного сервера. Close ждёт ответа сервера; отмена ctx прерывает загрузку.
+// name не используется: у сервера один снапшот.
+func (c *HTTP) OpenBackup(ctx context.Context, name string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	rw := &restoreWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := c.Restore(ctx, pr)
+		pr.CloseWithError(err) // запись в трубу не зависнет, если сервер ответил раньше
+		rw.done <- err
+	}()
+	return rw, nil
+}
+
+// restoreWriter - труба в PUT /snapshot, Close ждёт ответа
+type restoreWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *restoreWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+func (w *restoreWriter) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
+
+// Copy переносит снапшот src в dst потоком, не собирая его в памяти клиента: резервная
+// копия на другой сервер или перенос данных между кластерами. dst получает данные поверх
+// своих, как Restore.
+func Copy(ctx context.Context, dst, src Client) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(src.Dump(ctx, pw))
+	}()
+	err := dst.Restore(ctx, pr)
+	pr.CloseWithError(err)
+	return err
+}
@@ -0,0 +1,78 @@
+// Package client - клиенты для сетевых фронтендов хранилища (httpserver и respserver).
+//
+// Оба клиента реализуют общий интерфейс Client, так что вызывающий код (cmd/storecli)
+// не зависит от протокола. RESP клиент не умеет то, чего нет в протоколе Redis:
+// Stats, Dump и Restore возвращают ErrUnsupported.
+//
+// Ring раскладывает ключи по нескольким серверам согласованным хешированием и сам
+// тоже реализует Client.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// ErrUnsupported возвращается, если операция недоступна в протоколе клиента.
+var ErrUnsupported = errors.New("client: operation is not supported by this protocol")
+
+// Client - операции над удаленным хранилищем.
+type Client interface {
+	// Get возвращает значение ключа, false если ключа нет или он истёк.
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Set записывает значение, ttl == 0 - TTL по умолчанию на сервере.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Delete удаляет ключи.
+	Delete(ctx context.Context, keys ...string) error
+	// Keys возвращает отсортированные ключи, подходящие под glob-шаблон.
+	Keys(ctx context.Context, pattern string) ([]string, error)
+	// Stats возвращает статистику хранилища.
+	Stats(ctx context.Context) (store.Stats, error)
+	// Dump пишет снапшот хранилища в w.
+	Dump(ctx context.Context, w io.Writer) error
+	// Restore загружает снапшот из r поверх текущих данных.
+	Restore(ctx context.Context, r io.Reader) error
+	// Close освобождает соединения клиента.
+	Close() error
+}
+
+// Option настраивает клиента.
+type Option func(*options)
+
+type options struct {
+	token  string
+	tls    *tls.Config
+	vnodes int // только Ring
+
+	hash      func(string) uint64 // только Ring, nil - ringHash
+	partition func(string) string // только Ring, nil - ключ целиком
+}
+
+// WithToken передаёт токен аутентификации: заголовок Authorization: Bearer для HTTP
+// и команду AUTH сразу после подключения для RESP.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithTLS задаёт TLS конфигурацию. RESP клиент с ней подключается по TLS, HTTP клиент
+// использует её для https:// адресов, если не передан собственный http.Client.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tls = cfg
+	}
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// HTTP - клиент для httpserver.
+type HTTP struct {
+	base  string
+	hc    *http.Client
+	token string
+}
+
+// NewHTTP создаёт клиент для сервера с адресом baseURL (например "http://localhost:8080").
+// Если hc == nil, используется http.DefaultClient, а с WithTLS - клиент с этой TLS конфигурацией.
+func NewHTTP(baseURL string, hc *http.Client, opts ...Option) *HTTP {
+	o := applyOptions(opts)
+	if hc == nil {
+		hc = http.DefaultClient
+		if o.tls != nil {
+			tr := http.DefaultTransport.(*http.Transport).Clone()
+			tr.TLSClientConfig = o.tls
+			hc = &http.Client{Transport: tr}
+		}
+	}
+	return &HTTP{base: strings.TrimRight(baseURL, "/"), hc: hc, token: o.token}
+}
+
+var (
+	_ Client        = (*HTTP)(nil)
+	_ store.Backend = (*HTTP)(nil)
+)
+
+// Get реализует Client.
+func (c *HTTP) Get(ctx context.Context, key string) (string, bool, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/keys/"+url.PathEscape(key), nil)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", false, err
+		}
+		return string(body), true, nil
+	case http.StatusNotFound:
+		return "", false, nil
+	default:
+		return "", false, statusError(resp)
+	}
+}
+
+// Set реализует Client.
+func (c *HTTP) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	path := "/keys/" + url.PathEscape(key)
+	if ttl != 0 {
+		path += "?ttl=" + url.QueryEscape(ttl.String())
+	}
+	return c.expectNoContent(ctx, http.MethodPut, path, strings.NewReader(value))
+}
+
+// Delete реализует Client.
+func (c *HTTP) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := c.expectNoContent(ctx, http.MethodDelete, "/keys/"+url.PathEscape(key), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listPage - ответ GET /keys, нужны только ключи
+type listPage struct {
+	Items []struct {
+		Key string `json:"key"`
+	} `json:"items"`
+	Next string `json:"next"`
+}
+
+// Keys реализует Client, проходя по страницам GET /keys.
+func (c *HTTP) Keys(ctx context.Context, pattern string) ([]string, error) {
+	var keys []string
+	after := ""
+	for {
+		q := url.Values{"limit": {"1000"}, "match": {pattern}}
+		if after != "" {
+			q.Set("after", after)
+		}
+
+		var page listPage
+		if err := c.getJSON(ctx, "/keys?"+q.Encode(), &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			keys = append(keys, item.Key)
+		}
+		if page.Next == "" {
+			return keys, nil
+		}
+		after = page.Next
+	}
+}
+
+// Stats реализует Client.
+func (c *HTTP) Stats(ctx context.Context) (store.Stats, error) {
+	var st store.Stats
+	err := c.getJSON(ctx, "/stats", &st)
+	return st, err
+}
+
+// Dump реализует Client.
+func (c *HTTP) Dump(ctx context.Context, w io.Writer) error {
+	return c.DumpFormat(ctx, w, "")
+}
+
+// DumpFormat выгружает данные в формате format: "json" (как Dump, он же по умолчанию),
+// "jsonl" (store.Store.DumpJSONL) или "csv" (store.Store.DumpCSV).
+func (c *HTTP) DumpFormat(ctx context.Context, w io.Writer, format string) error {
+	resp, err := c.do(ctx, http.MethodGet, snapshotPath(format), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Restore реализует Client.
+func (c *HTTP) Restore(ctx context.Context, r io.Reader) error {
+	return c.RestoreFormat(ctx, r, "")
+}
+
+// RestoreFormat загружает данные в формате format, см. DumpFormat.
+func (c *HTTP) RestoreFormat(ctx context.Context, r io.Reader, format string) error {
+	return c.expectNoContent(ctx, http.MethodPut, snapshotPath(format), r)
+}
+
+func snapshotPath(format string) string {
+	if format == "" {
+		return "/snapshot"
+	}
+	return "/snapshot?format=" + url.QueryEscape(format)
+}
+
+// Close реализует Client.
+func (c *HTTP) Close() error {
+	c.hc.CloseIdleConnections()
+	return nil
+}
+
+func (c *HTTP) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.hc.Do(req)
+}
+
+func (c *HTTP) expectNoContent(ctx context.Context, method, path string, body io.Reader) error {
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+	return nil
+}
+
+func (c *HTTP) getJSON(ctx context.Context, path string, v any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// statusError превращает неуспешный ответ в ошибку с текстом от http.Error
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return fmt.Errorf("client: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// RESP - клиент для respserver (и любого сервера, понимающего GET/SET/DEL/KEYS из Redis)
+// поверх одного TCP соединения. Запросы сериализуются, клиент безопасен для
+// конкурентного использования.
+type RESP struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// DialRESP подключается к RESP серверу по адресу addr. С WithToken сразу выполняется AUTH.
+func DialRESP(ctx context.Context, addr string, opts ...Option) (*RESP, error) {
+	o := applyOptions(opts)
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if o.tls != nil {
+		d := tls.Dialer{Config: o.tls}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &RESP{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+	if o.token != "" {
+		if _, err := c.do(ctx, "AUTH", o.token); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+var (
+	_ Client        = (*RESP)(nil)
+	_ store.Backend = (*RESP)(nil)
+)
+
+// Get реализует Client.
+func (c *RESP) Get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return "", false, err
+	}
+	if reply == nil {
+		return "", false, nil
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return "", false, fmt.Errorf("client: unexpected GET reply %T", reply)
+	}
+	return s, true, nil
+}
+
+// Set реализует Client. TTL передаётся через PX, с точностью до миллисекунды.
+func (c *RESP) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+// Delete реализует Client.
+func (c *RESP) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Keys реализует Client.
+func (c *RESP) Keys(ctx context.Context, pattern string) ([]string, error) {
+	reply, err := c.do(ctx, "KEYS", pattern)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("client: unexpected KEYS reply %T", reply)
+	}
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			keys = append(keys, s)
+		}
+	}
+	return keys, nil
+}
+
+// Stats не поддерживается протоколом, возвращает ErrUnsupported.
+func (c *RESP) Stats(ctx context.Context) (store.Stats, error) {
+	return store.Stats{}, ErrUnsupported
+}
+
+// Dump не поддерживается протоколом, возвращает ErrUnsupported.
+func (c *RESP) Dump(ctx context.Context, w io.Writer) error {
+	return ErrUnsupported
+}
+
+// Restore не поддерживается протоколом, возвращает ErrUnsupported.
+func (c *RESP) Restore(ctx context.Context, r io.Reader) error {
+	return ErrUnsupported
+}
+
+// Close реализует Client.
+func (c *RESP) Close() error {
+	return c.conn.Close()
+}
+
+// RemoteError - ответ сервера с ошибкой ("-ERR ...").
+type RemoteError struct {
+	Msg string
+}
+
+func (e *RemoteError) Error() string {
+	return "client: server error: " + e.Msg
+}
+
+// do отправляет команду и читает один ответ. Ответ - string (simple и bulk строки),
+// int64, []any или nil для null bulk.
+func (c *RESP) do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.w.WriteString(arg)
+		c.w.WriteString("\r\n")
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return readReply(c.r)
+}
+
+var errProtocol = errors.New("client: protocol error")
+
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("%w: empty reply", errProtocol)
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, &RemoteError{Msg: line[1:]}
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid integer", errProtocol)
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid array length", errProtocol)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected reply type %q", errProtocol, line[0])
+	}
+}
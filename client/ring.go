@@ -0,0 +1,331 @@
+package client
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// ErrNoNodes - в Ring нет ни одного сервера.
+var ErrNoNodes = errors.New("client: ring has no nodes")
+
+const defaultVirtualNodes = 128
+
+// WithVirtualNodes задаёт число виртуальных узлов на сервер в Ring, по умолчанию 128.
+// Чем их больше, тем ровнее ключи делятся между серверами. Остальные клиенты опцию не используют.
+func WithVirtualNodes(n int) Option {
+	return func(o *options) {
+		o.vnodes = n
+	}
+}
+
+// WithHash задаёт хеш, по которому Ring раскладывает ключи и точки серверов на кольце,
+// по умолчанию FNV-1a с перемешиванием. Хеш должен быть одинаковым во всех процессах,
+// которые делят кольцо, иначе они разойдутся во владельцах ключей. Остальные клиенты опцию
+// не используют.
+func WithHash(hash func(s string) uint64) Option {
+	return func(o *options) {
+		o.hash = hash
+	}
+}
+
+// WithPartitioner задаёт, по какой части ключа Ring выбирает сервер: partition возвращает
+// ключ раздела, ключи с одинаковым разделом всегда живут на одном сервере. Так связанные
+// ключи одного арендатора можно держать вместе, а, наоборот, добавив к разделу суффикс,
+// разнести горячие ключи. По умолчанию раздел - ключ целиком. См. HashTagPartitioner.
+func WithPartitioner(partition func(key string) string) Option {
+	return func(o *options) {
+		o.partition = partition
+	}
+}
+
+// HashTagPartitioner - раздел по хеш-тегу, как в Redis Cluster: если в ключе есть непустая
+// подстрока в фигурных скобках, раздел - она ("{tenant1}:user:42" и "{tenant1}:cart" лягут
+// на один сервер), иначе ключ целиком.
+func HashTagPartitioner(key string) string {
+	open := strings.IndexByte(key, '{')
+	if open < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[open+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[open+1 : open+1+end]
+}
+
+// Ring - клиент, который распределяет ключи между несколькими серверами хранилища
+// по кольцу согласованного хеширования. Серверы - любые Client (HTTP, RESP), у каждого
+// своё имя, по имени считаются его точки на кольце, так что порядок добавления не важен.
+//
+// При AddNode и RemoveNode на другой сервер переезжает только доля ключей нового
+// или удалённого сервера. Данные не переносятся: переехавшие ключи промахиваются
+// на новом владельце и заполняются заново, как при обычных промахах кеша.
+//
+// Dump и Restore возвращают ErrUnsupported: снапшот одного сервера не описывает кластер.
+type Ring struct {
+	vnodes    int
+	hash      func(string) uint64
+	partition func(string) string // nil - ключ целиком
+
+	mu     sync.RWMutex
+	nodes  map[string]Client
+	points []ringPoint // по возрастанию hash
+}
+
+type ringPoint struct {
+	hash uint64
+	node string
+}
+
+var _ Client = (*Ring)(nil)
+
+// NewRing создаёт кольцо из серверов nodes (имя сервера -> клиент).
+func NewRing(nodes map[string]Client, opts ...Option) *Ring {
+	o := applyOptions(opts)
+	if o.vnodes <= 0 {
+		o.vnodes = defaultVirtualNodes
+	}
+	if o.hash == nil {
+		o.hash = ringHash
+	}
+	r := &Ring{vnodes: o.vnodes, hash: o.hash, partition: o.partition, nodes: make(map[string]Client, len(nodes))}
+	for name, c := range nodes {
+		r.nodes[name] = c
+	}
+	r.rebuildLocked()
+	return r
+}
+
+// AddNode добавляет сервер или заменяет клиент сервера с тем же именем.
+func (r *Ring) AddNode(name string, c Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nodes[name] = c
+	r.rebuildLocked()
+}
+
+// RemoveNode убирает сервер из кольца и возвращает его клиент, закрыть его - забота
+// вызывающего. false - сервера с таким именем нет.
+func (r *Ring) RemoveNode(name string) (Client, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.nodes[name]
+	if !ok {
+		return nil, false
+	}
+	delete(r.nodes, name)
+	r.rebuildLocked()
+	return c, true
+}
+
+// Nodes возвращает отсортированные имена серверов.
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.nodes))
+	for name := range r.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Owner возвращает имя сервера, которому принадлежит ключ, пустую строку - если серверов нет.
+func (r *Ring) Owner(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ownerLocked(key)
+}
+
+func (r *Ring) rebuildLocked() {
+	r.points = r.points[:0]
+	for name := range r.nodes {
+		for i := range r.vnodes {
+			r.points = append(r.points, ringPoint{hash: r.hash(name + "#" + strconv.Itoa(i)), node: name})
+		}
+	}
+	slices.SortFunc(r.points, func(a, b ringPoint) int {
+		if a.hash != b.hash {
+			return cmp.Compare(a.hash, b.hash)
+		}
+		return cmp.Compare(a.node, b.node) // совпадение хешей разных серверов разрешаем по имени
+	})
+}
+
+// ownerLocked - первая точка кольца не меньше хеша раздела ключа, по кругу
+func (r *Ring) ownerLocked(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	if r.partition != nil {
+		key = r.partition(key)
+	}
+	h := r.hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// client возвращает клиент сервера-владельца ключа
+func (r *Ring) client(key string) (Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name := r.ownerLocked(key)
+	if name == "" {
+		return nil, ErrNoNodes
+	}
+	return r.nodes[name], nil
+}
+
+// snapshot возвращает серверы для операций над всеми серверами
+func (r *Ring) snapshot() map[string]Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nodes := make(map[string]Client, len(r.nodes))
+	for name, c := range r.nodes {
+		nodes[name] = c
+	}
+	return nodes
+}
+
+// Get реализует Client.
+func (r *Ring) Get(ctx context.Context, key string) (string, bool, error) {
+	c, err := r.client(key)
+	if err != nil {
+		return "", false, err
+	}
+	return c.Get(ctx, key)
+}
+
+// Set реализует Client.
+func (r *Ring) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c, err := r.client(key)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, value, ttl)
+}
+
+// Delete реализует Client: ключи группируются по серверам, по одному запросу на сервер.
+func (r *Ring) Delete(ctx context.Context, keys ...string) error {
+	r.mu.RLock()
+	if len(r.nodes) == 0 {
+		r.mu.RUnlock()
+		return ErrNoNodes
+	}
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		name := r.ownerLocked(key)
+		groups[name] = append(groups[name], key)
+	}
+	nodes := make(map[string]Client, len(groups))
+	for name := range groups {
+		nodes[name] = r.nodes[name]
+	}
+	r.mu.RUnlock()
+
+	var errs []error
+	for name, group := range groups {
+		if err := nodes[name].Delete(ctx, group...); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Keys реализует Client: ключи собираются со всех серверов.
+func (r *Ring) Keys(ctx context.Context, pattern string) ([]string, error) {
+	var (
+		keys []string
+		errs []error
+	)
+	for name, c := range r.snapshot() {
+		part, err := c.Keys(ctx, pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", name, err))
+			continue
+		}
+		keys = append(keys, part...)
+	}
+	sort.Strings(keys)
+	return slices.Compact(keys), errors.Join(errs...)
+}
+
+// Stats реализует Client: счетчики серверов складываются, детальная статистика
+// (задержки, префиксы, пространства имён) не собирается.
+func (r *Ring) Stats(ctx context.Context) (store.Stats, error) {
+	var (
+		total store.Stats
+		errs  []error
+	)
+	for name, c := range r.snapshot() {
+		st, err := c.Stats(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", name, err))
+			continue
+		}
+		total.Size += st.Size
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Sets += st.Sets
+		total.Deletes += st.Deletes
+		total.Expired += st.Expired
+		total.Evictions += st.Evictions
+		total.MemoryBytes += st.MemoryBytes
+	}
+	return total, errors.Join(errs...)
+}
+
+// Dump реализует Client, всегда ErrUnsupported.
+func (r *Ring) Dump(ctx context.Context, w io.Writer) error {
+	return ErrUnsupported
+}
+
+// Restore реализует Client, всегда ErrUnsupported.
+func (r *Ring) Restore(ctx context.Context, rd io.Reader) error {
+	return ErrUnsupported
+}
+
+// Close закрывает клиенты всех серверов.
+func (r *Ring) Close() error {
+	var errs []error
+	for name, c := range r.snapshot() {
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ringHash - FNV-1a с перемешиванием из MurmurHash3: у голого FNV строки с разницей
+// в последних символах ложатся рядом и точки кольца слипаются
+func ringHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
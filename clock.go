@@ -0,0 +1,148 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock - источник времени хранилища. Через него идут TTL, очистка, журналы и внутренние
+// таймеры, так что в тестах время можно перематывать вместо sleep, см. ManualClock.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker - тикер Clock, как time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// WithClock задаёт источник времени, по умолчанию системные часы.
+func WithClock(c Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+// realClock - системные часы
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// WithCoarseClock заменяет вызов часов на каждой операции временем, которое фоновая горутина
+// обновляет раз в resolution: на десятках тысяч Get и Set в секунду сам time.Now заметен в
+// профиле. Цена - точность: ключ может прожить до resolution дольше TTL, а UpdatedAt,
+// CreatedAt, время чтения и журналы получают время с тем же шагом. Несовместим с
+// WithDeterministic. После Close хранилище снова спрашивает Clock на каждой операции.
+// 0 - без кеширования времени.
+func WithCoarseClock(resolution time.Duration) Option {
+	return func(c *config) {
+		c.coarseClock = resolution
+	}
+}
+
+// Now возвращает время хранилища: по WithClock, с WithCoarseClock - с его шагом. Обёрткам
+// над хранилищем (например ratelimit) оно нужно, что-бы их окна шли по тем же часам, что TTL.
+func (s *Store) Now() time.Time {
+	return s.now()
+}
+
+// now - текущее время по Clock хранилища или по WithCoarseClock
+func (s *Store) now() time.Time {
+	if t := s.coarseNow.Load(); t != nil {
+		return *t
+	}
+	return s.cfg.clock.Now()
+}
+
+// runCoarseClock обновляет s.coarseNow, пока хранилище не закрыто, см. WithCoarseClock
+func (s *Store) runCoarseClock(t Ticker) {
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			now := s.cfg.clock.Now()
+			s.coarseNow.Store(&now)
+		case <-s.life.done:
+			s.coarseNow.Store(nil)
+			return
+		}
+	}
+}
+
+// ManualClock - часы, которые идут только по Advance, для детерминированных тестов.
+// Тикеры срабатывают внутри Advance и, как time.Ticker, пропускают тики, если их не успели прочитать.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+// NewManualClock создаёт часы, которые показывают start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now возвращает текущее время часов.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker создаёт тикер с периодом d, d должен быть положительным.
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("store: non-positive interval for ManualClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTicker{c: c, period: d, next: c.now.Add(d), ch: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance переводит часы вперёд на d и срабатывает тикеры, чей срок наступил.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		for !t.next.After(c.now) {
+			select {
+			case t.ch <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+type manualTicker struct {
+	c      *ManualClock
+	period time.Duration
+	next   time.Time // под c.mu
+	ch     chan time.Time
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.ch }
+
+func (t *manualTicker) Stop() {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	for i, other := range t.c.tickers {
+		if other == t {
+			t.c.tickers = append(t.c.tickers[:i], t.c.tickers[i+1:]...)
+			return
+		}
+	}
+}
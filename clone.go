@@ -0,0 +1,89 @@
+package store
+
+import "sync/atomic"
+
+// CloneOption настраивает Clone.
+type CloneOption func(*cloneConfig)
+
+type cloneConfig struct {
+	lastKeys bool
+	views    bool
+}
+
+// WithCloneLastKeys переносит в копию журнал последних записей (RetrieveLastKey, PeekLastKeys).
+func WithCloneLastKeys() CloneOption {
+	return func(c *cloneConfig) {
+		c.lastKeys = true
+	}
+}
+
+// WithCloneViews переносит в копию просмотры и время последнего чтения ключей.
+func WithCloneViews() CloneOption {
+	return func(c *cloneConfig) {
+		c.views = true
+	}
+}
+
+// Clone возвращает независимое хранилище с теми же настройками, пространствами имён
+// и содержимым, например для фикстур в тестах или blue/green замены конфигурации:
+// копию готовят и проверяют, а затем подменяют ею рабочее хранилище.
+//
+// Копия не пишет в приёмник WithSink и журнал WithDurableLog и не публикуется в expvar -
+// иначе изменения и статистика попадали бы туда дважды. Статистика, журнал (без
+// WithCloneLastKeys) и просмотры (без WithCloneViews) у копии начинаются с нуля, версии
+// ключей сохраняются.
+func (s *Store) Clone(opts ...CloneOption) *Store {
+	var cc cloneConfig
+	for _, opt := range opts {
+		opt(&cc)
+	}
+
+	cfg := s.cfg
+	cfg.sink, cfg.durableLog, cfg.expvarName = nil, nil, ""
+	c := newStore(cfg)
+
+	s.mu.RLock()
+	c.namespaces = make(map[string]*Namespace, len(s.namespaces))
+	c.nsLabeled = s.nsLabeled
+	for name, ns := range s.namespaces {
+		cns := &Namespace{
+			s:       c,
+			name:    ns.name,
+			prefix:  ns.prefix,
+			label:   ns.label,
+			own:     ns.own,
+			members: make(map[string]struct{}),
+		}
+		cns.resolveLocked() // c ещё никому не виден
+		c.namespaces[name] = cns
+	}
+	for raw, item := range s.data {
+		next := item.copyItem()
+		if item.counter != nil {
+			next.counter = new(atomic.Int64) // счётчик меняется на месте, делить его нельзя
+			next.counter.Store(item.counter.Load())
+		}
+		if !cc.views {
+			next.Views.Store(0)
+			next.accessedAt.Store(0)
+		}
+		c.putLocked(raw, next)
+		next.Version = item.Version // putLocked выдаёт новую версию, а копия ещё никому не видна
+	}
+	c.version = s.version
+	for raw, forever := range s.pins {
+		if c.pins == nil {
+			c.pins = make(map[string]bool, len(s.pins))
+		}
+		c.pins[raw] = forever
+	}
+	s.mu.RUnlock()
+
+	if cc.lastKeys {
+		entries := s.recent.latest(-1)
+		for i := len(entries) - 1; i >= 0; i-- {
+			c.recent.push(entries[i])
+		}
+	}
+	return c
+}
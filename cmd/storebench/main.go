@@ -0,0 +1,376 @@
+// Команда storebench - нагрузочный тест хранилища для оценки ёмкости: смесь чтений и записей
+// по заданному распределению ключей из нескольких горутин, в конце - пропускная способность
+// и квантили задержек по операциям.
+//
+//	storebench [-http URL | -resp ADDR] [-token T] [-duration 10s] [-concurrency 8]
+//	           [-reads 0.9] [-keys 100000] [-dist uniform|zipf] [-zipf-s 1.1]
+//	           [-value 128] [-ttl 0] [-prefill] [-max-memory 0]
+//
+// Без -http и -resp нагрузка идёт на встроенное хранилище в том же процессе, что показывает
+// потолок самого хранилища без сети; -max-memory задаёт ему WithMaxMemory. С -prefill перед
+// замером записываются все ключи, иначе первые чтения - промахи.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/client"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "storebench:", err)
+		os.Exit(1)
+	}
+}
+
+// config - параметры прогона
+type config struct {
+	duration    time.Duration
+	concurrency int
+	reads       float64
+	keys        int
+	dist        string
+	zipfS       float64
+	valueSize   int
+	ttl         time.Duration
+	prefill     bool
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("storebench", flag.ContinueOnError)
+	httpURL := fs.String("http", "", "base URL of the REST API, empty - embedded store")
+	respAddr := fs.String("resp", "", "address of the RESP frontend, empty - embedded store")
+	token := fs.String("token", os.Getenv("STORE_AUTH_TOKEN"), "auth token, defaults to $STORE_AUTH_TOKEN")
+	maxMemory := fs.Int64("max-memory", 0, "memory limit of the embedded store in bytes, 0 - no limit")
+	var cfg config
+	fs.DurationVar(&cfg.duration, "duration", 10*time.Second, "how long to run the load")
+	fs.IntVar(&cfg.concurrency, "concurrency", 8, "number of concurrent workers")
+	fs.Float64Var(&cfg.reads, "reads", 0.9, "share of reads in the mix, 0..1")
+	fs.IntVar(&cfg.keys, "keys", 100000, "size of the keyspace")
+	fs.StringVar(&cfg.dist, "dist", "uniform", "key distribution: uniform or zipf")
+	fs.Float64Var(&cfg.zipfS, "zipf-s", 1.1, "skew of the zipf distribution, > 1")
+	fs.IntVar(&cfg.valueSize, "value", 128, "value size in bytes")
+	fs.DurationVar(&cfg.ttl, "ttl", 0, "TTL of written keys, 0 - server default")
+	fs.BoolVar(&cfg.prefill, "prefill", false, "write every key before the measured run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	t, err := dial(*httpURL, *respAddr, *token, *maxMemory)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	ctx := context.Background()
+	value := strings.Repeat("x", cfg.valueSize)
+	if cfg.prefill {
+		started := time.Now()
+		if err := prefill(ctx, t, cfg, value); err != nil {
+			return fmt.Errorf("prefill: %w", err)
+		}
+		fmt.Fprintf(stdout, "prefill: %d keys in %s\n", cfg.keys, time.Since(started).Round(time.Millisecond))
+	}
+
+	res := bench(ctx, t, cfg, value)
+	res.print(stdout, cfg)
+	return nil
+}
+
+func (c config) validate() error {
+	switch {
+	case c.duration <= 0:
+		return errors.New("-duration must be positive")
+	case c.concurrency <= 0:
+		return errors.New("-concurrency must be positive")
+	case c.reads < 0 || c.reads > 1:
+		return errors.New("-reads must be between 0 and 1")
+	case c.keys <= 0:
+		return errors.New("-keys must be positive")
+	case c.dist != "uniform" && c.dist != "zipf":
+		return fmt.Errorf("unknown distribution %q", c.dist)
+	case c.dist == "zipf" && c.zipfS <= 1:
+		return errors.New("-zipf-s must be greater than 1")
+	case c.valueSize < 0:
+		return errors.New("-value must not be negative")
+	}
+	return nil
+}
+
+// target - то, что нагружаем: client.Client или встроенное хранилище
+type target interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Close() error
+}
+
+// embedded - встроенное хранилище с интерфейсом клиента
+type embedded struct {
+	s *store.Store
+}
+
+func (e embedded) Get(_ context.Context, key string) (string, bool, error) {
+	value, ok := e.s.Get(key)
+	return value, ok, nil
+}
+
+func (e embedded) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	return e.s.Set(key, value, ttl)
+}
+
+func (e embedded) Close() error {
+	return e.s.Close(context.Background())
+}
+
+func dial(httpURL, respAddr, token string, maxMemory int64) (target, error) {
+	var opts []client.Option
+	if token != "" {
+		opts = append(opts, client.WithToken(token))
+	}
+	switch {
+	case httpURL != "" && respAddr != "":
+		return nil, errors.New("only one of -http and -resp can be set")
+	case respAddr != "":
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return client.DialRESP(ctx, respAddr, opts...)
+	case httpURL != "":
+		return client.NewHTTP(httpURL, nil, opts...), nil
+	default:
+		s, err := store.New(store.WithMaxMemory(maxMemory))
+		if err != nil {
+			return nil, err
+		}
+		return embedded{s: s}, nil
+	}
+}
+
+func benchKey(i uint64) string {
+	return "bench:" + strconv.FormatUint(i, 10)
+}
+
+// prefill записывает все ключи пространства в cfg.concurrency горутин
+func prefill(ctx context.Context, t target, cfg config, value string) error {
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	for w := range cfg.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < cfg.keys; i += cfg.concurrency {
+				if err := t.Set(ctx, benchKey(uint64(i)), value, cfg.ttl); err != nil {
+					once.Do(func() { first = err })
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return first
+}
+
+// keyGen - генератор номеров ключей одного воркера
+type keyGen func() uint64
+
+func newKeyGen(cfg config, r *rand.Rand) keyGen {
+	if cfg.dist == "zipf" {
+		z := rand.NewZipf(r, cfg.zipfS, 1, uint64(cfg.keys-1))
+		return z.Uint64
+	}
+	n := uint64(cfg.keys)
+	return func() uint64 { return r.Uint64N(n) }
+}
+
+// result - итог прогона, собранный из воркеров
+type result struct {
+	elapsed    time.Duration
+	get, set   histogram
+	hits       uint64
+	getErrors  uint64
+	setErrors  uint64
+	firstError error
+}
+
+func bench(ctx context.Context, t target, cfg config, value string) *result {
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		res result
+		wg  sync.WaitGroup
+	)
+	started := time.Now()
+	for range cfg.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local result
+			r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+			next := newKeyGen(cfg, r)
+			for ctx.Err() == nil {
+				key := benchKey(next())
+				opStart := time.Now()
+				if r.Float64() < cfg.reads {
+					_, ok, err := t.Get(ctx, key)
+					if ctx.Err() != nil {
+						break // операцию прервал конец прогона
+					}
+					local.get.observe(time.Since(opStart))
+					switch {
+					case err != nil:
+						local.getErrors++
+						local.firstError = firstOf(local.firstError, err)
+					case ok:
+						local.hits++
+					}
+					continue
+				}
+				err := t.Set(ctx, key, value, cfg.ttl)
+				if ctx.Err() != nil {
+					break
+				}
+				local.set.observe(time.Since(opStart))
+				if err != nil {
+					local.setErrors++
+					local.firstError = firstOf(local.firstError, err)
+				}
+			}
+			mu.Lock()
+			res.merge(&local)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	res.elapsed = time.Since(started)
+	return &res
+}
+
+func firstOf(first, err error) error {
+	if first != nil {
+		return first
+	}
+	return err
+}
+
+func (r *result) merge(o *result) {
+	r.get.merge(&o.get)
+	r.set.merge(&o.set)
+	r.hits += o.hits
+	r.getErrors += o.getErrors
+	r.setErrors += o.setErrors
+	r.firstError = firstOf(r.firstError, o.firstError)
+}
+
+func (r *result) print(w io.Writer, cfg config) {
+	total := r.get.count + r.set.count
+	seconds := r.elapsed.Seconds()
+	fmt.Fprintf(w, "workers %d, keys %d (%s), value %d bytes, reads %.0f%%\n",
+		cfg.concurrency, cfg.keys, cfg.dist, cfg.valueSize, cfg.reads*100)
+	fmt.Fprintf(w, "%d ops in %s, %.0f ops/s\n", total, r.elapsed.Round(time.Millisecond), float64(total)/seconds)
+	fmt.Fprintf(w, "%-4s %10s %12s %10s %10s %10s %10s %10s %10s\n",
+		"op", "count", "ops/s", "mean", "p50", "p95", "p99", "p999", "max")
+	for _, op := range []struct {
+		name string
+		h    *histogram
+	}{{"get", &r.get}, {"set", &r.set}} {
+		h := op.h
+		fmt.Fprintf(w, "%-4s %10d %12.0f %10s %10s %10s %10s %10s %10s\n", op.name, h.count, float64(h.count)/seconds,
+			h.mean(), h.quantile(0.5), h.quantile(0.95), h.quantile(0.99), h.quantile(0.999), h.max)
+	}
+	if r.get.count > 0 {
+		fmt.Fprintf(w, "hit ratio %.2f%%\n", float64(r.hits)/float64(r.get.count)*100)
+	}
+	if r.getErrors+r.setErrors > 0 {
+		fmt.Fprintf(w, "errors: get %d, set %d, first: %v\n", r.getErrors, r.setErrors, r.firstError)
+	}
+}
+
+// histogram - гистограмма задержек одного воркера, корзины как в store.WithLatencyHistograms:
+// 8 корзин на степень двойки наносекунд, ошибка квантилей не больше 12.5%
+type histogram struct {
+	counts [64 * subBuckets]uint64
+	count  uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+const (
+	subBucketBits = 3
+	subBuckets    = 1 << subBucketBits
+)
+
+func bucketIndex(ns uint64) int {
+	if ns < subBuckets {
+		return int(ns)
+	}
+	exp := bits.Len64(ns) - 1
+	sub := (ns >> (exp - subBucketBits)) & (subBuckets - 1)
+	return (exp-subBucketBits+1)*subBuckets + int(sub)
+}
+
+func bucketUpper(i int) time.Duration {
+	if i < subBuckets {
+		return time.Duration(i + 1)
+	}
+	exp := i/subBuckets + subBucketBits - 1
+	sub := uint64(i % subBuckets)
+	return time.Duration((subBuckets + sub + 1) << (exp - subBucketBits))
+}
+
+func (h *histogram) observe(d time.Duration) {
+	d = max(d, 0)
+	h.counts[bucketIndex(uint64(d))]++
+	h.count++
+	h.sum += d
+	h.max = max(h.max, d)
+}
+
+func (h *histogram) merge(o *histogram) {
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	h.count += o.count
+	h.sum += o.sum
+	h.max = max(h.max, o.max)
+}
+
+func (h *histogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+func (h *histogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(q*float64(h.count-1)) + 1
+	var seen uint64
+	for i, c := range h.counts {
+		if seen += c; seen >= rank {
+			return min(bucketUpper(i), h.max)
+		}
+	}
+	return h.max
+}
@@ -0,0 +1,296 @@
+// Команда storecli - администрирование работающего кеша через HTTP или RESP фронтенд.
+//
+//	storecli [-http URL | -resp ADDR] [-token T] [-tls] [-ca FILE] [-timeout 5s] <command> [args]
+//
+// Токен берётся из -token или переменной окружения STORE_AUTH_TOKEN. Для -http
+// TLS включается схемой https://, для -resp - флагом -tls; -ca задаёт корневой
+// сертификат сервера.
+//
+// Команды:
+//
+//	get KEY                  вывести значение, код выхода 1 если ключа нет
+//	set [-ttl 10m] KEY VALUE записать значение, VALUE "-" читается из stdin
+//	del KEY...               удалить ключи
+//	keys [PATTERN]           список ключей по glob-шаблону, по умолчанию "*"
+//	stats                    статистика в JSON (только HTTP)
+//	dump [FILE]              снапшот в FILE или stdout (только HTTP)
+//	restore [FILE]           загрузить снапшот из FILE или stdin (только HTTP)
+//	backup TARGET [NAME]     снапшот сервера в его цель резервного копирования (только HTTP)
+//	copy URL                 перенести снапшот сервера в другой сервер по HTTP
+//
+// У dump и restore флаг -format выбирает формат: json (по умолчанию) - снапшот сервера,
+// jsonl - объект JSON на ключ для jq и фикстур, csv - таблица key,type,value,ttl,views.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/client"
+)
+
+// errNotFound - ключ не найден, печатать нечего, но код выхода ненулевой
+var errNotFound = errors.New("key not found")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if !errors.Is(err, errNotFound) {
+			fmt.Fprintln(os.Stderr, "storecli:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("storecli", flag.ContinueOnError)
+	httpURL := fs.String("http", "", "base URL of the REST API, e.g. http://localhost:8080")
+	respAddr := fs.String("resp", "", "address of the RESP frontend, e.g. localhost:6379")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout for the whole command")
+	token := fs.String("token", os.Getenv("STORE_AUTH_TOKEN"), "auth token, defaults to $STORE_AUTH_TOKEN")
+	useTLS := fs.Bool("tls", false, "connect to the RESP frontend over TLS")
+	caFile := fs.String("ca", "", "PEM file with CA certificates to verify the server")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: storecli [-http URL | -resp ADDR] [-token T] [-tls] [-ca FILE] [-timeout 5s] get|set|del|keys|stats|dump|restore|backup|copy [args]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("command is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var opts []client.Option
+	if *token != "" {
+		opts = append(opts, client.WithToken(*token))
+	}
+	if *useTLS || *caFile != "" {
+		tlsCfg, err := loadTLS(*caFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, client.WithTLS(tlsCfg))
+	}
+
+	c, err := dial(ctx, *httpURL, *respAddr, opts...)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "get":
+		return cmdGet(ctx, c, cmdArgs, stdout)
+	case "set":
+		return cmdSet(ctx, c, cmdArgs, stdin)
+	case "del":
+		if len(cmdArgs) == 0 {
+			return errors.New("del: at least one key is required")
+		}
+		return c.Delete(ctx, cmdArgs...)
+	case "keys":
+		return cmdKeys(ctx, c, cmdArgs, stdout)
+	case "stats":
+		st, err := c.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(st)
+	case "dump":
+		return cmdDump(ctx, c, cmdArgs, stdout)
+	case "restore":
+		return cmdRestore(ctx, c, cmdArgs, stdin)
+	case "backup":
+		return cmdBackup(ctx, c, cmdArgs, stdout)
+	case "copy":
+		if len(cmdArgs) != 1 {
+			return errors.New("copy: destination URL is required")
+		}
+		return client.Copy(ctx, client.NewHTTP(cmdArgs[0], nil, opts...), c)
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func dial(ctx context.Context, httpURL, respAddr string, opts ...client.Option) (client.Client, error) {
+	switch {
+	case httpURL != "" && respAddr != "":
+		return nil, errors.New("only one of -http and -resp can be set")
+	case respAddr != "":
+		return client.DialRESP(ctx, respAddr, opts...)
+	case httpURL != "":
+		return client.NewHTTP(httpURL, nil, opts...), nil
+	default:
+		return client.NewHTTP("http://localhost:8080", nil, opts...), nil
+	}
+}
+
+// loadTLS собирает TLS конфигурацию, caFile == "" - системные корневые сертификаты
+func loadTLS(caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RootCAs = x509.NewCertPool()
+	if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", caFile)
+	}
+	return cfg, nil
+}
+
+func cmdGet(ctx context.Context, c client.Client, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("get: exactly one key is required")
+	}
+	value, ok, err := c.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errNotFound
+	}
+	_, err = fmt.Fprintln(stdout, value)
+	return err
+}
+
+func cmdSet(ctx context.Context, c client.Client, args []string, stdin io.Reader) error {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	ttl := fs.Duration("ttl", 0, "TTL of the key, 0 uses the server default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("set: KEY and VALUE are required")
+	}
+
+	value := fs.Arg(1)
+	if value == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+	return c.Set(ctx, fs.Arg(0), value, *ttl)
+}
+
+func cmdKeys(ctx context.Context, c client.Client, args []string, stdout io.Writer) error {
+	pattern := "*"
+	switch len(args) {
+	case 0:
+	case 1:
+		pattern = args[0]
+	default:
+		return errors.New("keys: at most one pattern is allowed")
+	}
+
+	keys, err := c.Keys(ctx, pattern)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		fmt.Fprintln(stdout, k)
+	}
+	return nil
+}
+
+func cmdDump(ctx context.Context, c client.Client, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	format := fs.String("format", "json", "snapshot format: json, jsonl or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("dump: at most one file is allowed")
+	}
+	dump := c.Dump
+	if *format != "json" {
+		hc, ok := c.(*client.HTTP)
+		if !ok {
+			return errors.New("dump: -format requires -http")
+		}
+		dump = func(ctx context.Context, w io.Writer) error { return hc.DumpFormat(ctx, w, *format) }
+	}
+	if fs.NArg() == 0 {
+		return dump(ctx, stdout)
+	}
+
+	f, err := os.Create(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := dump(ctx, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func cmdRestore(ctx context.Context, c client.Client, args []string, stdin io.Reader) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	format := fs.String("format", "json", "snapshot format: json, jsonl or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("restore: at most one file is allowed")
+	}
+	restore := c.Restore
+	if *format != "json" {
+		hc, ok := c.(*client.HTTP)
+		if !ok {
+			return errors.New("restore: -format requires -http")
+		}
+		restore = func(ctx context.Context, r io.Reader) error { return hc.RestoreFormat(ctx, r, *format) }
+	}
+	if fs.NArg() == 0 {
+		return restore(ctx, stdin)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return restore(ctx, f)
+}
+
+func cmdBackup(ctx context.Context, c client.Client, args []string, stdout io.Writer) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("backup: TARGET [NAME] is required")
+	}
+	hc, ok := c.(*client.HTTP)
+	if !ok {
+		return errors.New("backup: requires -http")
+	}
+	name := ""
+	if len(args) == 2 {
+		name = args[1]
+	}
+	res, err := hc.Backup(ctx, args[0], name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s/%s: %d bytes in %s\n", res.Target, res.Name, res.Bytes, res.Took)
+	return nil
+}
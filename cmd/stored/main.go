@@ -0,0 +1,124 @@
+// Команда stored - standalone сервис кеша: хранилище, очистка по TTL, снапшоты на диск,
+// метрики и сетевые фронтенды (HTTP, RESP, gRPC) в одном бинарнике.
+//
+// Конфигурация читается из JSON или YAML файла (-config или переменная окружения STORED_CONFIG),
+// флаги переопределяют значения из файла, так что один общий конфиг можно подкручивать
+// под конкретный запуск:
+//
+//	stored -config /etc/store.yaml -max-memory 256MB -default-ttl 10m -resp-addr :6380
+//
+// Логи пишутся в stderr. По SIGINT/SIGTERM сервер останавливает листенеры, дожидается
+// активных запросов и сохраняет снапшот, если он настроен, - всё, что нужно для запуска
+// в контейнере.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/internal/server"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "stored:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	fs := flag.NewFlagSet("stored", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("STORED_CONFIG"), "path to JSON or YAML config file")
+
+	// значения флагов собираем в отдельный Config и потом переносим только явно заданные
+	var fcfg server.Config
+	fs.Var(&fcfg.CleanupInterval, "cleanup-interval", "how often expired keys are removed")
+	fs.Var(&fcfg.DefaultTTL, "default-ttl", "TTL for writes without explicit TTL, 0 disables")
+	fs.Var(&fcfg.MaxMemory, "max-memory", "approximate memory limit, e.g. 256MB, 0 disables")
+	fs.StringVar(&fcfg.SnapshotPath, "snapshot", "", "snapshot file path")
+	fs.Var(&fcfg.SnapshotInterval, "snapshot-interval", "how often snapshot is saved, 0 saves only on shutdown")
+	fs.StringVar(&fcfg.MetricsAddr, "metrics-addr", "", "listen address for /metrics and /debug/vars")
+	fs.StringVar(&fcfg.HTTPAddr, "http-addr", "", "listen address for the REST API")
+	fs.StringVar(&fcfg.RESPAddr, "resp-addr", "", "listen address for the RESP (redis-cli) frontend")
+	fs.StringVar(&fcfg.GRPCAddr, "grpc-addr", "", "listen address for the gRPC service")
+	fs.Var(&fcfg.ShutdownTimeout, "shutdown-timeout", "how long to wait for listeners on shutdown")
+	fs.StringVar(&fcfg.TLSCertFile, "tls-cert", "", "PEM certificate file, enables TLS with -tls-key")
+	fs.StringVar(&fcfg.TLSKeyFile, "tls-key", "", "PEM private key file")
+	fs.StringVar(&fcfg.HandoffSocket, "handoff-socket", "", "unix socket to take over a running process and hand off to the next one")
+	fs.StringVar(&fcfg.ReplicationAddr, "replication-addr", "", "listen address for replicas")
+	fs.StringVar(&fcfg.ReplicaOf, "replica-of", "", "replication address of the primary, makes this node a read-only replica")
+	fs.Var(&fcfg.HealthCleanupMaxAge, "health-cleanup-max-age", "health check fails if no cleanup pass finished for this long, 0 disables")
+	fs.Var(&fcfg.HealthSnapshotMaxAge, "health-snapshot-max-age", "readiness fails if no snapshot was saved for this long, 0 disables")
+	fs.Float64Var(&fcfg.HealthMemoryRatio, "health-memory-ratio", 0, "readiness fails above this fraction of max-memory, 0 disables")
+	fs.Var(&fcfg.HealthReplicaMaxLag, "health-replica-max-lag", "readiness fails if replica lag exceeds this, 0 disables")
+	fs.StringVar(&fcfg.AuthToken, "auth-token", "", "bearer token required by http, resp and grpc (prefer $"+server.AuthTokenEnv+")")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := server.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cleanup-interval":
+			cfg.CleanupInterval = fcfg.CleanupInterval
+		case "default-ttl":
+			cfg.DefaultTTL = fcfg.DefaultTTL
+		case "max-memory":
+			cfg.MaxMemory = fcfg.MaxMemory
+		case "snapshot":
+			cfg.SnapshotPath = fcfg.SnapshotPath
+		case "snapshot-interval":
+			cfg.SnapshotInterval = fcfg.SnapshotInterval
+		case "metrics-addr":
+			cfg.MetricsAddr = fcfg.MetricsAddr
+		case "http-addr":
+			cfg.HTTPAddr = fcfg.HTTPAddr
+		case "resp-addr":
+			cfg.RESPAddr = fcfg.RESPAddr
+		case "grpc-addr":
+			cfg.GRPCAddr = fcfg.GRPCAddr
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = fcfg.ShutdownTimeout
+		case "tls-cert":
+			cfg.TLSCertFile = fcfg.TLSCertFile
+		case "tls-key":
+			cfg.TLSKeyFile = fcfg.TLSKeyFile
+		case "auth-token":
+			cfg.AuthToken = fcfg.AuthToken
+		case "handoff-socket":
+			cfg.HandoffSocket = fcfg.HandoffSocket
+		case "replication-addr":
+			cfg.ReplicationAddr = fcfg.ReplicationAddr
+		case "replica-of":
+			cfg.ReplicaOf = fcfg.ReplicaOf
+		case "health-cleanup-max-age":
+			cfg.HealthCleanupMaxAge = fcfg.HealthCleanupMaxAge
+		case "health-snapshot-max-age":
+			cfg.HealthSnapshotMaxAge = fcfg.HealthSnapshotMaxAge
+		case "health-memory-ratio":
+			cfg.HealthMemoryRatio = fcfg.HealthMemoryRatio
+		case "health-replica-max-lag":
+			cfg.HealthReplicaMaxLag = fcfg.HealthReplicaMaxLag
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return server.Run(ctx, cfg, logger)
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Codec сериализует значения для SetJSON и GetJSON (хранилища и Namespace) и снапшоты
+// (WithSnapshotCodec). Подойдёт любая обёртка над gob, msgpack, protobuf или CBOR с этими двумя методами.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec - Codec поверх encoding/json, используется по умолчанию.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// WithCodec задаёт Codec для SetJSON и GetJSON, по умолчанию JSONCodec.
+func WithCodec(c Codec) Option {
+	return func(cfg *config) {
+		cfg.codec = c
+	}
+}
+
+// WithSnapshotCodec задаёт формат SaveSnapshot и LoadSnapshot. По умолчанию снапшот - JSON,
+// который пишется и читается потоком, с другим Codec снапшот целиком собирается в памяти.
+// Снапшот читается только тем же Codec, которым записан.
+func WithSnapshotCodec(c Codec) Option {
+	return func(cfg *config) {
+		cfg.snapshotCodec = c
+	}
+}
+
+// WithNamespaceCodec задаёт Codec для SetJSON и GetJSON пространства, по умолчанию Codec хранилища.
+func WithNamespaceCodec(c Codec) NamespaceOption {
+	return func(ns *Namespace) {
+		ns.own.Codec = c
+	}
+}
+
+// SetJSON сериализует v через Codec хранилища и сохраняет результат как Set.
+func (s *Store) SetJSON(key string, v any, ttl time.Duration) error {
+	data, err := marshalValue(s.cfg.codec, key, v)
+	if err != nil {
+		return err
+	}
+	return s.Set(key, data, ttl)
+}
+
+// GetJSON читает значение и десериализует его в out через Codec хранилища.
+// false без ошибки - ключа нет или он истёк, ошибка декодирования приходит вместе с true.
+func (s *Store) GetJSON(key string, out any) (bool, error) {
+	v, ok := s.Get(key)
+	if !ok {
+		return false, nil
+	}
+	return true, unmarshalValue(s.cfg.codec, key, v, out)
+}
+
+// SetJSON сериализует v через Codec пространства и сохраняет результат как Set пространства.
+func (ns *Namespace) SetJSON(key string, v any, ttl time.Duration) error {
+	data, err := marshalValue(ns.valueCodec(), key, v)
+	if err != nil {
+		return err
+	}
+	return ns.Set(key, data, ttl)
+}
+
+// GetJSON как Store.GetJSON, но с Codec пространства.
+func (ns *Namespace) GetJSON(key string, out any) (bool, error) {
+	v, ok := ns.Get(key)
+	if !ok {
+		return false, nil
+	}
+	return true, unmarshalValue(ns.valueCodec(), key, v, out)
+}
+
+func (ns *Namespace) valueCodec() Codec {
+	return ns.Settings().Codec
+}
+
+func marshalValue(c Codec, key string, v any) (string, error) {
+	data, err := c.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("store: marshal %q: %w", key, err)
+	}
+	return string(data), nil
+}
+
+func unmarshalValue(c Codec, key, data string, out any) error {
+	if err := c.Unmarshal([]byte(data), out); err != nil {
+		return fmt.Errorf("store: unmarshal %q: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"sync"
+)
+
+// WithCompression сжимает gzip строковые значения не короче minSize байт при записи
+// (Set, SetNX, SetXX, LoadSnapshot) и распаковывает при чтении. Значение остаётся сжатым,
+// только если стало меньше, так что уже сжатые данные хранятся как есть. Хорошо работает
+// на JSON и тексте, сэкономленный объём виден в Stats.CompressionSaved.
+// WithMaxValueSize и WithMaxMemory считают: первый - исходный размер, второй - сжатый.
+func WithCompression(minSize int) Option {
+	return func(c *config) {
+		c.compressMin = minSize
+	}
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return w
+	},
+}
+
+// compressValue сжимает значение по WithCompression, ok=false - значение сохраняется как есть
+func (s *Store) compressValue(value string) (string, bool) {
+	if s.cfg.compressMin <= 0 || len(value) < s.cfg.compressMin {
+		return value, false
+	}
+
+	var buf bytes.Buffer
+	w := gzipWriters.Get().(*gzip.Writer)
+	w.Reset(&buf)
+	_, err := io.WriteString(w, value)
+	if err == nil {
+		err = w.Close()
+	}
+	gzipWriters.Put(w)
+	if err != nil || buf.Len() >= len(value) {
+		return value, false
+	}
+	s.stats.compressed.Add(1)
+	return buf.String(), true
+}
+
+// gzipReader - распаковщик с источником и буфером копирования для переиспользования
+// через пул, что-бы чтение сжатого значения выделяло память только под результат
+type gzipReader struct {
+	src strings.Reader
+	zr  gzip.Reader
+	buf [4 << 10]byte
+}
+
+var gzipReaders = sync.Pool{
+	New: func() any { return new(gzipReader) },
+}
+
+// plain - строковое значение элемента, распакованное, если оно сжато
+func (it *Item) plain() string {
+	if it.rawSize == 0 {
+		return it.Value
+	}
+	r := gzipReaders.Get().(*gzipReader)
+	defer gzipReaders.Put(r)
+	r.src.Reset(it.Value)
+	if err := r.zr.Reset(&r.src); err != nil {
+		return it.Value // сжимаем только сами, до сюда не доходит
+	}
+	var b strings.Builder
+	b.Grow(it.rawSize)
+	io.CopyBuffer(&b, &r.zr, r.buf[:])
+	return b.String()
+}
+
+// setCompressed кладёт в ещё не опубликованный элемент значение, сжатое по WithCompression
+func (s *Store) setCompressed(it *Item, value string) {
+	it.Value, it.rawSize = value, 0
+	if v, ok := s.compressValue(value); ok {
+		it.Value, it.rawSize = v, len(value)
+	}
+}
+
+// compressionSaved - сколько байт экономит сжатие элемента
+func (it *Item) compressionSaved() int64 {
+	if it.rawSize == 0 {
+		return 0
+	}
+	return int64(it.rawSize - len(it.Value))
+}
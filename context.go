@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// GetContext - Get, который учитывает отмену и дедлайн ctx: отменённый ctx сразу возвращает
+// ctx.Err(), а ожидание чужой записи с WithMissDedup прерывается, как только ctx завершится.
+// Промах - ("", false, nil), как у Get.
+func (s *Store) GetContext(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	if t, ok := s.startOp(opGet, key); ok {
+		defer t.stop()
+	}
+	return s.lookup(ctx, key)
+}
+
+// SetContext - Set, который не пишет значение, если ctx уже отменён или истёк его дедлайн.
+// Сама запись в память не блокируется, поэтому отмена после проверки её не прерывает.
+// Автор записи для журнала аудита и атрибуты запроса берутся из ctx, см. WithActor и WithAttrs.
+func (s *Store) SetContext(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.set(key, value, ttl, ctxOpts(ctx))
+}
+
+// DeleteContext - Delete, который не удаляет ключ, если ctx уже отменён. Автор удаления
+// для журнала аудита и атрибуты запроса берутся из ctx, см. WithActor и WithAttrs.
+func (s *Store) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.remove(key, ctxOpts(ctx))
+	return nil
+}
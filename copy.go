@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// copyBatch - сколько ключей CopyFrom читает из источника за раз
+const copyBatch = 256
+
+// CopyProgress - итог копирования, см. CopyFrom и CopyFromSnapshot.
+type CopyProgress struct {
+	Total   int `json:"total"`   // ключей в источнике под префиксом
+	Copied  int `json:"copied"`  // записано
+	Skipped int `json:"skipped"` // уже были в хранилище или пропали из источника, см. WithCopyOverwrite
+	Failed  int `json:"failed"`  // запись вернула ошибку
+}
+
+// CopyOption настраивает CopyFrom и CopyFromSnapshot.
+type CopyOption func(*copyConfig)
+
+type copyConfig struct {
+	prefix    string
+	overwrite bool
+}
+
+// WithCopyPrefix копирует только ключи с префиксом prefix.
+func WithCopyPrefix(prefix string) CopyOption {
+	return func(c *copyConfig) {
+		c.prefix = prefix
+	}
+}
+
+// WithCopyOverwrite копирует и ключи, которые уже есть в хранилище. По умолчанию они
+// пропускаются: при живой миграции новое хранилище уже принимает записи, и они свежее.
+func WithCopyOverwrite() CopyOption {
+	return func(c *copyConfig) {
+		c.overwrite = true
+	}
+}
+
+// CopyFrom переносит ключи из src в хранилище с их оставшимся TTL, для живой миграции со
+// старого экземпляра на новый: новое хранилище уже обслуживает трафик, а CopyFrom догружает
+// в него остальное. Ключи читаются пачками, так что источник не блокируется надолго.
+//
+// Если src - *Store, ключи читаются через GetManyDetailed: чтениями в источнике они не
+// считаются, а Views переносятся. У другого Cache значение и TTL берутся через Get и TTL.
+// Коллекции Cache отдаёт строкой JSON, и они копируются строкой; с типами их переносит
+// CopyFromSnapshot. Ошибки отдельных ключей копирование не останавливают, как в Warm.
+func (s *Store) CopyFrom(ctx context.Context, src Cache, opts ...CopyOption) (CopyProgress, error) {
+	cfg := copyConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	keys := src.Keys(escapeGlob(cfg.prefix) + "*")
+	progress := CopyProgress{Total: len(keys)}
+	var first error
+
+	detailed, _ := src.(interface {
+		GetManyDetailed(keys ...string) map[string]ItemDetails
+	})
+	for len(keys) > 0 {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		batch := keys[:min(copyBatch, len(keys))]
+		keys = keys[len(batch):]
+		if !cfg.overwrite {
+			batch = s.copyMissing(batch, &progress)
+		}
+
+		var found map[string]ItemDetails
+		if detailed != nil {
+			found = detailed.GetManyDetailed(batch...)
+		} else {
+			found = make(map[string]ItemDetails, len(batch))
+			for _, key := range batch {
+				if d, ok := copyDetails(src, key); ok {
+					found[key] = d
+				}
+			}
+		}
+		for _, key := range batch {
+			d, ok := found[key]
+			if !ok {
+				progress.Skipped++ // истёк или удалён, пока копировали
+				continue
+			}
+			ttl := d.TTL
+			if ttl == 0 {
+				ttl = NoExpiration // без срока и в новом хранилище, а не WithDefaultTTL
+			}
+			if err := s.set(key, d.Value, ttl, writeOpts{views: d.Views}); err != nil {
+				progress.Failed++
+				if first == nil {
+					first = err
+				}
+				continue
+			}
+			progress.Copied++
+		}
+	}
+
+	if first != nil {
+		return progress, fmt.Errorf("store: copy: %d of %d keys failed, first: %w", progress.Failed, progress.Total, first)
+	}
+	s.cfg.logger.Info("store: copy finished", "keys", progress.Total, "copied", progress.Copied, "skipped", progress.Skipped)
+	return progress, nil
+}
+
+// copyMissing оставляет в batch ключи, которых нет в хранилище, остальные считает пропущенными
+func (s *Store) copyMissing(batch []string, p *CopyProgress) []string {
+	res := make([]string, 0, len(batch))
+	for _, key := range batch {
+		if s.present(key) {
+			p.Skipped++
+			continue
+		}
+		res = append(res, key)
+	}
+	return res
+}
+
+// copyDetails читает ключ произвольного Cache: TTL до Get, что-бы не продлить значению жизнь,
+// если ключ перезапишут между вызовами
+func copyDetails(src Cache, key string) (ItemDetails, bool) {
+	ttl, ok := src.TTL(key)
+	if !ok {
+		return ItemDetails{}, false
+	}
+	value, ok := src.Get(key)
+	if !ok {
+		return ItemDetails{}, false
+	}
+	return ItemDetails{ItemDTO: ItemDTO{Value: value}, TTL: ttl}, true
+}
+
+// CopyFromSnapshot - CopyFrom из снапшота SaveSnapshot, для миграции с удалённого сервера,
+// который отдаёт снапшот (client.Client.Dump), или между процессами через файл. В отличие
+// от LoadSnapshot учитывает WithCopyPrefix и WithCopyOverwrite и переносит коллекции с
+// их типом. Снапшот читается целиком, а пишется одной блокировкой, как в LoadSnapshot:
+//
+//	pr, pw := io.Pipe()
+//	go func() { pw.CloseWithError(old.Dump(ctx, pw)) }()
+//	p, err := s.CopyFromSnapshot(pr, store.WithCopyPrefix("user:"))
+func (s *Store) CopyFromSnapshot(r io.Reader, opts ...CopyOption) (CopyProgress, error) {
+	cfg := copyConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := s.enter(); err != nil {
+		return CopyProgress{}, err
+	}
+	defer s.leave()
+	_, items, err := s.decodeSnapshot(r)
+	if err != nil {
+		return CopyProgress{}, fmt.Errorf("store: copy: %w", err)
+	}
+
+	var progress CopyProgress
+	now := s.now()
+	s.mu.Lock()
+	for key, si := range items {
+		if !strings.HasPrefix(key, cfg.prefix) {
+			continue
+		}
+		progress.Total++
+		if cur, ok := s.data[key]; ok && !cur.expiredAt(now) && !cfg.overwrite {
+			progress.Skipped++
+			continue
+		}
+		if !si.ExpiresAt.IsZero() && now.After(si.ExpiresAt) {
+			progress.Skipped++
+			continue
+		}
+		item, ok := s.itemFromSnapshot(key, si)
+		if !ok {
+			progress.Skipped++
+			continue
+		}
+		s.putLocked(key, item)
+		s.sinkBulkLocked(EventSet, key, item, now)
+		progress.Copied++
+	}
+	s.evictLocked(now, "")
+	s.mu.Unlock()
+
+	s.cfg.logger.Info("store: copy from snapshot finished", "keys", progress.Total, "copied", progress.Copied, "skipped", progress.Skipped)
+	return progress, nil
+}
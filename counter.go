@@ -0,0 +1,133 @@
+package store
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Counter - целочисленный счетчик на ключе хранилища. Значение хранится числом, а не строкой,
+// поэтому Add не разбирает и не форматирует его, как IncrBy, а прибавляет атомарно под
+// блокировкой чтения: одновременные Add не ждут друг друга.
+//
+// Дешевизна достигнута тем, что Add на существующем счетчике не рассылает события подписчикам
+// (Watch, Subscribe) и не считается в Stats.Sets - на счетчиках просмотров и лимитах это
+// тысячи вызовов в секунду. Создание счетчика записывается как обычно. С WithSink каждый Add
+// пишет новое значение в приёмник (и реплики, см. пакет replication) и ради порядка этих
+// записей идёт под блокировкой записи, так что одновременные Add друг друга ждут.
+type Counter struct {
+	s   *Store
+	key string
+}
+
+// NewCounter возвращает счетчик на ключе key. Ключ создаётся при первом Add
+// с TTL по умолчанию (WithDefaultTTL), пока его нет - Value возвращает 0.
+// Get по ключу счетчика возвращает значение строкой.
+func (s *Store) NewCounter(key string) *Counter {
+	return &Counter{s: s, key: key}
+}
+
+// Add атомарно прибавляет delta и возвращает новое значение. Если результат переполняет
+// int64, значение не меняется и возвращается ErrNotInteger. Если ключ хранит не счетчик -
+// ErrWrongType. Как и остальные записи, после Close возвращает ErrClosed,
+// в режиме только для чтения - ErrReadOnly.
+func (c *Counter) Add(delta int64) (int64, error) {
+	if err := c.s.enter(); err != nil {
+		return 0, err
+	}
+	defer c.s.leave()
+
+	raw := c.s.wkey(c.key)
+	if c.s.sink != nil {
+		return c.s.addSinked(c.key, raw, delta)
+	}
+	now := c.s.now()
+
+	c.s.mu.RLock()
+	item, ok := c.s.data[raw]
+	if ok && !item.expiredAt(now) && item.kind == kindCounter {
+		n, err := addChecked(item.counter, delta)
+		c.s.mu.RUnlock()
+		return n, err
+	}
+	c.s.mu.RUnlock()
+
+	return c.s.createCounter(c.key, delta)
+}
+
+// addSinked - Add с WithSink: значение уходит в приёмник под блокировкой записи, иначе
+// одновременные Add могли бы положить свои значения в очередь не в том порядке, в каком
+// их получили, и приёмник остался бы со старым
+func (s *Store) addSinked(key, raw string, delta int64) (int64, error) {
+	queued := s.sinkReserve()
+	now := s.now()
+	s.mu.Lock()
+	item, ok := s.data[raw]
+	if !ok || item.expiredAt(now) || item.kind != kindCounter {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return s.createCounter(key, delta)
+	}
+	n, err := addChecked(item.counter, delta)
+	if err == nil && queued {
+		s.sinkAppendLocked(EventSet, raw, item, now)
+		queued = false
+	}
+	s.mu.Unlock()
+	s.sinkRelease(queued) // переполнение: значение не изменилось
+	return n, err
+}
+
+// createCounter - медленный путь Add: создаёт ключ под блокировкой записи.
+// Пока блокировку ждали, счетчик мог создать другой Add, тогда просто прибавляем
+// (с WithSink - записываем, что-бы новое значение дошло до приёмника)
+func (s *Store) createCounter(key string, delta int64) (int64, error) {
+	var n int64
+	var addErr error
+	err := s.update(key, kindCounter, func(next *Item) bool {
+		if next.counter == nil {
+			next.counter = new(atomic.Int64)
+			next.counter.Store(delta)
+			n = delta
+			return true
+		}
+		n, addErr = addChecked(next.counter, delta)
+		return addErr == nil && s.sink != nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, addErr
+}
+
+// addChecked прибавляет delta без переполнения
+func addChecked(v *atomic.Int64, delta int64) (int64, error) {
+	for {
+		cur := v.Load()
+		if (delta > 0 && cur > maxInt64-delta) || (delta < 0 && cur < minInt64-delta) {
+			return cur, ErrNotInteger
+		}
+		if v.CompareAndSwap(cur, cur+delta) {
+			return cur + delta, nil
+		}
+	}
+}
+
+// Value возвращает текущее значение, 0 - если ключа нет, он истёк или хранит не счетчик.
+// Число просмотров ключа не увеличивается.
+func (c *Counter) Value() int64 {
+	c.s.mu.RLock()
+	item, ok := c.s.data[c.s.skey(c.key)]
+	c.s.mu.RUnlock()
+
+	if !ok || item.expiredAt(c.s.now()) || item.kind != kindCounter {
+		return 0
+	}
+	return item.counter.Load()
+}
+
+// SetExpiry задаёт счетчику TTL, ttl <= 0 снимает срок истечения.
+// Возвращает false, если счетчика ещё нет или он истёк, а также после Close
+// и в режиме только для чтения, как Expire.
+func (c *Counter) SetExpiry(ttl time.Duration) bool {
+	return c.s.Expire(c.key, ttl)
+}
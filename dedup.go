@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// pendingMiss - промах, для которого первый вызывающий сейчас получает значение из бэкенда
+type pendingMiss struct {
+	done     chan struct{} // закрывается, когда ключ записали
+	deadline time.Time
+}
+
+// WithMissDedup включает окно дедупликации промахов. Первый Get, промахнувшийся по ключу,
+// возвращает промах как обычно - считается, что вызывающий сходит в бэкенд и запишет значение.
+// Следующие Get того же ключа в течение window не промахиваются сразу, а ждут этой записи
+// и возвращают только что записанное значение. Если за window значение так и не записали,
+// ожидающие получают промах.
+//
+// Так обработчики с fan-out, которые делают Get -> бэкенд -> Set без явного загрузчика,
+// не ходят в бэкенд за одним и тем же ключом N раз одновременно.
+func WithMissDedup(window time.Duration) Option {
+	return func(c *config) {
+		c.missDedupWindow = window
+	}
+}
+
+// awaitMiss регистрирует промах по ключу хранения key. Возвращает true, если другой
+// вызывающий уже вычисляет значение и оно было записано, пока мы ждали. Ожидание
+// прерывается отменой ctx, тогда возвращается ctx.Err()
+func (s *Store) awaitMiss(ctx context.Context, key string) (bool, error) {
+	now := s.now()
+
+	s.dedupMu.Lock()
+	p, ok := s.pending[key]
+	if !ok || now.After(p.deadline) {
+		// первый промах: ожидать некого, значение получит сам вызывающий
+		s.pending[key] = &pendingMiss{
+			done:     make(chan struct{}),
+			deadline: now.Add(s.cfg.missDedupWindow),
+		}
+		s.dedupMu.Unlock()
+		return false, nil
+	}
+	s.dedupMu.Unlock()
+
+	wait := p.deadline.Sub(now)
+	if wait <= 0 {
+		return false, nil
+	}
+	timer := s.cfg.clock.NewTicker(wait)
+	defer timer.Stop()
+
+	select {
+	case <-p.done:
+		return true, nil
+	case <-timer.C():
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// resolveMiss будит всех, кто ждёт значение ключа хранения key
+func (s *Store) resolveMiss(key string) {
+	if s.pending == nil {
+		return
+	}
+
+	s.dedupMu.Lock()
+	if p, ok := s.pending[key]; ok {
+		close(p.done)
+		delete(s.pending, key)
+	}
+	s.dedupMu.Unlock()
+}
+
+// pruneMisses удаляет просроченные ожидания, по которым значение так и не записали
+func (s *Store) pruneMisses(now time.Time) {
+	if s.pending == nil {
+		return
+	}
+
+	s.dedupMu.Lock()
+	for key, p := range s.pending {
+		if now.After(p.deadline) {
+			delete(s.pending, key)
+		}
+	}
+	s.dedupMu.Unlock()
+}
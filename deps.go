@@ -0,0 +1,102 @@
+package store
+
+import "time"
+
+// SetWithDeps сохраняет значение, которое не должно пережить свои входы: когда любой ключ
+// из deps удаляется (Delete, истечение TTL, вытеснение, каскад), ключ key удаляется вслед
+// за ним, а за key - ключи, которые зависят уже от него. Так производные и агрегированные
+// значения не отдаются, когда исходных уже нет. Циклы допустимы: каждый ключ удаляется
+// один раз.
+//
+// Каждый ключ из deps должен быть в хранилище и не истечь, иначе значение не записывается
+// и возвращается ErrNotFound. Перезапись входа зависимый ключ не трогает. Зависимости -
+// часть значения, как теги: запись key через Set и другие методы их снимает. Каскадное
+// удаление, как истечение, не попадает в приёмник WithSink и журнал аудита, подписчики
+// Watch видят его как EventDelete.
+func (s *Store) SetWithDeps(key, value string, ttl time.Duration, deps ...string) error {
+	raw := s.wkey(key)
+	keys := make([]string, 0, len(deps))
+	for _, d := range deps {
+		if d = s.skey(d); d != raw {
+			keys = append(keys, d)
+		}
+	}
+	return s.set(key, value, ttl, writeOpts{deps: normalizeTags(keys)})
+}
+
+// Dependents возвращает ключи, которые напрямую зависят от key, см. SetWithDeps.
+func (s *Store) Dependents(key string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	raw := s.skey(key)
+	res := make([]string, 0, len(s.dependents[raw]))
+	for k := range s.dependents[raw] {
+		if uk, ok := s.userKey(k); ok {
+			res = append(res, uk)
+		}
+	}
+	return res
+}
+
+// depsLiveLocked - все ключи deps есть и не истекли, вызывается под s.mu
+func (s *Store) depsLiveLocked(deps []string, now time.Time) bool {
+	for _, d := range deps {
+		if it, ok := s.data[d]; !ok || it.expiredAt(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// linkDepsLocked переносит обратные рёбра ключа key с зависимостей old на зависимости it.
+// old или it может быть nil. Вызывается под s.mu.Lock
+func (s *Store) linkDepsLocked(key string, old, it *Item) {
+	if old != nil {
+		for _, d := range old.deps {
+			if ks := s.dependents[d]; ks != nil {
+				delete(ks, key)
+				if len(ks) == 0 {
+					delete(s.dependents, d)
+				}
+			}
+		}
+	}
+	if it == nil || len(it.deps) == 0 {
+		return
+	}
+	if s.dependents == nil {
+		s.dependents = make(map[string]map[string]struct{})
+	}
+	for _, d := range it.deps {
+		ks := s.dependents[d]
+		if ks == nil {
+			ks = make(map[string]struct{})
+			s.dependents[d] = ks
+		}
+		ks[key] = struct{}{}
+	}
+}
+
+// invalidateDependentsLocked удаляет ключи, зависящие от удалённого key, и далее по цепочке.
+// Вложенные вызовы из removeLocked только ставят ключ в очередь s.cascade, так что глубокая
+// цепочка не растит стек, а цикл заканчивается на уже удалённом ключе. Вызывается под s.mu.Lock
+func (s *Store) invalidateDependentsLocked(key string) {
+	if len(s.dependents[key]) == 0 {
+		return
+	}
+	s.cascade = append(s.cascade, key)
+	if len(s.cascade) > 1 {
+		return // очередь уже разбирается выше по стеку
+	}
+	for i := 0; i < len(s.cascade); i++ {
+		for dep := range s.dependents[s.cascade[i]] {
+			if s.removeLocked(dep, EventDelete) {
+				s.stats.invalidated.Add(1)
+				s.sinkBulkLocked(EventDelete, dep, nil, s.now())
+			}
+		}
+	}
+	clear(s.cascade)
+	s.cascade = s.cascade[:0]
+}
@@ -0,0 +1,38 @@
+package store
+
+import "time"
+
+// WithDeterministic включает детерминированный режим для property- и fuzz-тестов, которые
+// сравнивают хранилище с простой эталонной моделью: одинаковая последовательность вызовов
+// из одной горутины даёт одинаковые результаты от запуска к запуску.
+//
+// В этом режиме:
+//   - время идёт только по часам WithClock (обязателен), например ManualClock;
+//   - хранилище не запускает фоновых горутин: Do, загрузки LoadingStore, включая обновление
+//     устаревших значений, и Warm идут в горутине вызывающего, а WithSink и WithDurableLog,
+//     которые пишут из фоновой горутины, недопустимы; WithInvariantChecks проверяет
+//     инварианты на проходах очистки, а не по тикеру;
+//   - стоимость загрузки меряется по тем же часам, а не по настенным;
+//   - вытеснение WithMaxMemory смотрит все ключи, а не случайную выборку, и при равенстве
+//     выбирает меньший ключ - дороже, но не зависит от порядка обхода мапы;
+//   - ETag не содержит случайной метки хранилища.
+//
+// Недопустимы и опции с недетерминированным результатом: WithAdmission (хеши частот со
+// случайной затравкой) и WithLatencyHistograms (время по настенным часам). Sample, RandomKey
+// и досрочное обновление LoadingStore по XFetch остаются случайными, Watch запускает горутину
+// на подписку - в тестах удобнее Subscribe. Журнал операций для сравнения с моделью пишет
+// teststore.Record.
+func WithDeterministic() Option {
+	return func(c *config) {
+		c.deterministic = true
+	}
+}
+
+// wallNow - время для измерения длительностей, например стоимости загрузки: настенные часы,
+// а с WithDeterministic - часы WithClock, что-бы длительность не зависела от машины
+func (s *Store) wallNow() time.Time {
+	if s.cfg.deterministic {
+		return s.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,23 @@
+package store
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDeterministicInvariantChecksRunInline(t *testing.T) {
+	before := runtime.NumGoroutine()
+	s, err := New(WithDeterministic(), WithClock(NewManualClock(time.Unix(0, 0))), WithInvariantChecks(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := runtime.NumGoroutine(); n != before {
+		t.Fatalf("deterministic store started %d goroutines", n-before)
+	}
+	s.Set("k", "v", 0)
+	if _, err := s.CleanupNow(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
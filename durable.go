@@ -0,0 +1,203 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoDurableLog возвращают SetDurable и DeleteDurable, если хранилище создано без WithDurableLog.
+var ErrNoDurableLog = errors.New("store: durable log is not configured")
+
+// SyncWriter - журнал для WithDurableLog: Sync должен сбрасывать записанное на диск,
+// как (*os.File).Sync.
+type SyncWriter interface {
+	io.Writer
+	Sync() error
+}
+
+// WithDurableLog включает журнал упреждающей записи для SetDurable: каждое такое изменение
+// пишется строкой в формате CDCWriter в w, и SetDurable возвращается только после w.Sync.
+// Записи, пришедшие, пока идёт Sync, собираются в следующую пачку и сбрасываются одним
+// Sync (group commit), так что под нагрузкой fsync на запись не приходится.
+//
+// Обычные Set, Delete, истечение и вытеснение в журнал не попадают и остаются со скоростью
+// памяти: журнал - для немногих ключей, потеря которых при падении недопустима. Поэтому
+// ключи журнала перезаписывают через SetDurable и удаляют через DeleteDurable: перезапись
+// обычным Set или удаление Delete журнал не видит, и после рестарта LoadDurableLog вернёт
+// последнее значение SetDurable. После рестарта журнал применяется через LoadDurableLog,
+// обычно поверх последнего снапшота.
+// Журнал только растёт: после SaveSnapshot его можно начать заново.
+func WithDurableLog(w SyncWriter) Option {
+	return func(c *config) {
+		c.durableLog = w
+	}
+}
+
+// durableLog - журнал SetDurable. Изменения добавляются под s.mu, так что порядок строк
+// совпадает с порядком записей, а пишет и сбрасывает их фоновая горутина
+type durableLog struct {
+	w SyncWriter
+
+	mu      sync.Mutex
+	pending []Mutation
+	waiters []*durableWait
+	closed  bool
+
+	notify  chan struct{}
+	stopped chan struct{}
+
+	commits atomic.Uint64 // вызовов Sync
+	records atomic.Uint64 // записанных изменений
+}
+
+// durableWait - ожидание SetDurable: queued - изменение попало в журнал, под s.mu
+type durableWait struct {
+	queued bool
+	done   chan error
+}
+
+func newDurableLog(w SyncWriter) *durableLog {
+	return &durableLog{w: w, notify: make(chan struct{}, 1), stopped: make(chan struct{})}
+}
+
+// appendLocked добавляет изменение в следующую пачку, вызывается под s.mu
+func (l *durableLog) appendLocked(m Mutation, wait *durableWait) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		wait.queued = true
+		wait.done <- ErrClosed
+		return
+	}
+	l.pending = append(l.pending, m)
+	l.waiters = append(l.waiters, wait)
+	l.mu.Unlock()
+	wait.queued = true
+
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run пишет пачки, пока журнал не закроют, и дописывает остаток
+func (l *durableLog) run() {
+	defer close(l.stopped)
+	for range l.notify {
+		l.mu.Lock()
+		batch, waiters, closed := l.pending, l.waiters, l.closed
+		l.pending, l.waiters = nil, nil
+		l.mu.Unlock()
+
+		if len(batch) > 0 {
+			err := l.commit(batch)
+			for _, w := range waiters {
+				w.done <- err
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// commit пишет пачку одним Write и сбрасывает её одним Sync
+func (l *durableLog) commit(batch []Mutation) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, m := range batch {
+		if err := enc.Encode(m); err != nil {
+			return fmt.Errorf("store: durable log encode: %w", err)
+		}
+	}
+	if _, err := l.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("store: durable log write: %w", err)
+	}
+	if err := l.w.Sync(); err != nil {
+		return fmt.Errorf("store: durable log sync: %w", err)
+	}
+	l.commits.Add(1)
+	l.records.Add(uint64(len(batch)))
+	return nil
+}
+
+// close дописывает ожидающие изменения и останавливает горутину
+func (l *durableLog) close() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		<-l.stopped
+		return
+	}
+	l.closed = true
+	l.mu.Unlock()
+	l.notify <- struct{}{}
+	<-l.stopped
+}
+
+// SetDurable - Set, который возвращается только после того, как изменение сброшено на диск
+// журналом WithDurableLog. Значение видно читателям сразу, как у Set, а ошибка записи журнала
+// возвращается вызывающему: тогда значение в памяти есть, но падение процесса его потеряет.
+// Без WithDurableLog возвращает ErrNoDurableLog, ничего не записав.
+func (s *Store) SetDurable(key, value string, ttl time.Duration) error {
+	if s.durable == nil {
+		return ErrNoDurableLog
+	}
+	if err := s.enter(); err != nil {
+		return err
+	}
+	defer s.leave()
+
+	wait := &durableWait{done: make(chan error, 1)}
+	if err := s.set(key, value, ttl, writeOpts{durable: wait}); err != nil {
+		return err
+	}
+	if !wait.queued {
+		return nil // запись не принята фильтром WithAdmission, как у Set
+	}
+	return <-wait.done
+}
+
+// DeleteDurable - Delete, который возвращается только после того, как удаление сброшено
+// на диск журналом WithDurableLog, так что LoadDurableLog после рестарта ключ не вернёт.
+// Ключ удаляется из памяти сразу, ошибки - как у SetDurable.
+func (s *Store) DeleteDurable(key string) error {
+	if s.durable == nil {
+		return ErrNoDurableLog
+	}
+	if err := s.enter(); err != nil {
+		return err
+	}
+	defer s.leave()
+
+	wait := &durableWait{done: make(chan error, 1)}
+	if err := s.remove(key, writeOpts{durable: wait}); err != nil {
+		return err
+	}
+	return <-wait.done
+}
+
+// durableAppendLocked добавляет запись ключа хранения raw в журнал, вызывается под s.mu
+func (s *Store) durableAppendLocked(raw string, it *Item, now time.Time, wait *durableWait) {
+	s.durable.appendLocked(s.mutation(EventSet, raw, it, now), wait)
+}
+
+// LoadDurableLog применяет журнал WithDurableLog из r через ApplyMutation: значения с уже
+// прошедшим сроком не восстанавливаются. Обрезанная при падении последняя строка - ошибка,
+// изменения до неё уже применены.
+func (s *Store) LoadDurableLog(r io.Reader) error {
+	return ReadCDC(r, s.ApplyMutation)
+}
+
+// closeDurable останавливает журнал после завершения записей, см. Close
+func (s *Store) closeDurable() {
+	if s.durable != nil {
+		s.durable.close()
+	}
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer - SyncWriter в памяти, считает вызовы Sync
+type syncBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	syncs int
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.syncs++
+	return nil
+}
+
+func (b *syncBuffer) bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+// durableStore пишет ключи через SetDurable и возвращает содержимое журнала
+func durableStore(t *testing.T, write func(s *Store)) []byte {
+	t.Helper()
+	log := &syncBuffer{}
+	s, err := New(WithDurableLog(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(s)
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if log.syncs == 0 {
+		t.Fatal("durable log was never synced")
+	}
+	return log.bytes()
+}
+
+func TestDurableLogReplay(t *testing.T) {
+	data := durableStore(t, func(s *Store) {
+		for _, kv := range [][2]string{{"a", "1"}, {"b", "2"}, {"a", "3"}} {
+			if err := s.SetDurable(kv[0], kv[1], 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.SetDurable("ttl", "x", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		s.Set("plain", "not logged", 0)
+	})
+
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadDurableLog(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"a": "3", "b": "2", "ttl": "x"} {
+		if got, ok := s.Get(key); !ok || got != want {
+			t.Errorf("Get(%q) = %q, %v, want %q", key, got, ok, want)
+		}
+	}
+	if ttl, ok := s.TTL("ttl"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL(ttl) = %v, %v, want up to 1h", ttl, ok)
+	}
+	if _, ok := s.Get("plain"); ok {
+		t.Error("plain Set was replayed from the durable log")
+	}
+}
+
+func TestDurableLogReplaySkipsExpired(t *testing.T) {
+	data := durableStore(t, func(s *Store) {
+		if err := s.SetDurable("short", "x", time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	})
+	time.Sleep(5 * time.Millisecond)
+
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadDurableLog(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get("short"); ok {
+		t.Error("expired entry was restored")
+	}
+}
+
+func TestDurableLogTruncated(t *testing.T) {
+	data := durableStore(t, func(s *Store) {
+		for _, key := range []string{"first", "second", "last"} {
+			if err := s.SetDurable(key, "v", 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	// падение посреди записи последней строки
+	cut := bytes.LastIndexByte(data[:len(data)-1], '\n') + 1
+	data = data[:cut+(len(data)-cut)/2]
+
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadDurableLog(bytes.NewReader(data)); err == nil {
+		t.Fatal("truncated log loaded without error")
+	}
+	for _, key := range []string{"first", "second"} {
+		if _, ok := s.Get(key); !ok {
+			t.Errorf("%q before the truncated line was not applied", key)
+		}
+	}
+	if _, ok := s.Get("last"); ok {
+		t.Error("truncated entry was applied")
+	}
+}
+
+func TestSetDurableWithoutLog(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDurable("k", "v", 0); !errors.Is(err, ErrNoDurableLog) {
+		t.Fatalf("SetDurable = %v, want ErrNoDurableLog", err)
+	}
+	if _, ok := s.Get("k"); ok {
+		t.Error("SetDurable without a log stored the value")
+	}
+	s.Set("k", "v", 0)
+	if err := s.DeleteDurable("k"); !errors.Is(err, ErrNoDurableLog) {
+		t.Fatalf("DeleteDurable = %v, want ErrNoDurableLog", err)
+	}
+	wantValue(t, s, "k", "v")
+}
+
+func TestDeleteDurableReplay(t *testing.T) {
+	data := durableStore(t, func(s *Store) {
+		for _, key := range []string{"kept", "deleted", "overwritten"} {
+			if err := s.SetDurable(key, "v", 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.DeleteDurable("deleted"); err != nil {
+			t.Fatal(err)
+		}
+		wantMissing(t, s, "deleted")
+		// обычная перезапись журнал не видит, см. WithDurableLog
+		s.Set("overwritten", "plain", 0)
+	})
+
+	s := newTestStore(t)
+	if err := s.LoadDurableLog(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, s, "kept", "v")
+	wantMissing(t, s, "deleted")
+	wantValue(t, s, "overwritten", "v")
+}
+
+func TestDurableLogRejectedInDeterministicMode(t *testing.T) {
+	_, err := New(WithDeterministic(), WithClock(NewManualClock(time.Unix(0, 0))), WithDurableLog(&syncBuffer{}))
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("New = %v, want ErrInvalidConfig", err)
+	}
+}
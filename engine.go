@@ -0,0 +1,77 @@
+package store
+
+import "sync"
+
+// Engine - как хранилище обслуживает чтения по ключу, см. WithEngine.
+type Engine int
+
+const (
+	// EngineMap - мапа под RWMutex, по умолчанию: чтение берёт RLock.
+	EngineMap Engine = iota
+	// EngineSyncMap - рядом с мапой ведётся sync.Map с теми же элементами, и чтения по
+	// ключу (Get, чтения коллекций, TTL, GetViews...) идут в неё без блокировок.
+	EngineSyncMap
+)
+
+func (e Engine) String() string {
+	switch e {
+	case EngineMap:
+		return "map"
+	case EngineSyncMap:
+		return "syncmap"
+	default:
+		return "unknown"
+	}
+}
+
+// WithEngine выбирает движок чтений. EngineSyncMap рассчитан на нагрузку из одних чтений,
+// где даже RLock заметен в профиле из-за общей на все ядра строки кеша: чтения масштабируются
+// по ядрам, но каждая запись обновляет обе мапы и становится дороже, а указатели на элементы
+// хранятся дважды. Публичный API тот же.
+//
+// Запись в sync.Map происходит под той же блокировкой, что и в мапу, поэтому чтение ключа видит
+// либо прежний, либо новый элемент. Операции над многими ключами (Reset, ReplaceAll) читатели
+// по ключам видят постепенно, а не одним моментом. Обходы (Keys, Scan, снапшоты) по-прежнему
+// идут по мапе под RLock.
+func WithEngine(e Engine) Option {
+	return func(c *config) {
+		c.engine = e
+	}
+}
+
+// loadItem - элемент по ключу хранения без учёта истечения, без блокировки с EngineSyncMap
+func (s *Store) loadItem(key string) (*Item, bool) {
+	s.activateKey(key)
+	if s.mirror != nil {
+		v, ok := s.mirror.Load(key)
+		if !ok {
+			return nil, false
+		}
+		return v.(*Item), true
+	}
+	s.mu.RLock()
+	item, ok := s.data[key]
+	s.mu.RUnlock()
+	return item, ok
+}
+
+// mirrorStoreLocked и mirrorDeleteLocked повторяют изменение мапы в sync.Map, под s.mu.Lock
+func (s *Store) mirrorStoreLocked(key string, it *Item) {
+	if s.mirror != nil {
+		s.mirror.Store(key, it)
+	}
+}
+
+func (s *Store) mirrorDeleteLocked(key string) {
+	if s.mirror != nil {
+		s.mirror.Delete(key)
+	}
+}
+
+// newMirror - sync.Map для EngineSyncMap, nil для EngineMap
+func newMirror(e Engine) *sync.Map {
+	if e != EngineSyncMap {
+		return nil
+	}
+	return new(sync.Map)
+}
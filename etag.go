@@ -0,0 +1,70 @@
+package store
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strconv"
+)
+
+// ErrNotModified возвращает GetIfNoneMatch, если ETag ключа совпал с переданным.
+var ErrNotModified = errors.New("store: not modified")
+
+// MetaETag - поле метаданных SetWithMeta, которое задаёт ETag значения вместо версии.
+const MetaETag = "etag"
+
+// newEpoch - метка запуска хранилища: версии начинаются с единицы в каждом хранилище,
+// и без неё ETag или SessionToken после рестарта совпал бы с чужим. С WithDeterministic
+// метка нулевая
+func newEpoch(deterministic bool) uint64 {
+	if deterministic {
+		return 0
+	}
+	return rand.Uint64()
+}
+
+// etag - ETag элемента: поле MetaETag, если значение записали с ним, иначе версия. Counter.Add
+// меняет счетчик на месте, не меняя версию, поэтому в ETag счетчика входит и его значение
+func (s *Store) etag(it *Item) string {
+	if e, ok := it.meta[MetaETag]; ok && e != "" {
+		return e
+	}
+	tag := strconv.FormatUint(s.epoch, 36) + "-" + strconv.FormatUint(it.Version, 36)
+	if it.kind == kindCounter && it.counter != nil {
+		tag += "-" + strconv.FormatInt(it.counter.Load(), 36)
+	}
+	return tag
+}
+
+// ETag возвращает ETag ключа, не считая вызов чтением. ETag меняется с каждой записью
+// ключа: это версия (см. GetWithVersion) с меткой хранилища, так что ETag другого хранилища
+// или того же после рестарта не совпадёт; у счетчика (NewCounter) - ещё и с его значением. Значение, записанное через SetWithMeta с полем
+// MetaETag, возвращает его, например ETag источника данных. ETag без кавычек HTTP.
+// false - ключа нет или он истёк.
+func (s *Store) ETag(key string) (string, bool) {
+	item, ok := s.loadItem(s.skey(key))
+	if !ok || item.expiredAt(s.now()) {
+		return "", false
+	}
+	return s.etag(item), true
+}
+
+// GetIfNoneMatch - условное чтение для If-None-Match: если ETag ключа равен etag, возвращает
+// ErrNotModified без значения, иначе значение как Get. В обоих случаях возвращается текущий
+// ETag, а вызов считается чтением. ErrNotFound - ключа нет или он истёк.
+//
+// При совпадении значение не собирается, так что ответ 304 не распаковывает сжатые
+// значения и не строит JSON коллекций.
+func (s *Store) GetIfNoneMatch(key, etag string) (value, current string, err error) {
+	if t, ok := s.startOp(opGet, key); ok {
+		defer t.stop()
+	}
+	item, ok := s.getItem(s.skey(key))
+	if !ok {
+		return "", "", ErrNotFound
+	}
+	current = s.etag(item)
+	if etag != "" && etag == current {
+		return "", current, ErrNotModified
+	}
+	return item.text(), current, nil
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func wantETag(t *testing.T, s *Store, key string) string {
+	t.Helper()
+	tag, ok := s.ETag(key)
+	if !ok || tag == "" {
+		t.Fatalf("ETag(%q) = %q, %v", key, tag, ok)
+	}
+	return tag
+}
+
+func TestETagChangesWithWrites(t *testing.T) {
+	s := newTestStore(t)
+	s.Set("k", "v1", 0)
+	first := wantETag(t, s, "k")
+	if got := wantETag(t, s, "k"); got != first {
+		t.Errorf("ETag changed without a write: %q, then %q", first, got)
+	}
+	s.Set("k", "v1", 0)
+	if got := wantETag(t, s, "k"); got == first {
+		t.Error("ETag did not change after Set")
+	}
+	if _, ok := s.ETag("missing"); ok {
+		t.Error("ETag of a missing key")
+	}
+
+	other := newTestStore(t)
+	other.Set("k", "v1", 0)
+	if wantETag(t, other, "k") == first {
+		t.Error("ETag of another store matches")
+	}
+}
+
+func TestETagOfCounterFollowsAdd(t *testing.T) {
+	s := newTestStore(t)
+	c := s.NewCounter("views")
+	if _, err := c.Add(1); err != nil {
+		t.Fatal(err)
+	}
+	before := wantETag(t, s, "views")
+	if _, err := c.Add(1); err != nil {
+		t.Fatal(err)
+	}
+	after := wantETag(t, s, "views")
+	if after == before {
+		t.Fatal("ETag did not change after Counter.Add")
+	}
+	if _, _, err := s.GetIfNoneMatch("views", before); err != nil {
+		t.Errorf("GetIfNoneMatch with the ETag before Add = %v, want the new value", err)
+	}
+	if _, _, err := s.GetIfNoneMatch("views", after); !errors.Is(err, ErrNotModified) {
+		t.Errorf("GetIfNoneMatch with the current ETag = %v, want ErrNotModified", err)
+	}
+}
+
+func TestGetIfNoneMatch(t *testing.T) {
+	s := newTestStore(t)
+	if _, _, err := s.GetIfNoneMatch("k", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetIfNoneMatch of a missing key = %v, want ErrNotFound", err)
+	}
+	s.Set("k", "v", 0)
+	value, tag, err := s.GetIfNoneMatch("k", "")
+	if err != nil || value != "v" || tag != wantETag(t, s, "k") {
+		t.Fatalf("GetIfNoneMatch = %q, %q, %v", value, tag, err)
+	}
+	if value, _, err := s.GetIfNoneMatch("k", tag); !errors.Is(err, ErrNotModified) || value != "" {
+		t.Errorf("GetIfNoneMatch with the current ETag = %q, %v, want ErrNotModified", value, err)
+	}
+
+	if err := s.SetWithMeta("meta", "v", 0, map[string]string{MetaETag: "source-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if tag := wantETag(t, s, "meta"); tag != "source-1" {
+		t.Errorf("ETag with MetaETag = %q, want source-1", tag)
+	}
+}
@@ -0,0 +1,111 @@
+package store
+
+import "time"
+
+// EvictionPolicy - алгоритм выбора жертвы вытеснения WithMaxMemory, см. WithEvictionPolicy.
+type EvictionPolicy uint8
+
+const (
+	// EvictSampled - самый давно записанный ключ из небольшой случайной выборки, как
+	// приближенный LRU в Redis (по умолчанию). С WithAdmission учитывает частоту ключей.
+	EvictSampled EvictionPolicy = iota
+	// EvictClock - CLOCK (второй шанс): ключи стоят в кольце, чтение только ставит ключу
+	// флаг, а стрелка при вытеснении снимает флаги и вытесняет первый ключ без флага.
+	// Недавно прочитанные ключи так переживают проход стрелки, а Get не трогает ни
+	// списков, ни блокировки на запись. Частоту WithAdmission при выборе не учитывает.
+	EvictClock
+)
+
+// WithEvictionPolicy задаёт, как WithMaxMemory выбирает, что вытеснить. В обоих режимах
+// сначала вытесняются истекшие ключи, среди остальных - ключи самого низкого приоритета,
+// см. SetWithPriority. Лимиты пространств имён вытесняют по выборке в любом режиме.
+func WithEvictionPolicy(p EvictionPolicy) Option {
+	return func(c *config) {
+		c.eviction = p
+	}
+}
+
+// clockRing - кольцо ключей хранения для EvictClock, под s.mu
+type clockRing struct {
+	keys []string
+	pos  map[string]int // индекс ключа в keys
+	hand int
+}
+
+func newClockRing() *clockRing {
+	return &clockRing{pos: make(map[string]int)}
+}
+
+// add добавляет новый ключ в конец кольца
+func (r *clockRing) add(key string) {
+	if _, ok := r.pos[key]; ok {
+		return
+	}
+	r.pos[key] = len(r.keys)
+	r.keys = append(r.keys, key)
+}
+
+// remove убирает ключ, ставя на его место последний: порядок кольца немного меняется,
+// зато без сдвига среза
+func (r *clockRing) remove(key string) {
+	i, ok := r.pos[key]
+	if !ok {
+		return
+	}
+	last := len(r.keys) - 1
+	if i != last {
+		r.keys[i] = r.keys[last]
+		r.pos[r.keys[i]] = i
+	}
+	r.keys[last] = ""
+	r.keys = r.keys[:last]
+	delete(r.pos, key)
+	if r.hand >= len(r.keys) {
+		r.hand = 0
+	}
+}
+
+func (r *clockRing) reset() {
+	clear(r.pos)
+	r.keys, r.hand = r.keys[:0], 0
+}
+
+// markRead ставит элементу флаг чтения для EvictClock. Запись только при смене флага, что-бы
+// частые чтения горячего ключа не гоняли строку кеша между ядрами
+func (it *Item) markRead() {
+	if !it.referenced.Load() {
+		it.referenced.Store(true)
+	}
+}
+
+// clockVictimLocked ведёт стрелку кольца до жертвы: истекшего ключа или ключа приоритета p
+// без флага чтения, флаги по пути снимаются. За два круга жертва находится, если она есть
+func (s *Store) clockVictimLocked(now time.Time, protect string, p Priority) (string, bool) {
+	r := s.ring
+	for range 2*len(r.keys) + 1 {
+		if len(r.keys) == 0 {
+			return "", false
+		}
+		if r.hand >= len(r.keys) {
+			r.hand = 0
+		}
+		key := r.keys[r.hand]
+		r.hand++
+		if key == protect || s.pinnedLocked(key) {
+			continue
+		}
+		item := s.data[key]
+		if item.expiredAt(now) {
+			return key, true
+		}
+		if item.priority != p {
+			continue
+		}
+		if item.referenced.Load() {
+			item.referenced.Store(false)
+			continue
+		}
+		return key, true
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+package store
+
+import "context"
+
+// LazyExpiration - что делает чтение, которое нашло истекший ключ, см. WithLazyExpiration.
+type LazyExpiration int
+
+const (
+	// LazyDelete - промах, ключ удаляется сразу (по умолчанию).
+	LazyDelete LazyExpiration = iota
+	// LazyRetain - промах, но ключ остаётся до прохода Cleanup: его можно разглядеть
+	// через FullList, Expiring и снапшот, разбирая, почему ключ пропал.
+	LazyRetain
+	// LazyStale - Get отдаёт истекшее значение как попадание, пока Cleanup его не удалит.
+	// Подходит, когда устаревшее значение лучше промаха, а Cleanup задаёт, насколько.
+	LazyStale
+)
+
+// WithLazyExpiration задаёт поведение Get (и GetWithVersion, GetWithMeta, ...) для истекшего,
+// но ещё не удалённого ключа. Остальные чтения (коллекции, TTL, Exists) истекшие ключи
+// не удаляют и не отдают в любом режиме.
+func WithLazyExpiration(mode LazyExpiration) Option {
+	return func(c *config) {
+		c.lazyExpiration = mode
+	}
+}
+
+// WithExpireCallback вызывает fn для каждого ключа, удалённого по истечению: в Get
+// (режим LazyDelete) и в Cleanup. fn получает ключ и последнее значение и вызывается
+// после снятия блокировки в горутине, которая удалила ключ, поэтому может обращаться
+// к хранилищу, но должна быть быстрой. В отличие от Watch не теряет события при
+// медленном обработчике. Пространства имён наследуют fn, если не задали свой
+// WithNamespaceExpireCallback.
+func WithExpireCallback(fn func(key, value string)) Option {
+	return func(c *config) {
+		c.onExpire = fn
+	}
+}
+
+// WithExpireCallbackContext - WithExpireCallback, fn которого получает контекст с атрибутами
+// запроса, записавшего значение (AttrsFrom), что-бы связать истечение с тем, кто положил
+// ключ. Вызывается для всех ключей, в том числе пространств имён, вместе с колбэками
+// WithExpireCallback и WithNamespaceExpireCallback.
+func WithExpireCallbackContext(fn func(ctx context.Context, key, value string)) Option {
+	return func(c *config) {
+		c.onExpireCtx = fn
+	}
+}
+
+// expiredItem - удалённый по истечению элемент для WithExpireCallback
+type expiredItem struct {
+	raw    string
+	item   *Item
+	notify func(key, value string) // WithExpireCallback или колбэк пространства имён, может быть nil
+}
+
+// expireLocked удаляет истекший ключ и добавляет его в gone, если для него задан
+// WithExpireCallback или WithNamespaceExpireCallback. Вызывается под s.mu.Lock,
+// gone передаётся в expired после снятия блокировки
+func (s *Store) expireLocked(raw string, item *Item, gone []expiredItem) []expiredItem {
+	notify := s.cfg.onExpire
+	if ns := s.nsLocked(raw); ns != nil {
+		notify = ns.Settings().OnExpire
+	}
+	s.removeLocked(raw, EventExpire)
+	s.stats.expired.Add(1)
+	if notify != nil || s.cfg.onExpireCtx != nil {
+		gone = append(gone, expiredItem{raw: raw, item: item, notify: notify})
+	}
+	return gone
+}
+
+// expired вызывает колбэки истечения для удалённых ключей, без блокировки
+func (s *Store) expired(gone []expiredItem) {
+	for _, e := range gone {
+		key, ok := s.userKey(e.raw)
+		if !ok {
+			continue
+		}
+		start := s.callbackStart()
+		if e.notify != nil {
+			e.notify(key, e.item.text())
+		}
+		if s.cfg.onExpireCtx != nil {
+			s.cfg.onExpireCtx(attrsContext(e.item.attrs), key, e.item.text())
+		}
+		s.callbackDone(start)
+	}
+}
@@ -0,0 +1,300 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrFull возвращает запись, которой не хватило места под WithMaxMemory с FullReject или
+// FullBlock.
+var ErrFull = errors.New("store: store is full")
+
+// FullPolicy - что делать с записью, которой не хватает места под WithMaxMemory, см. WithFullPolicy.
+type FullPolicy int
+
+const (
+	// FullEvict - запись вытесняет другие ключи, как без WithFullPolicy.
+	FullEvict FullPolicy = iota
+	// FullReject - запись возвращает ErrFull, живые ключи не вытесняются.
+	FullReject
+	// FullBlock - запись ждёт, пока место освободится (Delete, истечение, вытеснение
+	// лимитами пространства), но не дольше таймаута, и тогда возвращает ErrFull.
+	FullBlock
+)
+
+func (p FullPolicy) String() string {
+	switch p {
+	case FullEvict:
+		return "evict"
+	case FullReject:
+		return "reject"
+	case FullBlock:
+		return "block"
+	default:
+		return "unknown"
+	}
+}
+
+// WithFullPolicy задаёт поведение при заполненном WithMaxMemory. Кешу, который можно
+// пересчитать, подходит FullEvict; хранилищу сессий или очереди, где потеря ключа хуже
+// отказа, - FullReject; производителю, который может подождать потребителя, - FullBlock
+// с timeout (для остальных политик timeout не используется).
+//
+// Истекшие ключи освобождают место при любой политике. Отказ и ожидание действуют на все
+// записи, которые добавляют данные: Set и его варианты, SetNX, SetXX, IncrBy, записи коллекций
+// (HSet, LPush, SAdd, ZAdd, Counter, ...), а Txn и Pipeline.Commit - на всю пачку по её
+// суммарному объёму: при отказе не применяется ни одна запись. Загрузка снапшота с FullReject
+// и FullBlock живые ключи не вытесняет, но и не отклоняется, так что объём может превысить
+// лимит. Лимиты пространств имён (WithNamespaceMaxMemory) вытесняют при любой политике.
+func WithFullPolicy(p FullPolicy, timeout time.Duration) Option {
+	return func(c *config) {
+		c.fullPolicy = p
+		c.fullTimeout = timeout
+	}
+}
+
+// FullEvent - запись, которой не хватило места, см. WithOnFull.
+type FullEvent struct {
+	Key    string     // ключ записи
+	Policy FullPolicy // что с ней сделали
+	Used   int64      // объём хранилища в момент события
+	Limit  int64      // WithMaxMemory
+	Err    error      // nil - запись прошла (FullEvict или дождалась места), иначе ее ошибка
+}
+
+// WithOnFull вызывает fn на каждую запись, которой не хватило места под WithMaxMemory
+// без вытеснения живых ключей, например для алерта о том, что лимит мал; для FullBlock -
+// один раз, когда ожидание закончилось. fn вызывается синхронно, после того как блокировки
+// хранилища отпущены; под постоянной нагрузкой на заполненное хранилище это каждая новая
+// запись, так что прореживать алерты - дело fn.
+func WithOnFull(fn func(FullEvent)) Option {
+	return func(c *config) {
+		c.onFull = fn
+	}
+}
+
+// roomLocked проверяет, что it помещается под ключом key в WithMaxMemory, удаляя для этого
+// истекшие ключи. Живые ключи не трогает. Вызывается под s.mu.Lock
+func (s *Store) roomLocked(key string, it *Item, now time.Time) bool {
+	if s.cfg.maxMemory == 0 {
+		return true
+	}
+	return s.freeLocked(now, key, func() int64 {
+		need := itemSize(key, it)
+		if old, ok := s.data[key]; ok {
+			need -= old.size
+		}
+		return need
+	})
+}
+
+// freeLocked удаляет истекшие ключи, кроме protect, пока прирост объёма need() не поместится
+// в WithMaxMemory. need пересчитывается после каждого удаления. Вызывается под s.mu.Lock
+func (s *Store) freeLocked(now time.Time, protect string, need func() int64) bool {
+	for s.memUsed+need() > s.cfg.maxMemory {
+		victim, ok := s.expiredVictimLocked(now, protect)
+		if !ok {
+			return false
+		}
+		s.removeLocked(victim, EventEvict)
+		s.stats.evictions.Add(1)
+	}
+	return true
+}
+
+// expiredVictimLocked ищет истекший ключ в случайной выборке, с WithDeterministic - наименьший
+// истекший ключ среди всех
+func (s *Store) expiredVictimLocked(now time.Time, protect string) (string, bool) {
+	victim, found, seen := "", false, 0
+	for key, item := range s.data {
+		if key != protect && item.expiredAt(now) {
+			if !s.cfg.deterministic {
+				return key, true
+			}
+			if !found || key < victim {
+				victim, found = key, true
+			}
+		}
+		if seen++; !s.cfg.deterministic && seen >= 4*evictionSample {
+			break
+		}
+	}
+	return victim, found
+}
+
+// fullLocked применяет WithFullPolicy к записи key, которой не хватает места. Для FullEvict
+// возвращает событие WithOnFull (nil - место есть), для остальных политик - ErrFull
+func (s *Store) fullLocked(key string, it *Item, now time.Time) (*FullEvent, error) {
+	if s.cfg.maxMemory == 0 || s.cfg.fullPolicy == FullEvict && s.cfg.onFull == nil {
+		return nil, nil
+	}
+	if s.roomLocked(key, it, now) {
+		return nil, nil
+	}
+	if s.cfg.fullPolicy != FullEvict {
+		return nil, ErrFull
+	}
+	return &FullEvent{Key: key, Policy: FullEvict, Used: s.memUsed, Limit: s.cfg.maxMemory}, nil
+}
+
+// signalRoomLocked будит записи FullBlock после того, как объём уменьшился. Вызывается под s.mu.Lock
+func (s *Store) signalRoomLocked() {
+	if s.cfg.fullPolicy != FullBlock {
+		return
+	}
+	s.roomGen.Add(1)
+	if s.roomFreed != nil {
+		close(s.roomFreed)
+		s.roomFreed = nil
+	}
+}
+
+// withRoom выполняет попытку записи attempt с учётом FullBlock: повторяет её, пока ей не
+// хватает места, между попытками ждёт освобождения места без блокировок и места
+// WithWriteConcurrency, что-бы удаления не стояли за ждущими записями. Поэтому attempt
+// сам входит в хранилище и берёт блокировки, а TTL каждой попытки отсчитывается заново.
+// С другими политиками attempt выполняется один раз. ctx не nil - ждать не дольше ctx, см. TrySet
+func (s *Store) withRoom(key string, ctx context.Context, attempt func() error) error {
+	if s.cfg.fullPolicy != FullBlock || s.cfg.maxMemory == 0 {
+		return attempt()
+	}
+	var timeout Ticker
+	defer func() {
+		if timeout != nil {
+			timeout.Stop()
+		}
+	}()
+	var busy <-chan struct{}
+	if ctx != nil {
+		busy = ctx.Done()
+	}
+	for {
+		gen := s.roomGen.Load()
+		err := attempt()
+		if !errors.Is(err, ErrFull) {
+			if timeout != nil {
+				s.full(key, FullBlock, err)
+			}
+			return err
+		}
+		if timeout == nil {
+			timeout = s.cfg.clock.NewTicker(s.cfg.fullTimeout)
+		}
+
+		s.mu.Lock()
+		if s.roomGen.Load() != gen {
+			s.mu.Unlock()
+			continue // место освободилось, пока мы пробовали
+		}
+		if s.roomFreed == nil {
+			s.roomFreed = make(chan struct{})
+		}
+		freed := s.roomFreed
+		s.mu.Unlock()
+
+		select {
+		case <-freed:
+			continue
+		case <-timeout.C():
+			err = ErrFull
+		case <-s.life.done:
+			err = ErrClosed
+		case <-busy:
+			err = ErrBusy
+		}
+		s.full(key, FullBlock, err)
+		return err
+	}
+}
+
+// placeLocked - проверки места для записи it под ключом key, общие для всех путей записи:
+// фильтр допуска (WithAdmission) и WithFullPolicy. admitted == false без ошибки - запись
+// отклонил фильтр допуска; full - событие WithOnFull для FullEvict, его передают в placed.
+// Вызывается под s.mu.Lock
+func (s *Store) placeLocked(key string, it *Item, now time.Time) (admitted bool, full *FullEvent, err error) {
+	if !s.admitLocked(key, it, now) {
+		return false, nil, nil
+	}
+	full, err = s.fullLocked(key, it, now)
+	return err == nil, full, err
+}
+
+// batchWrite - запись пачки, которую фиксируют целиком (Txn, Pipeline); item == nil - удаление
+type batchWrite struct {
+	raw  string
+	item *Item
+}
+
+// placeBatchLocked - placeLocked для пачки writes: фильтр допуска решает по каждой записи
+// (admitted[i] == false - запись пропускается, как отклонённый Set), WithFullPolicy - по
+// суммарному приросту объёма, так что при нехватке места ErrFull получает вся пачка и ничего
+// из неё не применяется. Вызывается под s.mu.Lock
+func (s *Store) placeBatchLocked(writes []batchWrite, now time.Time) (admitted []bool, full *FullEvent, err error) {
+	admitted = make([]bool, len(writes))
+	for i, w := range writes {
+		admitted[i] = w.item == nil || s.admitLocked(w.raw, w.item, now)
+	}
+	if s.cfg.maxMemory == 0 || s.cfg.fullPolicy == FullEvict && s.cfg.onFull == nil {
+		return admitted, nil, nil
+	}
+	need := func() int64 {
+		var n int64
+		final := make(map[string]bool, len(writes))
+		for i := len(writes) - 1; i >= 0; i-- {
+			w := writes[i]
+			if !admitted[i] || final[w.raw] {
+				continue // для повторов ключа важна последняя запись
+			}
+			final[w.raw] = true
+			if old, ok := s.data[w.raw]; ok {
+				n -= old.size
+			}
+			if w.item != nil {
+				n += itemSize(w.raw, w.item)
+			}
+		}
+		return n
+	}
+	if s.freeLocked(now, "", need) {
+		return admitted, nil, nil
+	}
+	if s.cfg.fullPolicy != FullEvict {
+		return admitted, nil, ErrFull
+	}
+	return admitted, &FullEvent{Policy: FullEvict, Used: s.memUsed, Limit: s.cfg.maxMemory}, nil
+}
+
+// placed завершает placeLocked после снятия блокировок: учитывает отказ FullReject
+// и вызывает WithOnFull. Отказ FullBlock учитывает withRoom, когда ожидание закончилось
+func (s *Store) placed(userKey string, full *FullEvent, err error) {
+	if err != nil {
+		if s.cfg.fullPolicy == FullReject {
+			s.full(userKey, FullReject, err)
+		}
+		return
+	}
+	if full != nil {
+		full.Key = userKey
+		start := s.callbackStart()
+		s.cfg.onFull(*full)
+		s.callbackDone(start)
+	}
+}
+
+// full учитывает запись, которой не хватило места, и вызывает WithOnFull без блокировок хранилища
+func (s *Store) full(key string, p FullPolicy, err error) {
+	if err != nil {
+		s.stats.fullRejected.Add(1)
+		s.cfg.logger.Debug("store: write rejected, store is full", "key", key, "policy", p.String(), "err", err)
+	}
+	if s.cfg.onFull == nil {
+		return
+	}
+	s.mu.RLock()
+	used := s.memUsed
+	s.mu.RUnlock()
+	start := s.callbackStart()
+	s.cfg.onFull(FullEvent{Key: key, Policy: p, Used: used, Limit: s.cfg.maxMemory, Err: err})
+	s.callbackDone(start)
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMaxMemory = 1000
+
+// fullStore - хранилище с WithMaxMemory(testMaxMemory) и политикой p, заполненное ключами
+// fill-<n>, пока следующий Set не получит ErrFull
+func fullStore(t *testing.T, p FullPolicy, timeout time.Duration) *Store {
+	t.Helper()
+	s, err := New(WithMaxMemory(testMaxMemory), WithFullPolicy(p, timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := strings.Repeat("x", 100)
+	for i := 0; ; i++ {
+		err := s.Set("fill-"+strings.Repeat("0", i), value, 0)
+		if errors.Is(err, ErrFull) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > testMaxMemory {
+			t.Fatal("store never became full")
+		}
+	}
+	wantWithinLimit(t, s)
+	return s
+}
+
+func wantWithinLimit(t *testing.T, s *Store) {
+	t.Helper()
+	if used := s.MemoryStats().TotalBytes; used > testMaxMemory {
+		t.Errorf("memory = %d B, over the %d B limit", used, testMaxMemory)
+	}
+}
+
+func TestFullRejectKeepsLiveKeys(t *testing.T) {
+	s := fullStore(t, FullReject, 0)
+	wantValue(t, s, "fill-", strings.Repeat("x", 100))
+	if err := s.Set("new", strings.Repeat("y", 100), 0); !errors.Is(err, ErrFull) {
+		t.Fatalf("Set = %v, want ErrFull", err)
+	}
+	wantMissing(t, s, "new")
+	if s.Stats().FullRejected == 0 {
+		t.Error("rejected write was not counted")
+	}
+	// перезапись тем же объёмом места не требует
+	if err := s.Set("fill-", strings.Repeat("z", 100), 0); err != nil {
+		t.Errorf("overwrite of the same size = %v", err)
+	}
+}
+
+func TestFullRejectFreesExpiredKeys(t *testing.T) {
+	s, err := New(WithMaxMemory(testMaxMemory), WithFullPolicy(FullReject, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("short", strings.Repeat("x", 600), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := s.Set("long", strings.Repeat("y", 600), 0); err != nil {
+		t.Fatalf("Set over an expired key = %v", err)
+	}
+	wantWithinLimit(t, s)
+}
+
+func TestFullRejectTxnIsAtomic(t *testing.T) {
+	s := fullStore(t, FullReject, 0)
+	err := s.Txn(func(tx *Txn) error {
+		tx.Delete("fill-")
+		tx.Set("small", "v", 0)
+		for _, key := range []string{"a", "b", "c"} {
+			tx.Set(key, strings.Repeat("y", 100), 0)
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrFull) {
+		t.Fatalf("Txn = %v, want ErrFull", err)
+	}
+	wantValue(t, s, "fill-", strings.Repeat("x", 100))
+	for _, key := range []string{"small", "a", "b", "c"} {
+		wantMissing(t, s, key)
+	}
+	wantWithinLimit(t, s)
+
+	// удаление в той же транзакции освобождает место под запись
+	err = s.Txn(func(tx *Txn) error {
+		tx.Delete("fill-")
+		return tx.Set("a", strings.Repeat("y", 100), 0)
+	})
+	if err != nil {
+		t.Fatalf("Txn that frees its own room = %v", err)
+	}
+	wantValue(t, s, "a", strings.Repeat("y", 100))
+	wantWithinLimit(t, s)
+}
+
+func TestFullRejectPipelineIsAtomic(t *testing.T) {
+	s := fullStore(t, FullReject, 0)
+	p := s.Pipeline()
+	p.Delete("fill-").Set("small", "v", 0)
+	for _, key := range []string{"a", "b", "c"} {
+		p.Set(key, strings.Repeat("y", 100), 0)
+	}
+	if n, err := p.Commit(); !errors.Is(err, ErrFull) || n != 0 {
+		t.Fatalf("Commit = %d, %v, want 0, ErrFull", n, err)
+	}
+	wantValue(t, s, "fill-", strings.Repeat("x", 100))
+	for _, key := range []string{"small", "a", "b", "c"} {
+		wantMissing(t, s, key)
+	}
+	wantWithinLimit(t, s)
+}
+
+func TestFullBlockTxnWaitsForRoom(t *testing.T) {
+	s := fullStore(t, FullBlock, time.Second)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.Delete("fill-")
+	}()
+	err := s.Txn(func(tx *Txn) error {
+		return tx.Set("a", strings.Repeat("y", 100), 0)
+	})
+	if err != nil {
+		t.Fatalf("Txn = %v after room was freed", err)
+	}
+	wantValue(t, s, "a", strings.Repeat("y", 100))
+	wantWithinLimit(t, s)
+
+	s2 := fullStore(t, FullBlock, 20*time.Millisecond)
+	start := time.Now()
+	err = s2.Txn(func(tx *Txn) error {
+		return tx.Set("b", strings.Repeat("y", 100), 0)
+	})
+	if !errors.Is(err, ErrFull) {
+		t.Fatalf("Txn = %v, want ErrFull after the timeout", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("FullBlock returned before the timeout")
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"sort"
+	"strings"
+)
+
+// Keys возвращает отсортированный список неистекших ключей, подходящих под glob-шаблон
+// в стиле Redis KEYS: * - любая последовательность, ? - один символ, [abc], [a-z] и [^a] -
+// классы символов, \ экранирует следующий символ. Шаблон "*" возвращает все ключи.
+func (s *Store) Keys(pattern string) []string {
+	now := s.now()
+	keys := make([]string, 0)
+
+	s.mu.RLock()
+	for raw, item := range s.data {
+		if item.expiredAt(now) {
+			continue
+		}
+		key, ok := s.userKey(raw)
+		if !ok || !matchGlob(pattern, key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(keys)
+	return keys
+}
+
+// MatchGlob сообщает, подходит ли str под glob-шаблон в синтаксисе Keys, например для
+// каналов PSUBSCRIBE в respserver.
+func MatchGlob(pattern, str string) bool {
+	return matchGlob(pattern, str)
+}
+
+// matchGlob сопоставляет строку с glob-шаблоном, без рекурсии: при несовпадении
+// откатываемся к последней звездочке
+func matchGlob(pattern, str string) bool {
+	p, s := 0, 0
+	starP, starS := -1, 0
+
+	for s < len(str) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starS = p, s
+				p++
+				continue
+			case '?':
+				p++
+				s++
+				continue
+			case '[':
+				if next, ok := matchClass(pattern, p, str[s]); ok {
+					p = next
+					s++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == str[s] {
+					p += 2
+					s++
+					continue
+				}
+			default:
+				if pattern[p] == str[s] {
+					p++
+					s++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		starS++
+		p, s = starP+1, starS
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass проверяет символ c по классу, начинающемуся в pattern[start] == '['.
+// Возвращает позицию после класса и результат проверки
+func matchClass(pattern string, start int, c byte) (int, bool) {
+	i := start + 1
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+
+	matched := false
+	for i < len(pattern) && pattern[i] != ']' {
+		lo := pattern[i]
+		if lo == '\\' && i+1 < len(pattern) {
+			i++
+			lo = pattern[i]
+		}
+		hi := lo
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			hi = pattern[i+2]
+			i += 2
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if c >= lo && c <= hi {
+			matched = true
+		}
+		i++
+	}
+	if i >= len(pattern) {
+		// незакрытый класс считаем обычным символом '['
+		return start + 1, c == '['
+	}
+	return i + 1, matched != negate
+}
+
+// escapeGlob экранирует спецсимволы шаблона, что-бы строка совпадала только сама с собой
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,402 @@
+// Package gossip - взаимная инвалидация кешей между процессами, которые встраивают хранилище,
+// без центрального брокера.
+//
+// Каждый процесс держит Node - приёмник изменений store.WithSink. Запись или удаление ключа
+// в одном процессе рассылается соседям UDP-датаграммой, и соседи удаляют у себя этот ключ
+// (store.Store.Invalidate); следующее чтение у них промахнётся и загрузит свежее значение.
+// Самих значений протокол не передаёт, так что кеши согласованы лишь примерно: датаграмма
+// может потеряться, а между записью и инвалидацией соседи успевают отдать старое значение.
+// Для данных, где это недопустимо, подходит пакет replication.
+//
+//	n := gossip.NewNode(gossip.WithPeers("10.0.0.2:7946", "10.0.0.3:7946"), gossip.WithSecret(key))
+//	s := store.NewStore(store.WithSink(n, store.SinkOptions{DropWhenFull: true}))
+//	conn, _ := net.ListenPacket("udp", ":7946")
+//	go n.Serve(s, conn)
+//
+// По умолчанию узел рассылает инвалидацию всем соседям сам. С WithFanout он отправляет её
+// нескольким случайным соседям, а те пересылают дальше, пока не кончится WithHops, - так
+// нагрузка на узел не растёт с размером кластера. Повторы отбрасываются по (узел, номер).
+//
+// Список соседей задаётся явно (WithPeers, AddPeer): членства и обнаружения узлов нет.
+// Без WithSecret датаграммы не подписываются и любой, кто может слать UDP на порт узла,
+// может сбрасывать его кеш.
+package gossip
+
+import (
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// ErrNodeClosed возвращается из Serve после Close.
+var ErrNodeClosed = errors.New("gossip: node closed")
+
+const (
+	defaultHops        = 3
+	defaultMaxDatagram = 1200 // влезает в MTU без фрагментации
+	seenCapacity       = 4096
+	macSize            = sha256.Size
+)
+
+// Option настраивает Node.
+type Option func(*options)
+
+type options struct {
+	peers       []string
+	fanout      int
+	hops        int
+	secret      []byte
+	maxDatagram int
+}
+
+// WithPeers задаёт начальный список соседей, адреса host:port.
+func WithPeers(addrs ...string) Option {
+	return func(o *options) {
+		o.peers = append(o.peers, addrs...)
+	}
+}
+
+// WithFanout включает распространение по цепочке: инвалидация уходит n случайным соседям,
+// и каждый получатель пересылает её ещё n. По умолчанию 0 - отправка всем соседям напрямую.
+func WithFanout(n int) Option {
+	return func(o *options) {
+		o.fanout = n
+	}
+}
+
+// WithHops задаёт, сколько раз инвалидацию можно переслать с WithFanout, по умолчанию 3.
+func WithHops(n int) Option {
+	return func(o *options) {
+		o.hops = n
+	}
+}
+
+// WithSecret включает подпись датаграмм HMAC-SHA256 общим ключом: датаграммы без
+// верной подписи отбрасываются. Ключ должен совпадать у всех узлов.
+func WithSecret(key []byte) Option {
+	return func(o *options) {
+		o.secret = key
+	}
+}
+
+// WithMaxDatagram задаёт максимальный размер датаграммы в байтах, по умолчанию 1200.
+// Пачка ключей, которая не помещается, делится на несколько датаграмм.
+func WithMaxDatagram(n int) Option {
+	return func(o *options) {
+		o.maxDatagram = n
+	}
+}
+
+// message - датаграмма инвалидации
+type message struct {
+	From uint64   `json:"from"`
+	Seq  uint64   `json:"seq"`
+	Hops int      `json:"hops,omitempty"` // сколько раз ещё можно переслать
+	Keys []string `json:"keys"`
+}
+
+type msgID struct {
+	from, seq uint64
+}
+
+// Stats - счётчики узла.
+type Stats struct {
+	Sent        uint64 // датаграмм отправлено, включая пересланные
+	SendErrors  uint64 // датаграмм не удалось отправить
+	Received    uint64 // датаграмм принято и применено
+	Rejected    uint64 // датаграмм отброшено: не разобрались или неверная подпись
+	Duplicates  uint64 // датаграмм отброшено как повтор
+	Invalidated uint64 // ключей удалено по датаграммам соседей
+}
+
+// Node - участник взаимной инвалидации. Node - store.Sink.
+type Node struct {
+	opts options
+	id   uint64
+	seq  atomic.Uint64
+
+	mu     sync.Mutex
+	conn   net.PacketConn
+	peers  map[string]*net.UDPAddr
+	closed bool
+
+	seenMu   sync.Mutex
+	seen     map[msgID]struct{}
+	seenRing []msgID // порядок добавления в seen, старые вытесняются
+	seenNext int
+
+	sent, sendErrors, received, rejected, duplicates, invalidated atomic.Uint64
+}
+
+var _ store.Sink = (*Node)(nil)
+
+// NewNode создаёт узел. Его нужно передать в store.WithSink хранилища, а затем запустить
+// Serve с этим хранилищем. Если хранилищу нужен и другой приёмник, их объединяют
+// через store.SinkFunc. Соседи с нераспознанным адресом пропускаются, см. AddPeer.
+func NewNode(opts ...Option) *Node {
+	o := options{hops: defaultHops, maxDatagram: defaultMaxDatagram}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var id [8]byte
+	crand.Read(id[:])
+
+	n := &Node{
+		opts:     o,
+		id:       binary.LittleEndian.Uint64(id[:]),
+		peers:    make(map[string]*net.UDPAddr, len(o.peers)),
+		seen:     make(map[msgID]struct{}, seenCapacity),
+		seenRing: make([]msgID, seenCapacity),
+	}
+	for _, addr := range o.peers {
+		n.AddPeer(addr)
+	}
+	return n
+}
+
+// AddPeer добавляет соседа по адресу host:port.
+func (n *Node) AddPeer(addr string) error {
+	udp, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.peers[addr] = udp
+	n.mu.Unlock()
+	return nil
+}
+
+// RemovePeer убирает соседа.
+func (n *Node) RemovePeer(addr string) {
+	n.mu.Lock()
+	delete(n.peers, addr)
+	n.mu.Unlock()
+}
+
+// Peers возвращает отсортированные адреса соседей.
+func (n *Node) Peers() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	addrs := make([]string, 0, len(n.peers))
+	for addr := range n.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Stats возвращает счётчики узла.
+func (n *Node) Stats() Stats {
+	return Stats{
+		Sent:        n.sent.Load(),
+		SendErrors:  n.sendErrors.Load(),
+		Received:    n.received.Load(),
+		Rejected:    n.rejected.Load(),
+		Duplicates:  n.duplicates.Load(),
+		Invalidated: n.invalidated.Load(),
+	}
+}
+
+// Write рассылает соседям ключи пачки: и запись, и удаление у соседей превращаются в удаление.
+// Доставка не гарантируется и не повторяется, поэтому Write всегда возвращает nil,
+// а неудачные отправки видны в Stats. До Serve изменения отбрасываются. Очистка
+// хранилища (EventFlush) соседям не рассылается: у каждого узла свой Reset.
+func (n *Node) Write(ctx context.Context, batch []store.Mutation) error {
+	seen := make(map[string]struct{}, len(batch))
+	keys := make([]string, 0, len(batch))
+	for _, m := range batch {
+		if _, ok := seen[m.Key]; ok || m.Type == store.EventFlush {
+			continue
+		}
+		seen[m.Key] = struct{}{}
+		keys = append(keys, m.Key)
+	}
+
+	for _, chunk := range n.chunks(keys) {
+		msg := message{From: n.id, Seq: n.seq.Add(1), Keys: chunk}
+		if n.opts.fanout > 0 {
+			msg.Hops = n.opts.hops
+		}
+		n.send(msg, nil)
+	}
+	return nil
+}
+
+// chunks делит ключи на части, каждая из которых помещается в датаграмму. Ключ, который
+// не помещается и один, уходит отдельной датаграммой - отправка скорее всего не удастся
+func (n *Node) chunks(keys []string) [][]string {
+	const overhead = 96 // поля message кроме ключей
+	limit := n.opts.maxDatagram - overhead - macSize
+
+	var (
+		chunks [][]string
+		cur    []string
+		size   int
+	)
+	for _, key := range keys {
+		b, _ := json.Marshal(key)
+		if len(cur) > 0 && size+len(b)+1 > limit {
+			chunks = append(chunks, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, key)
+		size += len(b) + 1
+	}
+	if len(cur) > 0 {
+		chunks = append(chunks, cur)
+	}
+	return chunks
+}
+
+// send отправляет датаграмму соседям, кроме except: всем или WithFanout случайным
+func (n *Node) send(msg message, except net.Addr) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	if n.opts.secret != nil {
+		payload = append(payload, n.sign(payload)...)
+	}
+
+	n.mu.Lock()
+	conn := n.conn
+	targets := make([]*net.UDPAddr, 0, len(n.peers))
+	for _, addr := range n.peers {
+		if except == nil || addr.String() != except.String() {
+			targets = append(targets, addr)
+		}
+	}
+	n.mu.Unlock()
+	if conn == nil {
+		return
+	}
+
+	if n.opts.fanout > 0 && len(targets) > n.opts.fanout {
+		rand.Shuffle(len(targets), func(i, j int) { targets[i], targets[j] = targets[j], targets[i] })
+		targets = targets[:n.opts.fanout]
+	}
+	for _, addr := range targets {
+		if _, err := conn.WriteTo(payload, addr); err != nil {
+			n.sendErrors.Add(1)
+			continue
+		}
+		n.sent.Add(1)
+	}
+}
+
+func (n *Node) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, n.opts.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Serve принимает датаграммы соседей на conn и удаляет их ключи из s. Всегда возвращает
+// ошибку, после Close - ErrNodeClosed. Через conn же узел рассылает свои инвалидации.
+func (n *Node) Serve(s *store.Store, conn net.PacketConn) error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		conn.Close()
+		return ErrNodeClosed
+	}
+	n.conn = conn
+	n.mu.Unlock()
+
+	buf := make([]byte, 64<<10)
+	for {
+		nr, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			n.mu.Lock()
+			closed := n.closed
+			n.mu.Unlock()
+			if closed {
+				return ErrNodeClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		n.handle(s, buf[:nr], from)
+	}
+}
+
+func (n *Node) handle(s *store.Store, data []byte, from net.Addr) {
+	if n.opts.secret != nil {
+		if len(data) < macSize {
+			n.rejected.Add(1)
+			return
+		}
+		payload, sig := data[:len(data)-macSize], data[len(data)-macSize:]
+		if !hmac.Equal(sig, n.sign(payload)) {
+			n.rejected.Add(1)
+			return
+		}
+		data = payload
+	}
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		n.rejected.Add(1)
+		return
+	}
+	if msg.From == n.id || !n.markSeen(msgID{msg.From, msg.Seq}) {
+		n.duplicates.Add(1)
+		return
+	}
+
+	n.received.Add(1)
+	for _, key := range msg.Keys {
+		s.Invalidate(key)
+	}
+	n.invalidated.Add(uint64(len(msg.Keys)))
+
+	if n.opts.fanout > 0 && msg.Hops > 0 {
+		msg.Hops--
+		n.send(msg, from)
+	}
+}
+
+// markSeen запоминает датаграмму, false - она уже была
+func (n *Node) markSeen(id msgID) bool {
+	n.seenMu.Lock()
+	defer n.seenMu.Unlock()
+
+	if _, ok := n.seen[id]; ok {
+		return false
+	}
+	if old := n.seenRing[n.seenNext]; old != (msgID{}) {
+		delete(n.seen, old)
+	}
+	n.seenRing[n.seenNext] = id
+	n.seenNext = (n.seenNext + 1) % len(n.seenRing)
+	n.seen[id] = struct{}{}
+	return true
+}
+
+// Close останавливает Serve и закрывает conn.
+func (n *Node) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil
+	}
+	n.closed = true
+	if n.conn != nil {
+		return n.conn.Close()
+	}
+	return nil
+}
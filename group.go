@@ -0,0 +1,146 @@
+package store
+
+import "time"
+
+// expireGroup - группа ключей с общим сроком, см. SetInGroup
+type expireGroup struct {
+	expiresAt time.Time           // общий срок членов, нулевой - до ExpireGroup
+	members   map[string]struct{} // ключи хранения
+}
+
+// SetInGroup сохраняет значение членом группы name: члены группы истекают в один момент и
+// удаляются вместе, так что читатель видит либо весь согласованный набор, либо ни одного
+// ключа. Срок задаёт первый член: группа живёт ttl от его записи (ttl <= 0 - до ExpireGroup),
+// следующие члены получают тот же срок, и их ttl не используется. После срока группа
+// начинается заново.
+//
+// Удаление любого члена (Delete, вытеснение, SetWithDeps) удаляет всю группу, истекают члены
+// и так вместе. Перезапись ключа через Set и другие методы выводит его из группы, а Expire
+// и Persist члена меняют только его срок, и он перестаёт истекать вместе с группой.
+func (s *Store) SetInGroup(name, key, value string, ttl time.Duration) error {
+	if name == "" {
+		return s.set(key, value, ttl, writeOpts{})
+	}
+	return s.set(key, value, ttl, writeOpts{group: name})
+}
+
+// ExpireGroup сразу удаляет все ключи группы name как истекшие, с WithExpireCallback, и
+// возвращает их число. 0 - группы нет.
+func (s *Store) ExpireGroup(name string) int {
+	if s.enter() != nil {
+		return 0
+	}
+	defer s.leave()
+
+	var gone []expiredItem
+	now := s.now()
+	s.mu.Lock()
+	g := s.groups[name]
+	n := 0
+	if g != nil {
+		delete(s.groups, name) // удаление членов не должно снова удалять группу
+		for key := range g.members {
+			if item, ok := s.data[key]; ok {
+				gone = s.expireLocked(key, item, gone)
+				s.sinkBulkLocked(EventDelete, key, nil, now)
+				n++
+			}
+		}
+	}
+	s.mu.Unlock()
+	s.expired(gone)
+	return n
+}
+
+// GroupKeys возвращает ключи группы name, пусто - группы нет.
+func (s *Store) GroupKeys(name string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	g := s.groups[name]
+	if g == nil {
+		return nil
+	}
+	res := make([]string, 0, len(g.members))
+	for key := range g.members {
+		if uk, ok := s.userKey(key); ok {
+			res = append(res, uk)
+		}
+	}
+	return res
+}
+
+// joinGroupLocked готовит item к вступлению в группу name: создаёт группу, если её нет или
+// её срок прошёл, и выставляет item общий срок. Членов истекшей группы удаляет и добавляет
+// в gone. Само членство добавляет putLocked. Вызывается под s.mu.Lock
+func (s *Store) joinGroupLocked(item *Item, name string, ttl time.Duration, now time.Time, gone []expiredItem) []expiredItem {
+	g := s.groups[name]
+	if g != nil && !g.expiresAt.IsZero() && now.After(g.expiresAt) {
+		delete(s.groups, name)
+		for key := range g.members {
+			if old, ok := s.data[key]; ok {
+				gone = s.expireLocked(key, old, gone)
+			}
+		}
+		g = nil
+	}
+	if g == nil {
+		if s.groups == nil {
+			s.groups = make(map[string]*expireGroup)
+		}
+		g = &expireGroup{expiresAt: expiresAt(now, s.effectiveTTL(ttl)), members: make(map[string]struct{})}
+		s.groups[name] = g
+	}
+	item.group = name
+	item.ExpiresAt = g.expiresAt
+	item.freshUntil = time.Time{}
+	return gone
+}
+
+// groupPutLocked переносит членство ключа key из группы old в группу it, вызывается из putLocked
+func (s *Store) groupPutLocked(key string, old, it *Item) {
+	if old != nil && old.group != "" && old.group != it.group {
+		if g := s.groups[old.group]; g != nil {
+			delete(g.members, key)
+			if len(g.members) == 0 {
+				delete(s.groups, old.group)
+			}
+		}
+	}
+	if it.group != "" {
+		if g := s.groups[it.group]; g != nil {
+			g.members[key] = struct{}{}
+		}
+	}
+}
+
+// groupRemoveLocked удаляет вслед за ключом key остальных членов его группы с той же
+// причиной why, вызывается из removeLocked. При истечении только выводит key из группы:
+// у членов общий срок, остальные истекли тоже, и их удалит Cleanup с WithExpireCallback
+func (s *Store) groupRemoveLocked(key string, old *Item, why EventType) {
+	if old.group == "" {
+		return
+	}
+	g := s.groups[old.group]
+	if g == nil {
+		return
+	}
+	delete(g.members, key)
+	if why == EventExpire {
+		if len(g.members) == 0 {
+			delete(s.groups, old.group)
+		}
+		return
+	}
+	delete(s.groups, old.group) // вложенные removeLocked группу уже не найдут
+	for m := range g.members {
+		if s.removeLocked(m, why) {
+			if why == EventEvict {
+				s.stats.evictions.Add(1)
+			} else {
+				s.stats.deletes.Add(1)
+				s.sinkBulkLocked(EventDelete, m, nil, s.now())
+			}
+		}
+	}
+}
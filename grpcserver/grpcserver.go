@@ -0,0 +1,218 @@
+// Package grpcserver - gRPC фронтенд для store.Store по контракту storepb.
+// Клиент - сгенерированный storepb.NewStoreClient, дедлайны и отмена передаются через context.
+package grpcserver
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/grpcserver/storepb"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	// streamBatch - сколько ключей ListStream берёт из хранилища за раз
+	streamBatch = 500
+)
+
+// Server реализует storepb.StoreServer поверх хранилища.
+type Server struct {
+	storepb.UnimplementedStoreServer
+
+	s *store.Store
+}
+
+// New создаёт сервис для хранилища s.
+func New(s *store.Store) *Server {
+	return &Server{s: s}
+}
+
+// Register создаёт сервис и регистрирует его в gs.
+func Register(gs *grpc.Server, s *store.Store) *Server {
+	srv := New(s)
+	storepb.RegisterStoreServer(gs, srv)
+	return srv
+}
+
+// Get возвращает значение ключа.
+func (srv *Server) Get(ctx context.Context, req *storepb.GetRequest) (*storepb.GetResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	value, ok := srv.s.Get(req.GetKey())
+	return &storepb.GetResponse{Value: value, Found: ok}, nil
+}
+
+// Set сохраняет значение.
+func (srv *Server) Set(ctx context.Context, req *storepb.SetRequest) (*storepb.SetResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	if err := srv.s.Set(req.GetKey(), req.GetValue(), req.GetTtl().AsDuration()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &storepb.SetResponse{}, nil
+}
+
+// Delete удаляет ключ.
+func (srv *Server) Delete(ctx context.Context, req *storepb.DeleteRequest) (*storepb.DeleteResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	srv.s.Delete(req.GetKey())
+	return &storepb.DeleteResponse{}, nil
+}
+
+// GetMany возвращает значения ключей req.Keys, как Get для каждого.
+func (srv *Server) GetMany(ctx context.Context, req *storepb.GetManyRequest) (*storepb.GetManyResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if len(req.GetKeys()) > maxPageLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d keys per call", maxPageLimit)
+	}
+
+	resp := &storepb.GetManyResponse{Values: make(map[string]string, len(req.GetKeys()))}
+	for _, k := range req.GetKeys() {
+		if value, ok := srv.s.Get(k); ok {
+			resp.Values[k] = value
+		}
+	}
+	return resp, nil
+}
+
+// List возвращает страницу ключей после req.After.
+func (srv *Server) List(ctx context.Context, req *storepb.ListRequest) (*storepb.ListResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	case limit == 0:
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	all := srv.s.FullList()
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		if k > req.GetAfter() && strings.HasPrefix(k, req.GetPrefix()) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	resp := &storepb.ListResponse{}
+	for _, k := range keys {
+		if len(resp.Items) == limit {
+			resp.Next = resp.Items[len(resp.Items)-1].Key
+			break
+		}
+		resp.Items = append(resp.Items, itemOf(k, all[k]))
+	}
+	return resp, nil
+}
+
+// ListStream отправляет ключи после req.After по одному, limit 0 - все. Хранилище читается
+// пачками через store.List, так что ни сервер, ни клиент не держат весь список в памяти;
+// ключ, записанный во время обхода, может не попасть в поток.
+func (srv *Server) ListStream(req *storepb.ListRequest, stream storepb.Store_ListStreamServer) error {
+	ctx := stream.Context()
+	limit := int(req.GetLimit())
+	if limit < 0 {
+		return status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+
+	opts := store.ListOptions{
+		Prefix: req.GetPrefix(),
+		Limit:  streamBatch,
+		Fields: store.ListValue | store.ListTimes | store.ListViews,
+	}
+	sent := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		page, err := srv.s.List(opts)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		for _, e := range page.Items {
+			if e.Key <= req.GetAfter() {
+				continue
+			}
+			if limit > 0 && sent == limit {
+				return nil
+			}
+			if err := stream.Send(itemOf(e.Key, e.ItemDTO)); err != nil {
+				return err
+			}
+			sent++
+		}
+		if page.Next == "" {
+			return nil
+		}
+		opts.Cursor = page.Next
+	}
+}
+
+// itemOf - элемент контракта для ключа key
+func itemOf(key string, dto store.ItemDTO) *storepb.Item {
+	item := &storepb.Item{Key: key, Value: dto.Value, Views: dto.Views}
+	if !dto.ExpiresAt.IsZero() {
+		item.ExpiresAt = timestamppb.New(dto.ExpiresAt)
+	}
+	return item
+}
+
+// watchTypes - соответствие видов событий хранилища и контракта
+var watchTypes = map[store.EventType]storepb.WatchEvent_Type{
+	store.EventSet:    storepb.WatchEvent_TYPE_SET,
+	store.EventDelete: storepb.WatchEvent_TYPE_DELETE,
+	store.EventExpire: storepb.WatchEvent_TYPE_EXPIRE,
+	store.EventEvict:  storepb.WatchEvent_TYPE_EVICT,
+}
+
+// Watch отправляет изменения ключа или префикса, пока клиент не отменит вызов.
+// Если клиент не успевает читать, старые события отбрасываются.
+func (srv *Server) Watch(req *storepb.WatchRequest, stream storepb.Store_WatchServer) error {
+	ctx := stream.Context()
+
+	var events <-chan store.Event
+	switch {
+	case req.GetKey() != "" && req.GetPrefix() != "":
+		return status.Error(codes.InvalidArgument, "only one of key and prefix can be set")
+	case req.GetKey() != "":
+		events = srv.s.Watch(ctx, req.GetKey(), store.WithDropPolicy(store.DropOldest))
+	default:
+		events = srv.s.WatchPrefix(ctx, req.GetPrefix(), store.WithDropPolicy(store.DropOldest))
+	}
+
+	for ev := range events {
+		err := stream.Send(&storepb.WatchEvent{
+			Type:  watchTypes[ev.Type],
+			Key:   ev.Key,
+			Value: ev.Value,
+			Time:  timestamppb.New(ev.At),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return status.FromContextError(ctx.Err()).Err()
+}
@@ -0,0 +1,5 @@
+// Package storepb - контракт gRPC сервиса хранилища: сообщения и клиент (NewStoreClient),
+// сгенерированные из store.proto.
+package storepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative store.proto
@@ -0,0 +1,64 @@
+package store
+
+import "maps"
+
+// HSet записывает поля хеша key и возвращает, сколько полей добавлено (а не перезаписано).
+// Все поля записываются атомарно. Отсутствующий ключ создаётся с TTL по умолчанию
+// (WithDefaultTTL), у существующего TTL сохраняется. Если ключ хранит не хеш,
+// возвращается ErrWrongType.
+func (s *Store) HSet(key string, fields map[string]string) (int, error) {
+	added := 0
+	err := s.update(key, kindHash, func(next *Item) bool {
+		added = 0 // fn может вызываться повторно, см. update
+		// элементы не меняются на месте, поэтому хеш копируем
+		hash := make(map[string]string, len(next.hash)+len(fields))
+		maps.Copy(hash, next.hash)
+		for f, v := range fields {
+			if _, exists := hash[f]; !exists {
+				added++
+			}
+			hash[f] = v
+		}
+		next.hash = hash
+		return len(fields) > 0
+	})
+	return added, err
+}
+
+// HGet возвращает значение поля хеша. false - ключа или поля нет, ключ истёк
+// или хранит не хеш. Как и Get, увеличивает число просмотров ключа.
+func (s *Store) HGet(key, field string) (string, bool) {
+	item, ok := s.view(key, kindHash)
+	if !ok {
+		return "", false
+	}
+	value, ok := item.hash[field]
+	return value, ok
+}
+
+// HGetAll возвращает копию всех полей хеша, nil - если ключа нет, он истёк или хранит не хеш.
+func (s *Store) HGetAll(key string) map[string]string {
+	item, ok := s.view(key, kindHash)
+	if !ok {
+		return nil
+	}
+	return maps.Clone(item.hash)
+}
+
+// HDel удаляет поля хеша и возвращает, сколько из них было. Хеш без полей удаляется целиком.
+// Если ключ хранит не хеш, возвращается ErrWrongType.
+func (s *Store) HDel(key string, fields ...string) (int, error) {
+	removed := 0
+	err := s.update(key, kindHash, func(next *Item) bool {
+		hash := maps.Clone(next.hash)
+		for _, f := range fields {
+			if _, exists := hash[f]; exists {
+				delete(hash, f)
+				removed++
+			}
+		}
+		next.hash = hash
+		return removed > 0
+	})
+	return removed, err
+}
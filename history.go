@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoHistory - у ключа нет столько прежних значений, сколько просят откатить, см. RollBack.
+var ErrNoHistory = errors.New("store: not enough history for key")
+
+// HistoryEntry - прежнее значение ключа.
+type HistoryEntry struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"` // когда это значение было записано
+	Version   uint64    `json:"version"`
+}
+
+// WithHistory хранит для каждого строкового ключа depth прежних значений, см. History
+// и RollBack: так неудачное обновление кеша можно разобрать и откатить во время инцидента.
+// История живёт, пока живёт ключ: удаление, истечение и вытеснение её стирают.
+// Прежние значения учитываются в памяти процесса, но не в WithMaxMemory.
+func WithHistory(depth int) Option {
+	return func(c *config) {
+		c.historyDepth = depth
+	}
+}
+
+// recordHistoryLocked кладет в историю ключа значение old, которое заменяет next. Смена только TTL
+// (Expire) и значения коллекций в историю не попадают. Вызывается под s.mu.Lock
+func (s *Store) recordHistoryLocked(key string, old, next *Item) {
+	if old.kind != kindString || next.kind != kindString || old.Value == next.Value {
+		return
+	}
+	if s.history == nil {
+		s.history = make(map[string][]HistoryEntry)
+	}
+	h := append(s.history[key], HistoryEntry{Value: old.plain(), UpdatedAt: old.UpdatedAt, Version: old.Version})
+	if len(h) > s.cfg.historyDepth {
+		h = append(h[:0:0], h[len(h)-s.cfg.historyDepth:]...)
+	}
+	s.history[key] = h
+}
+
+// History возвращает прежние значения ключа, от новых к старым. nil - истории нет
+// или WithHistory не задан.
+func (s *Store) History(key string) []HistoryEntry {
+	s.mu.RLock()
+	h := s.history[s.skey(key)]
+	res := make([]HistoryEntry, len(h))
+	for i, e := range h {
+		res[len(h)-1-i] = e
+	}
+	s.mu.RUnlock()
+
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// RollBack возвращает ключу значение, которое было n записей назад (n = 1 - предыдущее),
+// TTL ключа не меняется. Откат - обычная запись: текущее значение само уходит в историю,
+// так что откат можно откатить. Если ключа нет или истории меньше n - ErrNoHistory.
+func (s *Store) RollBack(key string, n int) error {
+	if err := s.checkWrite(key, 0); err != nil {
+		return err
+	}
+	if err := s.beginWrite(writeOpts{}); err != nil {
+		return err
+	}
+	defer s.endWrite()
+
+	key = s.skey(key)
+	now := s.now()
+
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	cur, ok := s.data[key]
+	h := s.history[key]
+	if !ok || cur.expiredAt(now) || cur.kind != kindString || n <= 0 || n > len(h) {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return ErrNoHistory
+	}
+	next := cur.copyItem()
+	next.UpdatedAt = now
+	s.setCompressed(next, h[len(h)-n].Value)
+	s.putLocked(key, next)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, next, now)
+	}
+	s.evictLocked(now, key)
+	s.mu.Unlock()
+
+	s.stats.sets.Add(1)
+	s.push(key)
+	s.cfg.logger.Info("store: key rolled back", "key", key, "steps", n)
+	return nil
+}
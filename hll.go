@@ -0,0 +1,130 @@
+package store
+
+import (
+	"encoding/base64"
+	"math"
+	"math/bits"
+)
+
+const (
+	// hllPrecision - число бит хеша на номер регистра: 2^14 регистров по байту, 16KB на ключ
+	// и стандартная ошибка 1.04/sqrt(2^14) ~ 0.81%, как в Redis
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+)
+
+// PFAdd добавляет элементы в HyperLogLog key и сообщает, изменилась ли оценка.
+// HyperLogLog занимает фиксированные 16KB при любом числе элементов, PFCount оценивает
+// число различных элементов с ошибкой около 0.81%. Отсутствующий ключ создаётся с TTL
+// по умолчанию (WithDefaultTTL), истекший убирается Cleanup, как обычный ключ.
+// Если ключ хранит другой тип, возвращается ErrWrongType.
+//
+// Get по такому ключу возвращает регистры в base64.
+func (s *Store) PFAdd(key string, elements ...string) (bool, error) {
+	changed := false
+	err := s.update(key, kindHLL, func(next *Item) bool {
+		created := next.hll == nil
+		var regs []byte
+		for _, e := range elements {
+			idx, rank := hllPosition(e)
+			cur := byte(0)
+			if regs != nil {
+				cur = regs[idx]
+			} else if !created {
+				cur = next.hll[idx]
+			}
+			if rank <= cur {
+				continue
+			}
+			if regs == nil {
+				// регистры копируем только при первом изменении, старый элемент могут читать
+				regs = make([]byte, hllRegisters)
+				copy(regs, next.hll)
+			}
+			regs[idx] = rank
+		}
+		if regs == nil {
+			if !created {
+				return false
+			}
+			regs = make([]byte, hllRegisters) // PFAdd без элементов создаёт пустой HyperLogLog
+		}
+		next.hll = regs
+		changed = true
+		return true
+	})
+	return changed, err
+}
+
+// PFCount возвращает оценку числа различных элементов, для нескольких ключей -
+// их объединения. Отсутствующие, истекшие ключи и ключи другого типа пропускаются.
+func (s *Store) PFCount(keys ...string) uint64 {
+	var union []byte
+	for _, key := range keys {
+		item, ok := s.view(key, kindHLL)
+		if !ok {
+			continue
+		}
+		if union == nil && len(keys) == 1 {
+			return hllEstimate(item.hll)
+		}
+		if union == nil {
+			union = make([]byte, hllRegisters)
+		}
+		for i, r := range item.hll {
+			union[i] = max(union[i], r)
+		}
+	}
+	if union == nil {
+		return 0
+	}
+	return hllEstimate(union)
+}
+
+// hllPosition - номер регистра и ранг (позиция первой единицы) элемента.
+// Хеш детерминированный, иначе после загрузки снапшота те же элементы посчитались бы заново
+func hllPosition(e string) (idx int, rank byte) {
+	h := hash64(e)
+	idx = int(h >> (64 - hllPrecision))
+	rest := h<<hllPrecision | 1<<(hllPrecision-1) // ограничитель, ранг не больше 64-p+1
+	return idx, byte(bits.LeadingZeros64(rest) + 1)
+}
+
+// hllEstimate - оценка HyperLogLog с поправкой линейного счета для малых множеств
+func hllEstimate(regs []byte) uint64 {
+	m := float64(hllRegisters)
+	sum, zeros := 0.0, 0
+	for _, r := range regs {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est + 0.5)
+}
+
+// hash64 - FNV-1a с перемешиванием из MurmurHash3, у самого FNV старшие биты распределены плохо
+func hash64(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// hllText - регистры для Get, подписчиков и приёмника изменений
+func hllText(regs []byte) string {
+	return base64.StdEncoding.EncodeToString(regs)
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithHotKeyDetection включает поиск горячих ключей: ключ горячий, если за скользящее окно window
+// его прочитали больше threshold раз. onHot (может быть nil) вызывается один раз за окно, когда ключ
+// становится горячим, синхронно в горутине чтения, поэтому должен быть быстрым.
+// Текущие горячие ключи отдаёт HotKeys. Такие ключи стоит реплицировать или кешировать локально.
+func WithHotKeyDetection(threshold int, window time.Duration, onHot func(key string, reads int)) Option {
+	return func(c *config) {
+		c.hotThreshold = threshold
+		c.hotWindow = window
+		c.onHot = onHot
+	}
+}
+
+// HotKey - горячий ключ с оценкой числа чтений за окно, см. HotKeys.
+type HotKey struct {
+	Key   string `json:"key"`
+	Reads int    `json:"reads"`
+}
+
+// hotKeys считает чтения ключей в двух окнах, текущем и предыдущем: оценка за скользящее
+// окно - текущее плюс предыдущее, взвешенное по ещё не прошедшей доле окна, как в ratelimit.
+// Ключи, которые не читали два окна, забываются при смене окна
+type hotKeys struct {
+	threshold int
+	window    time.Duration
+
+	mu       sync.Mutex
+	idx      int64 // номер текущего окна
+	cur      map[string]int
+	prev     map[string]int
+	reported map[string]struct{} // о ком уже сообщили в текущем окне
+}
+
+func newHotKeys(threshold int, window time.Duration) *hotKeys {
+	return &hotKeys{
+		threshold: threshold,
+		window:    window,
+		cur:       make(map[string]int),
+		prev:      make(map[string]int),
+		reported:  make(map[string]struct{}),
+	}
+}
+
+// rotateLocked переключает окна, если текущее прошло, и возвращает долю прошедшего окна
+func (h *hotKeys) rotateLocked(now time.Time) float64 {
+	idx := now.UnixNano() / int64(h.window)
+	switch {
+	case idx == h.idx+1:
+		h.prev, h.cur = h.cur, make(map[string]int, len(h.cur))
+		clear(h.reported)
+	case idx != h.idx:
+		clear(h.prev)
+		clear(h.cur)
+		clear(h.reported)
+	}
+	h.idx = idx
+	return float64(now.UnixNano()%int64(h.window)) / float64(h.window)
+}
+
+func (h *hotKeys) estimateLocked(key string, elapsed float64) int {
+	return int(float64(h.prev[key])*(1-elapsed)) + h.cur[key]
+}
+
+// record учитывает чтение ключа и возвращает оценку, если ключ только что стал горячим
+func (h *hotKeys) record(key string, now time.Time) (int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	elapsed := h.rotateLocked(now)
+	h.cur[key]++
+	reads := h.estimateLocked(key, elapsed)
+	if reads <= h.threshold {
+		return 0, false
+	}
+	if _, ok := h.reported[key]; ok {
+		return 0, false
+	}
+	h.reported[key] = struct{}{}
+	return reads, true
+}
+
+func (h *hotKeys) hot(now time.Time) []HotKey {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	elapsed := h.rotateLocked(now)
+	var res []HotKey
+	seen := func(key string) {
+		if reads := h.estimateLocked(key, elapsed); reads > h.threshold {
+			res = append(res, HotKey{Key: key, Reads: reads})
+		}
+	}
+	for key := range h.cur {
+		seen(key)
+	}
+	for key := range h.prev {
+		if _, ok := h.cur[key]; !ok {
+			seen(key)
+		}
+	}
+	return res
+}
+
+// recordRead учитывает чтение ключа хранения key для WithHotKeyDetection
+func (s *Store) recordRead(key string, now time.Time) {
+	if s.hot == nil {
+		return
+	}
+	reads, hot := s.hot.record(key, now)
+	if !hot {
+		return
+	}
+	userKey, ok := s.userKey(key)
+	if !ok {
+		return
+	}
+	s.cfg.logger.Debug("store: hot key detected", "key", userKey, "reads", reads)
+	if s.cfg.onHot != nil {
+		start := s.callbackStart()
+		s.cfg.onHot(userKey, reads)
+		s.callbackDone(start)
+	}
+}
+
+// HotKeys возвращает ключи, которые сейчас горячие по WithHotKeyDetection, от самых читаемых.
+// Без WithHotKeyDetection возвращает nil.
+func (s *Store) HotKeys() []HotKey {
+	if s.hot == nil {
+		return nil
+	}
+	all := s.hot.hot(s.now())
+	res := all[:0]
+	for _, hk := range all {
+		if key, ok := s.userKey(hk.Key); ok {
+			hk.Key = key
+			res = append(res, hk)
+		}
+	}
+	slices.SortFunc(res, func(a, b HotKey) int {
+		if a.Reads != b.Reads {
+			return cmp.Compare(b.Reads, a.Reads)
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return res
+}
@@ -0,0 +1,342 @@
+// Package httpcache - net/http middleware, которое кеширует ответы на GET в store.Store.
+//
+// Ответ сохраняется по методу и URL (путь с query) вместе со статусом и выбранными
+// заголовками. TTL берется из Cache-Control ответа (s-maxage, затем max-age), ответы
+// с no-store, private, no-cache, Set-Cookie или Vary: * не кешируются, как и ответы на
+// запросы с Authorization, если ответ не разрешил это через public или s-maxage (RFC 9111,
+// 3.5). Ответ с Vary хранится отдельно для каждого сочетания значений перечисленных в нём
+// заголовков запроса. Изменяющий запрос (POST, PUT, PATCH, DELETE) к пути из WithBustPaths
+// сбрасывает закешированные ответы под этим путём.
+package httpcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Option настраивает Cache.
+type Option func(*Cache)
+
+// WithPrefix задаёт префикс ключей в хранилище, по умолчанию "httpcache:".
+func WithPrefix(prefix string) Option {
+	return func(c *Cache) {
+		c.prefix = prefix
+	}
+}
+
+// WithDefaultTTL кеширует на ttl ответы без max-age в Cache-Control.
+// По умолчанию такие ответы не кешируются.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.defaultTTL = ttl
+	}
+}
+
+// WithHeaders задаёт заголовки ответа, которые сохраняются вместе с телом.
+// По умолчанию Content-Type, Content-Encoding, Content-Language, ETag, Last-Modified и Cache-Control.
+func WithHeaders(names ...string) Option {
+	return func(c *Cache) {
+		c.headers = names
+	}
+}
+
+// WithBustPaths задаёт префиксы путей, изменяющий запрос к которым сбрасывает кеш:
+// успешный POST /api/users/42 при префиксе "/api/users" удаляет все закешированные
+// ответы на GET /api/users...
+func WithBustPaths(prefixes ...string) Option {
+	return func(c *Cache) {
+		c.bust = prefixes
+	}
+}
+
+// WithMaxBodySize задаёт максимальный размер кешируемого тела, по умолчанию 1MB.
+// Ответы больше отдаются как есть и не кешируются.
+func WithMaxBodySize(n int) Option {
+	return func(c *Cache) {
+		c.maxBody = n
+	}
+}
+
+// Cache кеширует ответы обработчиков в хранилище.
+type Cache struct {
+	s          *store.Store
+	prefix     string
+	defaultTTL time.Duration
+	headers    []string
+	bust       []string
+	maxBody    int
+}
+
+// New создаёт кеш ответов поверх s.
+func New(s *store.Store, opts ...Option) *Cache {
+	c := &Cache{
+		s:       s,
+		prefix:  "httpcache:",
+		headers: []string{"Content-Type", "Content-Encoding", "Content-Language", "ETag", "Last-Modified", "Cache-Control"},
+		maxBody: 1 << 20,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// entry - сохранённый ответ. Для ответа с Vary по ключу URL лежит entry только с Vary,
+// а сам ответ - по ключу варианта, см. variantKey
+type entry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Stored time.Time   `json:"stored"`
+	Vary   []string    `json:"vary,omitempty"`
+}
+
+// Middleware оборачивает next кешем. Закешированный ответ отдаётся с заголовками
+// X-Cache: HIT и Age, ответ из next - с X-Cache: MISS.
+func (c *Cache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			c.serveGet(w, r, next)
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status < 400 {
+				c.bustFor(r.URL.Path)
+			}
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (c *Cache) serveGet(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	key := c.key(r.Method, r.URL.RequestURI())
+
+	if !hasDirective(r.Header.Get("Cache-Control"), "no-cache", "no-store") {
+		if e, ok := c.lookup(key, r); ok {
+			for name, values := range e.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("X-Cache", "HIT")
+			w.Header().Set("Age", strconv.Itoa(int(time.Since(e.Stored).Seconds())))
+			w.WriteHeader(e.Status)
+			w.Write(e.Body)
+			return
+		}
+	}
+
+	w.Header().Set("X-Cache", "MISS")
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK, limit: c.maxBody}
+	next.ServeHTTP(rec, r)
+
+	ttl, ok := c.ttl(r, rec)
+	if !ok || rec.overflow {
+		return
+	}
+	e := entry{Status: rec.status, Header: make(http.Header), Body: rec.body.Bytes(), Stored: time.Now()}
+	for _, name := range c.headers {
+		if values := w.Header().Values(name); len(values) > 0 {
+			e.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	if vary := varyHeaders(w.Header()); len(vary) > 0 {
+		marker, err := json.Marshal(entry{Stored: e.Stored, Vary: vary})
+		if err != nil {
+			return
+		}
+		c.s.Set(key, string(marker), ttl)
+		key = variantKey(key, vary, r)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	c.s.Set(key, string(data), ttl)
+}
+
+// lookup возвращает сохранённый ответ на r, для ответа с Vary - вариант по заголовкам r
+func (c *Cache) lookup(key string, r *http.Request) (entry, bool) {
+	e, ok := c.load(key)
+	if !ok || len(e.Vary) == 0 {
+		return e, ok
+	}
+	return c.load(variantKey(key, e.Vary, r))
+}
+
+func (c *Cache) load(key string) (entry, bool) {
+	raw, ok := c.s.Get(key)
+	if !ok {
+		return entry{}, false
+	}
+	var e entry
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		return entry{}, false
+	}
+	return e, true
+}
+
+// varyHeaders - заголовки запроса из Vary ответа h, канонические и без повторов
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// variantKey - ключ ответа на r с Vary по заголовкам vary. URI в ключе не содержит
+// пробелов, так что вариант не совпадёт с ключом другого URL
+func variantKey(key string, vary []string, r *http.Request) string {
+	values := make(url.Values, len(vary))
+	for _, name := range vary {
+		values[name] = r.Header.Values(name)
+	}
+	return key + " " + values.Encode()
+}
+
+// ttl решает, можно ли кешировать ответ на r, и на сколько
+func (c *Cache) ttl(r *http.Request, rec *recorder) (time.Duration, bool) {
+	if !cacheableStatus(rec.status) {
+		return 0, false
+	}
+	h := rec.Header()
+	if h.Get("Set-Cookie") != "" || slices.Contains(varyHeaders(h), "*") {
+		return 0, false
+	}
+
+	cc := h.Get("Cache-Control")
+	if hasDirective(cc, "no-store", "no-cache", "private") {
+		return 0, false
+	}
+	// ответ авторизованному клиенту общий кеш хранит, только если ответ это разрешил
+	if r.Header.Get("Authorization") != "" && !hasDirective(cc, "public", "s-maxage") {
+		return 0, false
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if secs, ok := directiveValue(cc, name); ok {
+			n, err := strconv.Atoi(secs)
+			if err != nil || n <= 0 {
+				return 0, false
+			}
+			return time.Duration(n) * time.Second, true
+		}
+	}
+	return c.defaultTTL, c.defaultTTL > 0
+}
+
+// cacheableStatus - статусы, которые RFC 9111 разрешает кешировать по умолчанию
+func cacheableStatus(status int) bool {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusNotFound,
+		http.StatusMethodNotAllowed, http.StatusGone, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// bustFor сбрасывает кеш под префиксами WithBustPaths, которые покрывают path
+func (c *Cache) bustFor(path string) {
+	for _, prefix := range c.bust {
+		if strings.HasPrefix(path, prefix) {
+			c.Purge(prefix)
+		}
+	}
+}
+
+// Purge удаляет закешированные ответы на GET с путём, начинающимся с pathPrefix,
+// и возвращает их число. Purge("") сбрасывает весь кеш ответов.
+func (c *Cache) Purge(pathPrefix string) int {
+	keys := c.s.Keys(escapeGlob(c.key(http.MethodGet, pathPrefix)) + "*")
+	for _, key := range keys {
+		c.s.Delete(key)
+	}
+	return len(keys)
+}
+
+func (c *Cache) key(method, uri string) string {
+	return c.prefix + method + " " + uri
+}
+
+// escapeGlob экранирует спецсимволы шаблона Keys
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// hasDirective сообщает, есть ли в Cache-Control хоть одна из директив
+func hasDirective(cc string, names ...string) bool {
+	for _, part := range strings.Split(cc, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveValue возвращает значение директивы вида max-age=60
+func directiveValue(cc, name string) (string, bool) {
+	for _, part := range strings.Split(cc, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && strings.EqualFold(k, name) {
+			return strings.Trim(v, `"`), true
+		}
+	}
+	return "", false
+}
+
+// recorder пропускает ответ клиенту и попутно запоминает статус и тело до limit байт
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+	wrote    bool
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if !rec.wrote {
+		rec.status, rec.wrote = status, true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	rec.wrote = true
+	if rec.limit > 0 && !rec.overflow {
+		if rec.body.Len()+len(p) > rec.limit {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap даёт http.ResponseController добраться до исходного ResponseWriter.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,89 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// get делает GET path через h с заголовками запроса header ("имя", "значение", ...)
+func get(h http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func wantCache(t *testing.T, rec *httptest.ResponseRecorder, status, body string) {
+	t.Helper()
+	if got := rec.Header().Get("X-Cache"); got != status || rec.Body.String() != body {
+		t.Errorf("X-Cache %s, body %q, want %s, %q", got, rec.Body.String(), status, body)
+	}
+}
+
+func TestCachesPublicResponses(t *testing.T) {
+	c := New(store.NewStore())
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("body"))
+	}))
+	wantCache(t, get(h, "/a"), "MISS", "body")
+	wantCache(t, get(h, "/a"), "HIT", "body")
+	wantCache(t, get(h, "/a", "Cache-Control", "no-cache"), "MISS", "body")
+}
+
+func TestDoesNotShareAuthorizedResponses(t *testing.T) {
+	c := New(store.NewStore())
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cc := "max-age=60"
+		if r.URL.Path == "/public" {
+			cc = "public, max-age=60"
+		}
+		w.Header().Set("Cache-Control", cc)
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+
+	wantCache(t, get(h, "/private", "Authorization", "alice"), "MISS", "alice")
+	wantCache(t, get(h, "/private", "Authorization", "bob"), "MISS", "bob")
+	wantCache(t, get(h, "/private"), "MISS", "")
+
+	wantCache(t, get(h, "/public", "Authorization", "alice"), "MISS", "alice")
+	wantCache(t, get(h, "/public", "Authorization", "bob"), "HIT", "alice")
+}
+
+func TestVaryKeepsVariantsApart(t *testing.T) {
+	c := New(store.NewStore())
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Add("Vary", "accept-language")
+		w.Header().Add("Vary", "Accept-Encoding, Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language") + "/" + r.Header.Get("Accept-Encoding")))
+	}))
+
+	wantCache(t, get(h, "/v", "Accept-Language", "en"), "MISS", "en/")
+	wantCache(t, get(h, "/v", "Accept-Language", "ru"), "MISS", "ru/")
+	wantCache(t, get(h, "/v", "Accept-Language", "en"), "HIT", "en/")
+	wantCache(t, get(h, "/v", "Accept-Language", "en", "Accept-Encoding", "gzip"), "MISS", "en/gzip")
+	wantCache(t, get(h, "/v", "Accept-Language", "ru"), "HIT", "ru/")
+
+	if n := c.Purge("/v"); n == 0 {
+		t.Error("Purge did not remove the variants")
+	}
+	wantCache(t, get(h, "/v", "Accept-Language", "ru"), "MISS", "ru/")
+}
+
+func TestVaryStarIsNotCached(t *testing.T) {
+	c := New(store.NewStore())
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept, *")
+		w.Write([]byte("body"))
+	}))
+	get(h, "/a")
+	wantCache(t, get(h, "/a"), "MISS", "body")
+}
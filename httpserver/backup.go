@@ -0,0 +1,92 @@
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// BackupTarget - место, куда POST /backup отправляет снапшот: бакет S3-совместимого
+// хранилища, каталог на другом диске или другой сервер хранилища (client.HTTP реализует
+// BackupTarget загрузкой снапшота в его PUT /snapshot).
+//
+// OpenBackup открывает объект name на запись. Close фиксирует объект и возвращает ошибку
+// фиксации. Если снапшот записать не удалось, ctx отменяется до Close, и цель не должна
+// оставлять обрезанный объект, например прерывает multipart-загрузку.
+type BackupTarget interface {
+	OpenBackup(ctx context.Context, name string) (io.WriteCloser, error)
+}
+
+// BackupTargetFunc - функция как BackupTarget.
+type BackupTargetFunc func(ctx context.Context, name string) (io.WriteCloser, error)
+
+// OpenBackup вызывает f.
+func (f BackupTargetFunc) OpenBackup(ctx context.Context, name string) (io.WriteCloser, error) {
+	return f(ctx, name)
+}
+
+// WithBackupTargets задаёт цели POST /backup по имени. Адрес цели из запроса не берётся:
+// иначе любой с токеном мог бы выгрузить данные куда угодно.
+func WithBackupTargets(targets map[string]BackupTarget) Option {
+	return func(srv *Server) {
+		srv.backups = targets
+	}
+}
+
+// BackupResult - ответ POST /backup.
+type BackupResult struct {
+	Target string        `json:"target"`
+	Name   string        `json:"name"`  // имя объекта в цели
+	Bytes  int64         `json:"bytes"` // размер снапшота
+	Took   time.Duration `json:"took"`
+}
+
+// backup - POST /backup?target=: согласованный снапшот (SaveSnapshot) потоком в цель
+// WithBackupTargets, не собирая его в памяти сервера. name= задаёт имя объекта, по
+// умолчанию store-<время UTC>.json
+func (srv *Server) backup(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	target, ok := srv.backups[q.Get("target")]
+	if !ok {
+		http.Error(w, "unknown backup target", http.StatusNotFound)
+		return
+	}
+	start := time.Now()
+	name := q.Get("name")
+	if name == "" {
+		name = "store-" + start.UTC().Format("20060102T150405Z") + ".json"
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	dst, err := target.OpenBackup(ctx, name)
+	if err != nil {
+		http.Error(w, "open backup: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	cw := &countingWriter{w: dst}
+	if err := srv.s.SaveSnapshot(cw); err != nil {
+		cancel()
+		dst.Close()
+		http.Error(w, "backup: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := dst.Close(); err != nil {
+		http.Error(w, "commit backup: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, BackupResult{Target: q.Get("target"), Name: name, Bytes: cw.n, Took: time.Since(start)})
+}
+
+// countingWriter считает записанные байты
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,198 @@
+package httpserver
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// debugValueLen - сколько символов значения показывает HTML страница /debug/keys
+const debugValueLen = 120
+
+// debugSorts - значения параметра sort страницы /debug/keys
+var debugSorts = map[string]store.ListSort{
+	"":        store.SortByKey,
+	"key":     store.SortByKey,
+	"updated": store.SortByUpdatedAt,
+	"expires": store.SortByExpiresAt,
+	"views":   store.SortByViews,
+	"size":    store.SortBySize,
+}
+
+// NewDebugHandler возвращает отладочную страницу ключей хранилища, как expvar для
+// переменных процесса: её можно повесить на служебный порт без остального REST API.
+// Та же страница доступна на Server как GET /debug/keys.
+//
+//	GET ?prefix=&sort=key|updated|expires|views|size&desc=1&limit=&cursor=&min_size=
+//
+// отвечает страницей store.List: ключи с TTL, числом чтений, объёмом и началом значения.
+// По умолчанию HTML с таблицей и ссылкой на следующую страницу, format=json или
+// Accept: application/json - JSON; values=0 не показывает значения.
+//
+// Из опций действуют WithAuthToken и WithAuthFunc. Страница раскрывает данные, без
+// аутентификации её стоит отдавать только на закрытом адресе.
+func NewDebugHandler(s *store.Store, opts ...Option) http.Handler {
+	srv := &Server{s: s}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !srv.allowed(w, r) {
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		srv.debugKeys(w, r)
+	})
+}
+
+// debugEntry - строка страницы /debug/keys
+type debugEntry struct {
+	Key       string        `json:"key"`
+	Value     string        `json:"value,omitempty"`
+	TTL       time.Duration `json:"ttl,omitempty"` // сколько осталось жить, 0 - без TTL
+	ExpiresAt *time.Time    `json:"expiresAt,omitempty"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+	Views     uint64        `json:"views"`
+	Size      int64         `json:"size"`
+	Tags      []string      `json:"tags,omitempty"`
+}
+
+type debugPage struct {
+	Items []debugEntry `json:"items"`
+	Next  string       `json:"next,omitempty"` // курсор следующей страницы
+
+	NextURL string     `json:"-"` // ссылка на следующую страницу для HTML
+	Query   url.Values `json:"-"` // параметры запроса для формы фильтра
+	Sorts   []string   `json:"-"`
+}
+
+func (srv *Server) debugKeys(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sortBy, ok := debugSorts[q.Get("sort")]
+	if !ok {
+		http.Error(w, "unknown sort", http.StatusBadRequest)
+		return
+	}
+	opts := store.ListOptions{
+		Prefix: q.Get("prefix"),
+		Limit:  defaultPageLimit,
+		Cursor: q.Get("cursor"),
+		SortBy: sortBy,
+		Desc:   q.Get("desc") == "1",
+		Fields: store.ListTimes | store.ListViews | store.ListSize | store.ListTags,
+	}
+	values := q.Get("values") != "0"
+	if values {
+		opts.Fields |= store.ListValue
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		opts.Limit = min(n, maxPageLimit)
+	}
+	if raw := q.Get("min_size"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid min_size", http.StatusBadRequest)
+			return
+		}
+		opts.MinSize = n
+	}
+
+	list, err := srv.s.List(opts)
+	switch {
+	case errors.Is(err, store.ErrInvalidCursor):
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	page := debugPage{
+		Items: make([]debugEntry, 0, len(list.Items)),
+		Next:  list.Next,
+		Query: q,
+		Sorts: []string{"key", "updated", "expires", "views", "size"},
+	}
+	for _, e := range list.Items {
+		entry := debugEntry{
+			Key:       e.Key,
+			Value:     e.Value,
+			UpdatedAt: e.UpdatedAt,
+			Views:     e.Views,
+			Size:      e.Size,
+			Tags:      e.Tags,
+		}
+		if !e.ExpiresAt.IsZero() {
+			entry.ExpiresAt = &e.ExpiresAt
+			entry.TTL = max(e.ExpiresAt.Sub(now), 0).Round(time.Millisecond)
+		}
+		page.Items = append(page.Items, entry)
+	}
+
+	if q.Get("format") == "json" || r.Header.Get("Accept") == "application/json" {
+		writeJSON(w, page)
+		return
+	}
+	if page.Next != "" {
+		next := make(url.Values, len(q))
+		for k, v := range q {
+			next[k] = v
+		}
+		next.Set("cursor", page.Next)
+		page.NextURL = "?" + next.Encode()
+	}
+	for i := range page.Items {
+		page.Items[i].Value = truncate(page.Items[i].Value, debugValueLen)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	debugTemplate.Execute(w, page)
+}
+
+// truncate обрезает s до n символов с многоточием
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>store keys</title>
+<style>
+body{font-family:sans-serif;font-size:14px}
+table{border-collapse:collapse}
+td,th{border:1px solid #ccc;padding:2px 6px;text-align:left;vertical-align:top}
+td.v{font-family:monospace;max-width:40em;overflow-wrap:anywhere}
+</style></head><body>
+<form method="get">
+prefix <input name="prefix" value="{{.Query.Get "prefix"}}">
+sort <select name="sort">
+{{$sort := .Query.Get "sort"}}{{range $s := .Sorts}}<option{{if eq $s $sort}} selected{{end}}>{{$s}}</option>{{end}}
+</select>
+<label><input type="checkbox" name="desc" value="1"{{if eq (.Query.Get "desc") "1"}} checked{{end}}> desc</label>
+min size <input name="min_size" size="8" value="{{.Query.Get "min_size"}}">
+<input type="submit" value="show">
+</form>
+<table>
+<tr><th>key</th><th>ttl</th><th>views</th><th>size</th><th>updated</th><th>tags</th><th>value</th></tr>
+{{range .Items}}<tr><td>{{.Key}}</td><td>{{if .ExpiresAt}}{{.TTL}}{{else}}-{{end}}</td><td>{{.Views}}</td><td>{{.Size}}</td><td>{{.UpdatedAt.Format "2006-01-02 15:04:05"}}</td><td>{{range .Tags}}{{.}} {{end}}</td><td class="v">{{.Value}}</td></tr>
+{{else}}<tr><td colspan="7">no keys</td></tr>
+{{end}}</table>
+{{if .NextURL}}<p><a href="{{.NextURL}}">next page</a></p>{{end}}
+</body></html>
+`))
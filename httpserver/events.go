@@ -0,0 +1,85 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// eventsBuffer - буфер событий одного подписчика /events, при переполнении теряются старые
+const eventsBuffer = 1024
+
+// eventTypes - значения параметра types для /events
+var eventTypes = map[string]store.EventType{
+	"set":    store.EventSet,
+	"delete": store.EventDelete,
+	"expire": store.EventExpire,
+	"evict":  store.EventEvict,
+}
+
+// events - GET /events: изменения ключей потоком Server-Sent Events, пока клиент не
+// отключится. match= - glob-шаблон ключей как в Keys, по умолчанию все; types= - события
+// через запятую (set, delete, expire, evict), по умолчанию все. Каждое событие - store.Event
+// в JSON с именем события SSE по его типу, так что в браузере хватает EventSource:
+//
+//	new EventSource("/events?types=expire").addEventListener("expire", ...)
+//
+// Подписчик, который не успевает читать, теряет самые старые события
+func (srv *Server) events(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var types map[store.EventType]bool
+	if raw := q.Get("types"); raw != "" {
+		types = make(map[store.EventType]bool)
+		for _, name := range strings.Split(raw, ",") {
+			t, ok := eventTypes[strings.TrimSpace(name)]
+			if !ok {
+				http.Error(w, "unknown event type "+name, http.StatusBadRequest)
+				return
+			}
+			types[t] = true
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	pattern := q.Get("match")
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	sub := srv.s.Subscribe(pattern, store.WithWatchBuffer(eventsBuffer), store.WithDropPolicy(store.DropOldest))
+	defer sub.Unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx := r.Context()
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if types != nil && !types[ev.Type] {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// HealthConfig - пороги проверок /healthz и /readyz. Нулевое поле выключает свою проверку.
+type HealthConfig struct {
+	CleanupMaxAge  time.Duration // проход очистки должен был закончиться не раньше, иначе очистка встала
+	SnapshotMaxAge time.Duration // SaveSnapshot должен был пройти не раньше, только для /readyz
+	MemoryMaxRatio float64       // доля MemoryBytes от WithMaxMemory, выше - не готов; только для /readyz
+	ReplicaMaxLag  time.Duration // Lag реплики, выше - не готов; только для /readyz и с Replica
+
+	// Replica - реплика этого узла (replication.Replica), nil - узел не реплика.
+	// Отключённая реплика не готова при любых порогах.
+	Replica ReplicaStatus
+}
+
+// ReplicaStatus - состояние реплики для /readyz, его реализует replication.Replica.
+type ReplicaStatus interface {
+	Connected() bool
+	Lag() time.Duration
+}
+
+// WithHealth задаёт пороги /healthz и /readyz. Без него обе проверяют только, что хранилище
+// не закрыто.
+//
+// Отсчёт возраста очистки и снапшота идёт не раньше создания Server, так что после старта
+// у очистки и снапшотов есть CleanupMaxAge и SnapshotMaxAge на первый проход.
+func WithHealth(cfg HealthConfig) Option {
+	return func(srv *Server) {
+		srv.health = cfg
+	}
+}
+
+// check - результат одной проверки в ответе /healthz и /readyz
+type check struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type healthResponse struct {
+	Status string           `json:"status"` // "ok" или "fail"
+	Checks map[string]check `json:"checks"`
+}
+
+// healthz - живость процесса: хранилище не закрыто и очистка по TTL работает. Оркестратор
+// перезапускает процесс, который её не проходит
+func (srv *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]check{"store": srv.checkStore()}
+	if h := srv.health; h.CleanupMaxAge > 0 {
+		checks["cleanup"] = srv.checkAge(srv.s.Stats().LastCleanup, h.CleanupMaxAge, "cleanup pass")
+	}
+	writeHealth(w, checks)
+}
+
+// readyz - готовность принимать запросы: проверки healthz, свежесть снапшота, запас памяти
+// и отставание реплики. Балансировщик не шлёт запросы узлу, который её не проходит
+func (srv *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]check{"store": srv.checkStore()}
+	h, st := srv.health, srv.s.Stats()
+	if h.CleanupMaxAge > 0 {
+		checks["cleanup"] = srv.checkAge(st.LastCleanup, h.CleanupMaxAge, "cleanup pass")
+	}
+	if h.SnapshotMaxAge > 0 {
+		checks["snapshot"] = srv.checkAge(st.LastSnapshot, h.SnapshotMaxAge, "snapshot")
+	}
+	if h.MemoryMaxRatio > 0 {
+		checks["memory"] = srv.checkMemory(st)
+	}
+	if h.Replica != nil {
+		checks["replica"] = srv.checkReplica()
+	}
+	writeHealth(w, checks)
+}
+
+func (srv *Server) checkStore() check {
+	if srv.s.Closed() {
+		return check{Detail: "store is closed"}
+	}
+	return check{OK: true}
+}
+
+// checkAge проверяет, что с last прошло не больше maxAge. Отсчёт не раньше создания Server
+func (srv *Server) checkAge(last time.Time, maxAge time.Duration, what string) check {
+	since := last
+	if since.Before(srv.started) {
+		since = srv.started
+	}
+	age := time.Since(since).Round(time.Millisecond)
+	switch {
+	case age <= maxAge:
+		if last.IsZero() {
+			return check{OK: true, Detail: fmt.Sprintf("no %s yet", what)}
+		}
+		return check{OK: true, Detail: fmt.Sprintf("last %s %s ago", what, age)}
+	case last.IsZero():
+		return check{Detail: fmt.Sprintf("no %s for %s, limit %s", what, age, maxAge)}
+	default:
+		return check{Detail: fmt.Sprintf("last %s %s ago, limit %s", what, age, maxAge)}
+	}
+}
+
+func (srv *Server) checkMemory(st store.Stats) check {
+	if st.MemoryLimit == 0 {
+		return check{OK: true, Detail: "no memory limit"}
+	}
+	ratio := float64(st.MemoryBytes) / float64(st.MemoryLimit)
+	detail := fmt.Sprintf("%d of %d bytes used (%.0f%%)", st.MemoryBytes, st.MemoryLimit, ratio*100)
+	return check{OK: ratio <= srv.health.MemoryMaxRatio, Detail: detail}
+}
+
+func (srv *Server) checkReplica() check {
+	r := srv.health.Replica
+	if !r.Connected() {
+		return check{Detail: "replica is not connected to primary"}
+	}
+	lag := r.Lag().Round(time.Millisecond)
+	if limit := srv.health.ReplicaMaxLag; limit > 0 && lag > limit {
+		return check{Detail: fmt.Sprintf("replica lag %s, limit %s", lag, limit)}
+	}
+	return check{OK: true, Detail: fmt.Sprintf("replica lag %s", lag)}
+}
+
+// writeHealth отвечает 200, если все проверки прошли, иначе 503
+func writeHealth(w http.ResponseWriter, checks map[string]check) {
+	resp := healthResponse{Status: "ok", Checks: checks}
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = "fail"
+		}
+	}
+	if resp.Status != "ok" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,503 @@
+// Package httpserver - REST фронтенд для store.Store поверх net/http.
+//
+//	GET    /keys/{key}          значение ключа, 404 если нет или истёк
+//	PUT    /keys/{key}?ttl=30s  записать тело запроса как значение,
+//	                            priority=low|normal|high - как SetWithPriority
+//	DELETE /keys/{key}          удалить ключ
+//	GET    /keys?limit=&after=  страница ключей, отсортированных по имени,
+//	                            match= оставляет ключи по glob-шаблону как в Keys,
+//	                            prefix= - ключи с префиксом; stream=1 - все ключи
+//	                            после after (limit - сколько, 0 - все) потоком
+//	                            JSON Lines, по элементу страницы на строку
+//	POST   /mget                значения нескольких ключей: тело {"keys": [...]},
+//	                            ответ {"values": {...}} без отсутствующих ключей
+//	POST   /reset               очистить хранилище, ответ {"removed": n}; prefix= или
+//	                            namespace= - только эти ключи, как ResetMatching и
+//	                            ResetNamespace; expect=n и confirm= - подтверждение,
+//	                            см. store.WithResetGuard, без него 412
+//	POST   /cleanup             удалить истекшие ключи, ответ {"removed": n}
+//	GET    /stats               статистика хранилища
+//	GET    /slowlog?n=10        последние n записей медленного журнала, от новых к старым,
+//	                            см. store.WithSlowLog; DELETE /slowlog очищает журнал
+//	GET    /healthz             живость: хранилище не закрыто, очистка по TTL работает;
+//	                            200 или 503 с {"status": ..., "checks": {...}}
+//	GET    /readyz              готовность: то же, свежесть снапшота, запас памяти
+//	                            и отставание реплики, пороги - WithHealth
+//	GET    /snapshot            снапшот хранилища в формате SaveSnapshot,
+//	                            format=jsonl или csv - DumpJSONL или DumpCSV
+//	PUT    /snapshot            загрузить снапшот из тела запроса поверх текущих данных,
+//	                            format=jsonl или csv - LoadJSONL или LoadCSV
+//	POST   /backup?target=      снапшот потоком в цель WithBackupTargets, name= - имя
+//	                            объекта; ответ BackupResult
+//	GET    /events              изменения ключей потоком Server-Sent Events, match= -
+//	                            glob-шаблон ключей, types=expire,delete - только эти события
+//	GET    /debug/keys          отладочная страница ключей, см. NewDebugHandler
+//
+// С WithAuthToken каждый запрос, кроме /healthz и /readyz, должен передавать заголовок
+// "Authorization: Bearer <token>": пробы оркестратора обычно без токена, а ответ проверок
+// не раскрывает данных. WithAuthFunc так же проверяет все запросы, кроме проб.
+// TLS настраивается на http.Server, который обслуживает этот обработчик.
+package httpserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	// defaultSlowLog - сколько записей отдаёт /slowlog без n, как SLOWLOG GET
+	defaultSlowLog = 10
+
+	// streamBatch - сколько ключей stream=1 берёт из хранилища за раз
+	streamBatch = 500
+
+	// maxValueSize - ограничение на тело PUT, что-бы случайный огромный запрос не съел память
+	maxValueSize = 32 << 20
+)
+
+// Option настраивает Server.
+type Option func(*Server)
+
+// WithAuthToken требует bearer-токен в каждом запросе, без него сервер отвечает 401.
+func WithAuthToken(token string) Option {
+	return func(srv *Server) {
+		srv.token = token
+	}
+}
+
+// WithAuthFunc пропускает только запросы, для которых auth вернула true, остальным сервер
+// отвечает 403. Например, проверка сессии админки или адреса клиента. Вместе с WithAuthToken
+// запрос должен пройти обе проверки.
+func WithAuthFunc(auth func(r *http.Request) bool) Option {
+	return func(srv *Server) {
+		srv.auth = auth
+	}
+}
+
+// Server - http.Handler поверх хранилища.
+type Server struct {
+	s     *store.Store
+	mux   *http.ServeMux
+	token string
+	auth  func(r *http.Request) bool // nil - без проверки, см. WithAuthFunc
+
+	backups map[string]BackupTarget // цели POST /backup по имени
+
+	health  HealthConfig
+	started time.Time // начало отсчёта проверок возраста, см. WithHealth
+}
+
+// New создаёт обработчик для хранилища s.
+func New(s *store.Store, opts ...Option) *Server {
+	srv := &Server{
+		s:       s,
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+
+	srv.mux.HandleFunc("GET /keys/{key}", srv.getKey)
+	srv.mux.HandleFunc("PUT /keys/{key}", srv.putKey)
+	srv.mux.HandleFunc("DELETE /keys/{key}", srv.deleteKey)
+	srv.mux.HandleFunc("GET /keys", srv.listKeys)
+	srv.mux.HandleFunc("POST /mget", srv.getMany)
+	srv.mux.HandleFunc("POST /reset", srv.reset)
+	srv.mux.HandleFunc("POST /cleanup", srv.cleanup)
+	srv.mux.HandleFunc("GET /stats", srv.stats)
+	srv.mux.HandleFunc("GET /slowlog", srv.slowLog)
+	srv.mux.HandleFunc("DELETE /slowlog", srv.resetSlowLog)
+	srv.mux.HandleFunc("GET /healthz", srv.healthz)
+	srv.mux.HandleFunc("GET /readyz", srv.readyz)
+	srv.mux.HandleFunc("GET /snapshot", srv.dump)
+	srv.mux.HandleFunc("PUT /snapshot", srv.restore)
+	srv.mux.HandleFunc("POST /backup", srv.backup)
+	srv.mux.HandleFunc("GET /events", srv.events)
+	srv.mux.HandleFunc("GET /debug/keys", srv.debugKeys)
+
+	return srv
+}
+
+// ServeHTTP реализует http.Handler.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !probe(r) && !srv.allowed(w, r) {
+		return
+	}
+	srv.mux.ServeHTTP(w, r)
+}
+
+// allowed проверяет WithAuthToken и WithAuthFunc, при отказе сам отвечает 401 или 403
+func (srv *Server) allowed(w http.ResponseWriter, r *http.Request) bool {
+	if srv.token != "" && !srv.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="store"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if srv.auth != nil && !srv.auth(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// probe - запрос проверки живости или готовности, они доступны без токена
+func probe(r *http.Request) bool {
+	return r.Method == http.MethodGet && (r.URL.Path == "/healthz" || r.URL.Path == "/readyz")
+}
+
+// authorized сравнивает bearer-токен за постоянное время
+func (srv *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(srv.token)) == 1
+}
+
+func (srv *Server) getKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	value, etag, err := srv.s.GetIfNoneMatch(key, matchETag(r.Header.Get("If-None-Match"), srv.s, key))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrNotModified):
+		w.Header().Set("ETag", strconv.Quote(etag))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", strconv.Quote(etag))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	io.WriteString(w, value)
+}
+
+// matchETag возвращает текущий ETag ключа, если он есть в If-None-Match или там "*",
+// иначе пусто. Сравнение слабое, как требует RFC 9110 для If-None-Match, поэтому префикс
+// W/ отбрасывается
+func matchETag(header string, s *store.Store, key string) string {
+	if header == "" {
+		return ""
+	}
+	current, ok := s.ETag(key)
+	if !ok {
+		return ""
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if unq, err := strconv.Unquote(tag); err == nil {
+			tag = unq
+		}
+		if tag == "*" || tag == current {
+			return current
+		}
+	}
+	return ""
+}
+
+func (srv *Server) putKey(w http.ResponseWriter, r *http.Request) {
+	var ttl time.Duration
+	if raw := r.URL.Query().Get("ttl"); raw != "" {
+		var err error
+		ttl, err = time.ParseDuration(raw)
+		if err != nil {
+			http.Error(w, "invalid ttl: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	priority, err := store.ParsePriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueSize))
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := srv.s.SetWithPriority(r.PathValue("key"), string(body), ttl, priority); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, store.ErrReadOnly) || errors.Is(err, store.ErrClosed) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) deleteKey(w http.ResponseWriter, r *http.Request) {
+	srv.s.Delete(r.PathValue("key"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listItem - элемент страницы GET /keys
+type listItem struct {
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Views     uint64     `json:"views"`
+	Size      int64      `json:"size"`
+}
+
+// listPage - ответ GET /keys, Next передаётся в after для следующей страницы
+type listPage struct {
+	Items []listItem `json:"items"`
+	Next  string     `json:"next,omitempty"`
+}
+
+func (srv *Server) listKeys(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("stream") == "1" {
+		srv.streamKeys(w, r)
+		return
+	}
+	limit := defaultPageLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxPageLimit)
+	}
+	after := r.URL.Query().Get("after")
+	prefix := r.URL.Query().Get("prefix")
+
+	all := srv.s.FullList()
+	var keys []string
+	if match := r.URL.Query().Get("match"); match != "" {
+		for _, k := range srv.s.Keys(match) {
+			if _, ok := all[k]; ok && k > after && strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+	} else {
+		keys = make([]string, 0, len(all))
+		for k := range all {
+			if k > after && strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+	}
+
+	page := listPage{Items: make([]listItem, 0, min(limit, len(keys)))}
+	for _, k := range keys {
+		if len(page.Items) == limit {
+			page.Next = page.Items[len(page.Items)-1].Key
+			break
+		}
+		page.Items = append(page.Items, listItemOf(k, all[k]))
+	}
+
+	writeJSON(w, page)
+}
+
+func listItemOf(key string, dto store.ItemDTO) listItem {
+	item := listItem{Key: key, Value: dto.Value, Views: dto.Views, Size: dto.Size}
+	if !dto.ExpiresAt.IsZero() {
+		item.ExpiresAt = &dto.ExpiresAt
+	}
+	return item
+}
+
+// streamKeys - GET /keys?stream=1: ключи читаются из хранилища пачками через store.List и
+// отправляются по мере чтения, так что большой список не собирается в один ответ. Ключ,
+// записанный во время обхода, может не попасть в поток
+func (srv *Server) streamKeys(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 0
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	after := q.Get("after")
+
+	w.Header().Set("Content-Type", "application/jsonl")
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	opts := store.ListOptions{
+		Prefix: q.Get("prefix"),
+		Limit:  streamBatch,
+		Fields: store.ListValue | store.ListTimes | store.ListViews | store.ListSize,
+	}
+	sent := 0
+	for r.Context().Err() == nil {
+		page, err := srv.s.List(opts)
+		if err != nil {
+			return // заголовки уже отправлены, клиент увидит обрезанный поток
+		}
+		for _, e := range page.Items {
+			if e.Key <= after {
+				continue
+			}
+			if limit > 0 && sent == limit {
+				return
+			}
+			if err := enc.Encode(listItemOf(e.Key, e.ItemDTO)); err != nil {
+				return
+			}
+			sent++
+		}
+		rc.Flush()
+		if page.Next == "" {
+			return
+		}
+		opts.Cursor = page.Next
+	}
+}
+
+// getMany - POST /mget, значения читаются как GET /keys/{key}
+func (srv *Server) getMany(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxValueSize)).Decode(&req); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Keys) > maxPageLimit {
+		http.Error(w, "too many keys, max "+strconv.Itoa(maxPageLimit), http.StatusBadRequest)
+		return
+	}
+
+	values := make(map[string]string, len(req.Keys))
+	for _, k := range req.Keys {
+		if value, ok := srv.s.Get(k); ok {
+			values[k] = value
+		}
+	}
+	writeJSON(w, map[string]map[string]string{"values": values})
+}
+
+func (srv *Server) reset(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var opts []store.ResetOption
+	if raw := q.Get("expect"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid expect", http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, store.WithResetExpectedSize(n))
+	}
+	if token := q.Get("confirm"); token != "" {
+		opts = append(opts, store.WithResetConfirm(token))
+	}
+
+	var removed int
+	var err error
+	switch {
+	case q.Has("namespace"):
+		removed, err = srv.s.ResetNamespace(q.Get("namespace"), opts...)
+	case q.Get("prefix") != "":
+		removed, err = srv.s.ResetMatching(q.Get("prefix"), opts...)
+	default:
+		removed, err = srv.s.ResetAll(opts...)
+	}
+	switch {
+	case errors.Is(err, store.ErrResetRefused):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	case errors.Is(err, store.ErrClosed):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]int{"removed": removed})
+}
+
+func (srv *Server) cleanup(w http.ResponseWriter, r *http.Request) {
+	removed, err := srv.s.CleanupNow(r.Context())
+	if err != nil {
+		http.Error(w, "cleanup: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]int{"removed": removed})
+}
+
+func (srv *Server) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, srv.s.Stats())
+}
+
+func (srv *Server) slowLog(w http.ResponseWriter, r *http.Request) {
+	n := defaultSlowLog
+	if raw := r.URL.Query().Get("n"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+		n = v
+	}
+	entries := srv.s.SlowLog(n)
+	if entries == nil {
+		entries = []store.SlowEntry{}
+	}
+	writeJSON(w, entries)
+}
+
+func (srv *Server) resetSlowLog(w http.ResponseWriter, r *http.Request) {
+	srv.s.ResetSlowLog()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) dump(w http.ResponseWriter, r *http.Request) {
+	// заголовки уже отправлены, так что ошибку записи клиент увидит как обрезанное тело
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		srv.s.SaveSnapshot(w)
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/jsonl")
+		srv.s.DumpJSONL(w)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		srv.s.DumpCSV(w)
+	default:
+		http.Error(w, "unknown format", http.StatusBadRequest)
+	}
+}
+
+func (srv *Server) restore(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		err = srv.s.LoadSnapshot(r.Body)
+	case "jsonl":
+		err = srv.s.LoadJSONL(r.Body)
+	case "csv":
+		err = srv.s.LoadCSV(r.Body)
+	default:
+		http.Error(w, "unknown format", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "load snapshot: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
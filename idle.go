@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// WithMaxIdle удаляет ключи, к которым не обращались дольше d, независимо от TTL: так
+// заброшенные записи с длинным TTL не копятся. Обращение - чтение (LastAccessedAt в AccessInfo)
+// или запись значения. Ключи удаляет очистка (Cleanup, CleanupNow) событием EventEvict,
+// закреплённые Pin ключи не трогаются. 0 - выключено.
+func WithMaxIdle(d time.Duration) Option {
+	return func(c *config) {
+		c.maxIdle = d
+	}
+}
+
+// idleAt - время последнего обращения к элементу: чтения или записи
+func (it *Item) idleAt() time.Time {
+	if at := it.lastAccessed(); at.After(it.UpdatedAt) {
+		return at
+	}
+	return it.UpdatedAt
+}
+
+// pruneIdle удаляет ключи без обращений дольше WithMaxIdle, возвращает их число
+func (s *Store) pruneIdle(ctx context.Context, now time.Time) int {
+	if s.cfg.maxIdle <= 0 {
+		return 0
+	}
+	deadline := now.Add(-s.cfg.maxIdle)
+
+	var idle []string
+	s.mu.RLock()
+	for k, item := range s.data {
+		if !s.pinnedLocked(k) && item.idleAt().Before(deadline) {
+			idle = append(idle, k)
+		}
+	}
+	s.mu.RUnlock()
+	if len(idle) == 0 || ctx.Err() != nil {
+		return 0
+	}
+
+	removed := 0
+	s.mu.Lock()
+	for _, k := range idle {
+		// ключ могли прочитать или перезаписать между RUnlock и Lock
+		if item, ok := s.data[k]; ok && item.idleAt().Before(deadline) && !s.pinnedLocked(k) {
+			s.removeLocked(k, EventEvict)
+			removed++
+		}
+	}
+	s.mu.Unlock()
+	s.stats.idleRemoved.Add(uint64(removed))
+	s.cfg.logger.Debug("store: idle keys removed", "removed", removed, "maxIdle", s.cfg.maxIdle)
+	return removed
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Info - сводка о хранилище для диагностики, см. Store.Info.
+type Info struct {
+	Engine string `json:"engine"` // движок чтений, см. WithEngine
+
+	// Shards - число шардов, ShardEntries - ключей в каждом. Хранилище держит ключи в одной
+	// мапе под одной блокировкой, так что шард один и ShardEntries[0] == Entries.
+	Shards       int   `json:"shards"`
+	ShardEntries []int `json:"shardEntries"`
+
+	Entries     int   `json:"entries"`
+	Pinned      int   `json:"pinned"`
+	MemoryBytes int64 `json:"memoryBytes"`           // примерный объём данных
+	MemoryLimit int64 `json:"memoryLimit,omitempty"` // WithMaxMemory, 0 - без лимита
+
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	HitRatio  float64 `json:"hitRatio"` // Hits / (Hits + Misses), 0 - чтений не было
+	Expired   uint64  `json:"expired"`
+	Evictions uint64  `json:"evictions"`
+
+	Cleanup CleanupInfo `json:"cleanup"`
+
+	Namespaces map[string]int `json:"namespaces,omitempty"` // ключей в пространствах имён
+	Watchers   int            `json:"watchers"`             // подписок Watch, WatchPrefix и Subscribe
+
+	Started  time.Time     `json:"started"` // создание хранилища
+	Uptime   time.Duration `json:"uptime"`
+	ReadOnly bool          `json:"readOnly"`
+	Closed   bool          `json:"closed"`
+}
+
+// CleanupInfo - работа очистки истекших ключей: Cleanup, RunCleanup и CleanupNow.
+type CleanupInfo struct {
+	Passes      uint64        `json:"passes"`              // проходов с создания хранилища
+	Last        time.Time     `json:"last,omitzero"`       // конец последнего прохода, нулевое - проходов не было
+	LastRemoved int           `json:"lastRemoved"`         // удалено последним проходом
+	LastTook    time.Duration `json:"lastTook"`            // длительность последнего прохода
+	Incremental bool          `json:"incremental"`         // WithIncrementalCleanup
+	Scheduled   int           `json:"scheduled,omitempty"` // отложенных SetAt ждут активации
+}
+
+// janitorStats - счетчики проходов очистки, пишутся в конце каждого прохода
+type janitorStats struct {
+	passes  atomic.Uint64
+	last    atomic.Int64 // конец последнего прохода в UnixNano
+	removed atomic.Int64
+	took    atomic.Int64
+}
+
+func (j *janitorStats) record(removed int, start, end time.Time) {
+	j.removed.Store(int64(removed))
+	j.took.Store(int64(end.Sub(start)))
+	j.last.Store(end.UnixNano())
+	j.passes.Add(1)
+}
+
+// Info собирает в одном вызове то, что при разборе проблем иначе смотрят по частям: движок,
+// распределение ключей, память, долю попаданий, работу очистки и время работы. Дешевле Stats:
+// без гистограмм задержек и статистики префиксов.
+func (s *Store) Info() Info {
+	s.mu.RLock()
+	size, mem, pinned := len(s.data), s.memUsed, len(s.pins)
+	var namespaces map[string]int
+	if len(s.namespaces) > 0 {
+		namespaces = make(map[string]int, len(s.namespaces))
+		for _, ns := range s.namespaces {
+			namespaces[ns.name] = len(ns.members)
+		}
+	}
+	s.mu.RUnlock()
+
+	hits, misses := s.stats.hits.Load(), s.stats.misses.Load()
+	var ratio float64
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	now := s.now()
+
+	return Info{
+		Engine:       s.cfg.engine.String(),
+		Shards:       1,
+		ShardEntries: []int{size},
+
+		Entries:     size,
+		Pinned:      pinned,
+		MemoryBytes: mem,
+		MemoryLimit: s.cfg.maxMemory,
+
+		Hits:      hits,
+		Misses:    misses,
+		HitRatio:  ratio,
+		Expired:   s.stats.expired.Load(),
+		Evictions: s.stats.evictions.Load(),
+
+		Cleanup: CleanupInfo{
+			Passes:      s.janitor.passes.Load(),
+			Last:        unixTime(s.janitor.last.Load()),
+			LastRemoved: int(s.janitor.removed.Load()),
+			LastTook:    time.Duration(s.janitor.took.Load()),
+			Incremental: s.cfg.cleanupBatch > 0,
+			Scheduled:   int(s.sched.n.Load()),
+		},
+
+		Namespaces: namespaces,
+		Watchers:   int(s.watchers.n.Load()),
+
+		Started:  s.started,
+		Uptime:   now.Sub(s.started),
+		ReadOnly: s.ReadOnly(),
+		Closed:   s.Closed(),
+	}
+}
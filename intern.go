@@ -0,0 +1,78 @@
+package store
+
+// WithValueInterning включает интернирование строковых значений не длиннее maxLen байт:
+// одинаковые значения разных ключей хранятся одной строкой. Полезно, когда значения берутся
+// из небольшого набора (статусы, флаги, повторяющиеся JSON-документы). Таблица считает
+// ссылки: значение уходит из неё, когда его не хранит ни один ключ, так что удаления и
+// перезаписи не оставляют мусора. Коллекции не интернируются.
+//
+// Объём хранилища для WithMaxMemory считается без учёта интернирования, сэкономленные
+// байты видны в Stats.InternSaved.
+func WithValueInterning(maxLen int) Option {
+	return func(c *config) {
+		c.internMax = maxLen
+	}
+}
+
+// internEntry - общая строка значения и число элементов, которые её хранят
+type internEntry struct {
+	value string
+	refs  int
+}
+
+// internTable - таблица общих значений для WithValueInterning, под s.mu
+type internTable struct {
+	maxLen  int
+	entries map[string]*internEntry
+	saved   int64 // байт, которые не пришлось хранить повторно
+}
+
+func newInternTable(maxLen int) *internTable {
+	return &internTable{maxLen: maxLen, entries: make(map[string]*internEntry)}
+}
+
+func (t *internTable) eligible(it *Item) bool {
+	return it.kind == kindString && it.Value != "" && len(it.Value) <= t.maxLen
+}
+
+// internLocked заменяет значение ещё не опубликованного элемента общей строкой
+func (s *Store) internLocked(it *Item) {
+	t := s.interned
+	if t == nil || !t.eligible(it) {
+		return
+	}
+	e, ok := t.entries[it.Value]
+	if !ok {
+		e = &internEntry{value: it.Value}
+		t.entries[it.Value] = e
+	} else {
+		t.saved += int64(len(e.value))
+	}
+	e.refs++
+	it.Value = e.value
+}
+
+// releaseInternLocked снимает ссылку элемента, который уходит из мапы
+func (s *Store) releaseInternLocked(it *Item) {
+	t := s.interned
+	if t == nil || !t.eligible(it) {
+		return
+	}
+	e, ok := t.entries[it.Value]
+	if !ok {
+		return // каждый элемент мапы проходит через internLocked, сюда не доходит
+	}
+	if e.refs--; e.refs == 0 {
+		delete(t.entries, it.Value)
+	} else {
+		t.saved -= int64(len(e.value))
+	}
+}
+
+// internStats - число общих значений и сэкономленные байты, вызывается под s.mu
+func (s *Store) internStatsLocked() (int, int64) {
+	if s.interned == nil {
+		return 0, 0
+	}
+	return len(s.interned.entries), s.interned.saved
+}
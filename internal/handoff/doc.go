@@ -0,0 +1,39 @@
+// Package handoff - передача работающего кеша новому процессу при деплое без холодного старта.
+//
+// Старый процесс слушает unix-сокет (Listen). Новый процесс при старте подключается к нему
+// (Request), и дальше работает протокол переключения:
+//
+//  1. новый процесс шлёт запрос "HANDOFF 1";
+//  2. старый останавливает приём запросов на своих листенерах, но не закрывает сами сокеты:
+//     дубликаты их файловых дескрипторов вместе со списком имён уходят новому процессу
+//     через SCM_RIGHTS, а новые подключения в это время копятся в backlog ядра;
+//  3. старый пишет снапшот хранилища и закрывает свою сторону на запись;
+//  4. новый загружает снапшот, подтверждает "OK" и начинает обслуживать полученные сокеты;
+//  5. старый, получив подтверждение, завершается, не сохраняя снапшот в файл.
+//
+// Порты не освобождаются ни на мгновение, поэтому клиенты не видят connection refused,
+// а после переключения кеш уже прогрет. Если новый процесс не подтвердил приём,
+// старый завершается как при обычной остановке, со снапшотом в файл.
+//
+// Поддерживаются только unix-платформы, на остальных функции возвращают ErrUnsupported.
+package handoff
+
+import "errors"
+
+var (
+	// ErrUnsupported - платформа не поддерживает передачу дескрипторов.
+	ErrUnsupported = errors.New("handoff: not supported on this platform")
+	// ErrNoPeer - по сокету никто не слушает, передавать кеш некому.
+	ErrNoPeer = errors.New("handoff: no running process to take over from")
+)
+
+// protocolHello - запрос нового процесса, версия нужна на случай смены формата
+const protocolHello = "HANDOFF 1\n"
+
+// protocolAck - подтверждение, что снапшот загружен и сокеты приняты
+const protocolAck = "OK\n"
+
+// header - описание передаваемых дескрипторов, порядок Listeners совпадает с порядком fd
+type header struct {
+	Listeners []string `json:"listeners"`
+}
@@ -0,0 +1,37 @@
+//go:build !unix
+
+package handoff
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+)
+
+// Listener на этой платформе недоступен.
+type Listener struct{}
+
+// Listen всегда возвращает ErrUnsupported.
+func Listen(path string) (*Listener, error) { return nil, ErrUnsupported }
+
+func (l *Listener) Accept(ctx context.Context) (*Offer, error) { return nil, ErrUnsupported }
+func (l *Listener) Close() error                               { return nil }
+
+// Offer на этой платформе недоступен.
+type Offer struct{}
+
+func (o *Offer) Send(files map[string]*os.File, snapshot func(io.Writer) error) error {
+	return ErrUnsupported
+}
+
+// Takeover на этой платформе недоступен.
+type Takeover struct{}
+
+// Request всегда возвращает ErrUnsupported.
+func Request(ctx context.Context, path string) (*Takeover, error) { return nil, ErrUnsupported }
+
+func (t *Takeover) Listeners() map[string]net.Listener { return nil }
+func (t *Takeover) Snapshot() io.Reader                { return nil }
+func (t *Takeover) Ack() error                         { return ErrUnsupported }
+func (t *Takeover) Abort()                             {}
@@ -0,0 +1,258 @@
+//go:build unix
+
+package handoff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// maxHeader - ограничение на размер заголовка с именами листенеров
+const maxHeader = 64 << 10
+
+// Listener принимает запросы на передачу от новых процессов.
+type Listener struct {
+	ln *net.UnixListener
+}
+
+// Listen слушает unix-сокет path. Оставшийся от упавшего процесса файл сокета удаляется.
+func Listen(path string) (*Listener, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{ln: ln}, nil
+}
+
+// Accept ждёт запрос на передачу. После отмены ctx возвращает ctx.Err().
+func (l *Listener) Accept(ctx context.Context) (*Offer, error) {
+	stop := context.AfterFunc(ctx, func() { l.ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := l.ln.AcceptUnix()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+
+		hello := make([]byte, len(protocolHello))
+		if _, err := io.ReadFull(conn, hello); err != nil || string(hello) != protocolHello {
+			conn.Close() // не наш клиент, ждём дальше
+			continue
+		}
+		return &Offer{conn: conn}, nil
+	}
+}
+
+// Close перестаёт принимать запросы и удаляет файл сокета.
+func (l *Listener) Close() error {
+	return l.ln.Close()
+}
+
+// Offer - принятый запрос на стороне старого процесса.
+type Offer struct {
+	conn *net.UnixConn
+}
+
+// Send передаёт листенеры (имя -> дубликат дескриптора) и снапшот, который пишет snapshot,
+// затем ждёт подтверждения нового процесса. nil означает, что новый процесс всё принял
+// и старому пора завершаться. Файлы можно закрыть сразу после возврата.
+func (o *Offer) Send(files map[string]*os.File, snapshot func(io.Writer) error) error {
+	defer o.conn.Close()
+
+	var (
+		h   header
+		fds []int
+	)
+	for name, f := range files {
+		h.Listeners = append(h.Listeners, name)
+		fds = append(fds, int(f.Fd()))
+	}
+	body, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	msg := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	msg = append(msg, body...)
+	var oob []byte
+	if len(fds) > 0 {
+		oob = syscall.UnixRights(fds...)
+	}
+	if _, _, err := o.conn.WriteMsgUnix(msg, oob, nil); err != nil {
+		return fmt.Errorf("handoff: send listeners: %w", err)
+	}
+
+	w := bufio.NewWriter(o.conn)
+	if err := snapshot(w); err != nil {
+		return fmt.Errorf("handoff: send snapshot: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("handoff: send snapshot: %w", err)
+	}
+	if err := o.conn.CloseWrite(); err != nil {
+		return err
+	}
+
+	ack := make([]byte, len(protocolAck))
+	if _, err := io.ReadFull(o.conn, ack); err != nil || string(ack) != protocolAck {
+		return fmt.Errorf("handoff: new process did not confirm takeover: %v", err)
+	}
+	return nil
+}
+
+// Takeover - принятое состояние на стороне нового процесса.
+type Takeover struct {
+	conn      *net.UnixConn
+	listeners map[string]net.Listener
+	snapshot  io.Reader
+}
+
+// Request подключается к старому процессу по сокету path и запрашивает передачу.
+// Если никто не слушает, возвращает ErrNoPeer.
+func Request(ctx context.Context, path string) (*Takeover, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, ErrNoPeer
+		}
+		return nil, err
+	}
+	conn := c.(*net.UnixConn)
+
+	t, err := takeover(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func takeover(conn *net.UnixConn) (*Takeover, error) {
+	if _, err := io.WriteString(conn, protocolHello); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, maxHeader)
+	oob := make([]byte, syscall.CmsgSpace(64*4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, fmt.Errorf("handoff: read listeners: %w", err)
+	}
+	fds, err := parseRights(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*os.File, len(fds))
+	for i, fd := range fds {
+		files[i] = os.NewFile(uintptr(fd), "handoff")
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close() // net.FileListener делает свой дубликат
+		}
+	}()
+
+	// заголовок мог прийти не целиком, а за ним в том же чтении - начало снапшота
+	data := buf[:n]
+	if len(data) < 4 {
+		return nil, fmt.Errorf("handoff: short header")
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	if size > maxHeader-4 {
+		return nil, fmt.Errorf("handoff: header too large")
+	}
+	for len(data) < 4+size {
+		m, err := conn.Read(buf[n:])
+		if err != nil {
+			return nil, fmt.Errorf("handoff: read header: %w", err)
+		}
+		n += m
+		data = buf[:n]
+	}
+
+	var h header
+	if err := json.Unmarshal(data[4:4+size], &h); err != nil {
+		return nil, fmt.Errorf("handoff: decode header: %w", err)
+	}
+	if len(h.Listeners) != len(files) {
+		return nil, fmt.Errorf("handoff: got %d descriptors for %d listeners", len(files), len(h.Listeners))
+	}
+
+	t := &Takeover{
+		conn:      conn,
+		listeners: make(map[string]net.Listener, len(files)),
+		snapshot:  io.MultiReader(bytes.NewReader(data[4+size:]), conn),
+	}
+	for i, name := range h.Listeners {
+		ln, err := net.FileListener(files[i])
+		if err != nil {
+			t.closeListeners()
+			return nil, fmt.Errorf("handoff: listener %s: %w", name, err)
+		}
+		t.listeners[name] = ln
+	}
+	return t, nil
+}
+
+func parseRights(oob []byte) ([]int, error) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, fmt.Errorf("handoff: parse control message: %w", err)
+	}
+	var fds []int
+	for _, m := range msgs {
+		rights, err := syscall.ParseUnixRights(&m)
+		if err != nil {
+			return nil, fmt.Errorf("handoff: parse rights: %w", err)
+		}
+		fds = append(fds, rights...)
+	}
+	return fds, nil
+}
+
+// Listeners возвращает полученные листенеры по именам, как их назвал старый процесс.
+func (t *Takeover) Listeners() map[string]net.Listener {
+	return t.listeners
+}
+
+// Snapshot - поток снапшота от старого процесса, читается до EOF до вызова Ack.
+func (t *Takeover) Snapshot() io.Reader {
+	return t.snapshot
+}
+
+// Ack подтверждает переключение, после него старый процесс завершается.
+func (t *Takeover) Ack() error {
+	defer t.conn.Close()
+	_, err := io.WriteString(t.conn, protocolAck)
+	return err
+}
+
+// Abort отказывается от переключения: листенеры закрываются, старый процесс
+// не получит подтверждение и завершится со снапшотом в файл.
+func (t *Takeover) Abort() {
+	t.closeListeners()
+	t.conn.Close()
+}
+
+func (t *Takeover) closeListeners() {
+	for _, ln := range t.listeners {
+		ln.Close()
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config - конфигурация сервиса, читается из JSON или YAML файла.
+type Config struct {
+	CleanupInterval  Duration `json:"cleanupInterval" yaml:"cleanupInterval"`   // период очистки истекших ключей
+	DefaultTTL       Duration `json:"defaultTTL" yaml:"defaultTTL"`             // TTL для записей без явного TTL, 0 - без истечения
+	MaxMemory        ByteSize `json:"maxMemory" yaml:"maxMemory"`               // лимит объёма данных, 0 - без лимита
+	SnapshotPath     string   `json:"snapshotPath" yaml:"snapshotPath"`         // файл снапшота, пусто - без сохранения на диск
+	SnapshotInterval Duration `json:"snapshotInterval" yaml:"snapshotInterval"` // период сохранения снапшота, 0 - только при остановке
+	MetricsAddr      string   `json:"metricsAddr" yaml:"metricsAddr"`           // адрес для /debug/vars и /metrics, пусто - не слушаем
+	HTTPAddr         string   `json:"httpAddr" yaml:"httpAddr"`                 // адрес REST API, пусто - не слушаем
+	RESPAddr         string   `json:"respAddr" yaml:"respAddr"`                 // адрес RESP (redis-cli) фронтенда, пусто - не слушаем
+	GRPCAddr         string   `json:"grpcAddr" yaml:"grpcAddr"`                 // адрес gRPC сервиса, пусто - не слушаем
+	ShutdownTimeout  Duration `json:"shutdownTimeout" yaml:"shutdownTimeout"`   // сколько ждём остановки листенеров
+	TLSCertFile      string   `json:"tlsCertFile" yaml:"tlsCertFile"`           // PEM сертификат, вместе с TLSKeyFile включает TLS на всех листенерах
+	TLSKeyFile       string   `json:"tlsKeyFile" yaml:"tlsKeyFile"`             // PEM ключ сертификата
+	AuthToken        string   `json:"authToken" yaml:"authToken"`               // bearer-токен для http, resp, grpc и реплик, пусто - без аутентификации
+	HandoffSocket    string   `json:"handoffSocket" yaml:"handoffSocket"`       // unix-сокет для передачи кеша новому процессу при деплое, пусто - выключено
+	ReplicationAddr  string   `json:"replicationAddr" yaml:"replicationAddr"`   // адрес для реплик, пусто - узел не раздаёт изменения
+	ReplicaOf        string   `json:"replicaOf" yaml:"replicaOf"`               // адрес replicationAddr основного узла, узел становится репликой только для чтения
+
+	// пороги /healthz и /readyz на httpAddr, 0 - проверка выключена, см. httpserver.HealthConfig
+	HealthCleanupMaxAge  Duration `json:"healthCleanupMaxAge" yaml:"healthCleanupMaxAge"`   // давность последнего прохода очистки
+	HealthSnapshotMaxAge Duration `json:"healthSnapshotMaxAge" yaml:"healthSnapshotMaxAge"` // давность последнего снапшота
+	HealthMemoryRatio    float64  `json:"healthMemoryRatio" yaml:"healthMemoryRatio"`       // доля maxMemory, выше - узел не готов
+	HealthReplicaMaxLag  Duration `json:"healthReplicaMaxLag" yaml:"healthReplicaMaxLag"`   // отставание реплики от основного узла
+}
+
+// AuthTokenEnv - переменная окружения с токеном, если его не хочется держать в файле конфигурации.
+const AuthTokenEnv = "STORE_AUTH_TOKEN"
+
+// DefaultConfig - значения, с которыми сервис стартует без файла конфигурации.
+func DefaultConfig() Config {
+	return Config{
+		CleanupInterval: Duration(time.Second),
+		MetricsAddr:     ":9090",
+		HTTPAddr:        ":8080",
+		RESPAddr:        ":6379",
+		GRPCAddr:        ":9000",
+		ShutdownTimeout: Duration(10 * time.Second),
+	}
+}
+
+// LoadConfig читает конфиг из path поверх значений по умолчанию. Формат определяется
+// по расширению: .yaml/.yml - YAML, иначе JSON. Пустой path - только значения по умолчанию.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		cfg.AuthToken = os.Getenv(AuthTokenEnv)
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	default:
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if cfg.AuthToken == "" {
+		cfg.AuthToken = os.Getenv(AuthTokenEnv)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate проверяет значения конфигурации.
+func (c Config) Validate() error {
+	if c.CleanupInterval <= 0 {
+		return fmt.Errorf("config: cleanupInterval must be positive")
+	}
+	if c.DefaultTTL < 0 || c.SnapshotInterval < 0 || c.ShutdownTimeout < 0 ||
+		c.HealthCleanupMaxAge < 0 || c.HealthSnapshotMaxAge < 0 || c.HealthReplicaMaxLag < 0 {
+		return fmt.Errorf("config: durations must not be negative")
+	}
+	if c.MaxMemory < 0 {
+		return fmt.Errorf("config: maxMemory must not be negative")
+	}
+	if c.HealthMemoryRatio < 0 || c.HealthMemoryRatio > 1 {
+		return fmt.Errorf("config: healthMemoryRatio must be between 0 and 1")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("config: tlsCertFile and tlsKeyFile must be set together")
+	}
+	if c.ReplicationAddr != "" && c.ReplicaOf != "" {
+		return fmt.Errorf("config: replicationAddr and replicaOf are mutually exclusive")
+	}
+	return nil
+}
+
+// Duration - time.Duration, который в конфиге пишется строкой вида "1m30s"
+type Duration time.Duration
+
+// UnmarshalJSON принимает строку в формате time.ParseDuration.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10s\": %w", err)
+	}
+	return d.Set(s)
+}
+
+// UnmarshalYAML принимает строку в формате time.ParseDuration.
+func (d *Duration) UnmarshalYAML(n *yaml.Node) error {
+	return d.Set(n.Value)
+}
+
+// MarshalJSON пишет длительность строкой.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Set разбирает строку, вместе со String реализует flag.Value.
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// ByteSize - объём в байтах, в конфиге задаётся числом или строкой с суффиксом: "512KB", "64MB", "1GB"
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// UnmarshalJSON принимает число байт или строку с суффиксом.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("size must be a number or a string like \"64MB\": %w", err)
+	}
+	return b.Set(s)
+}
+
+// UnmarshalYAML принимает число байт или строку с суффиксом.
+func (b *ByteSize) UnmarshalYAML(n *yaml.Node) error {
+	return b.Set(n.Value)
+}
+
+// Set разбирает строку, вместе со String реализует flag.Value.
+func (b *ByteSize) Set(s string) error {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range byteUnits {
+		if rest, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, mult = strings.TrimSpace(rest), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", s)
+	}
+	*b = ByteSize(n * mult)
+	return nil
+}
+
+func (b ByteSize) String() string {
+	return strconv.FormatInt(int64(b), 10)
+}
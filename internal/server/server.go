@@ -0,0 +1,469 @@
+// Package server - сборка сервиса кеша для cmd/stored:
+// хранилище, очистка по TTL, снапшоты, метрики и сетевые фронтенды.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/grpcserver"
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/httpserver"
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/internal/handoff"
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/replication"
+	"github.com/Shk337/test-task-in-memory-cache-golang-senior/respserver"
+)
+
+// listener - сетевой фронтенд, который запускается и останавливается вместе с сервисом.
+// Сокет ln открывает Run (или получает от старого процесса при handoff), serve только обслуживает его
+type listener struct {
+	name     string
+	addr     string
+	ln       net.Listener
+	serve    func(net.Listener) error
+	shutdown func(context.Context) error
+}
+
+// Run поднимает хранилище и листенеры по конфигурации, блокируется до отмены ctx.
+// При остановке листенеры завершаются с таймаутом ShutdownTimeout, затем хранилище закрывается
+// (store.Store.Close) и сохраняется снапшот.
+//
+// С TLSCertFile/TLSKeyFile все листенеры, включая метрики, слушают TLS. AuthToken требуется
+// только на http, resp и grpc: метрики остаются доступны для скрейпа без токена.
+//
+// С ReplicationAddr узел раздаёт свои изменения репликам, с ReplicaOf сам становится репликой
+// только для чтения, см. пакет replication.
+//
+// С HandoffSocket сервис при старте забирает кеш и сокеты у работающего процесса, а сам
+// отдаёт их следующему, см. пакет handoff. После успешной передачи Run возвращает nil
+// без сохранения снапшота в файл.
+func Run(ctx context.Context, cfg Config, logger *slog.Logger) error {
+	var tlsCfg *tls.Config
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("load tls certificate: %w", err)
+		}
+		tlsCfg = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	if cfg.AuthToken != "" && tlsCfg == nil {
+		logger.Warn("auth token is sent in plaintext, configure tlsCertFile and tlsKeyFile")
+	}
+
+	opts := []store.Option{
+		store.WithExpvar("store"),
+		store.WithLogger(logger),
+		store.WithLatencyHistograms(),
+		store.WithDefaultTTL(time.Duration(cfg.DefaultTTL)),
+		store.WithMaxMemory(int64(cfg.MaxMemory)),
+	}
+	var primary *replication.Primary
+	if cfg.ReplicationAddr != "" {
+		primary = replication.NewPrimary(replication.WithToken(cfg.AuthToken))
+		// без DropWhenFull: отброшенное изменение реплики бы молча пропустили. Primary.Write
+		// не ждёт реплик, медленную отключает (она переподключится со снапшотом), так что
+		// очередь разбирается быстро и запись в хранилище её почти не ждёт
+		opts = append(opts, store.WithSink(primary, store.SinkOptions{}))
+	}
+	s, err := store.New(opts...)
+	if err != nil {
+		return err
+	}
+
+	takeover, err := takeOver(ctx, s, cfg, logger)
+	if err != nil {
+		return err
+	}
+	if takeover == nil && cfg.SnapshotPath != "" {
+		err := s.LoadSnapshotFile(cfg.SnapshotPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			logger.Info("no snapshot, starting empty", "path", cfg.SnapshotPath)
+		case err != nil:
+			return err
+		}
+	}
+
+	var replica *replication.Replica
+	if cfg.ReplicaOf != "" {
+		ropts := []replication.Option{replication.WithToken(cfg.AuthToken)}
+		if tlsCfg != nil {
+			ropts = append(ropts, replication.WithTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+		}
+		replica = replication.NewReplica(cfg.ReplicaOf, s, ropts...)
+	}
+
+	listeners := buildListeners(s, cfg, tlsCfg, replica)
+	if primary != nil {
+		listeners = append(listeners, &listener{
+			name: "replication",
+			addr: cfg.ReplicationAddr,
+			serve: func(ln net.Listener) error {
+				if tlsCfg != nil {
+					ln = tls.NewListener(ln, tlsCfg)
+				}
+				return primary.Serve(s, ln)
+			},
+			shutdown: primary.Close,
+		})
+	}
+	if err := bindListeners(listeners, takeover, logger); err != nil {
+		if takeover != nil {
+			takeover.Abort()
+		}
+		return err
+	}
+	if takeover != nil {
+		if err := takeover.Ack(); err != nil {
+			closeListeners(listeners)
+			return fmt.Errorf("handoff: confirm takeover: %w", err)
+		}
+		logger.Info("took over from previous process", "keys", s.Size())
+	}
+
+	var offers <-chan *handoff.Offer
+	if cfg.HandoffSocket != "" {
+		hl, err := handoff.Listen(cfg.HandoffSocket)
+		if err != nil {
+			closeListeners(listeners)
+			return fmt.Errorf("handoff: %w", err)
+		}
+		defer hl.Close()
+		offers = acceptOffer(ctx, hl, logger)
+	}
+
+	runCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Cleanup(runCtx, time.NewTicker(time.Duration(cfg.CleanupInterval)))
+	}()
+
+	if replica != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info("replicating", "primary", cfg.ReplicaOf)
+			if err := replica.Run(runCtx); !errors.Is(err, context.Canceled) {
+				logger.Error("replication stopped", "err", err)
+			}
+		}()
+	}
+
+	if cfg.SnapshotPath != "" && cfg.SnapshotInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			saveSnapshots(runCtx, s, cfg, logger)
+		}()
+	}
+
+	errc := make(chan error, len(listeners))
+	for _, l := range listeners {
+		logger.Info("listening", "listener", l.name, "addr", l.ln.Addr().String())
+		go func() {
+			err := l.serve(l.ln)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, respserver.ErrServerClosed) &&
+				!errors.Is(err, replication.ErrPrimaryClosed) {
+				errc <- err
+			}
+		}()
+	}
+
+	var (
+		runErr error
+		offer  *handoff.Offer
+	)
+	select {
+	case <-ctx.Done():
+		logger.Info("shutting down")
+	case runErr = <-errc:
+		logger.Error("listener failed, shutting down", "err", runErr)
+	case offer = <-offers:
+		logger.Info("handing off to new process")
+	}
+
+	// дубликаты сокетов снимаем до остановки: Shutdown закрывает свои дескрипторы,
+	// а сокет остаётся жить в дубликате, и новые подключения ждут в backlog
+	var files map[string]*os.File
+	if offer != nil {
+		files = listenerFiles(listeners, logger)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	for _, l := range listeners {
+		if err := l.shutdown(shutdownCtx); err != nil {
+			logger.Error("shutdown listener", "listener", l.name, "err", err)
+		}
+	}
+
+	stopBackground()
+	wg.Wait()
+
+	// после Close записей нет, так снапшот ниже ничего не теряет
+	if err := s.Close(shutdownCtx); err != nil {
+		logger.Error("close store", "err", err)
+	}
+
+	if offer != nil {
+		err := offer.Send(files, s.SaveSnapshot)
+		for _, f := range files {
+			f.Close()
+		}
+		if err == nil {
+			logger.Info("handoff complete, server stopped")
+			return nil
+		}
+		logger.Error("handoff failed, stopping normally", "err", err)
+	}
+
+	if cfg.SnapshotPath != "" {
+		if err := s.SaveSnapshotFile(cfg.SnapshotPath); err != nil {
+			logger.Error("save snapshot on shutdown", "err", err)
+			return errors.Join(runErr, err)
+		}
+	}
+
+	logger.Info("server stopped")
+	return runErr
+}
+
+// takeOver забирает кеш у работающего процесса, если он есть. nil без ошибки - передачи не было
+func takeOver(ctx context.Context, s *store.Store, cfg Config, logger *slog.Logger) (*handoff.Takeover, error) {
+	if cfg.HandoffSocket == "" {
+		return nil, nil
+	}
+
+	t, err := handoff.Request(ctx, cfg.HandoffSocket)
+	switch {
+	case errors.Is(err, handoff.ErrNoPeer):
+		return nil, nil
+	case errors.Is(err, handoff.ErrUnsupported):
+		logger.Warn("handoff is not supported on this platform, starting cold")
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("handoff: %w", err)
+	}
+
+	if err := s.LoadSnapshot(t.Snapshot()); err != nil {
+		t.Abort()
+		return nil, fmt.Errorf("handoff: %w", err)
+	}
+	return t, nil
+}
+
+// acceptOffer ждёт один запрос на передачу в фоне
+func acceptOffer(ctx context.Context, hl *handoff.Listener, logger *slog.Logger) <-chan *handoff.Offer {
+	offers := make(chan *handoff.Offer, 1)
+	go func() {
+		offer, err := hl.Accept(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("handoff socket failed", "err", err)
+			}
+			return
+		}
+		hl.Close() // следующий процесс будет слушать этот путь сам
+		offers <- offer
+	}()
+	return offers
+}
+
+// buildListeners описывает включенные в конфиге фронтенды, сокеты открывает bindListeners.
+// replica - реплика узла для /readyz, nil - узел не реплика
+func buildListeners(s *store.Store, cfg Config, tlsCfg *tls.Config, replica *replication.Replica) []*listener {
+	var listeners []*listener
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /debug/vars", expvar.Handler())
+		mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			s.WritePrometheus(w)
+		})
+		srv := &http.Server{Handler: mux, TLSConfig: tlsCfg}
+		listeners = append(listeners, &listener{
+			name:     "metrics",
+			addr:     cfg.MetricsAddr,
+			serve:    serveHTTP(srv),
+			shutdown: srv.Shutdown,
+		})
+	}
+
+	if cfg.HTTPAddr != "" {
+		var opts []httpserver.Option
+		if cfg.AuthToken != "" {
+			opts = append(opts, httpserver.WithAuthToken(cfg.AuthToken))
+		}
+		health := httpserver.HealthConfig{
+			CleanupMaxAge:  time.Duration(cfg.HealthCleanupMaxAge),
+			SnapshotMaxAge: time.Duration(cfg.HealthSnapshotMaxAge),
+			MemoryMaxRatio: cfg.HealthMemoryRatio,
+			ReplicaMaxLag:  time.Duration(cfg.HealthReplicaMaxLag),
+		}
+		if replica != nil {
+			health.Replica = replica // nil *Replica в интерфейсе был бы не nil
+		}
+		opts = append(opts, httpserver.WithHealth(health))
+		srv := &http.Server{Handler: httpserver.New(s, opts...), TLSConfig: tlsCfg}
+		listeners = append(listeners, &listener{
+			name:     "http",
+			addr:     cfg.HTTPAddr,
+			serve:    serveHTTP(srv),
+			shutdown: srv.Shutdown,
+		})
+	}
+
+	if cfg.RESPAddr != "" {
+		var opts []respserver.Option
+		if cfg.AuthToken != "" {
+			opts = append(opts, respserver.WithAuth(cfg.AuthToken))
+		}
+		srv := respserver.New(s, opts...)
+		listeners = append(listeners, &listener{
+			name: "resp",
+			addr: cfg.RESPAddr,
+			serve: func(ln net.Listener) error {
+				if tlsCfg != nil {
+					ln = tls.NewListener(ln, tlsCfg)
+				}
+				return srv.Serve(ln)
+			},
+			shutdown: srv.Shutdown,
+		})
+	}
+
+	if cfg.GRPCAddr != "" {
+		var opts []grpc.ServerOption
+		if cfg.AuthToken != "" {
+			opts = append(opts, grpcserver.AuthOptions(cfg.AuthToken)...)
+		}
+		if tlsCfg != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+		}
+		gs := grpc.NewServer(opts...)
+		grpcserver.Register(gs, s)
+		listeners = append(listeners, &listener{
+			name:     "grpc",
+			addr:     cfg.GRPCAddr,
+			serve:    gs.Serve,
+			shutdown: func(ctx context.Context) error { return stopGRPC(ctx, gs) },
+		})
+	}
+	return listeners
+}
+
+// bindListeners открывает сокеты, а при handoff берёт полученные от старого процесса по имени.
+// Лишние полученные сокеты (фронтенд выключили в новом конфиге) закрываются
+func bindListeners(listeners []*listener, t *handoff.Takeover, logger *slog.Logger) error {
+	var inherited map[string]net.Listener
+	if t != nil {
+		inherited = t.Listeners()
+	}
+
+	for _, l := range listeners {
+		if ln, ok := inherited[l.name]; ok {
+			l.ln = ln
+			delete(inherited, l.name)
+			continue
+		}
+		ln, err := net.Listen("tcp", l.addr)
+		if err != nil {
+			closeListeners(listeners)
+			return fmt.Errorf("listen %s on %s: %w", l.name, l.addr, err)
+		}
+		l.ln = ln
+	}
+
+	for name, ln := range inherited {
+		logger.Info("closing inherited listener that is not configured", "listener", name)
+		ln.Close()
+	}
+	return nil
+}
+
+func closeListeners(listeners []*listener) {
+	for _, l := range listeners {
+		if l.ln != nil {
+			l.ln.Close()
+		}
+	}
+}
+
+// listenerFiles дублирует дескрипторы сокетов для передачи новому процессу
+func listenerFiles(listeners []*listener, logger *slog.Logger) map[string]*os.File {
+	files := make(map[string]*os.File, len(listeners))
+	for _, l := range listeners {
+		fl, ok := l.ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+		f, err := fl.File()
+		if err != nil {
+			logger.Error("duplicate listener for handoff", "listener", l.name, "err", err)
+			continue
+		}
+		files[l.name] = f
+	}
+	return files
+}
+
+// serveHTTP обслуживает ln, с TLS если у srv есть TLSConfig с сертификатом
+func serveHTTP(srv *http.Server) func(net.Listener) error {
+	return func(ln net.Listener) error {
+		if srv.TLSConfig != nil {
+			return srv.ServeTLS(ln, "", "")
+		}
+		return srv.Serve(ln)
+	}
+}
+
+// saveSnapshots периодически сохраняет снапшот, пока ctx не отменён
+func saveSnapshots(ctx context.Context, s *store.Store, cfg Config, logger *slog.Logger) {
+	ticker := time.NewTicker(time.Duration(cfg.SnapshotInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SaveSnapshotFile(cfg.SnapshotPath); err != nil {
+				logger.Error("save snapshot", "path", cfg.SnapshotPath, "err", err)
+			}
+		}
+	}
+}
+
+// stopGRPC ждёт завершения активных вызовов, а по таймауту ctx обрывает их
+func stopGRPC(ctx context.Context, gs *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		gs.Stop()
+		return ctx.Err()
+	}
+}
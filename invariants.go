@@ -0,0 +1,192 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvariant - внутреннее состояние хранилища противоречиво, см. CheckInvariants.
+var ErrInvariant = errors.New("store: invariant violated")
+
+// debugInvariantInterval - период проверки инвариантов по умолчанию в сборке с тегом storedebug
+const debugInvariantInterval = time.Second
+
+// WithInvariantChecks проверяет инварианты (CheckInvariants) при создании хранилища и затем
+// каждые every в фоне, нарушения пишутся в лог ошибкой. В сборке с тегом storedebug проверка
+// включена по умолчанию раз в секунду, а нарушение - паника, что-бы поломка, внесённая
+// новой функцией, не прошла тесты незамеченной. every < 0 выключает проверку и там.
+// С WithDeterministic фоновой горутины нет: инварианты проверяются при создании и на каждом
+// проходе очистки, в горутине вызывающего.
+//
+// Проверка обходит все ключи под блокировкой на чтение, в рабочей сборке её лучше не включать
+// или ставить редкой.
+func WithInvariantChecks(every time.Duration) Option {
+	return func(c *config) {
+		c.invariantEvery = every
+	}
+}
+
+// CheckInvariants сверяет внутренние структуры хранилища с мапой данных: счётчики размера,
+// памяти, сжатия и приоритетов, индексы WithIndexes, копию EngineSyncMap, кольцо EvictClock,
+// члены пространств имён, закрепления, историю и корзину DeleteSoft, журнал последних ключей.
+// nil - всё сходится, иначе ошибка ErrInvariant со списком расхождений.
+// Проверка держит блокировку на чтение на время обхода всех ключей.
+func (s *Store) CheckInvariants() error {
+	var problems []string
+	check := func(bad bool, format string, args ...any) {
+		if bad {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	s.mu.RLock()
+	n := len(s.data)
+	check(int(s.length.Load()) != n, "size counter %d, map has %d keys", s.length.Load(), n)
+	check(s.peakEntries < n, "peak entries %d below %d keys", s.peakEntries, n)
+
+	var mem, saved int64
+	var prio [priorityLevels]int
+	expiring := 0
+	tagged := make(map[string]int)
+	for key, item := range s.data {
+		mem += item.size
+		saved += item.compressionSaved()
+		prio[item.priority+1]++
+		if !item.ExpiresAt.IsZero() {
+			expiring++
+		}
+		if _, ok := s.tombs[key]; ok {
+			check(true, "key %q is both live and in tombstones", key)
+		}
+		if s.mirror != nil {
+			v, ok := s.mirror.Load(key)
+			check(!ok || v.(*Item) != item, "key %q differs in sync.Map engine copy", key)
+		}
+		if s.ring != nil {
+			_, ok := s.ring.pos[key]
+			check(!ok, "key %q missing from clock ring", key)
+		}
+		if ns := s.nsLocked(key); ns != nil {
+			_, ok := ns.members[key]
+			check(!ok, "key %q missing from namespace %q", key, ns.name)
+		}
+		if s.idx != nil {
+			if s.idx.kinds&IndexExpiry != 0 && !item.ExpiresAt.IsZero() {
+				_, ok := tget(s.idx.expiry, expiryKey{item.ExpiresAt.UnixNano(), key}, cmpExpiryKey)
+				check(!ok, "key %q missing from expiry index", key)
+			}
+			for _, tag := range item.tags {
+				if s.idx.tags == nil {
+					break
+				}
+				_, ok := s.idx.tags[tag][key]
+				check(!ok, "key %q missing from tag index %q", key, tag)
+				tagged[tag]++
+			}
+		}
+	}
+	check(mem != s.memUsed, "memory counter %d, items add up to %d", s.memUsed, mem)
+	check(saved != s.compressionSaved, "compression counter %d, items add up to %d", s.compressionSaved, saved)
+	check(prio != s.priorityCount, "priority counters %v, items add up to %v", s.priorityCount, prio)
+
+	if s.mirror != nil {
+		mirrored := 0
+		s.mirror.Range(func(_, _ any) bool {
+			mirrored++
+			return true
+		})
+		check(mirrored != n, "sync.Map engine copy has %d keys, map has %d", mirrored, n)
+	}
+	if s.ring != nil {
+		check(len(s.ring.keys) != n || len(s.ring.pos) != n, "clock ring has %d keys, map has %d", len(s.ring.keys), n)
+		for i, key := range s.ring.keys {
+			check(s.ring.pos[key] != i, "clock ring position of %q is %d, want %d", key, s.ring.pos[key], i)
+		}
+	}
+	if s.idx != nil {
+		if s.idx.kinds&IndexExpiry != 0 {
+			indexed := 0
+			tascend(s.idx.expiry, expiryKey{at: -1 << 63}, cmpExpiryKey, func(expiryKey, struct{}) bool {
+				indexed++
+				return true
+			})
+			check(indexed != expiring, "expiry index has %d keys, %d keys have a deadline", indexed, expiring)
+		}
+		for tag, keys := range s.idx.tags {
+			check(len(keys) != tagged[tag], "tag index %q has %d keys, %d keys carry the tag", tag, len(keys), tagged[tag])
+		}
+	}
+	for name, ns := range s.namespaces {
+		var nsMem int64
+		for key := range ns.members {
+			item, ok := s.data[key]
+			if !ok {
+				check(true, "namespace %q member %q is not in the map", name, key)
+				continue
+			}
+			nsMem += item.size
+		}
+		check(nsMem != ns.memUsed, "namespace %q memory counter %d, members add up to %d", name, ns.memUsed, nsMem)
+	}
+	for key := range s.pins {
+		_, ok := s.data[key]
+		check(!ok, "pinned key %q is not in the map", key)
+	}
+	for key := range s.history {
+		_, ok := s.data[key]
+		check(!ok, "history of %q outlived the key", key)
+	}
+	s.mu.RUnlock()
+
+	// журнал последних ключей не чистится при удалении, RetrieveLastKey это учитывает,
+	// так что проверяется только само кольцо
+	r := s.recent
+	r.mu.Lock()
+	check(r.n < 0 || r.n > len(r.buf) || r.head < 0 || r.head >= len(r.buf), "recent ring head %d, length %d, capacity %d", r.head, r.n, len(r.buf))
+	for i := 0; i < r.n && i < len(r.buf); i++ {
+		check(r.buf[(r.head+i)%len(r.buf)].key == "", "recent ring entry %d is empty", i)
+	}
+	r.mu.Unlock()
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvariant, strings.Join(problems, "; "))
+}
+
+// runInvariantChecks проверяет инварианты сразу и по тикеру, пока хранилище не закрыто
+func (s *Store) runInvariantChecks(t Ticker) {
+	defer t.Stop()
+	for {
+		s.reportInvariants()
+		select {
+		case <-t.C():
+		case <-s.life.done:
+			return
+		}
+	}
+}
+
+// reportInvariants проверяет инварианты и сообщает о нарушении: паника в сборке storedebug,
+// иначе ошибка в лог
+func (s *Store) reportInvariants() {
+	if err := s.CheckInvariants(); err != nil {
+		if debugBuild {
+			panic(err)
+		}
+		s.cfg.logger.Error("store: invariant check failed", "err", err)
+	}
+}
+
+// invariantInterval - период фоновой проверки инвариантов, 0 - не проверять
+func (c *config) invariantInterval() time.Duration {
+	switch {
+	case c.invariantEvery < 0:
+		return 0
+	case c.invariantEvery == 0 && debugBuild:
+		return debugInvariantInterval
+	}
+	return c.invariantEvery
+}
@@ -0,0 +1,6 @@
+//go:build storedebug
+
+package store
+
+// debugBuild - сборка с тегом storedebug: инварианты проверяются по умолчанию, см. WithInvariantChecks
+const debugBuild = true
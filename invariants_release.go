@@ -0,0 +1,6 @@
+//go:build !storedebug
+
+package store
+
+// debugBuild - сборка с тегом storedebug, см. WithInvariantChecks
+const debugBuild = false
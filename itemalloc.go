@@ -0,0 +1,45 @@
+package store
+
+import "sync"
+
+// WithItemBlocks выделяет элементы не по одному, а блоками по blockSize штук: при постоянной
+// перезаписи миллионов ключей куча получает в blockSize раз меньше объектов, и выделение
+// памяти дешевле. Действует на Set и его варианты, SetNX, SetXX, SetIfVersion и транзакции.
+//
+// Элементы не переиспользуются после удаления: читатели держат элемент и после снятия
+// блокировки (элементы не меняются после записи), так что вернуть его в пул при Delete или
+// истечении нельзя без подсчёта ссылок. Цена - блок живёт, пока жив хотя бы один его элемент,
+// поэтому при долгоживущих вперемешку с короткоживущими ключами память может расти;
+// подбирайте blockSize (порядка 64-256) под нагрузку и смотрите на Stats.MemoryBytes и RSS.
+func WithItemBlocks(blockSize int) Option {
+	return func(c *config) {
+		c.itemBlock = blockSize
+	}
+}
+
+// itemBlocks - блочный аллокатор элементов для WithItemBlocks
+type itemBlocks struct {
+	size int
+
+	mu    sync.Mutex
+	block []Item
+}
+
+func (b *itemBlocks) alloc() *Item {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.block) == 0 {
+		b.block = make([]Item, b.size)
+	}
+	it := &b.block[0]
+	b.block = b.block[1:]
+	return it
+}
+
+// newItem - пустой элемент для записи, из блока с WithItemBlocks
+func (s *Store) newItem() *Item {
+	if s.items == nil {
+		return &Item{}
+	}
+	return s.items.alloc()
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// jsonlRecord - строка DumpJSONL: элемент в формате снапшота с пользовательским ключом
+type jsonlRecord struct {
+	Key string  `json:"key"`
+	TTL float64 `json:"ttl,omitempty"` // секунд до истечения на момент выгрузки
+	snapshotItem
+}
+
+// exportRecord - неистекший ключ для DumpJSONL и DumpCSV
+type exportRecord struct {
+	key  string
+	si   snapshotItem
+	text string
+}
+
+// exportRecords собирает неистекшие ключи текущей версии схемы по возрастанию ключа
+func (s *Store) exportRecords() ([]exportRecord, time.Time) {
+	now := s.now()
+	s.mu.RLock()
+	records := make([]exportRecord, 0, len(s.data))
+	for raw, item := range s.data {
+		if item.expiredAt(now) {
+			continue
+		}
+		key, ok := s.userKey(raw)
+		if !ok {
+			continue // ключ другой версии схемы, см. WithKeyVersion
+		}
+		records = append(records, exportRecord{key: key, si: snapshotItemOf(item), text: item.text()})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(records, func(i, j int) bool { return records[i].key < records[j].key })
+	return records, now
+}
+
+func ttlSeconds(expiresAt, now time.Time) float64 {
+	if expiresAt.IsZero() {
+		return 0
+	}
+	return expiresAt.Sub(now).Seconds()
+}
+
+// DumpJSONL записывает неистекшие ключи в w по объекту JSON на строку (JSON Lines), по
+// возрастанию ключа: поля снапшота (value или содержимое коллекции, kind, expiresAt, views,
+// provenance...) плюс key и ttl - секунды до истечения. В отличие от SaveSnapshot, формат
+// удобно разбирать jq и другими построчными утилитами, а ключи - пользовательские,
+// без префикса версии схемы.
+func (s *Store) DumpJSONL(w io.Writer) error {
+	records, now := s.exportRecords()
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, r := range records {
+		if err := enc.Encode(jsonlRecord{Key: r.key, TTL: ttlSeconds(r.si.ExpiresAt, now), snapshotItem: r.si}); err != nil {
+			return fmt.Errorf("store: dump jsonl: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("store: dump jsonl: %w", err)
+	}
+	return nil
+}
+
+// LoadJSONL загружает ключи из формата DumpJSONL поверх текущих данных. Для фикстур
+// достаточно key и value: ttl задаёт срок от момента загрузки и важнее expiresAt, без обоих
+// ключ не истекает. Строки проверяются политикой ключей, как Set; на первой ошибке загрузка
+// останавливается с номером строки, уже загруженные ключи остаются.
+func (s *Store) LoadJSONL(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var rec jsonlRecord
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("store: load jsonl: line %d: %w", line, err)
+		}
+		if err := s.loadRecord(rec.Key, rec.snapshotItem, rec.TTL); err != nil {
+			return fmt.Errorf("store: load jsonl: line %d: %w", line, err)
+		}
+	}
+}
+
+// csvHeader - колонки DumpCSV и LoadCSV
+var csvHeader = []string{"key", "type", "value", "ttl", "views"}
+
+// DumpCSV записывает неистекшие ключи в w таблицей CSV с заголовком key,type,value,ttl,views
+// для электронных таблиц: value коллекций - JSON, как в Get, ttl - секунды до истечения,
+// пусто - без срока. Метаданные, которых нет в колонках, теряются, полная выгрузка - DumpJSONL.
+func (s *Store) DumpCSV(w io.Writer) error {
+	records, now := s.exportRecords()
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, r := range records {
+		kind := r.si.Kind
+		if kind == "" {
+			kind = kindString.String()
+		}
+		ttl := ""
+		if !r.si.ExpiresAt.IsZero() {
+			ttl = strconv.FormatFloat(ttlSeconds(r.si.ExpiresAt, now), 'f', 3, 64)
+		}
+		cw.Write([]string{r.key, kind, r.text, ttl, strconv.FormatUint(r.si.Views, 10)})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("store: dump csv: %w", err)
+	}
+	return nil
+}
+
+// LoadCSV загружает ключи из таблицы в формате DumpCSV поверх текущих данных. Обязательны
+// колонки key и value, остальные - по желанию и в любом порядке; type по умолчанию string.
+// HyperLogLog из CSV не загружается. Ошибки - как у LoadJSONL, с номером строки.
+func (s *Store) LoadCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("store: load csv: header: %w", err)
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	if _, ok := col["key"]; !ok {
+		return errors.New("store: load csv: no key column")
+	}
+	if _, ok := col["value"]; !ok {
+		return errors.New("store: load csv: no value column")
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("store: load csv: %w", err)
+		}
+		si, ttl, err := csvItem(field(row, "type"), field(row, "value"), field(row, "ttl"), field(row, "views"))
+		if err == nil {
+			err = s.loadRecord(field(row, "key"), si, ttl)
+		}
+		if err != nil {
+			return fmt.Errorf("store: load csv: line %d: %w", line, err)
+		}
+	}
+}
+
+// csvItem разбирает колонки строки CSV в формат снапшота
+func csvItem(kind, value, ttl, views string) (snapshotItem, float64, error) {
+	var si snapshotItem
+	var err error
+	switch kind {
+	case "", "string":
+		si.Value = value
+	case "counter":
+		si.Kind = kind
+		si.Counter, err = strconv.ParseInt(value, 10, 64)
+	case "hash":
+		si.Kind = kind
+		err = json.Unmarshal([]byte(value), &si.Hash)
+	case "list":
+		si.Kind = kind
+		err = json.Unmarshal([]byte(value), &si.List)
+	case "set":
+		si.Kind = kind
+		err = json.Unmarshal([]byte(value), &si.Set)
+	case "zset":
+		si.Kind = kind
+		err = json.Unmarshal([]byte(value), &si.ZSet)
+	default:
+		return si, 0, fmt.Errorf("unsupported type %q", kind)
+	}
+	if err != nil {
+		return si, 0, fmt.Errorf("bad %s value: %w", kind, err)
+	}
+
+	var seconds float64
+	if ttl != "" {
+		if seconds, err = strconv.ParseFloat(ttl, 64); err != nil {
+			return si, 0, fmt.Errorf("bad ttl %q", ttl)
+		}
+	}
+	if views != "" {
+		if si.Views, err = strconv.ParseUint(views, 10, 64); err != nil {
+			return si, 0, fmt.Errorf("bad views %q", views)
+		}
+	}
+	return si, seconds, nil
+}
+
+// loadRecord записывает элемент LoadJSONL или LoadCSV. ttl > 0 - срок от текущего момента,
+// истекшие элементы пропускаются. В приёмник WithSink, как и LoadSnapshot, не пишет
+func (s *Store) loadRecord(key string, si snapshotItem, ttl float64) error {
+	if err := s.enter(); err != nil {
+		return err
+	}
+	defer s.leave()
+
+	if key == "" {
+		return errors.New("empty key")
+	}
+	if err := s.checkWrite(key, 0); err != nil {
+		return err
+	}
+	if err := s.checkValueSize(key, len(si.Value)); err != nil {
+		return err
+	}
+
+	now := s.now()
+	if ttl > 0 {
+		si.ExpiresAt = now.Add(time.Duration(ttl * float64(time.Second)))
+	}
+	if !si.ExpiresAt.IsZero() && now.After(si.ExpiresAt) {
+		return nil
+	}
+	if si.UpdatedAt.IsZero() {
+		si.UpdatedAt = now
+	}
+	item, ok := s.itemFromSnapshot(key, si)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.putLocked(s.wkey(key), item)
+	s.evictLocked(now, "")
+	s.mu.Unlock()
+	return nil
+}
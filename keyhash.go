@@ -0,0 +1,87 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync/atomic"
+)
+
+// hashedKeyPrefix - начало ключа хранения, который заменяет длинный ключ, см. WithKeyHashing
+const hashedKeyPrefix = "#h:"
+
+// WithKeyHashing хранит ключи длиннее n байт под их хешем SHA-256 (128 бит, 35 байт с префиксом):
+// когда ключом служит целый URL или JSON запроса, длинная строка не копируется в индексы,
+// фильтр Блума, журнал последних записей и историю, а сравнения и хеширование мапы не
+// проходят её целиком. Для вызывающего ничего не меняется: операции принимают исходный
+// ключ, а листинги, события Watch и снапшот показывают его же.
+//
+// Исходные ключи хранятся рядом, один раз на ключ, и учитываются в памяти процесса, но не
+// в WithMaxMemory. Исходный ключ запоминают только записи, чтения отсутствующих ключей
+// таблицу не растят; если запись ничего не положила (ErrFull, несовпадение версии), ключ
+// забывается на втором проходе Cleanup. WithMaxKeyLen проверяет исходный ключ, так что
+// вместе с хешированием его задают больше n или не задают. 0 - без хеширования.
+func WithKeyHashing(n int) Option {
+	return func(c *config) {
+		c.keyHashMin = n
+	}
+}
+
+// longKey - исходный ключ в таблице WithKeyHashing
+type longKey struct {
+	key   string
+	stale atomic.Bool // ключа не было в хранилище на прошлом проходе Cleanup
+}
+
+// rememberLongKey запоминает исходный ключ key для ключа хранения raw
+func (s *Store) rememberLongKey(raw, key string) {
+	if v, ok := s.longKeys.Load(raw); ok {
+		v.(*longKey).stale.Store(false)
+		return
+	}
+	s.longKeys.LoadOrStore(raw, &longKey{key: key})
+}
+
+// hashKey заменяет ключ длиннее WithKeyHashing его хешем, long - ключ заменён
+func (s *Store) hashKey(key string) (hashed string, long bool) {
+	if s.cfg.keyHashMin <= 0 || len(key) <= s.cfg.keyHashMin {
+		return key, false
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hashedKeyPrefix + hex.EncodeToString(sum[:16]), true
+}
+
+// unhashKey возвращает исходный ключ для key - пользовательской части ключа хранения raw.
+// Ключи, которые не хешировались, возвращаются как есть
+func (s *Store) unhashKey(raw, key string) string {
+	if s.cfg.keyHashMin <= 0 || !strings.HasPrefix(key, hashedKeyPrefix) {
+		return key
+	}
+	if v, ok := s.longKeys.Load(raw); ok {
+		return v.(*longKey).key
+	}
+	return key
+}
+
+// pruneLongKeys забывает исходные ключи, которых нет в хранилище два прохода Cleanup подряд,
+// вызывается из Cleanup. Один проход не годится: запись могла уже вызвать wkey, но ещё
+// не положить ключ в мапу
+func (s *Store) pruneLongKeys() {
+	if s.cfg.keyHashMin <= 0 {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.longKeys.Range(func(raw, v any) bool {
+		lk := v.(*longKey)
+		switch _, ok := s.data[raw.(string)]; {
+		case ok:
+			lk.stale.Store(false)
+		case lk.stale.Load():
+			s.longKeys.Delete(raw)
+		default:
+			lk.stale.Store(true)
+		}
+		return true
+	})
+}
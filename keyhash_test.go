@@ -0,0 +1,43 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func longKeyCount(s *Store) int {
+	n := 0
+	s.longKeys.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestKeyHashingReadsDoNotRemember(t *testing.T) {
+	s, err := New(WithKeyHashing(40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := strings.Repeat("k", 64)
+	for i := range 100 {
+		s.Get(prefix + strconv.Itoa(i))
+		s.Exists(prefix + strconv.Itoa(i))
+	}
+	if n := longKeyCount(s); n != 0 {
+		t.Fatalf("reads of missing keys remembered %d long keys", n)
+	}
+
+	long := prefix + "-stored"
+	if err := s.Set(long, "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, s, long, "v")
+	if keys := s.Keys("*"); len(keys) != 1 || keys[0] != long {
+		t.Errorf("Keys = %q, want the original long key", keys)
+	}
+	if n := longKeyCount(s); n != 1 {
+		t.Errorf("remembered %d long keys, want 1", n)
+	}
+}
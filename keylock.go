@@ -0,0 +1,45 @@
+package store
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// keyLockStripes - число мьютексов, по которым раскладываются ключи LockKey
+const keyLockStripes = 256
+
+// keyLockSeed - общий для процесса, полосы одного ключа не меняются
+var keyLockSeed = maphash.MakeSeed()
+
+// keyLocks - полосатые мьютексы LockKey: память не растёт с числом ключей, а разные ключи
+// изредка делят полосу
+type keyLocks [keyLockStripes]sync.Mutex
+
+func (l *keyLocks) stripe(key string) *sync.Mutex {
+	return &l[maphash.String(keyLockSeed, key)%keyLockStripes]
+}
+
+// LockKey захватывает мьютекс ключа в этом процессе, что-бы сериализовать сложную внешнюю
+// операцию над ключом (прочитать, сходить в бэкенд, записать) без блокировки всего хранилища.
+// Замок рекомендательный: операции хранилища его не проверяют, договориться должны вызывающие.
+//
+// Ключи раскладываются по 256 мьютексам, поэтому разные ключи иногда ждут друг друга, а
+// захват второго ключа, пока держите первый, может оказаться повторным захватом той же
+// полосы и зависнуть - держите не больше одного ключа за раз. Для замков между процессами
+// есть пакет lock.
+func (s *Store) LockKey(key string) {
+	s.keyLocks.stripe(key).Lock()
+}
+
+// UnlockKey отпускает мьютекс, захваченный LockKey. Как и sync.Mutex, паникует, если ключ не захвачен.
+func (s *Store) UnlockKey(key string) {
+	s.keyLocks.stripe(key).Unlock()
+}
+
+// WithKeyLock выполняет fn под LockKey(key) и возвращает её ошибку. Мьютекс отпускается
+// и при панике fn.
+func (s *Store) WithKeyLock(key string, fn func() error) error {
+	s.LockKey(key)
+	defer s.UnlockKey(key)
+	return fn()
+}
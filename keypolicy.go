@@ -0,0 +1,153 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrInvalidKey - ключ не прошел проверку WithKeyPolicy, WithMaxKeyLen или WithKeyValidator.
+	// Конкретная причина доступна через errors.As(err, *KeyError).
+	ErrInvalidKey = errors.New("store: invalid key")
+	// ErrBlankKey - ключ состоит только из пробельных символов.
+	ErrBlankKey = errors.New("store: blank key")
+	// ErrKeyControlChar - ключ содержит управляющие символы.
+	ErrKeyControlChar = errors.New("store: key contains control characters")
+	// ErrKeyTooLong - ключ длиннее WithMaxKeyLen.
+	ErrKeyTooLong = errors.New("store: key is too long")
+	// ErrValueTooLarge - значение больше WithMaxValueSize.
+	ErrValueTooLarge = errors.New("store: value is too large")
+)
+
+// KeyError - отклоненный ключ и причина. errors.Is(err, ErrInvalidKey) верно для любой причины,
+// errors.Is(err, ErrEmptyKey) и т.п. - для конкретной.
+type KeyError struct {
+	Key    string
+	Reason error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%s %q: %v", ErrInvalidKey, e.Key, e.Reason)
+}
+
+func (e *KeyError) Unwrap() error { return e.Reason }
+
+// Is позволяет проверять любой KeyError через errors.Is(err, ErrInvalidKey).
+func (e *KeyError) Is(target error) bool { return target == ErrInvalidKey }
+
+// KeyPolicy - набор правил для ключей при записи, флаги объединяются через |.
+type KeyPolicy uint8
+
+const (
+	// RejectEmptyKeys отклоняет пустой ключ "".
+	RejectEmptyKeys KeyPolicy = 1 << iota
+	// RejectBlankKeys отклоняет ключи только из пробельных символов, включая пустой.
+	RejectBlankKeys
+	// RejectControlChars отклоняет ключи с управляющими символами (\n, \t, \x00, ...).
+	RejectControlChars
+)
+
+// WithKeyPolicy включает проверку ключей на Set, SetNX, SetXX, SetWithProvenance и IncrBy:
+// такие записи отклоняются с *KeyError независимо от WithStrict, а в режиме StrictPanic вызывают панику.
+// Без политики пустой ключ сохраняется и потом становится мусором, который никто не ищет.
+func WithKeyPolicy(p KeyPolicy) Option {
+	return func(c *config) {
+		c.keyPolicy |= p
+	}
+}
+
+// WithMaxKeyLen ограничивает длину ключа в байтах, 0 - без ограничения.
+// Ограничение применяется к пользовательскому ключу, без префикса WithKeyVersion.
+func WithMaxKeyLen(n int) Option {
+	return func(c *config) {
+		c.maxKeyLen = n
+	}
+}
+
+// WithMaxValueSize ограничивает размер значения в байтах, 0 - без ограничения. Set и
+// SetWithProvenance возвращают ErrValueTooLarge, SetNX и SetXX - false, а для коллекций
+// (HSet, RPush, SetBit, ...) ограничение применяется к размеру коллекции после записи.
+// Защищает от случайно закешированных многомегабайтных ответов.
+func WithMaxValueSize(n int) Option {
+	return func(c *config) {
+		c.maxValueSize = n
+	}
+}
+
+// checkValueSize проверяет размер значения по WithMaxValueSize
+func (s *Store) checkValueSize(key string, size int) error {
+	if s.cfg.maxValueSize <= 0 || size <= s.cfg.maxValueSize {
+		return nil
+	}
+	s.cfg.logger.Debug("store: value rejected", "key", key, "size", size, "max", s.cfg.maxValueSize)
+	if s.cfg.strict == StrictPanic {
+		panic(fmt.Errorf("%w: %d bytes for key %q", ErrValueTooLarge, size, key))
+	}
+	return ErrValueTooLarge
+}
+
+// WithKeyValidator добавляет собственную проверку ключа, которая выполняется после встроенных.
+// Ошибка validate возвращается как причина в *KeyError.
+func WithKeyValidator(validate func(key string) error) Option {
+	return func(c *config) {
+		c.keyValidator = validate
+	}
+}
+
+// WithKeyTransformer приводит ключ к каноническому виду в каждой операции, до проверок
+// WithKeyPolicy и WithKeyValidator: так "User:42", " user:42" и "user:42" от разных команд
+// попадают в один ключ, а не дают тихие промахи. Подходят strings.ToLower и strings.TrimSpace;
+// несколько WithKeyTransformer применяются по порядку.
+//
+// Листинги (Keys, FullList, List, снапшот) и события Watch показывают ключи в каноническом
+// виде, а шаблон Keys и префиксы не преобразуются. Снапшот и репликация применяют
+// transform повторно, поэтому он должен быть идемпотентным: transform(transform(k)) == transform(k).
+// Ключ пространства имён (Namespace) преобразуется вместе с префиксом пространства.
+func WithKeyTransformer(transform func(key string) string) Option {
+	return func(c *config) {
+		if prev := c.keyTransform; prev != nil {
+			c.keyTransform = func(key string) string { return transform(prev(key)) }
+			return
+		}
+		c.keyTransform = transform
+	}
+}
+
+// canonKey - ключ после WithKeyTransformer
+func (s *Store) canonKey(key string) string {
+	if s.cfg.keyTransform == nil {
+		return key
+	}
+	return s.cfg.keyTransform(key)
+}
+
+// checkKey проверяет ключ по настроенной политике
+func (s *Store) checkKey(key string) error {
+	p := s.cfg.keyPolicy
+	key = s.canonKey(key)
+
+	var reason error
+	switch {
+	case key == "" && p&(RejectEmptyKeys|RejectBlankKeys) != 0:
+		reason = ErrEmptyKey
+	case p&RejectBlankKeys != 0 && strings.TrimSpace(key) == "":
+		reason = ErrBlankKey
+	case p&RejectControlChars != 0 && strings.IndexFunc(key, unicode.IsControl) >= 0:
+		reason = ErrKeyControlChar
+	case s.cfg.maxKeyLen > 0 && len(key) > s.cfg.maxKeyLen:
+		reason = ErrKeyTooLong
+	case s.cfg.keyValidator != nil:
+		reason = s.cfg.keyValidator(key)
+	}
+	if reason == nil {
+		return nil
+	}
+
+	err := &KeyError{Key: key, Reason: reason}
+	if s.cfg.strict == StrictPanic {
+		panic(err)
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// keyVersionSep отделяет версию схемы от ключа: "v2:user:42"
+const keyVersionSep = ":"
+
+// Entry - элемент, передаваемый в функцию миграции MigrateKeys.
+type Entry struct {
+	Key       string
+	Value     string
+	ExpiresAt time.Time // нулевое значение - без срока истечения
+}
+
+// WithKeyVersion включает версионирование ключей: все ключи прозрачно хранятся
+// с префиксом "<v>:", а методы чтения и листинга видят только ключи текущей версии.
+// Когда формат значений меняется, достаточно поднять версию - старые записи станут
+// невидимыми и либо истекут, либо будут перенесены через MigrateKeys.
+func WithKeyVersion(v string) Option {
+	return func(c *config) {
+		c.keyVersion = v
+	}
+}
+
+// skey превращает пользовательский ключ в ключ хранения
+func (s *Store) skey(key string) string {
+	raw, _, _ := s.storageKey(key)
+	return raw
+}
+
+// wkey - skey для записей, которые могут создать ключ: запоминает исходный длинный ключ,
+// см. WithKeyHashing. Чтения его не запоминают, иначе промахи по случайным длинным ключам
+// копили бы таблицу до Cleanup
+func (s *Store) wkey(key string) string {
+	raw, key, long := s.storageKey(key)
+	if long {
+		s.rememberLongKey(raw, key)
+	}
+	return raw
+}
+
+// storageKey возвращает ключ хранения raw, канонический исходный ключ key и признак long,
+// что ключ заменён хешем
+func (s *Store) storageKey(key string) (raw, canon string, long bool) {
+	canon = s.canonKey(key)
+	hashed, long := s.hashKey(canon)
+	raw = hashed
+	if s.cfg.keyVersion != "" {
+		raw = s.cfg.keyVersion + keyVersionSep + hashed
+	}
+	return raw, canon, long
+}
+
+// userKey обратное к skey, ok=false если ключ принадлежит другой версии схемы
+func (s *Store) userKey(raw string) (string, bool) {
+	if s.cfg.keyVersion == "" {
+		return s.unhashKey(raw, raw), true
+	}
+	key, ok := strings.CutPrefix(raw, s.cfg.keyVersion+keyVersionSep)
+	if !ok {
+		return "", false
+	}
+	return s.unhashKey(raw, key), true
+}
+
+// MigrateKeys переносит записи версии oldVersion в текущую версию схемы.
+// Для каждой записи вызывается transform: вернув false, запись просто удаляется,
+// иначе результат сохраняется под текущей версией, а старая запись удаляется.
+// Просмотры, история обращений и источник значения (Provenance) переносятся на новую запись.
+// Пустой oldVersion означает ключи, сохраненные без версии. Коллекции (хеш, список, множества) приходят
+// в Entry.Value как JSON: если transform его не изменил, коллекция переносится как есть,
+// иначе ключ становится строкой.
+//
+// transform вызывается без блокировок хранилища. Если запись изменилась, пока её
+// преобразовывали, она пропускается. Возвращает количество перенесенных записей.
+func (s *Store) MigrateKeys(oldVersion string, transform func(old Entry) (Entry, bool)) int {
+	if oldVersion == s.cfg.keyVersion {
+		return 0
+	}
+	if s.enter() != nil {
+		return 0
+	}
+	defer s.leave()
+
+	type candidate struct {
+		raw   string
+		item  *Item
+		entry Entry
+	}
+
+	now := s.now()
+	var found []candidate
+
+	s.mu.RLock()
+	for raw, item := range s.data {
+		key, ok := oldVersionKey(raw, oldVersion, s.cfg.keyVersion)
+		if !ok {
+			continue
+		}
+		if !item.ExpiresAt.IsZero() && now.After(item.ExpiresAt) {
+			continue
+		}
+		found = append(found, candidate{
+			raw:   raw,
+			item:  item,
+			entry: Entry{Key: key, Value: item.text(), ExpiresAt: item.ExpiresAt},
+		})
+	}
+	s.mu.RUnlock()
+
+	migrated := 0
+	for _, c := range found {
+		next, keep := transform(c.entry)
+
+		s.mu.Lock()
+		if cur, ok := s.data[c.raw]; !ok || cur != c.item {
+			s.mu.Unlock()
+			continue
+		}
+		s.removeLocked(c.raw, EventDelete)
+		if s.sink != nil && (!keep || next.Key != c.entry.Key) {
+			// userKey не снимает префикс старой версии, ключ приёмника - c.entry.Key
+			s.sink.appendUnreservedLocked(Mutation{Type: EventDelete, Key: c.entry.Key, At: now})
+		}
+		if keep {
+			item := &Item{
+				ExpiresAt:  next.ExpiresAt,
+				CreatedAt:  c.item.CreatedAt,
+				UpdatedAt:  now,
+				Provenance: c.item.Provenance,
+				maxViews:   c.item.maxViews,
+			}
+			s.setCompressed(item, next.Value)
+			item.Views.Store(c.item.Views.Load())
+			item.accessedAt.Store(c.item.accessedAt.Load())
+			if c.item.kind != kindString && next.Value == c.entry.Value {
+				// значение не меняли - коллекция переносится как есть, иначе ключ становится строкой
+				item = c.item.copyItem()
+				item.ExpiresAt, item.UpdatedAt = next.ExpiresAt, now
+			}
+			raw := s.wkey(next.Key)
+			s.putLocked(raw, item)
+			s.sinkBulkLocked(EventSet, raw, item, now)
+			s.evictLocked(now, raw)
+			migrated++
+		}
+		s.mu.Unlock()
+	}
+
+	s.cfg.logger.Info("store: keys migrated", "from", oldVersion, "to", s.cfg.keyVersion,
+		"candidates", len(found), "migrated", migrated)
+	return migrated
+}
+
+// oldVersionKey проверяет, что raw принадлежит версии oldVersion, и возвращает ключ без префикса
+func oldVersionKey(raw, oldVersion, current string) (string, bool) {
+	if oldVersion != "" {
+		return strings.CutPrefix(raw, oldVersion+keyVersionSep)
+	}
+	// ключи без версии - всё, что не относится к текущей версии
+	if current != "" && strings.HasPrefix(raw, current+keyVersionSep) {
+		return "", false
+	}
+	return raw, true
+}
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// ErrWrongType - операция над ключом, который хранит значение другого типа (например HSet над строкой).
+var ErrWrongType = errors.New("store: operation against a key holding the wrong kind of value")
+
+// valueKind - тип значения элемента. Строки хранятся в Item.Value, остальные типы -
+// в своих полях элемента, которые, как и весь элемент, не меняются после записи в мапу
+type valueKind uint8
+
+const (
+	kindString valueKind = iota
+	kindHash
+	kindList
+	kindSet
+	kindZSet
+	kindCounter
+	kindHLL
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindHash:
+		return "hash"
+	case kindList:
+		return "list"
+	case kindSet:
+		return "set"
+	case kindZSet:
+		return "zset"
+	case kindCounter:
+		return "counter"
+	case kindHLL:
+		return "hyperloglog"
+	default:
+		return "unknown"
+	}
+}
+
+// parseKind - обратное к String, для загрузки снапшота. Пустая строка - строка
+func parseKind(s string) (valueKind, bool) {
+	switch s {
+	case "", "string":
+		return kindString, true
+	case "hash":
+		return kindHash, true
+	case "list":
+		return kindList, true
+	case "set":
+		return kindSet, true
+	case "zset":
+		return kindZSet, true
+	case "counter":
+		return kindCounter, true
+	case "hyperloglog":
+		return kindHLL, true
+	default:
+		return 0, false
+	}
+}
+
+// text - значение элемента строкой: для строк само значение, для остальных типов - JSON.
+// Так Get, FullList, подписчики и приёмник изменений видят содержимое любого ключа
+func (it *Item) text() string {
+	if it == nil {
+		return ""
+	}
+	switch it.kind {
+	case kindHash:
+		b, _ := json.Marshal(it.hash)
+		return string(b)
+	case kindList:
+		b, _ := json.Marshal(it.list)
+		return string(b)
+	case kindSet:
+		b, _ := json.Marshal(it.members())
+		return string(b)
+	case kindZSet:
+		b, _ := json.Marshal(it.zset.rangeByScore(math.Inf(-1), math.Inf(1)))
+		return string(b)
+	case kindCounter:
+		return strconv.FormatInt(it.counter.Load(), 10)
+	case kindHLL:
+		return hllText(it.hll)
+	default:
+		return it.plain()
+	}
+}
+
+// setPayload делает it коллекцией src того же типа, строковое значение it сбрасывается
+func (it *Item) setPayload(src *Item) {
+	it.Value, it.rawSize = "", 0
+	it.kind, it.hash, it.list, it.set = src.kind, src.hash, src.list, src.set
+	it.zset, it.counter, it.hll = src.zset, src.counter, src.hll
+}
+
+// payloadSize - примерный объём данных элемента без ключа и служебных полей
+func (it *Item) payloadSize() int {
+	n := len(it.Value)
+	for f, v := range it.hash {
+		n += len(f) + len(v) + 16 // 16 - примерная цена записи в мапе
+	}
+	for _, v := range it.list {
+		n += len(v) + 16 // заголовок строки
+	}
+	for m := range it.set {
+		n += len(m) + 16
+	}
+	n += it.zset.len() * 128 // два узла дерева на элемент, имя считаем в заголовке узла
+	if it.counter != nil {
+		n += 8
+	}
+	n += len(it.hll)
+	n += metaSize(it.meta) // метаданные считаются частью значения
+	return n
+}
+
+// length - число элементов коллекции, для строки 1
+func (it *Item) length() int {
+	switch it.kind {
+	case kindHash:
+		return len(it.hash)
+	case kindList:
+		return len(it.list)
+	case kindSet:
+		return len(it.set)
+	case kindZSet:
+		return it.zset.len()
+	default:
+		return 1
+	}
+}
+
+// Type возвращает тип значения ключа: "string", "hash", "list", "set", "zset", "counter"
+// или "hyperloglog", "none" - если ключа нет или он истёк.
+func (s *Store) Type(key string) string {
+	item, ok := s.loadItem(s.skey(key))
+
+	if !ok || item.expiredAt(s.now()) {
+		return "none"
+	}
+	return item.kind.String()
+}
+
+// update - общая часть записи в коллекции (HSet, LPush, ...): под одной блокировкой находит
+// живой элемент типа kind и вызывает fn с его копией, для отсутствующего ключа - с новым
+// элементом с TTL по умолчанию. Копия делит коллекции с исходным элементом, поэтому fn
+// не меняет их на месте, а подставляет новые. fn возвращает false, если менять нечего,
+// а опустевшая коллекция удаляет ключ, как в Redis. Если ключ хранит другой тип - ErrWrongType.
+// Записи, которой не хватило места (WithFullPolicy) или которую не пропустил фильтр допуска
+// (WithAdmission), - ErrFull. С FullBlock fn вызывается заново на каждую попытку, поэтому
+// результат он присваивает, а не накапливает
+func (s *Store) update(key string, kind valueKind, fn func(next *Item) bool) error {
+	if err := s.checkWrite(key, 0); err != nil {
+		return err
+	}
+	return s.withRoom(key, nil, func() error {
+		return s.updateOnce(key, kind, fn)
+	})
+}
+
+// updateOnce - одна попытка update
+func (s *Store) updateOnce(key string, kind valueKind, fn func(next *Item) bool) error {
+	if err := s.beginWrite(writeOpts{}); err != nil {
+		return err
+	}
+	defer s.endWrite()
+
+	userKey := key
+	key = s.wkey(key)
+	now := s.now()
+
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	cur, ok := s.data[key]
+	if ok && cur.expiredAt(now) {
+		ok = false
+	}
+	if ok && cur.kind != kind {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return ErrWrongType
+	}
+
+	next := &Item{ExpiresAt: expiresAt(now, s.effectiveTTL(0)), kind: kind}
+	if ok {
+		next = cur.copyItem()
+	}
+	if !fn(next) {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return nil
+	}
+	if size := next.payloadSize(); s.cfg.maxValueSize > 0 && size > s.cfg.maxValueSize {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return s.checkValueSize(key, size) // может паниковать в StrictPanic, поэтому без блокировки
+	}
+
+	if next.length() == 0 {
+		if ok {
+			s.removeLocked(key, EventDelete)
+			s.stats.deletes.Add(1)
+		}
+		if queued {
+			s.sinkAppendLocked(EventDelete, key, nil, now)
+		}
+		s.mu.Unlock()
+		return nil
+	}
+
+	admitted, full, err := s.placeLocked(key, next, now)
+	if !admitted {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		s.placed(userKey, full, err)
+		if err == nil {
+			err = ErrFull // отказ фильтра допуска: значение не записано
+		}
+		return err
+	}
+	next.UpdatedAt = now
+	s.putLocked(key, next)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, next, now)
+	}
+	s.evictLocked(now, key)
+	s.mu.Unlock()
+	s.placed(userKey, full, nil)
+
+	s.stats.sets.Add(1)
+	s.resolveMiss(key)
+	if !ok {
+		s.push(key)
+	}
+	return nil
+}
+
+// view - чтение коллекции типа kind со статистикой попаданий. Истекший ключ не удаляется,
+// его уберёт Get или Cleanup
+func (s *Store) view(key string, kind valueKind) (*Item, bool) {
+	s.activateKey(s.skey(key))
+	s.recordAccess(s.skey(key))
+	if s.bloomAbsent(s.skey(key)) {
+		s.stats.misses.Add(1)
+		return nil, false
+	}
+	item, ok := s.loadItem(s.skey(key))
+	if !ok {
+		s.bloomMiss()
+	}
+
+	if !ok || item.expiredAt(s.now()) || item.kind != kind {
+		s.stats.misses.Add(1)
+		return nil, false
+	}
+	now := s.now()
+	skey := s.skey(key)
+	s.countView(skey, item, now)
+	s.stats.hits.Add(1)
+	s.recordRead(skey, now)
+	return item, true
+}
@@ -0,0 +1,304 @@
+package store
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Имена операций в Stats.Latency и метриках Prometheus.
+const (
+	opGet     = "get"
+	opSet     = "set"
+	opDelete  = "delete"
+	opCleanup = "cleanup"
+
+	// ожидание блокировки хранилища, см. waitMutex
+	opLockRead  = "lock_wait_read"
+	opLockWrite = "lock_wait_write"
+
+	// работа под блокировкой на запись и пользовательские колбэки, см. WithLockTiming
+	opLockHold = "lock_hold_write"
+	opCallback = "callback"
+)
+
+// WithLatencyHistograms включает сбор гистограмм задержек по типам операций
+// (get, set, delete, cleanup). Запись в гистограмму - пара атомарных инкрементов,
+// но к каждой операции добавляются два вызова time.Now, поэтому по умолчанию выключено.
+//
+// Вместе с ними собирается время ожидания блокировки хранилища на чтение и на запись
+// (lock_wait_read, lock_wait_write): рост его квантилей при обычных задержках самих
+// операций означает конкуренцию за блокировку. Хранилище не шардировано, блокировка
+// одна на всё хранилище, поэтому и гистограммы ожидания общие.
+func WithLatencyHistograms() Option {
+	return func(c *config) {
+		c.latency = true
+	}
+}
+
+// WithLockTiming дополняет WithLatencyHistograms (и включает их) разбивкой времени операций:
+// кроме ожидания блокировки собирается время, пока блокировка на запись удерживается
+// (lock_hold_write - работа с данными под блокировкой), и время в пользовательских колбэках
+// (callback - WithExpireCallback, WithOnFull, WithHotKeyDetection). Так видно, откуда
+// задержка: большое ожидание при коротком удержании - конкуренция, долгое удержание -
+// тяжёлые записи или проходы очистки, а callback - медленный код вызывающего.
+//
+// Удержание блокировки на чтение не замеряется: читатели держат её ровно на поиск в мапе.
+func WithLockTiming() Option {
+	return func(c *config) {
+		c.latency = true
+		c.lockTiming = true
+	}
+}
+
+// histogram - гистограмма в стиле HDR: на каждую степень двойки приходится
+// 1<<subBucketBits корзин, так что относительная ошибка квантилей не больше 12.5%
+// при фиксированном размере и без блокировок
+type histogram struct {
+	counts [bucketCount]atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Uint64 // наносекунды
+	max    atomic.Uint64
+}
+
+const (
+	subBucketBits = 3
+	subBuckets    = 1 << subBucketBits
+	maxExponent   = 40 // 2^40 нс ~ 18 минут, всё что дольше попадает в последнюю корзину
+	bucketCount   = (maxExponent - subBucketBits + 2) * subBuckets
+)
+
+// bucketIndex - номер корзины для значения в наносекундах
+func bucketIndex(ns uint64) int {
+	if ns < subBuckets {
+		return int(ns)
+	}
+	exp := bits.Len64(ns) - 1
+	if exp > maxExponent {
+		return bucketCount - 1
+	}
+	sub := (ns >> (exp - subBucketBits)) & (subBuckets - 1)
+	return (exp-subBucketBits+1)*subBuckets + int(sub)
+}
+
+// bucketUpper - верхняя граница корзины (не включительно) в наносекундах
+func bucketUpper(i int) uint64 {
+	if i < subBuckets {
+		return uint64(i) + 1
+	}
+	exp := i/subBuckets + subBucketBits - 1
+	sub := uint64(i % subBuckets)
+	return (subBuckets + sub + 1) << (exp - subBucketBits)
+}
+
+func (h *histogram) observe(d time.Duration) {
+	ns := uint64(max(d, 0))
+	h.counts[bucketIndex(ns)].Add(1)
+	h.count.Add(1)
+	h.sum.Add(ns)
+	for {
+		cur := h.max.Load()
+		if ns <= cur || h.max.CompareAndSwap(cur, ns) {
+			return
+		}
+	}
+}
+
+// since записывает время, прошедшее с start, удобно для defer h.since(time.Now())
+func (h *histogram) since(start time.Time) {
+	h.observe(time.Since(start))
+}
+
+// LatencySnapshot - квантили задержек одной операции.
+type LatencySnapshot struct {
+	Count uint64        `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	P999  time.Duration `json:"p999"`
+	Max   time.Duration `json:"max"`
+	Sum   time.Duration `json:"sum"` // суммарное время, для счётчиков вроде OpenTelemetry
+}
+
+func (h *histogram) snapshot() LatencySnapshot {
+	var counts [bucketCount]uint64
+	var total uint64
+	for i := range counts {
+		counts[i] = h.counts[i].Load()
+		total += counts[i]
+	}
+	if total == 0 {
+		return LatencySnapshot{}
+	}
+
+	quantile := func(q float64) time.Duration {
+		rank := uint64(q*float64(total-1)) + 1
+		var seen uint64
+		for i, c := range counts {
+			seen += c
+			if seen >= rank {
+				return time.Duration(bucketUpper(i))
+			}
+		}
+		return time.Duration(bucketUpper(bucketCount - 1))
+	}
+
+	maxNs := time.Duration(h.max.Load())
+	return LatencySnapshot{
+		Count: total,
+		Mean:  time.Duration(h.sum.Load() / max(h.count.Load(), 1)),
+		P50:   min(quantile(0.5), maxNs),
+		P90:   min(quantile(0.9), maxNs),
+		P95:   min(quantile(0.95), maxNs),
+		P99:   min(quantile(0.99), maxNs),
+		P999:  min(quantile(0.999), maxNs),
+		Max:   maxNs,
+		Sum:   time.Duration(h.sum.Load()),
+	}
+}
+
+// cumulative - количество наблюдений не больше le, по границам корзин
+func (h *histogram) cumulative(le time.Duration) uint64 {
+	var n uint64
+	for i := range h.counts {
+		if bucketUpper(i) > uint64(le) {
+			break
+		}
+		n += h.counts[i].Load()
+	}
+	return n
+}
+
+// latencies - гистограммы по операциям
+type latencies struct {
+	get, set, delete, cleanup histogram
+
+	lockRead, lockWrite histogram
+
+	lockHold, callback histogram // только с WithLockTiming
+}
+
+func newLatencies() *latencies {
+	return &latencies{}
+}
+
+// latency возвращает гистограмму операции op или nil, если сбор выключен
+func (s *Store) latency(op string) *histogram {
+	if s.lat == nil {
+		return nil
+	}
+	switch op {
+	case opGet:
+		return &s.lat.get
+	case opSet:
+		return &s.lat.set
+	case opDelete:
+		return &s.lat.delete
+	case opCleanup:
+		return &s.lat.cleanup
+	case opLockRead:
+		return &s.lat.lockRead
+	case opLockWrite:
+		return &s.lat.lockWrite
+	case opLockHold:
+		return &s.lat.lockHold
+	case opCallback:
+		return &s.lat.callback
+	}
+	return nil
+}
+
+// latencyOps - операции в порядке вывода, lockOps - ожидание блокировки,
+// timingOps - разбивка WithLockTiming
+var (
+	latencyOps = []string{opGet, opSet, opDelete, opCleanup}
+	lockOps    = []string{opLockRead, opLockWrite}
+	timingOps  = []string{opLockHold, opCallback}
+)
+
+// latencySnapshots - квантили по всем операциям, nil если сбор выключен
+func (s *Store) latencySnapshots() map[string]LatencySnapshot {
+	if s.lat == nil {
+		return nil
+	}
+	ops := append(append([]string(nil), latencyOps...), lockOps...)
+	if s.cfg.lockTiming {
+		ops = append(ops, timingOps...)
+	}
+	res := make(map[string]LatencySnapshot, len(ops))
+	for _, op := range ops {
+		res[op] = s.latency(op).snapshot()
+	}
+	return res
+}
+
+// callbackStart - начало пользовательского колбэка для WithLockTiming, нулевое время -
+// замер выключен. Пара к callbackDone
+func (s *Store) callbackStart() time.Time {
+	if !s.cfg.lockTiming {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// callbackDone записывает время колбэка, начатого в callbackStart
+func (s *Store) callbackDone(start time.Time) {
+	if !start.IsZero() {
+		s.lat.callback.since(start)
+	}
+}
+
+// waitMutex - sync.RWMutex, который с WithLatencyHistograms записывает время ожидания
+// блокировки. Захват без конкуренции (удался TryLock) записывается нулевым ожиданием
+// и обходится без вызовов time.Now. С WithLockTiming ещё и время удержания на запись
+type waitMutex struct {
+	sync.RWMutex
+	read, write *histogram // nil - без замеров
+	hold        *histogram // nil - удержание не замеряем
+
+	lockedAt time.Time // захват на запись для hold, меняется только владельцем блокировки
+}
+
+func (m *waitMutex) Lock() {
+	switch {
+	case m.write == nil:
+		m.RWMutex.Lock()
+	case m.RWMutex.TryLock():
+		m.write.observe(0)
+	default:
+		start := time.Now()
+		m.RWMutex.Lock()
+		m.write.since(start)
+	}
+	m.held()
+}
+
+// held запоминает момент захвата на запись для замера удержания
+func (m *waitMutex) held() {
+	if m.hold != nil {
+		m.lockedAt = time.Now()
+	}
+}
+
+func (m *waitMutex) Unlock() {
+	if m.hold != nil {
+		m.hold.since(m.lockedAt)
+	}
+	m.RWMutex.Unlock()
+}
+
+func (m *waitMutex) RLock() {
+	switch {
+	case m.read == nil:
+		m.RWMutex.RLock()
+	case m.RWMutex.TryRLock():
+		m.read.observe(0)
+	default:
+		start := time.Now()
+		m.RWMutex.RLock()
+		m.read.since(start)
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	// ErrClosed - запись в хранилище после Close.
+	ErrClosed = errors.New("store: store is closed")
+
+	// ErrReadOnly - запись в хранилище в режиме только для чтения, см. SetReadOnly.
+	ErrReadOnly = errors.New("store: store is read-only")
+)
+
+// lifecycle - состояние закрытия хранилища, режим только для чтения и счётчик выполняющихся записей
+type lifecycle struct {
+	closed   atomic.Bool
+	readOnly atomic.Bool
+	inflight atomic.Int64
+	done     chan struct{} // закрывается в Close, останавливает фоновую очистку
+	idle     chan struct{} // сигнал Close, что записей в процессе не осталось
+}
+
+// Close закрывает хранилище для участия в упорядоченной остановке сервиса: новые записи
+// получают ErrClosed (методы без ошибки, например Delete и Reset, ничего не делают,
+// с WithStrict(StrictPanic) такая запись паникует),
+// фоновая очистка (Cleanup, CleanupEvery, RunCleanup) останавливается, Close ждёт
+// завершения уже начатых записей, сбрасывает журнал WithDurableLog и дописывает очередь
+// WithSink в приёмник.
+//
+// Чтение после Close работает, так что снапшот удобно сохранить уже после закрытия -
+// в нём точно не будет потерянных записей. Если ctx завершился раньше, Close возвращает
+// ctx.Err(), хранилище при этом остаётся закрытым, и Close можно вызвать ещё раз.
+func (s *Store) Close(ctx context.Context) error {
+	if s.life.closed.CompareAndSwap(false, true) {
+		close(s.life.done)
+		s.cfg.logger.Info("store: closing")
+	}
+
+	for s.life.inflight.Load() > 0 {
+		select {
+		case <-s.life.idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	s.closeDurable()
+	if err := s.CloseSink(ctx); err != nil {
+		return err
+	}
+	s.cfg.logger.Info("store: closed")
+	return nil
+}
+
+// Closed сообщает, закрыто ли хранилище через Close.
+func (s *Store) Closed() bool {
+	return s.life.closed.Load()
+}
+
+// SetReadOnly включает и выключает режим только для чтения, например на время обслуживания,
+// вывода узла из балансировки или раздачи восстановленного снапшота, который нельзя менять.
+// В этом режиме записи возвращают ErrReadOnly, а методы без ошибки (Delete, Reset,
+// RetrieveLastKey) ничего не делают. Чтение работает как обычно, истекшие ключи по-прежнему
+// удаляются в Get и Cleanup, изменения с основного узла (ApplyMutation) применяются.
+// Записи, начатые до включения режима, завершаются.
+func (s *Store) SetReadOnly(readOnly bool) {
+	if s.life.readOnly.Swap(readOnly) != readOnly {
+		s.cfg.logger.Info("store: read-only mode changed", "readOnly", readOnly)
+	}
+}
+
+// ReadOnly сообщает, включён ли режим только для чтения.
+func (s *Store) ReadOnly() bool {
+	return s.life.readOnly.Load()
+}
+
+// enter отмечает начало записи: после Close - ErrClosed, в режиме только для чтения - ErrReadOnly.
+// Запись после Close - ошибка вызывающего, в StrictPanic она паникует, см. misuse.
+// Каждому успешному enter - свой leave
+func (s *Store) enter() error {
+	s.life.inflight.Add(1)
+	switch {
+	case s.life.closed.Load():
+		s.leave()
+		s.misuse(ErrClosed)
+		return ErrClosed
+	case s.life.readOnly.Load():
+		s.leave()
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// enterWrite - enter для записи с параметрами w: изменения с основного узла
+// не отклоняются режимом только для чтения, а они и записи самого хранилища после Close
+// возвращают ErrClosed без паники - это не ошибка вызывающего
+func (s *Store) enterWrite(w writeOpts) error {
+	if !w.replicated && !w.internal {
+		return s.enter()
+	}
+	s.life.inflight.Add(1)
+	switch {
+	case s.life.closed.Load():
+		s.leave()
+		return ErrClosed
+	case s.life.readOnly.Load() && !w.replicated:
+		s.leave()
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// leave отмечает конец записи и будит Close, если она была последней
+func (s *Store) leave() {
+	if s.life.inflight.Add(-1) == 0 && s.life.closed.Load() {
+		select {
+		case s.life.idle <- struct{}{}:
+		default:
+		}
+	}
+}
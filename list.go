@@ -0,0 +1,90 @@
+package store
+
+// LPush добавляет значения в начало списка key по одному, как в Redis: LPush(k, "a", "b")
+// даёт список [b a]. Возвращает длину списка после вставки. Отсутствующий ключ создаётся
+// с TTL по умолчанию (WithDefaultTTL), у существующего TTL общий на весь список и сохраняется.
+// Если ключ хранит не список, возвращается ErrWrongType.
+//
+// Вставка в начало копирует список, для очередей дешевле RPush и LPop.
+func (s *Store) LPush(key string, values ...string) (int, error) {
+	n := 0
+	err := s.update(key, kindList, func(next *Item) bool {
+		list := make([]string, 0, len(values)+len(next.list))
+		for i := len(values) - 1; i >= 0; i-- {
+			list = append(list, values[i])
+		}
+		next.list = append(list, next.list...)
+		n = len(next.list)
+		return len(values) > 0
+	})
+	return n, err
+}
+
+// RPush добавляет значения в конец списка key и возвращает его длину после вставки.
+// Остальное как у LPush.
+func (s *Store) RPush(key string, values ...string) (int, error) {
+	n := 0
+	err := s.update(key, kindList, func(next *Item) bool {
+		// append дописывает за длиной старого списка: старый элемент, который ещё могут
+		// читать после RUnlock, эти ячейки не видит. RPop обрезает ёмкость, что-бы это сохранялось
+		next.list = append(next.list, values...)
+		n = len(next.list)
+		return len(values) > 0
+	})
+	return n, err
+}
+
+// LPop снимает первое значение списка. false - ключа нет или список пуст.
+// Последнее значение удаляет ключ. Если ключ хранит не список, возвращается ErrWrongType.
+func (s *Store) LPop(key string) (string, bool, error) {
+	var value string
+	var ok bool
+	err := s.update(key, kindList, func(next *Item) bool {
+		if len(next.list) == 0 {
+			return false
+		}
+		value, ok = next.list[0], true
+		next.list = next.list[1:]
+		return true
+	})
+	return value, ok, err
+}
+
+// RPop снимает последнее значение списка, остальное как у LPop.
+func (s *Store) RPop(key string) (string, bool, error) {
+	var value string
+	var ok bool
+	err := s.update(key, kindList, func(next *Item) bool {
+		n := len(next.list)
+		if n == 0 {
+			return false
+		}
+		value, ok = next.list[n-1], true
+		next.list = next.list[: n-1 : n-1]
+		return true
+	})
+	return value, ok, err
+}
+
+// LRange возвращает значения списка с start по stop включительно. Отрицательные индексы
+// считаются с конца, -1 - последнее значение, так что LRange(k, 0, -1) - весь список.
+// nil - ключа нет, он истёк или хранит не список.
+func (s *Store) LRange(key string, start, stop int) []string {
+	item, ok := s.view(key, kindList)
+	if !ok {
+		return nil
+	}
+
+	n := len(item.list)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return []string{}
+	}
+	return append([]string(nil), item.list[start:stop+1]...)
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/base64"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor возвращает List, если курсор испорчен или получен с другим SortBy.
+var ErrInvalidCursor = errors.New("store: invalid list cursor")
+
+const defaultListLimit = 100
+
+// ListSort - порядок страниц List.
+type ListSort int
+
+const (
+	SortByKey       ListSort = iota // по ключу
+	SortByUpdatedAt                 // по времени последней записи
+	SortByExpiresAt                 // по сроку истечения, ключи без срока - в конце
+	SortByViews                     // по числу чтений
+	SortBySize                      // по объёму, см. ItemDTO.Size
+)
+
+// ListOptions - параметры List.
+type ListOptions struct {
+	Prefix string     // только ключи с префиксом
+	Limit  int        // размер страницы, 0 - 100
+	Cursor string     // ListPage.Next предыдущей страницы, пусто - первая страница
+	SortBy ListSort   // порядок, при равенстве - по ключу
+	Desc   bool       // в обратном порядке
+	Fields ListFields // поля ItemDTO, 0 - ListAll
+
+	MinSize int64 // только ключи объёмом от MinSize байт, см. ItemDTO.Size
+
+	IncludeExpired bool // истекшие, но ещё не удалённые ключи тоже попадают в список
+}
+
+// ListEntry - элемент страницы List.
+type ListEntry struct {
+	Key string
+	ItemDTO
+}
+
+// ListPage - страница List. Next передаётся в ListOptions.Cursor за следующей страницей,
+// пусто - страница последняя.
+type ListPage struct {
+	Items []ListEntry
+	Next  string
+}
+
+// listCandidate - ключ с значением сортировки, собирается под RLock без копирования элемента
+type listCandidate struct {
+	key  string
+	val  int64
+	item *Item
+}
+
+// List возвращает страницу ключей для административных интерфейсов, не собирая FullList:
+// проход по хранилищу держит кучу размером с страницу (O(N log Limit)), а ItemDTO строятся
+// только для ключей страницы. Курсор - позиция последнего ключа страницы, так что записи
+// между страницами не сдвигают уже выданные. При SortBy, отличном от SortByKey, ключ, чьё
+// значение сортировки изменилось между запросами (запись, чтение), может попасть на две
+// страницы или ни на одну. Ключи, которые занимают больше всего памяти:
+//
+//	page, _ := s.List(store.ListOptions{SortBy: store.SortBySize, Desc: true, Limit: 50})
+func (s *Store) List(opts ListOptions) (ListPage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	fields := opts.Fields
+	if fields == 0 {
+		fields = ListAll
+	}
+	compare := compareCandidates
+	if opts.Desc {
+		compare = func(a, b listCandidate) int { return compareCandidates(b, a) }
+	}
+	var after *listCandidate
+	if opts.Cursor != "" {
+		c, err := decodeListCursor(opts.Cursor, opts.SortBy)
+		if err != nil {
+			return ListPage{}, err
+		}
+		after = &c
+	}
+
+	// limit+1 - что-бы узнать, есть ли следующая страница
+	page := &listHeap{cmp: compare, items: make([]listCandidate, 0, limit+1)}
+	now := s.now()
+	s.mu.RLock()
+	for raw, item := range s.data {
+		if !opts.IncludeExpired && item.expiredAt(now) || item.size < opts.MinSize {
+			continue
+		}
+		key, ok := s.userKey(raw)
+		if !ok || !strings.HasPrefix(key, opts.Prefix) {
+			continue
+		}
+		c := listCandidate{key: key, val: listSortValue(item, opts.SortBy), item: item}
+		if after != nil && compare(c, *after) <= 0 {
+			continue
+		}
+		if page.Len() <= limit {
+			heap.Push(page, c)
+		} else if compare(c, page.items[0]) < 0 {
+			page.items[0] = c
+			heap.Fix(page, 0)
+		}
+	}
+	s.mu.RUnlock()
+
+	found := page.items
+	slices.SortFunc(found, compare)
+	var res ListPage
+	if len(found) > limit {
+		found = found[:limit]
+		last := found[limit-1]
+		res.Next = encodeListCursor(opts.SortBy, last)
+	}
+	res.Items = make([]ListEntry, 0, len(found))
+	for _, c := range found {
+		res.Items = append(res.Items, ListEntry{Key: c.key, ItemDTO: itemDTO(c.item, fields)})
+	}
+	return res, nil
+}
+
+// compareCandidates - порядок по возрастанию значения сортировки, при равенстве - по ключу
+func compareCandidates(a, b listCandidate) int {
+	if c := cmp.Compare(a.val, b.val); c != 0 {
+		return c
+	}
+	return strings.Compare(a.key, b.key)
+}
+
+// listSortValue - значение сортировки элемента, для SortByKey порядок задаёт только ключ
+func listSortValue(item *Item, by ListSort) int64 {
+	switch by {
+	case SortByUpdatedAt:
+		return item.UpdatedAt.UnixNano()
+	case SortByExpiresAt:
+		if item.ExpiresAt.IsZero() {
+			return math.MaxInt64
+		}
+		return item.ExpiresAt.UnixNano()
+	case SortByViews:
+		return int64(min(item.Views.Load(), math.MaxInt64))
+	case SortBySize:
+		return item.size
+	default:
+		return 0
+	}
+}
+
+// encodeListCursor - "порядок:значение:ключ" в base64url, ключ может содержать что угодно
+func encodeListCursor(by ListSort, c listCandidate) string {
+	raw := strconv.Itoa(int(by)) + ":" + strconv.FormatInt(c.val, 10) + ":" + c.key
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeListCursor(cursor string, by ListSort) (listCandidate, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return listCandidate{}, ErrInvalidCursor
+	}
+	sortBy, rest, ok1 := strings.Cut(string(raw), ":")
+	val, key, ok2 := strings.Cut(rest, ":")
+	if !ok1 || !ok2 || sortBy != strconv.Itoa(int(by)) {
+		return listCandidate{}, ErrInvalidCursor
+	}
+	v, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return listCandidate{}, ErrInvalidCursor
+	}
+	return listCandidate{key: key, val: v}, nil
+}
+
+// listHeap - max-куча по порядку страницы: в вершине худший из лучших кандидатов
+type listHeap struct {
+	cmp   func(a, b listCandidate) int
+	items []listCandidate
+}
+
+func (h *listHeap) Len() int           { return len(h.items) }
+func (h *listHeap) Less(i, j int) bool { return h.cmp(h.items[i], h.items[j]) > 0 }
+func (h *listHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *listHeap) Push(x any)         { h.items = append(h.items, x.(listCandidate)) }
+func (h *listHeap) Pop() any {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
@@ -0,0 +1,337 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotFound возвращается загрузчиком, если ключа нет в бэкенде, и LoadingStore.Get -
+// если ключа нет или отсутствие закешировано (WithNotFoundTTL, SetNegative).
+var ErrNotFound = errors.New("store: not found")
+
+// negativeSweepEvery - раз в сколько записей в кеш отсутствия из него вычищаются истекшие
+const negativeSweepEvery = 1024
+
+// LoaderFunc загружает значение ключа из бэкенда. ttl == 0 - TTL хранилища по умолчанию.
+// Для отсутствующего ключа загрузчик возвращает ErrNotFound (можно обёрнутую).
+type LoaderFunc func(ctx context.Context, key string) (value string, ttl time.Duration, err error)
+
+// LoadingOption настраивает LoadingStore.
+type LoadingOption func(*LoadingStore)
+
+// WithStaleWhileRevalidate включает режим stale-while-revalidate: загруженное значение
+// хранится ещё window после своего TTL. Get в это время сразу возвращает устаревшее
+// значение и запускает обновление в фоне, так что горячий путь не ждёт загрузчик.
+// Если обновление не удалось, устаревшее значение отдаётся до конца окна.
+//
+// Окно входит в TTL записи в хранилище: Store().TTL показывает срок вместе с окном.
+func WithStaleWhileRevalidate(window time.Duration) LoadingOption {
+	return func(ls *LoadingStore) {
+		ls.staleWindow = window
+	}
+}
+
+// WithEarlyRefresh включает вероятностное досрочное обновление (XFetch): при каждом
+// попадании значение обновляется в фоне с вероятностью, которая растёт по мере
+// приближения TTL и с ростом времени загрузки. Условие обновления:
+//
+//	now - cost * beta * ln(rand()) >= expiresAt
+//
+// Горячие ключи читают чаще, поэтому обновляются раньше, а одновременного пересчёта
+// популярного значения в момент истечения не происходит. beta = 1 - значение из статьи,
+// больше единицы - обновлять раньше.
+func WithEarlyRefresh(beta float64) LoadingOption {
+	return func(ls *LoadingStore) {
+		ls.earlyBeta = beta
+	}
+}
+
+// WithNegativeCache запоминает ошибку загрузчика на ttl: повторные Get в это время
+// сразу получают ту же ошибку, не нагружая бэкенд, который только что отказал.
+// ErrNotFound настраивается отдельно через WithNotFoundTTL.
+func WithNegativeCache(ttl time.Duration) LoadingOption {
+	return func(ls *LoadingStore) {
+		ls.negativeTTL = ttl
+	}
+}
+
+// WithNotFoundTTL запоминает ответ загрузчика ErrNotFound на ttl, так повторные запросы
+// несуществующих ключей не доходят до базы. Обычно ttl короче TTL найденных значений.
+func WithNotFoundTTL(ttl time.Duration) LoadingOption {
+	return func(ls *LoadingStore) {
+		ls.notFoundTTL = ttl
+	}
+}
+
+// LoadingStore - read-through обёртка над Store: при промахе значение загружается
+// через LoaderFunc и кладется в хранилище. Одновременные промахи по одному ключу
+// вызывают загрузчик ровно один раз (singleflight), остальные ждут его результат.
+// Загрузка не затирает ключ, который за время её работы записали или удалили напрямую
+// через Store(): такое значение новее загруженного.
+type LoadingStore struct {
+	s    *Store
+	load LoaderFunc
+
+	negativeTTL time.Duration
+	notFoundTTL time.Duration
+	staleWindow time.Duration
+	earlyBeta   float64
+
+	mu       sync.Mutex
+	calls    map[string]*loadCall
+	negative map[string]negativeEntry // закешированные ошибки и отсутствие ключей
+	inserts  int                      // записей в negative с последней чистки
+
+	loads      atomic.Uint64
+	loadErrors atomic.Uint64
+	staleHits  atomic.Uint64
+	early      atomic.Uint64
+	notFound   atomic.Uint64
+}
+
+// loadCall - загрузка в процессе, done закрывается после записи результата
+type loadCall struct {
+	done  chan struct{}
+	value string
+	err   error
+}
+
+type negativeEntry struct {
+	err       error
+	expiresAt time.Time
+}
+
+// LoadingStats - счетчики загрузчика.
+type LoadingStats struct {
+	Loads      uint64 `json:"loads"`      // вызовов загрузчика
+	LoadErrors uint64 `json:"loadErrors"` // из них с ошибкой
+	StaleHits  uint64 `json:"staleHits"`  // отдано устаревших значений с фоновым обновлением
+	Early      uint64 `json:"early"`      // досрочных обновлений по WithEarlyRefresh
+	NotFound   uint64 `json:"notFound"`   // загрузок, вернувших ErrNotFound
+}
+
+// NewLoadingStore создаёт read-through обёртку над s с загрузчиком load.
+func NewLoadingStore(s *Store, load LoaderFunc, opts ...LoadingOption) *LoadingStore {
+	ls := &LoadingStore{
+		s:        s,
+		load:     load,
+		calls:    make(map[string]*loadCall),
+		negative: make(map[string]negativeEntry),
+	}
+	for _, opt := range opts {
+		opt(ls)
+	}
+	return ls
+}
+
+// Get возвращает значение из хранилища, а при промахе - из загрузчика.
+//
+// Загрузчик получает контекст первого промахнувшегося без отмены: если тот, кто запустил
+// загрузку, ушёл по таймауту, остальные ожидающие всё равно получат результат.
+// Каждый вызывающий ждёт не дольше своего ctx.
+func (ls *LoadingStore) Get(ctx context.Context, key string) (string, error) {
+	value, ok, err := ls.s.GetContext(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		if ls.staleWindow > 0 || ls.earlyBeta > 0 {
+			ls.maybeRefresh(ctx, key)
+		}
+		return value, nil
+	}
+
+	now := ls.s.now()
+	ls.mu.Lock()
+	if neg, ok := ls.negative[key]; ok {
+		if now.Before(neg.expiresAt) {
+			ls.mu.Unlock()
+			return "", neg.err
+		}
+		delete(ls.negative, key)
+	}
+	c, ok := ls.calls[key]
+	if !ok {
+		c = &loadCall{done: make(chan struct{})}
+		ls.calls[key] = c
+		if !ls.s.cfg.deterministic {
+			go ls.run(context.WithoutCancel(ctx), key, c)
+		}
+	}
+	ls.mu.Unlock()
+	if !ok && ls.s.cfg.deterministic {
+		ls.run(context.WithoutCancel(ctx), key, c)
+	}
+
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// maybeRefresh запускает фоновое обновление устаревшего значения или досрочное по XFetch
+func (ls *LoadingStore) maybeRefresh(ctx context.Context, key string) {
+	expiry, cost, ok := ls.s.loadInfo(key)
+	if !ok || expiry.IsZero() {
+		return
+	}
+
+	now := ls.s.now()
+	switch {
+	case now.After(expiry):
+		ls.staleHits.Add(1)
+		ls.start(ctx, key)
+	case ls.earlyBeta > 0 && cost > 0:
+		// 1-Float64 лежит в (0, 1], логарифм от нуля не берём
+		gap := time.Duration(-float64(cost) * ls.earlyBeta * math.Log(1-rand.Float64()))
+		if !now.Add(gap).Before(expiry) && ls.start(ctx, key) {
+			ls.early.Add(1)
+		}
+	}
+}
+
+// start запускает фоновую загрузку, если она ещё не идёт, не дожидаясь результата.
+// С WithDeterministic хранилища загрузка идёт сразу, в горутине вызывающего.
+// Возвращает false, если загрузка уже шла
+func (ls *LoadingStore) start(ctx context.Context, key string) bool {
+	ls.mu.Lock()
+	if _, ok := ls.calls[key]; ok {
+		ls.mu.Unlock()
+		return false
+	}
+	c := &loadCall{done: make(chan struct{})}
+	ls.calls[key] = c
+	ls.mu.Unlock()
+
+	if ls.s.cfg.deterministic {
+		ls.run(context.WithoutCancel(ctx), key, c)
+	} else {
+		go ls.run(context.WithoutCancel(ctx), key, c)
+	}
+	return true
+}
+
+// run вызывает загрузчик и раздаёт результат ожидающим. Результат кладется в хранилище,
+// только если ключ не меняли, пока шла загрузка: Set или Delete в это время новее того,
+// что загрузчик прочитал из бэкенда, и затирать их нельзя. Ожидающие тогда всё равно
+// получают загруженное значение
+func (ls *LoadingStore) run(ctx context.Context, key string, c *loadCall) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("store: loader panicked: %v", r)
+		}
+		ttl := ls.negativeTTL
+		switch {
+		case errors.Is(c.err, ErrNotFound):
+			ls.notFound.Add(1)
+			ttl = ls.notFoundTTL
+		case c.err != nil:
+			ls.loadErrors.Add(1)
+			ls.s.cfg.logger.Debug("store: load failed", "key", key, "err", c.err)
+		}
+
+		ls.mu.Lock()
+		delete(ls.calls, key)
+		if c.err != nil && ttl > 0 {
+			ls.rememberLocked(key, c.err, ttl)
+		}
+		ls.mu.Unlock()
+		close(c.done)
+	}()
+
+	ls.loads.Add(1)
+	ls.s.mu.RLock()
+	version := ls.s.versionLocked(ls.s.skey(key), ls.s.now())
+	ls.s.mu.RUnlock()
+	started := ls.s.wallNow()
+	value, ttl, err := ls.load(ctx, key)
+	if err != nil {
+		c.err = err
+		return
+	}
+	w := ctxOpts(ctx)
+	w.staleFor, w.loadCost = ls.staleWindow, ls.s.wallNow().Sub(started)
+	w.ifVersion, w.internal = &version, true
+	switch err := ls.s.set(key, value, ttl, w); {
+	case errors.Is(err, ErrVersionMismatch):
+		ls.s.cfg.logger.Debug("store: key changed during load, loaded value not cached", "key", key)
+	case err != nil:
+		c.err = err
+		return
+	}
+	c.value = value
+}
+
+// SetNegative явно помечает ключ отсутствующим на ttl: Get будет возвращать ErrNotFound,
+// не вызывая загрузчик. Значение ключа в хранилище, если оно есть, удаляется.
+// Пометку снимает Forget, а значение, записанное потом через Store().Set, Get вернёт сразу.
+func (ls *LoadingStore) SetNegative(key string, ttl time.Duration) {
+	ls.s.Delete(key)
+
+	ls.mu.Lock()
+	ls.rememberLocked(key, ErrNotFound, ttl)
+	ls.mu.Unlock()
+}
+
+// rememberLocked кеширует ошибку ключа, изредка вычищая истекшие записи,
+// что-бы перебор несуществующих ключей не раздувал кеш отсутствия. Вызывается под ls.mu
+func (ls *LoadingStore) rememberLocked(key string, err error, ttl time.Duration) {
+	now := ls.s.now()
+	ls.negative[key] = negativeEntry{err: err, expiresAt: now.Add(ttl)}
+
+	if ls.inserts++; ls.inserts < negativeSweepEvery {
+		return
+	}
+	ls.inserts = 0
+	for k, neg := range ls.negative {
+		if !now.Before(neg.expiresAt) {
+			delete(ls.negative, k)
+		}
+	}
+}
+
+// Forget сбрасывает запомненную ошибку загрузки ключа, следующий Get снова вызовет загрузчик.
+func (ls *LoadingStore) Forget(key string) {
+	ls.mu.Lock()
+	delete(ls.negative, key)
+	ls.mu.Unlock()
+}
+
+// Store возвращает обёрнутое хранилище для записи и остальных операций.
+func (ls *LoadingStore) Store() *Store {
+	return ls.s
+}
+
+// Stats возвращает счетчики загрузчика.
+func (ls *LoadingStore) Stats() LoadingStats {
+	return LoadingStats{
+		Loads:      ls.loads.Load(),
+		LoadErrors: ls.loadErrors.Load(),
+		StaleHits:  ls.staleHits.Load(),
+		Early:      ls.early.Load(),
+		NotFound:   ls.notFound.Load(),
+	}
+}
+
+// loadInfo возвращает логический срок истечения значения (без окна stale-while-revalidate)
+// и время его загрузки
+func (s *Store) loadInfo(key string) (expiry time.Time, cost time.Duration, ok bool) {
+	item, ok := s.loadItem(s.skey(key))
+	if !ok {
+		return time.Time{}, 0, false
+	}
+
+	expiry = item.ExpiresAt
+	if !item.freshUntil.IsZero() {
+		expiry = item.freshUntil
+	}
+	return expiry, item.loadCost, true
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockingLoader - загрузчик, который ждёт release и возвращает value
+type blockingLoader struct {
+	started chan struct{}
+	release chan struct{}
+	value   string
+}
+
+func newBlockingLoader(value string) *blockingLoader {
+	return &blockingLoader{started: make(chan struct{}, 1), release: make(chan struct{}), value: value}
+}
+
+func (l *blockingLoader) load(ctx context.Context, key string) (string, time.Duration, error) {
+	l.started <- struct{}{}
+	<-l.release
+	return l.value, time.Second, nil
+}
+
+// waitLoads ждёт, пока у ls не останется загрузок в процессе
+func waitLoads(t *testing.T, ls *LoadingStore) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ls.mu.Lock()
+		n := len(ls.calls)
+		ls.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("load did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLoadingStoreLoadsOnMiss(t *testing.T) {
+	s := newTestStore(t)
+	l := newBlockingLoader("loaded")
+	close(l.release)
+	ls := NewLoadingStore(s, l.load)
+	for range 2 {
+		v, err := ls.Get(context.Background(), "k")
+		if err != nil || v != "loaded" {
+			t.Fatalf("Get = %q, %v", v, err)
+		}
+	}
+	if n := ls.Stats().Loads; n != 1 {
+		t.Errorf("Loads = %d, want 1", n)
+	}
+	wantValue(t, s, "k", "loaded")
+}
+
+func TestLoadDoesNotOverwriteConcurrentSet(t *testing.T) {
+	s := newTestStore(t)
+	l := newBlockingLoader("stale")
+	ls := NewLoadingStore(s, l.load)
+
+	got := make(chan string)
+	go func() {
+		v, _ := ls.Get(context.Background(), "k")
+		got <- v
+	}()
+	<-l.started
+	s.Set("k", "fresh", 0) // бэкенд обновили и записали ключ, пока шла загрузка
+	close(l.release)
+
+	if v := <-got; v != "stale" {
+		t.Errorf("waiting Get = %q, want the loaded value", v)
+	}
+	waitLoads(t, ls)
+	wantValue(t, s, "k", "fresh")
+}
+
+func TestRefreshDoesNotResurrectDeletedKey(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s, err := New(WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newBlockingLoader("v1")
+	ls := NewLoadingStore(s, l.load, WithStaleWhileRevalidate(time.Minute))
+	go func() {
+		<-l.started
+		l.release <- struct{}{} // первая загрузка проходит сразу
+	}()
+	if v, err := ls.Get(context.Background(), "k"); err != nil || v != "v1" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+
+	// значение устарело: Get отдаёт его и обновляет в фоне, а ключ тем временем удаляют
+	clock.Advance(2 * time.Second)
+	if v, err := ls.Get(context.Background(), "k"); err != nil || v != "v1" {
+		t.Fatalf("stale Get = %q, %v", v, err)
+	}
+	<-l.started
+	s.Delete("k")
+	close(l.release)
+	waitLoads(t, ls)
+	wantMissing(t, s, "k")
+}
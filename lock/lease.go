@@ -0,0 +1,102 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Lease - замок, который владелец держит, пока жив: KeepAlive продлевает его пульсом, а
+// если замок потерян (истёк, пока владелец стоял на паузе, удалён или перехвачен), вызывается
+// onLost. Этого хватает для выбора лидера в пределах одного хранилища: лидер - тот, чья
+// аренда жива, остальные ждут её в TryLockContext.
+type Lease struct {
+	l      *Locker
+	key    string
+	token  Token
+	ttl    time.Duration
+	onLost func(key string)
+
+	once     sync.Once     // первое из onLost и Release, второе уже не срабатывает
+	released chan struct{} // закрыт Release
+}
+
+// AcquireLease захватывает замок key на ttl без ожидания, как Acquire, и возвращает аренду.
+// onLost (может быть nil) вызывается один раз, когда KeepAlive замечает потерю замка; после
+// Release не вызывается.
+func (l *Locker) AcquireLease(key string, ttl time.Duration, onLost func(key string)) (*Lease, error) {
+	token, err := l.Acquire(key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{l: l, key: key, token: token, ttl: ttl, onLost: onLost, released: make(chan struct{})}, nil
+}
+
+// Token возвращает fencing-токен аренды.
+func (a *Lease) Token() Token {
+	return a.token
+}
+
+// KeepAlive продлевает аренду на ttl каждую треть ttl, пока не отменится ctx (возвращает
+// ctx.Err()), не вызовут Release (nil) или замок не будет потерян (ErrNotHeld, после onLost).
+// Удаление и перехват замка KeepAlive видит сразу по событиям Watch, истечение - на
+// ближайшем продлении. После того как KeepAlive вернулся, аренду никто не продлевает.
+func (a *Lease) KeepAlive(ctx context.Context) error {
+	key := a.l.prefix + a.key
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := a.l.s.Watch(watchCtx, key, store.WithWatchBuffer(1))
+
+	ticker := time.NewTicker(max(a.ttl/3, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.released:
+			return nil
+		case e, ok := <-events:
+			if !ok || e.Type == store.EventSet && e.Value == a.token.String() {
+				continue // наше же продление
+			}
+			// удаление или чужая запись, но Release мог успеть раньше
+			if !a.l.s.CompareAndExpire(key, a.token.String(), a.ttl) {
+				return a.lost()
+			}
+		case <-ticker.C:
+			if !a.l.s.CompareAndExpire(key, a.token.String(), a.ttl) {
+				return a.lost()
+			}
+		}
+	}
+}
+
+// lost сообщает о потере замка, если аренду не освободили сами
+func (a *Lease) lost() error {
+	select {
+	case <-a.released:
+		return nil
+	default:
+	}
+	if a.onLost != nil {
+		a.once.Do(func() { a.onLost(a.key) })
+	}
+	return ErrNotHeld
+}
+
+// Release освобождает замок и останавливает KeepAlive. Если замок уже потерян, возвращает
+// ErrNotHeld. Повторный Release тоже возвращает ErrNotHeld.
+func (a *Lease) Release() error {
+	first := false
+	a.once.Do(func() {
+		first = true
+		close(a.released)
+	})
+	if !first {
+		return ErrNotHeld
+	}
+	return a.l.Release(a.key, a.token)
+}
@@ -0,0 +1,128 @@
+// Package lock - мьютексы с автоматическим истечением поверх store.Store.
+//
+// Замок - это ключ хранилища с TTL, который захватывается через SetNX. Каждый захват выдаёт
+// fencing-токен: токены строго растут, поэтому ресурс, защищённый замком, может отвергать
+// запросы с токеном меньше уже виденного - от владельца, чей замок истёк, пока он стоял на паузе.
+package lock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+var (
+	// ErrLocked - замок уже захвачен (Acquire).
+	ErrLocked = errors.New("lock: already locked")
+	// ErrNotHeld - замок с этим токеном не захвачен: истёк, освобождён или захвачен заново (Release).
+	ErrNotHeld = errors.New("lock: not held")
+	// ErrInvalidTTL - замок без срока истечения не освободится, если владелец упадёт.
+	ErrInvalidTTL = errors.New("lock: ttl must be positive")
+)
+
+// Token - fencing-токен захвата, строго растёт от захвата к захвату.
+type Token uint64
+
+func (t Token) String() string {
+	return strconv.FormatUint(uint64(t), 10)
+}
+
+// Option настраивает Locker.
+type Option func(*Locker)
+
+// WithPrefix задаёт префикс ключей замков в хранилище, по умолчанию "lock:".
+func WithPrefix(prefix string) Option {
+	return func(l *Locker) {
+		l.prefix = prefix
+	}
+}
+
+// WithRetryInterval задаёт, как часто TryLockContext повторяет попытку, если не пришло
+// событие об освобождении замка, по умолчанию 10ms.
+func WithRetryInterval(d time.Duration) Option {
+	return func(l *Locker) {
+		l.retry = d
+	}
+}
+
+// Locker выдаёт замки на ключах хранилища.
+type Locker struct {
+	s      *store.Store
+	prefix string
+	retry  time.Duration
+
+	fence atomic.Uint64
+}
+
+// New создаёт Locker поверх s.
+func New(s *store.Store, opts ...Option) *Locker {
+	l := &Locker{
+		s:      s,
+		prefix: "lock:",
+		retry:  10 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	// отсчёт от текущего времени: токены растут и после перезапуска процесса
+	// со снапшотом, в котором остались замки прошлого запуска
+	l.fence.Store(uint64(time.Now().UnixNano()))
+	return l
+}
+
+// Acquire захватывает замок key на ttl без ожидания. Если замок занят, возвращается ErrLocked.
+func (l *Locker) Acquire(key string, ttl time.Duration) (Token, error) {
+	if ttl <= 0 {
+		return 0, ErrInvalidTTL
+	}
+	token := Token(l.fence.Add(1))
+	if !l.s.SetNX(l.prefix+key, token.String(), ttl) {
+		return 0, ErrLocked
+	}
+	return token, nil
+}
+
+// Release освобождает замок, если он всё ещё захвачен с token.
+// Чужой или истёкший замок не трогается, возвращается ErrNotHeld.
+func (l *Locker) Release(key string, token Token) error {
+	if !l.s.CompareAndDelete(l.prefix+key, token.String()) {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// TryLockContext ждёт освобождения замка key и захватывает его на ttl.
+// Ожидание прерывается отменой ctx, тогда возвращается ctx.Err().
+func (l *Locker) TryLockContext(ctx context.Context, key string, ttl time.Duration) (Token, error) {
+	token, err := l.Acquire(key, ttl)
+	if !errors.Is(err, ErrLocked) {
+		return token, err
+	}
+
+	// освобождение через Release или Delete приходит событием, а истечение TTL
+	// замечается только при обращении к ключу, поэтому ещё и повторяем по таймеру
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := l.s.Watch(watchCtx, l.prefix+key, store.WithWatchBuffer(1))
+
+	ticker := time.NewTicker(l.retry)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-events:
+		case <-ticker.C:
+		}
+
+		token, err = l.Acquire(key, ttl)
+		if !errors.Is(err, ErrLocked) {
+			return token, err
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package store
+
+// Logger - минимальный интерфейс логгера хранилища. *slog.Logger подходит без адаптеров.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// nopLogger - логгер по умолчанию, ничего не пишет
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
+
+// WithLogger задаёт логгер для событий хранилища: проходы очистки, удаление истекших ключей,
+// сохранение и загрузка снапшотов. По умолчанию хранилище ничего не логирует.
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
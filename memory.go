@@ -0,0 +1,272 @@
+package store
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// itemOverhead - примерный размер служебных данных на элемент: Item, заголовки строк, запись в мапе
+	itemOverhead = 128
+
+	// evictionSample - сколько случайных ключей смотрим при выборе жертвы вытеснения
+	evictionSample = 5
+)
+
+// WithMaxMemory ограничивает примерный объём данных хранилища (ключи, значения и служебные
+// данные элементов). При превышении лимита запись вытесняет другие элементы: сначала истекшие,
+// иначе самые давно записанные из небольшой случайной выборки, как приближенный LRU в Redis.
+// Выборка берётся из ключей самого низкого приоритета, см. SetWithPriority. Другой алгоритм
+// выбора задаёт WithEvictionPolicy, отказ или ожидание вместо вытеснения - WithFullPolicy.
+func WithMaxMemory(bytes int64) Option {
+	return func(c *config) {
+		c.maxMemory = bytes
+	}
+}
+
+// effectiveTTL подставляет TTL по умолчанию
+func (s *Store) effectiveTTL(ttl time.Duration) time.Duration {
+	if ttl == 0 {
+		return s.cfg.defaultTTL
+	}
+	return ttl
+}
+
+// itemSize - примерный размер элемента с ключом
+func itemSize(key string, it *Item) int64 {
+	return int64(len(key)+it.payloadSize()) + itemOverhead
+}
+
+// putLocked кладет элемент в мапу с учётом объёма и оповещает подписчиков, вызывается под s.mu.Lock
+func (s *Store) putLocked(key string, it *Item) {
+	old, ok := s.data[key]
+	size := itemSize(key, it)
+	if ok {
+		s.memUsed -= old.size
+		if old.size > size {
+			s.signalRoomLocked()
+		}
+		s.compressionSaved -= old.compressionSaved()
+		s.priorityCount[old.priority+1]--
+		s.releaseInternLocked(old)
+	}
+	s.priorityCount[it.priority+1]++
+	if !ok && s.ring != nil {
+		s.ring.add(key)
+	}
+	s.internLocked(it)
+	s.compressionSaved += it.compressionSaved()
+	if ok && s.cfg.historyDepth > 0 {
+		s.recordHistoryLocked(key, old, it)
+	}
+	// элемент ещё не опубликован, поля можно заполнить на месте
+	it.size = size
+	if s.pins[key] {
+		it.ExpiresAt = time.Time{} // закреплён PinForever
+	}
+	s.version++
+	it.Version = s.version
+	if it.CreatedAt.IsZero() {
+		it.CreatedAt = it.UpdatedAt
+		if ok && !old.CreatedAt.IsZero() {
+			it.CreatedAt = old.CreatedAt
+		}
+	}
+	delete(s.tombs, key) // новое значение отменяет Restore
+	s.linkDepsLocked(key, old, it)
+	s.groupPutLocked(key, old, it)
+	s.bloomAddLocked(key)
+	s.indexLocked(key, old, it)
+	s.data[key] = it
+	if !ok {
+		s.length.Add(1)
+	}
+	s.peakEntries = max(s.peakEntries, len(s.data))
+	s.mirrorStoreLocked(key, it)
+	s.memUsed += size
+	if ns := s.nsLocked(key); ns != nil {
+		if ok {
+			ns.memUsed -= old.size
+		}
+		ns.members[key] = struct{}{}
+		ns.memUsed += size
+	}
+	if s.watchers.n.Load() > 0 {
+		s.notifyLocked(EventSet, key, it.text(), old.text(), it.attrs)
+	}
+}
+
+// removeLocked удаляет элемент с учётом объёма, why - причина для подписчиков. Вызывается под s.mu.Lock
+func (s *Store) removeLocked(key string, why EventType) bool {
+	old, ok := s.data[key]
+	if !ok {
+		return false
+	}
+	delete(s.data, key)
+	s.length.Add(-1)
+	if s.ring != nil {
+		s.ring.remove(key)
+	}
+	s.mirrorDeleteLocked(key)
+	delete(s.history, key)
+	delete(s.pins, key)
+	size := old.size
+	s.memUsed -= size
+	s.signalRoomLocked()
+	s.compressionSaved -= old.compressionSaved()
+	s.priorityCount[old.priority+1]--
+	s.releaseInternLocked(old)
+	s.unindexLocked(key, old)
+	if ns := s.nsLocked(key); ns != nil {
+		delete(ns.members, key)
+		ns.memUsed -= size
+	}
+	if s.watchers.n.Load() > 0 {
+		s.notifyLocked(why, key, "", old.text(), old.attrs)
+	}
+	s.linkDepsLocked(key, old, nil)
+	s.groupRemoveLocked(key, old, why)
+	s.invalidateDependentsLocked(key)
+	return true
+}
+
+// evictLocked вытесняет элементы, пока объём больше лимита. Ключ protect (только что записанный) не трогаем.
+// Сначала применяются лимиты пространства имён записанного ключа, без protect - всех пространств
+func (s *Store) evictLocked(now time.Time, protect string) {
+	if protect != "" {
+		if ns := s.nsLocked(protect); ns != nil {
+			s.evictNamespaceLocked(ns, now, protect)
+		}
+	} else {
+		for _, ns := range s.namespaces {
+			s.evictNamespaceLocked(ns, now, "")
+		}
+	}
+
+	for s.cfg.maxMemory > 0 && s.memUsed > s.cfg.maxMemory {
+		victim, ok := s.victimLocked(now, protect)
+		if !ok || s.cfg.fullPolicy != FullEvict && !s.data[victim].expiredAt(now) {
+			return // с FullReject и FullBlock живые ключи не вытесняются
+		}
+		s.removeLocked(victim, EventEvict)
+		s.stats.evictions.Add(1)
+		s.cfg.logger.Debug("store: key evicted", "key", victim, "memory", s.memUsed)
+	}
+}
+
+// victimLocked выбирает жертву: истекший ключ любого приоритета или давно записанный
+// ключ самого низкого приоритета, который есть в хранилище, см. SetWithPriority
+func (s *Store) victimLocked(now time.Time, protect string) (string, bool) {
+	for i, n := range s.priorityCount {
+		if n == 0 {
+			continue
+		}
+		victim, ok := "", false
+		if s.ring != nil {
+			victim, ok = s.clockVictimLocked(now, protect, Priority(i-1))
+		} else {
+			victim, ok = s.victimAtLocked(now, protect, Priority(i-1))
+		}
+		if ok {
+			return victim, true
+		}
+	}
+	return "", false
+}
+
+// victimAtLocked выбирает жертву приоритета p из случайной выборки: порядок обхода мапы
+// в Go случаен. Ключи других приоритетов в выборку не входят, но истекшие берутся сразу.
+// С WithAdmission из выборки вытесняется самый редкий ключ, а давность решает при равенстве
+func (s *Store) victimAtLocked(now time.Time, protect string, p Priority) (string, bool) {
+	var (
+		victim string
+		rarest uint8
+		oldest time.Time
+		found  bool
+		seen   int
+
+		expired bool // victim истёк, с WithDeterministic
+	)
+	for key, item := range s.data {
+		if key == protect || s.pinnedLocked(key) {
+			continue
+		}
+		if item.expiredAt(now) {
+			if !s.cfg.deterministic {
+				return key, true
+			}
+			if !expired || key < victim {
+				victim, expired, found = key, true, true
+			}
+			continue
+		}
+		if expired || item.priority != p {
+			continue
+		}
+		var freq uint8
+		if s.freq != nil {
+			freq = s.freq.estimate(key)
+		}
+		if !found || freq < rarest || freq == rarest && (item.UpdatedAt.Before(oldest) ||
+			s.cfg.deterministic && item.UpdatedAt.Equal(oldest) && key < victim) {
+			victim, rarest, oldest, found = key, freq, item.UpdatedAt, true
+		}
+		// с WithDeterministic смотрятся все ключи: выборка зависела бы от порядка обхода мапы
+		if seen++; seen >= evictionSample && !s.cfg.deterministic {
+			break
+		}
+	}
+	return victim, found
+}
+
+// EvictionCandidates возвращает до n ключей в том порядке, в каком их вытеснит WithMaxMemory:
+// сначала истекшие, затем по приоритету и давности записи. Само вытеснение смотрит случайную выборку, поэтому
+// это приближение, но с тем же критерием; с EvictClock порядок задаёт стрелка, и совпадение слабее.
+// Без WithMaxMemory возвращает nil.
+func (s *Store) EvictionCandidates(n int) []string {
+	if s.cfg.maxMemory <= 0 || n <= 0 {
+		return nil
+	}
+	type candidate struct {
+		key       string
+		expired   bool
+		priority  Priority
+		updatedAt time.Time
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	found := make([]candidate, 0, len(s.data))
+	for raw, item := range s.data {
+		if s.pinnedLocked(raw) {
+			continue
+		}
+		if key, ok := s.userKey(raw); ok {
+			found = append(found, candidate{key: key, expired: item.expiredAt(now), priority: item.priority, updatedAt: item.UpdatedAt})
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(found, func(a, b candidate) int {
+		if a.expired != b.expired {
+			if a.expired {
+				return -1
+			}
+			return 1
+		}
+		if a.priority != b.priority {
+			return int(a.priority) - int(b.priority)
+		}
+		if c := a.updatedAt.Compare(b.updatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.key, b.key)
+	})
+
+	keys := make([]string, 0, min(n, len(found)))
+	for _, c := range found[:min(n, len(found))] {
+		keys = append(keys, c.key)
+	}
+	return keys
+}
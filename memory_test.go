@@ -0,0 +1,52 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxMemoryEvictsOldest(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s, err := New(WithDeterministic(), WithClock(clock), WithMaxMemory(testMaxMemory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := strings.Repeat("x", 100)
+	for i := range 20 {
+		if err := s.Set("k"+strconv.Itoa(i), value, 0); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Second)
+		wantWithinLimit(t, s)
+	}
+	wantMissing(t, s, "k0")
+	wantValue(t, s, "k19", value)
+	if s.Stats().Evictions == 0 {
+		t.Error("evictions were not counted")
+	}
+
+	// перезапись освобождает место старого значения, а не занимает его повторно
+	before := s.MemoryStats().TotalBytes
+	s.Set("k19", value, 0)
+	if after := s.MemoryStats().TotalBytes; after != before {
+		t.Errorf("memory after an overwrite = %d B, want %d B", after, before)
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s, err := New(WithClock(clock), WithDefaultTTL(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("default", "v", 0)
+	s.Set("forever", "v", NoExpiration)
+	if ttl, ok := s.TTL("default"); !ok || ttl != time.Minute {
+		t.Errorf("TTL(default) = %v, %v, want 1m", ttl, ok)
+	}
+	clock.Advance(2 * time.Minute)
+	wantMissing(t, s, "default")
+	wantValue(t, s, "forever", "v")
+}
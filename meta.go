@@ -0,0 +1,52 @@
+package store
+
+import (
+	"errors"
+	"maps"
+	"time"
+)
+
+const (
+	maxMetaEntries = 32      // полей метаданных на элемент
+	maxMetaBytes   = 4 << 10 // суммарная длина имён и значений полей
+)
+
+// ErrMetaTooLarge - метаданные SetWithMeta больше 32 полей или 4KB.
+var ErrMetaTooLarge = errors.New("store: item metadata is too large")
+
+// SetWithMeta сохраняет значение как Set вместе с метаданными: источник, etag, content-type
+// и другие короткие поля, которые иначе пришлось бы кодировать в само значение. Хранилище
+// копирует meta, метаданные учитываются в объёме для WithMaxMemory. Запись через Set и
+// другие методы метаданные снимает, Expire, Rename и коллекции их сохраняют.
+func (s *Store) SetWithMeta(key, value string, ttl time.Duration, meta map[string]string) error {
+	if len(meta) > maxMetaEntries || metaSize(meta) > maxMetaBytes {
+		return ErrMetaTooLarge
+	}
+	var m map[string]string
+	if len(meta) > 0 {
+		m = maps.Clone(meta)
+	}
+	return s.set(key, value, ttl, writeOpts{meta: m})
+}
+
+// GetWithMeta возвращает значение как Get и копию метаданных SetWithMeta,
+// nil - ключ записан без них.
+func (s *Store) GetWithMeta(key string) (string, map[string]string, bool) {
+	if t, ok := s.startOp(opGet, key); ok {
+		defer t.stop()
+	}
+	item, ok := s.getItem(s.skey(key))
+	if !ok {
+		return "", nil, false
+	}
+	return item.text(), maps.Clone(item.meta), true
+}
+
+// metaSize - суммарная длина имён и значений полей
+func metaSize(meta map[string]string) int {
+	n := 0
+	for k, v := range meta {
+		n += len(k) + len(v)
+	}
+	return n
+}
@@ -0,0 +1,362 @@
+package store
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// NamespaceSep отделяет имя пространства имён от ключа: ключ "k" пространства "tenant"
+// хранится как "tenant:k" и в таком виде виден через Keys, FullList и снапшот хранилища.
+const NamespaceSep = ":"
+
+// NamespaceOption настраивает пространство имён.
+type NamespaceOption func(*Namespace)
+
+// NamespaceSettings - настройки пространства имён. Настройка, не заданная опцией пространства,
+// наследуется из WithNamespaceDefaults хранилища, а оттуда - из настроек самого хранилища
+// (WithDefaultTTL, WithCodec, WithExpireCallback). Действующие настройки возвращает
+// Namespace.Settings, заданные для пространства явно - Namespace.Overrides.
+type NamespaceSettings struct {
+	DefaultTTL time.Duration `json:"defaultTTL"` // TTL записей с ttl == 0, NoExpiration - без TTL
+	MaxKeys    int           `json:"maxKeys"`    // лимит числа ключей, в действующих настройках 0 - без лимита
+	MaxMemory  int64         `json:"maxMemory"`  // лимит объёма данных в байтах, так же
+
+	Codec    Codec                   `json:"-"` // Codec для SetJSON и GetJSON
+	OnExpire func(key, value string) `json:"-"` // вызывается для истекших ключей пространства
+}
+
+// WithNamespaceTTL задаёт TTL по умолчанию для записей пространства с ttl == 0,
+// вместо WithDefaultTTL хранилища. NoExpiration - записи без TTL, даже если он задан
+// хранилищу или в WithNamespaceDefaults.
+func WithNamespaceTTL(ttl time.Duration) NamespaceOption {
+	return func(ns *Namespace) {
+		ns.own.DefaultTTL = ttl
+	}
+}
+
+// WithNamespaceMaxKeys ограничивает число ключей пространства. Запись сверх лимита
+// вытесняет ключи этого же пространства (сначала истекшие, иначе давно записанные из
+// случайной выборки), так шумный арендатор не вытесняет чужие записи.
+// -1 - без лимита, даже если он задан в WithNamespaceDefaults.
+func WithNamespaceMaxKeys(n int) NamespaceOption {
+	return func(ns *Namespace) {
+		ns.own.MaxKeys = n
+	}
+}
+
+// WithNamespaceMaxMemory ограничивает примерный объём данных пространства, вытеснение
+// как у WithNamespaceMaxKeys. Общий лимит WithMaxMemory продолжает действовать на всё
+// хранилище, его стоит держать не меньше суммы лимитов пространств.
+// -1 - без лимита, даже если он задан в WithNamespaceDefaults.
+func WithNamespaceMaxMemory(bytes int64) NamespaceOption {
+	return func(ns *Namespace) {
+		ns.own.MaxMemory = bytes
+	}
+}
+
+// WithNamespaceExpireCallback вызывает fn для истекших ключей пространства вместо
+// WithExpireCallback хранилища, условия вызова те же. fn получает ключ с префиксом пространства.
+func WithNamespaceExpireCallback(fn func(key, value string)) NamespaceOption {
+	return func(ns *Namespace) {
+		ns.own.OnExpire = fn
+	}
+}
+
+// WithNamespaceDefaults задаёт настройки всех пространств имён хранилища, которые
+// пространство может переопределить своими опциями, см. NamespaceSettings.
+func WithNamespaceDefaults(opts ...NamespaceOption) Option {
+	return func(c *config) {
+		c.nsDefaults = append(c.nsDefaults, opts...)
+	}
+}
+
+// Namespace - изолированное пространство ключей внутри хранилища, например для одного
+// арендатора: свой префикс ключей, свой TTL по умолчанию, свои лимиты, Size, Reset и статистика.
+type Namespace struct {
+	s      *Store
+	name   string
+	prefix string
+	label  string // метка в NamespaceMetrics: name или NamespaceOther, пустая - без меток
+
+	own NamespaceSettings                 // заданное опциями пространства, под s.mu
+	eff atomic.Pointer[NamespaceSettings] // действующие настройки, см. resolveLocked
+
+	members map[string]struct{} // сырые ключи пространства, под s.mu
+	memUsed int64               // под s.mu
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	sets      atomic.Uint64
+	deletes   atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// NamespaceStats - статистика пространства имён.
+type NamespaceStats struct {
+	Keys        int    `json:"keys"`
+	MemoryBytes int64  `json:"memoryBytes"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Sets        uint64 `json:"sets"`
+	Deletes     uint64 `json:"deletes"`
+	Evictions   uint64 `json:"evictions"` // вытеснено лимитами пространства
+}
+
+// Namespace возвращает пространство имён name. Повторный вызов с тем же именем возвращает
+// то же пространство, переданные опции применяются к нему. Ключи, уже записанные
+// с префиксом name+NamespaceSep, попадают в пространство сразу.
+//
+// Имя не может быть пустым или содержать NamespaceSep - это ошибка программы, Namespace паникует.
+func (s *Store) Namespace(name string, opts ...NamespaceOption) *Namespace {
+	if name == "" || strings.Contains(name, NamespaceSep) {
+		panic("store: invalid namespace name " + name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, ok := s.namespaces[name]
+	if !ok {
+		ns = &Namespace{s: s, name: name, prefix: name + NamespaceSep, members: make(map[string]struct{})}
+		s.labelLocked(ns)
+		for raw, item := range s.data {
+			if user, ok := s.userKey(raw); ok && strings.HasPrefix(user, ns.prefix) {
+				ns.members[raw] = struct{}{}
+				ns.memUsed += item.size
+			}
+		}
+		if s.namespaces == nil {
+			s.namespaces = make(map[string]*Namespace)
+		}
+		s.namespaces[name] = ns
+	}
+	for _, opt := range opts {
+		opt(ns)
+	}
+	ns.resolveLocked()
+	s.evictNamespaceLocked(ns, s.now(), "")
+	return ns
+}
+
+// resolveLocked собирает действующие настройки: опции пространства, поверх
+// WithNamespaceDefaults, поверх настроек хранилища. Вызывается под s.mu.Lock
+func (ns *Namespace) resolveLocked() {
+	var defaults Namespace
+	for _, opt := range ns.s.cfg.nsDefaults {
+		opt(&defaults)
+	}
+	eff := inheritSettings(ns.own, defaults.own)
+	eff = inheritSettings(eff, NamespaceSettings{
+		DefaultTTL: ns.s.cfg.defaultTTL,
+		Codec:      ns.s.cfg.codec,
+		OnExpire:   ns.s.cfg.onExpire,
+	})
+	eff.MaxKeys, eff.MaxMemory = max(eff.MaxKeys, 0), max(eff.MaxMemory, 0)
+	ns.eff.Store(&eff)
+}
+
+// inheritSettings заполняет незаданные (нулевые) поля own из parent
+func inheritSettings(own, parent NamespaceSettings) NamespaceSettings {
+	if own.DefaultTTL == 0 {
+		own.DefaultTTL = parent.DefaultTTL
+	}
+	if own.MaxKeys == 0 {
+		own.MaxKeys = parent.MaxKeys
+	}
+	if own.MaxMemory == 0 {
+		own.MaxMemory = parent.MaxMemory
+	}
+	if own.Codec == nil {
+		own.Codec = parent.Codec
+	}
+	if own.OnExpire == nil {
+		own.OnExpire = parent.OnExpire
+	}
+	return own
+}
+
+// Settings возвращает действующие настройки пространства, с унаследованными.
+func (ns *Namespace) Settings() NamespaceSettings {
+	return *ns.eff.Load()
+}
+
+// Overrides возвращает настройки, заданные опциями этого пространства, остальные поля нулевые.
+func (ns *Namespace) Overrides() NamespaceSettings {
+	ns.s.mu.RLock()
+	defer ns.s.mu.RUnlock()
+	return ns.own
+}
+
+// nsLocked - пространство имён сырого ключа, nil если ключ ни в одном. Вызывается под s.mu
+func (s *Store) nsLocked(raw string) *Namespace {
+	if len(s.namespaces) == 0 {
+		return nil
+	}
+	user, ok := s.userKey(raw)
+	if !ok {
+		return nil
+	}
+	name, _, found := strings.Cut(user, NamespaceSep)
+	if !found {
+		return nil
+	}
+	return s.namespaces[name]
+}
+
+// Name возвращает имя пространства.
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+// Get возвращает значение ключа пространства, см. Store.Get.
+func (ns *Namespace) Get(key string) (string, bool) {
+	value, ok := ns.s.Get(ns.prefix + key)
+	if ok {
+		ns.hits.Add(1)
+	} else {
+		ns.misses.Add(1)
+	}
+	return value, ok
+}
+
+// Set сохраняет значение в пространстве, ttl == 0 - TTL пространства по умолчанию,
+// см. NamespaceSettings.
+func (ns *Namespace) Set(key, value string, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = ns.Settings().DefaultTTL
+	}
+	if err := ns.s.Set(ns.prefix+key, value, ttl); err != nil {
+		return err
+	}
+	ns.sets.Add(1)
+	return nil
+}
+
+// Delete удаляет ключ пространства.
+func (ns *Namespace) Delete(key string) {
+	ns.s.Delete(ns.prefix + key)
+	ns.deletes.Add(1)
+}
+
+// TTL возвращает оставшееся время жизни ключа пространства, см. Store.TTL.
+func (ns *Namespace) TTL(key string) (time.Duration, bool) {
+	return ns.s.TTL(ns.prefix + key)
+}
+
+// Expire задаёт ключу пространства новый TTL, см. Store.Expire.
+func (ns *Namespace) Expire(key string, ttl time.Duration) bool {
+	return ns.s.Expire(ns.prefix+key, ttl)
+}
+
+// Keys возвращает отсортированные ключи пространства (без префикса), подходящие под glob-шаблон.
+func (ns *Namespace) Keys(pattern string) []string {
+	keys := ns.s.Keys(escapeGlob(ns.prefix) + pattern)
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, ns.prefix)
+	}
+	return keys
+}
+
+// Size возвращает число ключей пространства, включая истекшие, но ещё не удаленные,
+// как и Store.Size.
+func (ns *Namespace) Size() int {
+	ns.s.mu.RLock()
+	defer ns.s.mu.RUnlock()
+	return len(ns.members)
+}
+
+// Reset удаляет все ключи пространства, не трогая остальные. Возвращает число удаленных ключей.
+// Каждый ключ удаляется как Delete: подписчики, WithSink, журнал аудита и счётчики удалений
+// видят его отдельно.
+func (ns *Namespace) Reset() int {
+	s := ns.s
+	if s.enter() != nil {
+		return 0
+	}
+	defer s.leave()
+	s.mu.Lock()
+	now := s.now()
+	removed := make([]string, 0, len(ns.members))
+	for raw := range ns.members {
+		if !s.removeLocked(raw, EventDelete) {
+			continue
+		}
+		s.stats.deletes.Add(1)
+		s.sinkBulkLocked(EventDelete, raw, nil, now)
+		key, _ := s.userKey(raw)
+		removed = append(removed, key)
+	}
+	s.mu.Unlock()
+
+	ns.deletes.Add(uint64(len(removed)))
+	for _, key := range removed {
+		s.audit(AuditDelete, key, writeOpts{})
+	}
+	s.cfg.logger.Info("store: namespace reset", "namespace", ns.name, "removed", len(removed))
+	return len(removed)
+}
+
+// Stats возвращает статистику пространства.
+func (ns *Namespace) Stats() NamespaceStats {
+	ns.s.mu.RLock()
+	defer ns.s.mu.RUnlock()
+	return ns.statsLocked()
+}
+
+// statsLocked - Stats, вызывается под s.mu
+func (ns *Namespace) statsLocked() NamespaceStats {
+	return NamespaceStats{
+		Keys:        len(ns.members),
+		MemoryBytes: ns.memUsed,
+		Hits:        ns.hits.Load(),
+		Misses:      ns.misses.Load(),
+		Sets:        ns.sets.Load(),
+		Deletes:     ns.deletes.Load(),
+		Evictions:   ns.evictions.Load(),
+	}
+}
+
+func (ns *Namespace) overLimitLocked() bool {
+	eff := ns.eff.Load()
+	return (eff.MaxKeys > 0 && len(ns.members) > eff.MaxKeys) ||
+		(eff.MaxMemory > 0 && ns.memUsed > eff.MaxMemory)
+}
+
+// evictNamespaceLocked вытесняет ключи пространства, пока оно превышает свои лимиты.
+// Выборка как в victimLocked, но только среди ключей пространства; приоритеты сравниваются
+// внутри выборки. Вызывается под s.mu.Lock
+func (s *Store) evictNamespaceLocked(ns *Namespace, now time.Time, protect string) {
+	for ns.overLimitLocked() {
+		var (
+			victim string
+			lowest Priority
+			oldest time.Time
+			found  bool
+			seen   int
+		)
+		for raw := range ns.members {
+			if raw == protect || s.pinnedLocked(raw) {
+				continue
+			}
+			item := s.data[raw]
+			if item.expiredAt(now) {
+				victim, found = raw, true
+				break
+			}
+			if !found || item.priority < lowest || item.priority == lowest && item.UpdatedAt.Before(oldest) {
+				victim, lowest, oldest, found = raw, item.priority, item.UpdatedAt, true
+			}
+			if seen++; seen >= evictionSample {
+				break
+			}
+		}
+		if !found {
+			return
+		}
+		s.removeLocked(victim, EventEvict)
+		ns.evictions.Add(1)
+		s.stats.evictions.Add(1)
+		s.cfg.logger.Debug("store: key evicted by namespace limit", "namespace", ns.name, "key", victim)
+	}
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// NamespaceOther - метка пространств имён сверх лимита WithNamespaceMetrics, их статистика
+// в NamespaceMetrics и в метриках складывается под этим именем.
+const NamespaceOther = "_other"
+
+// defaultNamespaceLabels - пространств со своей меткой без WithNamespaceMetrics
+const defaultNamespaceLabels = 100
+
+// WithNamespaceMetrics ограничивает число пространств имён, которые получают свою метку
+// namespace в NamespaceMetrics, WritePrometheus и otelstore, по умолчанию 100. Метку получают
+// первые limit созданных пространств, остальные считаются вместе под NamespaceOther: так число
+// рядов в системе метрик не растёт с числом арендаторов, а счётчики каждой метки не убывают.
+// limit < 0 - без меток по пространствам.
+func WithNamespaceMetrics(limit int) Option {
+	return func(c *config) {
+		c.nsLabels = limit
+	}
+}
+
+// nsLabelLimit - сколько пространств получают свою метку
+func (c *config) nsLabelLimit() int {
+	switch {
+	case c.nsLabels < 0:
+		return 0
+	case c.nsLabels == 0:
+		return defaultNamespaceLabels
+	}
+	return c.nsLabels
+}
+
+// labelLocked выбирает метку нового пространства, вызывается под s.mu.Lock
+func (s *Store) labelLocked(ns *Namespace) {
+	if s.cfg.nsLabels < 0 {
+		return
+	}
+	if s.nsLabeled < s.cfg.nsLabelLimit() {
+		ns.label = ns.name
+		s.nsLabeled++
+		return
+	}
+	ns.label = NamespaceOther
+}
+
+// NamespaceMetrics возвращает статистику пространств имён по меткам WithNamespaceMetrics:
+// пространства сверх лимита сложены под NamespaceOther. nil - пространств нет или метки
+// выключены. Для выгрузки в системы метрик, полную статистику возвращает Stats.
+func (s *Store) NamespaceMetrics() map[string]NamespaceStats {
+	s.mu.RLock()
+	list := make([]*Namespace, 0, len(s.namespaces))
+	for _, ns := range s.namespaces {
+		if ns.label != "" {
+			list = append(list, ns)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(list) == 0 {
+		return nil
+	}
+	out := make(map[string]NamespaceStats, min(len(list), s.cfg.nsLabelLimit()+1))
+	for _, ns := range list {
+		st := ns.Stats()
+		sum := out[ns.label]
+		sum.Keys += st.Keys
+		sum.MemoryBytes += st.MemoryBytes
+		sum.Hits += st.Hits
+		sum.Misses += st.Misses
+		sum.Sets += st.Sets
+		sum.Deletes += st.Deletes
+		sum.Evictions += st.Evictions
+		out[ns.label] = sum
+	}
+	return out
+}
+
+// writeNamespaceMetrics пишет NamespaceMetrics в формате Prometheus с меткой namespace
+func (s *Store) writeNamespaceMetrics(w io.Writer) {
+	metrics := s.NamespaceMetrics()
+	if len(metrics) == 0 {
+		return
+	}
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	series := []struct {
+		name, typ, help string
+		value           func(NamespaceStats) string
+	}{
+		{"store_namespace_keys", "gauge", "Number of keys in the namespace.",
+			func(st NamespaceStats) string { return strconv.Itoa(st.Keys) }},
+		{"store_namespace_memory_bytes", "gauge", "Approximate size of the namespace data.",
+			func(st NamespaceStats) string { return strconv.FormatInt(st.MemoryBytes, 10) }},
+		{"store_namespace_hits_total", "counter", "Namespace reads that found a live key.",
+			func(st NamespaceStats) string { return strconv.FormatUint(st.Hits, 10) }},
+		{"store_namespace_misses_total", "counter", "Namespace reads that found no live key.",
+			func(st NamespaceStats) string { return strconv.FormatUint(st.Misses, 10) }},
+		{"store_namespace_sets_total", "counter", "Successful namespace writes.",
+			func(st NamespaceStats) string { return strconv.FormatUint(st.Sets, 10) }},
+		{"store_namespace_evictions_total", "counter", "Keys evicted by namespace limits.",
+			func(st NamespaceStats) string { return strconv.FormatUint(st.Evictions, 10) }},
+	}
+	for _, m := range series {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s{namespace=%q} %s\n", m.name, name, m.value(metrics[name]))
+		}
+	}
+}
@@ -0,0 +1,538 @@
+package store
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotInteger - значение ключа не является целым числом (IncrBy).
+var ErrNotInteger = errors.New("store: value is not an integer or out of range")
+
+// expiredAt - истёк ли элемент к моменту now
+func (it *Item) expiredAt(now time.Time) bool {
+	return !it.ExpiresAt.IsZero() && now.After(it.ExpiresAt)
+}
+
+// copyItem копирует элемент для замены: элементы в мапе не меняются на месте,
+// потому что Get читает их поля уже после RUnlock
+func (it *Item) copyItem() *Item {
+	c := &Item{
+		Value:      it.Value,
+		ExpiresAt:  it.ExpiresAt,
+		CreatedAt:  it.CreatedAt,
+		UpdatedAt:  it.UpdatedAt,
+		Provenance: it.Provenance,
+		kind:       it.kind,
+		hash:       it.hash,
+		list:       it.list,
+		set:        it.set,
+		zset:       it.zset,
+		counter:    it.counter,
+		hll:        it.hll,
+		maxViews:   it.maxViews,
+		priority:   it.priority,
+		tags:       it.tags,
+		deps:       it.deps,
+		group:      it.group,
+		meta:       it.meta,
+		rawSize:    it.rawSize,
+		attrs:      it.attrs,
+	}
+	c.Views.Store(it.Views.Load())
+	c.accessedAt.Store(it.accessedAt.Load())
+	c.referenced.Store(it.referenced.Load())
+	return c
+}
+
+// expiresAt переводит ttl в момент истечения, ttl <= 0 - без истечения
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// SetNX сохраняет значение, только если ключа нет (или он истёк). Возвращает true, если значение записано.
+// В строгом режиме StrictError некорректная запись не выполняется и возвращается false.
+func (s *Store) SetNX(key, value string, ttl time.Duration) bool {
+	return s.setIf(key, value, ttl, false)
+}
+
+// SetXX сохраняет значение, только если ключ уже существует и не истёк. Возвращает true, если значение записано.
+func (s *Store) SetXX(key, value string, ttl time.Duration) bool {
+	return s.setIf(key, value, ttl, true)
+}
+
+// setIf - условная запись: проверка и запись под одной блокировкой
+func (s *Store) setIf(key, value string, ttl time.Duration, mustExist bool) bool {
+	if t, ok := s.startOp(opSet, key); ok {
+		defer t.stop()
+	}
+	if err := s.checkWrite(key, ttl); err != nil {
+		return false
+	}
+	if err := s.checkValueSize(key, len(value)); err != nil {
+		return false
+	}
+	written := false
+	s.withRoom(key, nil, func() (err error) {
+		written, err = s.setIfOnce(key, value, ttl, mustExist)
+		return err
+	})
+	return written
+}
+
+// setIfOnce - одна попытка setIf
+func (s *Store) setIfOnce(key, value string, ttl time.Duration, mustExist bool) (bool, error) {
+	if err := s.beginWrite(writeOpts{}); err != nil {
+		return false, err
+	}
+	defer s.endWrite()
+
+	userKey := key
+	key = s.wkey(key)
+	item := s.newItem()
+	s.setCompressed(item, value) // сжимаем до блокировки
+
+	now := s.now()
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	cur, ok := s.data[key]
+	exists := ok && !cur.expiredAt(now)
+	if exists != mustExist {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return false, nil
+	}
+	item.ExpiresAt, item.UpdatedAt = expiresAt(now, s.effectiveTTL(ttl)), now
+	admitted, full, err := s.placeLocked(key, item, now)
+	if !admitted {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		s.placed(userKey, full, err)
+		return false, err
+	}
+	s.putLocked(key, item)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, item, now)
+	}
+	s.evictLocked(now, key)
+	s.mu.Unlock()
+	s.placed(userKey, full, nil)
+
+	s.stats.sets.Add(1)
+	s.resolveMiss(key)
+	s.push(key)
+	return true, nil
+}
+
+// CompareAndDelete удаляет ключ, только если он не истёк и его значение равно value.
+// Возвращает true, если ключ удален. Проверка и удаление выполняются под одной блокировкой.
+func (s *Store) CompareAndDelete(key, value string) bool {
+	if s.beginWrite(writeOpts{}) != nil {
+		return false
+	}
+	defer s.endWrite()
+	key = s.skey(key)
+	now := s.now()
+
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.data[key]
+	if !ok || cur.expiredAt(now) || cur.kind != kindString || cur.plain() != value {
+		s.sinkRelease(queued)
+		return false
+	}
+	s.removeLocked(key, EventDelete)
+	s.stats.deletes.Add(1)
+	if queued {
+		s.sinkAppendLocked(EventDelete, key, nil, now)
+	}
+	return true
+}
+
+// CompareAndExpire задаёт ключу новый TTL, как Expire, только если он не истёк и его значение
+// равно value: так владелец продлевает свой замок или аренду и не продлевает чужую.
+// Проверка и продление выполняются под одной блокировкой.
+func (s *Store) CompareAndExpire(key, value string, ttl time.Duration) bool {
+	if err := s.checkWrite(key, ttl); err != nil {
+		return false
+	}
+	if s.beginWrite(writeOpts{}) != nil {
+		return false
+	}
+	defer s.endWrite()
+	key = s.skey(key)
+	now := s.now()
+
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.data[key]
+	if !ok || cur.expiredAt(now) || cur.kind != kindString || cur.plain() != value {
+		s.sinkRelease(queued)
+		return false
+	}
+	next := cur.copyItem()
+	next.ExpiresAt = expiresAt(now, ttl)
+	s.putLocked(key, next)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, next, now)
+	}
+	return true
+}
+
+// TTL возвращает оставшееся время жизни ключа. exists=false, если ключа нет или он истёк;
+// ttl == 0 при exists=true означает, что срок истечения не задан. Просмотры не увеличиваются.
+func (s *Store) TTL(key string) (ttl time.Duration, exists bool) {
+	item, ok := s.loadItem(s.skey(key))
+
+	now := s.now()
+	if !ok || item.expiredAt(now) {
+		return 0, false
+	}
+	if item.ExpiresAt.IsZero() {
+		return 0, true
+	}
+	return item.ExpiresAt.Sub(now), true
+}
+
+// Exists сообщает, есть ли ключ и не истёк ли он. В отличие от Get, не считается чтением:
+// Views, время последнего чтения, счетчики попаданий, горячие ключи и порядок
+// WithTouchOnGet не меняются, а истекший ключ не удаляется.
+func (s *Store) Exists(key string) bool {
+	return s.exists(s.skey(key), s.now())
+}
+
+// ExistsMany - Exists для нескольких ключей, результат в порядке keys. Ключи проверяются
+// по одному, так что это не снимок: ключ может появиться или пропасть между проверками.
+func (s *Store) ExistsMany(keys ...string) []bool {
+	now := s.now()
+	res := make([]bool, len(keys))
+	for i, key := range keys {
+		res[i] = s.exists(s.skey(key), now)
+	}
+	return res
+}
+
+// ItemDetails - ключ с метаданными для GetManyDetailed.
+type ItemDetails struct {
+	ItemDTO
+	TTL time.Duration // сколько осталось на момент вызова, 0 - без срока истечения, как у TTL
+}
+
+// GetManyDetailed возвращает значения keys вместе с TTL, Views и остальными полями ItemDTO
+// за один проход под RLock, вместо Get, GetViews и TTL на каждый ключ: для админок и
+// дашбордов, где N ключей - это 3N блокировок. В отличие от Get, не считается чтением:
+// Views и время последнего чтения не меняются. Отсутствующих и истекших ключей в результате нет.
+func (s *Store) GetManyDetailed(keys ...string) map[string]ItemDetails {
+	raws := make([]string, len(keys))
+	for i, key := range keys {
+		raws[i] = s.skey(key)
+	}
+	now := s.now()
+	res := make(map[string]ItemDetails, len(keys))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, raw := range raws {
+		item, ok := s.data[raw]
+		if !ok || item.expiredAt(now) {
+			continue
+		}
+		d := ItemDetails{ItemDTO: itemDTO(item, ListAll)}
+		if !item.ExpiresAt.IsZero() {
+			d.TTL = item.ExpiresAt.Sub(now)
+		}
+		res[keys[i]] = d
+	}
+	return res
+}
+
+func (s *Store) exists(key string, now time.Time) bool {
+	s.activateKey(key)
+	if s.bloomAbsent(key) {
+		return false
+	}
+	item, ok := s.loadItem(key)
+	return ok && !item.expiredAt(now)
+}
+
+// Expiry - ключ и момент его истечения, см. Expiring и ExpiringSoon.
+type Expiry struct {
+	Key       string        `json:"key"`
+	ExpiresAt time.Time     `json:"expiresAt"`
+	TTL       time.Duration `json:"ttl"` // сколько осталось на момент вызова, у истекших - отрицательное
+}
+
+// Expiring возвращает ключи, которые истекут в ближайшие within, от истекающих раньше.
+// Уже истекшие, но ещё не удалённые Cleanup ключи тоже попадают. Просмотры не увеличиваются.
+// С индексом IndexExpiry (WithIndexes) обходятся только такие ключи, без него - все.
+func (s *Store) Expiring(within time.Duration) []Expiry {
+	now := s.now()
+	deadline := now.Add(within)
+	var res []Expiry
+	add := func(raw string, at time.Time) {
+		if key, ok := s.userKey(raw); ok {
+			res = append(res, Expiry{Key: key, ExpiresAt: at, TTL: at.Sub(now)})
+		}
+	}
+
+	s.mu.RLock()
+	if idx := s.idx; idx != nil && idx.kinds&IndexExpiry != 0 {
+		tascend(idx.expiry, expiryKey{at: math.MinInt64}, cmpExpiryKey, func(k expiryKey, _ struct{}) bool {
+			if k.at > deadline.UnixNano() {
+				return false
+			}
+			add(k.key, s.data[k.key].ExpiresAt)
+			return true
+		})
+		s.mu.RUnlock()
+		return res // индекс обходится по возрастанию срока, сортировать не нужно
+	}
+	for raw, item := range s.data {
+		if item.ExpiresAt.IsZero() || item.ExpiresAt.After(deadline) {
+			continue
+		}
+		add(raw, item.ExpiresAt)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(res, func(a, b Expiry) int {
+		if c := a.ExpiresAt.Compare(b.ExpiresAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return res
+}
+
+// ExpiringSoon возвращает n неистекших ключей, которые истекут раньше всех, от ближайшего
+// срока, с оставшимся TTL - помогает разобраться, почему ключ пропал. Ключи без срока
+// истечения не попадают. С индексом IndexExpiry стоит O(log N + n), без него - проход по всем
+// ключам с кучей из n элементов, O(N log n).
+func (s *Store) ExpiringSoon(n int) []Expiry {
+	if n <= 0 {
+		return nil
+	}
+	now := s.now()
+	res := make([]Expiry, 0, min(n, 1024))
+
+	s.mu.RLock()
+	if idx := s.idx; idx != nil && idx.kinds&IndexExpiry != 0 {
+		// с now: истекшие ключи - с ExpiresAt строго раньше now
+		tascend(idx.expiry, expiryKey{at: now.UnixNano()}, cmpExpiryKey, func(k expiryKey, _ struct{}) bool {
+			if key, ok := s.userKey(k.key); ok {
+				at := s.data[k.key].ExpiresAt
+				res = append(res, Expiry{Key: key, ExpiresAt: at, TTL: at.Sub(now)})
+			}
+			return len(res) < n
+		})
+		s.mu.RUnlock()
+		return res
+	}
+	soon := &listHeap{cmp: compareCandidates, items: make([]listCandidate, 0, min(n, 1024))}
+	for raw, item := range s.data {
+		if item.ExpiresAt.IsZero() || item.expiredAt(now) {
+			continue
+		}
+		key, ok := s.userKey(raw)
+		if !ok {
+			continue
+		}
+		c := listCandidate{key: key, val: item.ExpiresAt.UnixNano(), item: item}
+		if soon.Len() < n {
+			heap.Push(soon, c)
+		} else if compareCandidates(c, soon.items[0]) < 0 {
+			soon.items[0] = c
+			heap.Fix(soon, 0)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(soon.items, compareCandidates)
+	for _, c := range soon.items {
+		res = append(res, Expiry{Key: c.key, ExpiresAt: c.item.ExpiresAt, TTL: c.item.ExpiresAt.Sub(now)})
+	}
+	return res
+}
+
+// Expire задаёт ключу новый TTL, ttl <= 0 снимает срок истечения.
+// Возвращает false, если ключа нет или он истёк.
+func (s *Store) Expire(key string, ttl time.Duration) bool {
+	if err := s.checkWrite(key, ttl); err != nil {
+		return false
+	}
+	if s.beginWrite(writeOpts{}) != nil {
+		return false
+	}
+	defer s.endWrite()
+
+	key = s.skey(key)
+	now := s.now()
+
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.data[key]
+	if !ok || cur.expiredAt(now) {
+		s.sinkRelease(queued)
+		return false
+	}
+	next := cur.copyItem()
+	next.ExpiresAt = expiresAt(now, ttl)
+	s.putLocked(key, next)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, next, now)
+	}
+	return true
+}
+
+// ExtendTTL атомарно продлевает срок ключа на by, но не дальше maxTotal от появления ключа
+// (CreatedAt), и возвращает новый остаток, как TTL: продление аренды пульсом, у которой
+// есть предельный срок. Срок не сокращается, даже если потолок уже пройден; maxTotal <= 0 -
+// без потолка. Ключ без срока истечения остаётся без него. false - ключа нет или он истёк.
+func (s *Store) ExtendTTL(key string, by, maxTotal time.Duration) (time.Duration, bool) {
+	if s.checkWrite(key, by) != nil || by <= 0 {
+		return 0, false
+	}
+	if s.beginWrite(writeOpts{}) != nil {
+		return 0, false
+	}
+	defer s.endWrite()
+
+	key = s.skey(key)
+	now := s.now()
+
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.data[key]
+	if !ok || cur.expiredAt(now) {
+		s.sinkRelease(queued)
+		return 0, false
+	}
+	if cur.ExpiresAt.IsZero() {
+		s.sinkRelease(queued)
+		return 0, true
+	}
+	until := cur.ExpiresAt.Add(by)
+	if maxTotal > 0 {
+		if ceiling := cur.CreatedAt.Add(maxTotal); until.After(ceiling) {
+			until = ceiling
+		}
+	}
+	if !until.After(cur.ExpiresAt) {
+		s.sinkRelease(queued)
+		return cur.ExpiresAt.Sub(now), true
+	}
+	next := cur.copyItem()
+	next.ExpiresAt = until
+	s.putLocked(key, next)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, next, now)
+	}
+	return until.Sub(now), true
+}
+
+// IncrBy атомарно прибавляет delta к целому значению ключа и возвращает результат.
+// Отсутствующий ключ считается равным 0 и создаётся с TTL по умолчанию (WithDefaultTTL),
+// у существующего ключа TTL сохраняется. Если значение не целое или результат
+// переполняет int64, возвращается ErrNotInteger. Новому ключу, которому не хватило места
+// (WithFullPolicy) или которого не пропустил фильтр допуска (WithAdmission), - ErrFull.
+func (s *Store) IncrBy(key string, delta int64) (int64, error) {
+	if err := s.checkWrite(key, 0); err != nil {
+		return 0, err
+	}
+	var n int64
+	err := s.withRoom(key, nil, func() (err error) {
+		n, err = s.incrByOnce(key, delta)
+		return err
+	})
+	return n, err
+}
+
+// incrByOnce - одна попытка IncrBy
+func (s *Store) incrByOnce(key string, delta int64) (int64, error) {
+	if err := s.beginWrite(writeOpts{}); err != nil {
+		return 0, err
+	}
+	defer s.endWrite()
+
+	userKey := key
+	key = s.wkey(key)
+	now := s.now()
+
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	cur, ok := s.data[key]
+	if ok && cur.expiredAt(now) {
+		ok = false
+	}
+
+	var n int64
+	next := &Item{UpdatedAt: now, ExpiresAt: expiresAt(now, s.effectiveTTL(0))}
+	if ok {
+		if cur.kind != kindString {
+			s.mu.Unlock()
+			s.sinkRelease(queued)
+			return 0, ErrWrongType
+		}
+		var err error
+		n, err = strconv.ParseInt(cur.Value, 10, 64)
+		if err != nil {
+			s.mu.Unlock()
+			s.sinkRelease(queued)
+			return 0, ErrNotInteger
+		}
+		next = cur.copyItem()
+		next.UpdatedAt = now
+	}
+
+	if (delta > 0 && n > maxInt64-delta) || (delta < 0 && n < minInt64-delta) {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return 0, ErrNotInteger
+	}
+	n += delta
+	next.Value, next.rawSize = strconv.FormatInt(n, 10), 0
+	admitted, full, err := s.placeLocked(key, next, now)
+	if !admitted {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		s.placed(userKey, full, err)
+		if err == nil {
+			err = ErrFull // отказ фильтра допуска: значение не записано
+		}
+		return 0, err
+	}
+	s.putLocked(key, next)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, next, now)
+	}
+	s.evictLocked(now, key)
+	s.mu.Unlock()
+	s.placed(userKey, full, nil)
+
+	s.stats.sets.Add(1)
+	s.resolveMiss(key)
+	if !ok {
+		s.push(key)
+	}
+	return n, nil
+}
+
+const (
+	maxInt64 = 1<<63 - 1
+	minInt64 = -1 << 63
+)
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConfig - опции New противоречат друг другу или вне допустимых значений.
+var ErrInvalidConfig = errors.New("store: invalid config")
+
+// Option настраивает хранилище при создании через New или NewStore.
+type Option func(*config)
+
+// config - набор настроек хранилища
+type config struct {
+	expvarName string // имя переменной expvar, пустое - не публикуем
+	logger     Logger
+	clock      Clock // источник времени, см. WithClock
+	codec      Codec // сериализация SetJSON и GetJSON, см. WithCodec
+
+	snapshotCodec Codec  // формат снапшота, nil - JSON потоком, см. WithSnapshotCodec
+	keyVersion    string // версия схемы ключей, см. WithKeyVersion
+	strict        StrictMode
+	latency       bool // собирать гистограммы задержек, см. WithLatencyHistograms
+	lockTiming    bool // удержание блокировки и время колбэков, см. WithLockTiming
+	approxStats   bool // Stats без ожидания блокировки, см. WithApproximateStats
+
+	maxIdle time.Duration // удалять ключи без обращений дольше, 0 - выключено, см. WithMaxIdle
+
+	invariantEvery time.Duration // период проверки инвариантов, см. WithInvariantChecks
+
+	missDedupWindow time.Duration // окно дедупликации промахов, 0 - выключено
+
+	keyPolicy    KeyPolicy // правила проверки ключей, см. WithKeyPolicy
+	maxKeyLen    int       // максимальная длина ключа, 0 - без ограничения
+	maxValueSize int       // максимальный размер значения, 0 - без ограничения
+	compressMin  int       // сжимать значения от этого размера, 0 - не сжимать
+
+	zeroCopyBytes bool                    // SetBytes и GetBytes без копирования, см. WithZeroCopyBytes
+	keyValidator  func(key string) error  // собственная проверка ключа
+	keyTransform  func(key string) string // приведение ключа к каноническому виду, см. WithKeyTransformer
+	keyHashMin    int                     // ключи длиннее хранятся под хешем, см. WithKeyHashing
+
+	cleanupMin    time.Duration // границы периода RunCleanup, см. WithAdaptiveCleanup
+	cleanupMax    time.Duration
+	cleanupBatch  int           // ключей за шаг очистки, 0 - очистка одним проходом, см. WithIncrementalCleanup
+	cleanupBudget time.Duration // ограничение времени прохода очистки
+
+	viewsHalfLife  time.Duration // период полураспада Views, см. WithViewsDecay
+	historyDepth   int           // сколько прежних значений ключа хранить, см. WithHistory
+	tombstoneGrace time.Duration // сколько хранить значения DeleteSoft, см. WithTombstoneGrace
+
+	nsDefaults []NamespaceOption // настройки пространств имён по умолчанию, см. WithNamespaceDefaults
+	nsLabels   int               // пространств со своей меткой в метриках, см. WithNamespaceMetrics
+
+	writeConcurrency int // одновременных Set и Delete не больше, 0 - без ограничения, см. WithWriteConcurrency
+
+	auditCapacity int               // размер журнала аудита, 0 - не хранить, см. WithAuditLog
+	auditHook     func(AuditRecord) // вызывается на каждую запись аудита, см. WithAuditHook
+
+	slowThreshold time.Duration // операции не короче попадают в медленный журнал, см. WithSlowLog
+	slowCapacity  int           // размер медленного журнала, 0 - выключен
+
+	recentCapacity int  // размер журнала последних записей, см. WithRecentCapacity
+	recentMRU      bool // журнал без повторов, см. WithLastKeysMRU
+	touchOnGet     bool // Get тоже попадает в журнал, см. WithTouchOnGet
+
+	statPrefixes []string // префиксы ключей для статистики, см. WithPrefixStats
+
+	hotThreshold int                         // порог чтений горячего ключа, см. WithHotKeyDetection
+	hotWindow    time.Duration               // окно подсчёта чтений, 0 - поиск выключен
+	onHot        func(key string, reads int) // вызывается, когда ключ становится горячим
+
+	sink       Sink       // приёмник отложенной записи, см. WithSink
+	durableLog SyncWriter // журнал SetDurable, см. WithDurableLog
+	sinkOpts   SinkOptions
+
+	defaultTTL time.Duration // TTL для записей с ttl == 0
+	maxMemory  int64         // лимит примерного объёма данных в байтах, 0 - без лимита
+	admission  Admission     // фильтр допуска новых ключей, см. WithAdmission
+
+	bloomKeys   int     // ожидаемое число ключей фильтра Блума, 0 - без фильтра, см. WithBloomFilter
+	bloomFPRate float64 // целевая доля ложных срабатываний фильтра
+
+	itemBlock int // элементов в блоке аллокатора, 0 - по одному, см. WithItemBlocks
+	internMax int // длина интернируемых значений, 0 - без интернирования, см. WithValueInterning
+
+	engine  Engine // движок чтений по ключу, см. WithEngine
+	indexes Index  // вторичные индексы, см. WithIndexes
+
+	lazyExpiration LazyExpiration          // чтение истекшего ключа, см. WithLazyExpiration
+	onExpire       func(key, value string) // см. WithExpireCallback
+	deterministic  bool                    // без фоновых горутин и случайности, см. WithDeterministic
+	eviction       EvictionPolicy          // выбор жертвы вытеснения, см. WithEvictionPolicy
+	coarseClock    time.Duration           // шаг кешированного времени, 0 - без кеша, см. WithCoarseClock
+	viewFlush      time.Duration           // период сброса буфера просмотров, 0 - без буфера, см. WithBufferedViews
+	resetToken     string                  // подтверждение очистки, пусто - без защиты, см. WithResetGuard
+	fullPolicy     FullPolicy              // что делать с записью при заполненном WithMaxMemory, см. WithFullPolicy
+	fullTimeout    time.Duration           // сколько ждёт FullBlock
+	onFull         func(FullEvent)         // см. WithOnFull
+
+	onExpireCtx func(ctx context.Context, key, value string) // см. WithExpireCallbackContext
+}
+
+// NoExpiration - ttl для записи без срока истечения, даже если задан WithDefaultTTL.
+const NoExpiration time.Duration = -1
+
+// WithDefaultTTL задаёт TTL для записей, сохраненных с ttl == 0.
+// Что-бы записать ключ без срока истечения, передайте NoExpiration.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.defaultTTL = ttl
+	}
+}
+
+// WithExpvar публикует статистику хранилища через expvar под именем name,
+// так что она появится в /debug/vars рядом с остальными метриками процесса.
+// Имя должно быть уникальным в процессе: занятое имя New отклоняет с ErrInvalidConfig.
+func WithExpvar(name string) Option {
+	return func(c *config) {
+		c.expvarName = name
+	}
+}
+
+// validate проверяет настройки после применения всех опций
+func (c *config) validate() error {
+	var problems []string
+	check := func(bad bool, msg string) {
+		if bad {
+			problems = append(problems, msg)
+		}
+	}
+	check(c.maxMemory < 0, "max memory must not be negative")
+	check(c.admission < AdmissionOff || c.admission > AdmissionDemote, "unknown admission mode")
+	check(c.admission != AdmissionOff && c.maxMemory <= 0, "admission filter requires max memory")
+	check(c.itemBlock < 0, "item block size must not be negative")
+	check(c.internMax < 0, "interning max length must not be negative")
+	check(c.eviction > EvictClock, "unknown eviction policy")
+	check(c.engine != EngineMap && c.engine != EngineSyncMap, "unknown engine")
+	check(c.indexes&^(IndexExpiry|IndexTags) != 0, "unknown index")
+	check(c.lazyExpiration < LazyDelete || c.lazyExpiration > LazyStale, "unknown lazy expiration mode")
+	check(c.deterministic && c.clock == nil, "deterministic mode requires a clock")
+	check(c.deterministic && c.sink != nil, "deterministic mode is incompatible with a sink")
+	check(c.deterministic && c.durableLog != nil, "deterministic mode is incompatible with a durable log")
+	check(c.deterministic && c.admission != AdmissionOff, "deterministic mode is incompatible with admission")
+	check(c.deterministic && c.latency, "deterministic mode is incompatible with latency histograms")
+	check(c.coarseClock < 0, "coarse clock resolution must not be negative")
+	check(c.deterministic && c.coarseClock > 0, "deterministic mode is incompatible with a coarse clock")
+	check(c.viewFlush < 0, "view buffer flush interval must not be negative")
+	check(c.deterministic && c.viewFlush > 0, "deterministic mode is incompatible with buffered views")
+	check(c.fullPolicy < FullEvict || c.fullPolicy > FullBlock, "unknown full policy")
+	check(c.fullPolicy == FullBlock && c.fullTimeout <= 0, "full policy block needs a positive timeout")
+	check(c.bloomKeys < 0, "bloom filter expected keys must not be negative")
+	check(c.bloomKeys > 0 && (c.bloomFPRate <= 0 || c.bloomFPRate >= 1), "bloom filter false positive rate must be in (0, 1)")
+	check(c.maxKeyLen < 0, "max key length must not be negative")
+	check(c.keyHashMin < 0, "key hashing threshold must not be negative")
+	check(c.keyHashMin > 0 && c.keyHashMin < len(hashedKeyPrefix)+32, "key hashing threshold is shorter than a hashed key")
+	check(c.maxValueSize < 0, "max value size must not be negative")
+	check(c.compressMin < 0, "compression threshold must not be negative")
+	check(c.recentCapacity < 0, "recent capacity must not be negative")
+	check(c.missDedupWindow < 0, "miss dedup window must not be negative")
+	check(c.viewsHalfLife < 0, "views half-life must not be negative")
+	check(c.historyDepth < 0, "history depth must not be negative")
+	check(c.maxIdle < 0, "max idle time must not be negative")
+	check(c.tombstoneGrace < 0, "tombstone grace period must not be negative")
+	check(c.writeConcurrency < 0, "write concurrency must not be negative")
+	check(c.auditCapacity < 0, "audit log capacity must not be negative")
+	check(c.slowThreshold < 0 || c.slowCapacity < 0, "slow log threshold and capacity must not be negative")
+	check(c.hotWindow < 0, "hot key window must not be negative")
+	check(c.hotWindow > 0 && c.hotThreshold <= 0, "hot key threshold must be positive")
+	check(c.cleanupMin < 0 || c.cleanupMax < 0, "cleanup intervals must not be negative")
+	check(c.cleanupMin > 0 && c.cleanupMax > 0 && c.cleanupMin > c.cleanupMax, "min cleanup interval is greater than max")
+	check(c.cleanupBatch < 0 || c.cleanupBudget < 0, "incremental cleanup batch and budget must not be negative")
+	check(c.sinkOpts.QueueSize < 0 || c.sinkOpts.BatchSize < 0 || c.sinkOpts.MaxRetries < 0, "sink sizes must not be negative")
+	check(c.sink != nil && c.sinkOpts.QueueSize > 0 && c.sinkOpts.BatchSize > c.sinkOpts.QueueSize, "sink batch size is greater than queue size")
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+}
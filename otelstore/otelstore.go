@@ -0,0 +1,240 @@
+// Package otelstore - обертка над store.Store, которая пишет метрики OpenTelemetry
+// (и при желании спаны) для операций Get/Set/Delete.
+package otelstore
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+const instrumentationName = "github.com/Shk337/test-task-in-memory-cache-golang-senior/otelstore"
+
+// Option настраивает обертку.
+type Option func(*config)
+
+type config struct {
+	meterProvider  metric.MeterProvider
+	tracerProvider trace.TracerProvider
+	spans          bool
+	name           string
+}
+
+// WithMeterProvider задаёт MeterProvider, по умолчанию берется глобальный otel.GetMeterProvider().
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) { c.meterProvider = mp }
+}
+
+// WithTracerProvider задаёт TracerProvider, по умолчанию берется глобальный otel.GetTracerProvider().
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.tracerProvider = tp }
+}
+
+// WithSpans включает создание спанов на каждую операцию. По умолчанию пишутся только метрики,
+// т.к. спан на каждый Get обычно слишком дорог.
+func WithSpans() Option {
+	return func(c *config) { c.spans = true }
+}
+
+// WithName задаёт значение атрибута cache.name, чтобы различать несколько хранилищ в одном процессе.
+func WithName(name string) Option {
+	return func(c *config) { c.name = name }
+}
+
+// Store - хранилище с инструментированием. Методы, которых нет в обертке,
+// доступны через Unwrap.
+type Store struct {
+	s      *store.Store
+	tracer trace.Tracer
+	spans  bool
+	attrs  []attribute.KeyValue
+
+	ops      metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// Wrap оборачивает хранилище. Ошибка возвращается, если не удалось создать инструменты метрик.
+func Wrap(s *store.Store, opts ...Option) (*Store, error) {
+	cfg := config{
+		meterProvider:  otel.GetMeterProvider(),
+		tracerProvider: otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	w := &Store{
+		s:      s,
+		tracer: cfg.tracerProvider.Tracer(instrumentationName),
+		spans:  cfg.spans,
+	}
+	if cfg.name != "" {
+		w.attrs = append(w.attrs, attribute.String("cache.name", cfg.name))
+	}
+
+	meter := cfg.meterProvider.Meter(instrumentationName)
+
+	var err error
+	w.ops, err = meter.Int64Counter("cache.operations",
+		metric.WithDescription("Количество операций с хранилищем"))
+	if err != nil {
+		return nil, err
+	}
+
+	w.duration, err = meter.Float64Histogram("cache.operation.duration",
+		metric.WithDescription("Длительность операций с хранилищем"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableGauge("cache.keys",
+		metric.WithDescription("Количество ключей в хранилище"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(s.Size()), metric.WithAttributes(w.attrs...))
+			return nil
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	// разбивка времени из store.WithLockTiming: суммы по фазам, без неё метрика пустая
+	_, err = meter.Float64ObservableCounter("cache.operation.phase.duration",
+		metric.WithDescription("Суммарное время ожидания блокировки, работы под ней и колбэков"),
+		metric.WithUnit("s"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			latency := s.Stats().Latency
+			for _, phase := range timingPhases {
+				l, ok := latency[phase]
+				if !ok {
+					continue
+				}
+				attrs := append(append([]attribute.KeyValue(nil), w.attrs...), attribute.String("cache.phase", phase))
+				o.Observe(l.Sum.Seconds(), metric.WithAttributes(attrs...))
+			}
+			return nil
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.registerNamespaces(meter); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// registerNamespaces регистрирует метрики по пространствам имён с атрибутом cache.namespace,
+// метки ограничены store.WithNamespaceMetrics
+func (w *Store) registerNamespaces(meter metric.Meter) error {
+	keys, err := meter.Int64ObservableGauge("cache.namespace.keys",
+		metric.WithDescription("Количество ключей в пространстве имён"))
+	if err != nil {
+		return err
+	}
+	memory, err := meter.Int64ObservableGauge("cache.namespace.memory",
+		metric.WithDescription("Примерный объём данных пространства имён"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	hits, err := meter.Int64ObservableCounter("cache.namespace.hits",
+		metric.WithDescription("Чтения пространства имён, нашедшие ключ"))
+	if err != nil {
+		return err
+	}
+	misses, err := meter.Int64ObservableCounter("cache.namespace.misses",
+		metric.WithDescription("Чтения пространства имён без ключа"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for name, st := range w.s.NamespaceMetrics() {
+			attrs := append(append([]attribute.KeyValue(nil), w.attrs...), attribute.String("cache.namespace", name))
+			set := metric.WithAttributes(attrs...)
+			o.ObserveInt64(keys, int64(st.Keys), set)
+			o.ObserveInt64(memory, st.MemoryBytes, set)
+			o.ObserveInt64(hits, int64(st.Hits), set)
+			o.ObserveInt64(misses, int64(st.Misses), set)
+		}
+		return nil
+	}, keys, memory, hits, misses)
+	return err
+}
+
+// timingPhases - операции store.Stats.Latency, которые пишутся с атрибутом cache.phase
+var timingPhases = []string{"lock_wait_read", "lock_wait_write", "lock_hold_write", "callback"}
+
+// Unwrap возвращает исходное хранилище.
+func (w *Store) Unwrap() *store.Store {
+	return w.s
+}
+
+// Get - store.Store.GetContext с метриками, результат (hit/miss, error - отменённый ctx)
+// пишется в атрибут cache.result.
+func (w *Store) Get(ctx context.Context, key string) (string, bool, error) {
+	ctx, finish := w.start(ctx, "Get")
+	value, ok, err := w.s.GetContext(ctx, key)
+
+	result := "miss"
+	switch {
+	case err != nil:
+		result = "error"
+	case ok:
+		result = "hit"
+	}
+	finish(ctx, attribute.String("cache.result", result))
+
+	return value, ok, err
+}
+
+// Set - store.Store.SetContext с метриками: ctx даёт отмену, автора и атрибуты записи.
+func (w *Store) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	ctx, finish := w.start(ctx, "Set")
+	err := w.s.SetContext(ctx, key, value, ttl)
+	finish(ctx, attribute.Bool("error", err != nil))
+	return err
+}
+
+// Delete - store.Store.DeleteContext с метриками.
+func (w *Store) Delete(ctx context.Context, key string) error {
+	ctx, finish := w.start(ctx, "Delete")
+	err := w.s.DeleteContext(ctx, key)
+	finish(ctx, attribute.Bool("error", err != nil))
+	return err
+}
+
+// start начинает измерение операции op, возвращаемая функция записывает метрики и закрывает спан
+func (w *Store) start(ctx context.Context, op string) (context.Context, func(context.Context, ...attribute.KeyValue)) {
+	begin := time.Now()
+
+	var span trace.Span
+	if w.spans {
+		ctx, span = w.tracer.Start(ctx, "cache."+op, trace.WithSpanKind(trace.SpanKindInternal))
+	}
+
+	return ctx, func(ctx context.Context, extra ...attribute.KeyValue) {
+		attrs := make([]attribute.KeyValue, 0, len(w.attrs)+len(extra)+1)
+		attrs = append(attrs, w.attrs...)
+		attrs = append(attrs, attribute.String("cache.operation", op))
+		attrs = append(attrs, extra...)
+
+		set := metric.WithAttributes(attrs...)
+		w.ops.Add(ctx, 1, set)
+		w.duration.Record(ctx, time.Since(begin).Seconds(), set)
+
+		if span != nil {
+			span.SetAttributes(attrs...)
+			span.SetAttributes(attribute.Int("cache.keys", w.s.Size()))
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package otelstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric/noop"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+func TestWrapperPassesContext(t *testing.T) {
+	s, err := store.New(store.WithAuditLog(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := Wrap(s, WithMeterProvider(noop.NewMeterProvider()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := store.WithActor(context.Background(), "alice")
+	if err := w.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := w.Get(ctx, "k"); err != nil || !ok || v != "v" {
+		t.Fatalf("Get = %q, %v, %v", v, ok, err)
+	}
+	if recs := s.AuditTail(1); len(recs) != 1 || recs[0].Actor != "alice" {
+		t.Errorf("audit = %+v, want the actor from ctx", recs)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := w.Get(canceled, "k"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get with a canceled ctx = %v", err)
+	}
+	if err := w.Set(canceled, "k", "other", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Set with a canceled ctx = %v", err)
+	}
+	if err := w.Delete(canceled, "k"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Delete with a canceled ctx = %v", err)
+	}
+	if v, ok := s.Get("k"); !ok || v != "v" {
+		t.Errorf("Get(k) = %q, %v after canceled writes", v, ok)
+	}
+}
@@ -0,0 +1,82 @@
+package store
+
+// PinOption настраивает Pin.
+type PinOption func(*pinConfig)
+
+type pinConfig struct {
+	forever bool
+}
+
+// PinForever к защите от вытеснения добавляет защиту от истечения: TTL ключа снимается,
+// а TTL последующих записей ключа игнорируется, пока ключ закреплён.
+func PinForever() PinOption {
+	return func(c *pinConfig) {
+		c.forever = true
+	}
+}
+
+// Pin закрепляет ключ: вытеснение по WithMaxMemory и лимитам пространств имён его
+// пропускает, так что массовая запись не вытолкнет, например, ключи конфигурации.
+// Перезапись значения закрепление сохраняет, удаление (Delete, истечение, Reset) - снимает.
+// Повторный Pin заменяет режим закрепления. Возвращает false, если ключа нет или он истёк.
+//
+// Закреплённые ключи считаются в объёме хранилища: если они одни занимают больше лимита,
+// хранилище останется над лимитом. Закрепления не попадают в снапшот.
+func (s *Store) Pin(key string, opts ...PinOption) bool {
+	var pc pinConfig
+	for _, opt := range opts {
+		opt(&pc)
+	}
+	if s.enter() != nil {
+		return false
+	}
+	defer s.leave()
+
+	key = s.skey(key)
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.data[key]
+	if !ok || cur.expiredAt(now) {
+		return false
+	}
+	if s.pins == nil {
+		s.pins = make(map[string]bool)
+	}
+	s.pins[key] = pc.forever
+	if pc.forever && !cur.ExpiresAt.IsZero() {
+		s.putLocked(key, cur.copyItem()) // putLocked снимет срок у копии
+	}
+	return true
+}
+
+// Unpin снимает закрепление, false - ключ не был закреплён. Срок, снятый PinForever,
+// не возвращается, его можно задать заново через Expire.
+func (s *Store) Unpin(key string) bool {
+	key = s.skey(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pins[key]; !ok {
+		return false
+	}
+	delete(s.pins, key)
+	return true
+}
+
+// Pinned сообщает, закреплён ли ключ.
+func (s *Store) Pinned(key string) bool {
+	key = s.skey(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.pins[key]
+	return ok
+}
+
+// pinnedLocked - ключ хранения закреплён и не вытесняется
+func (s *Store) pinnedLocked(key string) bool {
+	_, ok := s.pins[key]
+	return ok
+}
@@ -0,0 +1,228 @@
+package store
+
+import "time"
+
+// Pipeline копит Set, Delete и Expire и применяет их одной блокировкой хранилища в Commit:
+// пишущий пачками не захватывает блокировку на каждый ключ и не пропускает между своими
+// записями чтения и чужие записи. Другие операции видят либо все изменения Commit, либо
+// ни одного. В отличие от Txn, Pipeline ничего не читает и не проверяет конфликтов, а операции
+// применяются по порядку, в том числе несколько над одним ключом.
+//
+//	n, err := s.Pipeline().
+//		Set("user:1", a, time.Hour).
+//		Set("user:2", b, time.Hour).
+//		Delete("user:3").
+//		Commit()
+//
+// Pipeline не безопасен для конкурентного использования. После Commit и Discard он пуст
+// и годится для следующей пачки.
+type Pipeline struct {
+	s   *Store
+	ops []pipelineOp
+	err error // первая ошибка постановки, Commit вернёт её и ничего не применит
+}
+
+// pipelineKind - вид операции Pipeline
+type pipelineKind uint8
+
+const (
+	pipeSet pipelineKind = iota
+	pipeDelete
+	pipeExpire
+)
+
+// pipelineOp - отложенная операция Pipeline
+type pipelineOp struct {
+	kind pipelineKind
+	key  string // пользовательский ключ, для журнала аудита
+	raw  string
+	item *Item // для pipeSet
+	ttl  time.Duration
+}
+
+// Pipeline возвращает пустой Pipeline хранилища.
+func (s *Store) Pipeline() *Pipeline {
+	return &Pipeline{s: s}
+}
+
+// Set добавляет запись значения, TTL как у Store.Set. Ошибку политики ключей или размера
+// значения вернёт Commit.
+func (p *Pipeline) Set(key, value string, ttl time.Duration) *Pipeline {
+	s := p.s
+	if err := s.checkWrite(key, ttl); err != nil {
+		p.fail(err)
+		return p
+	}
+	if err := s.checkValueSize(key, len(value)); err != nil {
+		p.fail(err)
+		return p
+	}
+	item := s.newItem()
+	s.setCompressed(item, value) // сжимаем до блокировки
+	p.ops = append(p.ops, pipelineOp{kind: pipeSet, key: key, raw: s.wkey(key), item: item, ttl: ttl})
+	return p
+}
+
+// Delete добавляет удаление ключа.
+func (p *Pipeline) Delete(key string) *Pipeline {
+	p.ops = append(p.ops, pipelineOp{kind: pipeDelete, key: key, raw: p.s.skey(key)})
+	return p
+}
+
+// Expire добавляет смену TTL ключа, как Store.Expire: отсутствующий к моменту Commit ключ
+// пропускается.
+func (p *Pipeline) Expire(key string, ttl time.Duration) *Pipeline {
+	if err := p.s.checkWrite(key, ttl); err != nil {
+		p.fail(err)
+		return p
+	}
+	p.ops = append(p.ops, pipelineOp{kind: pipeExpire, key: key, raw: p.s.skey(key), ttl: ttl})
+	return p
+}
+
+// Len возвращает число накопленных операций.
+func (p *Pipeline) Len() int {
+	return len(p.ops)
+}
+
+// Discard отбрасывает накопленные операции и ошибку.
+func (p *Pipeline) Discard() {
+	clear(p.ops) // не держим элементы отброшенных записей
+	p.ops, p.err = p.ops[:0], nil
+}
+
+func (p *Pipeline) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// Commit применяет накопленные операции под одной блокировкой и возвращает, сколько из
+// них что-то изменили: Delete и Expire отсутствующего ключа не считаются. Если при
+// постановке была ошибка, ничего не применяется и возвращается первая из них; так же
+// пачка целиком получает ErrFull, если ей не хватает места под WithFullPolicy.
+// Место WithWriteConcurrency Commit занимает одно на всю пачку.
+func (p *Pipeline) Commit() (int, error) {
+	defer p.Discard()
+	if p.err != nil {
+		return 0, p.err
+	}
+	if len(p.ops) == 0 {
+		return 0, nil
+	}
+	var n int
+	err := p.s.withRoom(p.ops[0].key, nil, func() (err error) {
+		n, err = p.commit()
+		return err
+	})
+	return n, err
+}
+
+// commit - одна попытка Commit для withRoom
+func (p *Pipeline) commit() (int, error) {
+	s := p.s
+	if err := s.enter(); err != nil {
+		return 0, err
+	}
+	defer s.leave()
+	s.acquireWrite(nil) // без ctx не возвращает ошибку
+	defer s.releaseWrite()
+
+	queued := make([]bool, len(p.ops))
+	for i := range queued {
+		queued[i] = s.sinkReserve()
+	}
+	applied := make([]bool, len(p.ops))
+
+	// Expire объём не меняет, место проверяем для Set и Delete
+	writes := make([]batchWrite, 0, len(p.ops))
+	slot := make([]int, len(p.ops))
+	for i, op := range p.ops {
+		slot[i] = len(writes)
+		switch op.kind {
+		case pipeSet:
+			writes = append(writes, batchWrite{raw: op.raw, item: op.item})
+		case pipeDelete:
+			writes = append(writes, batchWrite{raw: op.raw})
+		}
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	for _, op := range p.ops {
+		if op.kind == pipeSet {
+			op.item.ExpiresAt, op.item.UpdatedAt = expiresAt(now, s.effectiveTTL(op.ttl)), now
+		}
+	}
+	admitted, full, err := s.placeBatchLocked(writes, now)
+	if err != nil {
+		s.mu.Unlock()
+		for _, q := range queued {
+			s.sinkRelease(q)
+		}
+		s.placed(p.ops[0].key, nil, err)
+		return 0, err
+	}
+	for i, op := range p.ops {
+		switch op.kind {
+		case pipeSet:
+			if !admitted[slot[i]] {
+				continue
+			}
+			s.putLocked(op.raw, op.item)
+			if queued[i] {
+				s.sinkAppendLocked(EventSet, op.raw, op.item, now)
+			}
+			applied[i], queued[i] = true, false
+		case pipeDelete:
+			if s.removeLocked(op.raw, EventDelete) {
+				s.stats.deletes.Add(1)
+				applied[i] = true
+			}
+			if queued[i] {
+				// в приёмнике ключ мог остаться, даже если из кеша он уже ушёл
+				s.sinkAppendLocked(EventDelete, op.raw, nil, now)
+				queued[i] = false
+			}
+		case pipeExpire:
+			cur, ok := s.data[op.raw]
+			if !ok || cur.expiredAt(now) {
+				continue
+			}
+			next := cur.copyItem()
+			next.ExpiresAt = expiresAt(now, op.ttl)
+			s.putLocked(op.raw, next)
+			if queued[i] {
+				s.sinkAppendLocked(EventSet, op.raw, next, now)
+				queued[i] = false
+			}
+			applied[i] = true
+		}
+	}
+	for i, op := range p.ops {
+		if applied[i] && op.kind == pipeSet {
+			s.evictLocked(now, op.raw)
+		}
+	}
+	s.mu.Unlock()
+	s.placed(p.ops[0].key, full, nil)
+
+	n := 0
+	for i, op := range p.ops {
+		s.sinkRelease(queued[i]) // место Expire отсутствующего ключа
+		if !applied[i] {
+			continue
+		}
+		n++
+		switch op.kind {
+		case pipeSet:
+			s.stats.sets.Add(1)
+			s.resolveMiss(op.raw)
+			s.push(op.raw)
+			s.audit(AuditSet, op.key, writeOpts{})
+		case pipeDelete:
+			s.audit(AuditDelete, op.key, writeOpts{})
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// PrefixStats - статистика по ключам с одним префиксом.
+type PrefixStats struct {
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	HitRatio    float64 `json:"hitRatio"` // hits / (hits + misses), 0 если обращений не было
+	Keys        int     `json:"keys"`
+	MemoryBytes int64   `json:"memoryBytes"`
+}
+
+// WithPrefixStats включает статистику попаданий, промахов и размера по логическим
+// областям кеша, заданным префиксами ключей ("user:", "session:", "html:").
+// Ключ относится к самому длинному подходящему префиксу; ключи без подходящего
+// префикса в эту статистику не попадают.
+//
+// Попадания и промахи считаются атомарными счетчиками на каждом Get, а количество
+// ключей и объём - обходом хранилища при вызове Stats.
+func WithPrefixStats(prefixes ...string) Option {
+	return func(c *config) {
+		c.statPrefixes = append(c.statPrefixes, prefixes...)
+	}
+}
+
+type prefixCounter struct {
+	prefix string
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// prefixCounters - счетчики, отсортированные по убыванию длины префикса для поиска самого длинного
+type prefixCounters struct {
+	list []*prefixCounter
+}
+
+func newPrefixCounters(prefixes []string) *prefixCounters {
+	seen := make(map[string]bool, len(prefixes))
+	pc := &prefixCounters{}
+	for _, p := range prefixes {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		pc.list = append(pc.list, &prefixCounter{prefix: p})
+	}
+	sort.Slice(pc.list, func(i, j int) bool {
+		return len(pc.list[i].prefix) > len(pc.list[j].prefix)
+	})
+	return pc
+}
+
+// match возвращает счетчик самого длинного префикса ключа
+func (pc *prefixCounters) match(key string) *prefixCounter {
+	for _, c := range pc.list {
+		if strings.HasPrefix(key, c.prefix) {
+			return c
+		}
+	}
+	return nil
+}
+
+// countPrefix учитывает результат Get по пользовательскому ключу
+func (s *Store) countPrefix(key string, hit bool) {
+	if s.prefixes == nil {
+		return
+	}
+	c := s.prefixes.match(key)
+	if c == nil {
+		return
+	}
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// prefixStats собирает статистику по префиксам, nil если она выключена
+func (s *Store) prefixStats() map[string]PrefixStats {
+	if s.prefixes == nil {
+		return nil
+	}
+
+	res := make(map[string]PrefixStats, len(s.prefixes.list))
+	for _, c := range s.prefixes.list {
+		ps := PrefixStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+		if total := ps.Hits + ps.Misses; total > 0 {
+			ps.HitRatio = float64(ps.Hits) / float64(total)
+		}
+		res[c.prefix] = ps
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	for raw, item := range s.data {
+		if item.expiredAt(now) {
+			continue
+		}
+		key, ok := s.userKey(raw)
+		if !ok {
+			continue
+		}
+		if c := s.prefixes.match(key); c != nil {
+			ps := res[c.prefix]
+			ps.Keys++
+			ps.MemoryBytes += item.size
+			res[c.prefix] = ps
+		}
+	}
+	s.mu.RUnlock()
+
+	return res
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Priority - приоритет ключа при вытеснении по WithMaxMemory.
+type Priority int8
+
+const (
+	// PriorityLow - дешёвые для пересчёта значения, вытесняются первыми.
+	PriorityLow Priority = -1
+	// PriorityNormal - приоритет Set и остальных записей.
+	PriorityNormal Priority = 0
+	// PriorityHigh - дорогие значения, вытесняются последними.
+	PriorityHigh Priority = 1
+)
+
+// priorityLevels - число уровней, индекс счетчика - p+1
+const priorityLevels = 3
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("Priority(%d)", int8(p))
+	}
+}
+
+// ParsePriority разбирает "low", "normal" или "high", пустая строка - PriorityNormal.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return 0, fmt.Errorf("store: unknown priority %q", s)
+	}
+}
+
+func (p Priority) valid() bool {
+	return p >= PriorityLow && p <= PriorityHigh
+}
+
+// SetWithPriority сохраняет значение как Set с приоритетом вытеснения: когда хранилище
+// упирается в WithMaxMemory, сначала вытесняются ключи PriorityLow, какими бы свежими
+// они ни были, затем PriorityNormal и только потом PriorityHigh. Внутри уровня - как
+// обычно, давно записанные. Приоритет - свойство записи: Set того же ключа вернёт PriorityNormal.
+// Лимиты пространств имён учитывают приоритет только внутри своей случайной выборки.
+func (s *Store) SetWithPriority(key, value string, ttl time.Duration, p Priority) error {
+	if !p.valid() {
+		return fmt.Errorf("store: set %q: invalid priority %d", key, p)
+	}
+	return s.set(key, value, ttl, writeOpts{priority: p})
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// promBuckets - границы le для гистограмм в формате Prometheus
+var promBuckets = []time.Duration{
+	time.Microsecond, 5 * time.Microsecond, 10 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 500 * time.Microsecond, time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 5 * time.Second,
+}
+
+// WritePrometheus пишет статистику хранилища в текстовом формате Prometheus,
+// что-бы отдавать её из /metrics без зависимости от клиентской библиотеки.
+// Гистограммы задержек выводятся, только если включены WithLatencyHistograms.
+func (s *Store) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	st := s.Stats()
+
+	gauge := func(name, help string, v uint64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+	}
+	counter := func(name, help string, v uint64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+
+	gauge("store_keys", "Number of keys in the store.", uint64(st.Size))
+	counter("store_hits_total", "Get calls that found a live key.", st.Hits)
+	counter("store_misses_total", "Get calls that found no live key.", st.Misses)
+	counter("store_sets_total", "Successful writes.", st.Sets)
+	counter("store_deletes_total", "Deleted keys.", st.Deletes)
+	counter("store_expired_total", "Keys removed after TTL expiry.", st.Expired)
+	counter("store_stale_reads_total", "Expired values returned by Get in LazyStale mode.", st.StaleReads)
+	counter("store_evictions_total", "Keys evicted by the memory limit.", st.Evictions)
+	counter("store_admission_rejected_total", "New keys rejected by the admission filter.", st.AdmissionRejected)
+	counter("store_admission_demoted_total", "New keys demoted to low priority by the admission filter.", st.AdmissionDemoted)
+	counter("store_full_rejected_total", "Writes that failed because the store was full.", st.FullRejected)
+	gauge("store_memory_bytes", "Approximate size of stored data.", uint64(st.MemoryBytes))
+	gauge("store_pinned_keys", "Keys pinned against eviction.", uint64(st.Pinned))
+	gauge("store_intern_saved_bytes", "Value bytes shared between keys by interning.", uint64(st.InternSaved))
+	counter("store_retrieved_total", "Keys taken by RetrieveLastKey.", st.Retrieved)
+	counter("store_retrieved_expired_total", "Keys taken by RetrieveLastKey that had already expired.", st.RetrievedExpired)
+
+	if b := st.Bloom; b != nil {
+		counter("store_bloom_skipped_total", "Reads answered as misses by the bloom filter.", b.Skipped)
+		counter("store_bloom_false_positives_total", "Reads the bloom filter let through for absent keys.", b.FalsePositives)
+		fmt.Fprintf(bw, "# HELP store_bloom_fill_ratio Share of set bits in the bloom filter.\n# TYPE store_bloom_fill_ratio gauge\nstore_bloom_fill_ratio %s\n",
+			strconv.FormatFloat(b.FillRatio, 'g', -1, 64))
+	}
+
+	if w := st.Writes; w != nil {
+		gauge("store_writes_active", "Writes holding a WithWriteConcurrency slot.", uint64(w.Active))
+		gauge("store_writes_queued", "Writes waiting for a WithWriteConcurrency slot.", uint64(w.Queued))
+		counter("store_writes_waited_total", "Writes that waited for a WithWriteConcurrency slot.", w.Waited)
+	}
+
+	s.writeNamespaceMetrics(bw)
+
+	if s.lat != nil {
+		s.writeHistograms(bw, "store_operation_duration_seconds", "Latency of store operations.", "op", latencyOps, latencyOps)
+		s.writeHistograms(bw, "store_lock_wait_seconds", "Time spent waiting for the store lock.", "mode", lockOps, []string{"read", "write"})
+		if s.cfg.lockTiming {
+			s.writeHistograms(bw, "store_lock_hold_seconds", "Time the store lock is held for writing.", "mode", timingOps[:1], []string{"write"})
+			s.writeHistograms(bw, "store_callback_duration_seconds", "Time spent in user callbacks.", "kind", timingOps[1:], []string{"all"})
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeHistograms пишет гистограммы ops одной метрикой name, values[i] - значение метки label для ops[i]
+func (s *Store) writeHistograms(w io.Writer, name, help, label string, ops, values []string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, op := range values {
+		h := s.latency(ops[i])
+		for _, le := range promBuckets {
+			fmt.Fprintf(w, "%s_bucket{%s=%q,le=%q} %d\n", name, label, op,
+				strconv.FormatFloat(le.Seconds(), 'g', -1, 64), h.cumulative(le))
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", name, label, op, h.count.Load())
+		fmt.Fprintf(w, "%s_sum{%s=%q} %s\n", name, label, op,
+			strconv.FormatFloat(time.Duration(h.sum.Load()).Seconds(), 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s=%q} %d\n", name, label, op, h.count.Load())
+	}
+}
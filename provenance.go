@@ -0,0 +1,55 @@
+package store
+
+import (
+	"maps"
+	"time"
+)
+
+// Provenance описывает источник значения: кто и откуда его записал.
+// Нужен, чтобы по устаревшим или неверным данным в кеше найти писателя.
+type Provenance struct {
+	Source        string `json:"source,omitempty"`        // система-источник данных
+	RequestID     string `json:"requestId,omitempty"`     // запрос, в рамках которого значение записано
+	LoaderVersion string `json:"loaderVersion,omitempty"` // версия кода, который построил значение
+}
+
+// clone копирует Provenance, что-бы не отдавать наружу указатель из хранилища
+func (p *Provenance) clone() *Provenance {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// ItemMeta - метаданные элемента без значения.
+type ItemMeta struct {
+	ExpiresAt  time.Time
+	UpdatedAt  time.Time
+	Views      uint64
+	Provenance *Provenance
+	Meta       map[string]string // копия, см. SetWithMeta
+}
+
+// SetWithProvenance сохраняет значение как Set и запоминает его источник.
+func (s *Store) SetWithProvenance(key, value string, ttl time.Duration, p Provenance) error {
+	return s.set(key, value, ttl, writeOpts{prov: &p})
+}
+
+// GetMeta возвращает метаданные ключа, если он существует и не истёк.
+// В отличие от Get не увеличивает счетчик просмотров.
+func (s *Store) GetMeta(key string) (ItemMeta, bool) {
+	item, ok := s.loadItem(s.skey(key))
+
+	if !ok || (!item.ExpiresAt.IsZero() && s.now().After(item.ExpiresAt)) {
+		return ItemMeta{}, false
+	}
+
+	return ItemMeta{
+		ExpiresAt:  item.ExpiresAt,
+		UpdatedAt:  item.UpdatedAt,
+		Views:      item.Views.Load(),
+		Provenance: item.Provenance.clone(),
+		Meta:       maps.Clone(item.meta),
+	}, true
+}
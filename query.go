@@ -0,0 +1,220 @@
+package store
+
+import (
+	"cmp"
+	"hash/maphash"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Index - вторичные индексы хранилища для Query, см. WithIndexes.
+type Index uint8
+
+const (
+	IndexExpiry Index = 1 << iota // ключи по сроку истечения, для Query.ExpiresWithin
+	IndexTags                     // ключи по тегам, для Query.Tag
+)
+
+// WithIndexes включает вторичные индексы для Query. Без индекса запрос с тем же условием
+// проходит по всем ключам, с индексом - только по подходящим. Индексы обновляются при каждой
+// записи и удалении под той же блокировкой: индекс истечения стоит O(log N) на запись,
+// индекс тегов - по записи в мапе на тег.
+//
+// Индекса по Views нет: чтения увеличивают Views без блокировки на запись, и упорядоченный
+// индекс сделал бы каждый Get записью. Query.MinViews проверяется при проходе до построения
+// ItemDTO, а самые читаемые ключи дешевле получить через TopViewed.
+func WithIndexes(idx Index) Option {
+	return func(c *config) {
+		c.indexes = idx
+	}
+}
+
+// SetWithTags сохраняет значение с тегами для Query.Tag. Теги заменяют прежние теги ключа,
+// запись через Set и другие методы теги снимает. Повторы и пустые теги отбрасываются.
+func (s *Store) SetWithTags(key, value string, ttl time.Duration, tags ...string) error {
+	return s.set(key, value, ttl, writeOpts{tags: normalizeTags(tags)})
+}
+
+// normalizeTags - отсортированные теги без повторов и пустых, nil если тегов нет
+func normalizeTags(tags []string) []string {
+	tags = slices.DeleteFunc(slices.Clone(tags), func(t string) bool { return t == "" })
+	if len(tags) == 0 {
+		return nil
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+func (it *Item) hasTag(tag string) bool {
+	_, ok := slices.BinarySearch(it.tags, tag)
+	return ok
+}
+
+// Query - условия Find. Условия складываются через И, нулевое поле - без условия.
+type Query struct {
+	Prefix        string        // ключи с префиксом
+	Tag           string        // ключи с тегом, см. SetWithTags и IndexTags
+	ExpiresWithin time.Duration // ключи, которые истекут не позже чем через столько, см. IndexExpiry
+	MinViews      uint64        // ключи, прочитанные хотя бы столько раз
+
+	// Match - произвольное условие, вызывается последним и без блокировки хранилища,
+	// так что может обращаться к нему.
+	Match func(key string, item ItemDTO) bool
+
+	Limit int // сколько ключей вернуть, 0 - все подходящие
+}
+
+// Find возвращает до limit ключей (0 - все), для которых pred вернул true. Проходит по всем
+// ключам; запросы по сроку истечения, тегам и просмотрам дешевле через Query.
+func (s *Store) Find(pred func(key string, item ItemDTO) bool, limit int) []ListEntry {
+	return s.Query(Query{Match: pred, Limit: limit})
+}
+
+// Query возвращает неистекшие ключи, подходящие под q. Если задан Tag и включён IndexTags,
+// проверяются только ключи с тегом, иначе если задан ExpiresWithin и включён IndexExpiry -
+// только истекающие в срок, по возрастанию срока. Без подходящего индекса запрос проходит
+// по всем ключам, и порядок результата не определён.
+//
+// Под RLock собираются только кандидаты по простым условиям, ItemDTO строятся и Match
+// вызывается уже после.
+func (s *Store) Query(q Query) []ListEntry {
+	now := s.now()
+	var deadline time.Time
+	if q.ExpiresWithin > 0 {
+		deadline = now.Add(q.ExpiresWithin)
+	}
+	var candidates []listCandidate
+	consider := func(raw string, item *Item) {
+		if item.expiredAt(now) || item.Views.Load() < q.MinViews {
+			return
+		}
+		if q.Tag != "" && !item.hasTag(q.Tag) {
+			return
+		}
+		if !deadline.IsZero() && (item.ExpiresAt.IsZero() || item.ExpiresAt.After(deadline)) {
+			return
+		}
+		key, ok := s.userKey(raw)
+		if !ok || !strings.HasPrefix(key, q.Prefix) {
+			return
+		}
+		candidates = append(candidates, listCandidate{key: key, item: item})
+	}
+
+	s.mu.RLock()
+	switch idx := s.idx; {
+	case idx != nil && q.Tag != "" && idx.tags != nil:
+		for raw := range idx.tags[q.Tag] {
+			consider(raw, s.data[raw])
+		}
+	case idx != nil && !deadline.IsZero() && idx.kinds&IndexExpiry != 0:
+		tascend(idx.expiry, expiryKey{at: now.UnixNano()}, cmpExpiryKey, func(k expiryKey, _ struct{}) bool {
+			if k.at > deadline.UnixNano() {
+				return false
+			}
+			consider(k.key, s.data[k.key])
+			return true
+		})
+	default:
+		for raw, item := range s.data {
+			consider(raw, item)
+		}
+	}
+	s.mu.RUnlock()
+
+	var res []ListEntry
+	for _, c := range candidates {
+		if q.Limit > 0 && len(res) == q.Limit {
+			break
+		}
+		dto := itemDTO(c.item, ListAll)
+		if q.Match != nil && !q.Match(c.key, dto) {
+			continue
+		}
+		res = append(res, ListEntry{Key: c.key, ItemDTO: dto})
+	}
+	return res
+}
+
+// expiryKey - ключ индекса истечения: срок в UnixNano, при равенстве - ключ хранения
+type expiryKey struct {
+	at  int64
+	key string
+}
+
+func cmpExpiryKey(a, b expiryKey) int {
+	if c := cmp.Compare(a.at, b.at); c != 0 {
+		return c
+	}
+	return strings.Compare(a.key, b.key)
+}
+
+// secondaryIndex - индексы WithIndexes по ключам хранения, под s.mu
+type secondaryIndex struct {
+	kinds  Index
+	expiry *tnode[expiryKey, struct{}]    // ключи со сроком истечения
+	tags   map[string]map[string]struct{} // тег -> ключи, nil без IndexTags
+}
+
+func newSecondaryIndex(kinds Index) *secondaryIndex {
+	idx := &secondaryIndex{kinds: kinds}
+	if kinds&IndexTags != 0 {
+		idx.tags = make(map[string]map[string]struct{})
+	}
+	return idx
+}
+
+// indexLocked переносит ключ в индексах с old (nil - ключа не было) на it
+func (s *Store) indexLocked(key string, old, it *Item) {
+	if s.idx == nil {
+		return
+	}
+	if old != nil {
+		s.unindexLocked(key, old)
+	}
+	idx := s.idx
+	if idx.kinds&IndexExpiry != 0 && !it.ExpiresAt.IsZero() {
+		idx.expiry = tput(idx.expiry, expiryKey{it.ExpiresAt.UnixNano(), key}, struct{}{}, maphash.String(zprioSeed, key), cmpExpiryKey)
+	}
+	for _, tag := range it.tags {
+		if idx.tags == nil {
+			break
+		}
+		keys := idx.tags[tag]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			idx.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// unindexLocked убирает ключ со значением old из индексов
+func (s *Store) unindexLocked(key string, old *Item) {
+	idx := s.idx
+	if idx == nil {
+		return
+	}
+	if idx.kinds&IndexExpiry != 0 && !old.ExpiresAt.IsZero() {
+		idx.expiry = tdel(idx.expiry, expiryKey{old.ExpiresAt.UnixNano(), key}, cmpExpiryKey)
+	}
+	for _, tag := range old.tags {
+		if idx.tags == nil {
+			break
+		}
+		if keys := idx.tags[tag]; keys != nil {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(idx.tags, tag)
+			}
+		}
+	}
+}
+
+// resetIndexLocked очищает индексы вместе с мапой данных
+func (s *Store) resetIndexLocked() {
+	if s.idx != nil {
+		s.idx = newSecondaryIndex(s.idx.kinds)
+	}
+}
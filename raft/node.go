@@ -0,0 +1,621 @@
+package raft
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Node - узел кластера Raft поверх хранилища.
+type Node struct {
+	id    string
+	peers map[string]string // id -> базовый URL остальных узлов
+	s     *store.Store
+	opts  options
+
+	kick chan struct{} // будит Run для немедленной репликации
+
+	mu               sync.Mutex
+	role             Role
+	term             uint64
+	votedFor         string
+	leader           string
+	log              []entry // log[0] - заглушка с индексом и сроком последнего снапшота
+	commitIndex      uint64
+	lastApplied      uint64
+	snapshot         []store.Mutation // содержимое хранилища на log[0].Index
+	electionDeadline time.Time
+	stopped          bool
+
+	nextIndex  map[string]uint64
+	matchIndex map[string]uint64
+	sending    map[string]bool // к узлу уже идёт запрос репликации
+
+	waiters map[uint64]waiter
+	applied chan struct{} // закрывается и заменяется при каждом продвижении lastApplied
+}
+
+// waiter - запись, которую ждёт Set или Delete на лидере
+type waiter struct {
+	term uint64
+	done chan error
+}
+
+var _ store.Backend = (*Node)(nil)
+
+// NewNode создаёт узел id кластера, peers - остальные узлы: id -> базовый URL их Handler,
+// например "http://10.0.0.2:7000". s - хранилище узла, его меняет только журнал.
+func NewNode(id string, peers map[string]string, s *store.Store, opts ...Option) *Node {
+	o := options{electionTimeout: defaultElectionTimeout, snapshotThreshold: defaultSnapshotThreshold}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.client == nil {
+		o.client = &http.Client{Timeout: o.electionTimeout}
+	}
+
+	n := &Node{
+		id:         id,
+		peers:      make(map[string]string, len(peers)),
+		s:          s,
+		opts:       o,
+		kick:       make(chan struct{}, 1),
+		log:        []entry{{}},
+		nextIndex:  make(map[string]uint64),
+		matchIndex: make(map[string]uint64),
+		sending:    make(map[string]bool),
+		waiters:    make(map[uint64]waiter),
+		applied:    make(chan struct{}),
+	}
+	for pid, addr := range peers {
+		if pid != id {
+			n.peers[pid] = addr
+		}
+	}
+	n.resetElectionLocked()
+	return n
+}
+
+// Store возвращает хранилище узла. Чтения из него не линеаризуемы, см. Get.
+func (n *Node) Store() *store.Store {
+	return n.s
+}
+
+// ID возвращает идентификатор узла.
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Leader возвращает идентификатор известного узлу лидера, пустую строку - если его нет.
+func (n *Node) Leader() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leader
+}
+
+// IsLeader сообщает, считает ли узел себя лидером.
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.role == Leader
+}
+
+// Status возвращает состояние узла.
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return Status{
+		ID:          n.id,
+		Role:        n.role.String(),
+		Term:        n.term,
+		Leader:      n.leader,
+		CommitIndex: n.commitIndex,
+		Applied:     n.lastApplied,
+		Snapshot:    n.log[0].Index,
+	}
+}
+
+// Get возвращает значение ключа, линеаризуемо: на ведомом узле запрос уходит лидеру.
+func (n *Node) Get(ctx context.Context, key string) (string, bool, error) {
+	n.mu.Lock()
+	role, leader := n.role, n.leader
+	n.mu.Unlock()
+
+	if role != Leader {
+		if leader == "" {
+			return "", false, ErrNoLeader
+		}
+		return n.forwardRead(ctx, leader, key)
+	}
+	if err := n.readIndex(ctx); err != nil {
+		return "", false, err
+	}
+	value, ok := n.s.Get(key)
+	return value, ok, nil
+}
+
+// Set записывает значение через журнал. ttl превращается в срок по часам узла, который
+// принял запись, поэтому часы узлов должны быть синхронизированы.
+func (n *Node) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m := store.Mutation{Type: store.EventSet, Key: key, Value: value, At: time.Now()}
+	if ttl > 0 {
+		m.ExpiresAt = m.At.Add(ttl)
+	}
+	return n.propose(ctx, []store.Mutation{m})
+}
+
+// Delete удаляет ключи через журнал одной записью.
+func (n *Node) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	now := time.Now()
+	ops := make([]store.Mutation, len(keys))
+	for i, key := range keys {
+		ops[i] = store.Mutation{Type: store.EventDelete, Key: key, At: now}
+	}
+	return n.propose(ctx, ops)
+}
+
+// propose добавляет изменения в журнал лидера и ждёт их применения, на ведомом пересылает лидеру
+func (n *Node) propose(ctx context.Context, ops []store.Mutation) error {
+	n.mu.Lock()
+	if n.role != Leader {
+		leader := n.leader
+		n.mu.Unlock()
+		if leader == "" {
+			return ErrNoLeader
+		}
+		return n.forwardPropose(ctx, leader, ops)
+	}
+	index := n.lastIndexLocked() + 1
+	n.log = append(n.log, entry{Term: n.term, Index: index, Ops: ops})
+	w := waiter{term: n.term, done: make(chan error, 1)}
+	n.waiters[index] = w
+	n.advanceCommitLocked() // в кластере из одного узла запись коммитится сразу
+	n.mu.Unlock()
+	n.wake()
+
+	select {
+	case err := <-w.done:
+		return err
+	case <-ctx.Done():
+		n.mu.Lock()
+		delete(n.waiters, index)
+		n.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// readIndex подтверждает лидерство и ждёт применения всего закоммиченного на этот момент
+func (n *Node) readIndex(ctx context.Context) error {
+	n.mu.Lock()
+	term := n.term
+	// пока не закоммичена запись текущего срока, commitIndex лидера может отставать
+	for n.role == Leader && n.termAtLocked(n.commitIndex) != term {
+		ch := n.applied
+		n.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		n.mu.Lock()
+	}
+	if n.role != Leader || n.term != term {
+		n.mu.Unlock()
+		return ErrNotLeader
+	}
+	index := n.commitIndex
+	n.mu.Unlock()
+
+	if !n.confirmLeadership(ctx, term) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return ErrNotLeader
+	}
+	return n.waitApplied(ctx, index)
+}
+
+func (n *Node) waitApplied(ctx context.Context, index uint64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for n.lastApplied < index {
+		if n.stopped {
+			return ErrNotLeader
+		}
+		ch := n.applied
+		n.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			n.mu.Lock()
+			return ctx.Err()
+		}
+		n.mu.Lock()
+	}
+	return nil
+}
+
+func (n *Node) wake() {
+	select {
+	case n.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Node) majority() int {
+	return (len(n.peers)+1)/2 + 1
+}
+
+func (n *Node) lastIndexLocked() uint64 {
+	return n.log[len(n.log)-1].Index
+}
+
+func (n *Node) lastTermLocked() uint64 {
+	return n.log[len(n.log)-1].Term
+}
+
+// termAtLocked возвращает срок записи index, 0 - записи нет или она ушла в снапшот раньше заглушки
+func (n *Node) termAtLocked(index uint64) uint64 {
+	base := n.log[0].Index
+	if index < base || index > n.lastIndexLocked() {
+		return 0
+	}
+	return n.log[index-base].Term
+}
+
+// becomeFollowerLocked переходит в ведомые, term - срок, увиденный у другого узла.
+// Таймер выборов откладывается, только если узел был лидером или кандидатом: ведомый
+// не должен откладывать выборы из-за чужого кандидата, за которого не голосовал
+func (n *Node) becomeFollowerLocked(term uint64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.leader = ""
+	}
+	if n.role != Follower {
+		n.role = Follower
+		n.resetElectionLocked()
+	}
+}
+
+// campaign начинает выборы
+func (n *Node) campaign() {
+	n.mu.Lock()
+	n.role = Candidate
+	n.term++
+	n.votedFor = n.id
+	n.leader = ""
+	n.resetElectionLocked()
+	req := voteRequest{Term: n.term, Candidate: n.id, LastIndex: n.lastIndexLocked(), LastTerm: n.lastTermLocked()}
+	if len(n.peers) == 0 {
+		n.becomeLeaderLocked()
+		n.mu.Unlock()
+		return
+	}
+	n.mu.Unlock()
+
+	votes := 1
+	for pid := range n.peers {
+		go func() {
+			var resp voteResponse
+			if err := n.call(context.Background(), pid, pathVote, req, &resp); err != nil {
+				return
+			}
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if resp.Term > n.term {
+				n.becomeFollowerLocked(resp.Term)
+				return
+			}
+			if n.role != Candidate || n.term != req.Term || !resp.Granted {
+				return
+			}
+			votes++
+			if votes >= n.majority() {
+				n.becomeLeaderLocked()
+				n.wake()
+			}
+		}()
+	}
+}
+
+func (n *Node) becomeLeaderLocked() {
+	n.role = Leader
+	n.leader = n.id
+	next := n.lastIndexLocked() + 1
+	for pid := range n.peers {
+		n.nextIndex[pid] = next
+		n.matchIndex[pid] = 0
+	}
+	n.log = append(n.log, entry{Term: n.term, Index: next})
+	n.advanceCommitLocked()
+}
+
+// replicateAll отправляет каждому ведомому недостающие записи или heartbeat
+func (n *Node) replicateAll() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for pid := range n.peers {
+		if !n.sending[pid] {
+			n.sending[pid] = true
+			go n.replicate(pid)
+		}
+	}
+}
+
+// replicate - один запрос репликации к узлу pid
+func (n *Node) replicate(pid string) {
+	defer func() {
+		n.mu.Lock()
+		n.sending[pid] = false
+		n.mu.Unlock()
+	}()
+
+	n.mu.Lock()
+	if n.role != Leader {
+		n.mu.Unlock()
+		return
+	}
+	term := n.term
+	next := n.nextIndex[pid]
+	base := n.log[0].Index
+	if next <= base {
+		req := snapshotRequest{Term: term, Leader: n.id, Index: base, LastTerm: n.log[0].Term, Data: n.snapshot}
+		n.mu.Unlock()
+
+		var resp appendResponse
+		if err := n.call(context.Background(), pid, pathSnapshot, req, &resp); err != nil {
+			return
+		}
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if resp.Term > n.term {
+			n.becomeFollowerLocked(resp.Term)
+			return
+		}
+		if n.role == Leader && n.term == term && resp.Success {
+			n.matchIndex[pid] = max(n.matchIndex[pid], req.Index)
+			n.nextIndex[pid] = n.matchIndex[pid] + 1
+			n.advanceCommitLocked()
+		}
+		return
+	}
+
+	last := min(n.lastIndexLocked(), next-1+maxEntriesPerAppend)
+	entries := append([]entry(nil), n.log[next-base:last-base+1]...)
+	req := appendRequest{
+		Term:      term,
+		Leader:    n.id,
+		PrevIndex: next - 1,
+		PrevTerm:  n.termAtLocked(next - 1),
+		Entries:   entries,
+		Commit:    n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	var resp appendResponse
+	if err := n.call(context.Background(), pid, pathAppend, req, &resp); err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if resp.Term > n.term {
+		n.becomeFollowerLocked(resp.Term)
+		return
+	}
+	if n.role != Leader || n.term != term {
+		return
+	}
+	if resp.Success {
+		n.matchIndex[pid] = max(n.matchIndex[pid], resp.Match)
+		n.nextIndex[pid] = n.matchIndex[pid] + 1
+		n.advanceCommitLocked()
+		if n.nextIndex[pid] <= n.lastIndexLocked() {
+			n.wake() // отставшему узлу есть что досылать
+		}
+		return
+	}
+	n.nextIndex[pid] = max(1, min(resp.Conflict, next-1))
+	n.wake()
+}
+
+// advanceCommitLocked коммитит записи текущего срока, которые есть у большинства
+func (n *Node) advanceCommitLocked() {
+	if n.role != Leader {
+		return
+	}
+	matches := make([]uint64, 0, len(n.peers)+1)
+	matches = append(matches, n.lastIndexLocked())
+	for pid := range n.peers {
+		matches = append(matches, n.matchIndex[pid])
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i] > matches[j] })
+	index := matches[n.majority()-1]
+	// записи прошлых сроков коммитятся только вместе с записью текущего
+	if index > n.commitIndex && n.termAtLocked(index) == n.term {
+		n.commitIndex = index
+		n.applyLocked()
+	}
+}
+
+// applyLocked применяет закоммиченные записи к хранилищу по порядку
+// Ожидающий записи получает ErrProposalLost, если на её индексе применилась запись другого срока
+func (n *Node) applyLocked() {
+	if n.lastApplied >= n.commitIndex {
+		return
+	}
+	for n.lastApplied < n.commitIndex {
+		e := n.log[n.lastApplied+1-n.log[0].Index]
+		var err error
+		for _, m := range e.Ops {
+			if aerr := n.s.ApplyMutation(m); aerr != nil && err == nil {
+				err = aerr
+			}
+		}
+		n.lastApplied = e.Index
+		if w, ok := n.waiters[e.Index]; ok {
+			delete(n.waiters, e.Index)
+			if w.term != e.Term {
+				err = ErrProposalLost
+			}
+			w.done <- err
+		}
+	}
+	n.broadcastLocked()
+	n.compactLocked()
+}
+
+func (n *Node) broadcastLocked() {
+	close(n.applied)
+	n.applied = make(chan struct{})
+}
+
+// compactLocked заменяет применённую часть журнала снапшотом хранилища. Хранилище меняется
+// только применением журнала под n.mu, поэтому срез точно соответствует lastApplied
+func (n *Node) compactLocked() {
+	base := n.log[0].Index
+	if n.opts.snapshotThreshold <= 0 || n.lastApplied-base < uint64(n.opts.snapshotThreshold) {
+		return
+	}
+	snap := n.s.Snapshot()
+	data := make([]store.Mutation, 0, snap.Len())
+	snap.Range(func(key string, item store.ItemDTO) bool {
+		data = append(data, store.Mutation{Type: store.EventSet, Key: key, Kind: item.Kind, Value: item.Value, ExpiresAt: item.ExpiresAt, At: item.UpdatedAt})
+		return true
+	})
+
+	cut := n.lastApplied - base
+	rest := make([]entry, 0, len(n.log)-int(cut))
+	rest = append(rest, entry{Term: n.log[cut].Term, Index: n.lastApplied})
+	rest = append(rest, n.log[cut+1:]...)
+	n.log = rest
+	n.snapshot = data
+}
+
+// handleVote - запрос голоса от кандидата
+func (n *Node) handleVote(req voteRequest) voteResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term > n.term {
+		n.becomeFollowerLocked(req.Term)
+	}
+	if req.Term < n.term {
+		return voteResponse{Term: n.term}
+	}
+	if n.lastIndexLocked() == 0 && req.LastIndex > 0 {
+		// пустой журнал у перезапущенного узла неотличим от нового: узел мог уже голосовать
+		// в этом сроке и потерять закоммиченные записи, а так проверка upToDate пропустит
+		// любого кандидата. Поэтому он голосует только на первых выборах кластера, пока
+		// журналы пусты у всех, а дальше - после того, как лидер его догонит
+		return voteResponse{Term: n.term}
+	}
+	upToDate := req.LastTerm > n.lastTermLocked() ||
+		req.LastTerm == n.lastTermLocked() && req.LastIndex >= n.lastIndexLocked()
+	if (n.votedFor == "" || n.votedFor == req.Candidate) && upToDate {
+		n.votedFor = req.Candidate
+		n.resetElectionLocked()
+		return voteResponse{Term: n.term, Granted: true}
+	}
+	return voteResponse{Term: n.term}
+}
+
+// handleAppend - записи журнала или heartbeat от лидера
+func (n *Node) handleAppend(req appendRequest) appendResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term < n.term {
+		return appendResponse{Term: n.term}
+	}
+	n.becomeFollowerLocked(req.Term)
+	n.leader = req.Leader
+	n.resetElectionLocked()
+
+	base := n.log[0].Index
+	if req.PrevIndex > n.lastIndexLocked() {
+		return appendResponse{Term: n.term, Conflict: n.lastIndexLocked() + 1}
+	}
+	entries := req.Entries
+	if req.PrevIndex < base {
+		// начало пачки уже в снапшоте, а значит закоммичено и совпадает
+		skip := base - req.PrevIndex
+		if uint64(len(entries)) <= skip {
+			entries = nil
+		} else {
+			entries = entries[skip:]
+		}
+	} else if n.termAtLocked(req.PrevIndex) != req.PrevTerm {
+		// откатываемся к началу конфликтующего срока целиком, а не по одной записи
+		conflictTerm := n.termAtLocked(req.PrevIndex)
+		i := req.PrevIndex
+		for i > base+1 && n.termAtLocked(i-1) == conflictTerm {
+			i--
+		}
+		return appendResponse{Term: n.term, Conflict: i}
+	}
+
+	for i, e := range entries {
+		if e.Index <= n.lastIndexLocked() {
+			if n.termAtLocked(e.Index) == e.Term {
+				continue
+			}
+			n.log = n.log[:e.Index-base]
+		}
+		n.log = append(n.log, entries[i:]...)
+		break
+	}
+
+	match := req.PrevIndex + uint64(len(req.Entries))
+	if req.Commit > n.commitIndex {
+		n.commitIndex = min(req.Commit, match)
+		n.applyLocked()
+	}
+	return appendResponse{Term: n.term, Success: true, Match: match}
+}
+
+// handleSnapshot - снапшот от лидера для узла, который отстал дальше журнала лидера
+func (n *Node) handleSnapshot(req snapshotRequest) appendResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term < n.term {
+		return appendResponse{Term: n.term}
+	}
+	n.becomeFollowerLocked(req.Term)
+	n.leader = req.Leader
+	n.resetElectionLocked()
+	if req.Index <= n.commitIndex {
+		return appendResponse{Term: n.term, Success: true, Match: req.Index}
+	}
+
+	// снапшот целиком заменяет данные: ключи, которых в нём нет, удаляются
+	seen := make(map[string]struct{}, len(req.Data))
+	for _, m := range req.Data {
+		n.s.ApplyMutation(m)
+		seen[m.Key] = struct{}{}
+	}
+	for _, key := range n.s.Keys("*") {
+		if _, ok := seen[key]; !ok {
+			n.s.ApplyMutation(store.Mutation{Type: store.EventDelete, Key: key})
+		}
+	}
+
+	n.log = []entry{{Term: req.LastTerm, Index: req.Index}}
+	n.snapshot = req.Data
+	n.commitIndex, n.lastApplied = req.Index, req.Index
+	for index, w := range n.waiters {
+		delete(n.waiters, index)
+		w.done <- ErrProposalLost
+	}
+	n.broadcastLocked()
+	return appendResponse{Term: n.term, Success: true, Match: req.Index}
+}
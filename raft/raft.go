@@ -0,0 +1,191 @@
+// Package raft - режим строгой согласованности: хранилище на 3 и более узлах, изменения
+// которого проходят через журнал операций, реплицируемый по алгоритму Raft.
+//
+// Каждый узел держит своё хранилище в режиме только для чтения (store.Store.SetReadOnly)
+// и меняет его только применением закоммиченных записей журнала - тех же store.Mutation,
+// что в CDC-потоке и пакете replication (store.Store.ApplyMutation). Писать нужно через
+// Node.Set и Node.Delete: на ведомом узле запись пересылается лидеру, а вызов возвращается,
+// когда запись закоммичена большинством узлов и применена лидером. Node.Get линеаризуем:
+// лидер подтверждает, что ещё лидер, и отвечает не раньше, чем применит всё закоммиченное
+// к моменту запроса. Чтения из Node.Store() быстрее, но могут отставать.
+//
+//	n := raft.NewNode("a", map[string]string{"b": "http://10.0.0.2:7000", "c": "http://10.0.0.3:7000"}, s)
+//	go http.ListenAndServe(":7000", n.Handler())
+//	go n.Run(ctx)
+//	err := n.Set(ctx, "key", "value", time.Minute)
+//
+// Узлы общаются JSON по HTTP, обработчик Handler монтируется в любой сервер, для TLS
+// подходит WithHTTPClient и http.Server с сертификатом. Журнал и голоса живут в памяти,
+// перезапущенный узел возвращается пустым ведомым и догоняет лидера по снапшоту. Пока
+// журнал пуст, узел не голосует за кандидатов с непустым журналом: он мог забыть и свой
+// голос в текущем сроке, и закоммиченные записи. Так закоммиченная запись переживает
+// перезапуск меньшинства узлов, но до того, как лидер догонит перезапущенные узлы, выборы
+// без них могут не состояться. Одновременный перезапуск большинства означает потерю данных,
+// как у любого кеша в памяти.
+//
+// Node реализует store.Backend, так что кластер можно поставить L2 за TieredStore.
+package raft
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+var (
+	// ErrNotLeader - узел перестал быть лидером, пока обрабатывал запись или чтение.
+	ErrNotLeader = errors.New("raft: not the leader")
+	// ErrNoLeader - лидер сейчас неизвестен, например идут выборы. Запрос можно повторить.
+	ErrNoLeader = errors.New("raft: no known leader")
+	// ErrProposalLost - запись не попала в журнал: лидер сменился до её коммита.
+	// Запись могла и не примениться, её можно повторить.
+	ErrProposalLost = errors.New("raft: proposal lost on leader change")
+	// ErrUnauthorized - узел отклонил токен, см. WithToken.
+	ErrUnauthorized = errors.New("raft: unauthorized")
+)
+
+const (
+	defaultElectionTimeout   = time.Second
+	defaultSnapshotThreshold = 10000
+	maxEntriesPerAppend      = 512
+)
+
+// Option настраивает Node.
+type Option func(*options)
+
+type options struct {
+	electionTimeout   time.Duration
+	snapshotThreshold int
+	token             string
+	client            *http.Client
+}
+
+// WithElectionTimeout задаёт таймаут выборов, по умолчанию секунда: ведомый, который
+// не слышал лидера случайное время от d до 2d, начинает выборы. Лидер шлёт heartbeat
+// каждые d/5. Значение должно быть у всех узлов одинаковым и заметно больше задержки сети.
+func WithElectionTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.electionTimeout = d
+	}
+}
+
+// WithSnapshotThreshold задаёт, сколько применённых записей журнала копится до снапшота,
+// по умолчанию 10000. Снапшот - срез хранилища, журнал до него отбрасывается, а отставшим
+// узлам лидер отправляет снапшот вместо записей.
+func WithSnapshotThreshold(n int) Option {
+	return func(o *options) {
+		o.snapshotThreshold = n
+	}
+}
+
+// WithToken задаёт общий токен узлов: запросы без заголовка Authorization: Bearer с ним
+// отклоняются.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithHTTPClient задаёт клиент для запросов к другим узлам, например с TLS.
+// По умолчанию - клиент с таймаутом в таймаут выборов.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// Role - роль узла в кластере.
+type Role int
+
+const (
+	Follower Role = iota
+	Candidate
+	Leader
+)
+
+func (r Role) String() string {
+	switch r {
+	case Follower:
+		return "follower"
+	case Candidate:
+		return "candidate"
+	case Leader:
+		return "leader"
+	}
+	return "unknown"
+}
+
+// Status - состояние узла.
+type Status struct {
+	ID          string `json:"id"`
+	Role        string `json:"role"`
+	Term        uint64 `json:"term"`
+	Leader      string `json:"leader,omitempty"`
+	CommitIndex uint64 `json:"commitIndex"`
+	Applied     uint64 `json:"applied"`
+	Snapshot    uint64 `json:"snapshot"` // индекс последнего снапшота
+}
+
+// entry - запись журнала. Ops пустой у записи, которую новый лидер добавляет при избрании,
+// чтобы закоммитить записи прошлых сроков
+type entry struct {
+	Term  uint64           `json:"term"`
+	Index uint64           `json:"index"`
+	Ops   []store.Mutation `json:"ops,omitempty"`
+}
+
+// Run запускает узел до отмены ctx: таймеры выборов и heartbeat лидера. Хранилище узла
+// переводится в режим только для чтения. Возвращает ctx.Err().
+func (n *Node) Run(ctx context.Context) error {
+	n.s.SetReadOnly(true)
+	t := time.NewTicker(n.heartbeat())
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.mu.Lock()
+			n.stopped = true
+			n.broadcastLocked()
+			n.mu.Unlock()
+			return ctx.Err()
+		case <-t.C:
+			n.tick()
+		case <-n.kick:
+			n.mu.Lock()
+			leader := n.role == Leader
+			n.mu.Unlock()
+			if leader {
+				n.replicateAll()
+			}
+		}
+	}
+}
+
+func (n *Node) tick() {
+	n.mu.Lock()
+	role := n.role
+	expired := time.Now().After(n.electionDeadline)
+	n.mu.Unlock()
+
+	switch {
+	case role == Leader:
+		n.replicateAll()
+	case expired:
+		n.campaign()
+	}
+}
+
+func (n *Node) heartbeat() time.Duration {
+	return n.opts.electionTimeout / 5
+}
+
+// resetElectionLocked откладывает выборы на случайное время от таймаута до двух таймаутов
+func (n *Node) resetElectionLocked() {
+	d := n.opts.electionTimeout
+	n.electionDeadline = time.Now().Add(d + rand.N(d))
+}
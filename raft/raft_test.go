@@ -0,0 +1,305 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+const testElectionTimeout = 50 * time.Millisecond
+
+// memNet - транспорт узлов в памяти: запрос к http://<id> сразу идёт в Handler узла id.
+// Изолированный узел не может ни отправить, ни получить запрос
+type memNet struct {
+	mu       sync.Mutex
+	handlers map[string]http.Handler
+	isolated map[string]bool
+}
+
+// memLink - RoundTripper узла from в сети memNet
+type memLink struct {
+	net  *memNet
+	from string
+}
+
+func (l memLink) RoundTrip(r *http.Request) (*http.Response, error) {
+	to := r.URL.Host
+	l.net.mu.Lock()
+	h := l.net.handlers[to]
+	cut := l.net.isolated[l.from] || l.net.isolated[to]
+	l.net.mu.Unlock()
+	if h == nil || cut {
+		return nil, fmt.Errorf("memnet: %s -> %s unreachable", l.from, to)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec.Result(), nil
+}
+
+func (m *memNet) isolate(id string, cut bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.isolated[id] = cut
+}
+
+// testCluster - узлы в одной сети memNet, работают до конца теста
+type testCluster struct {
+	t     *testing.T
+	net   *memNet
+	peers map[string]string
+	nodes map[string]*Node
+	stop  map[string]context.CancelFunc
+	wg    sync.WaitGroup
+}
+
+// cluster запускает узлы ids
+func cluster(t *testing.T, ids ...string) *testCluster {
+	t.Helper()
+	c := &testCluster{
+		t:     t,
+		net:   &memNet{handlers: make(map[string]http.Handler), isolated: make(map[string]bool)},
+		peers: make(map[string]string, len(ids)),
+		nodes: make(map[string]*Node, len(ids)),
+		stop:  make(map[string]context.CancelFunc, len(ids)),
+	}
+	for _, id := range ids {
+		c.peers[id] = "http://" + id
+	}
+	t.Cleanup(func() {
+		for _, cancel := range c.stop {
+			cancel()
+		}
+		c.wg.Wait()
+	})
+	for _, id := range ids {
+		c.start(id)
+	}
+	return c
+}
+
+// start запускает узел id с пустым хранилищем, как после перезапуска процесса
+func (c *testCluster) start(id string) *Node {
+	c.t.Helper()
+	if cancel := c.stop[id]; cancel != nil {
+		cancel()
+	}
+	s, err := store.New()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	client := &http.Client{Transport: memLink{net: c.net, from: id}, Timeout: testElectionTimeout}
+	n := NewNode(id, c.peers, s, WithElectionTimeout(testElectionTimeout), WithHTTPClient(client))
+	c.nodes[id] = n
+	c.net.mu.Lock()
+	c.net.handlers[id] = n.Handler()
+	c.net.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stop[id] = cancel
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		n.Run(ctx)
+	}()
+	return n
+}
+
+// waitFor проверяет cond, пока она не выполнится или не выйдет время
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// leaderOf ждёт единственного лидера среди узлов, которых признают все остальные узлы
+func leaderOf(t *testing.T, nodes map[string]*Node) *Node {
+	t.Helper()
+	var leader *Node
+	waitFor(t, "a leader", func() bool {
+		leader = nil
+		for _, n := range nodes {
+			if n.IsLeader() {
+				if leader != nil {
+					return false
+				}
+				leader = n
+			}
+		}
+		if leader == nil {
+			return false
+		}
+		for _, n := range nodes {
+			if n.Leader() != leader.ID() {
+				return false
+			}
+		}
+		return true
+	})
+	return leader
+}
+
+func without(nodes map[string]*Node, id string) map[string]*Node {
+	rest := make(map[string]*Node, len(nodes))
+	for pid, n := range nodes {
+		if pid != id {
+			rest[pid] = n
+		}
+	}
+	return rest
+}
+
+func TestLeaderElection(t *testing.T) {
+	nodes := cluster(t, "a", "b", "c").nodes
+	leader := leaderOf(t, nodes)
+
+	term := leader.Status().Term
+	if term == 0 {
+		t.Fatal("leader elected in term 0")
+	}
+	for _, n := range nodes {
+		if st := n.Status(); st.Term != term {
+			t.Errorf("node %s is in term %d, leader in %d", st.ID, st.Term, term)
+		}
+	}
+}
+
+func TestCommitAppliesOnAllNodes(t *testing.T) {
+	nodes := cluster(t, "a", "b", "c").nodes
+	leader := leaderOf(t, nodes)
+	ctx := context.Background()
+
+	var follower *Node
+	for _, n := range nodes {
+		if n != leader {
+			follower = n
+			break
+		}
+	}
+	// запись через ведомый пересылается лидеру
+	if err := follower.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader.Set(ctx, "gone", "x", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := leader.Delete(ctx, "gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range nodes {
+		v, ok, err := n.Get(ctx, "k")
+		if err != nil || !ok || v != "v" {
+			t.Errorf("node %s: Get(k) = %q, %v, %v", n.ID(), v, ok, err)
+		}
+		waitFor(t, "apply on "+n.ID(), func() bool {
+			v, ok := n.Store().Get("k")
+			_, gone := n.Store().Get("gone")
+			return ok && v == "v" && !gone
+		})
+	}
+
+	// хранилище узла меняет только журнал
+	if err := follower.Store().Set("direct", "x", 0); !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("direct write to a node store = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestLeaderFailover(t *testing.T) {
+	c := cluster(t, "a", "b", "c")
+	nodes, net := c.nodes, c.net
+	old := leaderOf(t, nodes)
+	oldTerm := old.Status().Term
+	ctx := context.Background()
+
+	if err := old.Set(ctx, "before", "1", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	net.isolate(old.ID(), true)
+	rest := without(nodes, old.ID())
+	leader := leaderOf(t, rest)
+	if term := leader.Status().Term; term <= oldTerm {
+		t.Fatalf("new leader term %d, want above %d", term, oldTerm)
+	}
+
+	// без большинства старый лидер ничего не коммитит
+	short, cancel := context.WithTimeout(ctx, 4*testElectionTimeout)
+	err := old.Set(short, "lost", "x", 0)
+	cancel()
+	if err == nil {
+		t.Fatal("isolated leader committed a write")
+	}
+	if err := leader.Set(ctx, "after", "2", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	net.isolate(old.ID(), false)
+	waitFor(t, "old leader to catch up", func() bool {
+		v, ok := old.Store().Get("after")
+		return ok && v == "2" && !old.IsLeader()
+	})
+	if v, ok := old.Store().Get("before"); !ok || v != "1" {
+		t.Errorf("old leader lost committed key: %q, %v", v, ok)
+	}
+	if _, ok := old.Store().Get("lost"); ok {
+		t.Error("uncommitted write of the isolated leader was applied")
+	}
+}
+
+func TestRestartedNodeDoesNotElectStaleLeader(t *testing.T) {
+	c := cluster(t, "a", "b", "c")
+	leader := leaderOf(t, c.nodes)
+	ctx := context.Background()
+
+	var lagging, restarted *Node
+	for _, n := range c.nodes {
+		switch {
+		case n == leader:
+		case lagging == nil:
+			lagging = n
+		default:
+			restarted = n
+		}
+	}
+
+	// X закоммичен лидером и restarted, lagging его не видел
+	c.net.isolate(lagging.ID(), true)
+	if err := leader.Set(ctx, "x", "committed", 0); err != nil {
+		t.Fatal(err)
+	}
+	// restarted теряет журнал и голос, лидер уходит, lagging возвращается
+	restarted = c.start(restarted.ID())
+	c.net.isolate(leader.ID(), true)
+	c.net.isolate(lagging.ID(), false)
+
+	deadline := time.Now().Add(20 * testElectionTimeout)
+	for time.Now().Before(deadline) {
+		if lagging.IsLeader() {
+			t.Fatal("node without the committed entry became leader with the vote of a restarted node")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.net.isolate(leader.ID(), false)
+	nodes := map[string]*Node{leader.ID(): leader, lagging.ID(): lagging, restarted.ID(): restarted}
+	now := leaderOf(t, nodes)
+	if v, ok, err := now.Get(ctx, "x"); err != nil || !ok || v != "committed" {
+		t.Fatalf("Get(x) on leader %s = %q, %v, %v", now.ID(), v, ok, err)
+	}
+	waitFor(t, "restarted node to catch up", func() bool {
+		v, ok := restarted.Store().Get("x")
+		return ok && v == "committed"
+	})
+}
@@ -0,0 +1,259 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+const (
+	pathVote     = "/raft/vote"
+	pathAppend   = "/raft/append"
+	pathSnapshot = "/raft/snapshot"
+	pathPropose  = "/raft/propose"
+	pathRead     = "/raft/read"
+	pathStatus   = "/raft/status"
+)
+
+type voteRequest struct {
+	Term      uint64 `json:"term"`
+	Candidate string `json:"candidate"`
+	LastIndex uint64 `json:"lastIndex"`
+	LastTerm  uint64 `json:"lastTerm"`
+}
+
+type voteResponse struct {
+	Term    uint64 `json:"term"`
+	Granted bool   `json:"granted"`
+}
+
+type appendRequest struct {
+	Term      uint64  `json:"term"`
+	Leader    string  `json:"leader"`
+	PrevIndex uint64  `json:"prevIndex"`
+	PrevTerm  uint64  `json:"prevTerm"`
+	Entries   []entry `json:"entries,omitempty"`
+	Commit    uint64  `json:"commit"`
+}
+
+// appendResponse - ответ и на appendRequest, и на snapshotRequest
+type appendResponse struct {
+	Term     uint64 `json:"term"`
+	Success  bool   `json:"success"`
+	Match    uint64 `json:"match,omitempty"`    // последний индекс, совпавший с лидером
+	Conflict uint64 `json:"conflict,omitempty"` // с какого индекса лидеру повторить при отказе
+}
+
+type snapshotRequest struct {
+	Term     uint64           `json:"term"`
+	Leader   string           `json:"leader"`
+	Index    uint64           `json:"index"`
+	LastTerm uint64           `json:"lastTerm"`
+	Data     []store.Mutation `json:"data"`
+}
+
+type proposeRequest struct {
+	Ops []store.Mutation `json:"ops"`
+}
+
+type readRequest struct {
+	Key string `json:"key"`
+}
+
+// result - ответ на пересланную запись или чтение
+type result struct {
+	Value string `json:"value,omitempty"`
+	Found bool   `json:"found,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Handler возвращает HTTP обработчик запросов других узлов. GET /raft/status отдаёт Status.
+func (n *Node) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+pathVote, serve(n, n.handleVote))
+	mux.HandleFunc("POST "+pathAppend, serve(n, n.handleAppend))
+	mux.HandleFunc("POST "+pathSnapshot, serve(n, n.handleSnapshot))
+	mux.HandleFunc("POST "+pathPropose, serveContext(n, func(ctx context.Context, req proposeRequest) result {
+		return errorResult(n.proposeLocal(ctx, req.Ops))
+	}))
+	mux.HandleFunc("POST "+pathRead, serveContext(n, func(ctx context.Context, req readRequest) result {
+		if n.Status().Role != Leader.String() {
+			return errorResult(ErrNotLeader)
+		}
+		if err := n.readIndex(ctx); err != nil {
+			return errorResult(err)
+		}
+		value, ok := n.s.Get(req.Key)
+		return result{Value: value, Found: ok}
+	}))
+	mux.HandleFunc("GET "+pathStatus, func(w http.ResponseWriter, r *http.Request) {
+		if !n.authorized(r) {
+			http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(n.Status())
+	})
+	return mux
+}
+
+func serve[Req, Resp any](n *Node, fn func(Req) Resp) http.HandlerFunc {
+	return serveContext(n, func(_ context.Context, req Req) Resp { return fn(req) })
+}
+
+func serveContext[Req, Resp any](n *Node, fn func(context.Context, Req) Resp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !n.authorized(r) {
+			http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+			return
+		}
+		var req Req
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fn(r.Context(), req))
+	}
+}
+
+func (n *Node) authorized(r *http.Request) bool {
+	if n.opts.token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(n.opts.token)) == 1
+}
+
+// call отправляет запрос узлу pid и разбирает ответ в resp
+func (n *Node) call(ctx context.Context, pid, path string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost, n.peers[pid]+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	if n.opts.token != "" {
+		hr.Header.Set("Authorization", "Bearer "+n.opts.token)
+	}
+
+	res, err := n.opts.client.Do(hr)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("raft: %s%s: %s", pid, path, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+// proposeLocal - запись, пересланная ведомым: повторно не пересылается, чтобы при смене
+// лидера запрос не ходил по кругу
+func (n *Node) proposeLocal(ctx context.Context, ops []store.Mutation) error {
+	if n.Status().Role != Leader.String() {
+		return ErrNotLeader
+	}
+	return n.propose(ctx, ops)
+}
+
+func (n *Node) forwardPropose(ctx context.Context, leader string, ops []store.Mutation) error {
+	var res result
+	if err := n.call(ctx, leader, pathPropose, proposeRequest{Ops: ops}, &res); err != nil {
+		return fmt.Errorf("raft: forward to leader %s: %w", leader, err)
+	}
+	return resultError(res)
+}
+
+func (n *Node) forwardRead(ctx context.Context, leader, key string) (string, bool, error) {
+	var res result
+	if err := n.call(ctx, leader, pathRead, readRequest{Key: key}, &res); err != nil {
+		return "", false, fmt.Errorf("raft: forward to leader %s: %w", leader, err)
+	}
+	if err := resultError(res); err != nil {
+		return "", false, err
+	}
+	return res.Value, res.Found, nil
+}
+
+// confirmLeadership рассылает heartbeat и проверяет, что большинство ещё признаёт срок term
+func (n *Node) confirmLeadership(ctx context.Context, term uint64) bool {
+	n.mu.Lock()
+	if n.role != Leader || n.term != term {
+		n.mu.Unlock()
+		return false
+	}
+	reqs := make(map[string]appendRequest, len(n.peers))
+	for pid := range n.peers {
+		prev := n.nextIndex[pid] - 1
+		reqs[pid] = appendRequest{Term: term, Leader: n.id, PrevIndex: prev, PrevTerm: n.termAtLocked(prev), Commit: n.commitIndex}
+	}
+	n.mu.Unlock()
+
+	acks := make(chan bool, len(reqs))
+	for pid, req := range reqs {
+		go func() {
+			var resp appendResponse
+			err := n.call(ctx, pid, pathAppend, req, &resp)
+			if err == nil && resp.Term > term {
+				n.mu.Lock()
+				if resp.Term > n.term {
+					n.becomeFollowerLocked(resp.Term)
+				}
+				n.mu.Unlock()
+			}
+			acks <- err == nil && resp.Term == term
+		}()
+	}
+
+	need := n.majority() - 1 // голос самого лидера уже есть
+	for range reqs {
+		if need <= 0 {
+			break
+		}
+		if <-acks {
+			need--
+		}
+	}
+	if need > 0 {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.role == Leader && n.term == term
+}
+
+var knownErrors = []error{ErrNotLeader, ErrNoLeader, ErrProposalLost, ErrUnauthorized, store.ErrReadOnly, store.ErrClosed, context.Canceled, context.DeadlineExceeded}
+
+func errorResult(err error) result {
+	if err == nil {
+		return result{}
+	}
+	return result{Error: err.Error()}
+}
+
+// resultError восстанавливает ошибку из ответа лидера, известные ошибки - как есть для errors.Is
+func resultError(res result) error {
+	if res.Error == "" {
+		return nil
+	}
+	for _, err := range knownErrors {
+		if res.Error == err.Error() {
+			return err
+		}
+	}
+	return errors.New(res.Error)
+}
@@ -0,0 +1,160 @@
+// Package ratelimit - ограничение частоты запросов на счетчиках store.Store.
+//
+// Используется скользящее окно: счетчик текущего окна складывается со счетчиком
+// предыдущего, взвешенным по доле окна, которая ещё не прошла. Так лимит ведёт себя
+// как token bucket с пополнением limit за window и не пропускает двойной лимит на стыке
+// окон, как обычный фиксированный счетчик. Счетчики - ключи хранилища с TTL в два окна,
+// так что неактивные пользователи не копятся.
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Result - решение по запросу.
+type Result struct {
+	Allowed    bool
+	Remaining  int           // сколько ещё запросов пропустим в текущем окне
+	RetryAfter time.Duration // через сколько стоит повторить, если запрос отклонён
+}
+
+// Option настраивает Limiter.
+type Option func(*Limiter)
+
+// WithPrefix задаёт префикс ключей счетчиков в хранилище, по умолчанию "ratelimit:".
+func WithPrefix(prefix string) Option {
+	return func(l *Limiter) {
+		l.prefix = prefix
+	}
+}
+
+// Limiter считает запросы по ключам (пользователь, IP, токен).
+type Limiter struct {
+	s      *store.Store
+	prefix string
+}
+
+// New создаёт Limiter поверх s.
+func New(s *store.Store, opts ...Option) *Limiter {
+	l := &Limiter{
+		s:      s,
+		prefix: "ratelimit:",
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Allow учитывает запрос по key и сообщает, укладывается ли он в limit запросов за window.
+// Если хранилище не смогло посчитать запрос (см. Check), запрос не пропускается.
+func (l *Limiter) Allow(key string, limit int, window time.Duration) bool {
+	res, err := l.Check(key, limit, window)
+	return err == nil && res.Allowed
+}
+
+// Check как Allow, но возвращает остаток лимита и время до повтора.
+// Отклонённые запросы в лимит не засчитываются. Окна считаются по часам хранилища
+// (store.WithClock).
+//
+// Если ключ счетчика занят чужим значением - другого типа или не числом (store.ErrWrongType,
+// store.ErrNotInteger), запрос пропускается: пользователь не виноват в чужой записи.
+// Остальные ошибки хранилища (закрыто, только для чтения, заполнено) возвращаются, и что
+// с запросом делать, решает вызывающий.
+func (l *Limiter) Check(key string, limit int, window time.Duration) (Result, error) {
+	if limit <= 0 || window <= 0 {
+		return Result{RetryAfter: window}, nil
+	}
+
+	now := l.s.Now()
+	idx := now.UnixNano() / int64(window)
+	elapsed := float64(now.UnixNano()%int64(window)) / float64(window)
+
+	cur := l.prefix + key + ":" + strconv.FormatInt(idx, 10)
+	prev := l.prefix + key + ":" + strconv.FormatInt(idx-1, 10)
+
+	n, err := l.s.IncrBy(cur, 1)
+	if errors.Is(err, store.ErrWrongType) || errors.Is(err, store.ErrNotInteger) {
+		// ключ счетчика занят чужим значением - не блокируем пользователя из-за этого
+		return Result{Allowed: true, Remaining: limit}, nil
+	}
+	if err != nil {
+		return Result{RetryAfter: window}, err
+	}
+	if n == 1 {
+		l.s.Expire(cur, 2*window)
+	}
+
+	var before int64
+	if v, ok := l.s.Get(prev); ok {
+		before, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	estimate := float64(before)*(1-elapsed) + float64(n)
+	if estimate <= float64(limit) {
+		return Result{Allowed: true, Remaining: int(float64(limit) - estimate)}, nil
+	}
+
+	l.s.IncrBy(cur, -1)
+	return Result{RetryAfter: retryAfter(before, n-1, limit, elapsed, window)}, nil
+}
+
+// retryAfter оценивает, когда вес предыдущего окна упадёт настолько, что запрос пройдёт.
+// Если текущее окно само по себе исчерпано - ждём его конца
+func retryAfter(before, cur int64, limit int, elapsed float64, window time.Duration) time.Duration {
+	rest := time.Duration((1 - elapsed) * float64(window))
+	if cur+1 > int64(limit) || before == 0 {
+		return rest
+	}
+	// before*(1-f) + cur + 1 <= limit  =>  f >= 1 - (limit-cur-1)/before
+	f := 1 - float64(int64(limit)-cur-1)/float64(before)
+	wait := time.Duration(math.Ceil((f - elapsed) * float64(window)))
+	return min(max(wait, 0), rest)
+}
+
+// Middleware ограничивает запросы limit за window по ключу из keyFunc. Отклонённый запрос
+// получает 429 с заголовком Retry-After, а если хранилище не смогло его посчитать - 503.
+// Пустой ключ от keyFunc - запрос не ограничивается.
+func (l *Limiter) Middleware(limit int, window time.Duration, keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := l.Check(key, limit, window)
+			if err != nil {
+				http.Error(w, "rate limiter unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			if !res.Allowed {
+				secs := int(math.Ceil(res.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ByRemoteAddr - ключ по IP клиента из RemoteAddr, для Middleware.
+// За прокси ключ лучше брать из доверенного заголовка.
+func ByRemoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
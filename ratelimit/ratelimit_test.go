@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+func newLimiter(t *testing.T) (*Limiter, *store.Store, *store.ManualClock) {
+	t.Helper()
+	// начало окна: доля прошедшего окна равна нулю
+	clock := store.NewManualClock(time.Unix(0, 0).Add(1000 * time.Minute))
+	s, err := store.New(store.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(s), s, clock
+}
+
+func TestLimitUsesStoreClock(t *testing.T) {
+	l, _, clock := newLimiter(t)
+	for i := range 3 {
+		if !l.Allow("u", 3, time.Minute) {
+			t.Fatalf("request %d rejected under the limit", i)
+		}
+	}
+	res, err := l.Check("u", 3, time.Minute)
+	if err != nil || res.Allowed {
+		t.Fatalf("Check over the limit = %+v, %v", res, err)
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v", res.RetryAfter)
+	}
+
+	// через два окна прошлые запросы уже не считаются
+	clock.Advance(2 * time.Minute)
+	if !l.Allow("u", 3, time.Minute) {
+		t.Error("request rejected after the window passed on the store clock")
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	l, s, clock := newLimiter(t)
+	// ключ текущего окна занят чужим значением - запрос пропускается
+	s.Set("ratelimit:foreign:"+windowIndex(clock, time.Minute), "not a number", 0)
+	if res, err := l.Check("foreign", 1, time.Minute); err != nil || !res.Allowed {
+		t.Errorf("Check on a foreign key = %+v, %v, want allowed", res, err)
+	}
+
+	s.SetReadOnly(true)
+	if _, err := l.Check("u", 1, time.Minute); !errors.Is(err, store.ErrReadOnly) {
+		t.Errorf("Check on a read-only store = %v, want ErrReadOnly", err)
+	}
+	if l.Allow("u", 1, time.Minute) {
+		t.Error("Allow passed a request the store could not count")
+	}
+	rec := httptest.NewRecorder()
+	mw := l.Middleware(1, time.Minute, func(*http.Request) string { return "u" })
+	mw(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Middleware status = %d, want 503", rec.Code)
+	}
+
+	s.SetReadOnly(false)
+	s.Close(context.Background())
+	if _, err := l.Check("u", 1, time.Minute); !errors.Is(err, store.ErrClosed) {
+		t.Errorf("Check on a closed store = %v, want ErrClosed", err)
+	}
+}
+
+func windowIndex(clock *store.ManualClock, window time.Duration) string {
+	return strconv.FormatInt(clock.Now().UnixNano()/int64(window), 10)
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ReadSnapshot - согласованный срез хранилища на момент Store.Snapshot, только для чтения.
+// Хранилище продолжает меняться, а срез - нет, так что его можно обходить и опрашивать
+// сколько угодно долго, например для экспорта или отладочного дампа. Безопасен для
+// конкурентного использования.
+type ReadSnapshot struct {
+	at      time.Time
+	version uint64                   // последняя Item.Version на момент среза
+	entries map[string]snapshotEntry // по пользовательскому ключу
+}
+
+// snapshotEntry - элемент среза. Сам элемент не меняется после записи в мапу, кроме атомиков,
+// поэтому их значения зафиксированы отдельно
+type snapshotEntry struct {
+	item       *Item
+	views      uint64
+	accessedAt int64
+	counter    int64
+}
+
+// Snapshot возвращает срез хранилища на текущий момент. Элементы не копируются: записи
+// в хранилище подменяют элементы, а не меняют их, поэтому срез стоит одной копии мапы
+// указателей под блокировкой чтения. Ключи, истекшие к моменту среза, в него не попадают.
+func (s *Store) Snapshot() *ReadSnapshot {
+	now := s.now()
+	s.mu.RLock()
+	entries := make(map[string]snapshotEntry, len(s.data))
+	for raw, item := range s.data {
+		if item.expiredAt(now) {
+			continue
+		}
+		key, ok := s.userKey(raw)
+		if !ok {
+			continue // ключ другой версии схемы, см. WithKeyVersion
+		}
+		e := snapshotEntry{item: item, views: item.Views.Load(), accessedAt: item.accessedAt.Load()}
+		if item.counter != nil {
+			e.counter = item.counter.Load()
+		}
+		entries[key] = e
+	}
+	version := s.version
+	s.mu.RUnlock()
+
+	return &ReadSnapshot{at: now, version: version, entries: entries}
+}
+
+// At возвращает момент среза по часам хранилища.
+func (rs *ReadSnapshot) At() time.Time {
+	return rs.at
+}
+
+// Version возвращает последнюю Item.Version хранилища на момент среза: записи с версией
+// не больше неё в срезе учтены, см. SessionToken.
+func (rs *ReadSnapshot) Version() uint64 {
+	return rs.version
+}
+
+// Len возвращает число ключей в срезе.
+func (rs *ReadSnapshot) Len() int {
+	return len(rs.entries)
+}
+
+// Get возвращает значение ключа на момент среза, для коллекций - JSON, как Store.Get.
+func (rs *ReadSnapshot) Get(key string) (string, bool) {
+	e, ok := rs.entries[key]
+	if !ok {
+		return "", false
+	}
+	return e.text(), true
+}
+
+// Item возвращает значение и метаданные ключа на момент среза.
+func (rs *ReadSnapshot) Item(key string) (ItemDTO, bool) {
+	e, ok := rs.entries[key]
+	if !ok {
+		return ItemDTO{}, false
+	}
+	return e.dto(), true
+}
+
+// Keys возвращает отсортированные ключи среза, подходящие под glob-шаблон, см. Store.Keys.
+func (rs *ReadSnapshot) Keys(pattern string) []string {
+	keys := make([]string, 0, len(rs.entries))
+	for key := range rs.entries {
+		if matchGlob(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Range обходит ключи среза по возрастанию, пока fn возвращает true.
+func (rs *ReadSnapshot) Range(fn func(key string, item ItemDTO) bool) {
+	for _, key := range rs.Keys("*") {
+		if !fn(key, rs.entries[key].dto()) {
+			return
+		}
+	}
+}
+
+func (e snapshotEntry) text() string {
+	if e.item.kind == kindCounter {
+		return strconv.FormatInt(e.counter, 10)
+	}
+	return e.item.text()
+}
+
+func (e snapshotEntry) dto() ItemDTO {
+	dto := ItemDTO{
+		Value:      e.text(),
+		ExpiresAt:  e.item.ExpiresAt,
+		CreatedAt:  e.item.CreatedAt,
+		UpdatedAt:  e.item.UpdatedAt,
+		Views:      e.views,
+		Version:    e.item.Version,
+		Provenance: e.item.Provenance.clone(),
+		Size:       e.item.size,
+	}
+	if e.item.kind != kindString {
+		dto.Kind = e.item.kind.String()
+	}
+	if e.accessedAt != 0 {
+		dto.LastAccessedAt = time.Unix(0, e.accessedAt)
+	}
+	return dto
+}
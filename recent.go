@@ -0,0 +1,201 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultRecentCapacity - сколько последних записей помнит хранилище без WithRecentCapacity
+const defaultRecentCapacity = 30
+
+// WithRecentCapacity задаёт размер кольцевого буфера последних записанных ключей,
+// из которого берут ключи RetrieveLastKey и RecentActivity. По умолчанию 30.
+func WithRecentCapacity(n int) Option {
+	return func(c *config) {
+		c.recentCapacity = n
+	}
+}
+
+// WithLastKeysCapacity - то же, что WithRecentCapacity: сколько последних ключей помнит
+// хранилище для RetrieveLastKey, PeekLastKeys и LastKeysSnapshot.
+func WithLastKeysCapacity(n int) Option {
+	return WithRecentCapacity(n)
+}
+
+// WithLastKeysMRU делает журнал последних ключей списком MRU: повторная запись ключа
+// поднимает его наверх, а не добавляет копию, так журнал помнит N последних разных ключей.
+// Поднятие ищет ключ в журнале, поэтому push становится O(WithRecentCapacity).
+func WithLastKeysMRU() Option {
+	return func(c *config) {
+		c.recentMRU = true
+	}
+}
+
+// WithTouchOnGet добавляет в журнал последних ключей и найденные через Get ключи,
+// вместе с WithLastKeysMRU чтение поднимает ключ наверх.
+func WithTouchOnGet() Option {
+	return func(c *config) {
+		c.touchOnGet = true
+	}
+}
+
+// Activity - запись в ключ из журнала последней активности.
+type Activity struct {
+	Key string    `json:"key"`
+	At  time.Time `json:"at"`
+}
+
+// recentEntry - элемент кольца, key хранится как в data, с префиксом версии
+type recentEntry struct {
+	key string
+	at  time.Time
+}
+
+// recentRing - ограниченное кольцо последних записей, при переполнении вытесняется самая старая
+type recentRing struct {
+	mu   sync.Mutex
+	buf  []recentEntry
+	head int // индекс самой старой записи
+	n    int
+	mru  bool // ключ встречается в кольце не больше одного раза
+}
+
+func newRecentRing(capacity int, mru bool) *recentRing {
+	if capacity <= 0 {
+		capacity = defaultRecentCapacity
+	}
+	return &recentRing{buf: make([]recentEntry, capacity), mru: mru}
+}
+
+func (r *recentRing) push(e recentEntry) {
+	r.mu.Lock()
+	if r.mru {
+		r.removeLocked(e.key)
+	}
+	r.buf[(r.head+r.n)%len(r.buf)] = e
+	if r.n < len(r.buf) {
+		r.n++
+	} else {
+		r.head = (r.head + 1) % len(r.buf)
+	}
+	r.mu.Unlock()
+}
+
+// removeLocked убирает запись key, сдвигая более свежие записи на её место
+func (r *recentRing) removeLocked(key string) {
+	for i := r.n - 1; i >= 0; i-- {
+		if r.buf[(r.head+i)%len(r.buf)].key != key {
+			continue
+		}
+		for j := i; j < r.n-1; j++ {
+			r.buf[(r.head+j)%len(r.buf)] = r.buf[(r.head+j+1)%len(r.buf)]
+		}
+		r.n--
+		r.buf[(r.head+r.n)%len(r.buf)] = recentEntry{}
+		return
+	}
+}
+
+// pop достаёт самую свежую запись
+func (r *recentRing) pop() (recentEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.n == 0 {
+		return recentEntry{}, false
+	}
+	r.n--
+	i := (r.head + r.n) % len(r.buf)
+	e := r.buf[i]
+	r.buf[i] = recentEntry{}
+	return e, true
+}
+
+// top возвращает самую свежую запись без удаления
+func (r *recentRing) top() (recentEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.n == 0 {
+		return recentEntry{}, false
+	}
+	return r.buf[(r.head+r.n-1)%len(r.buf)], true
+}
+
+// since возвращает записи не старше from, от новых к старым
+func (r *recentRing) since(from time.Time) []recentEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res []recentEntry
+	for i := r.n - 1; i >= 0; i-- {
+		e := r.buf[(r.head+i)%len(r.buf)]
+		if e.at.Before(from) {
+			break // записи добавляются по времени, дальше только старее
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// latest возвращает до n самых свежих записей, от новых к старым, n < 0 - все
+func (r *recentRing) latest(n int) []recentEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n < 0 || n > r.n {
+		n = r.n
+	}
+	res := make([]recentEntry, n)
+	for i := range n {
+		res[i] = r.buf[(r.head+r.n-1-i)%len(r.buf)]
+	}
+	return res
+}
+
+func (r *recentRing) reset() {
+	r.mu.Lock()
+	clear(r.buf)
+	r.head, r.n = 0, 0
+	r.mu.Unlock()
+}
+
+// RecentActivity возвращает записи в ключи за последние since, от новых к старым.
+// Журнал ограничен WithRecentCapacity, поэтому при частых записях окно может быть короче since.
+// Ключ, записанный несколько раз, встречается несколько раз, если не задан WithLastKeysMRU.
+func (s *Store) RecentActivity(since time.Duration) []Activity {
+	entries := s.recent.since(s.now().Add(-since))
+
+	res := make([]Activity, 0, len(entries))
+	for _, e := range entries {
+		if key, ok := s.userKey(e.key); ok {
+			res = append(res, Activity{Key: key, At: e.at})
+		}
+	}
+	return res
+}
+
+// PeekLastKeys возвращает до n последних записанных ключей, от новых к старым, не извлекая
+// их из журнала, в отличие от RetrieveLastKey. Ключ, записанный несколько раз, встречается
+// несколько раз, если не задан WithLastKeysMRU.
+func (s *Store) PeekLastKeys(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	return s.lastKeys(s.recent.latest(n))
+}
+
+// LastKeysSnapshot возвращает весь журнал последних записанных ключей, от новых к старым.
+func (s *Store) LastKeysSnapshot() []string {
+	return s.lastKeys(s.recent.latest(-1))
+}
+
+func (s *Store) lastKeys(entries []recentEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if key, ok := s.userKey(e.key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
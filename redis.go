@@ -0,0 +1,299 @@
+package store
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportRedis записывает неистекшие ключи в w командами Redis в протоколе RESP - тот формат,
+// который принимает redis-cli --pipe, так что данные переносятся в Redis одной командой:
+//
+//	cat dump.resp | redis-cli --pipe
+//
+// Строки и счетчики пишутся SET с PXAT, коллекции - HSET, RPUSH, SADD и ZADD и затем
+// PEXPIREAT, если у ключа есть срок. HyperLogLog пропускается: его регистры несовместимы
+// с форматом Redis. Ключи пишутся пользовательскими, без префикса версии схемы (WithKeyVersion).
+func (s *Store) ExportRedis(w io.Writer) error {
+	type export struct {
+		key  string
+		item *Item
+		n    int64 // значение счетчика на момент экспорта
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	items := make([]export, 0, len(s.data))
+	for raw, item := range s.data {
+		if item.expiredAt(now) || item.kind == kindHLL {
+			continue
+		}
+		key, ok := s.userKey(raw)
+		if !ok {
+			continue
+		}
+		e := export{key: key, item: item}
+		if item.kind == kindCounter {
+			e.n = item.counter.Load()
+		}
+		items = append(items, e)
+	}
+	s.mu.RUnlock()
+	sort.Slice(items, func(i, j int) bool { return items[i].key < items[j].key })
+
+	bw := bufio.NewWriter(w)
+	for _, e := range items {
+		it := e.item
+		var cmd []string
+		switch it.kind {
+		case kindString, kindCounter:
+			value := it.plain()
+			if it.kind == kindCounter {
+				value = strconv.FormatInt(e.n, 10)
+			}
+			cmd = []string{"SET", e.key, value}
+			if !it.ExpiresAt.IsZero() {
+				cmd = append(cmd, "PXAT", strconv.FormatInt(it.ExpiresAt.UnixMilli(), 10))
+			}
+		case kindHash:
+			cmd = []string{"HSET", e.key}
+			fields := make([]string, 0, len(it.hash))
+			for f := range it.hash {
+				fields = append(fields, f)
+			}
+			sort.Strings(fields)
+			for _, f := range fields {
+				cmd = append(cmd, f, it.hash[f])
+			}
+		case kindList:
+			cmd = append([]string{"RPUSH", e.key}, it.list...)
+		case kindSet:
+			cmd = append([]string{"SADD", e.key}, it.members()...)
+		case kindZSet:
+			cmd = []string{"ZADD", e.key}
+			for _, m := range it.zset.rangeByScore(math.Inf(-1), math.Inf(1)) {
+				cmd = append(cmd, strconv.FormatFloat(m.Score, 'g', -1, 64), m.Member)
+			}
+		}
+		writeRESP(bw, cmd)
+		if it.kind != kindString && it.kind != kindCounter && !it.ExpiresAt.IsZero() {
+			writeRESP(bw, []string{"PEXPIREAT", e.key, strconv.FormatInt(it.ExpiresAt.UnixMilli(), 10)})
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("store: export redis: %w", err)
+	}
+	return nil
+}
+
+func writeRESP(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+}
+
+// ImportRedis выполняет команды Redis из r поверх текущих данных: поток RESP, например
+// результат ExportRedis или дамп, который сторонние утилиты снимают с живого Redis через
+// SCAN, или команды по одной на строку (inline, аргументы через пробел, без кавычек).
+//
+// Поддерживаются SET (EX, PX, EXAT, PXAT), HSET, HMSET, RPUSH, LPUSH, SADD, ZADD, EXPIRE,
+// PEXPIRE, EXPIREAT, PEXPIREAT, DEL и UNLINK; SELECT и PING пропускаются. На неизвестной
+// команде или ошибке записи импорт останавливается с номером команды в ошибке, уже
+// выполненные команды остаются в силе.
+func (s *Store) ImportRedis(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		args, err := readRESPCommand(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("store: import redis: command %d: %w", n, err)
+		}
+		if len(args) == 0 {
+			n--
+			continue
+		}
+		if err := s.execRedis(args); err != nil {
+			return fmt.Errorf("store: import redis: command %d %s: %w", n, strings.ToUpper(args[0]), err)
+		}
+	}
+}
+
+// readRESPCommand читает одну команду: массив bulk-строк или inline строку. Пустая строка - nil
+func readRESPCommand(br *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(br)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("bad array header %q", line)
+	}
+	args := make([]string, count)
+	for i := range args {
+		h, err := readRESPLine(br)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if !strings.HasPrefix(h, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", h)
+		}
+		size, err := strconv.Atoi(h[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("bad bulk string header %q", h)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if string(buf[size:]) != "\r\n" {
+			return nil, errors.New("bulk string is not terminated by CRLF")
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readRESPLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) && line != "" {
+			return strings.TrimRight(line, "\r"), nil
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// errRedisSyntax - неверные аргументы команды импорта
+var errRedisSyntax = errors.New("syntax error")
+
+func (s *Store) execRedis(args []string) error {
+	cmd, args := strings.ToUpper(args[0]), args[1:]
+	switch cmd {
+	case "SELECT", "PING":
+		return nil
+	case "SET":
+		return s.execRedisSet(args)
+	case "HSET", "HMSET":
+		if len(args) < 3 || len(args)%2 != 1 {
+			return errRedisSyntax
+		}
+		fields := make(map[string]string, len(args)/2)
+		for i := 1; i < len(args); i += 2 {
+			fields[args[i]] = args[i+1]
+		}
+		_, err := s.HSet(args[0], fields)
+		return err
+	case "RPUSH", "LPUSH", "SADD":
+		if len(args) < 2 {
+			return errRedisSyntax
+		}
+		var err error
+		switch cmd {
+		case "RPUSH":
+			_, err = s.RPush(args[0], args[1:]...)
+		case "LPUSH":
+			_, err = s.LPush(args[0], args[1:]...)
+		default:
+			_, err = s.SAdd(args[0], args[1:]...)
+		}
+		return err
+	case "ZADD":
+		if len(args) < 3 || len(args)%2 != 1 {
+			return errRedisSyntax
+		}
+		members := make(map[string]float64, len(args)/2)
+		for i := 1; i < len(args); i += 2 {
+			score, err := strconv.ParseFloat(args[i], 64)
+			if err != nil {
+				return fmt.Errorf("bad score %q", args[i])
+			}
+			members[args[i+1]] = score
+		}
+		_, err := s.ZAdd(args[0], members)
+		return err
+	case "EXPIRE", "PEXPIRE", "EXPIREAT", "PEXPIREAT":
+		if len(args) != 2 {
+			return errRedisSyntax
+		}
+		at, err := redisExpiry(cmd, args[1], s.now())
+		if err != nil {
+			return err
+		}
+		if ttl := at.Sub(s.now()); ttl > 0 {
+			s.Expire(args[0], ttl)
+		} else {
+			s.Delete(args[0])
+		}
+		return nil
+	case "DEL", "UNLINK":
+		for _, key := range args {
+			s.Delete(key)
+		}
+		return nil
+	default:
+		return errors.New("unsupported command")
+	}
+}
+
+// execRedisSet - SET key value [EX s | PX ms | EXAT ts | PXAT ms-ts]
+func (s *Store) execRedisSet(args []string) error {
+	if len(args) != 2 && len(args) != 4 {
+		return errRedisSyntax
+	}
+	ttl := NoExpiration
+	if len(args) == 4 {
+		opt := strings.ToUpper(args[2])
+		cmd := map[string]string{"EX": "EXPIRE", "PX": "PEXPIRE", "EXAT": "EXPIREAT", "PXAT": "PEXPIREAT"}[opt]
+		if cmd == "" {
+			return errRedisSyntax
+		}
+		now := s.now()
+		at, err := redisExpiry(cmd, args[3], now)
+		if err != nil {
+			return err
+		}
+		if ttl = at.Sub(now); ttl <= 0 {
+			s.Delete(args[0]) // уже истекло, как в Redis - ключа нет
+			return nil
+		}
+	}
+	return s.Set(args[0], args[1], ttl)
+}
+
+// redisExpiry переводит аргумент команды истечения в момент времени
+func redisExpiry(cmd, arg string, now time.Time) (time.Time, error) {
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad expire time %q", arg)
+	}
+	switch cmd {
+	case "EXPIRE":
+		return now.Add(time.Duration(n) * time.Second), nil
+	case "PEXPIRE":
+		return now.Add(time.Duration(n) * time.Millisecond), nil
+	case "EXPIREAT":
+		return time.Unix(n, 0), nil
+	default:
+		return time.UnixMilli(n), nil
+	}
+}
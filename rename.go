@@ -0,0 +1,85 @@
+package store
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Rename переносит значение oldKey в newKey под одной блокировкой: другие вызовы не видят
+// момента, когда ключа нет ни под одним именем. Переносятся срок истечения, Views, CreatedAt,
+// приоритет и теги; история значений и закрепление (Pin) остаются за старым именем и удаляются.
+// Существующий newKey перезаписывается. ErrNotFound - oldKey нет или он истёк.
+// Подписчики и WithSink получают удаление oldKey и запись newKey.
+func (s *Store) Rename(oldKey, newKey string) error {
+	return s.moveKey(oldKey, newKey, 0, true)
+}
+
+// CopyKey записывает в dst копию значения src, src не меняется. ttl == 0 сохраняет срок
+// истечения src, NoExpiration снимает его, иначе копия истечёт через ttl. Копия - новый ключ:
+// Views и время чтения у неё с нуля, CreatedAt - момент копирования. Существующий dst
+// перезаписывается. ErrNotFound - src нет или он истёк.
+func (s *Store) CopyKey(src, dst string, ttl time.Duration) error {
+	return s.moveKey(src, dst, ttl, false)
+}
+
+// moveKey - общая часть Rename и CopyKey, rename - удалить исходный ключ
+func (s *Store) moveKey(from, to string, ttl time.Duration, rename bool) error {
+	if err := s.checkWrite(to, ttl); err != nil {
+		return err
+	}
+	if err := s.beginWrite(writeOpts{}); err != nil {
+		return err
+	}
+	defer s.endWrite()
+
+	src, dst := s.skey(from), s.wkey(to)
+	now := s.now()
+	setQueued := s.sinkReserve()
+	delQueued := rename && s.sinkReserve()
+
+	s.mu.Lock()
+	cur, ok := s.data[src]
+	if !ok || cur.expiredAt(now) || src == dst {
+		s.mu.Unlock()
+		s.sinkRelease(setQueued)
+		s.sinkRelease(delQueued)
+		if !ok || cur.expiredAt(now) {
+			return ErrNotFound
+		}
+		return nil // переименование и копирование в себя ничего не меняют
+	}
+	next := cur.copyItem()
+	if rename {
+		s.removeLocked(src, EventDelete)
+		if delQueued {
+			s.sinkAppendLocked(EventDelete, src, nil, now)
+		}
+	} else {
+		next.Views.Store(0)
+		next.accessedAt.Store(0)
+		next.CreatedAt, next.UpdatedAt = time.Time{}, now
+		if ttl != 0 {
+			next.ExpiresAt = expiresAt(now, ttl)
+		}
+		if cur.counter != nil {
+			// счётчик меняется на месте, копии нужен свой
+			next.counter = new(atomic.Int64)
+			next.counter.Store(cur.counter.Load())
+		}
+	}
+	s.putLocked(dst, next)
+	if setQueued {
+		s.sinkAppendLocked(EventSet, dst, next, now)
+	}
+	s.evictLocked(now, dst)
+	s.mu.Unlock()
+
+	s.stats.sets.Add(1)
+	s.resolveMiss(dst)
+	s.push(dst)
+	if rename {
+		s.audit(AuditDelete, from, writeOpts{})
+	}
+	s.audit(AuditSet, to, writeOpts{})
+	return nil
+}
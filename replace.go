@@ -0,0 +1,100 @@
+package store
+
+import "fmt"
+
+// ReplaceAll атомарно заменяет всё содержимое хранилища на items под одной блокировкой:
+// читатели видят либо старые данные, либо новые целиком, в отличие от Reset и записи
+// ключей по одному, когда периодическая перезагрузка из базы показывает полупустой кеш.
+//
+// Значения записываются строками, ключи с ExpiresAt в прошлом пропускаются, нулевое
+// UpdatedAt заменяется текущим временем, Views и LastAccessedAt переносятся, версии
+// выдаются заново. Если хотя бы один ключ или значение не проходит политику ключей
+// (WithKeyPolicy, WithMaxValueSize, строгий режим), ничего не меняется и возвращается ошибка.
+//
+// Подписчики и приёмник WithSink получают удаление ключей, которых нет в items, и запись
+// остальных.
+func (s *Store) ReplaceAll(items map[string]ItemDTO) error {
+	if err := s.enter(); err != nil {
+		return err
+	}
+	defer s.leave()
+
+	now := s.now()
+	fresh := make(map[string]*Item, len(items))
+	for key, dto := range items {
+		if err := s.checkWrite(key, 0); err != nil {
+			return fmt.Errorf("store: replace all: %w", err)
+		}
+		if err := s.checkValueSize(key, len(dto.Value)); err != nil {
+			return fmt.Errorf("store: replace all: %w", err)
+		}
+		if !dto.ExpiresAt.IsZero() && now.After(dto.ExpiresAt) {
+			continue
+		}
+		item := &Item{
+			ExpiresAt:  dto.ExpiresAt,
+			CreatedAt:  dto.CreatedAt,
+			UpdatedAt:  dto.UpdatedAt,
+			Provenance: dto.Provenance.clone(),
+		}
+		if item.UpdatedAt.IsZero() {
+			item.UpdatedAt = now
+		}
+		s.setCompressed(item, dto.Value)
+		item.Views.Store(dto.Views)
+		if !dto.LastAccessedAt.IsZero() {
+			item.accessedAt.Store(dto.LastAccessedAt.UnixNano())
+		}
+		fresh[s.wkey(key)] = item
+	}
+
+	s.mu.Lock()
+	old := s.data
+	s.data = make(map[string]*Item, len(fresh))
+	s.length.Store(0)
+	s.priorityCount = [priorityLevels]int{}
+	s.resetIndexLocked()
+	if s.ring != nil {
+		s.ring.reset()
+	}
+	s.history, s.tombs, s.dependents, s.groups = nil, nil, nil, nil
+	for key := range s.pins {
+		if _, ok := fresh[key]; !ok {
+			delete(s.pins, key)
+		}
+	}
+	s.memUsed, s.compressionSaved = 0, 0
+	if s.interned != nil {
+		s.interned = newInternTable(s.cfg.internMax)
+	}
+	for _, ns := range s.namespaces {
+		ns.members, ns.memUsed = make(map[string]struct{}), 0
+	}
+	for key, item := range fresh {
+		s.putLocked(key, item)
+	}
+	for key := range old {
+		if _, ok := fresh[key]; !ok {
+			s.mirrorDeleteLocked(key) // новые ключи sync.Map уже перезаписал putLocked
+		}
+	}
+	s.resetBloomLocked() // без ключей прежнего набора
+	notify := s.watchers.n.Load() > 0
+	for key, item := range old {
+		if _, ok := fresh[key]; ok {
+			continue
+		}
+		if notify {
+			s.notifyLocked(EventDelete, key, "", item.text(), item.attrs)
+		}
+		s.sinkBulkLocked(EventDelete, key, nil, now)
+	}
+	for key, item := range fresh {
+		s.sinkBulkLocked(EventSet, key, item, now)
+	}
+	s.evictLocked(now, "")
+	s.mu.Unlock()
+
+	s.cfg.logger.Info("store: dataset replaced", "keys", len(fresh), "previous", len(old))
+	return nil
+}
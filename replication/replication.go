@@ -0,0 +1,487 @@
+// Package replication - репликация хранилища primary/replica поверх CDC-потока (store.Mutation).
+//
+// Основной узел - Primary, он же приёмник изменений store.WithSink. Реплика (Replica)
+// подключается по TCP, получает полный срез данных основного узла, а затем живой поток
+// изменений, и обслуживает только чтение: её хранилище в режиме store.Store.SetReadOnly.
+// После обрыва связи реплика переподключается и заново получает полный срез.
+//
+//	p := replication.NewPrimary()
+//	s := store.NewStore(store.WithSink(p, store.SinkOptions{}))
+//	go p.Serve(s, ln)
+//
+//	r := replication.NewReplica("primary:7000", replicaStore)
+//	go r.Run(ctx)
+//
+// Протокол - JSON значения подряд: реплика шлёт hello с токеном, основной узел отвечает
+// заголовком с числом ключей среза, затем сами ключи как Mutation и дальше поток изменений.
+// Реплика, которая не успевает читать, отключается и потом получает срез заново. Очередь
+// WithSink нужна без DropWhenFull: изменение, отброшенное до Primary, реплики молча пропустят.
+//
+// Клиент, записавший ключ на основном узле через store.Store.SetWithToken, видит свою
+// запись на реплике, если читает с неё после Replica.CaughtUp или Replica.WaitFor с этим
+// токеном, иначе его стоит отправить на основной узел. Токен несёт эпоху основного узла
+// (store.Store.Epoch), так что после его рестарта версии новых записей не путаются
+// со старыми.
+package replication
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// ErrPrimaryClosed возвращается из Serve после Close.
+var ErrPrimaryClosed = errors.New("replication: primary closed")
+
+// ErrUnauthorized - основной узел отклонил токен реплики.
+var ErrUnauthorized = errors.New("replication: unauthorized")
+
+const (
+	defaultReplicaBuffer = 1024
+	defaultRetryInterval = time.Second
+)
+
+// Option настраивает Primary и Replica.
+type Option func(*options)
+
+type options struct {
+	token  string
+	buffer int
+	retry  time.Duration
+	tls    *tls.Config
+}
+
+// WithToken задаёт общий токен: основной узел принимает только реплики с этим токеном.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithReplicaBuffer задаёт, сколько пачек изменений основной узел держит для каждой реплики,
+// по умолчанию 1024. Реплика, отставшая больше, отключается.
+func WithReplicaBuffer(n int) Option {
+	return func(o *options) {
+		o.buffer = n
+	}
+}
+
+// WithRetryInterval задаёт паузу реплики перед переподключением, по умолчанию секунда.
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.retry = d
+	}
+}
+
+// WithTLS включает TLS при подключении реплики. Основной узел принимает листенер как есть,
+// для TLS его оборачивают через tls.NewListener.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tls = cfg
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{buffer: defaultReplicaBuffer, retry: defaultRetryInterval}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// hello - первое сообщение реплики
+type hello struct {
+	Token string `json:"token,omitempty"`
+}
+
+// header - ответ основного узла перед срезом
+type header struct {
+	Keys    int    `json:"keys"`
+	Version uint64 `json:"version,omitempty"` // store.ReadSnapshot.Version среза
+	Epoch   uint64 `json:"epoch,omitempty"`   // store.Store.Epoch основного узла
+	Error   string `json:"error,omitempty"`
+}
+
+// Primary раздаёт изменения хранилища подключенным репликам. Primary - store.Sink.
+type Primary struct {
+	opts options
+
+	mu       sync.Mutex
+	ln       net.Listener
+	replicas map[*follower]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// follower - подключенная реплика на стороне основного узла
+type follower struct {
+	conn net.Conn
+	ch   chan []store.Mutation
+	done chan struct{} // закрывается в drop
+	once sync.Once
+}
+
+func (f *follower) drop() {
+	f.once.Do(func() {
+		close(f.done)
+		f.conn.Close()
+	})
+}
+
+// NewPrimary создаёт основной узел. Его нужно передать в store.WithSink хранилища,
+// а затем запустить Serve с этим хранилищем.
+func NewPrimary(opts ...Option) *Primary {
+	return &Primary{opts: newOptions(opts), replicas: make(map[*follower]struct{})}
+}
+
+// Write раздаёт пачку изменений репликам не блокируясь: реплика с заполненным буфером
+// отключается, так медленная реплика не тормозит запись в хранилище.
+func (p *Primary) Write(ctx context.Context, batch []store.Mutation) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for f := range p.replicas {
+		select {
+		case f.ch <- batch:
+		default:
+			delete(p.replicas, f)
+			f.drop()
+		}
+	}
+	return nil
+}
+
+// Replicas возвращает число подключенных реплик.
+func (p *Primary) Replicas() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.replicas)
+}
+
+// Serve принимает реплики на ln и отдаёт им данные s. Всегда возвращает ошибку,
+// после Close - ErrPrimaryClosed.
+func (p *Primary) Serve(s *store.Store, ln net.Listener) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		ln.Close()
+		return ErrPrimaryClosed
+	}
+	p.ln = ln
+	p.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return ErrPrimaryClosed
+			}
+			return err
+		}
+		p.wg.Add(1)
+		go p.handle(s, conn)
+	}
+}
+
+// Close закрывает листенер и отключает реплики, затем ждёт завершения обработчиков или отмены ctx.
+func (p *Primary) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	if p.ln != nil {
+		p.ln.Close()
+	}
+	for f := range p.replicas {
+		delete(p.replicas, f)
+		f.drop()
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handle обслуживает одну реплику. Реплика регистрируется до снятия среза, поэтому изменения,
+// сделанные во время его отправки, придут следом и ничего не потеряется; часть из них может
+// повторить срез, это безопасно - применение изменения идемпотентно
+func (p *Primary) handle(s *store.Store, conn net.Conn) {
+	defer p.wg.Done()
+	f := &follower{conn: conn, ch: make(chan []store.Mutation, p.opts.buffer), done: make(chan struct{})}
+	defer f.drop()
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	w := bufio.NewWriter(conn)
+	enc := json.NewEncoder(w)
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return
+	}
+	if p.opts.token != "" && subtle.ConstantTimeCompare([]byte(h.Token), []byte(p.opts.token)) != 1 {
+		enc.Encode(header{Error: ErrUnauthorized.Error()})
+		w.Flush()
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.replicas[f] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.replicas, f)
+		p.mu.Unlock()
+	}()
+
+	snap := s.Snapshot()
+	if err := enc.Encode(header{Keys: snap.Len(), Version: snap.Version(), Epoch: s.Epoch()}); err != nil {
+		return
+	}
+	var err error
+	snap.Range(func(key string, item store.ItemDTO) bool {
+		err = enc.Encode(store.Mutation{Type: store.EventSet, Key: key, Kind: item.Kind, Value: item.Value, ExpiresAt: item.ExpiresAt, At: item.UpdatedAt})
+		return err == nil
+	})
+	if err != nil || w.Flush() != nil {
+		return
+	}
+
+	cdc := store.NewCDCWriter(w)
+	for {
+		select {
+		case batch := <-f.ch:
+			if err := cdc.Write(context.Background(), batch); err != nil {
+				return
+			}
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// Replica поддерживает хранилище копией основного узла.
+type Replica struct {
+	addr string
+	s    *store.Store
+	opts options
+
+	connected atomic.Bool
+	applied   atomic.Uint64
+	syncs     atomic.Uint64
+	lag       atomic.Int64 // задержка последнего изменения потока, см. Lag
+
+	posMu    sync.Mutex
+	epoch    uint64        // эпоха основного узла, к которой относится position
+	position uint64        // последняя версия основного узла, до которой реплика догнала его
+	advanced chan struct{} // закрывается и заменяется при смене position, см. WaitFor
+}
+
+// NewReplica создаёт реплику основного узла addr, которая пишет в s. Хранилище s
+// переводится в режим только для чтения при запуске Run.
+func NewReplica(addr string, s *store.Store, opts ...Option) *Replica {
+	return &Replica{addr: addr, s: s, opts: newOptions(opts), advanced: make(chan struct{})}
+}
+
+// CaughtUp сообщает, применила ли реплика все записи основного узла до tok включительно,
+// так что чтение с неё покажет сессии её собственные записи (store.SessionToken). Нулевой
+// токен всегда true. Версии сравниваются в эпохе основного узла (store.SessionToken.Epoch):
+// токен другой эпохи - записи узла до рестарта или после него, пока реплика не получила
+// новый срез, - false, такую сессию стоит отправить на основной узел. Токен без эпохи
+// сравнивается только по версии.
+func (r *Replica) CaughtUp(tok store.SessionToken) bool {
+	r.posMu.Lock()
+	defer r.posMu.Unlock()
+	return r.caughtUpLocked(tok)
+}
+
+func (r *Replica) caughtUpLocked(tok store.SessionToken) bool {
+	if tok.IsZero() {
+		return true
+	}
+	return (tok.Epoch == 0 || tok.Epoch == r.epoch) && r.position >= tok.Version
+}
+
+// WaitFor ждёт, пока реплика не догонит tok (CaughtUp), или отмены ctx - тогда возвращает
+// ctx.Err(), и сессию стоит отправить на основной узел. Токен прошлой эпохи реплика не
+// догонит никогда, так что ждать его имеет смысл только с ограниченным ctx.
+func (r *Replica) WaitFor(ctx context.Context, tok store.SessionToken) error {
+	for {
+		r.posMu.Lock()
+		caught, advanced := r.caughtUpLocked(tok), r.advanced
+		r.posMu.Unlock()
+		if caught {
+			return nil
+		}
+		select {
+		case <-advanced:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// advance отмечает, что реплика догнала основной узел эпохи epoch до версии version.
+// Новая эпоха - основной узел перезапущен, и его версии начались заново: позиция
+// сбрасывается на version, а не растёт
+func (r *Replica) advance(epoch, version uint64) {
+	r.posMu.Lock()
+	defer r.posMu.Unlock()
+	if epoch == r.epoch && version <= r.position {
+		return
+	}
+	r.epoch, r.position = epoch, version
+	close(r.advanced)
+	r.advanced = make(chan struct{})
+}
+
+// Store возвращает хранилище реплики.
+func (r *Replica) Store() *store.Store {
+	return r.s
+}
+
+// Connected сообщает, получает ли реплика сейчас поток изменений.
+func (r *Replica) Connected() bool {
+	return r.connected.Load()
+}
+
+// Applied возвращает, сколько изменений применено, включая ключи полных срезов.
+func (r *Replica) Applied() uint64 {
+	return r.applied.Load()
+}
+
+// Lag возвращает задержку последнего изменения потока: сколько прошло от записи на основном
+// узле до применения на реплике, 0 - изменений после полного среза ещё не было. Считается по
+// часам обоих узлов, так что включает их расхождение. Пока основной узел не пишет, Lag не
+// растёт - отставание после обрыва показывает Connected.
+func (r *Replica) Lag() time.Duration {
+	return time.Duration(r.lag.Load())
+}
+
+// Syncs возвращает, сколько раз реплика получала полный срез.
+func (r *Replica) Syncs() uint64 {
+	return r.syncs.Load()
+}
+
+// Run подключается к основному узлу и применяет его изменения до отмены ctx, переподключаясь
+// после обрыва через WithRetryInterval. Возвращает ctx.Err() или ErrUnauthorized - с неверным
+// токеном повторять бесполезно.
+func (r *Replica) Run(ctx context.Context) error {
+	r.s.SetReadOnly(true)
+	for {
+		err := r.sync(ctx)
+		r.connected.Store(false)
+		if errors.Is(err, ErrUnauthorized) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		t := time.NewTimer(r.opts.retry)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// sync - одно подключение: срез, затем поток до обрыва
+func (r *Replica) sync(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return err
+	}
+	if r.opts.tls != nil {
+		conn = tls.Client(conn, r.opts.tls)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := json.NewEncoder(conn).Encode(hello{Token: r.opts.token}); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return fmt.Errorf("replication: read header: %w", err)
+	}
+	if h.Error != "" {
+		if h.Error == ErrUnauthorized.Error() {
+			return ErrUnauthorized
+		}
+		return fmt.Errorf("replication: primary: %s", h.Error)
+	}
+
+	// срез целиком заменяет данные реплики: ключи, которых в нём нет, удаляются
+	seen := make(map[string]struct{}, h.Keys)
+	for range h.Keys {
+		var m store.Mutation
+		if err := dec.Decode(&m); err != nil {
+			return fmt.Errorf("replication: read snapshot: %w", err)
+		}
+		if err := r.apply(m); err != nil {
+			return err
+		}
+		seen[m.Key] = struct{}{}
+	}
+	for _, key := range r.s.Keys("*") {
+		if _, ok := seen[key]; !ok {
+			r.apply(store.Mutation{Type: store.EventDelete, Key: key})
+		}
+	}
+	r.syncs.Add(1)
+	r.lag.Store(0)
+	r.advance(h.Epoch, h.Version)
+	r.connected.Store(true)
+
+	for {
+		var m store.Mutation
+		if err := dec.Decode(&m); err != nil {
+			return fmt.Errorf("replication: read stream: %w", err)
+		}
+		if err := r.apply(m); err != nil {
+			return err
+		}
+		if !m.At.IsZero() {
+			r.lag.Store(int64(max(time.Since(m.At), 0)))
+		}
+		r.advance(h.Epoch, m.Version)
+	}
+}
+
+func (r *Replica) apply(m store.Mutation) error {
+	if err := r.s.ApplyMutation(m); err != nil {
+		return err
+	}
+	r.applied.Add(1)
+	return nil
+}
@@ -0,0 +1,146 @@
+package replication
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// primary запускает основной узел на addr ("" - свободный порт) и возвращает его хранилище
+// и адрес. Узел останавливается функцией stop, как при падении процесса
+func primary(t *testing.T, addr string) (s *store.Store, bound string, stop func()) {
+	t.Helper()
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPrimary()
+	s, err = store.New(store.WithSink(p, store.SinkOptions{FlushInterval: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go p.Serve(s, ln)
+	stop = func() {
+		p.Close(context.Background())
+		s.Close(context.Background())
+	}
+	t.Cleanup(stop)
+	return s, ln.Addr().String(), stop
+}
+
+func waitCaughtUp(t *testing.T, r *Replica, tok store.SessionToken) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.WaitFor(ctx, tok); err != nil {
+		t.Fatalf("replica did not catch up with %v: %v", tok, err)
+	}
+}
+
+func TestReplicaCatchesUpWithToken(t *testing.T) {
+	s, addr, _ := primary(t, "")
+	rs := store.NewStore()
+	r := NewReplica(addr, rs, WithRetryInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	tok, err := s.SetWithToken("k", "v", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCaughtUp(t, r, tok)
+	if v, ok := rs.Get("k"); !ok || v != "v" {
+		t.Errorf("replica Get(k) = %q, %v after CaughtUp", v, ok)
+	}
+}
+
+func TestCaughtUpResetsOnPrimaryRestart(t *testing.T) {
+	old, addr, stop := primary(t, "")
+	rs := store.NewStore()
+	r := NewReplica(addr, rs, WithRetryInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	var oldTok store.SessionToken
+	for i := range 10 {
+		tok, err := old.SetWithToken("k", "old-"+strconv.Itoa(i), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		oldTok = oldTok.Merge(tok)
+	}
+	waitCaughtUp(t, r, oldTok)
+
+	// рестарт без снапшота: версии нового узла снова начинаются с единицы
+	stop()
+	s, _, _ := primary(t, addr)
+	tok, err := s.SetWithToken("k", "new", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Version >= oldTok.Version {
+		t.Fatalf("restarted primary version %d, want below %d", tok.Version, oldTok.Version)
+	}
+	if r.CaughtUp(tok) {
+		if v, _ := rs.Get("k"); v != "new" {
+			t.Fatalf("CaughtUp with a token of the restarted primary, but Get(k) = %q", v)
+		}
+	}
+	waitCaughtUp(t, r, tok)
+	if v, ok := rs.Get("k"); !ok || v != "new" {
+		t.Errorf("replica Get(k) = %q, %v after CaughtUp", v, ok)
+	}
+	if r.CaughtUp(oldTok) {
+		t.Error("token of the previous primary epoch is reported as caught up")
+	}
+}
+
+func TestReplicaFollowsCounter(t *testing.T) {
+	s, addr, _ := primary(t, "")
+	rs := store.NewStore()
+	r := NewReplica(addr, rs, WithRetryInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	c := s.NewCounter("views")
+	tok, err := s.SetWithToken("ready", "1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitCaughtUp(t, r, tok)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			for range 10 {
+				if _, err := c.Add(1); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		v, _ := rs.Get("views")
+		if v == "100" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replica counter = %q, want 100", v)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
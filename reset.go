@@ -0,0 +1,111 @@
+package store
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrResetRefused возвращают ResetAll, ResetMatching и ResetNamespace, если очистка не
+// подтверждена, см. WithResetGuard и WithResetExpectedSize.
+var ErrResetRefused = errors.New("store: reset refused")
+
+// WithResetGuard защищает хранилище от случайной очистки: ResetAll, ResetMatching и
+// ResetNamespace удаляют ключи, только если им передали WithResetConfirm(token) или
+// WithResetExpectedSize, а Reset не делает ничего. Namespace.Reset защита не касается.
+func WithResetGuard(token string) Option {
+	return func(c *config) {
+		c.resetToken = token
+	}
+}
+
+// ResetOption - подтверждение очистки, см. ResetAll.
+type ResetOption func(*resetConfig)
+
+type resetConfig struct {
+	confirm  string
+	expected int // -1 - не проверять
+}
+
+// WithResetConfirm передаёт токен WithResetGuard.
+func WithResetConfirm(token string) ResetOption {
+	return func(c *resetConfig) {
+		c.confirm = token
+	}
+}
+
+// WithResetExpectedSize очищает, только если под очистку попадает ровно n ключей, включая
+// истекшие, но ещё не удаленные, как в Size. Администратор сначала смотрит, сколько ключей
+// удалит, и передаёт это число: если с тех пор их стало больше (промахнулись префиксом,
+// запрос ушёл не на тот экземпляр), очистка не выполняется. Проверяется и без WithResetGuard.
+func WithResetExpectedSize(n int) ResetOption {
+	return func(c *resetConfig) {
+		c.expected = n
+	}
+}
+
+// checkReset проверяет подтверждение очистки n ключей, вызывается под s.mu.Lock
+func (s *Store) checkReset(n int, opts []ResetOption) error {
+	cfg := resetConfig{expected: -1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.expected >= 0 && cfg.expected != n {
+		return fmt.Errorf("%w: expected %d keys, found %d", ErrResetRefused, cfg.expected, n)
+	}
+	if s.cfg.resetToken == "" || cfg.expected >= 0 {
+		return nil
+	}
+	if cfg.confirm == "" {
+		return fmt.Errorf("%w: reset is guarded, pass a confirmation token or the expected size", ErrResetRefused)
+	}
+	if subtle.ConstantTimeCompare([]byte(cfg.confirm), []byte(s.cfg.resetToken)) != 1 {
+		return fmt.Errorf("%w: wrong confirmation token", ErrResetRefused)
+	}
+	return nil
+}
+
+// ResetMatching удаляет ключи с префиксом prefix и возвращает их число, остальные не
+// трогает. Подписчики получают EventDelete на каждый ключ, отложенные SetAt под префиксом
+// отменяются. Пустой префикс - ResetAll.
+func (s *Store) ResetMatching(prefix string, opts ...ResetOption) (int, error) {
+	if prefix == "" {
+		return s.ResetAll(opts...)
+	}
+	if err := s.enter(); err != nil {
+		return 0, err
+	}
+	defer s.leave()
+
+	s.mu.Lock()
+	var keys []string
+	for raw := range s.data {
+		if key, ok := s.userKey(raw); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, raw)
+		}
+	}
+	if err := s.checkReset(len(keys), opts); err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	now := s.now()
+	for _, raw := range keys {
+		s.removeLocked(raw, EventDelete)
+		s.sinkBulkLocked(EventDelete, raw, nil, now)
+	}
+	s.mu.Unlock()
+	s.cancelScheduledMatching(prefix)
+	s.audit(AuditReset, prefix, writeOpts{})
+	s.cfg.logger.Info("store: reset matching", "prefix", prefix, "removed", len(keys))
+	return len(keys), nil
+}
+
+// ResetNamespace удаляет ключи пространства имён name, как ResetMatching с префиксом
+// name+NamespaceSep. Пространство не обязано быть создано через Namespace.
+func (s *Store) ResetNamespace(name string, opts ...ResetOption) (int, error) {
+	if name == "" || strings.Contains(name, NamespaceSep) {
+		return 0, fmt.Errorf("store: invalid namespace name %q", name)
+	}
+	return s.ResetMatching(name+NamespaceSep, opts...)
+}
@@ -0,0 +1,711 @@
+package respserver
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// command - обработчик команды, args[0] - имя команды
+type command struct {
+	arity   int // как в Redis: n - ровно n аргументов с именем, -n - минимум n
+	handler func(s *store.Store, w writer, args []string)
+}
+
+var commands = map[string]command{
+	"PING":      {-1, cmdPing},
+	"COMMAND":   {-1, cmdCommand},
+	"GET":       {2, cmdGet},
+	"SET":       {-3, cmdSet},
+	"DEL":       {-2, cmdDel},
+	"EXISTS":    {-2, cmdExists},
+	"TTL":       {2, cmdTTL},
+	"EXPIRE":    {3, cmdExpire},
+	"KEYS":      {2, cmdKeys},
+	"INCR":      {2, cmdIncr},
+	"FLUSHALL":  {-1, cmdFlushAll},
+	"INFO":      {-1, cmdInfo},
+	"SLOWLOG":   {-2, cmdSlowLog},
+	"TYPE":      {2, cmdType},
+	"HSET":      {-4, cmdHSet},
+	"HGET":      {3, cmdHGet},
+	"HDEL":      {-3, cmdHDel},
+	"HGETALL":   {2, cmdHGetAll},
+	"LPUSH":     {-3, cmdLPush},
+	"RPUSH":     {-3, cmdRPush},
+	"LPOP":      {2, cmdLPop},
+	"RPOP":      {2, cmdRPop},
+	"LRANGE":    {4, cmdLRange},
+	"SADD":      {-3, cmdSAdd},
+	"SREM":      {-3, cmdSRem},
+	"SISMEMBER": {3, cmdSIsMember},
+	"SMEMBERS":  {2, cmdSMembers},
+	"SCARD":     {2, cmdSCard},
+
+	"ZADD":          {-4, cmdZAdd},
+	"ZINCRBY":       {4, cmdZIncrBy},
+	"ZREM":          {-3, cmdZRem},
+	"ZRANGEBYSCORE": {-4, cmdZRangeByScore},
+	"PFADD":         {-2, cmdPFAdd},
+	"PFCOUNT":       {-2, cmdPFCount},
+	"SETBIT":        {4, cmdSetBit},
+	"GETBIT":        {3, cmdGetBit},
+	"BITCOUNT":      {2, cmdBitCount},
+	"APPEND":        {3, cmdAppend},
+	"GETRANGE":      {4, cmdGetRange},
+}
+
+const errWrongType = "WRONGTYPE Operation against a key holding the wrong kind of value"
+
+func cmdPing(s *store.Store, w writer, args []string) {
+	if len(args) > 1 {
+		w.bulk(args[1])
+		return
+	}
+	w.simple("PONG")
+}
+
+// cmdCommand - redis-cli при подключении запрашивает COMMAND DOCS, пустого ответа ему достаточно
+func cmdCommand(s *store.Store, w writer, args []string) {
+	w.array(nil)
+}
+
+func cmdGet(s *store.Store, w writer, args []string) {
+	if !checkType(s, w, args[1], "string") {
+		return
+	}
+	value, ok := s.Get(args[1])
+	if !ok {
+		w.null()
+		return
+	}
+	w.bulk(value)
+}
+
+// cmdSet - SET key value [EX seconds | PX milliseconds] [NX | XX]
+func cmdSet(s *store.Store, w writer, args []string) {
+	key, value := args[1], args[2]
+
+	var (
+		ttl    time.Duration
+		nx, xx bool
+	)
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if ttl != 0 || i+1 >= len(args) {
+				w.error("ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if strings.EqualFold(args[i], "PX") {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+	if nx && xx {
+		w.error("ERR syntax error")
+		return
+	}
+
+	switch {
+	case nx:
+		if !s.SetNX(key, value, ttl) {
+			w.null()
+			return
+		}
+	case xx:
+		if !s.SetXX(key, value, ttl) {
+			w.null()
+			return
+		}
+	default:
+		if err := s.Set(key, value, ttl); err != nil {
+			w.error("ERR " + err.Error())
+			return
+		}
+	}
+	w.simple("OK")
+}
+
+func cmdDel(s *store.Store, w writer, args []string) {
+	var n int64
+	for _, key := range args[1:] {
+		if _, ok := s.TTL(key); ok {
+			n++
+		}
+		s.Delete(key)
+	}
+	w.integer(n)
+}
+
+func cmdExists(s *store.Store, w writer, args []string) {
+	var n int64
+	for _, ok := range s.ExistsMany(args[1:]...) {
+		if ok {
+			n++
+		}
+	}
+	w.integer(n)
+}
+
+// cmdTTL - как в Redis: -2 ключа нет, -1 нет срока истечения, иначе секунды
+func cmdTTL(s *store.Store, w writer, args []string) {
+	ttl, ok := s.TTL(args[1])
+	switch {
+	case !ok:
+		w.integer(-2)
+	case ttl == 0:
+		w.integer(-1)
+	default:
+		w.integer(int64((ttl + time.Second/2) / time.Second))
+	}
+}
+
+// cmdExpire - EXPIRE key seconds, неположительный TTL удаляет ключ, как в Redis
+func cmdExpire(s *store.Store, w writer, args []string) {
+	seconds, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+
+	if seconds <= 0 {
+		_, ok := s.TTL(args[1])
+		s.Delete(args[1])
+		w.integer(boolInt(ok))
+		return
+	}
+	w.integer(boolInt(s.Expire(args[1], time.Duration(seconds)*time.Second)))
+}
+
+func cmdKeys(s *store.Store, w writer, args []string) {
+	w.array(s.Keys(args[1]))
+}
+
+func cmdIncr(s *store.Store, w writer, args []string) {
+	n, err := s.IncrBy(args[1], 1)
+	if errors.Is(err, store.ErrWrongType) {
+		w.error(errWrongType)
+		return
+	}
+	if errors.Is(err, store.ErrNotInteger) {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+	w.integer(n)
+}
+
+func cmdFlushAll(s *store.Store, w writer, args []string) {
+	if _, err := s.ResetAll(); err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.simple("OK")
+}
+
+// cmdInfo - INFO [section ...] в формате Redis: секции "# Name" со строками field:value,
+// без аргументов или с all/everything - все секции, см. store.Store.Info
+func cmdInfo(s *store.Store, w writer, args []string) {
+	info := s.Info()
+	sections := []struct {
+		name   string
+		fields [][2]string
+	}{
+		{"Server", [][2]string{
+			{"engine", info.Engine},
+			{"uptime_in_seconds", strconv.FormatInt(int64(info.Uptime/time.Second), 10)},
+			{"read_only", boolField(info.ReadOnly)},
+		}},
+		{"Memory", [][2]string{
+			{"used_memory", strconv.FormatInt(info.MemoryBytes, 10)},
+			{"maxmemory", strconv.FormatInt(info.MemoryLimit, 10)},
+		}},
+		{"Stats", [][2]string{
+			{"keyspace_hits", strconv.FormatUint(info.Hits, 10)},
+			{"keyspace_misses", strconv.FormatUint(info.Misses, 10)},
+			{"hit_ratio", strconv.FormatFloat(info.HitRatio, 'f', 4, 64)},
+			{"expired_keys", strconv.FormatUint(info.Expired, 10)},
+			{"evicted_keys", strconv.FormatUint(info.Evictions, 10)},
+			{"watchers", strconv.Itoa(info.Watchers)},
+		}},
+		{"Cleanup", [][2]string{
+			{"passes", strconv.FormatUint(info.Cleanup.Passes, 10)},
+			{"last_pass_ago_ms", lastPassAgo(info)},
+			{"last_removed", strconv.Itoa(info.Cleanup.LastRemoved)},
+			{"last_took_us", strconv.FormatInt(info.Cleanup.LastTook.Microseconds(), 10)},
+			{"incremental", boolField(info.Cleanup.Incremental)},
+			{"scheduled", strconv.Itoa(info.Cleanup.Scheduled)},
+		}},
+		{"Keyspace", keyspaceFields(info)},
+	}
+
+	want := make(map[string]bool, len(args)-1)
+	for _, a := range args[1:] {
+		want[strings.ToLower(a)] = true
+	}
+	all := len(want) == 0 || want["all"] || want["everything"]
+
+	var b strings.Builder
+	for _, sec := range sections {
+		if !all && !want[strings.ToLower(sec.name)] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + sec.name + "\r\n")
+		for _, f := range sec.fields {
+			b.WriteString(f[0] + ":" + f[1] + "\r\n")
+		}
+	}
+	w.bulk(b.String())
+}
+
+// cmdSlowLog - SLOWLOG GET [count] | LEN | RESET. Запись GET - массив из ID, unix-времени
+// начала, длительности в микросекундах и массива [операция, ключ], как в Redis до 4.0
+func cmdSlowLog(s *store.Store, w writer, args []string) {
+	switch strings.ToUpper(args[1]) {
+	case "GET":
+		n := 10
+		if len(args) > 2 {
+			v, err := strconv.Atoi(args[2])
+			if err != nil {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+			n = v
+		}
+		entries := s.SlowLog(n)
+		w.arrayLen(len(entries))
+		for _, e := range entries {
+			w.arrayLen(4)
+			w.integer(int64(e.ID))
+			w.integer(e.At.Unix())
+			w.integer(e.Duration.Microseconds())
+			if e.Key == "" {
+				w.array([]string{e.Op})
+			} else {
+				w.array([]string{e.Op, e.Key})
+			}
+		}
+	case "LEN":
+		w.integer(int64(s.SlowLogLen()))
+	case "RESET":
+		s.ResetSlowLog()
+		w.simple("OK")
+	default:
+		w.error("ERR unknown subcommand '" + args[1] + "'. Try SLOWLOG GET, LEN or RESET")
+	}
+}
+
+// keyspaceFields - ключи по шардам и пространствам имён
+func keyspaceFields(info store.Info) [][2]string {
+	fields := [][2]string{
+		{"keys", strconv.Itoa(info.Entries)},
+		{"pinned", strconv.Itoa(info.Pinned)},
+		{"shards", strconv.Itoa(info.Shards)},
+	}
+	for i, n := range info.ShardEntries {
+		fields = append(fields, [2]string{"shard" + strconv.Itoa(i), "keys=" + strconv.Itoa(n)})
+	}
+	names := make([]string, 0, len(info.Namespaces))
+	for name := range info.Namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, [2]string{"ns_" + name, "keys=" + strconv.Itoa(info.Namespaces[name])})
+	}
+	return fields
+}
+
+// lastPassAgo - сколько миллисекунд назад закончился проход очистки, -1 - проходов не было
+func lastPassAgo(info store.Info) string {
+	if info.Cleanup.Last.IsZero() {
+		return "-1"
+	}
+	return strconv.FormatInt(info.Started.Add(info.Uptime).Sub(info.Cleanup.Last).Milliseconds(), 10)
+}
+
+func boolField(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+func cmdType(s *store.Store, w writer, args []string) {
+	w.simple(s.Type(args[1]))
+}
+
+// cmdHSet - HSET key field value [field value ...]
+func cmdHSet(s *store.Store, w writer, args []string) {
+	if len(args)%2 != 0 {
+		w.error("ERR wrong number of arguments for 'hset' command")
+		return
+	}
+	fields := make(map[string]string, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		fields[args[i]] = args[i+1]
+	}
+	n, err := s.HSet(args[1], fields)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdHGet(s *store.Store, w writer, args []string) {
+	if !checkType(s, w, args[1], "hash") {
+		return
+	}
+	value, ok := s.HGet(args[1], args[2])
+	if !ok {
+		w.null()
+		return
+	}
+	w.bulk(value)
+}
+
+func cmdHDel(s *store.Store, w writer, args []string) {
+	n, err := s.HDel(args[1], args[2:]...)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+// cmdHGetAll отвечает плоским массивом field, value, ... в порядке полей, как redis-cli ожидает
+func cmdHGetAll(s *store.Store, w writer, args []string) {
+	if !checkType(s, w, args[1], "hash") {
+		return
+	}
+	fields := s.HGetAll(args[1])
+	names := make([]string, 0, len(fields))
+	for f := range fields {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	flat := make([]string, 0, 2*len(names))
+	for _, f := range names {
+		flat = append(flat, f, fields[f])
+	}
+	w.array(flat)
+}
+
+func cmdLPush(s *store.Store, w writer, args []string) {
+	n, err := s.LPush(args[1], args[2:]...)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdRPush(s *store.Store, w writer, args []string) {
+	n, err := s.RPush(args[1], args[2:]...)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdLPop(s *store.Store, w writer, args []string) {
+	value, ok, err := s.LPop(args[1])
+	writePop(w, value, ok, err)
+}
+
+func cmdRPop(s *store.Store, w writer, args []string) {
+	value, ok, err := s.RPop(args[1])
+	writePop(w, value, ok, err)
+}
+
+func writePop(w writer, value string, ok bool, err error) {
+	switch {
+	case err != nil:
+		writeTypeErr(w, err)
+	case !ok:
+		w.null()
+	default:
+		w.bulk(value)
+	}
+}
+
+func cmdLRange(s *store.Store, w writer, args []string) {
+	start, err1 := strconv.Atoi(args[2])
+	stop, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+	if !checkType(s, w, args[1], "list") {
+		return
+	}
+	w.array(s.LRange(args[1], start, stop))
+}
+
+func cmdSAdd(s *store.Store, w writer, args []string) {
+	n, err := s.SAdd(args[1], args[2:]...)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdSRem(s *store.Store, w writer, args []string) {
+	n, err := s.SRem(args[1], args[2:]...)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdSIsMember(s *store.Store, w writer, args []string) {
+	if !checkType(s, w, args[1], "set") {
+		return
+	}
+	w.integer(boolInt(s.SIsMember(args[1], args[2])))
+}
+
+func cmdSMembers(s *store.Store, w writer, args []string) {
+	if !checkType(s, w, args[1], "set") {
+		return
+	}
+	w.array(s.SMembers(args[1]))
+}
+
+func cmdSCard(s *store.Store, w writer, args []string) {
+	if !checkType(s, w, args[1], "set") {
+		return
+	}
+	w.integer(int64(s.SCard(args[1])))
+}
+
+// cmdZAdd - ZADD key score member [score member ...]
+func cmdZAdd(s *store.Store, w writer, args []string) {
+	if len(args)%2 != 0 {
+		w.error("ERR syntax error")
+		return
+	}
+	members := make(map[string]float64, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		score, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			w.error("ERR value is not a valid float")
+			return
+		}
+		members[args[i+1]] = score
+	}
+	n, err := s.ZAdd(args[1], members)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdZIncrBy(s *store.Store, w writer, args []string) {
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		w.error("ERR value is not a valid float")
+		return
+	}
+	score, err := s.ZIncrBy(args[1], args[3], delta)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.bulk(formatScore(score))
+}
+
+func cmdZRem(s *store.Store, w writer, args []string) {
+	n, err := s.ZRem(args[1], args[2:]...)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+// cmdZRangeByScore - ZRANGEBYSCORE key min max [WITHSCORES], границы как в Redis:
+// -inf, +inf и "(" перед числом для открытой границы
+func cmdZRangeByScore(s *store.Store, w writer, args []string) {
+	withScores := false
+	for _, opt := range args[4:] {
+		if !strings.EqualFold(opt, "WITHSCORES") {
+			w.error("ERR syntax error")
+			return
+		}
+		withScores = true
+	}
+	min, err1 := parseScoreBound(args[2], math.Inf(1))
+	max, err2 := parseScoreBound(args[3], math.Inf(-1))
+	if err1 != nil || err2 != nil {
+		w.error("ERR min or max is not a float")
+		return
+	}
+	if !checkType(s, w, args[1], "zset") {
+		return
+	}
+
+	out := []string{}
+	for _, m := range s.ZRangeByScore(args[1], min, max) {
+		out = append(out, m.Member)
+		if withScores {
+			out = append(out, formatScore(m.Score))
+		}
+	}
+	w.array(out)
+}
+
+// parseScoreBound разбирает границу диапазона, открытая граница "(x" сдвигается
+// к соседнему числу в сторону toward
+func parseScoreBound(s string, toward float64) (float64, error) {
+	open := strings.HasPrefix(s, "(")
+	f, err := strconv.ParseFloat(strings.TrimPrefix(s, "("), 64)
+	if err != nil {
+		return 0, err
+	}
+	if open {
+		f = math.Nextafter(f, toward)
+	}
+	return f, nil
+}
+
+// formatScore пишет счёт как Redis: без лишних нулей
+func formatScore(f float64) string {
+	return strconv.FormatFloat(f, 'g', 17, 64)
+}
+
+func cmdPFAdd(s *store.Store, w writer, args []string) {
+	changed, err := s.PFAdd(args[1], args[2:]...)
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(boolInt(changed))
+}
+
+func cmdPFCount(s *store.Store, w writer, args []string) {
+	for _, key := range args[1:] {
+		if !checkType(s, w, key, "hyperloglog") {
+			return
+		}
+	}
+	w.integer(int64(s.PFCount(args[1:]...)))
+}
+
+func cmdSetBit(s *store.Store, w writer, args []string) {
+	offset, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		w.error("ERR bit offset is not an integer or out of range")
+		return
+	}
+	if args[3] != "0" && args[3] != "1" {
+		w.error("ERR bit is not an integer or out of range")
+		return
+	}
+	old, err := s.SetBit(args[1], offset, args[3] == "1")
+	if errors.Is(err, store.ErrBitOffset) {
+		w.error("ERR bit offset is not an integer or out of range")
+		return
+	}
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(boolInt(old))
+}
+
+func cmdGetBit(s *store.Store, w writer, args []string) {
+	offset, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		w.error("ERR bit offset is not an integer or out of range")
+		return
+	}
+	if !checkType(s, w, args[1], "string") {
+		return
+	}
+	w.integer(boolInt(s.GetBit(args[1], offset)))
+}
+
+func cmdBitCount(s *store.Store, w writer, args []string) {
+	if !checkType(s, w, args[1], "string") {
+		return
+	}
+	w.integer(int64(s.BitCount(args[1])))
+}
+
+func cmdAppend(s *store.Store, w writer, args []string) {
+	n, err := s.Append(args[1], args[2])
+	if err != nil {
+		writeTypeErr(w, err)
+		return
+	}
+	w.integer(int64(n))
+}
+
+func cmdGetRange(s *store.Store, w writer, args []string) {
+	start, err1 := strconv.Atoi(args[2])
+	end, err2 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+	if !checkType(s, w, args[1], "string") {
+		return
+	}
+	v, _ := s.GetRange(args[1], start, end)
+	w.bulk(v)
+}
+
+// checkType отвечает WRONGTYPE, если ключ есть и хранит не want
+func checkType(s *store.Store, w writer, key, want string) bool {
+	if t := s.Type(key); t != want && t != "none" {
+		w.error(errWrongType)
+		return false
+	}
+	return true
+}
+
+func writeTypeErr(w writer, err error) {
+	switch {
+	case errors.Is(err, store.ErrWrongType):
+		w.error(errWrongType)
+	default:
+		w.error("ERR " + err.Error())
+	}
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
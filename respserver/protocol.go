@@ -0,0 +1,109 @@
+package respserver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxBulkLen - ограничение на размер одного аргумента, как proto-max-bulk-len в Redis
+const maxBulkLen = 512 << 20
+
+var errProtocol = errors.New("protocol error")
+
+// readCommand читает одну команду: массив bulk-строк (так шлют клиенты и redis-cli)
+// или inline-команду, разделенную пробелами (так удобно из telnet/nc)
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string is not terminated by CRLF", errProtocol)
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// readLine читает строку до \r\n (или \n) без разделителя
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writer - ответы в формате RESP2
+type writer struct {
+	*bufio.Writer
+}
+
+func (w writer) simple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func (w writer) error(msg string) {
+	w.WriteString("-" + msg + "\r\n")
+}
+
+func (w writer) integer(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w writer) bulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n")
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w writer) null() {
+	w.WriteString("$-1\r\n")
+}
+
+// arrayLen пишет заголовок массива из n элементов, сами элементы пишет вызывающий
+func (w writer) arrayLen(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+func (w writer) array(items []string) {
+	w.arrayLen(len(items))
+	for _, item := range items {
+		w.bulk(item)
+	}
+}
@@ -0,0 +1,190 @@
+package respserver
+
+import (
+	"bufio"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Каналы уведомлений о ключах в именах Redis (notify-keyspace-events): на канал keyspace
+// ключа приходит имя события, на канал keyevent события - ключ.
+const (
+	keyspacePrefix = "__keyspace@0__:"
+	keyeventPrefix = "__keyevent@0__:"
+)
+
+// pubsubBuffer - буфер событий подписки одного подключения, при переполнении теряются старые
+const pubsubBuffer = 1024
+
+// eventNames - имена событий как в уведомлениях Redis
+var eventNames = map[store.EventType]string{
+	store.EventSet:    "set",
+	store.EventDelete: "del",
+	store.EventExpire: "expired",
+	store.EventEvict:  "evicted",
+}
+
+// pubsub - подключение в режиме подписки: SUBSCRIBE на каналы и PSUBSCRIBE на шаблоны
+// каналов keyspace и keyevent. Ответы команд и сообщения пишутся из разных горутин под mu
+type pubsub struct {
+	srv *Server
+	w   writer
+
+	mu       sync.Mutex
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+// subscribed обслуживает подключение после первого SUBSCRIBE или PSUBSCRIBE, как Redis:
+// из команд доступны только (P)SUBSCRIBE, (P)UNSUBSCRIBE, PING и QUIT. Возвращает nil,
+// когда подписок не осталось и подключение вернулось к обычным командам, иначе ошибку
+// чтения или errQuit
+func (srv *Server) subscribed(r *bufio.Reader, w writer, args []string) error {
+	ps := &pubsub{srv: srv, w: w, channels: make(map[string]struct{}), patterns: make(map[string]struct{})}
+	sub := srv.s.Subscribe("*", store.WithWatchBuffer(pubsubBuffer), store.WithDropPolicy(store.DropOldest))
+	defer sub.Unsubscribe()
+
+	done := make(chan struct{})
+	defer close(done)
+	go ps.forward(sub.Events(), done)
+
+	for {
+		if args != nil {
+			if ps.command(args) {
+				return nil
+			}
+		}
+		var err error
+		if args, err = readCommand(r); err != nil {
+			if errors.Is(err, errProtocol) {
+				ps.mu.Lock()
+				w.error("ERR " + err.Error())
+				w.Flush()
+				ps.mu.Unlock()
+			}
+			return err
+		}
+		if strings.EqualFold(args[0], "QUIT") {
+			ps.mu.Lock()
+			w.simple("OK")
+			w.Flush()
+			ps.mu.Unlock()
+			return errQuit
+		}
+	}
+}
+
+// errQuit - клиент отправил QUIT в режиме подписки
+var errQuit = errors.New("respserver: quit")
+
+// command выполняет команду режима подписки. true - подписок не осталось
+func (ps *pubsub) command(args []string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	defer ps.w.Flush()
+
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		if len(args) < 2 {
+			ps.w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+			return false
+		}
+		set, kind := ps.channels, "subscribe"
+		if name == "PSUBSCRIBE" {
+			set, kind = ps.patterns, "psubscribe"
+		}
+		for _, ch := range args[1:] {
+			set[ch] = struct{}{}
+			ps.reply(kind, ch)
+		}
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		set, kind := ps.channels, "unsubscribe"
+		if name == "PUNSUBSCRIBE" {
+			set, kind = ps.patterns, "punsubscribe"
+		}
+		names := args[1:]
+		if len(names) == 0 {
+			for ch := range set {
+				names = append(names, ch)
+			}
+			sort.Strings(names)
+		}
+		if len(names) == 0 {
+			ps.w.arrayLen(3)
+			ps.w.bulk(kind)
+			ps.w.null()
+			ps.w.integer(0)
+		}
+		for _, ch := range names {
+			delete(set, ch)
+			ps.reply(kind, ch)
+		}
+	case "PING":
+		ps.w.arrayLen(2)
+		ps.w.bulk("pong")
+		if len(args) > 1 {
+			ps.w.bulk(args[1])
+		} else {
+			ps.w.bulk("")
+		}
+	default:
+		ps.w.error("ERR Can't execute '" + strings.ToLower(args[0]) + "': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+	}
+	return len(ps.channels)+len(ps.patterns) == 0
+}
+
+// reply - подтверждение (P)(UN)SUBSCRIBE с числом оставшихся подписок, под mu
+func (ps *pubsub) reply(kind, ch string) {
+	ps.w.arrayLen(3)
+	ps.w.bulk(kind)
+	ps.w.bulk(ch)
+	ps.w.integer(int64(len(ps.channels) + len(ps.patterns)))
+}
+
+// forward превращает события хранилища в сообщения каналов keyspace и keyevent
+func (ps *pubsub) forward(events <-chan store.Event, done <-chan struct{}) {
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			name, ok := eventNames[ev.Type]
+			if !ok {
+				continue
+			}
+			ps.mu.Lock()
+			sent := ps.publish(keyspacePrefix+ev.Key, name)
+			sent = ps.publish(keyeventPrefix+name, ev.Key) || sent
+			if sent && ps.w.Flush() != nil {
+				ps.mu.Unlock()
+				return
+			}
+			ps.mu.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
+// publish отправляет msg подписчикам канала ch и подходящих шаблонов, под mu.
+// false - никому
+func (ps *pubsub) publish(ch, msg string) bool {
+	sent := false
+	if _, ok := ps.channels[ch]; ok {
+		ps.w.array([]string{"message", ch, msg})
+		sent = true
+	}
+	for p := range ps.patterns {
+		if store.MatchGlob(p, ch) {
+			ps.w.array([]string{"pmessage", p, ch, msg})
+			sent = true
+		}
+	}
+	return sent
+}
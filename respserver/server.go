@@ -0,0 +1,243 @@
+// Package respserver - минимальный сервер протокола RESP2 поверх store.Store,
+// с которым работают обычные Redis-клиенты и redis-cli.
+//
+// Поддерживаются команды GET, SET (EX/PX/NX/XX), DEL, EXISTS, TTL, EXPIRE, KEYS,
+// INCR, FLUSHALL, INFO (сводка store.Store.Info), SLOWLOG (store.WithSlowLog), а также
+// PING и COMMAND, которые клиенты шлют при подключении.
+//
+// SUBSCRIBE и PSUBSCRIBE подписывают на уведомления об изменениях ключей в каналах Redis
+// notify-keyspace-events: на __keyspace@0__:<ключ> приходит событие (set, del, expired,
+// evicted), на __keyevent@0__:<событие> - ключ. Например, все истечения:
+//
+//	SUBSCRIBE __keyevent@0__:expired
+//
+// С WithAuth подключение должно сначала выполнить AUTH, с WithTLS сервер слушает TLS.
+package respserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// ErrServerClosed возвращается из Serve после Shutdown.
+var ErrServerClosed = errors.New("respserver: server closed")
+
+// Option настраивает Server.
+type Option func(*Server)
+
+// WithAuth требует от каждого подключения команду AUTH <token> (или AUTH <user> <token>,
+// имя пользователя игнорируется) до любых других команд, как requirepass в Redis.
+func WithAuth(token string) Option {
+	return func(srv *Server) {
+		srv.token = token
+	}
+}
+
+// WithTLS включает TLS для ListenAndServe. Serve принимает листенер как есть.
+func WithTLS(cfg *tls.Config) Option {
+	return func(srv *Server) {
+		srv.tls = cfg
+	}
+}
+
+// Server - RESP сервер для хранилища.
+type Server struct {
+	s     *store.Store
+	token string
+	tls   *tls.Config
+
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New создаёт сервер для хранилища s.
+func New(s *store.Store, opts ...Option) *Server {
+	srv := &Server{
+		s:     s,
+		conns: make(map[net.Conn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv
+}
+
+// ListenAndServe слушает TCP адрес addr и обслуживает подключения до Shutdown.
+func (srv *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if srv.tls != nil {
+		ln = tls.NewListener(ln, srv.tls)
+	}
+	return srv.Serve(ln)
+}
+
+// Serve принимает подключения на ln. Всегда возвращает ошибку, после Shutdown - ErrServerClosed.
+func (srv *Server) Serve(ln net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	srv.ln = ln
+	srv.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		if !srv.track(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		srv.wg.Add(1)
+		go srv.handle(conn)
+	}
+}
+
+// Shutdown закрывает листенер и все подключения, затем ждёт завершения обработчиков или отмены ctx.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.mu.Lock()
+	srv.closed = true
+	if srv.ln != nil {
+		srv.ln.Close()
+	}
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		srv.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (srv *Server) track(conn net.Conn) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.closed {
+		return false
+	}
+	srv.conns[conn] = struct{}{}
+	return true
+}
+
+func (srv *Server) untrack(conn net.Conn) {
+	srv.mu.Lock()
+	delete(srv.conns, conn)
+	srv.mu.Unlock()
+}
+
+// handle обслуживает одно подключение. Ответы буферизуются и сбрасываются,
+// когда во входном буфере не осталось команд, так конвейер (pipelining) не делает лишних write
+func (srv *Server) handle(conn net.Conn) {
+	defer srv.wg.Done()
+	defer srv.untrack(conn)
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := writer{bufio.NewWriter(conn)}
+	authed := srv.token == ""
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.error("ERR " + err.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		name := strings.ToUpper(args[0])
+		if name == "QUIT" {
+			w.simple("OK")
+			w.Flush()
+			return
+		}
+		switch {
+		case name == "AUTH":
+			authed = srv.auth(w, args)
+		case !authed:
+			w.error("NOAUTH Authentication required.")
+		case name == "SUBSCRIBE" || name == "PSUBSCRIBE":
+			if srv.subscribed(r, w, args) != nil {
+				return
+			}
+		default:
+			srv.dispatch(w, name, args)
+		}
+
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// auth проверяет токен из AUTH, сравнение за постоянное время
+func (srv *Server) auth(w writer, args []string) bool {
+	if len(args) < 2 || len(args) > 3 {
+		w.error("ERR wrong number of arguments for 'auth' command")
+		return false
+	}
+	if srv.token == "" {
+		w.error("ERR AUTH called without any password configured")
+		return true
+	}
+	if subtle.ConstantTimeCompare([]byte(args[len(args)-1]), []byte(srv.token)) != 1 {
+		w.error("WRONGPASS invalid username-password pair")
+		return false
+	}
+	w.simple("OK")
+	return true
+}
+
+func (srv *Server) dispatch(w writer, name string, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		w.error("ERR unknown command '" + args[0] + "'")
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		return
+	}
+	cmd.handler(srv.s, w, args)
+}
@@ -0,0 +1,53 @@
+package store
+
+import "math/rand/v2"
+
+// RandomKey возвращает случайный неистекший ключ, каждый с равной вероятностью.
+// false - хранилище пусто. Стоит столько же, сколько Sample(1).
+func (s *Store) RandomKey() (string, bool) {
+	keys := s.Sample(1)
+	if len(keys) == 0 {
+		return "", false
+	}
+	return keys[0], true
+}
+
+// Sample возвращает n случайных различных неистекших ключей (все, если их меньше): каждый
+// набор из n ключей равновероятен. Порядок ключей в результате тоже случаен.
+//
+// Выборка резервуарная: один проход под RLock, память O(n), копия мапы не строится.
+// Порядок обхода мапы в Go для этого не годится - он случаен, но не равномерен, ключи
+// за длинными цепочками таблицы выпадают реже. Поэтому проход всегда полный, O(N);
+// для дешёвой неравномерной выборки, как у вытеснения, хватит обычного range по мапе.
+func (s *Store) Sample(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	now := s.now()
+	picked := make([]string, 0, min(n, 1024))
+	seen := 0
+
+	s.mu.RLock()
+	for raw, item := range s.data {
+		if item.expiredAt(now) {
+			continue
+		}
+		if _, ok := s.userKey(raw); !ok {
+			continue
+		}
+		seen++
+		if len(picked) < n {
+			picked = append(picked, raw)
+		} else if j := rand.IntN(seen); j < n {
+			picked[j] = raw
+		}
+	}
+	s.mu.RUnlock()
+
+	// ключи заполнения идут в порядке обхода, перемешиваем
+	rand.Shuffle(len(picked), func(i, j int) { picked[i], picked[j] = picked[j], picked[i] })
+	for i, raw := range picked {
+		picked[i], _ = s.userKey(raw)
+	}
+	return picked
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scheduledSet - запись, отложенная SetAt
+type scheduledSet struct {
+	key   string // пользовательский ключ, активация пишет через Set
+	value string
+	at    time.Time
+	ttl   time.Duration
+}
+
+// schedule - отложенные записи SetAt по ключу хранения
+type schedule struct {
+	mu      sync.Mutex
+	pending map[string]scheduledSet
+	n       atomic.Int64 // len(pending), что-бы чтения без отложенных записей не брали mu
+}
+
+// SetAt записывает value так, что оно становится видно только с момента activateAt, например
+// для выкатки флага в заданное время. До этого ключ остаётся прежним, в том числе
+// отсутствующим, а ttl отсчитывается от activateAt, даже если активация (см. ниже) случилась
+// позже; если к ней срок уже вышел, значение не записывается. Если activateAt не в будущем,
+// SetAt записывает сразу, как Set. Повторный SetAt ключа заменяет отложенную запись, а обычный
+// Set её не отменяет: в activateAt значение SetAt перезапишет ключ, см. CancelScheduled.
+//
+// Отложенные записи активирует тот же механизм, что удаляет истекшие ключи: первое
+// обращение к ключу (Get, TTL, Exists, чтения коллекций) после activateAt и проход Cleanup.
+// Листинги (Keys, FullList, List) видят значение после прохода Cleanup или обращения к ключу.
+// Активация - обычная запись: подписчики, WithSink и журнал аудита видят её как Set.
+func (s *Store) SetAt(key, value string, activateAt time.Time, ttl time.Duration) error {
+	if err := s.checkWrite(key, ttl); err != nil {
+		return err
+	}
+	if err := s.checkValueSize(key, len(value)); err != nil {
+		return err
+	}
+	if !activateAt.After(s.now()) {
+		return s.set(key, value, ttl, writeOpts{})
+	}
+	if err := s.beginWrite(writeOpts{}); err != nil {
+		return err
+	}
+	defer s.endWrite()
+
+	sc := &s.sched
+	sc.mu.Lock()
+	if sc.pending == nil {
+		sc.pending = make(map[string]scheduledSet)
+	}
+	sc.pending[s.skey(key)] = scheduledSet{key: key, value: value, at: activateAt, ttl: ttl}
+	sc.n.Store(int64(len(sc.pending)))
+	sc.mu.Unlock()
+	return nil
+}
+
+// CancelScheduled отменяет запись SetAt, которая ещё не активирована. false - её не было.
+func (s *Store) CancelScheduled(key string) bool {
+	sc := &s.sched
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	raw := s.skey(key)
+	if _, ok := sc.pending[raw]; !ok {
+		return false
+	}
+	delete(sc.pending, raw)
+	sc.n.Store(int64(len(sc.pending)))
+	return true
+}
+
+// Scheduled возвращает ключи с отложенными SetAt записями и моменты их активации.
+func (s *Store) Scheduled() map[string]time.Time {
+	sc := &s.sched
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	res := make(map[string]time.Time, len(sc.pending))
+	for _, e := range sc.pending {
+		res[e.key] = e.at
+	}
+	return res
+}
+
+// activateKey активирует отложенную запись ключа хранения raw, если её время пришло.
+// Вызывается в начале чтений, без блокировок
+func (s *Store) activateKey(raw string) {
+	sc := &s.sched
+	if sc.n.Load() == 0 {
+		return
+	}
+	now := s.now()
+	sc.mu.Lock()
+	e, ok := sc.pending[raw]
+	if !ok || e.at.After(now) {
+		sc.mu.Unlock()
+		return
+	}
+	delete(sc.pending, raw)
+	sc.n.Store(int64(len(sc.pending)))
+	sc.mu.Unlock()
+
+	s.activate(raw, e)
+}
+
+// activateScheduled активирует все отложенные записи, время которых пришло, вызывается из Cleanup
+func (s *Store) activateScheduled(now time.Time) {
+	sc := &s.sched
+	if sc.n.Load() == 0 {
+		return
+	}
+	var due map[string]scheduledSet
+	sc.mu.Lock()
+	for raw, e := range sc.pending {
+		if !e.at.After(now) {
+			if due == nil {
+				due = make(map[string]scheduledSet)
+			}
+			due[raw] = e
+			delete(sc.pending, raw)
+		}
+	}
+	sc.n.Store(int64(len(sc.pending)))
+	sc.mu.Unlock()
+
+	for raw, e := range due {
+		s.activate(raw, e)
+	}
+}
+
+// activate записывает отложенное значение со сроком от e.at. В режиме только для чтения
+// запись возвращается в расписание и активируется после его выключения, если её не заменил
+// новый SetAt
+func (s *Store) activate(raw string, e scheduledSet) {
+	ttl := e.ttl
+	if ttl > 0 {
+		if ttl = e.at.Add(e.ttl).Sub(s.now()); ttl <= 0 {
+			s.cfg.logger.Debug("store: scheduled set expired before activation", "key", e.key)
+			return
+		}
+	}
+	err := s.set(e.key, e.value, ttl, writeOpts{internal: true})
+	switch {
+	case errors.Is(err, ErrReadOnly):
+		sc := &s.sched
+		sc.mu.Lock()
+		if _, ok := sc.pending[raw]; !ok {
+			sc.pending[raw] = e
+			sc.n.Store(int64(len(sc.pending)))
+		}
+		sc.mu.Unlock()
+	case err != nil:
+		s.cfg.logger.Error("store: scheduled set failed", "key", e.key, "err", err)
+	default:
+		s.cfg.logger.Debug("store: scheduled set activated", "key", e.key)
+	}
+}
+
+// resetSchedule отменяет все отложенные записи, см. Reset
+func (s *Store) resetSchedule() {
+	sc := &s.sched
+	sc.mu.Lock()
+	sc.pending = nil
+	sc.n.Store(0)
+	sc.mu.Unlock()
+}
+
+// cancelScheduledMatching отменяет отложенные SetAt ключей с префиксом
+func (s *Store) cancelScheduledMatching(prefix string) {
+	sc := &s.sched
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for raw, p := range sc.pending {
+		if strings.HasPrefix(p.key, prefix) {
+			delete(sc.pending, raw)
+		}
+	}
+	sc.n.Store(int64(len(sc.pending)))
+}
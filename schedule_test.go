@@ -0,0 +1,75 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func scheduleStore(t *testing.T) (*Store, *ManualClock) {
+	t.Helper()
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s, err := New(WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, clock
+}
+
+func TestSetAtActivates(t *testing.T) {
+	s, clock := scheduleStore(t)
+	s.Set("flag", "off", 0)
+	if err := s.SetAt("flag", "on", clock.Now().Add(time.Minute), 0); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, s, "flag", "off")
+	if at, ok := s.Scheduled()["flag"]; !ok || !at.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("Scheduled()[flag] = %v, %v", at, ok)
+	}
+
+	clock.Advance(time.Minute)
+	wantValue(t, s, "flag", "on")
+	if len(s.Scheduled()) != 0 {
+		t.Error("activated write is still scheduled")
+	}
+}
+
+func TestSetAtTTLCountsFromActivateAt(t *testing.T) {
+	s, clock := scheduleStore(t)
+	if err := s.SetAt("k", "v", clock.Now().Add(time.Minute), 10*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// к ключу обращаются через 4 минуты после activateAt
+	clock.Advance(5 * time.Minute)
+	wantValue(t, s, "k", "v")
+	if ttl, ok := s.TTL("k"); !ok || ttl != 6*time.Minute {
+		t.Errorf("TTL = %v, %v, want 6m from activateAt", ttl, ok)
+	}
+}
+
+func TestSetAtExpiredBeforeActivation(t *testing.T) {
+	s, clock := scheduleStore(t)
+	s.Set("k", "old", 0)
+	if err := s.SetAt("k", "new", clock.Now().Add(time.Minute), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(3 * time.Minute)
+	wantValue(t, s, "k", "old")
+	if len(s.Scheduled()) != 0 {
+		t.Error("expired scheduled write is still pending")
+	}
+}
+
+func TestCancelScheduled(t *testing.T) {
+	s, clock := scheduleStore(t)
+	if err := s.SetAt("k", "v", clock.Now().Add(time.Minute), 0); err != nil {
+		t.Fatal(err)
+	}
+	if !s.CancelScheduled("k") {
+		t.Fatal("CancelScheduled = false for a pending write")
+	}
+	if s.CancelScheduled("k") {
+		t.Error("CancelScheduled = true twice")
+	}
+	clock.Advance(time.Minute)
+	wantMissing(t, s, "k")
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSessionToken возвращает ParseSessionToken для испорченного токена.
+var ErrInvalidSessionToken = errors.New("store: invalid session token")
+
+// SessionToken - позиция последней записи сессии для чтения своих записей (read-your-writes).
+// Клиент хранит токен между запросами (в cookie или заголовке, см. String), сливает токены
+// своих записей через Merge и передаёт его репликам (replication.Replica.CaughtUp) и
+// TieredStore.GetSession, а те отвечают не старше этих записей. Нулевой токен - сессия
+// ещё не писала, подходит любая копия.
+type SessionToken struct {
+	// Epoch - Store.Epoch основного узла: версии после его рестарта начинаются заново,
+	// и сравнивать их можно только в одной эпохе.
+	Epoch uint64
+	// Version - Item.Version записи на основном узле. Версии выдаются под блокировкой
+	// хранилища в порядке записей, по ним реплика сравнивает себя с основным узлом.
+	Version uint64
+	// At - Item.UpdatedAt записи. Копии L1 в TieredStore получают свои версии, поэтому
+	// их свежесть сравнивается по времени, и расхождение часов процессов здесь важно.
+	At time.Time
+}
+
+// SetWithToken записывает значение, как Set, и возвращает токен этой записи. Запись,
+// которую не принял WithAdmission, возвращает нулевой токен.
+func (s *Store) SetWithToken(key, value string, ttl time.Duration) (SessionToken, error) {
+	var tok SessionToken
+	if err := s.set(key, value, ttl, writeOpts{token: &tok}); err != nil {
+		return SessionToken{}, err
+	}
+	return tok, nil
+}
+
+// Epoch возвращает метку запуска хранилища - случайное число, новое в каждом New
+// (с WithDeterministic - 0). Версии записей начинаются с единицы в каждом хранилище,
+// и метка отличает их от версий того же узла до рестарта, см. SessionToken.Epoch.
+func (s *Store) Epoch() uint64 {
+	return s.epoch
+}
+
+// IsZero сообщает, нулевой ли токен.
+func (t SessionToken) IsZero() bool {
+	return t.Epoch == 0 && t.Version == 0 && t.At.IsZero()
+}
+
+// Merge возвращает токен, который покрывает записи обоих: сессия, писавшая несколько
+// ключей, должна видеть их все. Из токенов разных эпох берётся более поздний по At:
+// записи прошлой эпохи основной узел после рестарта мог и не сохранить.
+func (t SessionToken) Merge(other SessionToken) SessionToken {
+	if t.Epoch != other.Epoch && !t.IsZero() && !other.IsZero() {
+		if other.At.After(t.At) {
+			return other
+		}
+		return t
+	}
+	t.Epoch = max(t.Epoch, other.Epoch)
+	t.Version = max(t.Version, other.Version)
+	if other.At.After(t.At) {
+		t.At = other.At
+	}
+	return t
+}
+
+// String кодирует токен для передачи по сети, обратное - ParseSessionToken.
+func (t SessionToken) String() string {
+	var at int64
+	if !t.At.IsZero() {
+		at = t.At.UnixNano()
+	}
+	s := strconv.FormatUint(t.Version, 36) + "." + strconv.FormatInt(at, 36)
+	if t.Epoch != 0 {
+		s += "." + strconv.FormatUint(t.Epoch, 36)
+	}
+	return s
+}
+
+// ParseSessionToken разбирает токен из String, пустая строка - нулевой токен. Токен без
+// эпохи (до её появления) разбирается с нулевой Epoch.
+func ParseSessionToken(s string) (SessionToken, error) {
+	if s == "" {
+		return SessionToken{}, nil
+	}
+	v, at, ok := strings.Cut(s, ".")
+	if !ok {
+		return SessionToken{}, ErrInvalidSessionToken
+	}
+	var epoch uint64
+	at, e, ok := strings.Cut(at, ".")
+	if ok {
+		n, err := strconv.ParseUint(e, 36, 64)
+		if err != nil || n == 0 {
+			return SessionToken{}, ErrInvalidSessionToken
+		}
+		epoch = n
+	}
+	version, err := strconv.ParseUint(v, 36, 64)
+	if err != nil {
+		return SessionToken{}, ErrInvalidSessionToken
+	}
+	ns, err := strconv.ParseInt(at, 36, 64)
+	if err != nil || ns < 0 {
+		return SessionToken{}, ErrInvalidSessionToken
+	}
+	return SessionToken{Epoch: epoch, Version: version, At: unixTime(ns)}, nil
+}
@@ -0,0 +1,181 @@
+// Package sessionstore - хранилище сессий gorilla/sessions поверх store.Store.
+//
+// В cookie лежит только подписанный идентификатор сессии, значения хранятся в store.Store
+// с TTL простоя: каждый запрос с сессией продлевает её (скользящий TTL), а сессия,
+// к которой долго не обращались, истекает сама.
+package sessionstore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Option настраивает SessionStore.
+type Option func(*SessionStore)
+
+// WithPrefix задаёт префикс ключей сессий в хранилище, по умолчанию "session:".
+func WithPrefix(prefix string) Option {
+	return func(ss *SessionStore) {
+		ss.prefix = prefix
+	}
+}
+
+// WithIdleTimeout задаёт, через сколько без запросов сессия истекает, по умолчанию 30 минут.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(ss *SessionStore) {
+		ss.idle = d
+	}
+}
+
+// WithOptions задаёт параметры cookie, по умолчанию Path "/", HttpOnly и SameSite=Lax
+// без MaxAge: cookie живёт до закрытия браузера, а время жизни сессии задаёт WithIdleTimeout.
+func WithOptions(opts sessions.Options) Option {
+	return func(ss *SessionStore) {
+		ss.Options = &opts
+	}
+}
+
+// SessionStore реализует sessions.Store.
+type SessionStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options // параметры cookie новых сессий
+
+	s      *store.Store
+	prefix string
+	idle   time.Duration
+}
+
+var _ sessions.Store = (*SessionStore)(nil)
+
+// New создаёт хранилище сессий поверх s. keyPairs - ключи подписи и шифрования
+// идентификатора в cookie, как в sessions.NewCookieStore.
+func New(s *store.Store, keyPairs [][]byte, opts ...Option) *SessionStore {
+	ss := &SessionStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		s:      s,
+		prefix: "session:",
+		idle:   30 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(ss)
+	}
+	return ss
+}
+
+// Get возвращает сессию из реестра запроса, загружая её при первом обращении.
+func (ss *SessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(ss, name)
+}
+
+// New загружает сессию по cookie запроса или создаёт новую, если cookie нет,
+// подпись неверна или сессия истекла. Ошибка декодирования cookie возвращается
+// вместе с новой сессией, как в sessions.CookieStore.
+func (ss *SessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(ss, name)
+	opts := *ss.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, ss.Codecs...); err != nil {
+		return session, err
+	}
+	ok, err := ss.load(session)
+	if err != nil {
+		return session, err
+	}
+	session.IsNew = !ok
+	return session, nil
+}
+
+// Save сохраняет сессию и пишет cookie с её идентификатором. Options.MaxAge < 0
+// удаляет сессию из хранилища и cookie из браузера.
+func (ss *SessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			ss.s.Delete(ss.prefix + session.ID)
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	if err := ss.save(session); err != nil {
+		return err
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, ss.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// load читает значения сессии и продлевает её TTL. false - сессии нет или она истекла
+func (ss *SessionStore) load(session *sessions.Session) (bool, error) {
+	key := ss.prefix + session.ID
+	data, ok := ss.s.Get(key)
+	if !ok {
+		return false, nil
+	}
+	ss.s.Expire(key, ss.ttl(session))
+
+	if err := gob.NewDecoder(strings.NewReader(data)).Decode(&session.Values); err != nil {
+		return false, fmt.Errorf("sessionstore: decode session: %w", err)
+	}
+	return true, nil
+}
+
+func (ss *SessionStore) save(session *sessions.Session) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return fmt.Errorf("sessionstore: encode session: %w", err)
+	}
+	return ss.s.Set(ss.prefix+session.ID, buf.String(), ss.ttl(session))
+}
+
+// ttl - время жизни сессии в хранилище: простой, но не дольше MaxAge cookie
+func (ss *SessionStore) ttl(session *sessions.Session) time.Duration {
+	ttl := ss.idle
+	if maxAge := time.Duration(session.Options.MaxAge) * time.Second; maxAge > 0 && (ttl <= 0 || maxAge < ttl) {
+		ttl = maxAge
+	}
+	if ttl <= 0 {
+		return store.NoExpiration
+	}
+	return ttl
+}
+
+// newID - случайный идентификатор сессии, 160 бит в base32 без паддинга
+func newID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(b), "="), nil
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"maps"
+	"slices"
+)
+
+// SAdd добавляет элементы в множество key и возвращает, сколько из них не было.
+// Отсутствующий ключ создаётся с TTL по умолчанию (WithDefaultTTL), у существующего
+// TTL сохраняется. Если ключ хранит не множество, возвращается ErrWrongType.
+func (s *Store) SAdd(key string, members ...string) (int, error) {
+	added := 0
+	err := s.update(key, kindSet, func(next *Item) bool {
+		added = 0 // fn может вызываться повторно, см. update
+		set := make(map[string]struct{}, len(next.set)+len(members))
+		maps.Copy(set, next.set)
+		for _, m := range members {
+			if _, exists := set[m]; !exists {
+				set[m] = struct{}{}
+				added++
+			}
+		}
+		next.set = set
+		return added > 0
+	})
+	return added, err
+}
+
+// SRem удаляет элементы из множества и возвращает, сколько из них было.
+// Пустое множество удаляется целиком. Если ключ хранит не множество, возвращается ErrWrongType.
+func (s *Store) SRem(key string, members ...string) (int, error) {
+	removed := 0
+	err := s.update(key, kindSet, func(next *Item) bool {
+		set := maps.Clone(next.set)
+		for _, m := range members {
+			if _, exists := set[m]; exists {
+				delete(set, m)
+				removed++
+			}
+		}
+		next.set = set
+		return removed > 0
+	})
+	return removed, err
+}
+
+// SIsMember сообщает, есть ли member в множестве key. false и для отсутствующего,
+// истекшего ключа или ключа другого типа.
+func (s *Store) SIsMember(key, member string) bool {
+	item, ok := s.view(key, kindSet)
+	if !ok {
+		return false
+	}
+	_, ok = item.set[member]
+	return ok
+}
+
+// SMembers возвращает отсортированные элементы множества, nil - если ключа нет,
+// он истёк или хранит не множество.
+func (s *Store) SMembers(key string) []string {
+	item, ok := s.view(key, kindSet)
+	if !ok {
+		return nil
+	}
+	return item.members()
+}
+
+// SCard возвращает число элементов множества, 0 - если ключа нет,
+// он истёк или хранит не множество.
+func (s *Store) SCard(key string) int {
+	item, ok := s.view(key, kindSet)
+	if !ok {
+		return 0
+	}
+	return len(item.set)
+}
+
+// members - отсортированные элементы множества, порядок нужен для стабильного JSON и снапшота
+func (it *Item) members() []string {
+	if it.set == nil {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(it.set))
+}
@@ -0,0 +1,231 @@
+// Package shadow - декоратор store.Cache для миграций: записи идут в текущий кеш и в теневой,
+// чтения сравниваются, расхождения логируются. Так новый движок, Codec или удалённый бэкенд
+// проверяется на живом трафике до переключения.
+//
+//	c := shadow.New(current, candidate, shadow.WithLogger(slog.Default()))
+//	svc := NewService(c) // сервис зависит от store.Cache
+//
+// Вызывающий всегда получает результат основного кеша, теневой на него не влияет: его ошибки
+// и расхождения только считаются и логируются. Вызов ждёт оба кеша, так что задержка
+// теневого добавляется к каждому вызову; WithReadSampling сокращает число теневых чтений.
+package shadow
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Option настраивает Cache.
+type Option func(*Cache)
+
+// WithLogger задаёт логгер расхождений и ошибок теневого кеша, по умолчанию slog.Default().
+func WithLogger(l store.Logger) Option {
+	return func(c *Cache) { c.logger = l }
+}
+
+// WithReadSampling сравнивает только долю p (0..1) чтений, остальные в теневой кеш не идут.
+// Записи уходят в теневой кеш всегда, иначе он разойдётся с основным. По умолчанию 1.
+func WithReadSampling(p float64) Option {
+	return func(c *Cache) { c.sample = p }
+}
+
+// WithOnMismatch вызывает fn на каждое расхождение после записи в лог.
+// fn вызывается синхронно в вызове кеша и должен быть быстрым.
+func WithOnMismatch(fn func(Mismatch)) Option {
+	return func(c *Cache) { c.onMismatch = fn }
+}
+
+// Mismatch - расхождение ответов основного и теневого кеша.
+type Mismatch struct {
+	Method  string `json:"method"`
+	Key     string `json:"key"`
+	Primary string `json:"primary"`
+	Shadow  string `json:"shadow"`
+}
+
+// Stats - итоги сравнения.
+type Stats struct {
+	Compared   uint64 `json:"compared"`
+	Mismatches uint64 `json:"mismatches"`
+	Errors     uint64 `json:"errors"` // ошибки теневого кеша при успехе основного
+}
+
+// Cache - store.Cache, который дублирует вызовы в теневой кеш.
+type Cache struct {
+	primary, shadow store.Cache
+
+	logger     store.Logger
+	sample     float64
+	onMismatch func(Mismatch)
+
+	compared   atomic.Uint64
+	mismatches atomic.Uint64
+	errors     atomic.Uint64
+}
+
+var _ store.Cache = (*Cache)(nil)
+
+// New оборачивает primary, дублируя вызовы в shadow.
+func New(primary, shadow store.Cache, opts ...Option) *Cache {
+	c := &Cache{primary: primary, shadow: shadow, logger: slog.Default(), sample: 1}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Decorator - New для store.Decorate, shadow - теневой кеш.
+func Decorator(shadow store.Cache, opts ...Option) store.Decorator {
+	return func(primary store.Cache) store.Cache {
+		return New(primary, shadow, opts...)
+	}
+}
+
+// Unwrap возвращает основной Cache.
+func (c *Cache) Unwrap() store.Cache { return c.primary }
+
+// Shadow возвращает теневой Cache.
+func (c *Cache) Shadow() store.Cache { return c.shadow }
+
+// ShadowStats возвращает итоги сравнения. Stats - метод store.Cache.
+func (c *Cache) ShadowStats() Stats {
+	return Stats{
+		Compared:   c.compared.Load(),
+		Mismatches: c.mismatches.Load(),
+		Errors:     c.errors.Load(),
+	}
+}
+
+// sampled решает, сравнивать ли чтение
+func (c *Cache) sampled() bool {
+	return c.sample >= 1 || c.sample > 0 && rand.Float64() < c.sample
+}
+
+// compare сверяет ответы и учитывает расхождение
+func (c *Cache) compare(method, key, primary, shadow string) {
+	c.compared.Add(1)
+	if primary == shadow {
+		return
+	}
+	c.mismatches.Add(1)
+	c.logger.Info("shadow: mismatch", "method", method, "key", key, "primary", primary, "shadow", shadow)
+	if c.onMismatch != nil {
+		c.onMismatch(Mismatch{Method: method, Key: key, Primary: primary, Shadow: shadow})
+	}
+}
+
+// shadowErr учитывает ошибку теневого кеша, если основной справился
+func (c *Cache) shadowErr(method, key string, primary, shadow error) {
+	if primary != nil || shadow == nil {
+		return
+	}
+	c.errors.Add(1)
+	c.logger.Error("shadow: shadow cache failed", "method", method, "key", key, "err", shadow)
+}
+
+// found - значение Get для сравнения, промах отличается от пустой строки
+func found(v string, ok bool) string {
+	if !ok {
+		return "<miss>"
+	}
+	return strconv.Quote(v)
+}
+
+// Get читает основной кеш, выборочно сравнивая ответ с теневым.
+func (c *Cache) Get(key string) (string, bool) {
+	v, ok := c.primary.Get(key)
+	if c.sampled() {
+		sv, sok := c.shadow.Get(key)
+		c.compare("Get", key, found(v, ok), found(sv, sok))
+	}
+	return v, ok
+}
+
+// Set записывает в оба кеша, возвращает ошибку основного.
+func (c *Cache) Set(key, value string, ttl time.Duration) error {
+	err := c.primary.Set(key, value, ttl)
+	if err == nil {
+		c.shadowErr("Set", key, err, c.shadow.Set(key, value, ttl))
+	}
+	return err
+}
+
+// Delete удаляет ключ из обоих кешей.
+func (c *Cache) Delete(key string) {
+	c.primary.Delete(key)
+	c.shadow.Delete(key)
+}
+
+// SetNX записывает в оба кеша и сравнивает, удалась ли запись.
+func (c *Cache) SetNX(key, value string, ttl time.Duration) bool {
+	ok := c.primary.SetNX(key, value, ttl)
+	c.compare("SetNX", key, strconv.FormatBool(ok), strconv.FormatBool(c.shadow.SetNX(key, value, ttl)))
+	return ok
+}
+
+// SetXX записывает в оба кеша и сравнивает, удалась ли запись.
+func (c *Cache) SetXX(key, value string, ttl time.Duration) bool {
+	ok := c.primary.SetXX(key, value, ttl)
+	c.compare("SetXX", key, strconv.FormatBool(ok), strconv.FormatBool(c.shadow.SetXX(key, value, ttl)))
+	return ok
+}
+
+// CompareAndDelete удаляет из обоих кешей и сравнивает, удалось ли удаление.
+func (c *Cache) CompareAndDelete(key, value string) bool {
+	ok := c.primary.CompareAndDelete(key, value)
+	c.compare("CompareAndDelete", key, strconv.FormatBool(ok), strconv.FormatBool(c.shadow.CompareAndDelete(key, value)))
+	return ok
+}
+
+// IncrBy увеличивает счётчик в обоих кешах и сравнивает результаты.
+func (c *Cache) IncrBy(key string, delta int64) (int64, error) {
+	n, err := c.primary.IncrBy(key, delta)
+	if err != nil {
+		return n, err
+	}
+	sn, serr := c.shadow.IncrBy(key, delta)
+	if serr != nil {
+		c.shadowErr("IncrBy", key, err, serr)
+		return n, err
+	}
+	c.compare("IncrBy", key, strconv.FormatInt(n, 10), strconv.FormatInt(sn, 10))
+	return n, err
+}
+
+// TTL читает основной кеш, выборочно сравнивая с теневым только наличие ключа:
+// остаток времени жизни в двух кешах расходится на время между вызовами.
+func (c *Cache) TTL(key string) (time.Duration, bool) {
+	ttl, ok := c.primary.TTL(key)
+	if c.sampled() {
+		_, sok := c.shadow.TTL(key)
+		c.compare("TTL", key, strconv.FormatBool(ok), strconv.FormatBool(sok))
+	}
+	return ttl, ok
+}
+
+// Expire меняет TTL в обоих кешах и сравнивает, нашёлся ли ключ.
+func (c *Cache) Expire(key string, ttl time.Duration) bool {
+	ok := c.primary.Expire(key, ttl)
+	c.compare("Expire", key, strconv.FormatBool(ok), strconv.FormatBool(c.shadow.Expire(key, ttl)))
+	return ok
+}
+
+// Keys - Keys основного кеша, теневой не опрашивается: полный обход дорог.
+func (c *Cache) Keys(pattern string) []string {
+	return c.primary.Keys(pattern)
+}
+
+// Size - Size основного кеша.
+func (c *Cache) Size() int {
+	return c.primary.Size()
+}
+
+// Stats - Stats основного кеша, итоги сравнения - ShadowStats.
+func (c *Cache) Stats() store.Stats {
+	return c.primary.Stats()
+}
@@ -0,0 +1,41 @@
+// Package shmstore - экспериментальное хранилище в разделяемой памяти, которое могут
+// одновременно использовать несколько процессов на одном хосте (например pre-fork воркеры)
+// без сетевого хопа.
+//
+// Сегмент - это файл (обычно в /dev/shm), отображенный в память через mmap с MAP_SHARED.
+// Внутри заголовок и массив слотов фиксированного размера; индекс - открытая адресация
+// по хешу ключа, слот находится по смещению от начала сегмента. Ключи и значения
+// ограничены размерами, заданными при создании сегмента.
+//
+// Между процессами данные защищены спинлоком в заголовке сегмента, в котором записан pid
+// владельца. Если процесс упадёт, держа блокировку, её заберёт первый процесс, который
+// увидит, что владельца нет (kill(pid, 0) - ESRCH). Слот, который упавший процесс писал,
+// может остаться записанным наполовину, так что режим экспериментальный: после SIGKILL
+// посреди операции ключ может вернуть мусор. Все процессы сегмента должны видеть друг
+// друга в одном пространстве pid, иначе живой владелец из другого контейнера сочтётся
+// умершим.
+//
+// Поддерживается только linux, на остальных платформах Open возвращает ErrUnsupported.
+package shmstore
+
+import "errors"
+
+var (
+	// ErrUnsupported - платформа не поддерживает разделяемый сегмент.
+	ErrUnsupported = errors.New("shmstore: shared memory mode is not supported on this platform")
+	// ErrTooLarge - ключ или значение не помещается в слот.
+	ErrTooLarge = errors.New("shmstore: key or value exceeds slot size")
+	// ErrFull - в сегменте не осталось свободных слотов.
+	ErrFull = errors.New("shmstore: segment is full")
+	// ErrLayoutMismatch - существующий сегмент создан с другими параметрами.
+	ErrLayoutMismatch = errors.New("shmstore: segment layout does not match options")
+	// ErrClosed - Set после Close.
+	ErrClosed = errors.New("shmstore: store is closed")
+)
+
+// Options - параметры сегмента. Все процессы, открывающие один сегмент, должны передавать одинаковые значения.
+type Options struct {
+	Slots        int // количество слотов, т.е. максимум ключей
+	MaxKeySize   int // максимальная длина ключа в байтах
+	MaxValueSize int // максимальная длина значения в байтах
+}
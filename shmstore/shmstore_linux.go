@@ -0,0 +1,330 @@
+//go:build linux
+
+package shmstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	magic   = 0x73686d73746f7265 // "shmstore"
+	version = 2                  // 2: в слове блокировки pid владельца, а не 1
+
+	headerSize = 64
+
+	// смещения полей заголовка
+	offMagic   = 0
+	offVersion = 8
+	offSlots   = 12
+	offKeyMax  = 16
+	offValMax  = 20
+	offLock    = 24 // pid процесса, держащего спинлок, 0 - свободен
+	offCount   = 28
+
+	// смещения полей слота
+	slotState     = 0
+	slotKeyLen    = 4
+	slotValLen    = 8
+	slotExpiresAt = 16
+	slotHash      = 24
+	slotData      = 32
+
+	stateEmpty   = 0
+	stateUsed    = 1
+	stateDeleted = 2 // надгробие: слот свободен, но цепочка поиска через него продолжается
+)
+
+var le = binary.LittleEndian
+
+// Store - хранилище поверх разделяемого сегмента. Безопасно для горутин и процессов.
+type Store struct {
+	// mu защищает сегмент от Close: спинлок живёт в самом сегменте и после Munmap недоступен.
+	// Операции держат mu на чтение вместе со спинлоком, Close - на запись
+	mu     sync.RWMutex
+	closed bool
+
+	mem      []byte
+	lockWord *uint32
+
+	slots    int
+	keyMax   int
+	valMax   int
+	slotSize int
+}
+
+// Open открывает сегмент по пути path, создавая его при первом открытии.
+// Если сегмент уже существует, его параметры должны совпадать с opts.
+func Open(path string, opts Options) (*Store, error) {
+	if opts.Slots <= 0 || opts.MaxKeySize <= 0 || opts.MaxValueSize < 0 {
+		return nil, fmt.Errorf("shmstore: invalid options %+v", opts)
+	}
+
+	slotSize := align8(slotData + opts.MaxKeySize + opts.MaxValueSize)
+	total := headerSize + opts.Slots*slotSize
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("shmstore: open segment: %w", err)
+	}
+	defer f.Close() // после mmap дескриптор не нужен
+
+	// flock сериализует инициализацию сегмента между процессами, стартующими одновременно
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return nil, fmt.Errorf("shmstore: lock segment: %w", err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("shmstore: stat segment: %w", err)
+	}
+
+	fresh := fi.Size() == 0
+	if fresh {
+		if err := f.Truncate(int64(total)); err != nil {
+			return nil, fmt.Errorf("shmstore: resize segment: %w", err)
+		}
+	} else if fi.Size() != int64(total) {
+		return nil, ErrLayoutMismatch
+	}
+
+	mem, err := syscall.Mmap(int(f.Fd()), 0, total, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("shmstore: mmap segment: %w", err)
+	}
+
+	s := &Store{
+		mem:      mem,
+		lockWord: (*uint32)(unsafe.Pointer(&mem[offLock])),
+		slots:    opts.Slots,
+		keyMax:   opts.MaxKeySize,
+		valMax:   opts.MaxValueSize,
+		slotSize: slotSize,
+	}
+
+	if fresh {
+		le.PutUint32(mem[offVersion:], version)
+		le.PutUint32(mem[offSlots:], uint32(opts.Slots))
+		le.PutUint32(mem[offKeyMax:], uint32(opts.MaxKeySize))
+		le.PutUint32(mem[offValMax:], uint32(opts.MaxValueSize))
+		le.PutUint64(mem[offMagic:], magic) // магию пишем последней - признак готового заголовка
+		return s, nil
+	}
+
+	if le.Uint64(mem[offMagic:]) != magic || le.Uint32(mem[offVersion:]) != version ||
+		int(le.Uint32(mem[offSlots:])) != opts.Slots ||
+		int(le.Uint32(mem[offKeyMax:])) != opts.MaxKeySize ||
+		int(le.Uint32(mem[offValMax:])) != opts.MaxValueSize {
+		syscall.Munmap(mem)
+		return nil, ErrLayoutMismatch
+	}
+	return s, nil
+}
+
+// Close отключает сегмент от процесса. Данные остаются в файле для других процессов.
+// После Close Get и Len возвращают пустой результат, Set - ErrClosed, Delete ничего не делает.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := syscall.Munmap(s.mem)
+	s.mem, s.lockWord = nil, nil
+	return err
+}
+
+// Get возвращает значение ключа, если он существует и не истёк.
+func (s *Store) Get(key string) (string, bool) {
+	if !s.lock() {
+		return "", false
+	}
+	defer s.unlock()
+
+	slot, found := s.find(key, hashKey(key))
+	if !found {
+		return "", false
+	}
+	if s.expired(slot, time.Now()) {
+		s.free(slot)
+		return "", false
+	}
+
+	off := slotData + s.keyMax
+	return string(slot[off : off+int(le.Uint32(slot[slotValLen:]))]), true
+}
+
+// Set сохраняет значение с TTL, ttl <= 0 - без срока истечения.
+func (s *Store) Set(key, value string, ttl time.Duration) error {
+	if len(key) > s.keyMax || len(value) > s.valMax {
+		return ErrTooLarge
+	}
+
+	now := time.Now()
+	var expires int64
+	if ttl > 0 {
+		expires = now.Add(ttl).UnixNano()
+	}
+
+	h := hashKey(key)
+
+	if !s.lock() {
+		return ErrClosed
+	}
+	defer s.unlock()
+
+	slot, found := s.find(key, h)
+	if !found {
+		slot = s.freeSlot(h, now)
+		if slot == nil {
+			return ErrFull
+		}
+		le.PutUint32(s.mem[offCount:], le.Uint32(s.mem[offCount:])+1)
+	}
+
+	le.PutUint32(slot[slotState:], stateUsed)
+	le.PutUint32(slot[slotKeyLen:], uint32(len(key)))
+	le.PutUint32(slot[slotValLen:], uint32(len(value)))
+	le.PutUint64(slot[slotExpiresAt:], uint64(expires))
+	le.PutUint64(slot[slotHash:], h)
+	copy(slot[slotData:], key)
+	copy(slot[slotData+s.keyMax:], value)
+
+	return nil
+}
+
+// Delete удаляет ключ.
+func (s *Store) Delete(key string) {
+	if !s.lock() {
+		return
+	}
+	defer s.unlock()
+
+	if slot, found := s.find(key, hashKey(key)); found {
+		s.free(slot)
+	}
+}
+
+// Len возвращает количество занятых слотов, включая истекшие, но еще не освобожденные.
+func (s *Store) Len() int {
+	if !s.lock() {
+		return 0
+	}
+	defer s.unlock()
+
+	return int(le.Uint32(s.mem[offCount:]))
+}
+
+// slot возвращает слот по индексу, смещение = заголовок + i*размер слота
+func (s *Store) slot(i int) []byte {
+	off := headerSize + i*s.slotSize
+	return s.mem[off : off+s.slotSize]
+}
+
+// find ищет занятый слот с ключом key, линейно пробируя от позиции хеша
+func (s *Store) find(key string, h uint64) ([]byte, bool) {
+	start := int(h % uint64(s.slots))
+	for n := 0; n < s.slots; n++ {
+		slot := s.slot((start + n) % s.slots)
+		switch le.Uint32(slot[slotState:]) {
+		case stateEmpty:
+			return nil, false
+		case stateUsed:
+			if le.Uint64(slot[slotHash:]) != h {
+				continue
+			}
+			keyLen := int(le.Uint32(slot[slotKeyLen:]))
+			if bytes.Equal(slot[slotData:slotData+keyLen], []byte(key)) {
+				return slot, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// freeSlot находит слот для нового ключа: первое надгробие или истекший элемент в цепочке,
+// иначе первый пустой слот
+func (s *Store) freeSlot(h uint64, now time.Time) []byte {
+	start := int(h % uint64(s.slots))
+	for n := 0; n < s.slots; n++ {
+		slot := s.slot((start + n) % s.slots)
+		switch le.Uint32(slot[slotState:]) {
+		case stateEmpty, stateDeleted:
+			return slot
+		case stateUsed:
+			if s.expired(slot, now) {
+				s.free(slot)
+				return slot
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) expired(slot []byte, now time.Time) bool {
+	expires := int64(le.Uint64(slot[slotExpiresAt:]))
+	return expires != 0 && now.UnixNano() > expires
+}
+
+// free помечает слот надгробием
+func (s *Store) free(slot []byte) {
+	le.PutUint32(slot[slotState:], stateDeleted)
+	le.PutUint32(s.mem[offCount:], le.Uint32(s.mem[offCount:])-1)
+}
+
+// lock захватывает межпроцессный спинлок в заголовке сегмента, записывая в него свой pid.
+// Блокировку, владелец которой умер, забирает себе. false - хранилище закрыто,
+// ничего не захвачено
+func (s *Store) lock() bool {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return false
+	}
+	pid := uint32(os.Getpid())
+	for spins := 0; ; spins++ {
+		if atomic.CompareAndSwapUint32(s.lockWord, 0, pid) {
+			return true
+		}
+		if spins < 64 {
+			runtime.Gosched()
+			continue
+		}
+		owner := atomic.LoadUint32(s.lockWord)
+		if owner != 0 && owner != pid && !alive(owner) && atomic.CompareAndSwapUint32(s.lockWord, owner, pid) {
+			return true
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+// alive сообщает, есть ли процесс pid. EPERM - процесс есть, но чужой
+func alive(pid uint32) bool {
+	return syscall.Kill(int(pid), 0) != syscall.ESRCH
+}
+
+func (s *Store) unlock() {
+	atomic.StoreUint32(s.lockWord, 0)
+	s.mu.RUnlock()
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
@@ -0,0 +1,41 @@
+package shmstore
+
+import (
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockOfDeadOwnerIsReclaimed(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "segment"), Options{Slots: 16, MaxKeySize: 16, MaxValueSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// процесс, который завершился, держа блокировку
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+	atomic.StoreUint32(s.lockWord, uint32(cmd.Process.Pid))
+
+	done := make(chan error, 1)
+	go func() { done <- s.Set("k", "v", 0) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Set hangs on a lock of a dead process")
+	}
+	if v, ok := s.Get("k"); !ok || v != "v" {
+		t.Errorf("Get = %q, %v", v, ok)
+	}
+	if owner := atomic.LoadUint32(s.lockWord); owner != 0 {
+		t.Errorf("lock word = %d after unlock, want 0", owner)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package shmstore
+
+import "time"
+
+// Store - хранилище в разделяемой памяти, на этой платформе недоступно.
+type Store struct{}
+
+// Open всегда возвращает ErrUnsupported.
+func Open(path string, opts Options) (*Store, error) {
+	return nil, ErrUnsupported
+}
+
+func (s *Store) Get(key string) (string, bool)                  { return "", false }
+func (s *Store) Set(key, value string, ttl time.Duration) error { return ErrUnsupported }
+func (s *Store) Delete(key string)                              {}
+func (s *Store) Len() int                                       { return 0 }
+func (s *Store) Close() error                                   { return nil }
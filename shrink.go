@@ -0,0 +1,76 @@
+package store
+
+// MemoryStats - разбивка примерного объёма хранилища, см. MemoryStats.
+type MemoryStats struct {
+	Entries       int   `json:"entries"`
+	KeyBytes      int64 `json:"keyBytes"`      // ключи хранения, с префиксом WithKeyVersion
+	ValueBytes    int64 `json:"valueBytes"`    // значения и содержимое коллекций, сжатые - в сжатом виде
+	OverheadBytes int64 `json:"overheadBytes"` // служебные данные элементов по оценке хранилища
+	TotalBytes    int64 `json:"totalBytes"`    // сумма, то же, что Stats.MemoryBytes
+
+	CompressionSaved int64 `json:"compressionSaved,omitempty"` // см. WithCompression
+	InternSaved      int64 `json:"internSaved,omitempty"`      // см. WithValueInterning
+
+	// PeakEntries - наибольшее число ключей с создания или последнего Shrink. Мапы в Go
+	// не уменьшаются, так что память под таблицу соответствует пику, а не Entries.
+	PeakEntries int `json:"peakEntries"`
+}
+
+// MemoryStats считает объём по ключам, значениям и служебным данным, обходя все элементы
+// под RLock, поэтому на больших хранилищах дороже Stats.
+func (s *Store) MemoryStats() MemoryStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ms := MemoryStats{
+		Entries:          len(s.data),
+		TotalBytes:       s.memUsed,
+		CompressionSaved: s.compressionSaved,
+		PeakEntries:      s.peakEntries,
+	}
+	_, ms.InternSaved = s.internStatsLocked()
+	for key, item := range s.data {
+		ms.KeyBytes += int64(len(key))
+		ms.ValueBytes += int64(item.payloadSize())
+	}
+	ms.OverheadBytes = int64(len(s.data)) * itemOverhead
+	return ms
+}
+
+// Shrink пересоздаёт внутренние мапы (данные, история, закрепления, пространства имён)
+// по текущему числу ключей. Мапы в Go не отдают память после удалений, поэтому после
+// массового удаления, например ежесуточного истечения большей части ключей, таблица
+// остаётся размером с пик; после Shrink старая таблица уходит сборщику мусора.
+// Reset и ReplaceAll и так создают новые мапы.
+// Вызов держит блокировку на запись на время копирования всех ключей.
+//
+// sync.Map движка EngineSyncMap не пересоздаётся, она освобождает память сама.
+// Вернуть освободившиеся страницы ОС сразу можно через debug.FreeOSMemory.
+func (s *Store) Shrink() {
+	s.mu.Lock()
+	before := s.peakEntries
+	s.data = rebuilt(s.data)
+	if s.history != nil {
+		s.history = rebuilt(s.history)
+	}
+	if s.pins != nil {
+		s.pins = rebuilt(s.pins)
+	}
+	for _, ns := range s.namespaces {
+		ns.members = rebuilt(ns.members)
+	}
+	s.peakEntries = len(s.data)
+	after := len(s.data)
+	s.mu.Unlock()
+	s.cfg.logger.Info("store: maps shrunk", "keys", after, "peak", before)
+}
+
+// rebuilt копирует мапу в новую по её текущему размеру: maps.Clone сохраняет размер
+// таблицы исходной мапы и для Shrink не подходит
+func rebuilt[M ~map[K]V, K comparable, V any](m M) M {
+	res := make(M, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// flightCall - вызов Do в процессе, done закрывается после записи результата
+type flightCall struct {
+	done   chan struct{}
+	value  any
+	err    error
+	shared bool // результат ждал кто-то кроме первого вызывающего, под flights.mu
+}
+
+// flights - вызовы Do в процессе по ключам
+type flights struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// Do выполняет fn один раз на всплеск одновременных вызовов с одним key: пока fn первого
+// вызывающего работает, остальные ждут и получают тот же результат. Подходит для любых
+// дорогих операций, привязанных к ключу кеша (запрос в удалённый сервис, пересчёт агрегата),
+// когда нужна не запись в хранилище, как у LoadingStore, а только схлопывание запросов.
+// Результат не кешируется: следующий вызов после завершения fn вызовет её снова.
+//
+// shared сообщает, что результат получили несколько вызывающих. fn получает контекст первого
+// вызывающего без отмены: если тот ушёл по таймауту, остальные всё равно получат результат.
+// Каждый вызывающий ждёт не дольше своего ctx. Паника fn возвращается ошибкой.
+// Пространство ключей Do отдельное от ключей хранилища и WithKeyVersion не учитывает.
+func (s *Store) Do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (v any, shared bool, err error) {
+	f := &s.flights
+	f.mu.Lock()
+	c, ok := f.calls[key]
+	if ok {
+		c.shared = true
+	} else {
+		if f.calls == nil {
+			f.calls = make(map[string]*flightCall)
+		}
+		c = &flightCall{done: make(chan struct{})}
+		f.calls[key] = c
+		if !s.cfg.deterministic {
+			go s.runFlight(context.WithoutCancel(ctx), key, c, fn)
+		}
+	}
+	f.mu.Unlock()
+	if !ok && s.cfg.deterministic {
+		s.runFlight(context.WithoutCancel(ctx), key, c, fn) // без горутины, см. WithDeterministic
+	}
+
+	select {
+	case <-c.done:
+		return c.value, c.shared, c.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// runFlight вызывает fn и раздаёт результат ожидающим
+func (s *Store) runFlight(ctx context.Context, key string, c *flightCall, fn func(context.Context) (any, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("store: do %q panicked: %v", key, r)
+		}
+		s.flights.mu.Lock()
+		delete(s.flights.calls, key)
+		s.flights.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = fn(ctx)
+}
@@ -0,0 +1,443 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mutation - изменение для внешнего приёмника: Type - EventSet, EventDelete или EventFlush
+// (очистка всего хранилища, Key пуст), Key - пользовательский ключ, ExpiresAt - срок
+// значения (нулевой - без истечения). У коллекций Kind - их тип, а Value - содержимое
+// в том же виде, что отдаёт Get, по ним ApplyMutation восстанавливает коллекцию.
+type Mutation struct {
+	Type      EventType `json:"type"`
+	Key       string    `json:"key"`
+	Kind      string    `json:"kind,omitempty"` // тип значения EventSet: пусто - строка, иначе hash, list, set, zset, counter, hyperloglog
+	Value     string    `json:"value,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+	At        time.Time `json:"at"`
+	Version   uint64    `json:"version,omitempty"` // Item.Version записи для EventSet, см. SessionToken
+
+	Attrs map[string]string `json:"attrs,omitempty"` // атрибуты запроса, сделавшего изменение, см. WithAttrs
+}
+
+// Sink - внешний приёмник изменений (база, очередь сообщений), см. WithSink.
+// Write получает пачку в порядке изменений и должен быть идемпотентным: после ошибки
+// та же пачка отправляется повторно.
+type Sink interface {
+	Write(ctx context.Context, batch []Mutation) error
+}
+
+// SinkFunc - функция как Sink.
+type SinkFunc func(ctx context.Context, batch []Mutation) error
+
+// Write вызывает f.
+func (f SinkFunc) Write(ctx context.Context, batch []Mutation) error {
+	return f(ctx, batch)
+}
+
+// SinkOptions - параметры очереди отложенной записи. Нулевые поля получают значения по умолчанию.
+type SinkOptions struct {
+	QueueSize     int           // максимум изменений в очереди, по умолчанию 10000
+	BatchSize     int           // максимум изменений в одном Write, по умолчанию 100
+	FlushInterval time.Duration // как долго копить неполную пачку, по умолчанию 100ms
+	MaxRetries    int           // повторов Write после ошибки, по умолчанию 3
+	RetryBackoff  time.Duration // пауза перед первым повтором, дальше удваивается, по умолчанию 100ms
+
+	// DropWhenFull - при заполненной очереди отбрасывать изменение, а не ждать места.
+	// По умолчанию запись в хранилище ждёт, пока приёмник разгребёт очередь.
+	DropWhenFull bool
+	// OnError вызывается с пачкой, которую не удалось записать после всех повторов.
+	OnError func(batch []Mutation, err error)
+}
+
+// WithSink превращает хранилище в буфер отложенной записи перед медленным хранилищем:
+// Set, SetNX, SetXX, SetWithProvenance, Expire, IncrBy, Counter.Add, записи коллекций, Delete
+// и CompareAndDelete складывают изменения в ограниченную очередь, а фоновая горутина пишет
+// их в sink пачками с повторами.
+//
+// Массовые изменения тоже попадают в приёмник: Reset и ResetAll - одним EventFlush,
+// ResetMatching, Namespace.Reset, ExpireGroup, каскадные удаления групп и зависимостей -
+// удалением каждого ключа, ReplaceAll, LoadSnapshot, CopyFromSnapshot и MigrateKeys -
+// записью и удалением затронутых ключей. Им не ждать места в очереди под блокировкой
+// хранилища, поэтому на заполненной очереди они встают сверх QueueSize (с DropWhenFull -
+// отбрасываются). Истечение TTL и вытеснение в приёмник не попадают - это жизненный цикл
+// кеша, а не изменение данных.
+//
+// Перед остановкой процесса очередь нужно дописать через CloseSink.
+func WithSink(sink Sink, opts SinkOptions) Option {
+	return func(c *config) {
+		c.sink = sink
+		c.sinkOpts = opts
+	}
+}
+
+// sinkQueue - очередь изменений. Место резервируется через slots до блокировки хранилища,
+// а само изменение добавляется под s.mu - так порядок в очереди совпадает с порядком
+// изменений, а ожидание места не держит блокировку хранилища
+type sinkQueue struct {
+	sink  Sink
+	opts  SinkOptions
+	clock Clock
+
+	slots chan struct{}
+
+	mu       sync.Mutex
+	items    []sinkEntry
+	inflight int           // изменений в пачке, которая сейчас пишется
+	slotted  int           // из них с местом в slots
+	drained  chan struct{} // закрывается, когда очередь опустела
+	closed   bool
+
+	notify  chan struct{}
+	stopped chan struct{}
+
+	written atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// sinkEntry - изменение в очереди, slot - под него занято место в slots
+type sinkEntry struct {
+	m    Mutation
+	slot bool
+}
+
+func newSinkQueue(sink Sink, opts SinkOptions, clock Clock) *sinkQueue {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 100 * time.Millisecond
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 100 * time.Millisecond
+	}
+	return &sinkQueue{
+		sink:    sink,
+		opts:    opts,
+		clock:   clock,
+		slots:   make(chan struct{}, opts.QueueSize),
+		drained: make(chan struct{}),
+		notify:  make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+	}
+}
+
+// reserve занимает место в очереди до записи в хранилище. false - место не получено
+// и изменение в приёмник не попадёт
+func (q *sinkQueue) reserve() bool {
+	select {
+	case <-q.stopped:
+		q.dropped.Add(1)
+		return false
+	default:
+	}
+	if q.opts.DropWhenFull {
+		select {
+		case q.slots <- struct{}{}:
+			return true
+		default:
+			q.dropped.Add(1)
+			return false
+		}
+	}
+	select {
+	case q.slots <- struct{}{}:
+		return true
+	case <-q.stopped:
+		q.dropped.Add(1)
+		return false
+	}
+}
+
+// release возвращает место, если запись в хранилище не состоялась
+func (q *sinkQueue) release() {
+	<-q.slots
+}
+
+// appendLocked добавляет изменение в зарезервированное место, вызывается под s.mu
+func (q *sinkQueue) appendLocked(m Mutation) {
+	q.push(sinkEntry{m: m, slot: true})
+}
+
+// appendUnreservedLocked добавляет изменение без резерва - для удалений, которые хранилище
+// делает само под s.mu и где ждать места нельзя. Без свободного места изменение встаёт
+// сверх QueueSize, а с DropWhenFull отбрасывается
+func (q *sinkQueue) appendUnreservedLocked(m Mutation) {
+	select {
+	case <-q.stopped:
+		q.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case q.slots <- struct{}{}:
+		q.push(sinkEntry{m: m, slot: true})
+	default:
+		if q.opts.DropWhenFull {
+			q.dropped.Add(1)
+			return
+		}
+		q.push(sinkEntry{m: m})
+	}
+}
+
+func (q *sinkQueue) push(e sinkEntry) {
+	q.mu.Lock()
+	q.items = append(q.items, e)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next ждёт полную пачку или FlushInterval с непустой очередью. false - очередь закрыта и пуста
+func (q *sinkQueue) next() ([]Mutation, bool) {
+	var timer Ticker
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		q.mu.Lock()
+		n := len(q.items)
+		if n >= q.opts.BatchSize || n > 0 && q.closed {
+			return q.takeLocked(), true
+		}
+		if n == 0 && q.closed {
+			q.mu.Unlock()
+			return nil, false
+		}
+		q.mu.Unlock()
+
+		if n > 0 && timer == nil {
+			timer = q.clock.NewTicker(q.opts.FlushInterval)
+		}
+		var tick <-chan time.Time
+		if timer != nil {
+			tick = timer.C()
+		}
+
+		select {
+		case <-q.notify:
+		case <-tick:
+			q.mu.Lock()
+			if len(q.items) > 0 {
+				return q.takeLocked(), true
+			}
+			q.mu.Unlock()
+			timer.Stop()
+			timer = nil
+		}
+	}
+}
+
+// takeLocked забирает пачку из головы очереди и отпускает q.mu
+func (q *sinkQueue) takeLocked() []Mutation {
+	n := min(len(q.items), q.opts.BatchSize)
+	batch := make([]Mutation, n)
+	q.slotted = 0
+	for i, e := range q.items[:n] {
+		batch[i] = e.m
+		if e.slot {
+			q.slotted++
+		}
+	}
+	q.items = q.items[n:]
+	q.inflight = n
+	q.mu.Unlock()
+	return batch
+}
+
+// done отмечает пачку записанной и освобождает её места
+func (q *sinkQueue) done() {
+	q.mu.Lock()
+	n := q.slotted
+	q.mu.Unlock()
+	for i := 0; i < n; i++ {
+		<-q.slots
+	}
+
+	q.mu.Lock()
+	q.inflight, q.slotted = 0, 0
+	if len(q.items) == 0 {
+		close(q.drained)
+		q.drained = make(chan struct{})
+	}
+	q.mu.Unlock()
+}
+
+// runSink пишет очередь в приёмник, пока её не закроют
+func (s *Store) runSink() {
+	q := s.sink
+	defer close(q.stopped)
+
+	for {
+		batch, ok := q.next()
+		if !ok {
+			return
+		}
+		s.writeSink(batch)
+		q.done()
+	}
+}
+
+// writeSink пишет пачку с повторами и экспоненциальной паузой
+func (s *Store) writeSink(batch []Mutation) {
+	q := s.sink
+	backoff := q.opts.RetryBackoff
+
+	var err error
+	for attempt := 0; attempt <= q.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = q.sink.Write(context.Background(), batch); err == nil {
+			q.written.Add(uint64(len(batch)))
+			return
+		}
+		s.cfg.logger.Debug("store: sink write failed", "attempt", attempt+1, "batch", len(batch), "err", err)
+	}
+
+	q.failed.Add(uint64(len(batch)))
+	s.cfg.logger.Error("store: sink batch dropped after retries", "batch", len(batch), "err", err)
+	if q.opts.OnError != nil {
+		q.opts.OnError(batch, err)
+	}
+}
+
+// FlushSink ждёт, пока все изменения, попавшие в очередь до вызова, будут записаны в приёмник.
+func (s *Store) FlushSink(ctx context.Context) error {
+	q := s.sink
+	if q == nil {
+		return nil
+	}
+
+	for {
+		q.mu.Lock()
+		if len(q.items) == 0 && q.inflight == 0 {
+			q.mu.Unlock()
+			return nil
+		}
+		drained := q.drained
+		q.mu.Unlock()
+
+		select {
+		case <-drained:
+		case <-q.stopped:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// CloseSink дописывает очередь в приёмник и останавливает фоновую запись.
+// Изменения после CloseSink в приёмник не попадают и считаются отброшенными.
+func (s *Store) CloseSink(ctx context.Context) error {
+	q := s.sink
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-q.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SinkStats - счетчики очереди отложенной записи.
+type SinkStats struct {
+	Queued  int    `json:"queued"`  // изменений ждут записи, включая пишущуюся пачку
+	Written uint64 `json:"written"` // записано в приёмник
+	Failed  uint64 `json:"failed"`  // не записано после всех повторов
+	Dropped uint64 `json:"dropped"` // отброшено без записи: очередь полна (DropWhenFull) или закрыта
+}
+
+// queued - изменений в очереди и в пишущейся пачке
+func (q *sinkQueue) queued() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items) + q.inflight
+}
+
+func (s *Store) sinkStats() *SinkStats {
+	if s.sink == nil {
+		return nil
+	}
+	return &SinkStats{
+		Queued:  s.sink.queued(),
+		Written: s.sink.written.Load(),
+		Failed:  s.sink.failed.Load(),
+		Dropped: s.sink.dropped.Load(),
+	}
+}
+
+// sinkReserve резервирует место под изменение перед записью в хранилище
+func (s *Store) sinkReserve() bool {
+	return s.sink != nil && s.sink.reserve()
+}
+
+// sinkRelease возвращает место, если запись в хранилище не состоялась
+func (s *Store) sinkRelease(queued bool) {
+	if queued {
+		s.sink.release()
+	}
+}
+
+// sinkAppendLocked кладет изменение в зарезервированное место, вызывается под s.mu
+func (s *Store) sinkAppendLocked(typ EventType, raw string, it *Item, now time.Time) {
+	s.sink.appendLocked(s.mutation(typ, raw, it, now))
+}
+
+// mutation - изменение ключа raw для приёмника, it - записанный элемент или nil
+func (s *Store) mutation(typ EventType, raw string, it *Item, now time.Time) Mutation {
+	key, _ := s.userKey(raw)
+	m := Mutation{Type: typ, Key: key, At: now}
+	if it != nil {
+		m.Value, m.ExpiresAt, m.Attrs, m.Version = it.text(), it.ExpiresAt, it.attrs, it.Version
+		if it.kind != kindString {
+			m.Kind = it.kind.String()
+		}
+	}
+	return m
+}
+
+// sinkDeleteLocked - sinkAppendLocked для удаления запросом с атрибутами attrs
+func (s *Store) sinkDeleteLocked(raw string, attrs map[string]string, now time.Time) {
+	key, _ := s.userKey(raw)
+	s.sink.appendLocked(Mutation{Type: EventDelete, Key: key, At: now, Attrs: attrs})
+}
+
+// sinkBulkLocked - sinkAppendLocked без резерва места для массовых изменений, см.
+// appendUnreservedLocked. Без WithSink ничего не делает
+func (s *Store) sinkBulkLocked(typ EventType, raw string, it *Item, now time.Time) {
+	if s.sink == nil {
+		return
+	}
+	s.sink.appendUnreservedLocked(s.mutation(typ, raw, it, now))
+}
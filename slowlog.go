@@ -0,0 +1,125 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// Имена операций, которые попадают только в медленный журнал, см. WithSlowLog.
+const (
+	opSnapshotSave = "snapshot_save"
+	opSnapshotLoad = "snapshot_load"
+)
+
+// SlowEntry - операция из медленного журнала, см. WithSlowLog.
+type SlowEntry struct {
+	ID       uint64        `json:"id"` // растёт с каждой записью, пропуски - вытесненные записи
+	At       time.Time     `json:"at"` // начало операции
+	Op       string        `json:"op"` // get, set, delete, cleanup, snapshot_save, snapshot_load
+	Key      string        `json:"key,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// WithSlowLog хранит последние capacity операций, которые шли не меньше threshold, как
+// SLOWLOG в Redis: чтения и записи ключей (те же, что в WithLatencyHistograms), проходы
+// очистки, SaveSnapshot и LoadSnapshot. Время операции включает ожидание блокировки
+// хранилища, так что задержки из-за долгого прохода очистки видны и на соседних Get.
+// См. SlowLog и ResetSlowLog.
+func WithSlowLog(threshold time.Duration, capacity int) Option {
+	return func(c *config) {
+		c.slowThreshold = threshold
+		c.slowCapacity = capacity
+	}
+}
+
+// slowRing - медленный журнал, при переполнении вытесняется самая старая запись
+type slowRing struct {
+	mu   sync.Mutex
+	buf  []SlowEntry
+	head int // индекс самой старой записи
+	n    int
+	next uint64 // ID следующей записи
+}
+
+func (r *slowRing) push(e SlowEntry) {
+	r.mu.Lock()
+	e.ID = r.next
+	r.next++
+	r.buf[(r.head+r.n)%len(r.buf)] = e
+	if r.n < len(r.buf) {
+		r.n++
+	} else {
+		r.head = (r.head + 1) % len(r.buf)
+	}
+	r.mu.Unlock()
+}
+
+// opTimer замеряет операцию для гистограмм задержек и медленного журнала
+type opTimer struct {
+	s       *Store
+	op, key string
+	start   time.Time
+}
+
+// startOp начинает замер операции op над ключом key. false - ни гистограммы, ни медленный
+// журнал не включены, и замер не нужен: так без них операции не вызывают time.Now
+func (s *Store) startOp(op, key string) (opTimer, bool) {
+	if s.lat == nil && s.slow == nil {
+		return opTimer{}, false
+	}
+	return opTimer{s: s, op: op, key: key, start: time.Now()}, true
+}
+
+// stop заканчивает замер, удобно для defer t.stop()
+func (t opTimer) stop() {
+	d := time.Since(t.start)
+	if h := t.s.latency(t.op); h != nil {
+		h.observe(d)
+	}
+	if t.s.slow != nil && d >= t.s.cfg.slowThreshold {
+		t.s.slow.push(SlowEntry{At: t.start, Op: t.op, Key: t.key, Duration: d})
+	}
+}
+
+// SlowLog возвращает до n последних записей медленного журнала, от новых к старым, как
+// SLOWLOG GET; n < 0 - все. Без WithSlowLog возвращает nil.
+func (s *Store) SlowLog(n int) []SlowEntry {
+	r := s.slow
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n < 0 || n > r.n {
+		n = r.n
+	}
+	res := make([]SlowEntry, n)
+	for i := range n {
+		res[i] = r.buf[(r.head+r.n-1-i)%len(r.buf)]
+	}
+	return res
+}
+
+// SlowLogLen возвращает, сколько записей сейчас в медленном журнале.
+func (s *Store) SlowLogLen() int {
+	r := s.slow
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.n
+}
+
+// ResetSlowLog очищает медленный журнал, ID записей продолжают расти.
+func (s *Store) ResetSlowLog() {
+	r := s.slow
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.head, r.n = 0, 0
+	clear(r.buf)
+	r.mu.Unlock()
+}
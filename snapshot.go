@@ -0,0 +1,238 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// snapshotItem - формат элемента в снапшоте
+type snapshotItem struct {
+	Value      string            `json:"value"`
+	ExpiresAt  time.Time         `json:"expiresAt,omitzero"`
+	CreatedAt  time.Time         `json:"createdAt,omitzero"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+	Views      uint64            `json:"views"`
+	AccessedAt time.Time         `json:"accessedAt,omitzero"`
+	MaxViews   uint64            `json:"maxViews,omitempty"`
+	Priority   Priority          `json:"priority,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Meta       map[string]string `json:"meta,omitempty"`
+	Provenance *Provenance       `json:"provenance,omitempty"`
+
+	Kind string            `json:"kind,omitempty"` // тип значения, пусто - строка
+	Hash map[string]string `json:"hash,omitempty"`
+	List []string          `json:"list,omitempty"`
+	Set  []string          `json:"set,omitempty"`
+	ZSet []ZMember         `json:"zset,omitempty"`
+
+	Counter int64  `json:"counter,omitempty"`
+	HLL     []byte `json:"hll,omitempty"`
+}
+
+// SaveSnapshot записывает все неистекшие элементы в w в формате JSON, с заголовком версии
+// формата и схемы ключей (WithKeyVersion). LoadSnapshot читает и снапшоты прежних версий.
+// Стек последних ключей в снапшот не попадает. Ключи пишутся как хранятся,
+// вместе с префиксом версии схемы (WithKeyVersion), что-бы MigrateKeys работал и после рестарта.
+func (s *Store) SaveSnapshot(w io.Writer) error {
+	if t, ok := s.startOp(opSnapshotSave, ""); ok {
+		defer t.stop()
+	}
+	s.flushViews()
+	now := s.now()
+	s.mu.RLock()
+	items := make(map[string]snapshotItem, len(s.data))
+	for key, item := range s.data {
+		if !item.ExpiresAt.IsZero() && now.After(item.ExpiresAt) {
+			continue
+		}
+		items[key] = snapshotItemOf(item)
+	}
+	s.mu.RUnlock()
+
+	if err := s.encodeSnapshot(w, items); err != nil {
+		s.cfg.logger.Error("store: save snapshot failed", "err", err)
+		return fmt.Errorf("store: save snapshot: %w", err)
+	}
+	s.lastSnapshot.Store(s.now().UnixNano())
+	s.cfg.logger.Info("store: snapshot saved", "keys", len(items))
+	return nil
+}
+
+// LoadSnapshot читает снапшот, записанный SaveSnapshot, и добавляет элементы в хранилище
+// поверх существующих. Элементы, истекшие к моменту загрузки, пропускаются, загруженные
+// попадают в приёмник WithSink как записи.
+func (s *Store) LoadSnapshot(r io.Reader) error {
+	if t, ok := s.startOp(opSnapshotLoad, ""); ok {
+		defer t.stop()
+	}
+	if err := s.enter(); err != nil {
+		return err
+	}
+	defer s.leave()
+	_, items, err := s.decodeSnapshot(r)
+	if err != nil {
+		s.cfg.logger.Error("store: load snapshot failed", "err", err)
+		return fmt.Errorf("store: load snapshot: %w", err)
+	}
+
+	now := s.now()
+	skipped := 0
+	s.mu.Lock()
+	for key, si := range items {
+		if !si.ExpiresAt.IsZero() && now.After(si.ExpiresAt) {
+			skipped++
+			continue
+		}
+		item, ok := s.itemFromSnapshot(key, si)
+		if !ok {
+			skipped++
+			continue
+		}
+		s.putLocked(key, item)
+		s.sinkBulkLocked(EventSet, key, item, now)
+	}
+	s.evictLocked(now, "")
+	s.mu.Unlock()
+
+	s.cfg.logger.Info("store: snapshot loaded", "keys", len(items)-skipped, "skipped", skipped)
+	return nil
+}
+
+// snapshotItemOf - элемент в формате снапшота, вызывается под s.mu
+func snapshotItemOf(item *Item) snapshotItem {
+	si := snapshotItem{
+		Value:      item.plain(), // в снапшоте значения без сжатия WithCompression
+		ExpiresAt:  item.ExpiresAt,
+		CreatedAt:  item.CreatedAt,
+		UpdatedAt:  item.UpdatedAt,
+		Views:      item.Views.Load(),
+		AccessedAt: item.lastAccessed(),
+		MaxViews:   item.maxViews,
+		Priority:   item.priority,
+		Tags:       item.tags,
+		Meta:       item.meta, // метаданные не меняются после записи
+		Provenance: item.Provenance.clone(),
+		Hash:       item.hash, // коллекции не меняются после записи, копировать не нужно
+		List:       item.list,
+		Set:        item.members(),
+	}
+	if item.kind != kindString {
+		si.Kind = item.kind.String()
+	}
+	switch item.kind {
+	case kindZSet:
+		si.ZSet = item.zset.rangeByScore(math.Inf(-1), math.Inf(1))
+	case kindCounter:
+		si.Counter = item.counter.Load()
+	case kindHLL:
+		si.HLL = item.hll
+	}
+	return si
+}
+
+// itemFromSnapshot собирает элемент из формата снапшота, false - элемент нужно пропустить.
+// Истечение по ExpiresAt проверяет вызывающий
+func (s *Store) itemFromSnapshot(key string, si snapshotItem) (*Item, bool) {
+	if si.MaxViews > 0 && si.Views >= si.MaxViews {
+		return nil, false // просмотры исчерпаны, см. SetWithMaxViews
+	}
+	kind, ok := parseKind(si.Kind)
+	if !ok {
+		s.cfg.logger.Error("store: unknown value kind in snapshot, key skipped", "key", key, "kind", si.Kind)
+		return nil, false
+	}
+	if !si.Priority.valid() {
+		si.Priority = PriorityNormal
+	}
+	item := &Item{
+		Value:      si.Value,
+		ExpiresAt:  si.ExpiresAt,
+		CreatedAt:  si.CreatedAt,
+		UpdatedAt:  si.UpdatedAt,
+		Provenance: si.Provenance,
+		maxViews:   si.MaxViews,
+		priority:   si.Priority,
+		tags:       normalizeTags(si.Tags),
+		meta:       si.Meta,
+		kind:       kind,
+		hash:       si.Hash,
+		list:       si.List,
+	}
+	if kind == kindSet {
+		item.set = make(map[string]struct{}, len(si.Set))
+		for _, m := range si.Set {
+			item.set[m] = struct{}{}
+		}
+	}
+	for _, m := range si.ZSet {
+		item.zset = item.zset.with(m.Member, m.Score)
+	}
+	switch kind {
+	case kindString:
+		s.setCompressed(item, si.Value)
+	case kindCounter:
+		item.counter = new(atomic.Int64)
+		item.counter.Store(si.Counter)
+	case kindHLL:
+		if len(si.HLL) != hllRegisters {
+			s.cfg.logger.Error("store: broken hyperloglog in snapshot, key skipped", "key", key)
+			return nil, false
+		}
+		item.hll = si.HLL
+	}
+	item.Views.Store(si.Views)
+	if !si.AccessedAt.IsZero() {
+		item.accessedAt.Store(si.AccessedAt.UnixNano())
+	}
+	return item, true
+}
+
+// SaveSnapshotFile сохраняет снапшот в файл path. Запись идёт во временный файл
+// рядом с path, который потом переименовывается, что-бы не оставить обрезанный снапшот при падении.
+func (s *Store) SaveSnapshotFile(path string) error {
+	if err := s.saveSnapshotFile(path); err != nil {
+		s.cfg.logger.Error("store: save snapshot file failed", "path", path, "err", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Store) saveSnapshotFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("store: save snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // после успешного Rename файла уже нет, ошибка игнорируется
+
+	if err := s.SaveSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("store: save snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("store: save snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("store: save snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshotFile загружает снапшот из файла path.
+func (s *Store) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("store: load snapshot: %w", err)
+	}
+	defer f.Close()
+
+	return s.LoadSnapshot(f)
+}
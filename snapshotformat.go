@@ -0,0 +1,242 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// snapshotFormat - метка снапшота в заголовке файла
+	snapshotFormat = "store-snapshot"
+
+	// snapshotVersion - версия формата, которую пишет SaveSnapshot. Версия 1 - JSON-объект
+	// элементов без заголовка, так писали снапшоты до появления версий
+	snapshotVersion = 2
+)
+
+// snapshotHeader - заголовок снапшота. В файле идёт до элементов, что-бы их можно было
+// разбирать по одному, уже зная версию
+type snapshotHeader struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Schema  string    `json:"schema,omitempty"` // WithKeyVersion сохранившего хранилища
+	SavedAt time.Time `json:"savedAt,omitzero"`
+}
+
+// snapshotFile - снапшот целиком, для encodeSnapshot и кодеков WithSnapshotCodec. Поля
+// заголовка не встроены структурой: встроенный неэкспортируемый тип не видят кодеки вроде gob
+type snapshotFile struct {
+	Format  string                  `json:"format"`
+	Version int                     `json:"version"`
+	Schema  string                  `json:"schema,omitempty"`
+	SavedAt time.Time               `json:"savedAt,omitzero"`
+	Items   map[string]snapshotItem `json:"items"`
+}
+
+func (f *snapshotFile) header() snapshotHeader {
+	return snapshotHeader{Format: f.Format, Version: f.Version, Schema: f.Schema, SavedAt: f.SavedAt}
+}
+
+// snapshotUpgrades[v] переводит элемент версии v в версию v+1, nil - элементы не менялись.
+// Новая версия формата добавляет сюда шаг, и старые файлы загружаются без отдельной утилиты
+var snapshotUpgrades = map[int]func(key string, si *snapshotItem){
+	1: nil, // 1 -> 2: появился заголовок, элементы те же
+}
+
+// upgradeItem доводит элемент версии from до snapshotVersion
+func upgradeItem(from int, key string, si *snapshotItem) {
+	for v := from; v < snapshotVersion; v++ {
+		if up := snapshotUpgrades[v]; up != nil {
+			up(key, si)
+		}
+	}
+}
+
+// checkSnapshotHeader проверяет, что снапшот этой версии можно загрузить
+func checkSnapshotHeader(h snapshotHeader) error {
+	if h.Format != snapshotFormat {
+		return fmt.Errorf("unknown snapshot format %q", h.Format)
+	}
+	if h.Version < 1 || h.Version > snapshotVersion {
+		return fmt.Errorf("snapshot format version %d is not supported, max %d", h.Version, snapshotVersion)
+	}
+	return nil
+}
+
+// encodeSnapshot пишет снапшот с заголовком в формате WithSnapshotCodec
+func (s *Store) encodeSnapshot(w io.Writer, items map[string]snapshotItem) error {
+	file := snapshotFile{
+		Format:  snapshotFormat,
+		Version: snapshotVersion,
+		Schema:  s.cfg.keyVersion,
+		SavedAt: s.now(),
+		Items:   items,
+	}
+	if s.cfg.snapshotCodec == nil {
+		return json.NewEncoder(w).Encode(file)
+	}
+	data, err := s.cfg.snapshotCodec.Marshal(file)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// decodeSnapshot читает снапшот любой поддерживаемой версии целиком, элементы уже
+// переведены в текущую версию
+func (s *Store) decodeSnapshot(r io.Reader) (snapshotHeader, map[string]snapshotItem, error) {
+	items := make(map[string]snapshotItem)
+	h, err := s.scanSnapshot(r, func(key string, si snapshotItem) error {
+		items[key] = si
+		return nil
+	})
+	if err != nil {
+		return h, nil, err
+	}
+	s.logSnapshotSchema(h)
+	return h, items, nil
+}
+
+// logSnapshotSchema предупреждает, что ключи снапшота сохранены под другой версией схемы
+func (s *Store) logSnapshotSchema(h snapshotHeader) {
+	if h.Version >= 2 && h.Schema != s.cfg.keyVersion {
+		s.cfg.logger.Info("store: snapshot has another key version, see MigrateKeys",
+			"snapshot", h.Schema, "current", s.cfg.keyVersion)
+	}
+}
+
+// scanSnapshot разбирает снапшот и вызывает fn для каждого элемента, переведённого в текущую
+// версию. JSON разбирается по одному элементу, так что память не зависит от размера файла;
+// снапшот кодека WithSnapshotCodec читается целиком
+func (s *Store) scanSnapshot(r io.Reader, fn func(key string, si snapshotItem) error) (snapshotHeader, error) {
+	if s.cfg.snapshotCodec != nil {
+		return s.scanCodecSnapshot(r, fn)
+	}
+
+	legacy := snapshotHeader{Format: snapshotFormat, Version: 1}
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return legacy, err
+	} else if tok != json.Delim('{') {
+		return legacy, errors.New("snapshot is not a JSON object")
+	}
+	for first := true; dec.More(); first = false {
+		tok, err := dec.Token()
+		if err != nil {
+			return legacy, err
+		}
+		key, _ := tok.(string) // ключи объекта - всегда строки
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return legacy, fmt.Errorf("key %q: %w", key, err)
+		}
+		if first && key == "format" && len(raw) > 0 && raw[0] == '"' {
+			// у элементов значения - объекты, строка бывает только в заголовке
+			return s.scanSnapshotBody(dec, raw, fn)
+		}
+		var si snapshotItem
+		if err := json.Unmarshal(raw, &si); err != nil {
+			return legacy, fmt.Errorf("key %q: %w", key, err)
+		}
+		upgradeItem(legacy.Version, key, &si)
+		if err := fn(key, si); err != nil {
+			return legacy, err
+		}
+	}
+	_, err := dec.Token()
+	return legacy, err
+}
+
+// scanSnapshotBody дочитывает снапшот с заголовком после поля format
+func (s *Store) scanSnapshotBody(dec *json.Decoder, format json.RawMessage, fn func(key string, si snapshotItem) error) (snapshotHeader, error) {
+	var h snapshotHeader
+	if err := json.Unmarshal(format, &h.Format); err != nil {
+		return h, err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return h, err
+		}
+		switch tok {
+		case "version":
+			err = dec.Decode(&h.Version)
+		case "schema":
+			err = dec.Decode(&h.Schema)
+		case "savedAt":
+			err = dec.Decode(&h.SavedAt)
+		case "items":
+			if err := checkSnapshotHeader(h); err != nil {
+				return h, err
+			}
+			err = scanSnapshotItems(dec, h.Version, fn)
+		default:
+			var skip json.RawMessage // поле заголовка из более новой версии
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return h, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return h, err
+	}
+	return h, checkSnapshotHeader(h)
+}
+
+// scanSnapshotItems разбирает объект элементов снапшота версии version
+func scanSnapshotItems(dec *json.Decoder, version int, fn func(key string, si snapshotItem) error) error {
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return errors.New("snapshot items are not a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		var si snapshotItem
+		if err := dec.Decode(&si); err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+		upgradeItem(version, key, &si)
+		if err := fn(key, si); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// scanCodecSnapshot - scanSnapshot для WithSnapshotCodec. Снапшот без заголовка (версия 1)
+// кодек разбирает как мапу элементов
+func (s *Store) scanCodecSnapshot(r io.Reader, fn func(key string, si snapshotItem) error) (snapshotHeader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return snapshotHeader{}, err
+	}
+	var file snapshotFile
+	if err := s.cfg.snapshotCodec.Unmarshal(data, &file); err != nil || file.Format == "" {
+		file = snapshotFile{Format: snapshotFormat, Version: 1}
+		if err := s.cfg.snapshotCodec.Unmarshal(data, &file.Items); err != nil {
+			return file.header(), err
+		}
+	}
+	h := file.header()
+	if err := checkSnapshotHeader(h); err != nil {
+		return h, err
+	}
+	for key, si := range file.Items {
+		upgradeItem(h.Version, key, &si)
+		if err := fn(key, si); err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
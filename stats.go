@@ -0,0 +1,219 @@
+package store
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stats - счетчики операций, обновляются атомарно без блокировок хранилища
+type stats struct {
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	sets      atomic.Uint64
+	deletes   atomic.Uint64
+	expired   atomic.Uint64
+	evictions atomic.Uint64
+
+	staleReads atomic.Uint64 // истекших значений отдано Get, см. LazyStale
+
+	admissionRejected atomic.Uint64 // см. WithAdmission
+	admissionDemoted  atomic.Uint64
+
+	fullRejected atomic.Uint64 // см. WithFullPolicy
+
+	compressed atomic.Uint64 // значений сжато при записи, см. WithCompression
+
+	bloomSkipped        atomic.Uint64 // см. WithBloomFilter
+	bloomFalsePositives atomic.Uint64
+	bloomRebuilds       atomic.Uint64
+
+	retrieved        atomic.Uint64
+	retrievedExpired atomic.Uint64
+	retrievedMissing atomic.Uint64
+
+	invalidated atomic.Uint64 // удалено вслед за входами, см. SetWithDeps
+	idleRemoved atomic.Uint64 // см. WithMaxIdle
+}
+
+// Stats - срез статистики хранилища на момент вызова.
+type Stats struct {
+	Size    int    `json:"size"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Sets    uint64 `json:"sets"`
+	Deletes uint64 `json:"deletes"`
+	Expired uint64 `json:"expired"` // удалено по истечению TTL (в Get и в Cleanup)
+
+	StaleReads uint64 `json:"staleReads,omitempty"` // истекших значений отдано Get в режиме LazyStale
+
+	Evictions   uint64 `json:"evictions"`             // вытеснено из-за лимита WithMaxMemory
+	MemoryBytes int64  `json:"memoryBytes"`           // примерный объём данных
+	MemoryLimit int64  `json:"memoryLimit,omitempty"` // WithMaxMemory, 0 - без лимита
+	Pinned      int    `json:"pinned"`                // закреплённых ключей, см. Pin
+
+	Invalidated uint64 `json:"invalidated,omitempty"` // удалено вслед за входами, см. SetWithDeps
+	IdleRemoved uint64 `json:"idleRemoved,omitempty"` // удалено без обращений, см. WithMaxIdle
+
+	AdmissionRejected uint64 `json:"admissionRejected,omitempty"` // новых ключей не записано фильтром WithAdmission
+	AdmissionDemoted  uint64 `json:"admissionDemoted,omitempty"`  // новых ключей записано с PriorityLow
+
+	FullRejected uint64 `json:"fullRejected,omitempty"` // записей не прошло из-за WithFullPolicy
+
+	Compressed       uint64 `json:"compressed,omitempty"`       // значений сжато при записи, см. WithCompression
+	CompressionSaved int64  `json:"compressionSaved,omitempty"` // на сколько байт сжатые значения в хранилище меньше исходных
+
+	InternedValues int   `json:"internedValues,omitempty"` // различных значений в таблице WithValueInterning
+	InternSaved    int64 `json:"internSaved,omitempty"`    // байт значений, хранящихся одной строкой на несколько ключей
+
+	Retrieved        uint64 `json:"retrieved"`        // ключей выдано RetrieveLastKey
+	RetrievedExpired uint64 `json:"retrievedExpired"` // из них уже истекли к моменту выдачи
+	RetrievedMissing uint64 `json:"retrievedMissing"` // из них уже были удалены
+
+	WatchDropped uint64 `json:"watchDropped"` // событий отброшено из-за переполненных буферов подписчиков
+
+	LastCleanup  time.Time `json:"lastCleanup,omitzero"`  // конец последнего прохода очистки, нулевое - проходов не было
+	LastSnapshot time.Time `json:"lastSnapshot,omitzero"` // последний успешный SaveSnapshot, нулевое - не было
+
+	// Latency - квантили задержек по операциям, только с WithLatencyHistograms
+	Latency map[string]LatencySnapshot `json:"latency,omitempty"`
+
+	// Prefixes - статистика по префиксам ключей, только с WithPrefixStats
+	Prefixes map[string]PrefixStats `json:"prefixes,omitempty"`
+
+	// Sink - состояние очереди отложенной записи, только с WithSink
+	Sink *SinkStats `json:"sink,omitempty"`
+
+	// Bloom - состояние фильтра Блума, только с WithBloomFilter
+	Bloom *BloomStats `json:"bloom,omitempty"`
+
+	// Writes - очередь записей, только с WithWriteConcurrency
+	Writes *WriteStats `json:"writes,omitempty"`
+
+	// Namespaces - статистика пространств имён, см. Namespace
+	Namespaces map[string]NamespaceStats `json:"namespaces,omitempty"`
+}
+
+// WithApproximateStats убирает из Stats ожидание блокировки хранилища: если она занята
+// записью, объём памяти, сжатие, закрепления и пространства имён берутся из прошлого
+// вызова Stats. Счётчики операций и Size всегда текущие. Полезно, когда метрики
+// опрашиваются часто, а запись идёт плотно.
+func WithApproximateStats() Option {
+	return func(c *config) {
+		c.approxStats = true
+	}
+}
+
+// lockedStats - часть Stats, которая читается под s.mu
+type lockedStats struct {
+	mem, saved  int64
+	pinned      int
+	interned    int
+	internSaved int64
+	namespaces  map[string]NamespaceStats
+}
+
+// readLockedStats читает lockedStats, с WithApproximateStats не ждёт занятую блокировку
+func (s *Store) readLockedStats() lockedStats {
+	if !s.cfg.approxStats {
+		s.mu.RLock()
+	} else if !s.mu.TryRLock() {
+		if c := s.statsCache.Load(); c != nil {
+			return *c
+		}
+		s.mu.RLock() // первый вызов, брать ещё нечего
+	}
+	ls := lockedStats{mem: s.memUsed, saved: s.compressionSaved, pinned: len(s.pins)}
+	ls.interned, ls.internSaved = s.internStatsLocked()
+	if len(s.namespaces) > 0 {
+		ls.namespaces = make(map[string]NamespaceStats, len(s.namespaces))
+		for _, ns := range s.namespaces {
+			ls.namespaces[ns.name] = ns.statsLocked()
+		}
+	}
+	s.mu.RUnlock()
+	if s.cfg.approxStats {
+		s.statsCache.Store(&ls)
+	}
+	return ls
+}
+
+// Stats возвращает текущую статистику хранилища, см. WithApproximateStats.
+func (s *Store) Stats() Stats {
+	ls := s.readLockedStats()
+	mem, saved, pinned := ls.mem, ls.saved, ls.pinned
+	interned, internSaved := ls.interned, ls.internSaved
+
+	return Stats{
+		Size:    s.Size(),
+		Hits:    s.stats.hits.Load(),
+		Misses:  s.stats.misses.Load(),
+		Sets:    s.stats.sets.Load(),
+		Deletes: s.stats.deletes.Load(),
+		Expired: s.stats.expired.Load(),
+
+		StaleReads: s.stats.staleReads.Load(),
+
+		Evictions: s.stats.evictions.Load(),
+		Pinned:    pinned,
+
+		Invalidated: s.stats.invalidated.Load(),
+		IdleRemoved: s.stats.idleRemoved.Load(),
+
+		AdmissionRejected: s.stats.admissionRejected.Load(),
+		AdmissionDemoted:  s.stats.admissionDemoted.Load(),
+
+		FullRejected: s.stats.fullRejected.Load(),
+
+		Compressed:       s.stats.compressed.Load(),
+		CompressionSaved: saved,
+
+		InternedValues: interned,
+		InternSaved:    internSaved,
+		MemoryBytes:    mem,
+		MemoryLimit:    s.cfg.maxMemory,
+
+		Retrieved:        s.stats.retrieved.Load(),
+		RetrievedExpired: s.stats.retrievedExpired.Load(),
+		RetrievedMissing: s.stats.retrievedMissing.Load(),
+
+		WatchDropped: s.watchers.dropped.Load(),
+
+		LastCleanup:  unixTime(s.janitor.last.Load()),
+		LastSnapshot: unixTime(s.lastSnapshot.Load()),
+
+		Latency:  s.latencySnapshots(),
+		Prefixes: s.prefixStats(),
+		Sink:     s.sinkStats(),
+		Bloom:    s.bloomStats(),
+		Writes:   s.writeStats(),
+
+		Namespaces: ls.namespaces,
+	}
+}
+
+// unixTime - время из UnixNano, 0 - нулевое время
+func unixTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// expvarMu делает проверку имени и публикацию в expvar одним шагом: expvar.Publish
+// паникует на занятом имени, а два New с одним WithExpvar могут идти параллельно
+var expvarMu sync.Mutex
+
+// publishExpvar регистрирует статистику в expvar, значение вычисляется при каждом чтении.
+// Занятое имя - ErrInvalidConfig
+func (s *Store) publishExpvar(name string) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("%w: expvar name %q is already published", ErrInvalidConfig, name)
+	}
+	expvar.Publish(name, expvar.Func(func() any { return s.Stats() }))
+	return nil
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExpvarNameIsPublishedOnce(t *testing.T) {
+	const name = "store-test-expvar-once"
+	var ok, taken atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			_, err := New(WithExpvar(name))
+			switch {
+			case err == nil:
+				ok.Add(1)
+			case errors.Is(err, ErrInvalidConfig):
+				taken.Add(1)
+			default:
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if ok.Load() != 1 || taken.Load() != 7 {
+		t.Fatalf("%d stores published %q, %d rejected", ok.Load(), name, taken.Load())
+	}
+
+	var st Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &st); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,11 +2,14 @@
 // Код представляет собой некое хранилище в памяти
 // data - основные данные ключ-значение
 // Item - сам элемент со значением ttl и кол-ом просмотров
-// lastKeys - стек с максимальным кол-ом элементов 30 который хранит последние ключи
+// recent - кольцо последних записанных ключей (по умолчанию 30) с временем записи
 package store
 
 import (
 	"context"
+	"errors"
+	"maps"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,125 +19,597 @@ import (
 type Item struct {
 	Value     string        `json:"value"`
 	ExpiresAt time.Time     `json:"expiresAt"` // Если время не задано, считается, что элемент не истекает.
+	CreatedAt time.Time     `json:"createdAt"` // Время появления ключа, перезапись значения его сохраняет.
+	UpdatedAt time.Time     `json:"updatedAt"` // Время последней записи значения.
+	Version   uint64        `json:"version"`   // Растёт с каждой записью ключа, см. GetWithVersion.
 	Views     atomic.Uint64 `json:"views"`     // +new: атомик быстрее и потокобезопаснее, подходит для инкриментов
+
+	accessedAt atomic.Int64 // время последнего чтения в UnixNano, 0 - не читали
+	referenced atomic.Bool  // читали с прошлого прохода стрелки, см. EvictClock
+
+	Provenance *Provenance `json:"provenance,omitempty"` // Кто записал значение, nil если не передали.
+
+	freshUntil time.Time         // для stale-while-revalidate: после этого значение устарело, но ещё отдаётся до ExpiresAt
+	loadCost   time.Duration     // сколько загрузчик вычислял значение, для WithEarlyRefresh
+	maxViews   uint64            // лимит чтений, 0 - без лимита, см. SetWithMaxViews
+	priority   Priority          // порядок вытеснения, см. SetWithPriority
+	tags       []string          // отсортированные теги, см. SetWithTags
+	deps       []string          // отсортированные ключи хранения, от которых зависит значение, см. SetWithDeps
+	group      string            // группа истечения, см. SetInGroup
+	meta       map[string]string // метаданные, не меняются после записи, см. SetWithMeta
+	rawSize    int               // длина исходного значения, если Value сжато, иначе 0, см. WithCompression
+	attrs      map[string]string // атрибуты запроса, записавшего значение, см. WithAttrs
+	size       int64             // примерный объём с ключом на момент записи, см. itemSize
+
+	kind valueKind           // тип значения, для kindString значение в Value
+	hash map[string]string   // поля для kindHash, см. HSet
+	list []string            // значения для kindList, см. RPush
+	set  map[string]struct{} // элементы для kindSet, см. SAdd
+	zset *zset               // элементы со счетами для kindZSet, см. ZAdd
+
+	counter *atomic.Int64 // значение kindCounter, меняется на месте атомарно, см. Counter
+	hll     []byte        // регистры kindHLL, см. PFAdd
 }
 
 // Store – простое in-memory хранилище.
 type Store struct {
-	mu   sync.RWMutex
+	mu   waitMutex
 	data map[string]*Item // +new: храним указатель на Item, что-бы работать с оригинальным значением в ресиверах
 
-	//стек последних ключей
-	stackMutex sync.Mutex
-	lastKeys   []string // последние ключи
+	mirror *sync.Map // копия data для чтений без блокировки, nil без EngineSyncMap, см. WithEngine
+
+	longKeys sync.Map // исходные ключи по ключу хранения, см. WithKeyHashing; skey пишет без блокировки
+
+	recent *recentRing // последние записанные ключи для RetrieveLastKey и RecentActivity
+
+	memUsed          int64        // примерный объём данных в байтах, меняется под mu
+	peakEntries      int          // наибольший len(data) с последнего Shrink, под mu
+	length           atomic.Int64 // len(data) для Size без блокировки, меняется под mu
+	version          uint64       // последняя выданная Item.Version, под mu
+	epoch            uint64       // метка запуска хранилища, см. Epoch
+	compressionSaved int64        // сколько байт экономят сжатые значения, под mu
+
+	cleanupCur cleanupCursor // продолжение пошаговой очистки, см. WithIncrementalCleanup
+
+	lastDecay atomic.Int64 // время прошлого затухания Views в UnixNano, см. WithViewsDecay
+
+	janitor      janitorStats // проходы очистки, см. Info
+	lastSnapshot atomic.Int64 // последний успешный SaveSnapshot в UnixNano
+	started      time.Time    // создание хранилища по его Clock, см. Info
+
+	coarseNow atomic.Pointer[time.Time] // время WithCoarseClock, nil - спрашивать Clock
+
+	viewBuf *viewBuffer // просмотры, ещё не добавленные к Views, nil - без WithBufferedViews
+
+	roomFreed chan struct{} // закрывается, когда объём уменьшился, для FullBlock; под mu
+	roomGen   atomic.Uint64 // сколько раз объём уменьшался, пишется под mu, см. withRoom
+
+	cfg        config
+	stats      stats
+	statsCache atomic.Pointer[lockedStats] // последнее чтение под mu, см. WithApproximateStats
+	lat        *latencies                  // nil, если гистограммы задержек выключены
+
+	prefixes *prefixCounters  // nil, если WithPrefixStats не задан
+	hot      *hotKeys         // nil, если WithHotKeyDetection не задан
+	freq     *frequencySketch // nil, если WithAdmission не задан
+
+	bloom atomic.Pointer[bloomFilter] // nil, если WithBloomFilter не задан
+	items *itemBlocks                 // nil, если WithItemBlocks не задан
+
+	interned *internTable    // общие значения, nil без WithValueInterning, под mu
+	idx      *secondaryIndex // nil без WithIndexes, под mu
+	ring     *clockRing      // nil без EvictClock, под mu
+
+	keyLocks keyLocks // мьютексы LockKey, не связаны с mu
+	flights  flights  // вызовы Do в процессе
+
+	watchers watchers // подписчики Watch и WatchPrefix
+
+	sink    *sinkQueue  // nil, если WithSink не задан
+	durable *durableLog // nil, если WithDurableLog не задан
+
+	writes *writeLimiter // nil, если WithWriteConcurrency не задан
+
+	auditLog *auditRing // nil, если WithAuditLog не задан
+	slow     *slowRing  // nil, если WithSlowLog не задан
+
+	namespaces map[string]*Namespace // пространства имён по имени, под mu
+	nsLabeled  int                   // пространств со своей меткой, см. WithNamespaceMetrics, под mu
+
+	history map[string][]HistoryEntry // прежние значения ключей хранения, от старых к новым, под mu
+	pins    map[string]bool           // закреплённые ключи хранения, true - и от истечения, см. Pin, под mu
+	tombs   map[string]tombstone      // значения, удалённые DeleteSoft, по ключу хранения, под mu
+
+	dependents map[string]map[string]struct{} // ключи, зависящие от ключа хранения, см. SetWithDeps, под mu
+	cascade    []string                       // очередь каскадного удаления, под mu
+	groups     map[string]*expireGroup        // группы истечения по имени, см. SetInGroup, под mu
+
+	sched schedule // отложенные записи, см. SetAt
+
+	priorityCount [priorityLevels]int // ключей каждого приоритета, индекс - Priority+1, под mu
+
+	life lifecycle // закрытие хранилища, см. Close
+
+	// промахи, которые сейчас "вычисляет" первый промахнувшийся, см. WithMissDedup
+	dedupMu sync.Mutex
+	pending map[string]*pendingMiss
 }
 
-// NewStore создаёт новое хранилище.
-func NewStore() *Store { // +new: возвращаем указатель на наш Стор, который создали
-	return &Store{
-		lastKeys: make([]string, 0, 30),
-		data:     make(map[string]*Item), // +new: нужно инициализировать мапу, что-бы избежать ошибок
+// NewStore создаёт новое хранилище. Неверные опции - ошибка программиста, поэтому NewStore
+// паникует, если New вернул бы ErrInvalidConfig.
+func NewStore(opts ...Option) *Store { // +new: возвращаем указатель на наш Стор, который создали
+	s, err := New(opts...)
+	if err != nil {
+		panic(err)
 	}
+	return s
+}
+
+// New создаёт новое хранилище, как NewStore, но возвращает ErrInvalidConfig вместо паники,
+// если опции вне допустимых значений, например из конфига сервиса.
+func New(opts ...Option) (*Store, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	s := newStore(cfg)
+	if cfg.expvarName != "" {
+		if err := s.publishExpvar(cfg.expvarName); err != nil {
+			s.Close(context.Background())
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// newStore создаёт хранилище по проверенной конфигурации, общая часть New и Clone
+func newStore(cfg config) *Store {
+	s := &Store{
+		data: make(map[string]*Item), // +new: нужно инициализировать мапу, что-бы избежать ошибок
+		life: lifecycle{done: make(chan struct{}), idle: make(chan struct{}, 1)},
+		cfg:  cfg,
+
+		epoch: newEpoch(cfg.deterministic),
+	}
+	s.mirror = newMirror(s.cfg.engine)
+	s.recent = newRecentRing(s.cfg.recentCapacity, s.cfg.recentMRU)
+	if s.cfg.logger == nil {
+		s.cfg.logger = nopLogger{}
+	}
+	if s.cfg.clock == nil {
+		s.cfg.clock = realClock{}
+	}
+	if s.cfg.codec == nil {
+		s.cfg.codec = JSONCodec{}
+	}
+	s.started = s.cfg.clock.Now()
+
+	if len(s.cfg.statPrefixes) > 0 {
+		s.prefixes = newPrefixCounters(s.cfg.statPrefixes)
+	}
+	if s.cfg.hotWindow > 0 {
+		s.hot = newHotKeys(s.cfg.hotThreshold, s.cfg.hotWindow)
+	}
+	if s.cfg.internMax > 0 {
+		s.interned = newInternTable(s.cfg.internMax)
+	}
+	if s.cfg.indexes != 0 {
+		s.idx = newSecondaryIndex(s.cfg.indexes)
+	}
+	if s.cfg.eviction == EvictClock {
+		s.ring = newClockRing()
+	}
+	if s.cfg.itemBlock > 1 {
+		s.items = &itemBlocks{size: s.cfg.itemBlock}
+	}
+	if s.cfg.bloomKeys > 0 {
+		s.bloom.Store(newBloomFilter(s.cfg.bloomKeys, s.cfg.bloomFPRate))
+	}
+	if s.cfg.admission != AdmissionOff {
+		s.freq = newFrequencySketch(s.cfg.maxMemory)
+	}
+	if s.cfg.missDedupWindow > 0 {
+		s.pending = make(map[string]*pendingMiss)
+	}
+	if s.cfg.latency {
+		s.lat = newLatencies()
+		s.mu.read, s.mu.write = &s.lat.lockRead, &s.lat.lockWrite
+		if s.cfg.lockTiming {
+			s.mu.hold = &s.lat.lockHold
+		}
+	}
+	if s.cfg.auditCapacity > 0 {
+		s.auditLog = &auditRing{buf: make([]AuditRecord, s.cfg.auditCapacity)}
+	}
+	if s.cfg.slowCapacity > 0 {
+		s.slow = &slowRing{buf: make([]SlowEntry, s.cfg.slowCapacity)}
+	}
+	if s.cfg.writeConcurrency > 0 {
+		s.writes = &writeLimiter{slots: make(chan struct{}, s.cfg.writeConcurrency)}
+	}
+	if s.cfg.sink != nil {
+		s.sink = newSinkQueue(s.cfg.sink, s.cfg.sinkOpts, s.cfg.clock)
+		go s.runSink()
+	}
+	if s.cfg.durableLog != nil {
+		s.durable = newDurableLog(s.cfg.durableLog)
+		go s.durable.run()
+	}
+	if s.cfg.coarseClock > 0 {
+		now := s.cfg.clock.Now()
+		s.coarseNow.Store(&now)
+		go s.runCoarseClock(s.cfg.clock.NewTicker(s.cfg.coarseClock))
+	}
+	if s.cfg.viewFlush > 0 {
+		s.viewBuf = &viewBuffer{}
+		go s.runViewFlush(s.cfg.clock.NewTicker(s.cfg.viewFlush))
+	}
+	if every := s.cfg.invariantInterval(); every > 0 {
+		if s.cfg.deterministic {
+			s.reportInvariants() // дальше - на проходах очистки, см. cleanupPass
+		} else {
+			go s.runInvariantChecks(s.cfg.clock.NewTicker(every))
+		}
+	}
+	return s
 }
 
 // Set сохраняет значение по ключу с TTL в секундах.
-// Если ttl <= 0, ключ не имеет срока истечения.
+// Если ttl <= 0, ключ не имеет срока истечения (ttl == 0 с WithDefaultTTL получает TTL по умолчанию).
+// Ошибка - некорректная запись в строгом режиме (WithStrict), ключ или значение вне политики
+// (ErrInvalidKey, ErrValueTooLarge), ErrFull по WithFullPolicy, ErrClosed после Close
+// и ErrReadOnly в режиме только для чтения; без этих опций и до Close - всегда nil.
 // +new: используем указатели на Store, что-бы ставить mutex на оригинальный кеш, и ttl = time.Duration для удобства
 // +new: upd. TTL в time.Duration
-func (s *Store) Set(key, value string, ttl time.Duration) {
-	var expires time.Time
-	if ttl > 0 {
-		expires = time.Now().Add(ttl)
+func (s *Store) Set(key, value string, ttl time.Duration) error {
+	return s.set(key, value, ttl, writeOpts{})
+}
+
+// writeOpts - дополнительные параметры записи для SetWithProvenance и LoadingStore
+type writeOpts struct {
+	prov     *Provenance
+	staleFor time.Duration     // сколько хранить значение после истечения TTL, см. WithStaleWhileRevalidate
+	loadCost time.Duration     // время загрузки значения, см. WithEarlyRefresh
+	maxViews uint64            // после стольких чтений ключ удаляется, см. SetWithMaxViews
+	views    uint64            // начальное число просмотров, см. CopyFrom
+	priority Priority          // порядок вытеснения, см. SetWithPriority
+	tags     []string          // теги после normalizeTags, см. SetWithTags
+	deps     []string          // ключи хранения входов, см. SetWithDeps
+	group    string            // группа истечения, срок группы - ttl записи, см. SetInGroup
+	meta     map[string]string // копия метаданных, см. SetWithMeta
+	actor    string            // кто пишет, для журнала аудита, см. WithActor
+	attrs    map[string]string // атрибуты запроса, см. WithAttrs
+
+	replicated bool // изменение с основного узла, проходит и в режиме только для чтения, см. ApplyMutation
+	local      bool // не попадает в приёмник WithSink, см. Invalidate
+	internal   bool // запись самого хранилища (загрузка, SetAt, Warm), после Close не паникует
+
+	lockCtx context.Context // не nil - ждать блокировку не дольше, см. TrySet
+
+	durable *durableWait  // не nil - записать в журнал WithDurableLog, см. SetDurable и DeleteDurable
+	token   *SessionToken // не nil - сюда пишется токен записи, см. SetWithToken
+	// не nil - записать, только если версия ключа всё ещё равна ему (0 - ключа нет),
+	// иначе ErrVersionMismatch, см. LoadingStore
+	ifVersion *uint64
+	payload   *Item // не nil - записать коллекцию этого элемента вместо строки, см. ApplyMutation
+}
+
+// set - общая часть Set, SetWithProvenance и записи из загрузчика
+func (s *Store) set(key, value string, ttl time.Duration, w writeOpts) error {
+	if t, ok := s.startOp(opSet, key); ok {
+		defer t.stop()
 	}
-	s.mu.Lock()          // +new: используем единый мутекс, не создаем новые каждый раз
-	s.data[key] = &Item{ // +new: сохраняем указатель на наш новый Итем
-		Value:     value,
-		ExpiresAt: expires,
+	return s.withRoom(key, w.lockCtx, func() error {
+		return s.setOnce(key, value, ttl, w)
+	})
+}
+
+// setOnce - одна попытка set
+func (s *Store) setOnce(key, value string, ttl time.Duration, w writeOpts) error {
+	if err := s.checkWrite(key, ttl); err != nil {
+		return err
+	}
+	if err := s.checkValueSize(key, len(value)); err != nil {
+		return err
 	}
+	if err := s.beginWrite(w); err != nil {
+		return err
+	}
+	defer s.endWrite()
+
+	userKey := key
+	key = s.wkey(key)
+	now := s.now()
+	item := s.newItem() // +new: сохраняем указатель на наш новый Итем
+	item.ExpiresAt = expiresAt(now, s.effectiveTTL(ttl))
+	item.UpdatedAt = now
+	item.Provenance = w.prov
+	item.loadCost = w.loadCost
+	item.maxViews = w.maxViews
+	item.Views.Store(w.views)
+	item.priority = w.priority
+	item.tags = w.tags
+	item.deps = w.deps
+	item.meta = w.meta
+	item.attrs = w.attrs
+	s.setCompressed(item, value)
+	if w.payload != nil {
+		item.setPayload(w.payload)
+	}
+	if w.staleFor > 0 && !item.ExpiresAt.IsZero() {
+		item.freshUntil = item.ExpiresAt
+		item.ExpiresAt = item.ExpiresAt.Add(w.staleFor)
+	}
+	s.recordAccess(key)
+	queued := s.sinkReserve()
+	if err := s.lockWrite(w.lockCtx); err != nil { // +new: используем единый мутекс, не создаем новые каждый раз
+		s.sinkRelease(queued)
+		return err
+	}
+	if w.deps != nil && !s.depsLiveLocked(w.deps, now) {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return ErrNotFound
+	}
+	if w.ifVersion != nil && s.versionLocked(key, now) != *w.ifVersion {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return ErrVersionMismatch
+	}
+	admitted, full, err := s.placeLocked(key, item, now)
+	if !admitted {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		s.placed(userKey, full, err)
+		return err // nil - запись отклонил фильтр допуска
+	}
+	var gone []expiredItem
+	if w.group != "" {
+		gone = s.joinGroupLocked(item, w.group, ttl, now, gone)
+	}
+	s.putLocked(key, item)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, item, now)
+	}
+	if w.durable != nil {
+		s.durableAppendLocked(key, item, now, w.durable)
+	}
+	if w.token != nil {
+		*w.token = SessionToken{Epoch: s.epoch, Version: item.Version, At: now}
+	}
+	s.evictLocked(now, key)
 	s.mu.Unlock() // +new: сразу отпустили Lock, как сохранили
+	s.expired(gone)
+	s.placed(userKey, full, nil)
+	s.stats.sets.Add(1)
+	s.resolveMiss(key)
 	s.push(key)
+	s.audit(AuditSet, userKey, w)
+	return nil
 }
 
 // RetrieveLastKey извлекает последний ключ
 // удаляет его из мапы и показывает пользователю
 // +new: и удаляет последний ключ из стака
 func (s *Store) RetrieveLastKey() string {
-	s.stackMutex.Lock() // +new: top() и pop() не атомарны - между ними моджет вклинится другой поток
-	if len(s.lastKeys) == 0 {
-		s.stackMutex.Unlock()
+	if s.enter() != nil {
 		return ""
 	}
+	defer s.leave()
+	e, ok := s.recent.pop() // +new: top() и pop() не атомарны - между ними моджет вклинится другой поток
+	if !ok {
+		return ""
+	}
+	k := e.key
 
-	k := s.lastKeys[len(s.lastKeys)-1]
-	s.lastKeys = s.lastKeys[:len(s.lastKeys)-1]
-	s.stackMutex.Unlock()
-
+	now := s.now()
+	queued := s.sinkReserve()
 	s.mu.Lock()
-	delete(s.data, k)
+	item, exists := s.data[k]
+	switch {
+	case !exists:
+		s.stats.retrievedMissing.Add(1)
+	case item.expiredAt(now):
+		s.stats.retrievedExpired.Add(1)
+	}
+	if s.removeLocked(k, EventDelete) {
+		s.stats.deletes.Add(1)
+	}
+	if queued {
+		s.sinkAppendLocked(EventDelete, k, nil, now)
+	}
 	s.mu.Unlock()
+	s.stats.retrieved.Add(1)
 
+	k, _ = s.userKey(k)
 	return k
 }
 
-// Size - получаем размер хранилища
+// Size - получаем размер хранилища. Блокировку не берёт: счётчик меняется вместе с мапой,
+// поэтому параллельно с записью можно увидеть значение до или после неё.
 func (s *Store) Size() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	l := len(s.data) // +new убрал Println, потому что возврат размера не подразумевает вывод к консоль
-	return l
+	return int(s.length.Load()) // +new убрал Println, потому что возврат размера не подразумевает вывод к консоль
 }
 
 // Get возвращает значение для ключа, если он существует и не истёк.
+// Для коллекций (HSet, RPush, SAdd, ZAdd) возвращается JSON со всем содержимым.
+// Чтение строкового значения не выделяет память; исключения - ключ с префиксом
+// WithKeyVersion (склейка ключа хранения), ключ, который изменил WithKeyTransformer,
+// и сжатое WithCompression значение (результат распаковки).
 func (s *Store) Get(key string) (string, bool) {
 	//	+new: if s.Size() == 0 лишняя проверка, потому что на if !ok, все-ровно вернем "", false
-	s.mu.RLock()
-	item, ok := s.data[key]
-	s.mu.RUnlock() // +new: отпустили мутекс на чтение сразу после прочтения
+	if t, ok := s.startOp(opGet, key); ok {
+		defer t.stop()
+	}
+	value, ok, _ := s.lookup(context.Background(), key)
+	return value, ok
+}
 
+// lookup - общая часть Get и GetContext: чтение с ожиданием дедупликации промахов,
+// которое прерывается отменой ctx
+func (s *Store) lookup(ctx context.Context, key string) (string, bool, error) {
+	userKey := key
+	key = s.skey(key)
+
+	value, ok := s.get(key)
+	if !ok && s.cfg.missDedupWindow > 0 {
+		resolved, err := s.awaitMiss(ctx, key)
+		if err != nil {
+			return "", false, err
+		}
+		if resolved {
+			value, ok = s.get(key)
+		}
+	}
+	s.countPrefix(userKey, ok)
+
+	return value, ok, nil
+}
+
+// get ищет элемент по ключу хранения, истекший элемент удаляется
+func (s *Store) get(key string) (string, bool) {
+	item, ok := s.getItem(key)
 	if !ok {
 		return "", false
 	}
+	return item.text(), true
+}
+
+// getItem - get, который возвращает сам элемент
+func (s *Store) getItem(key string) (*Item, bool) {
+	s.activateKey(key)
+	s.recordAccess(key)
+	if s.bloomAbsent(key) {
+		return s.readItem(key, nil, false)
+	}
+	item, ok := s.loadItem(key)
+	if !ok {
+		s.bloomMiss()
+	}
+	return s.readItem(key, item, ok)
+}
+
+// readItem - вторая половина getItem для найденного под блокировкой элемента: проверка
+// истечения и лимита просмотров, счетчики чтений
+func (s *Store) readItem(key string, item *Item, ok bool) (*Item, bool) {
+	if !ok {
+		s.stats.misses.Add(1)
+		return nil, false
+	}
 	// Если у элемента задано время истечения и оно прошло, считаем, что ключ не найден.
 	// +new добавил = проверку, на то что итем не удалился, перед проверкой его значения
-	if !item.ExpiresAt.IsZero() && time.Now().After(item.ExpiresAt) {
-		s.mu.Lock()
-		if curValue, ok := s.data[key]; ok && curValue == item {
-			delete(s.data, key)
-		}
+	now := s.now() // один вызов часов на чтение: на горячем пути это самая дорогая часть
+	if !item.ExpiresAt.IsZero() && now.After(item.ExpiresAt) {
+		switch s.cfg.lazyExpiration {
+		case LazyStale:
+			s.stats.staleReads.Add(1) // отдаём как попадание ниже
+		case LazyRetain:
+			s.stats.misses.Add(1)
+			return nil, false
+		default:
+			var gone []expiredItem
+			s.mu.Lock()
+			if curValue, ok := s.data[key]; ok && curValue == item {
+				gone = s.expireLocked(key, item, gone)
+				s.cfg.logger.Debug("store: expired key removed on get", "key", key)
+			}
 
-		s.mu.Unlock()
-		return "", false
+			s.mu.Unlock()
+			s.expired(gone)
+			s.stats.misses.Add(1)
+			return nil, false
+		}
+	}
+	views := s.countView(key, item, now) // +new: увеличваем количество просмотров на 1
+	if !s.consumeView(key, item, views) {
+		s.stats.misses.Add(1)
+		return nil, false
+	}
+	s.stats.hits.Add(1)
+	s.recordRead(key, now)
+	if s.cfg.touchOnGet {
+		s.push(key)
 	}
-	item.Views.Add(1) // +new: увеличваем количество просмотров на 1
 
-	return item.Value, true
+	return item, true
 }
 
 // GetViews - вернет сколько просмотрели ключ
 func (s *Store) GetViews(key string) uint64 {
-	s.mu.RLock()
-	item, ok := s.data[key]
-	s.mu.RUnlock()
+	key = s.skey(key)
+	item, ok := s.loadItem(key)
 
 	if !ok {
 		return 0
 	}
-	return item.Views.Load() // +new: возвращаем число просмотров из атомика
+	views := item.Views.Load() // +new: возвращаем число просмотров из атомика
+	if s.viewBuf != nil {
+		views += s.viewBuf.pending(key)
+	}
+	return views
 }
 
 // Delete удаляет элемент по ключу.
 func (s *Store) Delete(key string) {
+	s.remove(key, writeOpts{})
+}
+
+// Invalidate удаляет ключ как Delete, но удаление не попадает в приёмник WithSink и проходит
+// в режиме только для чтения. Так узел применяет инвалидацию от соседа (пакет gossip),
+// не рассылая её обратно.
+func (s *Store) Invalidate(key string) {
+	s.remove(key, writeOpts{replicated: true, local: true})
+}
+
+// remove - общая часть Delete, DeleteContext, DeleteDurable, Invalidate и ApplyMutation, из w
+// используются actor, replicated, local и durable. Ошибка - удаление не выполнено
+func (s *Store) remove(key string, w writeOpts) error {
+	if t, ok := s.startOp(opDelete, key); ok {
+		defer t.stop()
+	}
+	w.lockCtx = nil // без ctx место под запись ждётся без ошибки
+	if err := s.beginWrite(w); err != nil {
+		return err
+	}
+	defer s.endWrite()
+	userKey := key
+	key = s.skey(key)
+	queued := !w.local && s.sinkReserve()
 	s.mu.Lock() // +new: ставим лок из оригинального *Store
-	defer s.mu.Unlock()
 
-	delete(s.data, key)
+	if s.removeLocked(key, EventDelete) {
+		s.stats.deletes.Add(1)
+	}
+	now := s.now()
+	if queued {
+		// в приёмнике ключ мог остаться, даже если из кеша он уже ушёл
+		s.sinkDeleteLocked(key, w.attrs, now)
+	}
+	if w.durable != nil {
+		s.durable.appendLocked(s.mutation(EventDelete, key, nil, now), w.durable)
+	}
+	s.mu.Unlock()
+	s.audit(AuditDelete, userKey, w)
+	return nil
 }
 
 // +new: DTO без атомика
 type ItemDTO struct {
-	Value     string
-	ExpiresAt time.Time
-	Views     uint64
+	Value          string
+	Kind           string // тип значения: пусто - строка, иначе как в Mutation.Kind
+	ExpiresAt      time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	LastAccessedAt time.Time // нулевое - ключ не читали
+	Views          uint64
+	Version        uint64
+	Size           int64             // примерный объём в байтах с ключом, как его считает WithMaxMemory
+	Provenance     *Provenance       // копия, изменение не влияет на хранилище
+	Tags           []string          // см. SetWithTags, общий с хранилищем срез, не изменять
+	Meta           map[string]string // копия, см. SetWithMeta
 }
 
 // FullList возвращает список всего
@@ -142,16 +617,41 @@ type ItemDTO struct {
 // map[string]*Item — нельзя (утечка внутренних указателей).
 // map[string]Item — тоже нельзя, потому что это копирует atomic.Uint64
 func (s *Store) FullList() map[string]ItemDTO {
+	return s.FullListFields(ListAll)
+}
+
+// ListFields - какие поля ItemDTO заполняет FullListFields, остальные остаются нулевыми.
+type ListFields uint8
+
+const (
+	ListValue      ListFields = 1 << iota // Value, для коллекций - JSON, самое дорогое поле
+	ListTimes                             // ExpiresAt, CreatedAt, UpdatedAt, LastAccessedAt
+	ListViews                             // Views
+	ListVersion                           // Version
+	ListProvenance                        // Provenance, копия
+	ListTags                              // Tags
+	ListMeta                              // Meta, копия
+	ListSize                              // Size
+
+	// ListMetadata - всё, кроме значения, для административных списков.
+	ListMetadata = ListTimes | ListViews | ListVersion | ListProvenance | ListTags | ListMeta | ListSize
+	// ListAll - все поля, как FullList.
+	ListAll = ListValue | ListMetadata
+)
+
+// FullListFields - FullList только с полями fields. Без ListValue не собирается JSON коллекций
+// и не распаковываются сжатые значения, так что список метаданных большого хранилища
+// заметно дешевле и короче держит блокировку.
+func (s *Store) FullListFields(fields ListFields) map[string]ItemDTO {
 	s.mu.RLock()
 	newData := make(map[string]ItemDTO, len(s.data)) //	+new: сразу выделяем память
 
-	for key, val := range s.data {
-		newValue := ItemDTO{
-			Value:     val.Value,
-			ExpiresAt: val.ExpiresAt,
-			Views:     val.Views.Load(), // +new: сохраняем значение как uint64
+	for rawKey, val := range s.data {
+		key, ok := s.userKey(rawKey)
+		if !ok {
+			continue // ключ другой версии схемы, см. WithKeyVersion
 		}
-		newData[key] = newValue
+		newData[key] = itemDTO(val, fields)
 	}
 
 	s.mu.RUnlock()
@@ -159,89 +659,417 @@ func (s *Store) FullList() map[string]ItemDTO {
 	return newData
 }
 
+// itemDTO - ItemDTO элемента с полями fields, см. FullListFields
+func itemDTO(val *Item, fields ListFields) ItemDTO {
+	var dto ItemDTO
+	if fields&ListValue != 0 {
+		dto.Value = val.text()
+		if val.kind != kindString {
+			dto.Kind = val.kind.String()
+		}
+	}
+	if fields&ListTimes != 0 {
+		dto.ExpiresAt = val.ExpiresAt
+		dto.CreatedAt = val.CreatedAt
+		dto.UpdatedAt = val.UpdatedAt
+		dto.LastAccessedAt = val.lastAccessed()
+	}
+	if fields&ListViews != 0 {
+		dto.Views = val.Views.Load()
+	}
+	if fields&ListVersion != 0 {
+		dto.Version = val.Version
+	}
+	if fields&ListProvenance != 0 {
+		dto.Provenance = val.Provenance.clone()
+	}
+	if fields&ListTags != 0 {
+		dto.Tags = val.tags
+	}
+	if fields&ListMeta != 0 {
+		dto.Meta = maps.Clone(val.meta)
+	}
+	if fields&ListSize != 0 {
+		dto.Size = val.size
+	}
+	return dto
+}
+
+// ModifiedSince возвращает ключи, записанные строго после t (по UpdatedAt), отсортированные
+// по времени записи. Истекшие элементы не возвращаются.
+//
+// Отметка для следующего инкрементального прохода - время, а не ключ: сохраните s.Now()
+// перед вызовом и передайте его в следующий вызов. Запись в тот же тик часов, что и отметка,
+// следующий проход пропустит, поэтому с WithCoarseClock отметку сдвигают назад на разрешение
+// часов и пропускают повторы.
+//
+// Удаления и истечения здесь не видны: ключ просто пропадает из выборки. Синхронизировать
+// удаления по ModifiedSince нельзя - для этого есть Watch, Subscribe и WithSink.
+func (s *Store) ModifiedSince(t time.Time) []string {
+	type modified struct {
+		key       string
+		updatedAt time.Time
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	found := make([]modified, 0)
+	for rawKey, item := range s.data {
+		if !item.UpdatedAt.After(t) {
+			continue
+		}
+		key, ok := s.userKey(rawKey)
+		if !ok {
+			continue
+		}
+		if !item.ExpiresAt.IsZero() && now.After(item.ExpiresAt) {
+			continue
+		}
+		found = append(found, modified{key: key, updatedAt: item.UpdatedAt})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].updatedAt.Equal(found[j].updatedAt) {
+			return found[i].key < found[j].key
+		}
+		return found[i].updatedAt.Before(found[j].updatedAt)
+	})
+
+	keys := make([]string, len(found))
+	for i, m := range found {
+		keys[i] = m.key
+	}
+	return keys
+}
+
 // Cleanup периодически очищает хранилище от просроченных элементов.
 // +new: перепишу Cleanup, добавлю отмену по контексту и тикер вместо sleep
 func (s *Store) Cleanup(ctx context.Context, cleanTicker *time.Ticker) {
+	s.cleanupLoop(ctx, realTicker{cleanTicker})
+}
+
+// CleanupEvery как Cleanup, но тикер с периодом interval берётся из Clock хранилища (WithClock),
+// так что с ManualClock очисткой управляет Advance.
+func (s *Store) CleanupEvery(ctx context.Context, interval time.Duration) {
+	s.cleanupLoop(ctx, s.cfg.clock.NewTicker(interval))
+}
+
+// defaultCleanupInterval - период RunCleanup без WithAdaptiveCleanup
+const defaultCleanupInterval = time.Second
+
+// WithAdaptiveCleanup задаёт границы периода очистки для RunCleanup: если проход удаляет много
+// (четверть ключей и больше), период вдвое сокращается до minInterval, если ничего не удаляет -
+// вдвое растёт до maxInterval. minInterval == maxInterval - фиксированный период.
+func WithAdaptiveCleanup(minInterval, maxInterval time.Duration) Option {
+	return func(c *config) {
+		c.cleanupMin = minInterval
+		c.cleanupMax = maxInterval
+	}
+}
+
+// RunCleanup запускает очистку, которая подстраивает период под плотность истечения
+// в границах WithAdaptiveCleanup (по умолчанию раз в секунду), до отмены ctx.
+// Тикер берётся из Clock хранилища.
+func (s *Store) RunCleanup(ctx context.Context) {
+	minInterval, maxInterval := s.cfg.cleanupMin, s.cfg.cleanupMax
+	if minInterval <= 0 || maxInterval <= 0 {
+		minInterval, maxInterval = defaultCleanupInterval, defaultCleanupInterval
+	}
+	maxInterval = max(maxInterval, minInterval)
+
+	interval := maxInterval
+	ticker := s.cfg.clock.NewTicker(interval)
+	defer func() { ticker.Stop() }()
+
+	s.cfg.logger.Info("store: adaptive cleanup started", "min", minInterval, "max", maxInterval)
+	for {
+		select {
+		case <-ctx.Done():
+			s.cfg.logger.Info("store: cleanup stopped", "reason", ctx.Err())
+			return
+		case <-s.life.done:
+			s.cfg.logger.Info("store: cleanup stopped", "reason", ErrClosed)
+			return
+		case <-ticker.C():
+		}
+
+		removed := s.cleanupPass(ctx)
+		next := nextCleanupInterval(interval, removed, s.Size(), minInterval, maxInterval)
+		if next != interval {
+			s.cfg.logger.Debug("store: cleanup interval changed", "from", interval, "to", next, "removed", removed)
+			interval = next
+			ticker.Stop()
+			ticker = s.cfg.clock.NewTicker(interval)
+		}
+	}
+}
+
+// nextCleanupInterval подстраивает период очистки: left - сколько ключей осталось после прохода
+func nextCleanupInterval(cur time.Duration, removed, left int, minInterval, maxInterval time.Duration) time.Duration {
+	switch {
+	case removed == 0:
+		return min(cur*2, maxInterval)
+	case removed*4 >= removed+left:
+		return max(cur/2, minInterval)
+	}
+	return cur
+}
+
+func (s *Store) cleanupLoop(ctx context.Context, cleanTicker Ticker) {
 	defer cleanTicker.Stop()
 
+	s.cfg.logger.Info("store: cleanup started")
 	for {
 		select {
 		case <-ctx.Done():
+			s.cfg.logger.Info("store: cleanup stopped", "reason", ctx.Err())
 			return
-		case <-cleanTicker.C:
-			expiredKeys := []string{}
-
-			now := time.Now()
-			s.mu.RLock() // +new: делаем Rlock, для сбора истекших ключей
-			for k, item := range s.data {
-				if !item.ExpiresAt.IsZero() && now.After(item.ExpiresAt) {
-					expiredKeys = append(expiredKeys, k)
-				}
-			}
+		case <-s.life.done:
+			s.cfg.logger.Info("store: cleanup stopped", "reason", ErrClosed)
+			return
+		case <-cleanTicker.C():
+			s.cleanupPass(ctx)
+		}
+	}
+}
 
-			s.mu.RUnlock()
+// CleanupNow синхронно выполняет один проход очистки без фоновой Cleanup и возвращает,
+// сколько истекших элементов удалено. Отмена ctx прерывает проход, тогда возвращается ctx.Err()
+// и число элементов, удалённых до отмены.
+func (s *Store) CleanupNow(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	removed := s.cleanupPass(ctx)
+	return removed, ctx.Err()
+}
+
+// cleanupPass - один проход очистки, возвращает количество удаленных элементов
+func (s *Store) cleanupPass(ctx context.Context) int {
+	start := s.now()
+	removed := s.sweep(ctx)
+	s.janitor.record(removed, start, s.now())
+	if s.cfg.deterministic && s.cfg.invariantInterval() > 0 {
+		s.reportInvariants()
+	}
+	return removed
+}
+
+// sweep - тело прохода очистки
+func (s *Store) sweep(ctx context.Context) int {
+	if t, ok := s.startOp(opCleanup, ""); ok {
+		defer t.stop()
+	}
+	defer s.refreshBloom()
+
+	s.flushViews()
+	now := s.now()
+	s.decayViews(now)
+	s.activateScheduled(now)
+	s.pruneIdle(ctx, now)
+	if s.cfg.cleanupBatch > 0 {
+		return s.cleanupIncremental(ctx, now)
+	}
+
+	expiredKeys := []string{}
+	s.mu.RLock() // +new: делаем Rlock, для сбора истекших ключей
+	for k, item := range s.data {
+		if item.expiredAt(now) {
+			expiredKeys = append(expiredKeys, k)
+		}
+	}
+
+	s.mu.RUnlock()
+
+	if ctx.Err() != nil {
+		return 0 // отменили, пока собирали ключи
+	}
+	if len(expiredKeys) == 0 { // +new: если нет истекших ключей - выходим
+		s.pruneMisses(now)
+		s.pruneTombstones(now)
+		s.pruneLongKeys()
+		s.cfg.logger.Debug("store: cleanup pass", "removed", 0)
+		return 0
+	}
 
-			if len(expiredKeys) == 0 { // +new: если нет истекших ключей - выходим
-				continue
+	removed := 0
+	var gone []expiredItem
+	s.mu.Lock() // +new: ставим лок для удаления всех ключей, которые мы собрали
+	for _, v := range expiredKeys {
+		// ключ могли перезаписать между RUnlock и Lock, удаляем только если он всё ещё истек
+		if item, ok := s.data[v]; ok && item.expiredAt(now) {
+			gone = s.expireLocked(v, item, gone)
+			removed++
+		}
+	}
+	s.mu.Unlock()
+	s.expired(gone)
+	s.pruneMisses(now)
+	s.pruneTombstones(now)
+	s.pruneLongKeys()
+	s.cfg.logger.Debug("store: cleanup pass", "removed", removed, "took", s.now().Sub(now))
+	return removed
+}
+
+// WithIncrementalCleanup включает пошаговую очистку для больших хранилищ: вместо сбора всех
+// истекших ключей под одной RLock и удаления под одной длинной Lock проход проверяет ключи
+// шагами по batch и отпускает блокировку между шагами. budget ограничивает время прохода
+// (0 - без ограничения): не успевший проход продолжится со следующего ключа в следующий раз.
+// Список ключей для обхода копируется под RLock один раз на полный круг.
+func WithIncrementalCleanup(batch int, budget time.Duration) Option {
+	return func(c *config) {
+		c.cleanupBatch = batch
+		c.cleanupBudget = budget
+	}
+}
+
+// cleanupCursor - место, где остановился пошаговый проход очистки
+type cleanupCursor struct {
+	mu   sync.Mutex
+	keys []string // ключи круга обхода, nil - круг закончен
+	pos  int
+}
+
+// cleanupIncremental - проход очистки для WithIncrementalCleanup
+func (s *Store) cleanupIncremental(ctx context.Context, now time.Time) int {
+	c := &s.cleanupCur
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.keys == nil {
+		s.mu.RLock()
+		c.keys = make([]string, 0, len(s.data))
+		for k := range s.data {
+			c.keys = append(c.keys, k)
+		}
+		s.mu.RUnlock()
+		c.pos = 0
+	}
+
+	removed := 0
+	expired := make([]string, 0, s.cfg.cleanupBatch)
+	for c.pos < len(c.keys) && ctx.Err() == nil {
+		batch := c.keys[c.pos:min(c.pos+s.cfg.cleanupBatch, len(c.keys))]
+		c.pos += len(batch)
+
+		expired = expired[:0]
+		s.mu.RLock()
+		for _, k := range batch {
+			if item, ok := s.data[k]; ok && item.expiredAt(now) {
+				expired = append(expired, k)
 			}
+		}
+		s.mu.RUnlock()
 
-			s.mu.Lock() // +new: ставим лок для удаления всех ключей, которые мы собрали
-			for _, v := range expiredKeys {
-				delete(s.data, v)
+		if len(expired) > 0 {
+			var gone []expiredItem
+			s.mu.Lock()
+			for _, k := range expired {
+				// ключ могли перезаписать между RUnlock и Lock
+				if item, ok := s.data[k]; ok && item.expiredAt(now) {
+					gone = s.expireLocked(k, item, gone)
+					removed++
+				}
 			}
 			s.mu.Unlock()
+			s.expired(gone)
+		}
+
+		if s.cfg.cleanupBudget > 0 && s.now().Sub(now) >= s.cfg.cleanupBudget {
+			break
 		}
 	}
+	if c.pos >= len(c.keys) {
+		c.keys = nil
+	}
+	s.pruneMisses(now)
+	s.pruneTombstones(now)
+	s.pruneLongKeys()
+	s.cfg.logger.Debug("store: incremental cleanup pass", "removed", removed, "took", s.now().Sub(now))
+	return removed
 }
 
-// Reset очищает всё хранилище
-// +new: добавил очистку ключей из стека тоже
+// Reset очищает всё хранилище. С WithResetGuard ничего не делает: очистка под защитой
+// идёт через ResetAll с подтверждением
 func (s *Store) Reset() {
-	s.stackMutex.Lock()
-	s.lastKeys = make([]string, 0, 30)
-	s.stackMutex.Unlock()
+	if _, err := s.ResetAll(); err != nil && !errors.Is(err, ErrClosed) {
+		s.cfg.logger.Error("store: reset refused", "err", err)
+	}
+}
 
+// ResetAll очищает всё хранилище, как Reset, и возвращает число удалённых ключей. opts
+// проверяются под той же блокировкой, что и очистка, см. WithResetExpectedSize.
+// +new: добавил очистку ключей из стека тоже
+func (s *Store) ResetAll(opts ...ResetOption) (int, error) {
+	return s.resetAll(writeOpts{}, opts)
+}
+
+// resetAll - ResetAll с параметрами записи w, из w используются replicated и local
+func (s *Store) resetAll(w writeOpts, opts []ResetOption) (int, error) {
+	if err := s.enterWrite(w); err != nil {
+		return 0, err
+	}
+	defer s.leave()
+
+	queued := !w.local && s.sinkReserve()
 	s.mu.Lock()
+	removed := len(s.data)
+	if err := s.checkReset(removed, opts); err != nil {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return 0, err
+	}
+	s.recent.reset()
+	if s.watchers.n.Load() > 0 {
+		for key, item := range s.data {
+			s.notifyLocked(EventDelete, key, "", item.text(), item.attrs)
+		}
+	}
 	s.data = make(map[string]*Item)
+	s.length.Store(0)
+	if s.mirror != nil {
+		s.mirror.Clear()
+	}
+	s.history, s.pins, s.tombs = nil, nil, nil
+	s.dependents, s.groups = nil, nil
+	s.priorityCount = [priorityLevels]int{}
+	s.resetBloomLocked()
+	s.resetIndexLocked()
+	if s.ring != nil {
+		s.ring.reset()
+	}
+	if s.interned != nil {
+		s.interned = newInternTable(s.cfg.internMax)
+	}
+	s.memUsed, s.compressionSaved = 0, 0
+	s.signalRoomLocked()
+	for _, ns := range s.namespaces {
+		ns.members, ns.memUsed = make(map[string]struct{}), 0
+	}
+	if queued {
+		s.sink.appendLocked(Mutation{Type: EventFlush, At: s.now()})
+	}
 	s.mu.Unlock()
-
+	s.resetSchedule()
+	s.audit(AuditReset, "", w)
+	s.cfg.logger.Info("store: reset", "removed", removed)
+	return removed, nil
 }
 
-// сохраняем элемент
+// сохраняем ключ в журнал последних записей
 func (s *Store) push(value string) {
-	// +new: соблюдаем условие, что в стеке должно быть 30 последних элементов
-	s.stackMutex.Lock()
-
-	s.lastKeys = append(s.lastKeys, value)
-	if len(s.lastKeys) > 30 {
-		s.lastKeys = s.lastKeys[1:]
-	}
-
-	s.stackMutex.Unlock()
+	// +new: соблюдаем условие, что в журнале должно быть N последних элементов
+	s.recent.push(recentEntry{key: value, at: s.now()})
 }
 
 // удаляем верхний элемент
 func (s *Store) pop() {
-	s.stackMutex.Lock()
-
-	if len(s.lastKeys) == 0 {
-		s.stackMutex.Unlock()
-		return
-	}
-
-	s.lastKeys = s.lastKeys[:len(s.lastKeys)-1]
-	s.stackMutex.Unlock()
-
+	s.recent.pop()
 }
 
 // получаем верхний элемент
 func (s *Store) top() string {
-	s.stackMutex.Lock()
-	defer s.stackMutex.Unlock()
-
-	if len(s.lastKeys) == 0 {
-		return ""
-	}
-
-	return s.lastKeys[len(s.lastKeys)-1] // +new: берем длину от lastKeys, а не от data
+	e, _ := s.recent.top()
+	return e.key
 }
@@ -1,3 +1,8 @@
+//go:build ignore
+
+// Исходный код задания, оставлен для сравнения и в сборку не входит: его имена
+// совпадают с именами пакета.
+//
 // Задача найти все ошибки которые тут есть
 // Код представляет собой некое хранилище в памяти
 // data - основные данные ключ-значение
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrEmptyKey - запись с пустым ключом в строгом режиме.
+	ErrEmptyKey = errors.New("store: empty key")
+	// ErrNegativeTTL - запись с отрицательным TTL в строгом режиме.
+	ErrNegativeTTL = errors.New("store: negative ttl")
+)
+
+// StrictMode определяет, что делать с некорректным использованием хранилища.
+type StrictMode int
+
+const (
+	// StrictOff - поведение по умолчанию: пустой ключ сохраняется, отрицательный TTL значит "без истечения".
+	StrictOff StrictMode = iota
+	// StrictError - некорректная запись отклоняется и метод возвращает ошибку
+	// (методы с bool-результатом возвращают false).
+	StrictError
+	// StrictPanic - некорректная запись, в том числе запись после Close, вызывает панику
+	// с описанием ошибки.
+	StrictPanic
+)
+
+// WithStrict включает строгий режим проверки записей. Без него ошибки вызывающего кода
+// (пустой ключ, отрицательный TTL) молча принимаются и потом превращаются в мусор в кеше.
+func WithStrict(mode StrictMode) Option {
+	return func(c *config) {
+		c.strict = mode
+	}
+}
+
+// checkWrite проверяет ключ по политике ключей и аргументы записи по правилам строгого режима
+func (s *Store) checkWrite(key string, ttl time.Duration) error {
+	if err := s.checkKey(key); err != nil {
+		return err
+	}
+	if s.cfg.strict == StrictOff {
+		return nil
+	}
+
+	var err error
+	switch {
+	case key == "":
+		err = ErrEmptyKey
+	case ttl < 0 && ttl != NoExpiration:
+		err = ErrNegativeTTL
+	default:
+		return nil
+	}
+	return s.misuse(err)
+}
+
+// misuse сообщает о некорректном использовании в зависимости от режима
+func (s *Store) misuse(err error) error {
+	switch s.cfg.strict {
+	case StrictPanic:
+		panic(err)
+	case StrictError:
+		return err
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// wantPanic проверяет, что fn паникует ошибкой want
+func wantPanic(t *testing.T, want error, fn func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		err, _ := recover().(error)
+		if !errors.Is(err, want) {
+			t.Errorf("panic = %v, want %v", err, want)
+		}
+	}()
+	fn()
+}
+
+func TestStrictWriteAfterClose(t *testing.T) {
+	s, err := New(WithStrict(StrictError))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close(context.Background())
+	if err := s.Set("k", "v", 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("StrictError Set after Close = %v, want ErrClosed", err)
+	}
+
+	s, err = New(WithStrict(StrictPanic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close(context.Background())
+	wantPanic(t, ErrClosed, func() { s.Set("k", "v", 0) })
+	wantPanic(t, ErrClosed, func() { s.Delete("k") })
+	wantPanic(t, ErrClosed, func() { s.Txn(func(tx *Txn) error { return nil }) })
+}
+
+func TestStrictPanicInternalWritesAfterClose(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s, err := New(WithStrict(StrictPanic), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetAt("scheduled", "v", clock.Now().Add(time.Minute), 0); err != nil {
+		t.Fatal(err)
+	}
+	ls := NewLoadingStore(s, func(ctx context.Context, key string) (string, time.Duration, error) {
+		return "loaded", 0, nil
+	})
+	s.Close(context.Background())
+	clock.Advance(time.Minute)
+
+	// чтение после Close работает, а записи самого хранилища не паникуют
+	wantMissing(t, s, "scheduled")
+	if _, err := ls.Get(context.Background(), "k"); !errors.Is(err, ErrClosed) {
+		t.Errorf("LoadingStore.Get after Close = %v, want ErrClosed", err)
+	}
+}
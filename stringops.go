@@ -0,0 +1,53 @@
+package store
+
+// Append дописывает suffix к строковому значению key и возвращает новую длину. Отсутствующий
+// ключ создаётся с TTL по умолчанию (WithDefaultTTL), у существующего TTL сохраняется.
+// Если ключ хранит не строку, возвращается ErrWrongType, лимит WithMaxValueSize проверяется
+// для итоговой строки.
+//
+// Как и SetBit, Append копирует строку целиком, поэтому накопление логов подходит для
+// строк в килобайты, а не в сотни мегабайт. Сжатое значение (WithCompression) после Append
+// хранится несжатым до следующего Set: сжимать под блокировкой на запись слишком дорого.
+func (s *Store) Append(key, suffix string) (int, error) {
+	var n int
+	err := s.update(key, kindString, func(next *Item) bool {
+		cur := next.plain()
+		n = len(cur) + len(suffix)
+		if suffix == "" {
+			return false
+		}
+		next.Value, next.rawSize = cur+suffix, 0
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// GetRange возвращает байты строкового значения key с start по end включительно, как GETRANGE
+// в Redis: отрицательные индексы считаются от конца строки (-1 - последний байт), выход за
+// границы обрезается. Вызов считается чтением, как Get. false - ключа нет, он истёк или
+// хранит не строку; пустой диапазон существующего ключа - "" и true.
+//
+// Результат - подстрока значения и память не выделяет, кроме сжатых значений, которые
+// распаковываются целиком.
+func (s *Store) GetRange(key string, start, end int) (string, bool) {
+	item, ok := s.view(key, kindString)
+	if !ok {
+		return "", false
+	}
+	v := item.plain()
+	n := len(v)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = n + end
+	}
+	end = min(end, n-1)
+	if start > end {
+		return "", true
+	}
+	return v[start : end+1], true
+}
@@ -0,0 +1,239 @@
+package teststore
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Op - записанная операция Recorder: вызов и его результаты.
+type Op struct {
+	Call
+	Results []any // результаты в порядке сигнатуры, ошибка - error
+}
+
+// Recorder - store.Cache, который пишет журнал операций обёрнутого Cache вместе с результатами.
+// С хранилищем в режиме store.WithDeterministic журнал воспроизводится через Replay на
+// эталонной модели или другой реализации, а расхождение показывает первую неверную операцию:
+//
+//	rec := teststore.Record(store.NewStore(store.WithClock(clock), store.WithDeterministic()))
+//	// ... случайные операции из property-теста через rec
+//	if i, err := teststore.Replay(model, rec.Ops()); err != nil {
+//		t.Fatalf("operation %d: %v", i, err)
+//	}
+//
+// Время между операциями журнал не хранит: если тест переводит часы, он записывает это сам
+// или делает модель на тех же часах.
+type Recorder struct {
+	next store.Cache
+
+	mu  sync.Mutex
+	ops []Op
+}
+
+var _ store.Cache = (*Recorder)(nil)
+
+// Record оборачивает next.
+func Record(next store.Cache) *Recorder {
+	return &Recorder{next: next}
+}
+
+// Ops возвращает журнал по порядку.
+func (r *Recorder) Ops() []Op {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.ops)
+}
+
+// Reset очищает журнал.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = nil
+}
+
+// Unwrap возвращает обёрнутый Cache.
+func (r *Recorder) Unwrap() store.Cache { return r.next }
+
+// log добавляет операцию в журнал
+func (r *Recorder) log(method, key string, args []any, results ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = append(r.ops, Op{Call: Call{Method: method, Key: key, Args: args}, Results: results})
+}
+
+// Replay выполняет ops на c по порядку и сравнивает результаты с записанными.
+// Ошибки сравниваются по тексту, результаты Stats не сравниваются: счётчики у разных
+// реализаций законно отличаются. При расхождении возвращает номер операции и ошибку,
+// иначе len(ops) и nil.
+func Replay(c store.Cache, ops []Op) (int, error) {
+	for i, op := range ops {
+		got, err := apply(c, op.Call)
+		if err != nil {
+			return i, err
+		}
+		if op.Method == "Stats" {
+			continue
+		}
+		if !sameResults(got, op.Results) {
+			return i, fmt.Errorf("teststore: %s(%q): got %v, want %v", op.Method, op.Key, got, op.Results)
+		}
+	}
+	return len(ops), nil
+}
+
+// apply вызывает метод call на c и возвращает результаты
+func apply(c store.Cache, call Call) ([]any, error) {
+	arg := func(i int) any {
+		if i < len(call.Args) {
+			return call.Args[i]
+		}
+		return nil
+	}
+	str := func(i int) string { v, _ := arg(i).(string); return v }
+	dur := func(i int) time.Duration { v, _ := arg(i).(time.Duration); return v }
+
+	switch call.Method {
+	case "Get":
+		v, ok := c.Get(call.Key)
+		return []any{v, ok}, nil
+	case "Set":
+		return []any{c.Set(call.Key, str(0), dur(1))}, nil
+	case "Delete":
+		c.Delete(call.Key)
+		return nil, nil
+	case "SetNX":
+		return []any{c.SetNX(call.Key, str(0), dur(1))}, nil
+	case "SetXX":
+		return []any{c.SetXX(call.Key, str(0), dur(1))}, nil
+	case "CompareAndDelete":
+		return []any{c.CompareAndDelete(call.Key, str(0))}, nil
+	case "IncrBy":
+		delta, _ := arg(0).(int64)
+		n, err := c.IncrBy(call.Key, delta)
+		return []any{n, err}, nil
+	case "TTL":
+		ttl, ok := c.TTL(call.Key)
+		return []any{ttl, ok}, nil
+	case "Expire":
+		return []any{c.Expire(call.Key, dur(0))}, nil
+	case "Keys":
+		return []any{c.Keys(call.Key)}, nil
+	case "Size":
+		return []any{c.Size()}, nil
+	case "Stats":
+		return []any{c.Stats()}, nil
+	}
+	return nil, fmt.Errorf("teststore: unknown method %q", call.Method)
+}
+
+// sameResults сравнивает результаты, ошибки - по тексту
+func sameResults(got, want []any) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		ge, gok := got[i].(error)
+		we, wok := want[i].(error)
+		switch {
+		case gok || wok:
+			if gok != wok || ge.Error() != we.Error() {
+				return false
+			}
+		case got[i] == nil || want[i] == nil:
+			if got[i] != want[i] {
+				return false
+			}
+		case !reflect.DeepEqual(got[i], want[i]):
+			return false
+		}
+	}
+	return true
+}
+
+// Get - store.Cache.Get с записью в журнал.
+func (r *Recorder) Get(key string) (string, bool) {
+	v, ok := r.next.Get(key)
+	r.log("Get", key, nil, v, ok)
+	return v, ok
+}
+
+// Set - store.Cache.Set с записью в журнал.
+func (r *Recorder) Set(key, value string, ttl time.Duration) error {
+	err := r.next.Set(key, value, ttl)
+	r.log("Set", key, []any{value, ttl}, err)
+	return err
+}
+
+// Delete - store.Cache.Delete с записью в журнал.
+func (r *Recorder) Delete(key string) {
+	r.next.Delete(key)
+	r.log("Delete", key, nil)
+}
+
+// SetNX - store.Cache.SetNX с записью в журнал.
+func (r *Recorder) SetNX(key, value string, ttl time.Duration) bool {
+	ok := r.next.SetNX(key, value, ttl)
+	r.log("SetNX", key, []any{value, ttl}, ok)
+	return ok
+}
+
+// SetXX - store.Cache.SetXX с записью в журнал.
+func (r *Recorder) SetXX(key, value string, ttl time.Duration) bool {
+	ok := r.next.SetXX(key, value, ttl)
+	r.log("SetXX", key, []any{value, ttl}, ok)
+	return ok
+}
+
+// CompareAndDelete - store.Cache.CompareAndDelete с записью в журнал.
+func (r *Recorder) CompareAndDelete(key, value string) bool {
+	ok := r.next.CompareAndDelete(key, value)
+	r.log("CompareAndDelete", key, []any{value}, ok)
+	return ok
+}
+
+// IncrBy - store.Cache.IncrBy с записью в журнал.
+func (r *Recorder) IncrBy(key string, delta int64) (int64, error) {
+	n, err := r.next.IncrBy(key, delta)
+	r.log("IncrBy", key, []any{delta}, n, err)
+	return n, err
+}
+
+// TTL - store.Cache.TTL с записью в журнал.
+func (r *Recorder) TTL(key string) (time.Duration, bool) {
+	ttl, ok := r.next.TTL(key)
+	r.log("TTL", key, nil, ttl, ok)
+	return ttl, ok
+}
+
+// Expire - store.Cache.Expire с записью в журнал.
+func (r *Recorder) Expire(key string, ttl time.Duration) bool {
+	ok := r.next.Expire(key, ttl)
+	r.log("Expire", key, []any{ttl}, ok)
+	return ok
+}
+
+// Keys - store.Cache.Keys с записью в журнал, шаблон записывается как ключ.
+func (r *Recorder) Keys(pattern string) []string {
+	keys := r.next.Keys(pattern)
+	r.log("Keys", pattern, nil, slices.Clone(keys))
+	return keys
+}
+
+// Size - store.Cache.Size с записью в журнал.
+func (r *Recorder) Size() int {
+	n := r.next.Size()
+	r.log("Size", "", nil, n)
+	return n
+}
+
+// Stats - store.Cache.Stats с записью в журнал.
+func (r *Recorder) Stats() store.Stats {
+	st := r.next.Stats()
+	r.log("Stats", "", nil, st)
+	return st
+}
@@ -0,0 +1,224 @@
+// Package teststore - подделка store.Cache для юнит-тестов кода, который зависит от кеша.
+//
+// Fake хранит данные в настоящем store.Store на ручных часах (store.ManualClock), так что
+// TTL истекает по Advance или ExpireNow, а не по sleep. Вызовы записываются, их можно
+// проверить через Calls, а ошибки внедряются по имени метода через FailNext и FailAlways.
+package teststore
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	store "github.com/Shk337/test-task-in-memory-cache-golang-senior"
+)
+
+// Call - записанный вызов Fake.
+type Call struct {
+	Method string // имя метода store.Cache: "Get", "Set", ...
+	Key    string // ключ, для Keys - шаблон, для Size и Stats пусто
+	Args   []any  // остальные аргументы в порядке сигнатуры
+}
+
+// Fake реализует store.Cache. Нулевое значение не готово к работе, используйте New.
+type Fake struct {
+	s     *store.Store
+	clock *store.ManualClock
+
+	mu     sync.Mutex
+	calls  []Call
+	next   map[string][]error // ошибки для ближайших вызовов метода, по очереди
+	always map[string]error
+}
+
+var _ store.Cache = (*Fake)(nil)
+
+// New создаёт Fake. opts передаются в store.New поверх ручных часов с началом в
+// 2000-01-01 UTC. Неверные опции - паника, как в store.NewStore.
+func New(opts ...store.Option) *Fake {
+	clock := store.NewManualClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	return &Fake{
+		s:      store.NewStore(append([]store.Option{store.WithClock(clock)}, opts...)...),
+		clock:  clock,
+		next:   make(map[string][]error),
+		always: make(map[string]error),
+	}
+}
+
+// Store возвращает хранилище под подделкой, например для проверки содержимого в обход записи вызовов.
+func (f *Fake) Store() *store.Store { return f.s }
+
+// Clock возвращает часы хранилища.
+func (f *Fake) Clock() *store.ManualClock { return f.clock }
+
+// Advance переводит часы вперёд: ключи с истекшим TTL становятся невидимы.
+func (f *Fake) Advance(d time.Duration) { f.clock.Advance(d) }
+
+// ExpireNow немедленно истекает ключ, как будто его TTL закончился. false - ключа нет.
+func (f *Fake) ExpireNow(key string) bool {
+	if !f.s.Expire(key, time.Nanosecond) {
+		return false
+	}
+	f.clock.Advance(2 * time.Nanosecond) // ключ истекает строго после ExpiresAt
+	return true
+}
+
+// FailNext заставляет следующий вызов method вернуть err. Повторные вызовы ставят ошибки
+// в очередь: каждая срабатывает один раз. Методы без error в результате отдают промах
+// или false, Delete просто не удаляет.
+func (f *Fake) FailNext(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next[method] = append(f.next[method], err)
+}
+
+// FailAlways заставляет все вызовы method возвращать err до ClearFailures.
+func (f *Fake) FailAlways(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.always[method] = err
+}
+
+// ClearFailures убирает все внедрённые ошибки.
+func (f *Fake) ClearFailures() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	clear(f.next)
+	clear(f.always)
+}
+
+// Calls возвращает записанные вызовы по порядку.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// CallsTo возвращает записанные вызовы метода method.
+func (f *Fake) CallsTo(method string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []Call
+	for _, c := range f.calls {
+		if c.Method == method {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// ResetCalls забывает записанные вызовы.
+func (f *Fake) ResetCalls() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+// record записывает вызов и возвращает внедрённую ошибку, если она есть
+func (f *Fake) record(method, key string, args ...any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, Call{Method: method, Key: key, Args: args})
+	if q := f.next[method]; len(q) > 0 {
+		f.next[method] = q[1:]
+		return q[0]
+	}
+	return f.always[method]
+}
+
+// Get - store.Store.Get с записью вызова и внедрёнными ошибками.
+func (f *Fake) Get(key string) (string, bool) {
+	if f.record("Get", key) != nil {
+		return "", false
+	}
+	return f.s.Get(key)
+}
+
+// Set - store.Store.Set с записью вызова и внедрёнными ошибками.
+func (f *Fake) Set(key, value string, ttl time.Duration) error {
+	if err := f.record("Set", key, value, ttl); err != nil {
+		return err
+	}
+	return f.s.Set(key, value, ttl)
+}
+
+// Delete - store.Store.Delete с записью вызова и внедрёнными ошибками.
+func (f *Fake) Delete(key string) {
+	if f.record("Delete", key) != nil {
+		return
+	}
+	f.s.Delete(key)
+}
+
+// SetNX - store.Store.SetNX с записью вызова и внедрёнными ошибками.
+func (f *Fake) SetNX(key, value string, ttl time.Duration) bool {
+	if f.record("SetNX", key, value, ttl) != nil {
+		return false
+	}
+	return f.s.SetNX(key, value, ttl)
+}
+
+// SetXX - store.Store.SetXX с записью вызова и внедрёнными ошибками.
+func (f *Fake) SetXX(key, value string, ttl time.Duration) bool {
+	if f.record("SetXX", key, value, ttl) != nil {
+		return false
+	}
+	return f.s.SetXX(key, value, ttl)
+}
+
+// CompareAndDelete - store.Store.CompareAndDelete с записью вызова и внедрёнными ошибками.
+func (f *Fake) CompareAndDelete(key, value string) bool {
+	if f.record("CompareAndDelete", key, value) != nil {
+		return false
+	}
+	return f.s.CompareAndDelete(key, value)
+}
+
+// IncrBy - store.Store.IncrBy с записью вызова и внедрёнными ошибками.
+func (f *Fake) IncrBy(key string, delta int64) (int64, error) {
+	if err := f.record("IncrBy", key, delta); err != nil {
+		return 0, err
+	}
+	return f.s.IncrBy(key, delta)
+}
+
+// TTL - store.Store.TTL с записью вызова и внедрёнными ошибками.
+func (f *Fake) TTL(key string) (time.Duration, bool) {
+	if f.record("TTL", key) != nil {
+		return 0, false
+	}
+	return f.s.TTL(key)
+}
+
+// Expire - store.Store.Expire с записью вызова и внедрёнными ошибками.
+func (f *Fake) Expire(key string, ttl time.Duration) bool {
+	if f.record("Expire", key, ttl) != nil {
+		return false
+	}
+	return f.s.Expire(key, ttl)
+}
+
+// Keys - store.Store.Keys с записью вызова и внедрёнными ошибками.
+func (f *Fake) Keys(pattern string) []string {
+	if f.record("Keys", pattern) != nil {
+		return nil
+	}
+	return f.s.Keys(pattern)
+}
+
+// Size - store.Store.Size с записью вызова и внедрёнными ошибками.
+func (f *Fake) Size() int {
+	if f.record("Size", "") != nil {
+		return 0
+	}
+	return f.s.Size()
+}
+
+// Stats - store.Store.Stats с записью вызова и внедрёнными ошибками.
+func (f *Fake) Stats() store.Stats {
+	if f.record("Stats", "") != nil {
+		return store.Stats{}
+	}
+	return f.s.Stats()
+}
@@ -0,0 +1,287 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Backend - второй уровень для TieredStore: Redis, удаленный сервер или другой Store.
+// client.HTTP и client.RESP реализуют его как есть, Store - через AsBackend.
+type Backend interface {
+	// Get возвращает значение, false если ключа нет.
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Set записывает значение с ttl, ttl == 0 - TTL бэкенда по умолчанию.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Delete удаляет ключи.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// ErrTieredClosed - запись в TieredStore после Close.
+var ErrTieredClosed = errors.New("store: tiered store is closed")
+
+// TieredOption настраивает TieredStore.
+type TieredOption func(*TieredStore)
+
+// WithL1TTL задаёт TTL копии в L1 для значений, прочитанных из L2. Чужие записи в L2
+// до L1 не доходят, так что это верхняя граница устаревания локальной копии.
+// По умолчанию - TTL хранилища L1 по умолчанию (WithDefaultTTL).
+func WithL1TTL(ttl time.Duration) TieredOption {
+	return func(t *TieredStore) {
+		t.l1TTL = ttl
+	}
+}
+
+// WithWriteBehind включает отложенную запись в L2: Set и Delete меняют L1 сразу,
+// а в L2 изменения уходят из фоновой очереди длиной queue. Если очередь заполнена,
+// запись ждёт места (или отмены ctx), изменения не теряются молча.
+// Ошибки L2 в этом режиме только считаются и логируются.
+func WithWriteBehind(queue int) TieredOption {
+	return func(t *TieredStore) {
+		t.queueSize = max(queue, 1)
+	}
+}
+
+// TieredStore - двухуровневый кеш: L1 - локальное хранилище процесса, L2 - общий Backend.
+// Get читает L1, при промахе - L2 и кладет найденное в L1. Запись по умолчанию сквозная:
+// сначала L2, затем L1, так что ошибка L2 не оставляет в L1 значение, которого нет в L2.
+type TieredStore struct {
+	l1 *Store
+	l2 Backend
+
+	l1TTL     time.Duration
+	queueSize int
+
+	queue  chan tieredOp
+	mu     sync.RWMutex // защищает closed и отправку в queue от закрытия
+	closed bool
+	wg     sync.WaitGroup
+
+	l1Hits        atomic.Uint64
+	l2Hits        atomic.Uint64
+	misses        atomic.Uint64
+	l2Errors      atomic.Uint64
+	pendingWrites atomic.Int64
+}
+
+// tieredOp - отложенная операция над L2, del == true - удаление
+type tieredOp struct {
+	key   string
+	value string
+	ttl   time.Duration
+	del   bool
+}
+
+// TieredStats - счетчики двухуровневого кеша.
+type TieredStats struct {
+	L1Hits        uint64 `json:"l1Hits"`
+	L2Hits        uint64 `json:"l2Hits"`
+	Misses        uint64 `json:"misses"`
+	L2Errors      uint64 `json:"l2Errors"`      // ошибок L2, включая отложенные записи
+	PendingWrites int64  `json:"pendingWrites"` // в очереди отложенной записи
+}
+
+// NewTieredStore создаёт двухуровневый кеш. С WithWriteBehind запускается фоновая запись,
+// которую нужно остановить через Close.
+func NewTieredStore(l1 *Store, l2 Backend, opts ...TieredOption) *TieredStore {
+	t := &TieredStore{l1: l1, l2: l2}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.queueSize > 0 {
+		t.queue = make(chan tieredOp, t.queueSize)
+		t.wg.Add(1)
+		go t.writeBehind()
+	}
+	return t
+}
+
+// Get возвращает значение из L1, а при промахе - из L2.
+func (t *TieredStore) Get(ctx context.Context, key string) (string, bool, error) {
+	if value, ok := t.l1.Get(key); ok {
+		t.l1Hits.Add(1)
+		return value, true, nil
+	}
+
+	value, ok, err := t.l2.Get(ctx, key)
+	if err != nil {
+		t.l2Errors.Add(1)
+		return "", false, err
+	}
+	if !ok {
+		t.misses.Add(1)
+		return "", false, nil
+	}
+	t.l2Hits.Add(1)
+	if err := t.l1.Set(key, value, t.l1TTL); err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// Set записывает значение в оба уровня. В L1 копия получает min(ttl, WithL1TTL), если оба заданы.
+func (t *TieredStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	l1TTL := t.l1TTLFor(ttl)
+
+	if t.queue != nil {
+		if err := t.l1.Set(key, value, l1TTL); err != nil {
+			return err
+		}
+		return t.enqueue(ctx, tieredOp{key: key, value: value, ttl: ttl})
+	}
+
+	if err := t.l2.Set(ctx, key, value, ttl); err != nil {
+		t.l2Errors.Add(1)
+		return err
+	}
+	return t.l1.Set(key, value, l1TTL)
+}
+
+// SetSession записывает значение, как Set, и возвращает токен записи для GetSession.
+// С WithWriteBehind запись доходит до L2 позже, и другие процессы увидят её только
+// после этого: чтение своих записей между процессами тогда не гарантируется.
+func (t *TieredStore) SetSession(ctx context.Context, key, value string, ttl time.Duration) (SessionToken, error) {
+	l1TTL := t.l1TTLFor(ttl)
+	if t.queue != nil {
+		tok, err := t.l1.SetWithToken(key, value, l1TTL)
+		if err != nil {
+			return SessionToken{}, err
+		}
+		return tok, t.enqueue(ctx, tieredOp{key: key, value: value, ttl: ttl})
+	}
+
+	if err := t.l2.Set(ctx, key, value, ttl); err != nil {
+		t.l2Errors.Add(1)
+		return SessionToken{}, err
+	}
+	return t.l1.SetWithToken(key, value, l1TTL)
+}
+
+// GetSession - Get, который не отдаёт копию L1 старше записи tok: такая копия могла попасть
+// в L1 этого процесса до того, как сессия записала ключ через другой процесс с тем же L2.
+// Тогда копия удаляется, а значение читается из L2 и снова кладётся в L1. Свежесть копии
+// сравнивается по tok.At, так что расхождение часов процессов сужает гарантию.
+func (t *TieredStore) GetSession(ctx context.Context, key string, tok SessionToken) (string, bool, error) {
+	if meta, ok := t.l1.GetMeta(key); ok && meta.UpdatedAt.Before(tok.At) {
+		t.l1.Delete(key)
+	}
+	return t.Get(ctx, key)
+}
+
+// l1TTLFor - TTL копии в L1 для записи с ttl
+func (t *TieredStore) l1TTLFor(ttl time.Duration) time.Duration {
+	if t.l1TTL > 0 && (ttl <= 0 || t.l1TTL < ttl) {
+		return t.l1TTL
+	}
+	return ttl
+}
+
+// Delete удаляет ключ из обоих уровней.
+func (t *TieredStore) Delete(ctx context.Context, key string) error {
+	t.l1.Delete(key)
+	if t.queue != nil {
+		return t.enqueue(ctx, tieredOp{key: key, del: true})
+	}
+	if err := t.l2.Delete(ctx, key); err != nil {
+		t.l2Errors.Add(1)
+		return err
+	}
+	return nil
+}
+
+// Close дожидается записи очереди в L2 и останавливает фоновую запись. Повторный вызов ничего не делает.
+func (t *TieredStore) Close() error {
+	if t.queue == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+	return nil
+}
+
+// L1 возвращает локальное хранилище.
+func (t *TieredStore) L1() *Store {
+	return t.l1
+}
+
+// Stats возвращает счетчики двухуровневого кеша.
+func (t *TieredStore) Stats() TieredStats {
+	return TieredStats{
+		L1Hits:        t.l1Hits.Load(),
+		L2Hits:        t.l2Hits.Load(),
+		Misses:        t.misses.Load(),
+		L2Errors:      t.l2Errors.Load(),
+		PendingWrites: t.pendingWrites.Load(),
+	}
+}
+
+func (t *TieredStore) enqueue(ctx context.Context, op tieredOp) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return ErrTieredClosed
+	}
+	select {
+	case t.queue <- op:
+		t.pendingWrites.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeBehind переносит очередь в L2, пока её не закроет Close
+func (t *TieredStore) writeBehind() {
+	defer t.wg.Done()
+
+	ctx := context.Background()
+	for op := range t.queue {
+		var err error
+		if op.del {
+			err = t.l2.Delete(ctx, op.key)
+		} else {
+			err = t.l2.Set(ctx, op.key, op.value, op.ttl)
+		}
+		t.pendingWrites.Add(-1)
+		if err != nil {
+			t.l2Errors.Add(1)
+			t.l1.cfg.logger.Error("store: write-behind to L2 failed", "key", op.key, "err", err)
+		}
+	}
+}
+
+// storeBackend - Store как Backend
+type storeBackend struct {
+	s *Store
+}
+
+// AsBackend возвращает s как Backend, например для L2 из общего для нескольких
+// компонентов процесса хранилища.
+func AsBackend(s *Store) Backend {
+	return storeBackend{s: s}
+}
+
+func (b storeBackend) Get(ctx context.Context, key string) (string, bool, error) {
+	return b.s.GetContext(ctx, key)
+}
+
+func (b storeBackend) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return b.s.SetContext(ctx, key, value, ttl)
+}
+
+func (b storeBackend) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := b.s.DeleteContext(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
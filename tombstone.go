@@ -0,0 +1,146 @@
+package store
+
+import "time"
+
+// defaultTombstoneGrace - сколько DeleteSoft хранит удалённое значение, если WithTombstoneGrace не задан
+const defaultTombstoneGrace = 10 * time.Minute
+
+// WithTombstoneGrace задаёт, сколько DeleteSoft хранит удалённое значение для Restore.
+// 0 - по умолчанию, 10 минут.
+func WithTombstoneGrace(d time.Duration) Option {
+	return func(c *config) {
+		c.tombstoneGrace = d
+	}
+}
+
+// tombstone - значение, удалённое DeleteSoft
+type tombstone struct {
+	item  *Item
+	until time.Time // после этого момента Restore не вернёт значение
+}
+
+// DeleteSoft удаляет keys как Delete, но оставляет значения на срок WithTombstoneGrace,
+// в течение которого Restore возвращает ключ. Так ошибочную массовую инвалидацию можно
+// откатить, не дожидаясь, пока кеш наполнится из бэкенда. Для подписчиков, WithSink
+// и журнала аудита это обычное удаление. Возвращает, сколько ключей удалено.
+//
+// Удалённые значения учитываются в памяти процесса, но не в WithMaxMemory, и удаляются
+// проходом Cleanup после истечения срока.
+func (s *Store) DeleteSoft(keys ...string) int {
+	if s.beginWrite(writeOpts{}) != nil {
+		return 0
+	}
+	defer s.endWrite()
+
+	deleted := 0
+	for _, key := range keys {
+		raw := s.skey(key)
+		now := s.now()
+		queued := s.sinkReserve()
+
+		s.mu.Lock()
+		item, ok := s.data[raw]
+		if !ok || item.expiredAt(now) {
+			s.mu.Unlock()
+			s.sinkRelease(queued)
+			continue
+		}
+		s.removeLocked(raw, EventDelete)
+		if s.tombs == nil {
+			s.tombs = make(map[string]tombstone)
+		}
+		s.tombs[raw] = tombstone{item: item, until: now.Add(s.tombstoneGrace())}
+		if queued {
+			s.sinkAppendLocked(EventDelete, raw, nil, now)
+		}
+		s.mu.Unlock()
+
+		s.stats.deletes.Add(1)
+		s.audit(AuditDelete, key, writeOpts{})
+		deleted++
+	}
+	return deleted
+}
+
+// Restore возвращает ключ, удалённый DeleteSoft, с прежними значением, сроком истечения, Views
+// и метаданными; версия выдаётся новая. Запись ключа после DeleteSoft отменяет восстановление.
+// ErrNotFound - ключ не удаляли через DeleteSoft, срок WithTombstoneGrace прошёл, ключ
+// записали заново или значение истекло бы само.
+func (s *Store) Restore(key string) error {
+	if err := s.beginWrite(writeOpts{}); err != nil {
+		return err
+	}
+	defer s.endWrite()
+
+	raw := s.wkey(key)
+	now := s.now()
+	queued := s.sinkReserve()
+
+	s.mu.Lock()
+	t, ok := s.tombs[raw]
+	if ok {
+		delete(s.tombs, raw)
+	}
+	if !ok || now.After(t.until) || t.item.expiredAt(now) {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return ErrNotFound
+	}
+	next := t.item.copyItem()
+	s.putLocked(raw, next)
+	if queued {
+		s.sinkAppendLocked(EventSet, raw, next, now)
+	}
+	s.evictLocked(now, raw)
+	s.mu.Unlock()
+
+	s.stats.sets.Add(1)
+	s.resolveMiss(raw)
+	s.push(raw)
+	s.audit(AuditSet, key, writeOpts{})
+	return nil
+}
+
+// Tombstones возвращает ключи, которые можно вернуть через Restore, и до какого момента.
+func (s *Store) Tombstones() map[string]time.Time {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make(map[string]time.Time, len(s.tombs))
+	for raw, t := range s.tombs {
+		if now.After(t.until) || t.item.expiredAt(now) {
+			continue
+		}
+		if key, ok := s.userKey(raw); ok {
+			res[key] = t.until
+		}
+	}
+	return res
+}
+
+// tombstoneGrace - срок хранения удалённых DeleteSoft значений
+func (s *Store) tombstoneGrace() time.Duration {
+	if s.cfg.tombstoneGrace > 0 {
+		return s.cfg.tombstoneGrace
+	}
+	return defaultTombstoneGrace
+}
+
+// pruneTombstones удаляет значения DeleteSoft, которые уже нельзя вернуть, вызывается из Cleanup
+func (s *Store) pruneTombstones(now time.Time) {
+	s.mu.RLock()
+	n := len(s.tombs)
+	s.mu.RUnlock()
+	if n == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	for raw, t := range s.tombs {
+		if now.After(t.until) || t.item.expiredAt(now) {
+			delete(s.tombs, raw)
+		}
+	}
+	s.mu.Unlock()
+}
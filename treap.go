@@ -0,0 +1,86 @@
+package store
+
+// tnode - узел неизменяемого декартова дерева (treap). Изменение копирует только путь
+// от корня до места вставки или удаления, старый корень остаётся целым. Поэтому
+// элемент с таким деревом, как и остальные элементы, можно читать после RUnlock,
+// а запись стоит O(log n), а не копию всей коллекции
+type tnode[K, V any] struct {
+	key         K
+	val         V
+	prio        uint64 // ключи упорядочены как в дереве поиска, приоритеты - как в куче
+	left, right *tnode[K, V]
+}
+
+// tsplit делит дерево на ключи меньше k и остальные, с inclusive - на ключи <= k и остальные
+func tsplit[K, V any](n *tnode[K, V], k K, cmp func(a, b K) int, inclusive bool) (l, r *tnode[K, V]) {
+	if n == nil {
+		return nil, nil
+	}
+	cp := *n
+	if c := cmp(n.key, k); c < 0 || (inclusive && c == 0) {
+		cp.right, r = tsplit(n.right, k, cmp, inclusive)
+		return &cp, r
+	}
+	l, cp.left = tsplit(n.left, k, cmp, inclusive)
+	return l, &cp
+}
+
+// tmerge склеивает деревья, все ключи a меньше ключей b
+func tmerge[K, V any](a, b *tnode[K, V]) *tnode[K, V] {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.prio > b.prio:
+		cp := *a
+		cp.right = tmerge(a.right, b)
+		return &cp
+	default:
+		cp := *b
+		cp.left = tmerge(a, b.left)
+		return &cp
+	}
+}
+
+// tput возвращает дерево, в котором k имеет значение v
+func tput[K, V any](n *tnode[K, V], k K, v V, prio uint64, cmp func(a, b K) int) *tnode[K, V] {
+	l, rest := tsplit(n, k, cmp, false)
+	_, r := tsplit(rest, k, cmp, true)
+	return tmerge(tmerge(l, &tnode[K, V]{key: k, val: v, prio: prio}), r)
+}
+
+// tdel возвращает дерево без ключа k
+func tdel[K, V any](n *tnode[K, V], k K, cmp func(a, b K) int) *tnode[K, V] {
+	l, rest := tsplit(n, k, cmp, false)
+	_, r := tsplit(rest, k, cmp, true)
+	return tmerge(l, r)
+}
+
+func tget[K, V any](n *tnode[K, V], k K, cmp func(a, b K) int) (V, bool) {
+	for n != nil {
+		switch c := cmp(k, n.key); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n.val, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// tascend обходит ключи >= from по возрастанию, пока fn возвращает true
+func tascend[K, V any](n *tnode[K, V], from K, cmp func(a, b K) int, fn func(k K, v V) bool) bool {
+	if n == nil {
+		return true
+	}
+	if cmp(n.key, from) >= 0 {
+		if !tascend(n.left, from, cmp, fn) || !fn(n.key, n.val) {
+			return false
+		}
+	}
+	return tascend(n.right, from, cmp, fn)
+}
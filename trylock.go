@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrBusy возвращают TryGet и TrySet, если хранилище занято дольше, чем они готовы ждать.
+var ErrBusy = errors.New("store: store is busy")
+
+// пауза между попытками захвата блокировки в TryGet и TrySet, удваивается до lockRetryMax
+const (
+	lockRetryMin = 20 * time.Microsecond
+	lockRetryMax = time.Millisecond
+)
+
+// TryGet - Get для путей, где задержка важнее попадания: если хранилище надолго занято
+// записью (ReplaceAll, Reset, большой проход Cleanup), TryGet не ждёт её, а возвращает ErrBusy,
+// и вызывающий может сходить в бэкенд или ответить без кеша. Ждать блокировку можно до
+// дедлайна или отмены ctx, ctx без них (context.Background()) - не ждать вовсе. Уже отменённый
+// ctx возвращает ctx.Err(). Чужой записи WithMissDedup TryGet не ждёт.
+//
+// С WithEngine(EngineSyncMap) чтения блокировку не берут, и ErrBusy не бывает.
+func (s *Store) TryGet(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	if t, ok := s.startOp(opGet, key); ok {
+		defer t.stop()
+	}
+	userKey := key
+	key = s.skey(key)
+
+	s.activateKey(key)
+	s.recordAccess(key)
+	var (
+		item *Item
+		ok   bool
+	)
+	switch {
+	case s.bloomAbsent(key):
+	case s.mirror != nil:
+		item, ok = s.loadItem(key)
+	default:
+		if err := s.mu.rlockContext(ctx); err != nil {
+			return "", false, err
+		}
+		item, ok = s.data[key]
+		s.mu.RUnlock()
+		if !ok {
+			s.bloomMiss()
+		}
+	}
+	item, ok = s.readItem(key, item, ok)
+	s.countPrefix(userKey, ok)
+	if !ok {
+		return "", false, nil
+	}
+	return item.text(), true, nil
+}
+
+// TrySet - Set, который возвращает ErrBusy, если блокировку на запись или место в очереди
+// WithWriteConcurrency не удалось взять до дедлайна или отмены ctx, см. TryGet.
+// Значение тогда не записано. Автор записи
+// для журнала аудита и атрибуты запроса берутся из ctx, см. WithActor и WithAttrs.
+func (s *Store) TrySet(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w := ctxOpts(ctx)
+	w.lockCtx = ctx
+	return s.set(key, value, ttl, w)
+}
+
+// lockWrite берёт s.mu на запись: без ctx - как Lock, с ctx - не дольше ctx, см. TrySet
+func (s *Store) lockWrite(ctx context.Context) error {
+	if ctx == nil {
+		s.mu.Lock()
+		return nil
+	}
+	return s.mu.lockContext(ctx)
+}
+
+// lockContext - Lock, который ждёт не дольше ctx и тогда возвращает ErrBusy
+func (m *waitMutex) lockContext(ctx context.Context) error {
+	if err := m.acquire(ctx, m.RWMutex.TryLock, m.write); err != nil {
+		return err
+	}
+	m.held()
+	return nil
+}
+
+// rlockContext - RLock, который ждёт не дольше ctx и тогда возвращает ErrBusy
+func (m *waitMutex) rlockContext(ctx context.Context) error {
+	return m.acquire(ctx, m.RWMutex.TryRLock, m.read)
+}
+
+// acquire повторяет try с растущей паузой, пока он не удастся или не завершится ctx.
+// У sync.RWMutex нет захвата с таймаутом, а ожидание в пробах не ставит нас в очередь
+// за блокировкой и не задерживает читателей, пока запись ждёт
+func (m *waitMutex) acquire(ctx context.Context, try func() bool, h *histogram) error {
+	if try() {
+		if h != nil {
+			h.observe(0)
+		}
+		return nil
+	}
+	if ctx.Done() == nil {
+		return ErrBusy // ctx без дедлайна и отмены - не ждём
+	}
+
+	start := time.Now()
+	pause := lockRetryMin
+	timer := time.NewTimer(pause)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ErrBusy
+		case <-timer.C:
+		}
+		if try() {
+			if h != nil {
+				h.since(start)
+			}
+			return nil
+		}
+		pause = min(pause*2, lockRetryMax)
+		timer.Reset(pause)
+	}
+}
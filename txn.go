@@ -0,0 +1,194 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTxnConflict - ключ, прочитанный в транзакции, изменился до её фиксации, см. Store.Txn.
+var ErrTxnConflict = errors.New("store: transaction conflict")
+
+// Txn - транзакция над несколькими ключами. Get читает хранилище и запоминает прочитанное,
+// Set и Delete только копятся в транзакции, их видят последующие Get этой же транзакции.
+// Txn не безопасна для конкурентного использования и не используется после возврата fn.
+type Txn struct {
+	s      *Store
+	reads  map[string]*Item    // элемент ключа хранения на момент первого чтения, nil - ключа не было
+	writes map[string]txnWrite // отложенные записи по ключу хранения
+	order  []string            // ключи writes в порядке первой записи
+}
+
+// txnWrite - отложенная запись транзакции, item == nil - удаление
+type txnWrite struct {
+	item *Item
+	ttl  time.Duration
+}
+
+// Txn выполняет fn и атомарно фиксирует все её Set и Delete: другие операции хранилища
+// видят либо все изменения транзакции, либо ни одного. Если fn вернула ошибку, изменения
+// отбрасываются и Txn возвращает эту ошибку.
+//
+// Конфликты проверяются оптимистично: если ключ, прочитанный fn через tx.Get, к фиксации
+// перезаписан, удалён или создан другой операцией, ничего не применяется и возвращается
+// ErrTxnConflict - транзакцию можно повторить. Ключи, которые fn только записывает, не
+// проверяются. Изменения счётчиков (Counter) меняют элемент на месте и конфликтом не считаются.
+//
+// WithFullPolicy действует на транзакцию целиком: если её записям не хватает места,
+// FullReject возвращает ErrFull без изменений, FullBlock ждёт места и повторяет фиксацию.
+//
+// Чтения в транзакции не меняют Views и статистику попаданий.
+func (s *Store) Txn(fn func(tx *Txn) error) error {
+	if err := s.enter(); err != nil {
+		return err
+	}
+	defer s.leave()
+
+	tx := &Txn{s: s, reads: make(map[string]*Item), writes: make(map[string]txnWrite)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.order) == 0 {
+		return nil
+	}
+	key, _ := s.userKey(tx.order[0])
+	return s.withRoom(key, nil, func() error { return tx.commit(key) })
+}
+
+// commit фиксирует записи транзакции, одна попытка для withRoom; key - ключ для WithOnFull
+func (tx *Txn) commit(key string) error {
+	s := tx.s
+	// место WithWriteConcurrency - только на фиксацию: fn может писать в хранилище сама
+	s.acquireWrite(nil) // без ctx не возвращает ошибку
+	defer s.releaseWrite()
+
+	queued := make([]bool, len(tx.order))
+	for i := range queued {
+		queued[i] = s.sinkReserve()
+	}
+	release := func() {
+		for _, q := range queued {
+			s.sinkRelease(q)
+		}
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	for raw, seen := range tx.reads {
+		if s.data[raw] != seen {
+			s.mu.Unlock()
+			release()
+			return ErrTxnConflict
+		}
+	}
+	writes := make([]batchWrite, len(tx.order))
+	for i, raw := range tx.order {
+		w := tx.writes[raw]
+		if w.item != nil {
+			w.item.ExpiresAt, w.item.UpdatedAt = expiresAt(now, s.effectiveTTL(w.ttl)), now
+		}
+		writes[i] = batchWrite{raw: raw, item: w.item}
+	}
+	admitted, full, err := s.placeBatchLocked(writes, now)
+	if err != nil {
+		s.mu.Unlock()
+		release()
+		s.placed(key, nil, err)
+		return err
+	}
+	for i, raw := range tx.order {
+		w := tx.writes[raw]
+		if w.item == nil {
+			if s.removeLocked(raw, EventDelete) {
+				s.stats.deletes.Add(1)
+			}
+			if queued[i] {
+				s.sinkAppendLocked(EventDelete, raw, nil, now)
+			}
+			continue
+		}
+		if !admitted[i] {
+			continue // отклонил фильтр допуска, место приёмника вернём после блокировки
+		}
+		s.putLocked(raw, w.item)
+		if queued[i] {
+			s.sinkAppendLocked(EventSet, raw, w.item, now)
+		}
+	}
+	for i, raw := range tx.order {
+		if tx.writes[raw].item != nil && admitted[i] {
+			s.evictLocked(now, raw)
+		}
+	}
+	s.mu.Unlock()
+	s.placed(key, full, nil)
+
+	for i, raw := range tx.order {
+		if !admitted[i] {
+			s.sinkRelease(queued[i])
+			continue
+		}
+		userKey, _ := s.userKey(raw)
+		if tx.writes[raw].item == nil {
+			s.audit(AuditDelete, userKey, writeOpts{})
+			continue
+		}
+		s.stats.sets.Add(1)
+		s.resolveMiss(raw)
+		s.push(raw)
+		s.audit(AuditSet, userKey, writeOpts{})
+	}
+	return nil
+}
+
+// Get возвращает значение ключа с учётом записей транзакции. Повторное чтение ключа
+// возвращает то же, что и первое, даже если хранилище за это время изменилось.
+func (tx *Txn) Get(key string) (string, bool) {
+	raw := tx.s.skey(key)
+	if w, ok := tx.writes[raw]; ok {
+		if w.item == nil {
+			return "", false
+		}
+		return w.item.text(), true
+	}
+
+	item, seen := tx.reads[raw]
+	if !seen {
+		tx.s.mu.RLock()
+		item = tx.s.data[raw]
+		tx.s.mu.RUnlock()
+		tx.reads[raw] = item
+	}
+	if item == nil || item.expiredAt(tx.s.now()) {
+		return "", false
+	}
+	return item.text(), true
+}
+
+// Set откладывает запись значения до фиксации транзакции, TTL как у Store.Set.
+// Ошибка политики ключей или размера значения возвращается сразу.
+func (tx *Txn) Set(key, value string, ttl time.Duration) error {
+	s := tx.s
+	if err := s.checkWrite(key, ttl); err != nil {
+		return err
+	}
+	if err := s.checkValueSize(key, len(value)); err != nil {
+		return err
+	}
+
+	item := s.newItem()
+	s.setCompressed(item, value)
+	tx.put(s.wkey(key), txnWrite{item: item, ttl: ttl})
+	return nil
+}
+
+// Delete откладывает удаление ключа до фиксации транзакции.
+func (tx *Txn) Delete(key string) {
+	tx.put(tx.s.skey(key), txnWrite{})
+}
+
+func (tx *Txn) put(raw string, w txnWrite) {
+	if _, ok := tx.writes[raw]; !ok {
+		tx.order = append(tx.order, raw)
+	}
+	tx.writes[raw] = w
+}
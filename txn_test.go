@@ -0,0 +1,163 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func wantValue(t *testing.T, s *Store, key, want string) {
+	t.Helper()
+	if got, ok := s.Get(key); !ok || got != want {
+		t.Errorf("Get(%q) = %q, %v, want %q", key, got, ok, want)
+	}
+}
+
+func wantMissing(t *testing.T, s *Store, key string) {
+	t.Helper()
+	if got, ok := s.Get(key); ok {
+		t.Errorf("Get(%q) = %q, want missing", key, got)
+	}
+}
+
+func TestTxnCommit(t *testing.T) {
+	s := newTestStore(t)
+	s.Set("index", "1", 0)
+	s.Set("old", "x", 0)
+
+	err := s.Txn(func(tx *Txn) error {
+		n, _ := tx.Get("index")
+		if err := tx.Set("index", n+"2", 0); err != nil {
+			return err
+		}
+		if err := tx.Set("value", "v", 0); err != nil {
+			return err
+		}
+		tx.Delete("old")
+		// транзакция видит свои записи, хранилище - ещё нет
+		if v, ok := tx.Get("value"); !ok || v != "v" {
+			t.Errorf("tx.Get(value) = %q, %v inside the transaction", v, ok)
+		}
+		if _, ok := tx.Get("old"); ok {
+			t.Error("tx.Get sees a key deleted in the transaction")
+		}
+		wantMissing(t, s, "value")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, s, "index", "12")
+	wantValue(t, s, "value", "v")
+	wantMissing(t, s, "old")
+}
+
+func TestTxnConflictRollsBack(t *testing.T) {
+	s := newTestStore(t)
+	s.Set("a", "1", 0)
+	s.Set("b", "1", 0)
+
+	err := s.Txn(func(tx *Txn) error {
+		tx.Get("a")
+		tx.Set("a", "tx", 0)
+		tx.Set("b", "tx", 0)
+		tx.Delete("c")
+		s.Set("a", "other", 0) // прочитанный ключ меняет другая запись
+		return nil
+	})
+	if !errors.Is(err, ErrTxnConflict) {
+		t.Fatalf("Txn = %v, want ErrTxnConflict", err)
+	}
+	wantValue(t, s, "a", "other")
+	wantValue(t, s, "b", "1")
+}
+
+func TestTxnConflictOnDeletedAndCreatedKeys(t *testing.T) {
+	s := newTestStore(t)
+	s.Set("present", "1", 0)
+
+	for _, tc := range []struct {
+		name   string
+		key    string
+		change func()
+	}{
+		{"deleted", "present", func() { s.Delete("present") }},
+		{"created", "absent", func() { s.Set("absent", "1", 0) }},
+	} {
+		err := s.Txn(func(tx *Txn) error {
+			tx.Get(tc.key)
+			tx.Set("result", tc.name, 0)
+			tc.change()
+			return nil
+		})
+		if !errors.Is(err, ErrTxnConflict) {
+			t.Errorf("%s: Txn = %v, want ErrTxnConflict", tc.name, err)
+		}
+		wantMissing(t, s, "result")
+	}
+}
+
+func TestTxnWriteOnlyKeysDoNotConflict(t *testing.T) {
+	s := newTestStore(t)
+	err := s.Txn(func(tx *Txn) error {
+		tx.Set("k", "tx", 0)
+		s.Set("k", "other", 0) // ключ не читали - последней будет запись транзакции
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, s, "k", "tx")
+}
+
+func TestTxnErrorDiscardsWrites(t *testing.T) {
+	s := newTestStore(t)
+	s.Set("a", "1", 0)
+
+	boom := errors.New("boom")
+	err := s.Txn(func(tx *Txn) error {
+		tx.Set("a", "tx", 0)
+		tx.Set("b", "tx", 0)
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("Txn = %v, want fn error", err)
+	}
+	wantValue(t, s, "a", "1")
+	wantMissing(t, s, "b")
+}
+
+func TestTxnRetryAfterConflict(t *testing.T) {
+	s := newTestStore(t)
+	s.Set("n", "a", 0)
+
+	attempts := 0
+	for {
+		attempts++
+		err := s.Txn(func(tx *Txn) error {
+			v, _ := tx.Get("n")
+			if attempts == 1 {
+				s.Set("n", "b", 0)
+			}
+			return tx.Set("n", v+"+", 0)
+		})
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrTxnConflict) || attempts > 2 {
+			t.Fatalf("attempt %d: %v", attempts, err)
+		}
+	}
+	if attempts != 2 {
+		t.Errorf("committed after %d attempts, want 2", attempts)
+	}
+	wantValue(t, s, "n", "b+")
+}
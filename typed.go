@@ -0,0 +1,57 @@
+package store
+
+import "time"
+
+// TypedNamespace - типизированное представление пространства имён: Get и Set работают
+// со значениями T, сериализуя их через Codec. Несколько представлений одного пространства
+// с разными T допустимы, но читать чужие значения - ошибка декодирования.
+type TypedNamespace[T any] struct {
+	ns    *Namespace
+	codec Codec
+}
+
+// NewTypedNamespace возвращает представление пространства name хранилища s со значениями T.
+// codec == nil - Codec пространства (WithNamespaceCodec, иначе Codec хранилища), на настройки
+// самого пространства codec не влияет. Опции применяются как в Store.Namespace.
+//
+// Методы в Go не бывают обобщёнными, поэтому это функция, а не Store.TypedNamespace.
+func NewTypedNamespace[T any](s *Store, name string, codec Codec, opts ...NamespaceOption) *TypedNamespace[T] {
+	return &TypedNamespace[T]{ns: s.Namespace(name, opts...), codec: codec}
+}
+
+// Namespace возвращает нетипизированное пространство, на котором построено представление.
+func (t *TypedNamespace[T]) Namespace() *Namespace {
+	return t.ns
+}
+
+// Get читает и декодирует значение ключа. false без ошибки - ключа нет или он истёк,
+// ошибка декодирования приходит вместе с true, как в GetJSON.
+func (t *TypedNamespace[T]) Get(key string) (T, bool, error) {
+	var v T
+	data, ok := t.ns.Get(key)
+	if !ok {
+		return v, false, nil
+	}
+	return v, true, unmarshalValue(t.valueCodec(), key, data, &v)
+}
+
+// Set кодирует v и сохраняет как Namespace.Set, ttl == 0 - TTL пространства по умолчанию.
+func (t *TypedNamespace[T]) Set(key string, v T, ttl time.Duration) error {
+	data, err := marshalValue(t.valueCodec(), key, v)
+	if err != nil {
+		return err
+	}
+	return t.ns.Set(key, data, ttl)
+}
+
+// Delete удаляет ключ пространства.
+func (t *TypedNamespace[T]) Delete(key string) {
+	t.ns.Delete(key)
+}
+
+func (t *TypedNamespace[T]) valueCodec() Codec {
+	if t.codec != nil {
+		return t.codec
+	}
+	return t.ns.valueCodec()
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrVersionMismatch - версия ключа не совпала с ожидаемой в SetIfVersion.
+var ErrVersionMismatch = errors.New("store: version mismatch")
+
+// GetWithVersion возвращает значение ключа, как Get, и его версию. Версии растут монотонно
+// в пределах хранилища с каждой записью ключа (Set, Expire, HSet, ...), так что изменение
+// версии значит, что ключ перезаписали. Изменения счётчика Counter версию не меняют.
+func (s *Store) GetWithVersion(key string) (string, uint64, bool) {
+	if t, ok := s.startOp(opGet, key); ok {
+		defer t.stop()
+	}
+	item, ok := s.getItem(s.skey(key))
+	if !ok {
+		return "", 0, false
+	}
+	return item.text(), item.Version, true
+}
+
+// SetIfVersion записывает значение, только если текущая версия ключа равна expected
+// (версию возвращает GetWithVersion), expected == 0 - только если ключа нет или он истёк.
+// Так внешний писатель, прочитавший значение, не затрёт чужую запись, сделанную после
+// его чтения: при несовпадении возвращается ErrVersionMismatch и значение нужно перечитать.
+func (s *Store) SetIfVersion(key, value string, ttl time.Duration, expected uint64) error {
+	if t, ok := s.startOp(opSet, key); ok {
+		defer t.stop()
+	}
+	if err := s.checkWrite(key, ttl); err != nil {
+		return err
+	}
+	if err := s.checkValueSize(key, len(value)); err != nil {
+		return err
+	}
+	if err := s.beginWrite(writeOpts{}); err != nil {
+		return err
+	}
+	defer s.endWrite()
+
+	key = s.wkey(key)
+	item := s.newItem()
+	s.setCompressed(item, value)
+
+	now := s.now()
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	if s.versionLocked(key, now) != expected {
+		s.mu.Unlock()
+		s.sinkRelease(queued)
+		return ErrVersionMismatch
+	}
+	item.ExpiresAt, item.UpdatedAt = expiresAt(now, s.effectiveTTL(ttl)), now
+	s.putLocked(key, item)
+	if queued {
+		s.sinkAppendLocked(EventSet, key, item, now)
+	}
+	s.evictLocked(now, key)
+	s.mu.Unlock()
+
+	s.stats.sets.Add(1)
+	s.resolveMiss(key)
+	s.push(key)
+	return nil
+}
+
+// versionLocked - версия ключа хранения key, 0 - ключа нет или он истёк. Вызывается под s.mu
+func (s *Store) versionLocked(key string, now time.Time) uint64 {
+	if cur, ok := s.data[key]; ok && !cur.expiredAt(now) {
+		return cur.Version
+	}
+	return 0
+}
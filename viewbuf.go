@@ -0,0 +1,114 @@
+package store
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// viewShards - число полос буфера WithBufferedViews
+const viewShards = 64
+
+// WithBufferedViews копит просмотры Get в буфере и добавляет их к Views раз в flush. На сотнях
+// тысяч чтений одного горячего ключа в секунду атомарный счётчик Views на каждый Get гоняет
+// строку кеша между ядрами; буфер раскладывает чтения по полосам случайно, а не по ключу,
+// так что и один ключ читают без общей точки записи.
+//
+// GetViews складывает Views с ещё не добавленными просмотрами и точен. Остальные, кто видит
+// Views (листинги, TopViewed, Query.MinViews, снапшот, затухание), отстают не больше чем
+// на flush; Cleanup добавляет буфер в начале прохода. Ключи с лимитом SetWithMaxViews
+// считаются сразу, как без буфера. Несовместим с WithDeterministic. 0 - без буфера.
+func WithBufferedViews(flush time.Duration) Option {
+	return func(c *config) {
+		c.viewFlush = flush
+	}
+}
+
+// viewBuffer - буфер просмотров WithBufferedViews
+type viewBuffer struct {
+	shards [viewShards]viewShard
+}
+
+// viewShard - полоса буфера на своей строке кеша
+type viewShard struct {
+	mu sync.Mutex
+	m  map[string]uint64 // ключ хранения - просмотров
+	_  [48]byte
+}
+
+func (b *viewBuffer) add(key string) {
+	sh := &b.shards[rand.Uint32()%viewShards]
+	sh.mu.Lock()
+	if sh.m == nil {
+		sh.m = make(map[string]uint64)
+	}
+	sh.m[key]++
+	sh.mu.Unlock()
+}
+
+// pending - ещё не добавленные к Views просмотры ключа
+func (b *viewBuffer) pending(key string) uint64 {
+	var n uint64
+	for i := range b.shards {
+		sh := &b.shards[i]
+		sh.mu.Lock()
+		n += sh.m[key]
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// countView отмечает чтение элемента и возвращает новое число просмотров для consumeView.
+// С WithBufferedViews просмотр уходит в буфер, а результат - 0: лимита просмотров у
+// такого элемента нет
+func (s *Store) countView(key string, item *Item, now time.Time) uint64 {
+	if s.viewBuf == nil || item.maxViews > 0 {
+		return item.touch(now)
+	}
+	if ns := now.UnixNano(); item.accessedAt.Load() != ns {
+		item.accessedAt.Store(ns) // с WithCoarseClock время чаще всего то же
+	}
+	item.markRead()
+	s.viewBuf.add(key)
+	return 0
+}
+
+// flushViews добавляет буфер WithBufferedViews к Views элементов. Просмотры ключей, которых
+// уже нет, отбрасываются, а перезаписанный ключ получает просмотры прежнего значения
+func (s *Store) flushViews() {
+	b := s.viewBuf
+	if b == nil {
+		return
+	}
+	for i := range b.shards {
+		sh := &b.shards[i]
+		sh.mu.Lock()
+		m := sh.m
+		sh.m = nil
+		sh.mu.Unlock()
+		if len(m) == 0 {
+			continue
+		}
+		s.mu.RLock()
+		for key, n := range m {
+			if item, ok := s.data[key]; ok {
+				item.Views.Add(n)
+			}
+		}
+		s.mu.RUnlock()
+	}
+}
+
+// runViewFlush периодически вызывает flushViews, пока хранилище не закрыто
+func (s *Store) runViewFlush(t Ticker) {
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			s.flushViews()
+		case <-s.life.done:
+			s.flushViews()
+			return
+		}
+	}
+}
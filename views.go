@@ -0,0 +1,106 @@
+package store
+
+import (
+	"math"
+	"time"
+)
+
+// SetWithMaxViews сохраняет значение как Set, но ключ удаляется после maxViews чтений через Get:
+// maxViews = 1 - одноразовый секрет или ссылка. Чтения считаются атомарно, поэтому при
+// конкурентных Get значение получат ровно maxViews читателей, остальные получат промах.
+// maxViews = 0 - без ограничения, как Set.
+func (s *Store) SetWithMaxViews(key, value string, ttl time.Duration, maxViews uint64) error {
+	return s.set(key, value, ttl, writeOpts{maxViews: maxViews})
+}
+
+// consumeView учитывает чтение элемента с лимитом просмотров. false - просмотры уже исчерпаны
+// другими читателями. Последний просмотр удаляет ключ key
+func (s *Store) consumeView(key string, item *Item, views uint64) bool {
+	if item.maxViews == 0 || views < item.maxViews {
+		return true
+	}
+	if views > item.maxViews {
+		return false
+	}
+
+	queued := s.sinkReserve()
+	s.mu.Lock()
+	if cur, ok := s.data[key]; ok && cur == item && s.removeLocked(key, EventDelete) {
+		s.stats.deletes.Add(1)
+		if queued {
+			s.sinkAppendLocked(EventDelete, key, nil, s.now())
+		}
+		queued = false
+	}
+	s.mu.Unlock()
+	s.sinkRelease(queued)
+	s.cfg.logger.Debug("store: key removed after last view", "key", key)
+	return true
+}
+
+// WithViewsDecay включает экспоненциальное затухание Views с периодом полураспада halfLife:
+// каждый проход Cleanup умножает просмотры на 0.5^(прошло/halfLife). Так Views и TopViewed
+// отражают популярность за последнее время, а не за всю жизнь ключа. Без Cleanup затухания нет.
+func WithViewsDecay(halfLife time.Duration) Option {
+	return func(c *config) {
+		c.viewsHalfLife = halfLife
+	}
+}
+
+// ResetViews обнуляет просмотры ключа, false - ключа нет. Просмотры ключа из SetWithMaxViews
+// не сбрасываются: по ним считается лимит чтений.
+func (s *Store) ResetViews(key string) bool {
+	item, ok := s.loadItem(s.skey(key))
+
+	if !ok || item.expiredAt(s.now()) {
+		return false
+	}
+	if item.maxViews == 0 {
+		item.Views.Store(0)
+	}
+	return true
+}
+
+// ResetAllViews обнуляет просмотры всех ключей текущей версии схемы и возвращает их число.
+func (s *Store) ResetAllViews() int {
+	return s.scaleViews(0)
+}
+
+// DecayViews умножает просмотры всех ключей на factor из [0, 1], как один шаг WithViewsDecay.
+func (s *Store) DecayViews(factor float64) {
+	s.scaleViews(min(max(factor, 0), 1))
+}
+
+// scaleViews умножает Views на factor. Views меняется на месте атомарно, поэтому хватает RLock,
+// а CAS не теряет просмотры, добавленные конкурентными Get
+func (s *Store) scaleViews(factor float64) int {
+	n := 0
+	s.mu.RLock()
+	for raw, item := range s.data {
+		if _, ok := s.userKey(raw); !ok || item.maxViews > 0 {
+			continue
+		}
+		for {
+			old := item.Views.Load()
+			if item.Views.CompareAndSwap(old, uint64(float64(old)*factor)) {
+				break
+			}
+		}
+		n++
+	}
+	s.mu.RUnlock()
+	return n
+}
+
+// decayViews применяет WithViewsDecay за время с прошлого прохода, вызывается из cleanupPass
+func (s *Store) decayViews(now time.Time) {
+	if s.cfg.viewsHalfLife <= 0 {
+		return
+	}
+	last := s.lastDecay.Swap(now.UnixNano())
+	if last == 0 {
+		return // первый проход только запоминает время
+	}
+	elapsed := time.Duration(now.UnixNano() - last)
+	s.scaleViews(math.Exp2(-float64(elapsed) / float64(s.cfg.viewsHalfLife)))
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const defaultWarmConcurrency = 8
+
+// WarmProgress - ход прогрева, см. Warm.
+type WarmProgress struct {
+	Total    int `json:"total"`    // ключей к прогреву
+	Done     int `json:"done"`     // из них обработано
+	Loaded   int `json:"loaded"`   // загружено и записано
+	Skipped  int `json:"skipped"`  // уже были в хранилище, см. WithWarmOverwrite
+	NotFound int `json:"notFound"` // загрузчик вернул ErrNotFound
+	Failed   int `json:"failed"`   // загрузчик или запись вернули ошибку
+}
+
+// WarmOption настраивает Warm.
+type WarmOption func(*warmConfig)
+
+type warmConfig struct {
+	concurrency int
+	progress    func(WarmProgress)
+	overwrite   bool
+}
+
+// WithWarmConcurrency задаёт число одновременных вызовов загрузчика, по умолчанию 8.
+func WithWarmConcurrency(n int) WarmOption {
+	return func(c *warmConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithWarmProgress вызывает fn после каждого обработанного ключа. Вызовы не пересекаются,
+// но идут из рабочих горутин, так что fn не должна надолго блокироваться.
+func WithWarmProgress(fn func(WarmProgress)) WarmOption {
+	return func(c *warmConfig) {
+		c.progress = fn
+	}
+}
+
+// WithWarmOverwrite загружает и ключи, которые уже есть в хранилище. По умолчанию они
+// пропускаются, что-бы повторный прогрев не ходил в бэкенд зря.
+func WithWarmOverwrite() WarmOption {
+	return func(c *warmConfig) {
+		c.overwrite = true
+	}
+}
+
+// Warm заранее загружает keys через load ограниченным пулом горутин, что-бы сервис принял
+// трафик с уже горячим кешем. Значения записываются с TTL загрузчика, как в LoadingStore.
+// Для прогрева из набора фикстур подходят LoadJSONL и LoadCSV.
+//
+// Ошибки отдельных ключей прогрев не останавливают: Warm возвращает итоговый WarmProgress
+// и ошибку с числом неудачных ключей и первой из ошибок. ErrNotFound ошибкой не считается.
+// После отмены ctx новые ключи не берутся, а Warm возвращает ctx.Err().
+func (s *Store) Warm(ctx context.Context, keys []string, load LoaderFunc, opts ...WarmOption) (WarmProgress, error) {
+	cfg := warmConfig{concurrency: defaultWarmConcurrency}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.concurrency <= 0 {
+		cfg.concurrency = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		progress = WarmProgress{Total: len(keys)}
+		first    error
+	)
+	report := func(apply func(p *WarmProgress), err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		apply(&progress)
+		progress.Done++
+		if err != nil && first == nil {
+			first = err
+		}
+		if cfg.progress != nil {
+			cfg.progress(progress)
+		}
+	}
+
+	if s.cfg.deterministic {
+		// по одному ключу в горутине вызывающего, см. WithDeterministic
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				break
+			}
+			s.warmKey(ctx, key, load, cfg.overwrite, report)
+		}
+	} else {
+		work := make(chan string)
+		var wg sync.WaitGroup
+		for range min(cfg.concurrency, len(keys)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for key := range work {
+					s.warmKey(ctx, key, load, cfg.overwrite, report)
+				}
+			}()
+		}
+
+	feed:
+		for _, key := range keys {
+			select {
+			case work <- key:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(work)
+		wg.Wait()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return progress, err
+	}
+	if first != nil {
+		return progress, fmt.Errorf("store: warm: %d of %d keys failed, first: %w", progress.Failed, progress.Total, first)
+	}
+	s.cfg.logger.Info("store: warm-up finished", "keys", progress.Total, "loaded", progress.Loaded, "skipped", progress.Skipped)
+	return progress, nil
+}
+
+// warmKey загружает один ключ и сообщает результат в report
+func (s *Store) warmKey(ctx context.Context, key string, load LoaderFunc, overwrite bool, report func(func(*WarmProgress), error)) {
+	if !overwrite && s.present(key) {
+		report(func(p *WarmProgress) { p.Skipped++ }, nil)
+		return
+	}
+
+	started := s.wallNow()
+	value, ttl, err := load(ctx, key)
+	if err == nil {
+		err = s.set(key, value, ttl, writeOpts{loadCost: s.wallNow().Sub(started), internal: true})
+	}
+	switch {
+	case err == nil:
+		report(func(p *WarmProgress) { p.Loaded++ }, nil)
+	case errors.Is(err, ErrNotFound):
+		report(func(p *WarmProgress) { p.NotFound++ }, nil)
+	default:
+		report(func(p *WarmProgress) { p.Failed++ }, fmt.Errorf("key %q: %w", key, err))
+	}
+}
+
+// present проверяет, что ключ есть и не истёк, не трогая статистику чтений и просмотры
+func (s *Store) present(key string) bool {
+	now := s.now()
+	item, ok := s.loadItem(s.skey(key))
+	return ok && !item.expiredAt(now)
+}
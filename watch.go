@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType - вид изменения ключа.
+type EventType int
+
+const (
+	// EventSet - значение записано (Set, SetNX/SetXX, IncrBy, Expire, загрузка снапшота).
+	EventSet EventType = iota + 1
+	// EventDelete - ключ удален явно (Delete, RetrieveLastKey, Reset).
+	EventDelete
+	// EventExpire - ключ удален после истечения TTL (в Get или Cleanup).
+	EventExpire
+	// EventEvict - ключ вытеснен лимитом памяти.
+	EventEvict
+	// EventFlush - хранилище очищено целиком (Reset, ResetAll). Только для приёмника WithSink:
+	// подписчики получают EventDelete на каждый ключ.
+	EventFlush
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	case EventFlush:
+		return "flush"
+	default:
+		return "unknown"
+	}
+}
+
+// Event - изменение ключа. Type - причина изменения, Value - новое значение для EventSet
+// (для остальных пусто), OldValue - значение до изменения, пусто если ключа не было.
+type Event struct {
+	Type     EventType `json:"type"`
+	Key      string    `json:"key"`
+	Value    string    `json:"value,omitempty"`
+	OldValue string    `json:"oldValue,omitempty"`
+	At       time.Time `json:"at"`
+
+	Attrs map[string]string `json:"attrs,omitempty"` // атрибуты записи значения, для удаления - удалённого, см. WithAttrs
+}
+
+// ErrSlowConsumer - подписка с DisconnectSlow закрыта, потому что подписчик не успевал читать.
+var ErrSlowConsumer = errors.New("store: subscriber is too slow, subscription closed")
+
+// DropPolicy - что делать с событием, если буфер подписчика заполнен.
+// Хранилище никогда не ждёт медленного подписчика, запись не блокируется.
+type DropPolicy int
+
+const (
+	// DropNewest отбрасывает новое событие, в буфере остаются старые (по умолчанию).
+	DropNewest DropPolicy = iota
+	// DropOldest отбрасывает самое старое событие из буфера, что-бы положить новое.
+	DropOldest
+	// DisconnectSlow закрывает подписку при первом переполнении: подписчик дочитывает буфер,
+	// видит закрытый канал и Err() == ErrSlowConsumer. Подходит для CDC и аудита,
+	// где пропуск событий недопустим и лучше переподписаться с полной синхронизацией.
+	DisconnectSlow
+)
+
+const defaultWatchBuffer = 64
+
+// WatchOption настраивает подписку Watch и WatchPrefix.
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	buffer int
+	drop   DropPolicy
+}
+
+// WithWatchBuffer задаёт размер буфера канала событий, по умолчанию 64.
+func WithWatchBuffer(n int) WatchOption {
+	return func(c *watchConfig) {
+		c.buffer = n
+	}
+}
+
+// WithDropPolicy задаёт поведение при переполненном буфере, по умолчанию DropNewest.
+func WithDropPolicy(p DropPolicy) WatchOption {
+	return func(c *watchConfig) {
+		c.drop = p
+	}
+}
+
+// watcher - подписчик на изменения ключей, match получает пользовательский ключ
+type watcher struct {
+	match func(key string) bool
+	ch    chan Event
+	drop  DropPolicy
+	done  chan struct{} // закрывается вместе с ch
+
+	dropped atomic.Uint64
+	slow    atomic.Bool
+}
+
+// Subscription - подписка на поток изменений хранилища, см. Subscribe.
+type Subscription struct {
+	s *Store
+	w *watcher
+}
+
+// Events возвращает канал событий. Канал закрывается после Unsubscribe
+// или при отключении медленного подписчика (DisconnectSlow).
+func (sub *Subscription) Events() <-chan Event {
+	return sub.w.ch
+}
+
+// Unsubscribe отменяет подписку и закрывает канал событий. Повторный вызов ничего не делает.
+func (sub *Subscription) Unsubscribe() {
+	sub.s.unwatch(sub.w)
+}
+
+// Dropped возвращает, сколько событий подписка потеряла из-за переполненного буфера.
+func (sub *Subscription) Dropped() uint64 {
+	return sub.w.dropped.Load()
+}
+
+// Err возвращает ErrSlowConsumer, если подписка закрыта из-за медленного подписчика.
+func (sub *Subscription) Err() error {
+	if sub.w.slow.Load() {
+		return ErrSlowConsumer
+	}
+	return nil
+}
+
+// watchers - реестр подписчиков. Событие рассылается под mu.RLock, а подписчик удаляется
+// и его канал закрывается под mu.Lock, так отправка никогда не попадает в закрытый канал
+type watchers struct {
+	mu      sync.RWMutex
+	list    map[*watcher]struct{}
+	n       atomic.Int32 // быстрый путь: без подписчиков события даже не собираются
+	dropped atomic.Uint64
+}
+
+// Watch возвращает канал изменений ключа key. Канал закрывается после отмены ctx.
+// Если подписчик не успевает читать, события отбрасываются по WithDropPolicy.
+func (s *Store) Watch(ctx context.Context, key string, opts ...WatchOption) <-chan Event {
+	return s.watch(ctx, func(k string) bool { return k == key }, opts)
+}
+
+// WatchPrefix возвращает канал изменений всех ключей с префиксом prefix, "" - все ключи.
+func (s *Store) WatchPrefix(ctx context.Context, prefix string, opts ...WatchOption) <-chan Event {
+	return s.watch(ctx, func(k string) bool { return strings.HasPrefix(k, prefix) }, opts)
+}
+
+// Subscribe подписывает на все изменения ключей, подходящих под glob-шаблон (как в Keys),
+// со старым и новым значением и причиной изменения - для CDC и аудита.
+// Подписку нужно закрыть через Unsubscribe.
+func (s *Store) Subscribe(pattern string, opts ...WatchOption) *Subscription {
+	w := s.subscribe(func(k string) bool { return matchGlob(pattern, k) }, opts)
+	return &Subscription{s: s, w: w}
+}
+
+func (s *Store) watch(ctx context.Context, match func(string) bool, opts []WatchOption) <-chan Event {
+	w := s.subscribe(match, opts)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.unwatch(w)
+		case <-w.done:
+		}
+	}()
+	return w.ch
+}
+
+func (s *Store) subscribe(match func(string) bool, opts []WatchOption) *watcher {
+	cfg := watchConfig{buffer: defaultWatchBuffer}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	w := &watcher{
+		match: match,
+		ch:    make(chan Event, max(cfg.buffer, 1)),
+		drop:  cfg.drop,
+		done:  make(chan struct{}),
+	}
+
+	s.watchers.mu.Lock()
+	if s.watchers.list == nil {
+		s.watchers.list = make(map[*watcher]struct{})
+	}
+	s.watchers.list[w] = struct{}{}
+	s.watchers.n.Add(1)
+	s.watchers.mu.Unlock()
+
+	return w
+}
+
+// unwatch удаляет подписчика и закрывает его канал, если это ещё не сделано
+func (s *Store) unwatch(w *watcher) {
+	s.watchers.mu.Lock()
+	defer s.watchers.mu.Unlock()
+
+	if _, ok := s.watchers.list[w]; !ok {
+		return
+	}
+	delete(s.watchers.list, w)
+	s.watchers.n.Add(-1)
+	close(w.ch)
+	close(w.done)
+}
+
+// notifyLocked рассылает событие по сырому ключу, вызывается под s.mu.Lock,
+// поэтому события одного ключа приходят в порядке изменений
+func (s *Store) notifyLocked(typ EventType, raw, value, old string, attrs map[string]string) {
+	if s.watchers.n.Load() == 0 {
+		return
+	}
+	key, ok := s.userKey(raw)
+	if !ok {
+		return // ключ другой версии схемы, см. WithKeyVersion
+	}
+	ev := Event{Type: typ, Key: key, Value: value, OldValue: old, At: s.now(), Attrs: attrs}
+
+	s.watchers.mu.RLock()
+	for w := range s.watchers.list {
+		if w.match(key) && !s.watchers.send(w, ev) {
+			// закрыть канал под RLock нельзя, отключаем вне рассылки
+			go s.unwatch(w)
+		}
+	}
+	s.watchers.mu.RUnlock()
+}
+
+// send кладет событие в буфер подписчика без ожидания. Возвращает false,
+// если медленного подписчика нужно отключить
+func (ws *watchers) send(w *watcher, ev Event) bool {
+	if w.slow.Load() {
+		return true // уже отключается, новые события не нужны
+	}
+	select {
+	case w.ch <- ev:
+		return true
+	default:
+	}
+
+	switch w.drop {
+	case DisconnectSlow:
+		w.slow.Store(true)
+		w.dropped.Add(1)
+		ws.dropped.Add(1)
+		return false
+	case DropOldest:
+		// отправители сериализованы s.mu, так что место освобождаем только мы; читатель
+		// мог успеть забрать событие сам, тогда ничего не выбрасываем
+		select {
+		case <-w.ch:
+			w.dropped.Add(1)
+			ws.dropped.Add(1)
+		default:
+		}
+		select {
+		case w.ch <- ev:
+			return true
+		default:
+		}
+	}
+	w.dropped.Add(1)
+	ws.dropped.Add(1)
+	return true
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// WithWriteConcurrency ограничивает число одновременных записей значением n: Set и его
+// варианты (SetWithTags, SetWithMeta, загрузчики, Warm, ...), SetNX, SetXX, IncrBy, записи
+// коллекций, Expire, ExtendTTL, CompareAndDelete, Delete и остальные записи одного ключа
+// ждут в общей очереди. Массовые операции (Reset, ReplaceAll, загрузка снапшота) её не ждут.
+// Так массовая перезагрузка кеша сотнями горутин не забирает блокировку хранилища у
+// читателей и не переполняет очередь WithSink. TrySet в очереди ждёт не дольше своего ctx.
+// Глубину очереди показывает Stats().Writes. 0 - без ограничения.
+func WithWriteConcurrency(n int) Option {
+	return func(c *config) {
+		c.writeConcurrency = n
+	}
+}
+
+// WriteStats - состояние ограничения записей, см. WithWriteConcurrency.
+type WriteStats struct {
+	Limit      int    `json:"limit"`      // одновременных записей не больше
+	Active     int    `json:"active"`     // записей идёт сейчас
+	Queued     int    `json:"queued"`     // записей ждут своей очереди
+	PeakQueued int    `json:"peakQueued"` // наибольшая очередь с запуска
+	Waited     uint64 `json:"waited"`     // записей ждали в очереди, всего
+}
+
+// writeLimiter - семафор WithWriteConcurrency
+type writeLimiter struct {
+	slots  chan struct{}
+	queued atomic.Int64
+	peak   atomic.Int64
+	waited atomic.Uint64
+}
+
+// beginWrite - общий вход записи: enterWrite с параметрами w и место WithWriteConcurrency,
+// с w.lockCtx ожидание места не дольше ctx. nil - запись можно выполнять, в конце - endWrite
+func (s *Store) beginWrite(w writeOpts) error {
+	if err := s.enterWrite(w); err != nil {
+		return err
+	}
+	if err := s.acquireWrite(w.lockCtx); err != nil {
+		s.leave()
+		return err
+	}
+	return nil
+}
+
+// endWrite завершает beginWrite
+func (s *Store) endWrite() {
+	s.releaseWrite()
+	s.leave()
+}
+
+// acquireWrite занимает место под запись, ожидая очереди. С ctx (см. TrySet) ждёт
+// не дольше ctx и тогда возвращает ErrBusy. Каждому успешному acquireWrite - свой releaseWrite
+func (s *Store) acquireWrite(ctx context.Context) error {
+	l := s.writes
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if ctx != nil && ctx.Done() == nil {
+		return ErrBusy // ctx без дедлайна и отмены - не ждём, как в TrySet
+	}
+
+	l.waited.Add(1)
+	q := l.queued.Add(1)
+	defer l.queued.Add(-1)
+	for {
+		cur := l.peak.Load()
+		if q <= cur || l.peak.CompareAndSwap(cur, q) {
+			break
+		}
+	}
+
+	if ctx == nil {
+		l.slots <- struct{}{}
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ErrBusy
+	}
+}
+
+// releaseWrite освобождает место, занятое acquireWrite
+func (s *Store) releaseWrite() {
+	if s.writes != nil {
+		<-s.writes.slots
+	}
+}
+
+func (s *Store) writeStats() *WriteStats {
+	l := s.writes
+	if l == nil {
+		return nil
+	}
+	return &WriteStats{
+		Limit:      cap(l.slots),
+		Active:     len(l.slots),
+		Queued:     int(l.queued.Load()),
+		PeakQueued: int(l.peak.Load()),
+		Waited:     l.waited.Load(),
+	}
+}
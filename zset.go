@@ -0,0 +1,182 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"hash/maphash"
+	"math"
+	"strings"
+)
+
+// ErrInvalidScore - счёт элемента сортированного множества не конечное число (NaN или ±Inf):
+// такой счёт не сохранить в JSON снапшота.
+var ErrInvalidScore = errors.New("store: score must be a finite number")
+
+// ZMember - элемент сортированного множества со счётом.
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// zset - неизменяемое сортированное множество: два treap по одним приоритетам,
+// по элементу - для поиска счёта, по (счёт, элемент) - для диапазонов
+type zset struct {
+	byMember *tnode[string, float64]
+	byScore  *tnode[ZMember, struct{}]
+	n        int
+}
+
+// zprioSeed - приоритеты узлов берутся из хеша элемента со случайной затравкой процесса,
+// так дерево сбалансировано в среднем и подобрать имена под вырождение нельзя
+var zprioSeed = maphash.MakeSeed()
+
+func zcmpScore(a, b ZMember) int {
+	if c := cmp.Compare(a.Score, b.Score); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Member, b.Member)
+}
+
+func (z *zset) score(member string) (float64, bool) {
+	if z == nil {
+		return 0, false
+	}
+	return tget(z.byMember, member, strings.Compare)
+}
+
+// with возвращает множество, где member имеет счёт score
+func (z *zset) with(member string, score float64) *zset {
+	next := &zset{}
+	if z != nil {
+		*next = *z
+	}
+	if old, ok := next.score(member); ok {
+		next.byScore = tdel(next.byScore, ZMember{member, old}, zcmpScore)
+	} else {
+		next.n++
+	}
+	prio := maphash.String(zprioSeed, member)
+	next.byMember = tput(next.byMember, member, score, prio, strings.Compare)
+	next.byScore = tput(next.byScore, ZMember{member, score}, struct{}{}, prio, zcmpScore)
+	return next
+}
+
+// without возвращает множество без member
+func (z *zset) without(member string) *zset {
+	old, ok := z.score(member)
+	if !ok {
+		return z
+	}
+	return &zset{
+		byMember: tdel(z.byMember, member, strings.Compare),
+		byScore:  tdel(z.byScore, ZMember{member, old}, zcmpScore),
+		n:        z.n - 1,
+	}
+}
+
+func (z *zset) len() int {
+	if z == nil {
+		return 0
+	}
+	return z.n
+}
+
+// rangeByScore - элементы со счётом от min до max включительно по возрастанию счёта
+func (z *zset) rangeByScore(min, max float64) []ZMember {
+	out := []ZMember{}
+	if z == nil {
+		return out
+	}
+	tascend(z.byScore, ZMember{Score: min}, zcmpScore, func(m ZMember, _ struct{}) bool {
+		if m.Score > max {
+			return false
+		}
+		out = append(out, m)
+		return true
+	})
+	return out
+}
+
+// ZAdd записывает счета элементов сортированного множества key и возвращает, сколько
+// элементов добавлено (а не обновлено). Отсутствующий ключ создаётся с TTL по умолчанию
+// (WithDefaultTTL), у существующего TTL общий на всё множество и сохраняется.
+// Если ключ хранит не сортированное множество, возвращается ErrWrongType.
+//
+// Запись и удаление стоят O(log n): множество хранится в неизменяемом дереве.
+func (s *Store) ZAdd(key string, members map[string]float64) (int, error) {
+	for _, score := range members {
+		if !finite(score) {
+			return 0, ErrInvalidScore
+		}
+	}
+
+	added := 0
+	err := s.update(key, kindZSet, func(next *Item) bool {
+		added = 0 // fn может вызываться повторно, см. update
+		z := next.zset
+		for m, score := range members {
+			if old, ok := z.score(m); ok && old == score {
+				continue
+			} else if !ok {
+				added++
+			}
+			z = z.with(m, score)
+		}
+		changed := z != next.zset
+		next.zset = z
+		return changed
+	})
+	return added, err
+}
+
+// ZIncrBy прибавляет delta к счёту элемента (отсутствующий считается с нулём)
+// и возвращает новый счёт. Если счёт не конечный, возвращается ErrInvalidScore.
+func (s *Store) ZIncrBy(key, member string, delta float64) (float64, error) {
+	var score float64
+	var invalid bool
+	err := s.update(key, kindZSet, func(next *Item) bool {
+		old, _ := next.zset.score(member)
+		score = old + delta
+		if !finite(score) {
+			invalid = true
+			return false
+		}
+		next.zset = next.zset.with(member, score)
+		return true
+	})
+	if err == nil && invalid {
+		err = ErrInvalidScore
+	}
+	return score, err
+}
+
+// ZRem удаляет элементы из сортированного множества и возвращает, сколько из них было.
+// Пустое множество удаляется целиком. Если ключ другого типа, возвращается ErrWrongType.
+func (s *Store) ZRem(key string, members ...string) (int, error) {
+	removed := 0
+	err := s.update(key, kindZSet, func(next *Item) bool {
+		for _, m := range members {
+			if _, ok := next.zset.score(m); ok {
+				next.zset = next.zset.without(m)
+				removed++
+			}
+		}
+		return removed > 0
+	})
+	return removed, err
+}
+
+// ZRangeByScore возвращает элементы со счётом от min до max включительно, по возрастанию
+// счёта, при равных счетах - по имени. Для открытых границ подходят math.Inf(-1) и math.Inf(1).
+// nil - ключа нет, он истёк или хранит не сортированное множество.
+func (s *Store) ZRangeByScore(key string, min, max float64) []ZMember {
+	item, ok := s.view(key, kindZSet)
+	if !ok {
+		return nil
+	}
+	return item.zset.rangeByScore(min, max)
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}